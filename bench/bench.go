@@ -0,0 +1,237 @@
+// Package bench measures inco on synthetic modules of a given size, so
+// that changes made for speed (parallelism, caching) can be checked, and
+// kept, against numbers:
+//
+//	dir := b.TempDir()
+//	if err := bench.Generate(dir, 1000); err != nil { ... }
+//	r := bench.Run(dir)
+//
+// The benchmarks of this package run the same measurements under go test
+// -bench; inco bench-self prints them and compares them with a baseline.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imnive-design/inco-go/internal/inco"
+)
+
+// Sizes are the module sizes, in files, inco bench-self measures by
+// default.
+var Sizes = []int{100, 1000, 10000}
+
+// filesPerPackage is how many files Generate puts in each package.
+const filesPerPackage = 20
+
+// Generate writes a module of n Go files to dir: packages of
+// filesPerPackage files, each file declaring a type with a method and a
+// few functions, most of them with contracts of the common kinds
+// (preconditions with and without messages, -return, @ensure -closed),
+// and importing the previous package so the module has a package graph.
+func Generate(dir string, n int) error {
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module benchmod\n\ngo 1.21\n"), 0o644); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		pkg := i / filesPerPackage
+		pkgDir := filepath.Join(dir, fmt.Sprintf("pkg%04d", pkg))
+		if i%filesPerPackage == 0 {
+			if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(filepath.Join(pkgDir, fmt.Sprintf("file%03d.go", i%filesPerPackage)), []byte(source(pkg, i)), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// source returns the content of the i-th file, in package number pkg.
+func source(pkg, i int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package pkg%04d\n\nimport (\n\t\"errors\"\n\t\"fmt\"\n\t\"io\"\n", pkg)
+	if pkg > 0 && i%filesPerPackage == 0 {
+		fmt.Fprintf(&b, "\n\tprev \"benchmod/pkg%04d\"\n", pkg-1)
+	}
+	b.WriteString(")\n\n")
+	if pkg > 0 && i%filesPerPackage == 0 {
+		fmt.Fprintf(&b, "var _ = prev.Sum%d\n\n", i-filesPerPackage)
+	}
+	fmt.Fprintf(&b, `type Store%[1]d struct {
+	items map[string]int
+	limit int
+}
+
+func (s *Store%[1]d) Put(key string, v int) error {
+	// @inco: s != nil && s.items != nil, -panic("nil store")
+	// @inco: key != "", -return(errors.New("empty key"))
+	// @inco: len(s.items) < s.limit, -return(fmt.Errorf("store full: %%d items", len(s.items)))
+	s.items[key] = v
+	return nil
+}
+
+func Sum%[1]d(xs []int, max int) int {
+	// @inco: max > 0
+	total := 0
+	for _, x := range xs {
+		// @invariant total >= 0
+		if x > 0 {
+			total += x
+		}
+	}
+	// @inco: total <= max*len(xs), -panic(fmt.Sprintf("sum %%d over %%d", total, max))
+	return total
+}
+
+func Copy%[1]d(w io.Writer, r io.ReadCloser) error {
+	// @inco: w != nil && r != nil
+	// @ensure -closed r
+	_, err := io.Copy(w, r)
+	r.Close()
+	return err
+}
+
+func label%[1]d(n int) string {
+	return fmt.Sprint("item ", n)
+}
+`, i)
+	return b.String()
+}
+
+// ---------------------------------------------------------------------------
+// Measurements
+// ---------------------------------------------------------------------------
+
+// Result is what Run measured on a module.
+type Result struct {
+	Files   int           `json:"files"`
+	ColdGen time.Duration `json:"cold_gen_ns"` // inco gen with an empty cache
+	WarmGen time.Duration `json:"warm_gen_ns"` // inco gen again, every shadow cached
+	Audit   time.Duration `json:"audit_ns"`
+}
+
+// Run measures inco gen on the module in dir, first with an empty cache and
+// then with every shadow cached, and inco audit. It removes the cache
+// first and leaves the second generation's in place.
+func Run(dir string) Result {
+	e := inco.NewEngine(dir)
+	os.RemoveAll(e.CacheDir)
+	r := Result{Files: countFiles(dir)}
+	r.ColdGen = timeGen(dir)
+	r.WarmGen = timeGen(dir)
+	start := time.Now()
+	inco.Audit(dir, inco.Limits{})
+	r.Audit = time.Since(start)
+	return r
+}
+
+// timeGen returns how long one generation of the module in dir takes.
+func timeGen(dir string) time.Duration {
+	e := inco.NewEngine(dir)
+	e.Quiet = true
+	start := time.Now()
+	e.Run()
+	return time.Since(start)
+}
+
+// countFiles returns the number of Go files under dir.
+func countFiles(dir string) int {
+	n := 0
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".go") {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+// WriteTable writes results as a table, with the time per file, and next
+// to each measurement its ratio to the one of the same size in baseline,
+// when there is one.
+func WriteTable(w io.Writer, results, baseline []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "files\tcold gen\twarm gen\taudit\tcold gen/file\t\n")
+	for _, r := range results {
+		base, _ := find(baseline, r.Files)
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t\n", r.Files,
+			compared(r.ColdGen, base.ColdGen), compared(r.WarmGen, base.WarmGen), compared(r.Audit, base.Audit),
+			(r.ColdGen / time.Duration(max(r.Files, 1))).Round(time.Microsecond))
+	}
+	return tw.Flush()
+}
+
+// compared formats d, and its ratio to base when base is set.
+func compared(d, base time.Duration) string {
+	s := d.Round(time.Millisecond).String()
+	if base > 0 {
+		s += fmt.Sprintf(" (%.2fx)", float64(d)/float64(base))
+	}
+	return s
+}
+
+// find returns the result for a module of files files.
+func find(results []Result, files int) (Result, bool) {
+	for _, r := range results {
+		if r.Files == files {
+			return r, true
+		}
+	}
+	return Result{}, false
+}
+
+// Regressions describes the measurements of results more than maxRatio
+// times slower than those of the same size in baseline. Sizes missing from
+// baseline are not compared.
+func Regressions(results, baseline []Result, maxRatio float64) []string {
+	var out []string
+	for _, r := range results {
+		base, ok := find(baseline, r.Files)
+		if !ok {
+			continue
+		}
+		for _, m := range []struct {
+			name      string
+			got, base time.Duration
+		}{
+			{"cold gen", r.ColdGen, base.ColdGen},
+			{"warm gen", r.WarmGen, base.WarmGen},
+			{"audit", r.Audit, base.Audit},
+		} {
+			if m.base > 0 && float64(m.got) > maxRatio*float64(m.base) {
+				out = append(out, fmt.Sprintf("%d files: %s took %s, %.2fx the baseline's %s",
+					r.Files, m.name, m.got.Round(time.Millisecond), float64(m.got)/float64(m.base), m.base.Round(time.Millisecond)))
+			}
+		}
+	}
+	return out
+}
+
+// ReadResults reads the results WriteResults wrote to path.
+func ReadResults(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+// WriteResults writes results to w as a JSON array, the baseline format
+// of ReadResults.
+func WriteResults(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
@@ -0,0 +1,151 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imnive-design/inco-go/internal/inco"
+)
+
+// generate writes a module of n files to a temporary directory.
+func generate(tb testing.TB, n int) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := Generate(dir, n); err != nil {
+		tb.Fatal(err)
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	dir := generate(t, 45)
+	if got := countFiles(dir); got != 45 {
+		t.Fatalf("countFiles = %d, want 45", got)
+	}
+	for _, pkg := range []string{"pkg0000", "pkg0001", "pkg0002"} {
+		if _, err := os.Stat(filepath.Join(dir, pkg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Every file must instrument cleanly, with the package graph intact.
+	e := inco.NewEngine(dir)
+	e.Quiet = true
+	e.Typecheck = true
+	e.Run()
+	if got := len(e.Overlay.Replace); got != 45 {
+		t.Errorf("overlay replaces %d files, want 45", got)
+	}
+	if diags := inco.Vet(dir); len(diags) > 0 {
+		t.Errorf("vet reports problems in the generated module:\n%v", diags)
+	}
+}
+
+func TestRun(t *testing.T) {
+	r := Run(generate(t, 20))
+	if r.Files != 20 || r.ColdGen <= 0 || r.WarmGen <= 0 || r.Audit <= 0 {
+		t.Errorf("Run = %+v, want 20 files and every duration set", r)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	baseline := []Result{{Files: 100, ColdGen: time.Second, WarmGen: time.Second, Audit: time.Second}}
+	results := []Result{
+		{Files: 100, ColdGen: 2 * time.Second, WarmGen: 1400 * time.Millisecond, Audit: time.Second / 2},
+		{Files: 1000, ColdGen: time.Hour}, // not in the baseline
+	}
+	got := Regressions(results, baseline, 1.5)
+	want := []string{"100 files: cold gen took 2s, 2.00x the baseline's 1s"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Regressions = %q, want %q", got, want)
+	}
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	results := []Result{{Files: 100, ColdGen: 3 * time.Millisecond, WarmGen: time.Millisecond, Audit: 2 * time.Millisecond}}
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(results) {
+		t.Errorf("ReadResults = %v, want %v", got, results)
+	}
+	buf.Reset()
+	if err := WriteTable(&buf, results, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "(1.00x)") {
+		t.Errorf("table does not compare with the baseline:\n%s", buf.String())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Benchmarks
+// ---------------------------------------------------------------------------
+
+// sizes returns Sizes, without the largest under -short.
+func sizes() []int {
+	if testing.Short() {
+		return Sizes[:len(Sizes)-1]
+	}
+	return Sizes
+}
+
+func BenchmarkEngineRunCold(b *testing.B) {
+	for _, n := range sizes() {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			dir := generate(b, n)
+			cache := inco.NewEngine(dir).CacheDir
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				os.RemoveAll(cache)
+				b.StartTimer()
+				e := inco.NewEngine(dir)
+				e.Quiet = true
+				e.Run()
+			}
+		})
+	}
+}
+
+func BenchmarkEngineRunWarm(b *testing.B) {
+	for _, n := range sizes() {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			dir := generate(b, n)
+			e := inco.NewEngine(dir)
+			e.Quiet = true
+			e.Run()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				e := inco.NewEngine(dir)
+				e.Quiet = true
+				e.Run()
+			}
+		})
+	}
+}
+
+func BenchmarkAudit(b *testing.B) {
+	for _, n := range sizes() {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			dir := generate(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				inco.Audit(dir, inco.Limits{})
+			}
+		})
+	}
+}
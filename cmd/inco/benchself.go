@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/imnive-design/inco-go/bench"
+)
+
+// runBenchSelf generates a synthetic module of each size in a temporary
+// directory, measures gen and audit on it and prints the timings, or
+// writes them as JSON. With a baseline written by -json, it exits
+// exitFailure when a measurement is more than maxSlowdown times slower
+// than the baseline's.
+func runBenchSelf(sizes []int, jsonOut bool, baselinePath string, maxSlowdown float64) {
+	var baseline []bench.Result
+	if baselinePath != "" {
+		var err error
+		baseline, err = bench.ReadResults(baselinePath)
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("bench-self: %w", err))
+		if !(err == nil) {
+			panic(fmt.Errorf("bench-self: %w", err))
+		}
+	}
+	var results []bench.Result
+	for _, n := range sizes {
+		dir, err := os.MkdirTemp("", "inco-bench-*")
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		err = bench.Generate(dir, n)
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("bench-self: %w", err))
+		if !(err == nil) {
+			panic(fmt.Errorf("bench-self: %w", err))
+		}
+		fmt.Fprintf(os.Stderr, "inco: bench-self: %d files\n", n)
+		results = append(results, bench.Run(dir))
+		os.RemoveAll(dir)
+	}
+
+	write := bench.WriteTable
+	if jsonOut {
+		write = func(w io.Writer, results, _ []bench.Result) error { return bench.WriteResults(w, results) }
+	}
+	err := write(os.Stdout, results, baseline)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	regressions := bench.Regressions(results, baseline, maxSlowdown)
+	if len(regressions) == 0 {
+		return
+	}
+	for _, r := range regressions {
+		fmt.Fprintf(os.Stderr, "inco: bench-self: %s\n", r)
+	}
+	os.Exit(exitFailure)
+}
+
+// parseSizes parses the comma-separated module sizes of -sizes.
+func parseSizes(s string) []int {
+	var sizes []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		_ = err // @inco: err == nil && n > 0, -panic(usageError{"bench-self", fmt.Sprintf("invalid -sizes %q: want positive file counts, e.g. 100,1000", s)})
+		if !(err == nil && n > 0) {
+			panic(usageError{"bench-self", fmt.Sprintf("invalid -sizes %q: want positive file counts, e.g. 100,1000", s)})
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes
+}
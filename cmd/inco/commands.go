@@ -0,0 +1,522 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	inco "github.com/imnive-design/inco-go/internal/inco"
+)
+
+// Exit codes shared by every command.
+const (
+	exitFailure   = 1 // inco, or the go command it ran, reported a problem
+	exitUsage     = 2 // the command line is invalid
+	exitViolation = 3 // go run or go test ended with a contract violation
+)
+
+// usageError is a command-line mistake. guardPanic reports it with exit
+// code exitUsage instead of exitFailure.
+type usageError struct {
+	cmd string // "" for the top level
+	msg string
+}
+
+func (e usageError) Error() string {
+	if e.cmd == "" {
+		return e.msg
+	}
+	return e.cmd + ": " + e.msg
+}
+
+// ---------------------------------------------------------------------------
+// Command table
+// ---------------------------------------------------------------------------
+
+// command is one inco subcommand.
+type command struct {
+	name    string
+	aliases []string
+	args    string // synopsis after the name, e.g. "[-json] [-fix] [dir]"
+	help    string // what the command does, for -h
+
+	// setup registers the command's flags on fs and returns the function
+	// that runs it with the positional arguments.
+	setup func(fs *flag.FlagSet) func(args []string)
+
+	// goArgs marks commands whose arguments go to the go command. They
+	// are not parsed with a flag set; inco's own settings flags are picked
+	// out of them (see goIncoFlags).
+	goArgs bool
+}
+
+// commands lists the subcommands in the order the top-level usage shows.
+var commands = []*command{
+	{name: "gen", args: "[flags] [dir]",
+		help: "Scan source files and generate the overlay. -check instead generates it in a temporary\ndirectory and exits 1 if the cache directory holds a different one.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			load := settingFlags(fs)
+			check := fs.Bool("check", false, "fail if the committed overlay is out of date instead of writing it")
+			return func(args []string) {
+				dir := dirArg("gen", args)
+				if *check {
+					runGenCheck(dir, load(dir))
+					return
+				}
+				runGen(dir, load(dir))
+			}
+		}},
+	goCommand("build"), goCommand("test"), goCommand("run"), goCommand("list"),
+	{name: "audit", args: "[flags] [dir]",
+		help: "Report contract coverage and size warnings. -runtime adds the contracts the runs\nrecorded with -count-hits never evaluated. -annotate instead marks exported functions\nwithout contracts with a // inco:uncovered comment; -undo removes the marks.\n-uncovered-only and -unguarded-errors print one path:line:func per line instead.\n-schema prints the JSON Schema of the -format=json report. -match and -exclude-func\nscope the coverage figures to the functions whose names match regexps.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			load := settingFlags(fs, "max-func-contracts", "max-expr-terms", "quiet", "include-vendor", "audit-closures", "handlers")
+			vendorCoverage := fs.Bool("vendor-coverage", false, "count vendored packages in the coverage figures (with -include-vendor)")
+			match := fs.String("match", "", "count only the functions whose name matches the `regexp` in the coverage figures")
+			excludeFunc := fs.String("exclude-func", "", "leave the functions whose name matches the `regexp` out of the coverage figures")
+			format := fs.String("format", "text", "report `format`: "+strings.Join(inco.RendererNames(), ", "))
+			annotate := fs.Bool("annotate", false, "mark exported functions without contracts in the source")
+			undo := fs.Bool("undo", false, "remove the marks written by -annotate")
+			runtime := fs.String("runtime", "", "list the contracts no run recorded in the hits `file` of -count-hits evaluated")
+			uncoveredOnly := fs.Bool("uncovered-only", false, "print only path:line:func of each function without contracts")
+			unguardedErrors := fs.Bool("unguarded-errors", false, "print only path:line:func of each error assignment nothing checks")
+			schema := fs.Bool("schema", false, "print the JSON Schema of the -format=json report instead")
+			return func(args []string) {
+				if *schema {
+					os.Stdout.Write(inco.AuditSchema)
+					return
+				}
+				dir := dirArg("audit", args)
+				_ = annotate // @inco: !(*annotate && *undo), -panic(usageError{"audit", "-annotate and -undo are exclusive"})
+				if !(!(*annotate && *undo)) {
+					panic(usageError{"audit", "-annotate and -undo are exclusive"})
+				}
+				_ = uncoveredOnly // @inco: !(*uncoveredOnly && *unguardedErrors), -panic(usageError{"audit", "-uncovered-only and -unguarded-errors are exclusive"})
+				if !(!(*uncoveredOnly && *unguardedErrors)) {
+					panic(usageError{"audit", "-uncovered-only and -unguarded-errors are exclusive"})
+				}
+				if *annotate || *undo {
+					runAnnotate(dir, *undo)
+					return
+				}
+				opts := inco.AuditOptions{
+					VendorCoverage: *vendorCoverage,
+					Match:          funcPattern("match", *match),
+					ExcludeFunc:    funcPattern("exclude-func", *excludeFunc),
+				}
+				if *uncoveredOnly || *unguardedErrors {
+					r := runAudit(dir, load(dir), opts, "")
+					marks := r.Unguarded()
+					if *unguardedErrors {
+						marks = r.UnguardedErrors()
+					}
+					printWorklist(dir, marks)
+					return
+				}
+				renderer, ok := inco.Renderers[*format]
+				_ = ok // @inco: ok, -panic(usageError{"audit", fmt.Sprintf("unknown -format %q, want one of %s", *format, strings.Join(inco.RendererNames(), ", "))})
+				if !(ok) {
+					panic(usageError{"audit", fmt.Sprintf("unknown -format %q, want one of %s", *format, strings.Join(inco.RendererNames(), ", "))})
+				}
+				if *format == "text" {
+					renderer = inco.TextRenderer{Color: stdoutColor}
+				}
+				err := renderer.Render(os.Stdout, runAudit(dir, load(dir), opts, *runtime))
+				_ = err // @inco: err == nil, -panic(err)
+				if !(err == nil) {
+					panic(err)
+				}
+			}
+		}},
+	{name: "export", args: "[-format=markdown|json] [-o=outdir] [dir]", help: "Document each package's pre- and postconditions.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			format := fs.String("format", "markdown", "output `format`: markdown or json")
+			out := fs.String("o", "", "write one markdown file per package under `outdir` instead of stdout")
+			return func(args []string) { runExport(dirArg("export", args), *format, *out) }
+		}},
+	{name: "contracts", args: "diff [-json] <old> [<new>]",
+		help: "List the contracts added, removed or changed per function between two git revisions\nof the current directory. Without <new>, compare <old> with the working tree.\nExits 1 if any contract differs.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			jsonOut := fs.Bool("json", false, "write the changes as a JSON array")
+			return func(args []string) {
+				_ = args // @inco: len(args) >= 2 && len(args) <= 3 && args[0] == "diff", -panic(usageError{"contracts", "want diff <old> [<new>]"})
+				if !(len(args) >= 2 && len(args) <= 3 && args[0] == "diff") {
+					panic(usageError{"contracts", "want diff <old> [<new>]"})
+				}
+				runContractsDiff(args[1:], *jsonOut)
+			}
+		}},
+	{name: "release-check", args: "[-since=rev] [-json] [-compat-pkgs=./api/...] [dir]",
+		help: "Fail if an exported function gained a precondition or lost a postcondition since the\nlast tag (or -since), comparing with the working tree. Exits 1 if any contract breaks.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			since := fs.String("since", "", "compare with the git `revision` instead of the last tag")
+			jsonOut := fs.Bool("json", false, "write the breaking changes as a JSON array")
+			load := settingFlags(fs, "compat-pkgs")
+			return func(args []string) {
+				dir := dirArg("release-check", args)
+				runReleaseCheck(dir, *since, *jsonOut, load(dir))
+			}
+		}},
+	{name: "mutate", args: "[go test args]", goArgs: true,
+		help:  "Mutate each contract, run go test under the overlay and report mutants no test catches.\nExits 1 if any mutant survives.",
+		setup: func(fs *flag.FlagSet) func([]string) { return runMutate }},
+	{name: "vet", args: "[-json] [-fix] [-require-messages] [-require-pure] [dir]", help: "Check directives. Exits 1 if any problem remains.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			jsonOut := fs.Bool("json", false, "write the diagnostics as a JSON array")
+			fix := fs.Bool("fix", false, "apply suggested fixes first")
+			load := settingFlags(fs, "require-messages", "require-pure")
+			return func(args []string) {
+				dir := dirArg("vet", args)
+				runVet(dir, load(dir), *jsonOut, *fix)
+			}
+		}},
+	{name: "try", args: `"<directive>" [-types='name string'] [-results=error]`,
+		help: "Print the code a directive injects into a function with these parameters and\nresults, and typecheck it. Exits 1 if the directive does not parse or compile.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			params := fs.String("types", "", "parameter `list` of the synthetic function")
+			results := fs.String("results", "", "result `list` of the synthetic function")
+			load := settingFlags(fs, "messages", "sensitive")
+			return func(args []string) {
+				_ = args // @inco: len(args) == 1, -panic(usageError{"try", "want exactly one directive, e.g. inco try \"len(name) > 0\" -types='name string'"})
+				if !(len(args) == 1) {
+					panic(usageError{"try", "want exactly one directive, e.g. inco try \"len(name) > 0\" -types='name string'"})
+				}
+				runTry(args[0], *params, *results, load("."))
+			}
+		}},
+	{name: "release", args: "[flags] [dir] | release clean [dir]",
+		help: "Copy guards into the source tree as .inco.go files with //go:build inco.\n\"release clean\" removes released files and restores the originals.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			load := settingFlags(fs)
+			return func(args []string) {
+				if len(args) > 0 && args[0] == "clean" {
+					dir := dirArg("release clean", args[1:])
+					runReleaseClean(dir, load(dir))
+					return
+				}
+				dir := dirArg("release", args)
+				cfg := load(dir)
+				_ = cfg.Disable // @inco: !cfg.Disable, -panic("release: overlay generation is disabled by INCO_DISABLE")
+				if !(!cfg.Disable) {
+					panic("release: overlay generation is disabled by INCO_DISABLE")
+				}
+				runGen(dir, cfg)
+				runRelease(dir, cfg)
+			}
+		}},
+	{name: "verify-self", aliases: []string{"selftest"}, args: "[flags] [dir]",
+		help: "Generate with -typecheck, then build, vet and test inco itself under the overlay.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			load := settingFlags(fs)
+			return func(args []string) {
+				dir := dirArg("verify-self", args)
+				runVerifySelf(dir, load(dir))
+			}
+		}},
+	{name: "bench-self", args: "[-sizes=100,1000,10000] [-json] [-baseline=file] [-max-slowdown=1.5]",
+		help: "Measure gen, with an empty and a full cache, and audit on synthetic modules of each\nsize. With -baseline, a file written by -json, exits 1 if a measurement is more than\n-max-slowdown times the baseline's.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			sizes := fs.String("sizes", "100,1000,10000", "comma-separated module `sizes`, in files")
+			jsonOut := fs.Bool("json", false, "write the timings as JSON, the format of -baseline")
+			baseline := fs.String("baseline", "", "compare with the timings in `file`")
+			maxSlowdown := fs.Float64("max-slowdown", 1.5, "fail above this `ratio` to the baseline")
+			return func(args []string) {
+				_ = args // @inco: len(args) == 0, -panic(usageError{"bench-self", "takes no arguments"})
+				if !(len(args) == 0) {
+					panic(usageError{"bench-self", "takes no arguments"})
+				}
+				runBenchSelf(parseSizes(*sizes), *jsonOut, *baseline, *maxSlowdown)
+			}
+		}},
+	{name: "serve", args: "-rpc | -grpc=address [flags] [dir]",
+		help: "With -rpc, answer JSON-RPC 2.0 requests on stdin, one per line: generate, vet,\naudit, explain, suggest, shutdown. With -grpc, serve the inco.v1.Instrumenter\nservice of instrument.proto on address (host:port or unix:path) until interrupted.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			rpc := fs.Bool("rpc", false, "serve JSON-RPC over stdio")
+			grpcAddr := fs.String("grpc", "", "serve gRPC on `address`: host:port, or unix:path for a socket")
+			load := settingFlags(fs)
+			return func(args []string) {
+				_ = rpc // @inco: *rpc != (*grpcAddr != ""), -panic(usageError{"serve", "takes one of -rpc (JSON-RPC over stdio) and -grpc=address"})
+				if !(*rpc != (*grpcAddr != "")) {
+					panic(usageError{"serve", "takes one of -rpc (JSON-RPC over stdio) and -grpc=address"})
+				}
+				dir := dirArg("serve", args)
+				if *grpcAddr != "" {
+					runServeGRPC(dir, load(dir), *grpcAddr)
+					return
+				}
+				runServe(dir, load(dir))
+			}
+		}},
+	{name: "init", args: "[-hook] [-ci] [dir]",
+		help: "Set up inco in a project: write a commented .incoignore, add the cache directory\nto .gitignore, optionally install a pre-commit hook running inco vet and a GitHub\nActions workflow, then print a first audit. Existing files are kept.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			hook := fs.Bool("hook", false, "install a git pre-commit hook that runs inco vet")
+			ci := fs.Bool("ci", false, "write .github/workflows/inco.yml")
+			load := settingFlags(fs, "max-func-contracts", "max-expr-terms", "quiet")
+			return func(args []string) {
+				dir := dirArg("init", args)
+				runInit(dir, load(dir), inco.InitOptions{Hook: *hook, CI: *ci})
+			}
+		}},
+	{name: "violations", args: "show [file]",
+		help: "Summarize a violation log written under -log-violations: each violated contract\nwith its count, most frequent first. The log is file, otherwise $INCO_VIOLATION_LOG,\notherwise violations.jsonl in the cache directory.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			return func(args []string) {
+				_ = args // @inco: len(args) >= 1 && len(args) <= 2 && args[0] == "show", -panic(usageError{"violations", "want show [file]"})
+				if !(len(args) >= 1 && len(args) <= 2 && args[0] == "show") {
+					panic(usageError{"violations", "want show [file]"})
+				}
+				path := violationLog(loadConfig(moduleRoot(), nil))
+				if len(args) == 2 {
+					path = args[1]
+				}
+				runViolationsShow(path)
+			}
+		}},
+	{name: "doctor", args: "[flags] [dir]", help: "Show the effective settings and their sources.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			load := settingFlags(fs)
+			return func(args []string) {
+				dir := dirArg("doctor", args)
+				runDoctor(dir, load(dir))
+			}
+		}},
+	{name: "clean", args: "[dir]", help: "Remove the cache directory.",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			return func(args []string) { runClean(dirArg("clean", args)) }
+		}},
+}
+
+// goCommand returns the command that generates the overlay and runs
+// go subcmd with it.
+func goCommand(subcmd string) *command {
+	return &command{name: subcmd, args: "[settings flags] [go " + subcmd + " args]", goArgs: true,
+		help: "Generate the overlay, then run go " + subcmd + " -overlay with the remaining arguments.\n" +
+			"-n prints the go command instead of running it; -x echoes it first.\n" +
+			"The exit code is the go command's" + violationHelp[subcmd] + ".",
+		setup: func(fs *flag.FlagSet) func([]string) {
+			settingFlags(fs) // for -h only: the settings are picked out of args
+			return func(args []string) {
+				root := moduleRoot()
+				cfg := loadConfig(root, goIncoFlags(args))
+				cfg.TestBuild = subcmd == "test"
+				cfg.BuildTags = goTags(subcmd, args)
+				runGen(root, cfg)
+				runGo(subcmd, root, cfg, args)
+			}
+		}}
+}
+
+// violationHelp completes the exit code help of the go commands that run
+// the instrumented code.
+var violationHelp = map[string]string{
+	"run":  ", or 3 when a contract violation ends the program",
+	"test": ", or 3 when a contract violation ends a test",
+}
+
+// lookupCommand returns the command called name, or nil.
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name || slices.Contains(cmd.aliases, name) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Dispatch
+// ---------------------------------------------------------------------------
+
+// stdoutColor and stderrColor color the output of the command running,
+// unless -no-color, NO_COLOR or a non-terminal output rules it out. Set by
+// runCommand.
+var stdoutColor, stderrColor inco.Colorizer
+
+// setColor decides stdoutColor and stderrColor.
+func setColor(disabled bool) {
+	stdoutColor, stderrColor = inco.ColorFor(os.Stdout, disabled), inco.ColorFor(os.Stderr, disabled)
+}
+
+// runCommand runs the command named by args[0] with the rest of args.
+func runCommand(args []string) {
+	if len(args) == 0 || isHelpFlag(args[0]) || (args[0] == "help" && len(args) == 1) {
+		fmt.Print(usage)
+		return
+	}
+	if args[0] == "help" {
+		args = []string{args[1], "-h"}
+	}
+	cmd := lookupCommand(args[0])
+	_ = cmd // @inco: cmd != nil, -panic(usageError{"", fmt.Sprintf("unknown command %q; run 'inco help' for a list", args[0])})
+	if !(cmd != nil) {
+		panic(usageError{"", fmt.Sprintf("unknown command %q; run 'inco help' for a list", args[0])})
+	}
+	fs := cmd.flagSet()
+	run := cmd.setup(fs)
+	if cmd.goArgs {
+		if len(args) > 1 && isHelpFlag(args[1]) {
+			cmd.printHelp(os.Stdout)
+			return
+		}
+		goArgs, noColor := stripNoColor(cmd.name, args[1:])
+		setColor(noColor)
+		run(goArgs)
+		return
+	}
+	positional, err := parseInterspersed(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		cmd.printHelp(os.Stdout)
+		return
+	}
+	_ = err // @inco: err == nil, -panic(usageError{cmd.name, err.Error()})
+	if !(err == nil) {
+		panic(usageError{cmd.name, err.Error()})
+	}
+	setColor(fs.Lookup("no-color").Value.String() == "true")
+	run(positional)
+}
+
+// flagSet returns a flag set for cmd, holding the -no-color flag every
+// command accepts, that reports errors instead of printing them, so every
+// command fails the same way.
+func (cmd *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("inco "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Bool("no-color", false, "disable colored output (also NO_COLOR, or when not a terminal)")
+	return fs
+}
+
+// printHelp writes the usage of cmd and its flags to w.
+func (cmd *command) printHelp(w io.Writer) {
+	fmt.Fprintf(w, "Usage: inco %s %s\n\n%s\n", cmd.name, cmd.args, cmd.help)
+	if len(cmd.aliases) > 0 {
+		fmt.Fprintf(w, "\nAlias: %s\n", strings.Join(cmd.aliases, ", "))
+	}
+	fs := cmd.flagSet()
+	cmd.setup(fs)
+	var n int
+	fs.VisitAll(func(*flag.Flag) { n++ })
+	if n > 0 {
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs.SetOutput(w)
+		fs.PrintDefaults()
+	}
+}
+
+// parseInterspersed parses the flags in args wherever they appear and
+// returns the other arguments in order, so "inco vet ./pkg -json" and
+// "inco vet -json ./pkg" mean the same. A "--" ends flag parsing.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if len(positional) == 0 && args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+}
+
+// isHelpFlag reports whether arg asks for help.
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// dirArg returns the single optional directory argument of cmd, or ".".
+func dirArg(cmd string, args []string) string {
+	_ = args // @inco: len(args) <= 1, -panic(usageError{cmd, fmt.Sprintf("unexpected arguments %q; want at most one directory", args[1:])})
+	if !(len(args) <= 1) {
+		panic(usageError{cmd, fmt.Sprintf("unexpected arguments %q; want at most one directory", args[1:])})
+	}
+	if len(args) == 0 {
+		return "."
+	}
+	return args[0]
+}
+
+// ---------------------------------------------------------------------------
+// Settings flags
+// ---------------------------------------------------------------------------
+
+// setting is a flag that feeds the config (see loadConfig).
+type setting struct {
+	name  string
+	bool  bool // a boolean flag; the others take a value
+	def   bool // default of a boolean flag
+	usage string
+}
+
+// settings are the flags loadConfig resolves, in doctor report order.
+var settings = []setting{
+	{name: "trimpath", bool: true, usage: "emit module-relative //line paths (also read from GOFLAGS)"},
+	{name: "typecheck", bool: true, usage: "typecheck every package with the overlay applied"},
+	{name: "keep-going", bool: true, def: true, usage: "report failing files together and still write the overlay for the others"},
+	{name: "quiet", bool: true, usage: "print no progress line, summary or warnings"},
+	{name: "include-vendor", bool: true, usage: "process vendored packages too"},
+	{name: "audit-closures", bool: true, def: true, usage: "audit: count function literals as functions of their own in the coverage figures"},
+	{name: "strict", bool: true, usage: "fail generation when it reports warnings"},
+	{name: "profile", usage: "write a CPU profile of overlay generation to `file`"},
+	{name: "require-messages", bool: true, usage: "vet: report contracts in exported functions without a -panic message"},
+	{name: "require-pure", bool: true, usage: "vet: report contracts calling functions not known to be free of side effects"},
+	{name: "log-violations", bool: true, usage: "log violated contracts instead of panicking (see inco violations)"},
+	{name: "count-hits", bool: true, usage: "record which contracts are evaluated (see inco audit -runtime)"},
+	{name: "enable-tags", usage: "keep only directives with one of these comma-separated #`tags`"},
+	{name: "disable-tags", usage: "drop directives with any of these comma-separated #`tags`"},
+	{name: "pkgs", usage: "instrument only these comma-separated package `patterns` (./dir or ./dir/...)"},
+	{name: "messages", usage: "JSON message catalog `file` resolving msg(\"key\") in actions"},
+	{name: "overlays", usage: "compose the comma-separated overlay `files` of other code generators with inco's"},
+	{name: "sensitive", usage: "comma-separated name `patterns` whose values violation output redacts"},
+	{name: "handlers", usage: "audit: report functions matching these comma-separated name `patterns` as endpoints"},
+	{name: "compat-pkgs", usage: "release-check: hold only these comma-separated package `patterns` to their released contracts"},
+	{name: "max-func-contracts", usage: "warn above `N` contracts per function (default 10, negative = off)"},
+	{name: "max-expr-terms", usage: "warn above `N` &&/|| conditions per contract (default 4, negative = off)"},
+	{name: "typecheck-cache", usage: "keep the typecheck results of `N` packages (default 512, negative = unbounded)"},
+	{name: "max-memory", usage: "fail -typecheck when the heap grows beyond `MiB`"},
+}
+
+// settingFlags registers the settings flags called names on fs, or all of
+// them when names is empty, and returns the function that resolves the
+// config for a directory from the flags given and the environment.
+func settingFlags(fs *flag.FlagSet, names ...string) func(dir string) *config {
+	for _, s := range settings {
+		if len(names) > 0 && !slices.Contains(names, s.name) {
+			continue
+		}
+		if s.bool {
+			fs.Bool(s.name, s.def, s.usage)
+		} else {
+			fs.String(s.name, "", s.usage)
+		}
+	}
+	return func(dir string) *config {
+		given := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+		return loadConfig(dir, given)
+	}
+}
+
+// goIncoFlags picks inco's settings flags out of arguments meant for the
+// go command: -name=value for value settings and -name or -name=bool for
+// boolean ones. -trimpath is also a go flag and stays in args.
+func goIncoFlags(args []string) map[string]string {
+	given := make(map[string]string)
+	for _, s := range settings {
+		if v, ok := flagValue(args, s.name); ok {
+			given[s.name] = v
+		} else if s.bool && hasFlag(args, "-"+s.name) {
+			given[s.name] = "true"
+		}
+	}
+	return given
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	inco "github.com/imnive-design/inco-go/internal/inco"
+)
+
+// config holds the effective settings for one inco invocation.
+//
+// Every setting is resolved with the precedence flag > environment >
+// default, so CI systems and containers can configure inco without
+// changing command lines.
+type config struct {
+	TrimPath  bool   // -trimpath / GOFLAGS, INCO_TRIMPATH
+	Typecheck bool   // -typecheck, INCO_TYPECHECK
+	CacheDir  string // INCO_CACHE_DIR (absolute)
+	Disable   bool   // INCO_DISABLE: skip overlay generation entirely
+	KeepGoing bool   // -keep-going[=bool], INCO_KEEP_GOING (default true)
+	Quiet     bool   // -quiet, INCO_QUIET: no progress line, summary or warnings
+	Vendor    bool   // -include-vendor, INCO_INCLUDE_VENDOR: process vendored packages too
+	Closures  bool   // -audit-closures[=bool], INCO_AUDIT_CLOSURES: audit counts function literals (default true)
+	Strict    bool   // -strict, INCO_STRICT: generation fails on warnings
+	Profile   string // -profile, INCO_PROFILE: CPU profile of generation (absolute; "" = none)
+
+	RequireMessages bool   // -require-messages, INCO_REQUIRE_MESSAGES: vet rule for exported functions
+	RequirePure     bool   // -require-pure, INCO_REQUIRE_PURE: vet rule for calls in contracts
+	LogViolations   bool   // -log-violations, INCO_LOG_VIOLATIONS: log violations instead of panicking
+	CountHits       bool   // -count-hits, INCO_COUNT_HITS: record which contracts are evaluated
+	TestBuild       bool   // set by inco test: generate the @must channel deadlines
+	BuildTags       string // set by build, test, run and list: the -tags of the go command
+
+	EnableTags  []string // -enable-tags, INCO_ENABLE_TAGS
+	DisableTags []string // -disable-tags, INCO_DISABLE_TAGS
+	Packages    []string // -pkgs, INCO_PKGS: package patterns to instrument (default all)
+	Sensitive   []string // -sensitive, INCO_SENSITIVE: name patterns redacted in violation output
+	Handlers    []string // -handlers, INCO_HANDLERS: function name patterns audit reports as endpoints
+	CompatPkgs  []string // -compat-pkgs, INCO_COMPAT_PKGS: package patterns release-check holds to released contracts (default all)
+	Messages    string   // -messages, INCO_MESSAGES: message catalog (absolute; "" = none)
+	Overlays    []string // -overlays, INCO_OVERLAYS: overlay files of other code generators (absolute)
+
+	MaxFuncContracts int // -max-func-contracts, INCO_MAX_FUNC_CONTRACTS (0 = default, <0 = off)
+	MaxExprTerms     int // -max-expr-terms, INCO_MAX_EXPR_TERMS (0 = default, <0 = off)
+
+	TypecheckCache int // -typecheck-cache, INCO_TYPECHECK_CACHE: packages (0 = default, <0 = unbounded)
+	MaxMemory      int // -max-memory, INCO_MAX_MEMORY: MiB while typechecking (0 = no limit)
+
+	source map[string]string // setting name → "flag", "env <VAR>" or "default"
+}
+
+// loadConfig resolves the settings for the project in dir from the
+// settings flags given (name → value) and the environment.
+func loadConfig(dir string, flags map[string]string) *config {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+
+	c := &config{source: make(map[string]string)}
+	if _, ok := flags["trimpath"]; !ok && trimpathRequested(nil) {
+		flags = maps.Clone(flags)
+		if flags == nil {
+			flags = make(map[string]string)
+		}
+		flags["trimpath"] = "true" // from GOFLAGS
+	}
+	c.TrimPath = c.resolveSwitch("trimpath", flags, "INCO_TRIMPATH", false)
+	c.Typecheck = c.resolveSwitch("typecheck", flags, "INCO_TYPECHECK", false)
+	c.Disable = c.resolveSwitch("disable", nil, "INCO_DISABLE", false)
+	c.KeepGoing = c.resolveSwitch("keep-going", flags, "INCO_KEEP_GOING", true)
+	c.Quiet = c.resolveSwitch("quiet", flags, "INCO_QUIET", false)
+	c.Vendor = c.resolveSwitch("include-vendor", flags, "INCO_INCLUDE_VENDOR", false)
+	c.Closures = c.resolveSwitch("audit-closures", flags, "INCO_AUDIT_CLOSURES", true)
+	c.Strict = c.resolveSwitch("strict", flags, "INCO_STRICT", false)
+	c.Profile = c.resolvePath("profile", flags, "INCO_PROFILE", absDir)
+	c.RequireMessages = c.resolveSwitch("require-messages", flags, "INCO_REQUIRE_MESSAGES", false)
+	c.RequirePure = c.resolveSwitch("require-pure", flags, "INCO_REQUIRE_PURE", false)
+	c.LogViolations = c.resolveSwitch("log-violations", flags, "INCO_LOG_VIOLATIONS", false)
+	c.CountHits = c.resolveSwitch("count-hits", flags, "INCO_COUNT_HITS", false)
+	c.EnableTags = c.resolveList("enable-tags", flags, "INCO_ENABLE_TAGS")
+	c.DisableTags = c.resolveList("disable-tags", flags, "INCO_DISABLE_TAGS")
+	c.Packages = c.resolveList("pkgs", flags, "INCO_PKGS")
+	c.Sensitive = c.resolveList("sensitive", flags, "INCO_SENSITIVE")
+	c.Handlers = c.resolveList("handlers", flags, "INCO_HANDLERS")
+	c.CompatPkgs = c.resolveList("compat-pkgs", flags, "INCO_COMPAT_PKGS")
+	c.Messages = c.resolvePath("messages", flags, "INCO_MESSAGES", absDir)
+	c.Overlays = c.resolveList("overlays", flags, "INCO_OVERLAYS")
+	for i, p := range c.Overlays {
+		if !filepath.IsAbs(p) {
+			c.Overlays[i] = filepath.Join(absDir, p)
+		}
+	}
+	c.MaxFuncContracts = c.resolveInt("max-func-contracts", flags, "INCO_MAX_FUNC_CONTRACTS")
+	c.MaxExprTerms = c.resolveInt("max-expr-terms", flags, "INCO_MAX_EXPR_TERMS")
+	c.TypecheckCache = c.resolveInt("typecheck-cache", flags, "INCO_TYPECHECK_CACHE")
+	c.MaxMemory = c.resolveInt("max-memory", flags, "INCO_MAX_MEMORY")
+	_ = c.MaxMemory // @inco: c.MaxMemory >= 0, -panic(fmt.Errorf("max-memory: must not be negative"))
+	if !(c.MaxMemory >= 0) {
+		panic(fmt.Errorf("max-memory: must not be negative"))
+	}
+
+	c.CacheDir, c.source["cachedir"] = inco.DefaultCacheDir(absDir), "default"
+	if v := os.Getenv("INCO_CACHE_DIR"); v != "" {
+		if !filepath.IsAbs(v) {
+			v = filepath.Join(absDir, v)
+		}
+		c.CacheDir, c.source["cachedir"] = filepath.Clean(v), "env INCO_CACHE_DIR"
+	}
+	return c
+}
+
+// resolveSwitch returns the boolean value of flag name, otherwise of the
+// environment variable env, otherwise def, and records the source under
+// name.
+func (c *config) resolveSwitch(name string, flags map[string]string, env string, def bool) bool {
+	v, ok := flags[name]
+	switch {
+	case ok:
+		c.source[name] = "flag"
+	case os.Getenv(env) != "":
+		v, c.source[name] = os.Getenv(env), "env "+env
+	default:
+		c.source[name] = "default"
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("%s: invalid boolean %q", name, v))
+	if !(err == nil) {
+		panic(fmt.Errorf("%s: invalid boolean %q", name, v))
+	}
+	return b
+}
+
+// resolveList returns the comma-separated list given by flag name,
+// otherwise by the environment variable env, and records the source
+// under name.
+func (c *config) resolveList(name string, flags map[string]string, env string) []string {
+	v, ok := flags[name]
+	switch {
+	case ok:
+		c.source[name] = "flag"
+	case os.Getenv(env) != "":
+		v, c.source[name] = os.Getenv(env), "env "+env
+	default:
+		c.source[name] = "default"
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// resolvePath returns the path given by flag name, otherwise by
+// the environment variable env, otherwise "", and records the source under
+// name. A relative path is resolved against dir.
+func (c *config) resolvePath(name string, flags map[string]string, env, dir string) string {
+	v, ok := flags[name]
+	switch {
+	case ok:
+		c.source[name] = "flag"
+	case os.Getenv(env) != "":
+		v, c.source[name] = os.Getenv(env), "env "+env
+	default:
+		c.source[name] = "default"
+		return ""
+	}
+	if v != "" && !filepath.IsAbs(v) {
+		v = filepath.Join(dir, v)
+	}
+	return v
+}
+
+// resolveInt returns the integer given by flag name, otherwise
+// by the environment variable env, otherwise 0, and records the source
+// under name.
+func (c *config) resolveInt(name string, flags map[string]string, env string) int {
+	v, ok := flags[name]
+	switch {
+	case ok:
+		c.source[name] = "flag"
+	case os.Getenv(env) != "":
+		v, c.source[name] = os.Getenv(env), "env "+env
+	default:
+		c.source[name] = "default"
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("%s: invalid integer %q", name, v))
+	if !(err == nil) {
+		panic(fmt.Errorf("%s: invalid integer %q", name, v))
+	}
+	return n
+}
+
+// Limits returns the contract size limits for the engine and audit.
+func (c *config) Limits() inco.Limits {
+	return inco.Limits{MaxFuncContracts: c.MaxFuncContracts, MaxExprTerms: c.MaxExprTerms}
+}
+
+// flagValue returns the value of the last -name=value or --name=value in
+// args and whether there was one.
+func flagValue(args []string, name string) (string, bool) {
+	var v string
+	var found bool
+	for _, arg := range args {
+		if val, ok := cutFlag(arg, name); ok {
+			v, found = val, true
+		}
+	}
+	return v, found
+}
+
+// cutFlag returns the value of arg if it is -name=value or --name=value.
+func cutFlag(arg, name string) (string, bool) {
+	arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+	return strings.CutPrefix(arg, name+"=")
+}
+
+// stripIncoFlags removes inco's settings flags from the go command flags
+// in args. -trimpath is a go flag too and is kept.
+func stripIncoFlags(subcmd string, args []string) []string {
+	end := goFlagsEnd(subcmd, args)
+	out := slices.DeleteFunc(slices.Clone(args[:end]), func(arg string) bool {
+		return slices.ContainsFunc(settings, func(s setting) bool {
+			_ = s // @inco: s.name != "trimpath", -return(false)
+			if !(s.name != "trimpath") {
+				return false
+			}
+			_, ok := cutFlag(arg, s.name)
+			return ok || (s.bool && hasFlag([]string{arg}, "-"+s.name))
+		})
+	})
+	return append(out, args[end:]...)
+}
+
+// startProfile starts the CPU profile of -profile, if any, and returns
+// the function that stops it and writes the file.
+func (c *config) startProfile() (stop func()) {
+	_ = c.Profile // @inco: c.Profile != "", -return(func() {})
+	if !(c.Profile != "") {
+		return func() {}
+	}
+	f, err := os.Create(c.Profile)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("profile: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("profile: %w", err))
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		panic(fmt.Errorf("profile: %w", err))
+	}
+	return func() {
+		pprof.StopCPUProfile()
+		f.Close()
+	}
+}
+
+// progress returns the terminal to draw progress lines on, or nil when
+// Quiet is set or stderr is not a terminal.
+func (c *config) progress() io.Writer {
+	_ = c // @inco: !c.Quiet && inco.IsTerminal(os.Stderr), -return(nil)
+	if !(!c.Quiet && inco.IsTerminal(os.Stderr)) {
+		return nil
+	}
+	return os.Stderr
+}
+
+// PrintReport writes the effective settings and the source of each to w.
+func (c *config) PrintReport(w io.Writer) {
+	fmt.Fprintf(w, "inco doctor — effective configuration\n")
+	fmt.Fprintf(w, "=====================================\n\n")
+	fmt.Fprintf(w, "Precedence: flag > environment > default\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "  Setting\tFlag\tEnvironment\tValue\tSource\n")
+	fmt.Fprintf(tw, "  trimpath\t-trimpath\tINCO_TRIMPATH\t%t\t%s\n", c.TrimPath, c.source["trimpath"])
+	fmt.Fprintf(tw, "  typecheck\t-typecheck\tINCO_TYPECHECK\t%t\t%s\n", c.Typecheck, c.source["typecheck"])
+	fmt.Fprintf(tw, "  cachedir\t—\tINCO_CACHE_DIR\t%s\t%s\n", c.CacheDir, c.source["cachedir"])
+	fmt.Fprintf(tw, "  disable\t—\tINCO_DISABLE\t%t\t%s\n", c.Disable, c.source["disable"])
+	fmt.Fprintf(tw, "  keep-going\t-keep-going\tINCO_KEEP_GOING\t%t\t%s\n", c.KeepGoing, c.source["keep-going"])
+	fmt.Fprintf(tw, "  quiet\t-quiet\tINCO_QUIET\t%t\t%s\n", c.Quiet, c.source["quiet"])
+	fmt.Fprintf(tw, "  include-vendor\t-include-vendor\tINCO_INCLUDE_VENDOR\t%t\t%s\n", c.Vendor, c.source["include-vendor"])
+	fmt.Fprintf(tw, "  audit-closures\t-audit-closures\tINCO_AUDIT_CLOSURES\t%t\t%s\n", c.Closures, c.source["audit-closures"])
+	fmt.Fprintf(tw, "  strict\t-strict\tINCO_STRICT\t%t\t%s\n", c.Strict, c.source["strict"])
+	fmt.Fprintf(tw, "  profile\t-profile\tINCO_PROFILE\t%s\t%s\n", formatPath(c.Profile), c.source["profile"])
+	fmt.Fprintf(tw, "  require-messages\t-require-messages\tINCO_REQUIRE_MESSAGES\t%t\t%s\n", c.RequireMessages, c.source["require-messages"])
+	fmt.Fprintf(tw, "  require-pure\t-require-pure\tINCO_REQUIRE_PURE\t%t\t%s\n", c.RequirePure, c.source["require-pure"])
+	fmt.Fprintf(tw, "  log-violations\t-log-violations\tINCO_LOG_VIOLATIONS\t%t\t%s\n", c.LogViolations, c.source["log-violations"])
+	fmt.Fprintf(tw, "  count-hits\t-count-hits\tINCO_COUNT_HITS\t%t\t%s\n", c.CountHits, c.source["count-hits"])
+	fmt.Fprintf(tw, "  enable-tags\t-enable-tags\tINCO_ENABLE_TAGS\t%s\t%s\n", formatList(c.EnableTags, "(all)"), c.source["enable-tags"])
+	fmt.Fprintf(tw, "  disable-tags\t-disable-tags\tINCO_DISABLE_TAGS\t%s\t%s\n", formatList(c.DisableTags, "(none)"), c.source["disable-tags"])
+	fmt.Fprintf(tw, "  pkgs\t-pkgs\tINCO_PKGS\t%s\t%s\n", formatPatterns(c.Packages), c.source["pkgs"])
+	fmt.Fprintf(tw, "  sensitive\t-sensitive\tINCO_SENSITIVE\t%s\t%s\n", formatPath(strings.Join(c.Sensitive, ",")), c.source["sensitive"])
+	fmt.Fprintf(tw, "  handlers\t-handlers\tINCO_HANDLERS\t%s\t%s\n", formatList(c.Handlers, "(none)"), c.source["handlers"])
+	fmt.Fprintf(tw, "  compat-pkgs\t-compat-pkgs\tINCO_COMPAT_PKGS\t%s\t%s\n", formatPatterns(c.CompatPkgs), c.source["compat-pkgs"])
+	fmt.Fprintf(tw, "  messages\t-messages\tINCO_MESSAGES\t%s\t%s\n", formatPath(c.Messages), c.source["messages"])
+	fmt.Fprintf(tw, "  overlays\t-overlays\tINCO_OVERLAYS\t%s\t%s\n", formatPath(strings.Join(c.Overlays, ",")), c.source["overlays"])
+	fmt.Fprintf(tw, "  max-func-contracts\t-max-func-contracts\tINCO_MAX_FUNC_CONTRACTS\t%s\t%s\n",
+		formatLimit(c.MaxFuncContracts, inco.DefaultMaxFuncContracts), c.source["max-func-contracts"])
+	fmt.Fprintf(tw, "  max-expr-terms\t-max-expr-terms\tINCO_MAX_EXPR_TERMS\t%s\t%s\n",
+		formatLimit(c.MaxExprTerms, inco.DefaultMaxExprTerms), c.source["max-expr-terms"])
+	cacheSize := formatLimit(c.TypecheckCache, inco.DefaultTypecheckCache)
+	if c.TypecheckCache < 0 {
+		cacheSize = "unbounded"
+	}
+	fmt.Fprintf(tw, "  typecheck-cache\t-typecheck-cache\tINCO_TYPECHECK_CACHE\t%s\t%s\n", cacheSize, c.source["typecheck-cache"])
+	fmt.Fprintf(tw, "  max-memory\t-max-memory\tINCO_MAX_MEMORY\t%s\t%s\n", formatMemory(c.MaxMemory), c.source["max-memory"])
+	tw.Flush()
+}
+
+// formatList renders a list for the doctor report, as empty when it is
+// empty: "(all)" for the tags enabled, "(none)" for those disabled.
+func formatList(list []string, empty string) string {
+	if len(list) == 0 {
+		return empty
+	}
+	return strings.Join(list, ",")
+}
+
+// formatPath renders an optional path for the doctor report.
+func formatPath(p string) string {
+	if p == "" {
+		return "(none)"
+	}
+	return p
+}
+
+// formatPatterns renders the -pkgs or -compat-pkgs patterns for the doctor
+// report.
+func formatPatterns(patterns []string) string {
+	if len(patterns) == 0 {
+		return "./..."
+	}
+	return strings.Join(patterns, ",")
+}
+
+// formatMemory renders the -max-memory limit for the doctor report.
+func formatMemory(mib int) string {
+	if mib == 0 {
+		return "none"
+	}
+	return strconv.Itoa(mib) + " MiB"
+}
+
+// formatLimit renders a contract size limit for the doctor report.
+func formatLimit(n, def int) string {
+	switch {
+	case n < 0:
+		return "off"
+	case n == 0:
+		return strconv.Itoa(def)
+	}
+	return strconv.Itoa(n)
+}
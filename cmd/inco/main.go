@@ -3,154 +3,931 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 
+	"github.com/imnive-design/inco-go/incolog"
 	inco "github.com/imnive-design/inco-go/internal/inco"
+	"github.com/imnive-design/inco-go/internal/incogrpc"
 )
 
 const usage = `inco — invisible constraints, invincible code.
 
 Usage:
-  inco gen [dir]           Scan source files and generate overlay
+  inco gen [-trimpath] [-typecheck] [-enable-tags=a,b] [-disable-tags=c]
+           [-pkgs=./api/...,./db] [-messages=catalog.json] [-quiet]
+           [-sensitive=password,*token*] [dir]
+                           Scan source files and generate overlay
+  inco gen -check [dir]    Fail if the overlay in the cache directory is
+                           not what gen would write (committed overlays)
   inco build [args]        Run gen + go build -overlay
-  inco test [args]         Run gen + go test -overlay
+  inco test [args]         Run gen + go test -overlay (with @must deadlines)
   inco run [args]          Run gen + go run -overlay
-  inco audit [dir]         Contract coverage report
+  inco list [args]         Run gen + go list -overlay
+  inco audit [-format=text|json|html|sarif] [-handlers=*Handler]
+             [-runtime=hits.json] [-match=re] [-exclude-func=re] [dir]
+                           Contract coverage report, size warnings,
+                           contracts by endpoint and wire-up safety
+  inco audit -schema       Print the JSON Schema of the -format=json report
+  inco audit -annotate|-undo [dir]
+                           Mark exported functions without contracts with
+                           // inco:uncovered, or remove the marks
+  inco audit -uncovered-only|-unguarded-errors [dir]
+                           Print path:line:func of each function without
+                           contracts, or error assignment nothing checks
+  inco export [-format=markdown|json] [-o=outdir] [dir]
+                           Document each package's pre/postconditions
+  inco contracts diff [-json] <old> [<new>]
+                           Contracts added, removed or changed per function
+                           between two git revisions (<new>: working tree)
+  inco release-check [-since=rev] [-json] [-compat-pkgs=./api/...] [dir]
+                           Fail if exported functions gained preconditions
+                           or lost postconditions since the last tag
+  inco mutate [args]       Mutate each contract, run go test [args] under the
+                           overlay and report mutants no test catches
+  inco vet [-json] [-fix] [-require-messages] [-require-pure] [dir]
+                           Check directives; -fix applies suggested fixes
+  inco try "<directive>" [-types='name string'] [-results=error]
+                           Print the code a directive injects into a
+                           function with these parameters and results,
+                           and typecheck it
   inco release [dir]       Copy guards into source tree with //go:build inco
   inco release clean [dir] Remove released files and restore originals
-  inco clean [dir]         Remove .inco_cache
+  inco verify-self [dir]   Gen with -typecheck, then build, vet and test
+                           inco itself under the overlay (alias: selftest)
+  inco bench-self [-sizes=100,1000,10000] [-json] [-baseline=file]
+                  [-max-slowdown=1.5]
+                           Time gen and audit on synthetic modules; fail if
+                           slower than -max-slowdown times the baseline
+  inco serve -rpc [dir]    Answer JSON-RPC 2.0 requests on stdin (one per
+                           line): generate, vet, audit, explain, suggest,
+                           shutdown
+  inco serve -grpc=address [dir]
+                           Serve the inco.v1.Instrumenter gRPC service on
+                           host:port or unix:path: one file in, the
+                           instrumented file and its source map out
+  inco init [-hook] [-ci] [dir]
+                           Write .incoignore, ignore the cache in git,
+                           optionally install a pre-commit hook and a
+                           GitHub Actions workflow, then audit
+  inco violations show [file]
+                           Summarize the violations logged under
+                           -log-violations, most frequent first
+  inco doctor [dir]        Show effective settings and their sources
+  inco clean [dir]         Remove the cache directory
 
-If [dir] is omitted, the current directory is used.
+If [dir] is omitted, the current directory is used. build, test, run and
+list generate the overlay for the module containing the current directory
+(the nearest go.mod), wherever in it they run. Flags may come before or
+after [dir]. Run 'inco help <command>' or 'inco <command> -h' for the
+flags of a command. Output to a terminal is colored unless -no-color or
+NO_COLOR is given.
+
+Exit status is 0 on success, 1 when inco finds a problem (a failed
+generation, vet diagnostics, surviving mutants, a directive try rejects)
+and 2 for an invalid command line. build, test, run and list exit with the
+go command's status, except that run and test exit with 3 when a contract
+violation ended the program or a test, after a summary of the violations.
+
+-trimpath (on the command line or in GOFLAGS) makes generated //line
+directives relative, so binaries record module-relative paths for
+instrumented files just as they do for ordinary ones.
+
+build, test, run and list accept -n to print the go command (with the
+overlay) instead of running it; with -x the command is echoed to stderr
+before it runs and -x is forwarded to go.
+
+Settings are resolved as flag > environment > default:
+  INCO_TRIMPATH, INCO_TYPECHECK  booleans, as -trimpath and -typecheck
+  INCO_CACHE_DIR                 cache directory (default <dir>/.inco_cache)
+  INCO_DISABLE                   skip overlay generation; go runs unmodified
+  INCO_STRICT                    as -strict: generation fails when it
+                                 reports warnings (contract size limits,
+                                 formatting drift and the like)
+  INCO_PROFILE                   as -profile=file: write a CPU profile of
+                                 overlay generation (go tool pprof file)
+  INCO_KEEP_GOING                as -keep-going[=false]: on by default; files
+                                 that fail are reported together and the
+                                 overlay is still written for the others
+  INCO_ENABLE_TAGS               as -enable-tags: keep only these #tag groups
+  INCO_DISABLE_TAGS              as -disable-tags: drop these #tag groups
+  INCO_INCLUDE_VENDOR            as -include-vendor: instrument and audit
+                                 vendor directories too (audit leaves them
+                                 out of coverage unless -vendor-coverage)
+  INCO_AUDIT_CLOSURES            as -audit-closures[=false]: on by default;
+                                 audit counts function literals as
+                                 functions, guarded by their own contracts
+  INCO_REQUIRE_MESSAGES          as -require-messages: inco vet reports
+                                 contracts in exported functions that
+                                 panic without a message of their own
+  INCO_REQUIRE_PURE              as -require-pure: inco vet reports
+                                 contracts calling functions not known
+                                 to be free of side effects
+  INCO_LOG_VIOLATIONS            as -log-violations: contracts that would
+                                 panic append a record to a log instead
+                                 (INCO_VIOLATION_LOG, default
+                                 <cachedir>/violations.jsonl for test and
+                                 run) and execution continues
+  INCO_COUNT_HITS                as -count-hits: every check records that
+                                 it was evaluated (INCO_HITS_LOG, default
+                                 <cachedir>/hits.json for test and run);
+                                 inco audit -runtime=<file> lists the
+                                 checks no run evaluated
+  INCO_PKGS                      as -pkgs: instrument only these package
+                                 patterns (./dir or ./dir/..., relative
+                                 to [dir]; default all)
+  INCO_MESSAGES                  as -messages: JSON catalog resolving
+                                 msg("key") in directive actions
+  INCO_OVERLAYS                  as -overlays: overlay files of other code
+                                 generators (protoc plugins writing outside
+                                 the tree), composed with inco's so
+                                 instrumented code can import what exists
+                                 only in them
+  INCO_SENSITIVE                 as -sensitive: variable and field name
+                                 patterns whose values violation output
+                                 shows as "[redacted]"
+  INCO_HANDLERS                  as -handlers: function name patterns
+                                 (*Handler, Server.Handle*) inco audit
+                                 reports as endpoints, besides those
+                                 declared with // @handler
+  INCO_COMPAT_PKGS               as -compat-pkgs: the package patterns
+                                 inco release-check holds to their
+                                 released contracts (default all)
+  INCO_MAX_FUNC_CONTRACTS        as -max-func-contracts=N: warn above N
+                                 contracts per function (default 10)
+  INCO_MAX_EXPR_TERMS            as -max-expr-terms=N: warn above N &&/||
+                                 conditions per contract (default 4)
+                                 (a negative limit disables the warning)
+  INCO_TYPECHECK_CACHE           as -typecheck-cache=N: packages whose
+                                 typecheck result is kept (default 512)
+  INCO_MAX_MEMORY                as -max-memory=MiB: fail -typecheck when
+                                 the heap grows beyond MiB, naming the
+                                 heaviest packages
+
+Shadow files are always parsed before the overlay is written; -typecheck
+also typechecks every package with the overlay applied.
 `
 
 func main() {
 	defer guardPanic()
+	runCommand(os.Args[1:])
+}
 
-	if len(os.Args) < 2 {
-		fmt.Print(usage)
-		os.Exit(0)
-	}
-
-	switch os.Args[1] {
-	case "gen":
-		runGen(getDir(2))
-	case "build":
-		runGen(".")
-		runGo("build", ".", os.Args[2:])
-	case "test":
-		runGen(".")
-		runGo("test", ".", os.Args[2:])
-	case "run":
-		runGen(".")
-		runGo("run", ".", os.Args[2:])
-	case "audit":
-		runAudit(getDir(2)).PrintReport(os.Stdout)
-	case "release":
-		if len(os.Args) > 2 && os.Args[2] == "clean" {
-			runReleaseClean(getDir(3))
-		} else {
-			dir := getDir(2)
-			runGen(dir)
-			runRelease(dir)
+// guardPanic recovers from panics (including those injected by @inco:)
+// and exits with the panic message: exitUsage for a command-line mistake,
+// exitFailure otherwise.
+func guardPanic() {
+	r := recover()
+	_ = r // @inco: r != nil, -return
+	if !(r != nil) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %v\n", stderrColor.Error("inco:"), r)
+	if ue, ok := r.(usageError); ok {
+		if ue.cmd != "" {
+			fmt.Fprintf(os.Stderr, "run 'inco %s -h' for usage\n", strings.Fields(ue.cmd)[0])
+		}
+		os.Exit(exitUsage)
+	}
+	os.Exit(exitFailure)
+}
+
+// trimpathRequested reports whether -trimpath is set in args or GOFLAGS.
+func trimpathRequested(args []string) bool {
+	for _, arg := range append(strings.Fields(os.Getenv("GOFLAGS")), args...) {
+		switch strings.TrimPrefix(arg, "-") {
+		case "-trimpath", "trimpath", "-trimpath=true", "trimpath=true":
+			return true
 		}
-	case "clean":
-		dir := getDir(2)
-		err := os.RemoveAll(filepath.Join(dir, ".inco_cache"))
+	}
+	return false
+}
+
+// hasFlag reports whether args contains the boolean flag name.
+func hasFlag(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == name || arg == "-"+name {
+			return true
+		}
+	}
+	return false
+}
+
+func runGen(dir string, cfg *config) {
+	if cfg.Disable {
+		fmt.Fprintln(os.Stderr, "inco: overlay generation disabled by INCO_DISABLE")
+		return
+	}
+	defer cfg.startProfile()()
+	e := newEngine(dir, cfg)
+	e.Run()
+	_ = e.Warnings // @inco: !cfg.Strict || len(e.Warnings) == 0, -panic(strictError(e.Warnings))
+	if !(!cfg.Strict || len(e.Warnings) == 0) {
+		panic(strictError(e.Warnings))
+	}
+}
+
+// strictError reports the warnings that fail generation under -strict.
+func strictError(warnings []inco.Warning) error {
+	var lines []string
+	for _, w := range warnings {
+		lines = append(lines, w.String())
+	}
+	return fmt.Errorf("%d warning(s) with -strict:\n\t%s", len(warnings), strings.Join(lines, "\n\t"))
+}
+
+// runGenCheck generates the overlay for dir into a temporary directory
+// and exits 1 unless the cache directory holds the same one, so that CI
+// can verify a committed overlay instead of regenerating it.
+func runGenCheck(dir string, cfg *config) {
+	if cfg.Disable {
+		fmt.Fprintln(os.Stderr, "inco: overlay generation disabled by INCO_DISABLE")
+		return
+	}
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	tmp, err := os.MkdirTemp("", "inco-check-")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	defer os.RemoveAll(tmp)
+	fresh := *cfg
+	fresh.CacheDir, fresh.Quiet = tmp, true
+	newEngine(absDir, &fresh).Run()
+
+	diffs, err := inco.StaleShadows(absDir, cfg.CacheDir, tmp)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintf(os.Stderr, "inco: %s is up to date\n", cfg.CacheDir)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "inco: generated artifacts out of date in %s (%d file(s)):\n", cfg.CacheDir, len(diffs))
+	for _, d := range diffs {
+		fmt.Fprintf(os.Stderr, "  %s\n", d)
+	}
+	fmt.Fprintln(os.Stderr, "inco: run 'inco gen' and commit the result")
+	os.RemoveAll(tmp)
+	os.Exit(1)
+}
+
+// newEngine returns an engine for dir configured by cfg.
+func newEngine(dir string, cfg *config) *inco.Engine {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	e := inco.NewEngine(absDir)
+	e.CacheDir = cfg.CacheDir
+	e.TrimPath = cfg.TrimPath
+	e.Typecheck = cfg.Typecheck
+	e.KeepGoing = cfg.KeepGoing
+	e.EnableTags = cfg.EnableTags
+	e.DisableTags = cfg.DisableTags
+	e.Packages = cfg.Packages
+	e.Messages = cfg.Messages
+	e.Overlays = cfg.Overlays
+	e.Sensitive = cfg.Sensitive
+	e.Limits = cfg.Limits()
+	e.TypecheckCache = cfg.TypecheckCache
+	e.MaxMemory = uint64(cfg.MaxMemory) << 20
+	e.Color = stderrColor
+	e.Quiet = cfg.Quiet
+	e.IncludeVendor = cfg.Vendor
+	e.LogViolations = cfg.LogViolations
+	e.CountHits = cfg.CountHits
+	e.Must = cfg.TestBuild
+	e.Tests = cfg.TestBuild
+	e.BuildTags = cfg.BuildTags
+	e.Progress = cfg.progress()
+	return e
+}
+
+// runServe answers JSON-RPC requests on stdin until it is closed or a
+// shutdown request arrives.
+func runServe(dir string, cfg *config) {
+	e := newEngine(dir, cfg)
+	e.Quiet = true
+	fmt.Fprintf(os.Stderr, "inco: serving JSON-RPC for %s on stdio\n", e.Root)
+	err := inco.NewServer(e).Serve(os.Stdin, os.Stdout)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
+
+// runServeGRPC serves the instrumentation service for the project in dir
+// on addr, host:port or unix:path, until interrupted.
+func runServeGRPC(dir string, cfg *config, addr string) {
+	e := newEngine(dir, cfg)
+	e.Quiet = true
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+	}
+	l, err := net.Listen(network, addr)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("serve -grpc: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("serve -grpc: %w", err))
+	}
+	fmt.Fprintf(os.Stderr, "inco: serving gRPC for %s on %s\n", e.Root, l.Addr())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = incogrpc.NewServer(e).Serve(ctx, l)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
+
+// runAudit audits the project in dir with the settings of cfg, opts
+// giving the options of the audit command itself, and the hits file
+// of -runtime when hitsPath is set.
+func runAudit(dir string, cfg *config, opts inco.AuditOptions, hitsPath string) *inco.AuditResult {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:91
+	var hits map[incolog.Site]bool
+	if hitsPath != "" {
+		hits, err = incolog.ReadHits(hitsPath)
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("audit -runtime: %w", err))
+		if !(err == nil) {
+			panic(fmt.Errorf("audit -runtime: %w", err))
+		}
+	}
+	opts.Limits = cfg.Limits()
+	opts.Progress = cfg.progress()
+	opts.IncludeVendor = cfg.Vendor
+	opts.ExcludeClosures = !cfg.Closures
+	opts.Handlers = cfg.Handlers
+	opts.Runtime = hits
+	return inco.AuditWith(absDir, opts)
+}
+
+// funcPattern compiles the regexp of the audit flag name, nil when it is
+// empty.
+func funcPattern(name, expr string) *regexp.Regexp {
+	_ = expr // @inco: expr != "", -return(nil)
+	if !(expr != "") {
+		return nil
+	}
+	re, err := regexp.Compile(expr)
+	_ = err // @inco: err == nil, -panic(usageError{"audit", fmt.Sprintf("-%s: %v", name, err)})
+	if !(err == nil) {
+		panic(usageError{"audit", fmt.Sprintf("-%s: %v", name, err)})
+	}
+	return re
+}
+
+// printWorklist prints marks as path:line:func, one per line, with paths
+// relative to the current directory as given by dir, for shell pipelines
+// and quickfix lists.
+func printWorklist(dir string, marks []inco.Mark) {
+	for _, m := range marks {
+		fmt.Printf("%s:%d:%s\n", filepath.Join(dir, m.Path), m.Line, m.Func)
+	}
+}
+
+// runInit scaffolds inco in dir, lists what it did and prints a first
+// audit.
+func runInit(dir string, cfg *config, opts inco.InitOptions) {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	opts.CacheDir = cfg.CacheDir
+	for _, step := range inco.Init(absDir, opts) {
+		fmt.Fprintf(os.Stderr, "inco: %s\n", step)
+	}
+	fmt.Fprintln(os.Stderr)
+	err = inco.TextRenderer{Color: stdoutColor}.Render(os.Stdout, runAudit(dir, cfg, inco.AuditOptions{}, ""))
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
+
+// runAnnotate marks the exported functions without contracts under dir,
+// or with undo removes the marks, and lists the lines it changed.
+func runAnnotate(dir string, undo bool) {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	if undo {
+		marks := inco.Unannotate(absDir)
+		for _, m := range marks {
+			fmt.Println(m)
+		}
+		fmt.Fprintf(os.Stderr, "inco: removed %d uncovered marker(s)\n", len(marks))
+		return
+	}
+	marks := inco.Annotate(absDir)
+	for _, m := range marks {
+		fmt.Println(m)
+	}
+	fmt.Fprintf(os.Stderr, "inco: marked %d uncovered function(s) with %s\n", len(marks), inco.UncoveredMarker)
+}
+
+// runExport writes the contracts under dir as documentation: one markdown
+// document per package (or a JSON array with format "json"), to stdout or,
+// with out, to out/<package dir>/<package>.md.
+func runExport(dir, format, out string) {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	pkgs := inco.Export(absDir)
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(append([]inco.PackageDoc{}, pkgs...))
 		_ = err // @inco: err == nil, -panic(err)
 		if !(err == nil) {
 			panic(err)
 		}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:60
-		fmt.Println("inco: cache cleaned")
+	case "", "markdown":
+		for i, p := range pkgs {
+			if out != "" {
+				writeMarkdownFile(filepath.Join(out, p.Dir, p.Name+".md"), p)
+				continue
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			p.WriteMarkdown(os.Stdout)
+		}
 	default:
-		fmt.Fprintf(os.Stderr, "inco: unknown command %q\n", os.Args[1])
-		fmt.Print(usage)
-		os.Exit(1)
+		panic(fmt.Errorf("export: unknown format %q (want markdown or json)", format))
 	}
 }
 
-// guardPanic recovers from panics (including those injected by @inco:)
-// and exits cleanly with the panic message.
-func guardPanic() {
-	if r := recover(); r != nil {
-		fmt.Fprintf(os.Stderr, "inco: %v\n", r)
+// runContractsDiff prints the contract changes in the current directory
+// between the git revisions refs[0] and refs[1], or the working tree, and
+// exits 1 when there are any.
+func runContractsDiff(refs []string, jsonOut bool) {
+	dir, err := os.Getwd()
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	old, err := inco.ExportRef(dir, refs[0])
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("contracts diff: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("contracts diff: %w", err))
+	}
+	newName, new := "the working tree", inco.Export(dir)
+	if len(refs) == 2 {
+		newName = refs[1]
+		new, err = inco.ExportRef(dir, refs[1])
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("contracts diff: %w", err))
+		if !(err == nil) {
+			panic(fmt.Errorf("contracts diff: %w", err))
+		}
+	}
+	changes := inco.DiffContracts(old, new)
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(append([]inco.FuncChange{}, changes...))
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+	} else {
+		inco.WriteContractDiff(os.Stdout, changes)
+	}
+	_ = changes // @inco: len(changes) > 0, -return
+	if !(len(changes) > 0) {
+		fmt.Fprintf(os.Stderr, "inco: no contract changes between %s and %s\n", refs[0], newName)
+		return
+	}
+	os.Exit(exitFailure)
+}
+
+// runReleaseCheck prints the contracts of exported functions under dir
+// that break callers since the revision since (default: the last tag) and
+// exits 1 when there are any.
+func runReleaseCheck(dir, since string, jsonOut bool, cfg *config) {
+	absDir, err := filepath.Abs(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	if since == "" {
+		since, err = inco.LastTag(absDir)
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("release-check: %w; pass -since", err))
+		if !(err == nil) {
+			panic(fmt.Errorf("release-check: %w; pass -since", err))
+		}
+	}
+	released, err := inco.ExportRef(absDir, since)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("release-check: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("release-check: %w", err))
+	}
+	breaking := inco.BreakingChanges(inco.DiffContracts(released, inco.Export(absDir)), cfg.CompatPkgs)
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(append([]inco.FuncChange{}, breaking...))
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+	} else {
+		inco.WriteContractDiff(os.Stdout, breaking)
+	}
+	_ = breaking // @inco: len(breaking) > 0, -return
+	if !(len(breaking) > 0) {
+		fmt.Fprintf(os.Stderr, "inco: contracts compatible with %s\n", since)
+		return
+	}
+	var n int
+	for _, fc := range breaking {
+		n += len(fc.Changes)
+	}
+	fmt.Fprintf(os.Stderr, "inco: %d contract change(s) since %s break callers: new preconditions or dropped postconditions\n", n, since)
+	os.Exit(exitFailure)
+}
+
+// writeMarkdownFile writes p's markdown document to path.
+func writeMarkdownFile(path string, p inco.PackageDoc) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	f, err := os.Create(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	defer f.Close()
+	p.WriteMarkdown(f)
+	fmt.Fprintf(os.Stderr, "  %s\n", path)
+}
+
+// runMutate runs contract mutation testing in the current directory and
+// exits non-zero if any mutant survives. args are passed to go test.
+func runMutate(args []string) {
+	absDir, err := filepath.Abs(".")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	r := inco.Mutate(absDir, args, os.Stderr)
+	r.PrintReport(os.Stdout)
+	if r.Killed < len(r.Mutants) {
 		os.Exit(1)
 	}
 }
 
-func getDir(argIdx int) string {
-//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:78
-	if !(len(os.Args) <= argIdx) {
-		return os.Args[argIdx]
+// runTry prints the code directive would inject and fails if it does
+// not parse or compile.
+func runTry(directive, params, results string, cfg *config) {
+	code, err := newEngine(".", cfg).Try(directive, params, results)
+	fmt.Print(code)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("try: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("try: %w", err))
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:79
-	return "."
 }
 
-func runGen(dir string) {
-	absDir, err := filepath.Abs(dir)
+// violationLog returns the violation log -log-violations writes to:
+// $INCO_VIOLATION_LOG, otherwise violations.jsonl in the cache directory.
+func violationLog(cfg *config) string {
+	if path := os.Getenv(incolog.Env); path != "" {
+		return path
+	}
+	return filepath.Join(cfg.CacheDir, "violations.jsonl")
+}
+
+// runViolationsShow prints the contracts violated in the log at path,
+// most frequent first.
+func runViolationsShow(path string) {
+	records, err := incolog.Read(path)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:85
-	inco.NewEngine(absDir).Run()
+	tallies := inco.TallyViolations(records)
+	fmt.Printf("inco: %d violation(s) of %d contract(s) logged in %s\n", len(records), len(tallies), path)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, t := range tallies {
+		fmt.Fprintf(tw, "%6d\t%s:%d\t%s\t%s\n", t.Count, t.Path, t.Line, t.Kind, t.Expr)
+		for _, msg := range t.Messages {
+			fmt.Fprintf(tw, "\t\t\t  %q\n", msg)
+		}
+	}
+	tw.Flush()
+}
+
+// runClean removes the cache directory of the project in dir.
+func runClean(dir string) {
+	inco.RemoveCache(loadConfig(dir, nil).CacheDir)
+	fmt.Println("inco: cache cleaned")
 }
 
-func runAudit(dir string) *inco.AuditResult {
+// runVet reports directive problems and exits non-zero if any remain.
+// With fix, suggested fixes are applied first; with jsonOut, the remaining
+// diagnostics are written as a JSON array.
+func runVet(dir string, cfg *config, jsonOut, fix bool) {
 	absDir, err := filepath.Abs(dir)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:91
-	return inco.Audit(absDir)
+	diags := inco.VetWith(absDir, inco.VetOptions{RequireMessages: cfg.RequireMessages, RequirePure: cfg.RequirePure})
+	if fix {
+		fixed := len(diags)
+		diags = inco.ApplyFixes(diags)
+		fmt.Fprintf(os.Stderr, "inco: applied %d fix(es)\n", fixed-len(diags))
+	}
+
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(append([]inco.Diagnostic{}, diags...))
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+	} else {
+		for _, d := range diags {
+			if rel, err := filepath.Rel(absDir, d.Path); err == nil {
+				d.Path = rel
+			}
+			loc := fmt.Sprintf("%s:%d:%d:", d.Path, d.Line, d.Column)
+			fmt.Printf("%s %s\n", stdoutColor.Bold(loc), stdoutColor.Error(d.Message))
+			for _, f := range d.Fixes {
+				fmt.Printf("\t%s %s\n", stdoutColor.OK("suggested fix:"), f.Message)
+			}
+		}
+	}
+	if len(diags) > 0 {
+		os.Exit(1)
+	}
 }
 
-func runRelease(dir string) {
+func runRelease(dir string, cfg *config) {
 	absDir, err := filepath.Abs(dir)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:97
-	inco.Release(absDir)
+	inco.Release(absDir, cfg.CacheDir)
 }
 
-func runReleaseClean(dir string) {
+func runReleaseClean(dir string, cfg *config) {
 	absDir, err := filepath.Abs(dir)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:103
-	inco.ReleaseClean(absDir)
+	inco.ReleaseClean(absDir, cfg.CacheDir)
 }
 
-func runGo(subcmd, dir string, extraArgs []string) {
-	overlayPath := filepath.Join(dir, ".inco_cache", "overlay.json")
-	if _, err := os.Stat(overlayPath); os.IsNotExist(err) {
-		execGo(subcmd, extraArgs)
-		return
-	}
-	absOverlay, err := filepath.Abs(overlayPath)
+// moduleRoot returns the root of the module containing the working
+// directory (see inco.ModuleRoot). The go commands generate and find the
+// overlay there wherever they are run from.
+func moduleRoot() string {
+	wd, err := os.Getwd()
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/cmd/inco/main.inco.go:114
-	args := append([]string{fmt.Sprintf("-overlay=%s", absOverlay)}, extraArgs...)
-	execGo(subcmd, args)
+	return inco.ModuleRoot(wd)
+}
+
+// runGo runs the go subcommand in the working directory with the overlay
+// from cfg.CacheDir, if one exists, after checking that every shadow it
+// maps to is there. root is the directory the overlay was generated for.
+// While the go command runs, inco gen in other processes keeps those
+// shadows (see inco.UseOverlay). With cfg.Disable the overlay is never
+// used.
+func runGo(subcmd, root string, cfg *config, extraArgs []string) {
+	extraArgs, dryRun := stripDryRun(subcmd, stripIncoFlags(subcmd, extraArgs))
+	args := extraArgs
+	release := func() {}
+	overlayPath := filepath.Join(cfg.CacheDir, "overlay.json")
+	if _, err := os.Stat(overlayPath); err == nil && !cfg.Disable {
+		if !dryRun {
+			release, err = inco.UseOverlay(cfg.CacheDir)
+			_ = err // @inco: err == nil, -panic(fmt.Errorf("%w; run 'inco clean' and try again", err))
+			if !(err == nil) {
+				panic(fmt.Errorf("%w; run 'inco clean' and try again", err))
+			}
+		}
+		args = append([]string{fmt.Sprintf("-overlay=%s", overlayPath)}, extraArgs...)
+	}
+
+	if cfg.LogViolations && os.Getenv(incolog.Env) == "" {
+		os.Setenv(incolog.Env, violationLog(cfg))
+	}
+	if cfg.CountHits && os.Getenv(incolog.HitsEnv) == "" {
+		os.Setenv(incolog.HitsEnv, filepath.Join(cfg.CacheDir, "hits.json"))
+	}
+
+	cmdline := formatCommand(append([]string{"go", subcmd}, args...))
+	if dryRun {
+		fmt.Println(cmdline)
+		return
+	}
+	if slices.Contains(args[:goFlagsEnd(subcmd, args)], "-x") {
+		fmt.Fprintln(os.Stderr, cmdline)
+	}
+	if subcmd != "run" && subcmd != "test" || cfg.Disable {
+		code := execGo(subcmd, args, os.Stdout, os.Stderr)
+		release()
+		exitOnFailure(code)
+		return
+	}
+
+	// A program or test ended by a contract exits with exitViolation.
+	e := newEngine(root, cfg)
+	stdout, stderr := e.NewViolationScanner(), e.NewViolationScanner()
+	code := execGo(subcmd, args, io.MultiWriter(os.Stdout, stdout), io.MultiWriter(os.Stderr, stderr))
+	release()
+	stdout.Close()
+	stderr.Close()
+	violations := append(stdout.Violations, stderr.Violations...)
+	if len(violations) == 0 {
+		exitOnFailure(code)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\n%s\n", stderrColor.Error(fmt.Sprintf("inco: %d contract violation(s):", len(violations))))
+	for _, v := range violations {
+		fmt.Fprintf(os.Stderr, "  %s\n", strings.ReplaceAll(v.String(), "\n", "\n  "))
+	}
+	os.Exit(exitViolation)
+}
+
+// goFlagsEnd returns the index in args where go command flags end: at
+// -args, or for go run at the first non-flag argument (the package),
+// after which everything belongs to the program.
+func goFlagsEnd(subcmd string, args []string) int {
+	for i, arg := range args {
+		if arg == "-args" || (subcmd == "run" && !strings.HasPrefix(arg, "-")) {
+			return i
+		}
+	}
+	return len(args)
+}
+
+// goTags returns the value of the -tags flag among the go command flags in
+// args, given as -tags=list or -tags list, or "".
+func goTags(subcmd string, args []string) string {
+	flags := args[:goFlagsEnd(subcmd, args)]
+	var tags string
+	for i, arg := range flags {
+		if v, ok := cutFlag(arg, "tags"); ok {
+			tags = v
+		} else if (arg == "-tags" || arg == "--tags") && i+1 < len(args) {
+			tags = args[i+1] // for go run, goFlagsEnd takes the value for the package
+		}
+	}
+	return tags
+}
+
+// stripNoColor removes -no-color from the go command flags in args and
+// reports whether it was present.
+func stripNoColor(subcmd string, args []string) ([]string, bool) {
+	end := goFlagsEnd(subcmd, args)
+	i := slices.IndexFunc(args[:end], func(arg string) bool { return hasFlag([]string{arg}, "-no-color") })
+	_ = i // @inco: i >= 0, -return(args, false)
+	if !(i >= 0) {
+		return args, false
+	}
+	return slices.Delete(slices.Clone(args), i, i+1), true
 }
 
-func execGo(subcmd string, args []string) {
+// stripDryRun removes inco's -n flag from the go command flags in args and
+// reports whether it was present. With -n, inco prints the go command it
+// would run, overlay included, instead of running it.
+func stripDryRun(subcmd string, args []string) ([]string, bool) {
+	end := goFlagsEnd(subcmd, args)
+	i := slices.Index(args[:end], "-n")
+	_ = i // @inco: i >= 0, -return(args, false)
+	if !(i >= 0) {
+		return args, false
+	}
+	return slices.Delete(slices.Clone(args), i, i+1), true
+}
+
+// formatCommand renders argv as a shell command line, single-quoting
+// arguments that contain characters the shell would interpret.
+func formatCommand(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if arg != "" && !strings.ContainsFunc(arg, needsQuote) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// needsQuote reports whether r is special to a POSIX shell.
+func needsQuote(r rune) bool {
+	return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		strings.ContainsRune("-_=./,:@%+", r))
+}
+
+// reportToolchain prints what the go toolchain lacks for inco, if
+// anything, after go subcmd exited with code: a go release without
+// -overlay rejects the flag, and so on. Other failures are go's own to
+// explain.
+func reportToolchain(subcmd string, code int) {
+	t, err := inco.DetectToolchain(".")
+	_ = err // @inco: err == nil, -return
+	if !(err == nil) {
+		return
+	}
+	problems := t.Problems()
+	_ = problems // @inco: len(problems) > 0, -return
+	if !(len(problems) > 0) {
+		return
+	}
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s %s\n", stderrColor.Error("inco:"), p)
+	}
+	fmt.Fprintf(os.Stderr, "inco: go %s exited with status %d\n", subcmd, code)
+}
+
+// runDoctor prints the effective settings, then the go toolchain and
+// what it lacks for inco.
+func runDoctor(dir string, cfg *config) {
+	cfg.PrintReport(os.Stdout)
+	t, err := inco.DetectToolchain(dir)
+	if err != nil {
+		fmt.Printf("\nGo toolchain: %s\n", stdoutColor.Error(err.Error()))
+		return
+	}
+	fmt.Printf("\nGo toolchain: %s, go.mod %s\n", t.Version, formatPath(t.GOMOD))
+	for _, p := range t.Problems() {
+		fmt.Printf("  %s\n", stdoutColor.Error(p))
+	}
+}
+
+// exitOnFailure exits with code unless it is 0.
+func exitOnFailure(code int) {
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// execGo runs go subcmd with args and returns its exit code. When go
+// fails, the toolchain problems that explain it follow its own output.
+func execGo(subcmd string, args []string, stdout, stderr io.Writer) int {
 	cmd := execCommand("go", append([]string{subcmd}, args...)...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Stdin = os.Stdin
-	if err := cmd.Run(); err != nil {
-		os.Exit(1)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		reportToolchain(subcmd, exitErr.ExitCode())
+		return exitErr.ExitCode()
+	}
+	var notFound *exec.Error
+	_ = err // @inco: !errors.As(err, &notFound), -panic(inco.ErrNoGo)
+	if !(!errors.As(err, &notFound)) {
+		panic(inco.ErrNoGo)
+	}
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
 	}
+	return 0
 }
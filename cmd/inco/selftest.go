@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// selfModule is the module path verify-self expects to find in dir.
+const selfModule = "github.com/imnive-design/inco-go"
+
+// runVerifySelf regenerates inco's own shadows with typechecking, then
+// builds and tests the module under the overlay. It guarantees that the
+// tool still builds itself, and passes its tests, with contracts enabled.
+func runVerifySelf(dir string, cfg *config) {
+	_ = cfg.Disable // @inco: !cfg.Disable, -panic("verify-self: overlay generation is disabled by INCO_DISABLE")
+	if !(!cfg.Disable) {
+		panic("verify-self: overlay generation is disabled by INCO_DISABLE")
+	}
+	mod := modulePath(dir)
+	_ = mod // @inco: mod == selfModule, -panic(fmt.Errorf("verify-self: %s is module %q, not %s", dir, mod, selfModule))
+	if !(mod == selfModule) {
+		panic(fmt.Errorf("verify-self: %s is module %q, not %s", dir, mod, selfModule))
+	}
+	// The go commands below run in the working directory.
+	err := os.Chdir(dir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+
+	cfg.Typecheck = true
+	runGen(".", cfg)
+	for _, subcmd := range []string{"build", "vet", "test"} {
+		fmt.Fprintf(os.Stderr, "inco: verify-self: go %s ./...\n", subcmd)
+		runGo(subcmd, ".", cfg, []string{"./..."})
+	}
+	fmt.Println("inco: verify-self passed")
+}
+
+// modulePath returns the module path declared by dir/go.mod.
+func modulePath(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("verify-self: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("verify-self: %w", err))
+	}
+	return modfile.ModulePath(data)
+}
@@ -2,4 +2,18 @@ module github.com/imnive-design/inco-go
 
 go 1.25.0
 
-require golang.org/x/tools v0.42.0
+require (
+	github.com/golangci/plugin-module-register v0.1.2
+	golang.org/x/mod v0.33.0
+	golang.org/x/tools v0.42.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251202230838-ff82c1b0f217
+	google.golang.org/grpc v1.79.3
+	google.golang.org/protobuf v1.36.10
+)
+
+require (
+	golang.org/x/net v0.50.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+)
@@ -0,0 +1,75 @@
+// Package incoexpr provides predicates for contract expressions, so that
+// common checks read as one call instead of boolean plumbing:
+//
+//	// @inco: incoexpr.Between(age, 0, 150)
+//	// @inco: incoexpr.OneOf(mode, "r", "w", "rw"), -panic("bad mode")
+//
+// inco gen adds the import to the shadow when a directive uses it; the
+// module only needs to require github.com/imnive-design/inco-go.
+package incoexpr
+
+import (
+	"cmp"
+	"reflect"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// NonEmpty reports whether v has a length above zero when it is a
+// string, slice, map, array or channel, and otherwise whether v is not
+// its type's zero value. A nil v is empty.
+func NonEmpty(v any) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array, reflect.Chan:
+		return rv.Len() > 0
+	}
+	return !rv.IsZero()
+}
+
+// Between reports whether lo <= v <= hi.
+func Between[T cmp.Ordered](v, lo, hi T) bool {
+	return cmp.Compare(lo, v) <= 0 && cmp.Compare(v, hi) <= 0
+}
+
+// OneOf reports whether v equals one of options.
+func OneOf[T comparable](v T, options ...T) bool {
+	return slices.Contains(options, v)
+}
+
+// compiled caches the patterns MatchesRegexp has compiled, so that a
+// contract on a hot path compiles its pattern once.
+var compiled sync.Map // pattern → *regexp.Regexp
+
+// MatchesRegexp reports whether s contains a match of the regular
+// expression pattern. An invalid pattern is a bug in the contract, not a
+// violation: MatchesRegexp panics with the compile error.
+func MatchesRegexp(s, pattern string) bool {
+	re, ok := compiled.Load(pattern)
+	if !ok {
+		re, _ = compiled.LoadOrStore(pattern, regexp.MustCompile(pattern))
+	}
+	return re.(*regexp.Regexp).MatchString(s)
+}
+
+// AllNonNil reports whether no value in vs is nil, including a nil
+// pointer, slice, map, channel, function or interface stored in a
+// non-nil interface.
+func AllNonNil(vs ...any) bool {
+	for _, v := range vs {
+		if v == nil {
+			return false
+		}
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
+			if rv.IsNil() {
+				return false
+			}
+		}
+	}
+	return true
+}
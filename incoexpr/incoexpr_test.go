@@ -0,0 +1,77 @@
+package incoexpr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNonEmpty(t *testing.T) {
+	var nilPtr *int
+	var nilErr error
+	tests := []struct {
+		v    any
+		want bool
+	}{
+		{"", false},
+		{"x", true},
+		{[]int(nil), false},
+		{[]int{0}, true},
+		{map[string]int{}, false},
+		{map[string]int{"a": 1}, true},
+		{[0]int{}, false},
+		{0, false},
+		{3, true},
+		{nilPtr, false},
+		{nilErr, false},
+		{errors.New("e"), true},
+	}
+	for _, tt := range tests {
+		if got := NonEmpty(tt.v); got != tt.want {
+			t.Errorf("NonEmpty(%#v) = %t, want %t", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	if !Between(0, 0, 150) || !Between(150, 0, 150) || !Between(42, 0, 150) {
+		t.Error("bounds should be inclusive")
+	}
+	if Between(-1, 0, 150) || Between(151, 0, 150) {
+		t.Error("values outside the range accepted")
+	}
+	if !Between("b", "a", "c") || Between(2.5, 3, 4) {
+		t.Error("wrong result for strings or floats")
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	if !OneOf("rw", "r", "w", "rw") || OneOf("x", "r", "w") || OneOf(1) {
+		t.Error("wrong membership")
+	}
+}
+
+func TestMatchesRegexp(t *testing.T) {
+	for i := 0; i < 2; i++ { // second round hits the cache
+		if !MatchesRegexp("user-42", `^user-\d+$`) || MatchesRegexp("user-x", `^user-\d+$`) {
+			t.Fatal("wrong match")
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("invalid pattern did not panic")
+		}
+	}()
+	MatchesRegexp("x", "(")
+}
+
+func TestAllNonNil(t *testing.T) {
+	var nilPtr *int
+	var nilMap map[string]int
+	x := 1
+	if !AllNonNil() || !AllNonNil(&x, "", 0, map[string]int{}) {
+		t.Error("non-nil values rejected")
+	}
+	if AllNonNil(&x, nil) || AllNonNil(nilPtr) || AllNonNil(&x, nilMap) {
+		t.Error("nil values accepted")
+	}
+}
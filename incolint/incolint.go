@@ -0,0 +1,128 @@
+// Package incolint runs the checks of inco vet as a go/analysis analyzer,
+// and registers it as the golangci-lint module plugin "inco", so teams
+// already running golangci-lint get inco's diagnostics without another
+// CI step. Build a custom golangci-lint with .custom-gcl.yml:
+//
+//	version: v2.1.0
+//	plugins:
+//	  - module: github.com/imnive-design/inco-go
+//	    import: github.com/imnive-design/inco-go/incolint
+//	    version: latest
+//
+// and enable it in .golangci.yml:
+//
+//	linters:
+//	  enable: [inco]
+//	  settings:
+//	    custom:
+//	      inco:
+//	        type: module
+//	        settings:
+//	          require-messages: true
+//	          require-contracts: true
+//	          require-pure: true
+package incolint
+
+import (
+	"go/ast"
+	"go/token"
+
+	"github.com/golangci/plugin-module-register/register"
+	"github.com/imnive-design/inco-go/internal/inco"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Settings enables the optional checks, as inco vet's flags do.
+type Settings struct {
+	// RequireMessages reports contracts in exported functions that panic
+	// without a message of their own (-require-messages).
+	RequireMessages bool `json:"require-messages"`
+
+	// RequireContracts reports exported functions without a contract, the
+	// functions inco audit -annotate marks.
+	RequireContracts bool `json:"require-contracts"`
+
+	// RequirePure reports contracts calling functions not known to be
+	// free of side effects (-require-pure).
+	RequirePure bool `json:"require-pure"`
+}
+
+// Analyzer reports malformed directives, expressions that do not parse,
+// names that are not declared, contracts that contradict each other and
+// the other problems inco vet finds, with its suggested fixes.
+var Analyzer = New(Settings{})
+
+// New returns an analyzer with the optional checks s enables.
+func New(s Settings) *analysis.Analyzer {
+	opts := inco.VetOptions{RequireMessages: s.RequireMessages, RequireContracts: s.RequireContracts, RequirePure: s.RequirePure}
+	return &analysis.Analyzer{
+		Name: "inco",
+		Doc:  "check inco directives (// @inco:, @ensure, @invariant, @must)",
+		URL:  "https://github.com/imnive-design/inco-go#directive-checks-inco-vet",
+		Run: func(pass *analysis.Pass) (any, error) {
+			for _, f := range pass.Files {
+				run(pass, f, opts)
+			}
+			return nil, nil
+		},
+	}
+}
+
+// run reports the diagnostics of inco vet for the file f of pass.
+func run(pass *analysis.Pass, f *ast.File, opts inco.VetOptions) {
+	tf := pass.Fset.File(f.Pos())
+	_ = tf // @inco: tf != nil, -return
+	if !(tf != nil) {
+		return
+	}
+	// inco vet positions are unadjusted: //line directives do not move them.
+	pos := func(line, col int) token.Pos {
+		if line < 1 || line > tf.LineCount() {
+			return f.Pos()
+		}
+		return tf.LineStart(line) + token.Pos(col-1)
+	}
+	for _, d := range inco.VetFile(tf.Name(), opts) {
+		diag := analysis.Diagnostic{Pos: pos(d.Line, d.Column), Category: string(d.Code), Message: d.Message}
+		for _, fix := range d.Fixes {
+			sf := analysis.SuggestedFix{Message: fix.Message}
+			for _, e := range fix.Edits {
+				sf.TextEdits = append(sf.TextEdits, analysis.TextEdit{Pos: tf.Pos(e.Offset), End: tf.Pos(e.End), NewText: []byte(e.NewText)})
+			}
+			diag.SuggestedFixes = append(diag.SuggestedFixes, sf)
+		}
+		pass.Report(diag)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// golangci-lint module plugin
+// ---------------------------------------------------------------------------
+
+func init() {
+	register.Plugin("inco", newPlugin)
+}
+
+// plugin is the golangci-lint plugin: one analyzer configured by the
+// settings of .golangci.yml.
+type plugin struct {
+	settings Settings
+}
+
+func newPlugin(conf any) (register.LinterPlugin, error) {
+	s, err := register.DecodeSettings[Settings](conf)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	return &plugin{settings: s}, nil
+}
+
+func (p *plugin) BuildAnalyzers() ([]*analysis.Analyzer, error) {
+	return []*analysis.Analyzer{New(p.settings)}, nil
+}
+
+// GetLoadMode is syntax: inco vet needs no type information.
+func (p *plugin) GetLoadMode() string {
+	return register.LoadModeSyntax
+}
@@ -0,0 +1,95 @@
+package incolint
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golangci/plugin-module-register/register"
+	"golang.org/x/tools/go/analysis"
+)
+
+const src = `package p
+
+//line other.go:100
+func Exported(n int) {
+	_ = n // @inco:n > 0
+}
+
+func Covered(n int) {
+	_ = n // @inco: n > 0
+}
+`
+
+// analyze runs a on a file holding src and returns what it reports.
+func analyze(t *testing.T, a *analysis.Analyzer) (*token.FileSet, []analysis.Diagnostic) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "p.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []analysis.Diagnostic
+	pass := &analysis.Pass{Analyzer: a, Fset: fset, Files: []*ast.File{f},
+		Report: func(d analysis.Diagnostic) { diags = append(diags, d) }}
+	if _, err := a.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+	return fset, diags
+}
+
+func TestAnalyzer(t *testing.T) {
+	fset, diags := analyze(t, Analyzer)
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "missing space after @inco:") {
+		t.Fatalf("diagnostics = %+v, want the missing space", diags)
+	}
+	if pos := fset.PositionFor(diags[0].Pos, false); pos.Line != 5 || pos.Column != 17 {
+		t.Errorf("position = %d:%d, want 5:17 regardless of //line", pos.Line, pos.Column)
+	}
+	fixes := diags[0].SuggestedFixes
+	if len(fixes) != 1 || len(fixes[0].TextEdits) != 1 {
+		t.Fatalf("fixes = %+v, want one edit", fixes)
+	}
+	e := fixes[0].TextEdits[0]
+	tf := fset.File(e.Pos)
+	if fixed := src[:tf.Offset(e.Pos)] + string(e.NewText) + src[tf.Offset(e.End):]; strings.Count(fixed, "// @inco: n > 0") != 2 {
+		t.Errorf("fixed source:\n%s", fixed)
+	}
+
+	_, diags = analyze(t, New(Settings{RequireContracts: true}))
+	if len(diags) != 2 || diags[1].Message != "exported function Exported has no contract" {
+		t.Errorf("with require-contracts, diagnostics = %+v", diags)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	newPlugin, err := register.GetPlugin("inco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newPlugin(map[string]any{"require-contracts": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GetLoadMode() != register.LoadModeSyntax {
+		t.Errorf("load mode = %s, want syntax", p.GetLoadMode())
+	}
+	analyzers, err := p.BuildAnalyzers()
+	if err != nil || len(analyzers) != 1 {
+		t.Fatalf("BuildAnalyzers = %v, %v", analyzers, err)
+	}
+	if _, diags := analyze(t, analyzers[0]); len(diags) != 2 {
+		t.Errorf("diagnostics = %+v, want 2 with require-contracts", diags)
+	}
+	if _, err := newPlugin(map[string]any{"require-message": true}); err == nil {
+		t.Error("unknown setting accepted")
+	}
+}
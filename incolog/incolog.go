@@ -0,0 +1,182 @@
+// Package incolog records contract violations instead of panicking. Code
+// generated with inco's -log-violations setting calls Violation where a
+// contract would panic, so a whole test suite runs to the end and reports
+// how often each contract would have fired:
+//
+//	INCO_LOG_VIOLATIONS=1 inco test ./...
+//	inco violations show
+//
+// Records are appended as JSON lines to the file named by $INCO_VIOLATION_LOG,
+// or written to standard error when it is unset. Under -count-hits, Hit
+// records which contracts were evaluated at all, for inco audit -runtime.
+// The package has no
+// dependencies outside the standard library, since instrumented programs
+// link it.
+package incolog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Env is the environment variable naming the log file.
+const Env = "INCO_VIOLATION_LOG"
+
+// Record is one logged violation.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`              // "require", "invariant" or "ensure-closed"
+	File    string    `json:"file"`              // file of the directive, relative to the root inco ran in
+	Line    int       `json:"line"`              // line of the directive
+	Expr    string    `json:"expr"`              // the contract's expression; for ensure-closed, the resource
+	Message string    `json:"message,omitempty"` // the -panic value, printed; "" for the default message
+}
+
+var (
+	mu  sync.Mutex
+	out *os.File // opened on first use; nil until then
+)
+
+// Violation logs a violation of the contract expr of the given kind at
+// file:line. value is the value the contract would have panicked with, or
+// nil for the default message. Write errors are reported on standard
+// error rather than interrupting the program.
+func Violation(kind, file string, line int, expr string, value any) {
+	r := Record{Time: time.Now().UTC(), Kind: kind, File: file, Line: line, Expr: expr}
+	if value != nil {
+		r.Message = fmt.Sprint(value)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		data, _ = json.Marshal(Record{Time: r.Time, Kind: kind, File: file, Line: line, Expr: expr})
+	}
+	data = append(data, '\n')
+
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		out = open()
+	}
+	if _, err := out.Write(data); err != nil && out != os.Stderr {
+		fmt.Fprintf(os.Stderr, "incolog: %v\n", err)
+		os.Stderr.Write(data)
+	}
+}
+
+// open returns the log file named by Env, opened for appending, or
+// standard error.
+func open() *os.File {
+	path := os.Getenv(Env)
+	_ = path // @inco: path != "", -return(os.Stderr)
+	if !(path != "") {
+		return os.Stderr
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	_ = err // @inco: err == nil, -return(stderrFallback(err))
+	if !(err == nil) {
+		return stderrFallback(err)
+	}
+	return f
+}
+
+// stderrFallback reports why the log file cannot be opened and returns
+// standard error in its place.
+func stderrFallback(err error) *os.File {
+	fmt.Fprintf(os.Stderr, "incolog: %v; logging to standard error\n", err)
+	return os.Stderr
+}
+
+// Read returns the records of the log file at path, in order. Lines that
+// are not records, such as one cut short by a crash, are skipped.
+func Read(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	defer f.Close()
+	var records []Record
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var r Record
+		if json.Unmarshal(sc.Bytes(), &r) == nil && r.File != "" {
+			records = append(records, r)
+		}
+	}
+	return records, sc.Err()
+}
+
+// ---------------------------------------------------------------------------
+// Hits: contracts evaluated at least once
+// ---------------------------------------------------------------------------
+
+// HitsEnv is the environment variable naming the file Hit records the
+// evaluated contracts in. Hit records nothing when it is unset.
+const HitsEnv = "INCO_HITS_LOG"
+
+// Site is the position of a contract: its file, relative to the root inco
+// ran in, and its line.
+type Site struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+var (
+	hitMu   sync.Mutex
+	hit     = make(map[Site]bool) // sites recorded by this process
+	hitsOut *os.File              // opened on first use; nil until then
+)
+
+// Hit records that the contract at file:line was evaluated. Code generated
+// with inco's -count-hits setting calls it before each check. A site is
+// appended to the log once per process, as a JSON line, so every test
+// binary of a run can share the log.
+func Hit(file string, line int) {
+	s := Site{File: file, Line: line}
+	hitMu.Lock()
+	defer hitMu.Unlock()
+	if hit[s] {
+		return
+	}
+	hit[s] = true
+	if hitsOut == nil {
+		path := os.Getenv(HitsEnv)
+		_ = path // @inco: path != "", -return
+		if !(path != "") {
+			return
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		_ = err // @inco: err == nil, -return
+		if !(err == nil) {
+			return
+		}
+		hitsOut = f
+	}
+	data, _ := json.Marshal(s)
+	hitsOut.Write(append(data, '\n'))
+}
+
+// ReadHits returns the sites in the hits log at path. Lines that are not
+// sites are skipped.
+func ReadHits(path string) (map[Site]bool, error) {
+	f, err := os.Open(path)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	defer f.Close()
+	sites := make(map[Site]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s Site
+		if json.Unmarshal(sc.Bytes(), &s) == nil && s.File != "" {
+			sites[s] = true
+		}
+	}
+	return sites, sc.Err()
+}
@@ -0,0 +1,61 @@
+package incolog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestViolationRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "violations.jsonl")
+	t.Setenv(Env, path)
+	out = nil
+	t.Cleanup(func() { out = nil })
+
+	Violation("require", "api/user.go", 18, "len(name) > 0", nil)
+	Violation("require", "api/user.go", 18, "len(name) > 0", errors.New("name required"))
+	Violation("ensure-closed", "store.go", 12, "f", nil)
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"time":"2026-01-02T15:04:05Z","kind":"req`) // cut short
+	f.Close()
+
+	records, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("read %d records, want 3: %+v", len(records), records)
+	}
+	if r := records[0]; r.Kind != "require" || r.File != "api/user.go" || r.Line != 18 || r.Expr != "len(name) > 0" || r.Message != "" {
+		t.Errorf("first record = %+v", r)
+	}
+	if records[1].Message != "name required" {
+		t.Errorf("message = %q", records[1].Message)
+	}
+	if records[2].Kind != "ensure-closed" || records[2].Time.IsZero() {
+		t.Errorf("third record = %+v", records[2])
+	}
+}
+
+func TestHitReadHits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hits.json")
+	t.Setenv(HitsEnv, path)
+	hitsOut, hit = nil, make(map[Site]bool)
+	t.Cleanup(func() { hitsOut, hit = nil, make(map[Site]bool) })
+
+	Hit("api/user.go", 18)
+	Hit("api/user.go", 18)
+	Hit("store.go", 12)
+
+	data, _ := os.ReadFile(path)
+	if n := len(bytes.Split(bytes.TrimSpace(data), []byte("\n"))); n != 2 {
+		t.Errorf("%d lines written, want one per site:\n%s", n, data)
+	}
+	sites, err := ReadHits(path)
+	if err != nil || len(sites) != 2 || !sites[Site{"api/user.go", 18}] || !sites[Site{"store.go", 12}] {
+		t.Errorf("ReadHits = %v, %v", sites, err)
+	}
+}
@@ -0,0 +1,204 @@
+// Package incotest checks, from tests run with inco test, that code
+// violates its contracts when it should.
+//
+//	func TestCreate_EmptyName(t *testing.T) {
+//		incotest.ExpectViolation(t, func() { Create("") }, incotest.Require, "name required")
+//	}
+package incotest
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	inco "github.com/imnive-design/inco-go/internal/inco"
+)
+
+// Kind is the kind of contract a violation comes from.
+type Kind string
+
+const (
+	Any          Kind = ""              // any contract
+	Require      Kind = "require"       // @inco: precondition
+	Invariant    Kind = "invariant"     // @invariant loop invariant
+	Ensure       Kind = "ensure"        // @ensure postcondition
+	EnsureClosed Kind = "ensure-closed" // @ensure -closed postcondition
+	Must         Kind = "must"          // @must(timeout) channel deadline
+)
+
+// Violation is a recovered contract violation.
+type Violation struct {
+	Kind    Kind   // Any when only a message that does not tell identified it
+	Expr    string // the contract's expression; for EnsureClosed, the resource; for Must, the timeout
+	Path    string // file of the directive
+	Line    int    // line of the directive
+	Value   any    // the recovered panic value
+	Message string // Value as printed
+}
+
+func (v *Violation) String() string {
+	loc := v.Path
+	if v.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", v.Path, v.Line)
+	}
+	return fmt.Sprintf("%s violation of %s at %s: %s", describe(v.Kind), v.Expr, loc, v.Message)
+}
+
+// ExpectViolation runs fn and reports an error on t unless fn panics with
+// a violation of a contract of the given kind (Any for every kind) whose
+// message contains msg ("" for any message). It returns the violation,
+// or nil.
+//
+// The contract is found from where the panic was raised, so custom
+// -panic(...) messages are recognised too. fn must run under the overlay
+// of inco test; without it there are no contracts to violate.
+func ExpectViolation(t testing.TB, fn func(), kind Kind, msg string) *Violation {
+	t.Helper()
+	v, value, panicked := run(fn)
+	switch {
+	case !panicked:
+		t.Errorf("expected a %s violation, but the function returned normally (is the test running under inco test?)", describe(kind))
+	case v == nil:
+		t.Errorf("expected a %s violation, but the function panicked with %v, which is not a contract violation", describe(kind), value)
+	case kind != Any && v.Kind != kind:
+		t.Errorf("expected a %s violation, got %s", describe(kind), v)
+	case !strings.Contains(v.Message, msg):
+		t.Errorf("expected a %s violation with message containing %q, got %s", describe(kind), msg, v)
+	default:
+		return v
+	}
+	return nil
+}
+
+// ExpectNoViolation runs fn and reports an error on t if it panics with a
+// contract violation. Other panics propagate.
+func ExpectNoViolation(t testing.TB, fn func()) {
+	t.Helper()
+	v, value, panicked := run(fn)
+	if v != nil {
+		t.Errorf("unexpected %s", v)
+	} else if panicked {
+		panic(value)
+	}
+}
+
+// describe names kind in messages.
+func describe(kind Kind) string {
+	if kind == Any {
+		return "contract"
+	}
+	return string(kind)
+}
+
+// run calls fn and returns the violation it panicked with, if any.
+func run(fn func()) (v *Violation, value any, panicked bool) {
+	defer func() {
+		if value = recover(); value != nil || panicked {
+			file, line := panicSite()
+			v = violation(value, file, line)
+		}
+	}()
+	panicked = true
+	fn()
+	panicked = false
+	return nil, nil, false
+}
+
+// panicSite returns the file and line of the frame that called panic.
+// It must be called by the deferred function that recovers.
+func panicSite() (file string, line int) {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if f.Function == "runtime.gopanic" {
+			f, _ = frames.Next()
+			return f.File, f.Line
+		}
+		if !more {
+			return "", 0
+		}
+	}
+}
+
+// violation returns the violation that panicked with value at file:line,
+// or nil when no directive is there and value is not a default violation
+// message.
+func violation(value any, file string, line int) *Violation {
+	v := &Violation{Value: value, Message: fmt.Sprint(value)}
+	if err, ok := value.(error); ok {
+		v.Message = err.Error()
+	}
+	if d := directiveAt(file, line); d != nil {
+		v.Kind, v.Expr, v.Path, v.Line = Kind(d.Kind.String()), d.Expr, file, line
+		return v
+	}
+	// A deferred @ensure -closed check, or a frame without position
+	// information: the message tells the directive's position, but not
+	// its kind, unless the message is one only some kinds print.
+	rest, ok := strings.CutPrefix(v.Message, "inco violation: ")
+	_ = ok // @inco: ok, -return(nil)
+	if !(ok) {
+		return nil
+	}
+	i := strings.LastIndex(rest, " (at ")
+	_ = i // @inco: i >= 0, -return(nil)
+	if !(i >= 0) {
+		return nil
+	}
+	v.Expr, v.Path = rest[:i], strings.TrimSuffix(rest[i+len(" (at "):], ")")
+	if j := strings.LastIndexByte(v.Path, ':'); j >= 0 {
+		if n, err := strconv.Atoi(v.Path[j+1:]); err == nil {
+			v.Path, v.Line = v.Path[:j], n
+		}
+	}
+	if resource, ok := strings.CutSuffix(v.Expr, " not closed before return"); ok {
+		v.Kind, v.Expr = EnsureClosed, resource
+	} else if j := strings.LastIndex(v.Expr, " did not complete within "); j >= 0 {
+		v.Kind, v.Expr = Must, v.Expr[j+len(" did not complete within "):]
+	}
+	return v
+}
+
+// directiveAt returns the directive in the comment on line of file, or
+// nil. A line with several directives yields the first that checks an
+// expression or sets a channel deadline.
+func directiveAt(file string, line int) *inco.Directive {
+	_ = file // @inco: file != "" && line > 0, -return(nil)
+	if !(file != "" && line > 0) {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	lines := strings.Split(string(data), "\n")
+	_ = lines // @inco: line <= len(lines), -return(nil)
+	if !(line <= len(lines)) {
+		return nil
+	}
+	src := []byte(lines[line-1])
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile(file, -1, len(src)), src, nil, scanner.ScanComments)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return nil
+		}
+		if tok != token.COMMENT {
+			continue
+		}
+		for _, d := range inco.ParseDirectives(lit) {
+			if d.Kind == inco.KindRequire || d.Kind == inco.KindInvariant || d.Kind == inco.KindEnsure || d.Kind == inco.KindMust {
+				return d
+			}
+		}
+	}
+}
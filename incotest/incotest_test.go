@@ -0,0 +1,141 @@
+package incotest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The functions below are written as inco generates them: the panic maps
+// to its directive's line, so the tests need no overlay.
+
+func create(name string) {
+	_ = name // @inco: len(name) > 0, -panic("name required")
+	if !(len(name) > 0) {
+//line incotest_test.go:13
+		panic("name required")
+	}
+//line incotest_test.go:19
+}
+
+func sum(xs []int) (n int) {
+	for i := 0; i < 3; i++ {
+		// @invariant i < len(xs), -panic(fmt.Errorf("index %d", i))
+		if !(i < len(xs)) {
+//line incotest_test.go:23
+			panic(fmt.Errorf("index %d", i))
+		}
+//line incotest_test.go:29
+		n += xs[i]
+	}
+	return n
+}
+
+func closeLater() {
+	panic("inco violation: f not closed before return (at store.go:12)")
+}
+
+func send(ch chan int, timeout <-chan struct{}) {
+	_ = ch // @must(1ms)
+	select {
+//line incotest_test.go:39
+	case ch <- 1:
+	case <-timeout:
+//line incotest_test.go:39
+		panic("inco violation: ch <- 1 did not complete within 1ms (at incotest_test.go:39)")
+	}
+//line incotest_test.go:48
+}
+
+// recorder collects the errors ExpectViolation reports.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestExpectViolation(t *testing.T) {
+	v := ExpectViolation(t, func() { create("") }, Require, "name required")
+	if v == nil || v.Expr != "len(name) > 0" || !strings.HasSuffix(v.Path, "incotest_test.go") || v.Line != 13 {
+		t.Errorf("violation = %v", v)
+	}
+	v = ExpectViolation(t, func() { sum([]int{1}) }, Invariant, "index 1")
+	if v == nil || v.Expr != "i < len(xs)" {
+		t.Errorf("violation = %v", v)
+	}
+	v = ExpectViolation(t, closeLater, EnsureClosed, "")
+	if v == nil || v.Expr != "f" || v.Path != "store.go" || v.Line != 12 {
+		t.Errorf("violation = %v", v)
+	}
+	timeout := make(chan struct{})
+	close(timeout)
+	v = ExpectViolation(t, func() { send(make(chan int), timeout) }, Must, "did not complete within 1ms")
+	if v == nil || v.Expr != "1ms" || v.Line != 39 {
+		t.Errorf("violation = %v", v)
+	}
+	ExpectNoViolation(t, func() { create("ann") })
+}
+
+func TestExpectViolation_Failures(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func()
+		kind Kind
+		msg  string
+		want string
+	}{
+		{"no panic", func() { create("ann") }, Require, "", "returned normally"},
+		{"other panic", func() { panic("boom") }, Any, "", "panicked with boom, which is not a contract violation"},
+		{"wrong kind", func() { create("") }, Invariant, "", "expected a invariant violation, got require violation"},
+		{"wrong message", func() { create("") }, Any, "too long", `with message containing "too long"`},
+	}
+	for _, tt := range tests {
+		r := &recorder{TB: t}
+		if v := ExpectViolation(r, tt.fn, tt.kind, tt.msg); v != nil {
+			t.Errorf("%s: got violation %v", tt.name, v)
+		}
+		if len(r.errs) != 1 || !strings.Contains(r.errs[0], tt.want) {
+			t.Errorf("%s: errors = %q, want %q", tt.name, r.errs, tt.want)
+		}
+	}
+}
+
+type counter struct{ n int }
+
+func (c *counter) skip() {
+	// @ensure c.n == old(c.n)+1
+	{
+		_inco_old0 := c.n
+		defer func() {
+			if !(c.n == _inco_old0+1) {
+//line incotest_test.go:111
+				panic("inco violation: c.n == old(c.n)+1 (at incotest_test.go:111)")
+			}
+		}()
+	}
+//line incotest_test.go:112
+}
+
+func unpositioned() {
+	panic("inco violation: n > 0 (at lib.go:7)")
+}
+
+func TestExpectViolation_Ensure(t *testing.T) {
+	v := ExpectViolation(t, new(counter).skip, Ensure, "")
+	if v == nil || v.Expr != "c.n == old(c.n)+1" || !strings.HasSuffix(v.Path, "incotest_test.go") || v.Line != 111 {
+		t.Errorf("violation = %v", v)
+	}
+	// Only the message identifies this one: its kind is unknown.
+	v = ExpectViolation(t, unpositioned, Any, "")
+	if v == nil || v.Kind != Any || v.Expr != "n > 0" || v.Path != "lib.go" || v.Line != 7 {
+		t.Errorf("violation = %v", v)
+	}
+	if v != nil && v.String() != "contract violation of n > 0 at lib.go:7: inco violation: n > 0 (at lib.go:7)" {
+		t.Errorf("String() = %q", v.String())
+	}
+}
@@ -0,0 +1,221 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Review markers for uncovered functions
+// ---------------------------------------------------------------------------
+
+// UncoveredMarker is appended to the line opening the body of an exported
+// function without contracts by Annotate, and removed by Unannotate.
+const UncoveredMarker = "// inco:uncovered"
+
+// Mark is a function's position: a marker written or removed by Annotate
+// or Unannotate, or a function AuditResult.Unguarded lists.
+type Mark struct {
+	Path string // relative to root
+	Line int    // 1-based
+	Func string // e.g. "Open" or "(*Conn).Close"; "" when Unannotate finds no name on the line
+}
+
+func (m Mark) String() string {
+	_ = m // @inco: m.Func != "", -return(fmt.Sprintf("%s:%d", m.Path, m.Line))
+	if !(m.Func != "") {
+		return fmt.Sprintf("%s:%d", m.Path, m.Line)
+	}
+	return fmt.Sprintf("%s:%d: %s", m.Path, m.Line, m.Func)
+}
+
+// Annotate appends UncoveredMarker to the opening line of every exported
+// function or method (of an exported type) under root that declares no
+// contract, so reviewers see the worklist in the diff. Functions already
+// marked are left alone. It returns the markers added.
+func Annotate(root string) []Mark {
+	var marks []Mark
+	walkGoFiles(root, false, func(path string) error {
+		rel, _ := filepath.Rel(root, path)
+		src, err := os.ReadFile(path)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		joinContinuations(f, fset)
+
+		lines := splitLines(src)
+		changed := false
+		for _, fn := range uncoveredFuncs(f) {
+			line := srcLine(fset, fn.Body.Lbrace)
+			if strings.HasSuffix(lines[line-1], UncoveredMarker) {
+				continue
+			}
+			lines[line-1] += " " + UncoveredMarker
+			changed = true
+			marks = append(marks, Mark{Path: rel, Line: line, Func: auditName(fn)})
+		}
+		if changed {
+			writeLines(path, lines)
+		}
+		return nil
+	})
+	return marks
+}
+
+// Unannotate removes every UncoveredMarker written by Annotate under root
+// and returns the markers removed. Markers a reviewer has edited are kept.
+func Unannotate(root string) []Mark {
+	var marks []Mark
+	walkGoFiles(root, false, func(path string) error {
+		rel, _ := filepath.Rel(root, path)
+		src, err := os.ReadFile(path)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		lines := splitLines(src)
+		changed := false
+		for i, l := range lines {
+			trimmed, ok := strings.CutSuffix(l, " "+UncoveredMarker)
+			_ = ok // @inco: ok, -continue
+			if !(ok) {
+				continue
+			}
+			lines[i] = trimmed
+			changed = true
+			marks = append(marks, Mark{Path: rel, Line: i + 1, Func: funcName(trimmed)})
+		}
+		if changed {
+			writeLines(path, lines)
+		}
+		return nil
+	})
+	return marks
+}
+
+// uncoveredFuncs returns the exported function declarations of f, and the
+// exported methods of its exported types, whose bodies hold no directive
+// outside nested function literals.
+func uncoveredFuncs(f *ast.File) []*ast.FuncDecl {
+	var out []*ast.FuncDecl
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || !fn.Name.IsExported() {
+			continue
+		}
+		if fn.Recv != nil && !ast.IsExported(recvTypeName(fn.Recv.List[0].Type)) {
+			continue
+		}
+		if !hasOwnDirective(f, fn.Body) {
+			out = append(out, fn)
+		}
+	}
+	return out
+}
+
+// vetUncovered reports the exported functions of f without a contract
+// (see uncoveredFuncs), at their names.
+func vetUncovered(f *ast.File, fset *token.FileSet, path string) []Diagnostic {
+	var diags []Diagnostic
+	for _, fn := range uncoveredFuncs(f) {
+		pos := fset.PositionFor(fn.Name.Pos(), false)
+		diags = append(diags, Diagnostic{Path: path, Line: pos.Line, Column: pos.Column, Code: CodeUncovered,
+			Message: fmt.Sprintf("exported function %s has no contract", auditName(fn))})
+	}
+	return diags
+}
+
+// hasOwnDirective reports whether a directive comment lies in body but not
+// in a function literal nested in it, matching how inco audit attributes
+// directives to functions.
+func hasOwnDirective(f *ast.File, body *ast.BlockStmt) bool {
+	var lits []*ast.FuncLit
+	ast.Inspect(body, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.FuncLit); ok {
+			lits = append(lits, lit)
+		}
+		return true
+	})
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			if c.Pos() < body.Lbrace || c.Pos() > body.Rbrace || len(ParseDirectives(c.Text)) == 0 {
+				continue
+			}
+			nested := false
+			for _, lit := range lits {
+				nested = nested || (lit.Body.Lbrace <= c.Pos() && c.Pos() <= lit.Body.Rbrace)
+			}
+			if !nested {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// funcName returns the function name on a "func" declaration line, with
+// its receiver type, or "" when the line declares none.
+func funcName(line string) string {
+	fn, ok := strings.CutPrefix(strings.TrimSpace(line), "func ")
+	_ = ok // @inco: ok, -return("")
+	if !(ok) {
+		return ""
+	}
+	recv := ""
+	if strings.HasPrefix(fn, "(") {
+		end := strings.Index(fn, ")")
+		_ = end // @inco: end >= 0, -return("")
+		if !(end >= 0) {
+			return ""
+		}
+		if fields := strings.Fields(fn[1:end]); len(fields) > 0 {
+			typ := fields[len(fields)-1]
+			if i := strings.IndexByte(typ, '['); i >= 0 {
+				typ = typ[:i]
+			}
+			recv = typ + "."
+			if strings.HasPrefix(typ, "*") {
+				recv = "(" + typ + ")."
+			}
+		}
+		fn = strings.TrimSpace(fn[end+1:])
+	}
+	end := strings.IndexAny(fn, "([")
+	_ = end // @inco: end > 0, -return("")
+	if !(end > 0) {
+		return ""
+	}
+	return recv + fn[:end]
+}
+
+// writeLines writes lines, as splitLines returned them, back to path,
+// keeping its permissions, line endings and BOM.
+func writeLines(path string, lines []string) {
+	info, err := os.Stat(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	err = os.WriteFile(path, joinLines(lines, src), info.Mode().Perm())
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
@@ -0,0 +1,118 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Review markers for uncovered functions
+// ---------------------------------------------------------------------------
+
+const annotateSrc = `package main
+
+func Open(path string) {
+	// @inco: path != ""
+}
+
+func Close() {
+	go func() {
+		// @inco: true
+	}()
+}
+
+func (c *Conn) Read(
+	p []byte,
+) int {
+	return len(p)
+}
+
+func (c *conn) Write() {}
+
+func helper() {}
+`
+
+func TestAnnotate(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": annotateSrc})
+	path := filepath.Join(dir, "main.go")
+
+	marks := Annotate(dir)
+	var got []string
+	for _, m := range marks {
+		got = append(got, m.String())
+	}
+	if want := "main.go:7: Close|main.go:15: (*Conn).Read"; strings.Join(got, "|") != want {
+		t.Errorf("Annotate = %q, want %q", got, want)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "func Close() { // inco:uncovered\n") ||
+		!strings.Contains(string(data), ") int { // inco:uncovered\n") {
+		t.Errorf("marks not written:\n%s", data)
+	}
+	if again := Annotate(dir); len(again) != 0 {
+		t.Errorf("second Annotate = %v, want no new marks", again)
+	}
+
+	removed := Unannotate(dir)
+	if len(removed) != 2 || removed[0].String() != "main.go:7: Close" || removed[1].String() != "main.go:15" {
+		t.Errorf("Unannotate = %v", removed)
+	}
+	if data, _ := os.ReadFile(path); string(data) != annotateSrc {
+		t.Errorf("after Unannotate:\n%s", data)
+	}
+}
+
+func TestAnnotate_CRLF(t *testing.T) {
+	src := strings.ReplaceAll(annotateSrc, "\n", "\r\n")
+	dir := setupDir(t, map[string]string{"main.go": src})
+	path := filepath.Join(dir, "main.go")
+
+	if marks := Annotate(dir); len(marks) != 2 {
+		t.Fatalf("Annotate = %v, want 2 marks", marks)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "func Close() { // inco:uncovered\r\n") || strings.Contains(strings.ReplaceAll(string(data), "\r\n", ""), "\n") {
+		t.Errorf("marks not written before \\r\\n:\n%q", data)
+	}
+	if again := Annotate(dir); len(again) != 0 {
+		t.Errorf("second Annotate = %v, want no new marks", again)
+	}
+	if removed := Unannotate(dir); len(removed) != 2 {
+		t.Errorf("Unannotate = %v, want 2 marks", removed)
+	}
+	if data, _ := os.ReadFile(path); string(data) != src {
+		t.Errorf("after Unannotate:\n%q", data)
+	}
+}
+
+func TestVet_RequireContracts(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": annotateSrc})
+	var got []string
+	for _, d := range VetWith(dir, VetOptions{RequireContracts: true}) {
+		got = append(got, strings.TrimPrefix(d.String(), dir+"/"))
+	}
+	want := "main.go:7:6: exported function Close has no contract|main.go:13:16: exported function (*Conn).Read has no contract"
+	if strings.Join(got, "|") != want {
+		t.Errorf("VetWith(RequireContracts) = %q, want %q", got, want)
+	}
+	if diags := Vet(dir); len(diags) != 0 {
+		t.Errorf("Vet = %v, want none without RequireContracts", diags)
+	}
+}
+
+func TestFuncName(t *testing.T) {
+	tests := map[string]string{
+		"func Open(path string) {":         "Open",
+		"\tfunc (c *Conn) Close() error {": "(*Conn).Close",
+		"func (s Set[T]) Add(v T) {":       "Set.Add",
+		"func Map[T any](xs []T) []T {":    "Map",
+		") error {":                        "",
+	}
+	for line, want := range tests {
+		if got := funcName(line); got != want {
+			t.Errorf("funcName(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
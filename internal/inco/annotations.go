@@ -0,0 +1,144 @@
+package inco
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Annotation types
+// ---------------------------------------------------------------------------
+
+// Annotation describes one contract for editor hovers and inlay hints.
+type Annotation struct {
+	Kind        string   `json:"kind"` // "require", "invariant", "ensure", "ensure-closed", "recv-only" or "send-only"
+	Expr        string   `json:"expr"`
+	Cond        string   `json:"cond,omitempty"` // if(cond) guard
+	Tags        []string `json:"tags,omitempty"`
+	OnViolation string   `json:"on_violation"` // e.g. "panics", "returns `nil, err`"
+	Enabled     bool     `json:"enabled"`      // false when dropped by tag filters
+	Text        string   `json:"text"`         // one-line description
+}
+
+// Annotations is the content of annotations.json.
+type Annotations struct {
+	Root  string                  `json:"root"`        // absolute project root
+	Items map[string][]Annotation `json:"annotations"` // "relpath:line" → contracts in source order
+}
+
+// ---------------------------------------------------------------------------
+// Building annotations
+// ---------------------------------------------------------------------------
+
+// annotate describes the directives of the file at relPath, keyed by
+// "relpath:line" with slash-separated paths. Directives dropped by the tag
+// filters are included with Enabled false.
+func (e *Engine) annotate(relPath string, standalone, inline map[int][]*Directive) map[string][]Annotation {
+	items := make(map[string][]Annotation)
+	for _, m := range []map[int][]*Directive{standalone, inline} {
+		for line, ds := range m {
+			key := fmt.Sprintf("%s:%d", filepath.ToSlash(relPath), line)
+			for _, d := range ds {
+				items[key] = append(items[key], e.annotation(d))
+			}
+		}
+	}
+	return items
+}
+
+// annotation describes a single directive.
+func (e *Engine) annotation(d *Directive) Annotation {
+	a := Annotation{Expr: d.Expr, Cond: d.Cond, Tags: d.Tags, Enabled: e.tagEnabled(d)}
+	switch d.Kind {
+	case KindEnsureClosed:
+		a.Kind, a.OnViolation = "ensure-closed", "panics"
+		a.Text = fmt.Sprintf("Postcondition: %s must be closed before the function returns; otherwise panics.", d.Expr)
+	case KindEnsure:
+		a.Kind, a.OnViolation = "ensure", describeAction(d)
+		a.Text = fmt.Sprintf("Postcondition: %s holds when the function returns; otherwise %s.", d.Expr, a.OnViolation)
+		if d.Cond != "" {
+			a.Text = fmt.Sprintf("Postcondition: when %s, %s holds when the function returns; otherwise %s.", d.Cond, d.Expr, a.OnViolation)
+		}
+	case KindInvariant:
+		a.Kind, a.OnViolation = "invariant", describeAction(d)
+		a.Text = fmt.Sprintf("Loop invariant: %s holds at the start of every iteration; otherwise %s.", d.Expr, a.OnViolation)
+		if d.Cond != "" {
+			a.Text = fmt.Sprintf("Loop invariant: when %s, %s holds at the start of every iteration; otherwise %s.", d.Cond, d.Expr, a.OnViolation)
+		}
+	case KindMust:
+		a.Kind, a.OnViolation = "must", "panics"
+		a.Text = fmt.Sprintf("Deadline: the channel operation completes within %s in test builds; otherwise panics.", d.Expr)
+	case KindRecvOnly, KindSendOnly:
+		a.Kind, a.OnViolation = d.Kind.String(), "does not compile"
+		a.Text = fmt.Sprintf("Static check: %s must be a %s channel; otherwise the build fails.", d.Expr, chanDirection(d))
+	default:
+		a.Kind, a.OnViolation = "require", describeAction(d)
+		a.Text = fmt.Sprintf("Precondition: requires %s; otherwise %s.", d.Expr, a.OnViolation)
+		if d.Cond != "" {
+			a.Text = fmt.Sprintf("Precondition: when %s, requires %s; otherwise %s.", d.Cond, d.Expr, a.OnViolation)
+		}
+	}
+	if len(d.Tags) > 0 {
+		a.Text += " Tags: #" + strings.Join(d.Tags, ", #") + "."
+	}
+	if !a.Enabled {
+		a.Text += " Disabled by tag filters."
+	}
+	return a
+}
+
+// fileAnnotations returns the entries of items that belong to relPath.
+func fileAnnotations(items map[string][]Annotation, relPath string) map[string][]Annotation {
+	prefix := filepath.ToSlash(relPath) + ":"
+	out := make(map[string][]Annotation)
+	for key, as := range items {
+		if rest, ok := strings.CutPrefix(key, prefix); ok && !strings.Contains(rest, ":") {
+			out[key] = as
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// annotations.json I/O
+// ---------------------------------------------------------------------------
+
+func (e *Engine) annotationsPath() string {
+	return filepath.Join(e.CacheDir, "annotations.json")
+}
+
+// loadAnnotations returns the annotations of the previous run, or nil
+// when there are none.
+func (e *Engine) loadAnnotations() map[string][]Annotation {
+	data, err := os.ReadFile(e.annotationsPath())
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	var a Annotations
+	if json.Unmarshal(data, &a) != nil || a.Items == nil {
+		return nil
+	}
+	return a.Items
+}
+
+func (e *Engine) writeAnnotations(items map[string][]Annotation) {
+	err := os.MkdirAll(e.CacheDir, 0o755)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	data, err := json.MarshalIndent(Annotations{Root: e.Root, Items: items}, "", "  ")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	err = writeFileAtomic(e.annotationsPath(), data, 0o644)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
@@ -0,0 +1,99 @@
+package inco
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// annotations.json
+// ---------------------------------------------------------------------------
+
+// readAnnotations returns the annotations.json written by e.
+func readAnnotations(t *testing.T, e *Engine) Annotations {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(e.CacheDir, "annotations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a Annotations
+	if err := json.Unmarshal(data, &a); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestEngine_Annotations(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "os"
+
+func Open(path string, debug bool) (*os.File, error) {
+	// @inco: path != "", -return(nil, os.ErrInvalid)
+	// @inco: #slow if(debug) len(path) < 256
+	f, err := os.Open(path) // @inco: err == nil, -return(nil, err); @ensure -closed f
+	return f, f.Close()
+}
+`,
+		"sub/sub.go": `package sub
+
+func Do(x int) {
+	_ = x // @inco: x > 0
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.DisableTags = []string{"slow"}
+	e.Run()
+	a := readAnnotations(t, e)
+	if a.Root != dir {
+		t.Errorf("root = %q, want %q", a.Root, dir)
+	}
+	want := map[string][]Annotation{
+		"main.go:6": {{Kind: "require", Expr: `path != ""`, OnViolation: "returns `nil, os.ErrInvalid`", Enabled: true,
+			Text: "Precondition: requires path != \"\"; otherwise returns `nil, os.ErrInvalid`."}},
+		"main.go:7": {{Kind: "require", Expr: "len(path) < 256", Cond: "debug", Tags: []string{"slow"}, OnViolation: "panics",
+			Text: "Precondition: when debug, requires len(path) < 256; otherwise panics. Tags: #slow. Disabled by tag filters."}},
+		"main.go:8": {
+			{Kind: "require", Expr: "err == nil", OnViolation: "returns `nil, err`", Enabled: true,
+				Text: "Precondition: requires err == nil; otherwise returns `nil, err`."},
+			{Kind: "ensure-closed", Expr: "f", OnViolation: "panics", Enabled: true,
+				Text: "Postcondition: f must be closed before the function returns; otherwise panics."},
+		},
+		"sub/sub.go:4": {{Kind: "require", Expr: "x > 0", OnViolation: "panics", Enabled: true,
+			Text: "Precondition: requires x > 0; otherwise panics."}},
+	}
+	if !reflect.DeepEqual(a.Items, want) {
+		t.Errorf("annotations = %+v\nwant %+v", a.Items, want)
+	}
+
+	// Cached files keep their annotations.
+	e2 := NewEngine(dir)
+	e2.DisableTags = []string{"slow"}
+	e2.Run()
+	if got := readAnnotations(t, e2); !reflect.DeepEqual(got.Items, want) {
+		t.Errorf("annotations after cached run = %+v\nwant %+v", got.Items, want)
+	}
+}
+
+func TestEngine_AnnotationsMissingForcesRegeneration(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Do(x int) {
+	// @inco: x > 0
+}
+`})
+	NewEngine(dir).Run()
+	if err := os.Remove(filepath.Join(dir, ".inco_cache", "annotations.json")); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine(dir)
+	e.Run()
+	if got := readAnnotations(t, e).Items; len(got["main.go:4"]) != 1 {
+		t.Errorf("annotations after cache loss = %+v, want main.go:4", got)
+	}
+}
@@ -8,10 +8,16 @@ import (
 	"go/parser"
 	"go/token"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/imnive-design/inco-go/incolog"
 )
 
 // ---------------------------------------------------------------------------
@@ -20,22 +26,48 @@ import (
 
 // FuncAudit holds per-function audit data.
 type FuncAudit struct {
-	Name         string // function name (or "func literal" for closures)
+	Name         string // function name, (*T).Method or T.Method for methods, "func literal" for closures
 	Line         int    // 1-based line number of declaration
-	RequireCount int    // number of require directives in this function
+	RequireCount int    // number of directives (loop invariants included) in this function
 }
 
 // FileAudit holds per-file audit data.
 type FileAudit struct {
-	Path         string      // absolute path
-	RelPath      string      // relative to root
-	Funcs        []FuncAudit // declared functions
-	IfCount      int         // native if statements
-	RequireCount int         // @inco: directives
+	Path           string      // absolute path
+	RelPath        string      // relative to root
+	Funcs          []FuncAudit // declared functions
+	Lines          int         // source lines
+	BodilessFuncs  int         // functions declared without a body (assembly, //go:linkname), not in Funcs
+	IfCount        int         // native if statements
+	RequireCount   int         // @inco: directives
+	InvariantCount int         // @invariant directives
+	Warnings       []Warning   // contracts exceeding the size limits
+
+	PanicContracts int            // @inco: directives whose violation panics
+	MessageIssues  []MessageIssue // panic messages that are hard to diagnose
+
+	StateRules []StateRule // method contracts on the receiver
+	Endpoints  []Endpoint  // handlers declared in the file
+
+	UnguardedErrors []Mark // error assignments nothing checks (see unguardedErrors)
+
+	Vendor bool // in a vendor directory
+
+	panics []panicMessage // for the cross-file duplicate check
+
+	wiringDecls wiringDecls // for resolving wireups across the package
+	wireups     []Wireup    // constructors, before their field types are resolved
+
+	contracts []ContractSite // checks, for runtime reachability
 }
 
 // AuditResult is the aggregate report.
 type AuditResult struct {
+	SchemaVersion int // AuditSchemaVersion, for the JSON report
+
+	Module  string        // module path of the go.mod governing the root ("" = none)
+	Modules []ModuleAudit // the modules of the root's go.work, in workspace mode
+
 	Files           []FileAudit
 	IgnoredPaths    []string // files/dirs skipped by .incoignore
 	TotalFiles      int
@@ -43,16 +75,138 @@ type AuditResult struct {
 	GuardedFuncs    int // functions with >= 1 @inco: directive
 	TotalIfs        int
 	TotalRequires   int
+	TotalInvariants int
 	TotalDirectives int
+	TotalWarnings   int
+	TotalLines      int // source lines
+
+	Density  []DensityBucket  // functions by number of directives
+	Packages []PackageDensity // directives per package
+
+	TotalPanicContracts int // @inco: directives whose violation panics
+	TotalMessageIssues  int
+	ClearMessages       int // panic contracts without message issues
+
+	Receivers []ReceiverAudit // state rules grouped by receiver type
+
+	Endpoints          []Endpoint // handlers, by route
+	ValidatedEndpoints int        // endpoints with at least one contract
+
+	Wireups             []Wireup // constructors injecting pointer or interface dependencies
+	TotalDependencies   int      // fields of Wireups
+	CheckedDependencies int      // fields a constructor contract guards
+
+	Runtime            bool           // AuditOptions.Runtime was given
+	EvaluatedContracts int            // checks a recorded run evaluated
+	Unevaluated        []ContractSite // checks no recorded run evaluated
+
+	BodilessFuncs int // functions declared without a body, left out of TotalFuncs
+
+	VendorFiles   int  // files in vendor directories (AuditOptions.IncludeVendor)
+	VendorCounted bool // whether they count toward the coverage figures
+}
+
+// Diagnosability returns the percentage of panic contracts whose message
+// has no issue, or 100 when there are none.
+func (r *AuditResult) Diagnosability() float64 {
+	_ = r // @inco: r.TotalPanicContracts > 0, -return(100)
+	if !(r.TotalPanicContracts > 0) {
+		return 100
+	}
+	return float64(r.ClearMessages) / float64(r.TotalPanicContracts) * 100
+}
+
+// DirectivesPer100Lines returns the directives per 100 source lines.
+func (r *AuditResult) DirectivesPer100Lines() float64 {
+	return per(r.TotalDirectives*100, r.TotalLines)
+}
+
+// DirectivesPerFunc returns the directives per function.
+func (r *AuditResult) DirectivesPerFunc() float64 {
+	return per(r.TotalDirectives, r.TotalFuncs)
+}
+
+// Unguarded returns the functions without any directive, in file order,
+// function literals included unless AuditOptions.ExcludeClosures left
+// them out, and vendored functions only when they count toward coverage.
+func (r *AuditResult) Unguarded() []Mark {
+	var out []Mark
+	for _, f := range r.Files {
+		if f.Vendor && !r.VendorCounted {
+			continue
+		}
+		for _, fn := range f.Funcs {
+			if fn.RequireCount == 0 {
+				out = append(out, Mark{Path: f.RelPath, Line: fn.Line, Func: fn.Name})
+			}
+		}
+	}
+	return out
+}
+
+// UnguardedErrors returns the error assignments nothing checks, in file
+// order, leaving out vendored files unless they count toward coverage.
+func (r *AuditResult) UnguardedErrors() []Mark {
+	var out []Mark
+	for _, f := range r.Files {
+		if f.Vendor && !r.VendorCounted {
+			continue
+		}
+		out = append(out, f.UnguardedErrors...)
+	}
+	return out
 }
 
 // ---------------------------------------------------------------------------
 // Audit entry point
 // ---------------------------------------------------------------------------
 
+// AuditOptions configures AuditWith.
+type AuditOptions struct {
+	Limits   Limits
+	Progress io.Writer // terminal to draw a progress line on (see Progress); nil = none
+
+	// IncludeVendor audits vendored packages too. Their files are listed
+	// and checked like the others but left out of the coverage figures
+	// unless VendorCoverage is set.
+	IncludeVendor  bool
+	VendorCoverage bool
+
+	// ExcludeClosures leaves function literals out of the coverage
+	// figures and the unguarded functions: their contracts count for the
+	// function declaring them. By default a closure is a function of its
+	// own, guarded by its own contracts.
+	ExcludeClosures bool
+
+	// Match and ExcludeFunc scope the coverage figures and the unguarded
+	// functions to a naming convention: only the functions whose name
+	// Match matches, and none whose name ExcludeFunc matches, count. A
+	// name matches when the pattern matches either the bare function or
+	// method name (Get) or the name the report shows ((*Repository).Get);
+	// a function literal goes with the function declaring it. nil = all.
+	Match       *regexp.Regexp
+	ExcludeFunc *regexp.Regexp
+
+	// Handlers lists glob patterns of functions to report as endpoints
+	// besides those declared with @handler, e.g. "*Handler" or
+	// "Server.Handle*".
+	Handlers []string
+
+	// Runtime lists the contracts evaluated by runs with -count-hits (see
+	// incolog.ReadHits); the report then lists the checks never evaluated,
+	// which guard code no run reached. nil = no runtime data.
+	Runtime map[incolog.Site]bool
+}
+
 // Audit scans all Go source files under root and produces an AuditResult
-// summarising @inco: coverage and directive-vs-if ratios.
-func Audit(root string) *AuditResult {
+// summarising @inco: coverage and directive-vs-if ratios, and the
+// contracts that exceed limits.
+func Audit(root string, limits Limits) *AuditResult {
+	return AuditWith(root, AuditOptions{Limits: limits})
+}
+
+// AuditWith is Audit with options.
+func AuditWith(root string, opts AuditOptions) *AuditResult {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:54
 	if !(root != "") {
 		panic("Audit: root must not be empty")
@@ -69,21 +223,56 @@ func Audit(root string) *AuditResult {
 	var files []FileAudit
 	var ignored []string
 
-	walkGoFiles(absRoot, func(path string) error {
-		fa := auditFile(fset, absRoot, path)
+	var total int
+	if opts.Progress != nil {
+		total = len(collectGoFiles(absRoot, opts.IncludeVendor))
+	}
+	p := StartProgress(opts.Progress, "audit", absRoot, total)
+	defer p.Stop()
+	walkGoFiles(absRoot, opts.IncludeVendor, func(path string) error {
+		fa := auditFile(fset, absRoot, path, opts)
 		files = append(files, fa)
+		p.Step(path)
 		return nil
 	})
 
 	// Collect ignored paths by walking all .go files and checking .incoignore.
-	collectIgnored(absRoot, &ignored)
+	collectIgnored(absRoot, opts.IncludeVendor, &ignored)
 
 	sort.Slice(files, func(i, j int) bool { return files[i].RelPath < files[j].RelPath })
 
-	r := &AuditResult{Files: files, IgnoredPaths: ignored, TotalFiles: len(files)}
+	// Panic messages reused across many contracts.
+	panics := make(map[string][]panicMessage, len(files))
 	for _, f := range files {
+		panics[f.RelPath] = f.panics
+	}
+	for _, issue := range duplicateMessageIssues(panics) {
+		i := sort.Search(len(files), func(i int) bool { return files[i].RelPath >= issue.Path })
+		files[i].MessageIssues = append(files[i].MessageIssues, issue)
+	}
+
+	r := &AuditResult{SchemaVersion: AuditSchemaVersion, Files: files, IgnoredPaths: ignored, TotalFiles: len(files), VendorCounted: opts.VendorCoverage}
+	for _, f := range files {
+		r.TotalWarnings += len(f.Warnings)
+		sortMessageIssues(f.MessageIssues)
+		r.TotalPanicContracts += f.PanicContracts
+		r.TotalMessageIssues += len(f.MessageIssues)
+		flagged := make(map[string]bool)
+		for _, issue := range f.MessageIssues {
+			flagged[fmt.Sprintf("%d:%s", issue.Line, issue.Expr)] = true
+		}
+		r.ClearMessages += f.PanicContracts - len(flagged)
+		if f.Vendor {
+			r.VendorFiles++
+			if !r.VendorCounted {
+				continue
+			}
+		}
 		r.TotalIfs += f.IfCount
+		r.TotalLines += f.Lines
 		r.TotalRequires += f.RequireCount
+		r.TotalInvariants += f.InvariantCount
+		r.BodilessFuncs += f.BodilessFuncs
 		for _, fn := range f.Funcs {
 			r.TotalFuncs++
 			if fn.RequireCount > 0 {
@@ -91,7 +280,31 @@ func Audit(root string) *AuditResult {
 			}
 		}
 	}
-	r.TotalDirectives = r.TotalRequires
+	r.TotalDirectives = r.TotalRequires + r.TotalInvariants
+	counted := files
+	if !r.VendorCounted {
+		counted = slices.DeleteFunc(slices.Clone(files), func(f FileAudit) bool { return f.Vendor })
+	}
+	r.Density = densityHistogram(counted)
+	r.Packages = packageDensities(counted)
+	r.Module = modulePath(absRoot)
+	r.Modules = workspaceModules(absRoot, counted)
+	r.Receivers = receiverAudits(files)
+	r.Endpoints = collectEndpoints(files)
+	for _, ep := range r.Endpoints {
+		if ep.Validated() {
+			r.ValidatedEndpoints++
+		}
+	}
+	if opts.Runtime != nil {
+		r.Runtime = true
+		r.EvaluatedContracts, r.Unevaluated = reachability(files, opts.Runtime)
+	}
+	r.Wireups = wireups(files)
+	for _, w := range r.Wireups {
+		r.TotalDependencies += len(w.Fields)
+		r.CheckedDependencies += len(w.Fields) - len(w.Unchecked())
+	}
 	return r
 }
 
@@ -102,7 +315,7 @@ func Audit(root string) *AuditResult {
 // collectIgnored walks root and appends relative paths of files/dirs
 // that are skipped by .incoignore (but not by skipDirRe, which covers
 // hidden dirs, vendor, testdata — those are always skipped).
-func collectIgnored(root string, out *[]string) {
+func collectIgnored(root string, vendor bool, out *[]string) {
 	ig := NewIgnoreTree(root)
 	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:98
@@ -112,7 +325,7 @@ func collectIgnored(root string, out *[]string) {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:99
 		if d.IsDir() {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:100
-			if !(!skipDirRe.MatchString(d.Name())) {
+			if !(!skipDirRe.MatchString(d.Name()) || (vendor && d.Name() == "vendor")) {
 				return filepath.SkipDir
 			}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:101
@@ -139,12 +352,18 @@ func collectIgnored(root string, out *[]string) {
 	sort.Strings(*out)
 }
 
-func auditFile(fset *token.FileSet, root, path string) FileAudit {
+func auditFile(fset *token.FileSet, root, path string, opts AuditOptions) FileAudit {
 	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
+	joinContinuations(f, fset)
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:123
 
 	relPath := path
@@ -152,7 +371,20 @@ func auditFile(fset *token.FileSet, root, path string) FileAudit {
 		relPath = rel
 	}
 
-	fa := FileAudit{Path: path, RelPath: relPath}
+	fa := FileAudit{Path: path, RelPath: relPath, Lines: countLines(src), Vendor: inVendor(relPath)}
+
+	// 0. Check contract sizes against limits, as inco gen does.
+	standalone, inline := collectDirectives(f, fset, splitLines(src))
+	maps.Copy(standalone, inline)
+	fa.Warnings = opts.Limits.check(f, fset, relPath, standalone)
+	fa.panics = panicMessages(f, fset, standalone)
+	fa.PanicContracts = len(fa.panics)
+	fa.MessageIssues = messageIssues(relPath, fa.panics)
+	fa.StateRules = stateRules(f, fset, standalone)
+	fa.Endpoints = endpoints(f, fset, relPath, standalone, opts.Handlers)
+	fa.wiringDecls, fa.wireups = wiringFacts(f, fset, relPath, standalone)
+	fa.contracts = contractSites(relPath, standalone)
+	fa.UnguardedErrors = unguardedErrors(f, fset, relPath, standalone)
 
 	// 1. Parse directives from comments.
 	type directiveInfo struct {
@@ -162,14 +394,14 @@ func auditFile(fset *token.FileSet, root, path string) FileAudit {
 
 	for _, cg := range f.Comments {
 		for _, c := range cg.List {
-			d := ParseDirective(c.Text)
-			_ = d // @inco: d != nil, -continue
-			if !(d != nil) {
-				continue
+			for _, d := range ParseDirectives(c.Text) {
+				if d.Kind == KindInvariant {
+					fa.InvariantCount++
+				} else {
+					fa.RequireCount++
+				}
+				directives = append(directives, directiveInfo{pos: c.Pos()})
 			}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/audit.inco.go:141
-			fa.RequireCount++
-			directives = append(directives, directiveInfo{pos: c.Pos()})
 		}
 	}
 
@@ -187,31 +419,40 @@ func auditFile(fset *token.FileSet, root, path string) FileAudit {
 		line  int
 		start token.Pos
 		end   token.Pos
+		decl  *ast.FuncDecl // the declared function, or the one declaring a literal
 	}
 	var funcRanges []funcRange
 
+	var decl *ast.FuncDecl // the function declaration being inspected
 	ast.Inspect(f, func(n ast.Node) bool {
 		switch fn := n.(type) {
 		case *ast.FuncDecl:
-			if fn.Body != nil {
-				name := fn.Name.Name
-				if fn.Recv != nil && len(fn.Recv.List) > 0 {
-					name = recvTypeName(fn.Recv.List[0].Type) + "." + name
-				}
+			decl = fn
+			if fn.Body == nil {
+				// Implemented in assembly or linked by name: there is
+				// nothing to guard.
+				fa.BodilessFuncs++
+			} else {
 				funcRanges = append(funcRanges, funcRange{
-					name:  name,
+					name:  auditName(fn),
 					line:  fset.Position(fn.Pos()).Line,
 					start: fn.Body.Pos(),
 					end:   fn.Body.End(),
+					decl:  fn,
 				})
 			}
+		case *ast.GenDecl:
+			if decl != nil && fn.Pos() > decl.End() {
+				decl = nil // literals in package-level declarations
+			}
 		case *ast.FuncLit:
-			if fn.Body != nil {
+			if fn.Body != nil && !opts.ExcludeClosures {
 				funcRanges = append(funcRanges, funcRange{
 					name:  "func literal",
 					line:  fset.Position(fn.Pos()).Line,
 					start: fn.Body.Pos(),
 					end:   fn.Body.End(),
+					decl:  decl,
 				})
 			}
 		}
@@ -236,6 +477,9 @@ func auditFile(fset *token.FileSet, root, path string) FileAudit {
 	}
 
 	for i, fr := range funcRanges {
+		if !opts.selects(fr.decl) {
+			continue
+		}
 		fa.Funcs = append(fa.Funcs, FuncAudit{
 			Name:         fr.name,
 			Line:         fr.line,
@@ -246,6 +490,36 @@ func auditFile(fset *token.FileSet, root, path string) FileAudit {
 	return fa
 }
 
+// selects reports whether the functions declared by fn, nil for a
+// package-level declaration, count toward coverage under Match and
+// ExcludeFunc.
+func (opts AuditOptions) selects(fn *ast.FuncDecl) bool {
+	matches := func(re *regexp.Regexp) bool {
+		return fn != nil && (re.MatchString(fn.Name.Name) || re.MatchString(auditName(fn)))
+	}
+	_ = opts // @inco: opts.Match == nil || matches(opts.Match), -return(false)
+	if !(opts.Match == nil || matches(opts.Match)) {
+		return false
+	}
+	return opts.ExcludeFunc == nil || !matches(opts.ExcludeFunc)
+}
+
+// auditName returns the name the audit reports fn under: the function
+// name, or for a method the method expression naming it, (*Repository).Get
+// or Repository.Get, so methods of the same name on different types, or
+// on a type and a pointer to it, tell apart.
+func auditName(fn *ast.FuncDecl) string {
+	_ = fn // @inco: fn.Recv != nil && len(fn.Recv.List) > 0, -return(fn.Name.Name)
+	if !(fn.Recv != nil && len(fn.Recv.List) > 0) {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if _, ok := recv.(*ast.StarExpr); ok {
+		return "(*" + recvTypeName(recv) + ")." + fn.Name.Name
+	}
+	return recvTypeName(recv) + "." + fn.Name.Name
+}
+
 // recvTypeName extracts the type name from a method receiver expression.
 func recvTypeName(expr ast.Expr) string {
 	switch t := expr.(type) {
@@ -267,17 +541,37 @@ func recvTypeName(expr ast.Expr) string {
 
 // PrintReport writes a human-readable audit report to w.
 func (r *AuditResult) PrintReport(w io.Writer) {
-	fmt.Fprintf(w, "inco audit — contract coverage report\n")
+	r.printReport(w, false)
+}
+
+// printReport writes the report of PrintReport, colored by c: headings in
+// bold, percentages by how far they are from the goal, and warnings and
+// message issues as warnings.
+func (r *AuditResult) printReport(w io.Writer, c Colorizer) {
+	fmt.Fprintf(w, "%s\n", c.Bold("inco audit — contract coverage report"))
 	fmt.Fprintf(w, "======================================\n\n")
 
+	if r.Module != "" {
+		fmt.Fprintf(w, "  Module:         %s\n", r.Module)
+	}
 	fmt.Fprintf(w, "  Files scanned:  %d\n", r.TotalFiles)
-	fmt.Fprintf(w, "  Functions:      %d\n\n", r.TotalFuncs)
+	if r.VendorFiles > 0 && !r.VendorCounted {
+		fmt.Fprintf(w, "  Vendored files: %d  (not counted in coverage)\n", r.VendorFiles)
+	}
+	fmt.Fprintf(w, "  Functions:      %d\n", r.TotalFuncs)
+	if r.BodilessFuncs > 0 {
+		fmt.Fprintf(w, "  Without body:   %d  (assembly or linkname, not counted)\n", r.BodilessFuncs)
+	}
+	fmt.Fprintln(w)
+	if len(r.Modules) > 0 {
+		printModules(w, r.Modules, c)
+	}
 
 	// --- @inco: coverage ---
-	fmt.Fprintf(w, "@inco: coverage:\n")
+	fmt.Fprintf(w, "%s\n", c.Bold("@inco: coverage:"))
 	if r.TotalFuncs > 0 {
 		pct := float64(r.GuardedFuncs) / float64(r.TotalFuncs) * 100
-		fmt.Fprintf(w, "  With @inco::     %d / %d  (%.1f%%)\n", r.GuardedFuncs, r.TotalFuncs, pct)
+		fmt.Fprintf(w, "  With @inco::     %d / %d  (%s)\n", r.GuardedFuncs, r.TotalFuncs, c.Percent(pct, 75, 50))
 		fmt.Fprintf(w, "  Without @inco::  %d / %d  (%.1f%%)\n\n",
 			r.TotalFuncs-r.GuardedFuncs, r.TotalFuncs, 100-pct)
 	} else {
@@ -285,27 +579,30 @@ func (r *AuditResult) PrintReport(w io.Writer) {
 	}
 
 	// --- Directive vs if ---
-	fmt.Fprintf(w, "Directive vs if:\n")
+	fmt.Fprintf(w, "%s\n", c.Bold("Directive vs if:"))
 	fmt.Fprintf(w, "  @inco::           %d\n", r.TotalRequires)
+	if r.TotalInvariants > 0 {
+		fmt.Fprintf(w, "  @invariant:         %d\n", r.TotalInvariants)
+	}
 	fmt.Fprintf(w, "  ─────────────────────\n")
 	fmt.Fprintf(w, "  Total directives:   %d\n", r.TotalDirectives)
 	fmt.Fprintf(w, "  Native if stmts:    %d\n", r.TotalIfs)
 	total := r.TotalDirectives + r.TotalIfs
 	if total > 0 {
 		ratio := float64(r.TotalDirectives) / float64(total) * 100
-		fmt.Fprintf(w, "  inco/(if+inco):     %.1f%%\n\n", ratio)
+		fmt.Fprintf(w, "  inco/(if+inco):     %s\n\n", c.Percent(ratio, 50, 25))
 	} else {
 		fmt.Fprintf(w, "  inco/(if+inco):     — (no directives or if statements)\n\n")
 	}
 
+	printDensity(w, r, c)
+
 	// --- Per-file breakdown ---
-	fmt.Fprintf(w, "Per-file breakdown:\n")
+	fmt.Fprintf(w, "%s\n", c.Bold("Per-file breakdown:"))
 	// Calculate column widths.
 	maxPath := 4 // "File"
 	for _, f := range r.Files {
-		if len(f.RelPath) > maxPath {
-			maxPath = len(f.RelPath)
-		}
+		maxPath = max(maxPath, utf8.RuneCountInString(f.RelPath))
 	}
 	if maxPath > 50 {
 		maxPath = 50
@@ -321,8 +618,8 @@ func (r *AuditResult) PrintReport(w io.Writer) {
 			}
 		}
 		display := f.RelPath
-		if len(display) > maxPath {
-			display = "…" + display[len(display)-maxPath+1:]
+		if r := []rune(display); len(r) > maxPath {
+			display = "…" + string(r[len(r)-maxPath+1:])
 		}
 		fmt.Fprintf(w, "  %-*s  %7d  %2d  %5d  %7d\n",
 			maxPath, display, f.RequireCount,
@@ -330,24 +627,58 @@ func (r *AuditResult) PrintReport(w io.Writer) {
 	}
 
 	// --- Unguarded functions ---
-	var unguarded []string
-	for _, f := range r.Files {
-		for _, fn := range f.Funcs {
-			if fn.RequireCount == 0 && fn.Name != "func literal" {
-				unguarded = append(unguarded, fmt.Sprintf("  %s:%d  %s", f.RelPath, fn.Line, fn.Name))
+	if unguarded := r.Unguarded(); len(unguarded) > 0 {
+		fmt.Fprintf(w, "\n%s\n", c.Bold(fmt.Sprintf("Functions without @inco: (%d):", len(unguarded))))
+		for _, m := range unguarded {
+			fmt.Fprintf(w, "  %s:%d  %s\n", m.Path, m.Line, m.Func)
+		}
+	}
+
+	// --- Contract size warnings ---
+	if r.TotalWarnings > 0 {
+		fmt.Fprintf(w, "\n%s\n", c.Bold(fmt.Sprintf("Contract size warnings (%d):", r.TotalWarnings)))
+		for _, f := range r.Files {
+			for _, warn := range f.Warnings {
+				fmt.Fprintf(w, "  %s\n", c.Warning(warn.String()))
 			}
 		}
 	}
-	if len(unguarded) > 0 {
-		fmt.Fprintf(w, "\nFunctions without @inco: (%d):\n", len(unguarded))
-		for _, s := range unguarded {
-			fmt.Fprintln(w, s)
+
+	// --- Panic message quality ---
+	if r.TotalPanicContracts > 0 {
+		fmt.Fprintf(w, "\n%s\n", c.Bold("Panic message quality:"))
+		fmt.Fprintf(w, "  Panic contracts:  %d\n", r.TotalPanicContracts)
+		fmt.Fprintf(w, "  Diagnosability:   %s  (%d with clear messages)\n", c.Percent(r.Diagnosability(), 90, 70), r.ClearMessages)
+		for _, f := range r.Files {
+			for _, issue := range f.MessageIssues {
+				fmt.Fprintf(w, "  %s\n", c.Warning(issue.String()))
+			}
 		}
 	}
 
+	// --- Contracts by receiver type ---
+	if len(r.Receivers) > 0 {
+		printReceivers(w, r.Receivers, c)
+	}
+
+	// --- Contracts by endpoint ---
+	if len(r.Endpoints) > 0 {
+		printEndpoints(w, r.Endpoints, r.ValidatedEndpoints, c)
+	}
+
+	// --- Wire-up safety ---
+	if r.TotalDependencies > 0 {
+		printWireups(w, r.Wireups, r.CheckedDependencies, r.TotalDependencies, c)
+	}
+
+	// --- Runtime reachability ---
+	if r.Runtime && r.EvaluatedContracts+len(r.Unevaluated) > 0 {
+		printReachability(w, r.EvaluatedContracts, r.Unevaluated, c)
+	}
+
 	// --- Ignored paths ---
 	if len(r.IgnoredPaths) > 0 {
-		fmt.Fprintf(w, "\nIgnored by .incoignore (%d):\n", len(r.IgnoredPaths))
+		fmt.Fprintf(w, "\n%s\n", c.Bold(fmt.Sprintf("Ignored by .incoignore (%d):", len(r.IgnoredPaths))))
 		for _, p := range r.IgnoredPaths {
 			fmt.Fprintf(w, "  %s\n", p)
 		}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -48,7 +49,7 @@ type DB struct{}
 func (db *DB) Query(q string) (string, error) { return "", nil }
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalFiles != 1 {
 		t.Errorf("TotalFiles = %d, want 1", result.TotalFiles)
@@ -96,7 +97,7 @@ func C(z int) {
 }
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalFiles != 2 {
 		t.Errorf("TotalFiles = %d, want 2", result.TotalFiles)
@@ -145,7 +146,7 @@ func Y(b int) {
 }
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalFiles != 1 {
 		t.Errorf("TotalFiles = %d, want 1", result.TotalFiles)
@@ -173,7 +174,7 @@ func Outer() {
 }
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalFuncs != 2 { // Outer + func literal
 		t.Errorf("TotalFuncs = %d, want 2", result.TotalFuncs)
@@ -183,6 +184,121 @@ func Outer() {
 	}
 }
 
+func TestAudit_UnguardedClosure(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.go"), `package main
+
+func Outer(xs []int) int {
+	// @inco: len(xs) > 0
+	sum := func() int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	}
+	return sum()
+}
+`)
+
+	result := Audit(dir, Limits{})
+
+	unguarded := result.Unguarded()
+	if len(unguarded) != 1 || unguarded[0].Func != "func literal" || unguarded[0].Line != 5 {
+		t.Errorf("Unguarded() = %+v, want the func literal on line 5", unguarded)
+	}
+	if result.TotalFuncs != 2 || result.GuardedFuncs != 1 {
+		t.Errorf("TotalFuncs, GuardedFuncs = %d, %d, want 2, 1", result.TotalFuncs, result.GuardedFuncs)
+	}
+}
+
+func TestAudit_ExcludeClosures(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.go"), `package main
+
+func Outer() {
+	inner := func(x int) {
+		// @inco: x > 0
+	}
+	_ = inner
+}
+
+func Plain() {
+	defer func() {}()
+}
+`)
+
+	result := AuditWith(dir, AuditOptions{ExcludeClosures: true})
+
+	if result.TotalFuncs != 2 || result.GuardedFuncs != 1 {
+		t.Errorf("TotalFuncs, GuardedFuncs = %d, %d, want 2, 1", result.TotalFuncs, result.GuardedFuncs)
+	}
+	unguarded := result.Unguarded()
+	if len(unguarded) != 1 || unguarded[0].Func != "Plain" {
+		t.Errorf("Unguarded() = %+v, want only Plain", unguarded)
+	}
+}
+
+func TestAudit_MatchFuncs(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.go"), `package main
+
+type Server struct{}
+
+func (s *Server) HandleGet(id string) {
+	// @inco: id != ""
+}
+
+func (s *Server) HandleDelete(id string) {
+	check := func() {
+		// @inco: id != ""
+	}
+	check()
+}
+
+func (s *Server) helper() {}
+
+func ServeMetrics() {}
+
+func NewServer() *Server {
+	var validate = func() {}
+	validate()
+	return &Server{}
+}
+
+var hook = func() {}
+`)
+
+	names := func(r *AuditResult) string {
+		var got []string
+		for _, fn := range r.Files[0].Funcs {
+			got = append(got, fn.Name)
+		}
+		return strings.Join(got, ",")
+	}
+
+	r := AuditWith(dir, AuditOptions{Match: regexp.MustCompile(`^(Handle|Serve)`)})
+	if got, want := names(r), "(*Server).HandleGet,(*Server).HandleDelete,func literal,ServeMetrics"; got != want {
+		t.Errorf("-match funcs = %s, want %s", got, want)
+	}
+	if r.TotalFuncs != 4 || r.GuardedFuncs != 2 {
+		t.Errorf("TotalFuncs, GuardedFuncs = %d, %d, want 4, 2", r.TotalFuncs, r.GuardedFuncs)
+	}
+
+	r = AuditWith(dir, AuditOptions{Match: regexp.MustCompile(`^\(\*Server\)`), ExcludeFunc: regexp.MustCompile(`Delete|^helper$`)})
+	if got, want := names(r), "(*Server).HandleGet"; got != want {
+		t.Errorf("-match -exclude-func funcs = %s, want %s", got, want)
+	}
+
+	r = AuditWith(dir, AuditOptions{ExcludeFunc: regexp.MustCompile(`^Handle`)})
+	if got, want := names(r), "(*Server).helper,ServeMetrics,NewServer,func literal,func literal"; got != want {
+		t.Errorf("-exclude-func funcs = %s, want %s", got, want)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Empty project
 // ---------------------------------------------------------------------------
@@ -195,7 +311,7 @@ func TestAudit_EmptyProject(t *testing.T) {
 func main() {}
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalFuncs != 1 {
 		t.Errorf("TotalFuncs = %d, want 1", result.TotalFuncs)
@@ -224,7 +340,7 @@ func (s *Svc) Do(x int) {
 }
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalFuncs != 1 {
 		t.Errorf("TotalFuncs = %d, want 1", result.TotalFuncs)
@@ -235,8 +351,8 @@ func (s *Svc) Do(x int) {
 	if len(result.Files) != 1 || len(result.Files[0].Funcs) != 1 {
 		t.Fatal("unexpected file/func count")
 	}
-	if result.Files[0].Funcs[0].Name != "Svc.Do" {
-		t.Errorf("func name = %q, want %q", result.Files[0].Funcs[0].Name, "Svc.Do")
+	if result.Files[0].Funcs[0].Name != "(*Svc).Do" {
+		t.Errorf("func name = %q, want %q", result.Files[0].Funcs[0].Name, "(*Svc).Do")
 	}
 }
 
@@ -303,7 +419,7 @@ func Check(x int) {
 }
 `)
 
-	result := Audit(dir)
+	result := Audit(dir, Limits{})
 
 	if result.TotalRequires != 1 {
 		t.Errorf("TotalRequires = %d, want 1", result.TotalRequires)
@@ -312,3 +428,53 @@ func Check(x int) {
 		t.Errorf("TotalDirectives = %d, want 1", result.TotalDirectives)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Vendored packages
+// ---------------------------------------------------------------------------
+
+func TestAudit_IncludeVendor(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), `package main
+
+func X(a int) {
+	// @inco: a > 0
+}
+`)
+	writeFile(t, filepath.Join(dir, "vendor", "v", "v.go"), `package v
+
+func V(b int) {
+	if b < 0 {
+		panic("b")
+	}
+}
+`)
+
+	if r := Audit(dir, Limits{}); r.TotalFiles != 1 || r.VendorFiles != 0 {
+		t.Errorf("default: TotalFiles = %d, VendorFiles = %d, want 1, 0", r.TotalFiles, r.VendorFiles)
+	}
+
+	r := AuditWith(dir, AuditOptions{IncludeVendor: true})
+	if r.TotalFiles != 2 || r.VendorFiles != 1 {
+		t.Errorf("TotalFiles = %d, VendorFiles = %d, want 2, 1", r.TotalFiles, r.VendorFiles)
+	}
+	if r.TotalFuncs != 1 || r.GuardedFuncs != 1 || r.TotalIfs != 0 {
+		t.Errorf("vendor counted in coverage: funcs %d, guarded %d, ifs %d", r.TotalFuncs, r.GuardedFuncs, r.TotalIfs)
+	}
+	if u := r.Unguarded(); len(u) != 0 {
+		t.Errorf("Unguarded = %v, want none", u)
+	}
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	if !strings.Contains(buf.String(), "Vendored files: 1") {
+		t.Errorf("report does not mention vendored files:\n%s", buf.String())
+	}
+
+	r = AuditWith(dir, AuditOptions{IncludeVendor: true, VendorCoverage: true})
+	if r.TotalFuncs != 2 || r.GuardedFuncs != 1 || r.TotalIfs != 1 {
+		t.Errorf("-vendor-coverage: funcs %d, guarded %d, ifs %d", r.TotalFuncs, r.GuardedFuncs, r.TotalIfs)
+	}
+	if u := r.Unguarded(); len(u) != 1 || u[0].Func != "V" {
+		t.Errorf("Unguarded = %v, want V", u)
+	}
+}
@@ -0,0 +1,19 @@
+package inco
+
+import _ "embed"
+
+// ---------------------------------------------------------------------------
+// Audit report schema
+// ---------------------------------------------------------------------------
+
+// AuditSchemaVersion is the version of AuditSchema the JSON report of an
+// AuditResult follows, recorded in its SchemaVersion. It changes when a
+// property is removed or changes meaning; adding one keeps it, so
+// consumers of the report must ignore properties they do not know.
+const AuditSchemaVersion = 1
+
+// AuditSchema is the JSON Schema of the report JSONRenderer writes and
+// inco serve's audit method returns, as inco audit -schema prints it.
+//
+//go:embed audit.schema.json
+var AuditSchema []byte
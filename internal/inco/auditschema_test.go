@@ -0,0 +1,206 @@
+package inco
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// jsonSchema is the part of JSON Schema audit.schema.json uses.
+type jsonSchema struct {
+	Type       any                    `json:"type"` // a name or a list of names
+	Const      any                    `json:"const"`
+	Ref        string                 `json:"$ref"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Defs       map[string]*jsonSchema `json:"$defs"`
+}
+
+func loadAuditSchema(t *testing.T) *jsonSchema {
+	t.Helper()
+	var s jsonSchema
+	if err := json.Unmarshal(AuditSchema, &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+// types returns the type names s allows.
+func (s *jsonSchema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var names []string
+		for _, n := range t {
+			names = append(names, n.(string))
+		}
+		return names
+	}
+	return nil
+}
+
+// validate returns the places where v, decoded JSON, does not follow s.
+func (root *jsonSchema) validate(s *jsonSchema, v any, at string) []string {
+	if s.Ref != "" {
+		return root.validate(root.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")], v, at)
+	}
+	if s.Const != nil && !reflect.DeepEqual(s.Const, v) {
+		return []string{fmt.Sprintf("%s: %v, want %v", at, v, s.Const)}
+	}
+	var kind string
+	switch v := v.(type) {
+	case nil:
+		kind = "null"
+	case bool:
+		kind = "boolean"
+	case float64:
+		kind = "number"
+		if v == float64(int64(v)) {
+			kind = "integer"
+		}
+	case string:
+		kind = "string"
+	case []any:
+		kind = "array"
+	case map[string]any:
+		kind = "object"
+	}
+	if !slices.Contains(s.types(), kind) && !(kind == "integer" && slices.Contains(s.types(), "number")) {
+		return []string{fmt.Sprintf("%s: %s, want %v", at, kind, s.Type)}
+	}
+	var problems []string
+	switch v := v.(type) {
+	case []any:
+		for i, item := range v {
+			problems = append(problems, root.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing %s", at, name))
+			}
+		}
+		for name, p := range s.Properties {
+			if pv, ok := v[name]; ok {
+				problems = append(problems, root.validate(p, pv, at+"."+name)...)
+			}
+		}
+	}
+	return problems
+}
+
+func TestAuditSchema_ValidatesReport(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"main.go": `package main
+
+import "net/http"
+
+type Conn struct{ open bool }
+
+// Close closes c.
+func (c *Conn) Close() {
+	// @inco: c.open, -panic("x")
+	c.open = false
+}
+
+type Server struct{ db *Conn }
+
+func NewServer(db *Conn) *Server {
+	return &Server{db: db}
+}
+
+// @handler GET /users
+func (s *Server) Users(w http.ResponseWriter, r *http.Request) {
+	_ = r // @inco: if(r.Method != "HEAD") r.Method == "GET", -return
+	for i := 0; i < 3; i++ {
+		// @invariant i >= 0
+	}
+	err := s.load()
+	err = s.load()
+	_ = err
+}
+
+func (s *Server) load() error { return nil }
+
+func Big(a, b, c, d, e, f int) {
+	// @inco: a > 0 && b > 0 && c > 0 && d > 0 && e > 0 && f > 0 && a < b && b < c && c < d
+}
+
+func main() {}
+`,
+	})
+	var buf bytes.Buffer
+	if err := (JSONRenderer{}).Render(&buf, AuditWith(dir, AuditOptions{Limits: Limits{MaxExprTerms: 3}})); err != nil {
+		t.Fatal(err)
+	}
+	var report any
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	schema := loadAuditSchema(t)
+	for _, p := range schema.validate(schema, report, "report") {
+		t.Error(p)
+	}
+	if !strings.Contains(buf.String(), fmt.Sprintf(`"SchemaVersion": %d`, AuditSchemaVersion)) {
+		t.Errorf("report does not record the schema version:\n%s", buf.String())
+	}
+}
+
+// TestAuditSchema_CoversTypes keeps audit.schema.json and the report types
+// in step: a field added to a report type must be described in the
+// schema, and a property of the schema must not disappear from the
+// report, which would break its consumers, without a new schema version.
+func TestAuditSchema_CoversTypes(t *testing.T) {
+	schema := loadAuditSchema(t)
+	if got := schema.Properties["SchemaVersion"].Const; got != float64(AuditSchemaVersion) {
+		t.Errorf("schema version %v, want AuditSchemaVersion %d", got, AuditSchemaVersion)
+	}
+	var check func(s *jsonSchema, typ reflect.Type, at string)
+	check = func(s *jsonSchema, typ reflect.Type, at string) {
+		if s.Ref != "" {
+			s = schema.Defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		}
+		want := map[reflect.Kind]string{reflect.Int: "integer", reflect.Float64: "number", reflect.String: "string",
+			reflect.Bool: "boolean", reflect.Slice: "array", reflect.Struct: "object"}[typ.Kind()]
+		if !slices.Contains(s.types(), want) {
+			t.Errorf("%s: schema type %v, Go type %s", at, s.Type, typ)
+			return
+		}
+		switch typ.Kind() {
+		case reflect.Slice:
+			check(s.Items, typ.Elem(), at+"[]")
+		case reflect.Struct:
+			fields := make(map[string]bool)
+			for i := 0; i < typ.NumField(); i++ {
+				f := typ.Field(i)
+				name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+				if !f.IsExported() || name == "-" {
+					continue
+				}
+				if name == "" {
+					name = f.Name
+				}
+				fields[name] = true
+				p, ok := s.Properties[name]
+				if !ok {
+					t.Errorf("%s.%s is not in audit.schema.json", at, name)
+					continue
+				}
+				check(p, f.Type, at+"."+name)
+			}
+			for name := range s.Properties {
+				if !fields[name] {
+					t.Errorf("%s.%s is in audit.schema.json but no longer in the report", at, name)
+				}
+			}
+		}
+	}
+	check(schema, reflect.TypeOf(AuditResult{}), "AuditResult")
+}
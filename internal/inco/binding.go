@@ -0,0 +1,157 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ---------------------------------------------------------------------------
+// Explicit bindings: // @inco: err == nil -> rows, err := db.Query(...)
+// ---------------------------------------------------------------------------
+
+// splitBinding separates the explicit binding of a directive body, the
+// statement after a top-level "->": "@must(1s) -> ch <- v" → ("@must(1s)",
+// "ch <- v", true). "->" inside string and rune literals does not count;
+// Go has no such operator, so any other occurrence is the binding.
+func splitBinding(body string) (rest, bind string, ok bool) {
+	for i := 0; i < len(body); i++ {
+		switch ch := body[i]; ch {
+		case '"', '\'', '`':
+			for i++; i < len(body) && body[i] != ch; i++ {
+				if body[i] == '\\' && ch != '`' {
+					i++
+				}
+			}
+		case '-':
+			if i+1 < len(body) && body[i+1] == '>' {
+				return strings.TrimSpace(body[:i]), strings.TrimSpace(body[i+2:]), true
+			}
+		}
+	}
+	return body, "", false
+}
+
+// bindingRe compiles the binding pattern bind: the statement's source with
+// whitespace ignored, "..." standing for any text.
+func bindingRe(bind string) *regexp.Regexp {
+	parts := strings.Split(stripSpace(bind), "...")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile(`^(?s)` + strings.Join(parts, ".*") + `$`)
+}
+
+// bindingMatches reports whether the statement s matches the binding bind.
+func bindingMatches(fset *token.FileSet, s ast.Stmt, bind string) bool {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, s)
+	return bindingRe(bind).MatchString(stripSpace(buf.String()))
+}
+
+// stripSpace removes all white space from s.
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// attachedStmt returns the statement a directive on line applies to: for
+// an inline directive the simple statement starting on its line, which it
+// follows; for a standalone one the next statement of its function, which
+// it precedes. nil when there is none.
+func attachedStmt(f *ast.File, fset *token.FileSet, line int, inline bool) ast.Stmt {
+	bodyEnd, _ := enclosingBodyEnd(f, fset, line)
+	var stmt ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		if stmt != nil {
+			return false
+		}
+		s, ok := n.(ast.Stmt)
+		if _, block := s.(*ast.BlockStmt); !ok || block {
+			return true
+		}
+		start := srcLine(fset, s.Pos())
+		if inline && start == line && isSimpleStmt(s) || !inline && start > line && start < bodyEnd {
+			stmt = s
+		}
+		return true
+	})
+	return stmt
+}
+
+// bindingError returns why the directives on line, bound to the statement
+// bind, are not where they apply to it, or nil.
+func bindingError(f *ast.File, fset *token.FileSet, line int, inline bool, bind string) error {
+	s := attachedStmt(f, fset, line, inline)
+	_ = s // @inco: s != nil, -return(fmt.Errorf("-> %s: the directive applies to no statement here", bind))
+	if !(s != nil) {
+		return fmt.Errorf("-> %s: the directive applies to no statement here", bind)
+	}
+	_ = s // @inco: bindingMatches(fset, s, bind), -return(fmt.Errorf("-> %s: the directive applies to `%s` on line %d instead", bind, firstLine(fset, s), srcLine(fset, s.Pos())))
+	if !(bindingMatches(fset, s, bind)) {
+		return fmt.Errorf("-> %s: the directive applies to `%s` on line %d instead", bind, firstLine(fset, s), srcLine(fset, s.Pos()))
+	}
+	return nil
+}
+
+// firstLine returns the first line of the printed statement s.
+func firstLine(fset *token.FileSet, s ast.Stmt) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, s)
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	return line
+}
+
+// vetBindings reports the explicit bindings of a file that do not match
+// exactly one statement of the enclosing function, or match one the
+// directive does not apply to.
+func vetBindings(f *ast.File, fset *token.FileSet, path string, lines []string) []Diagnostic {
+	standalone, inline := collectDirectives(f, fset, lines)
+	var diags []Diagnostic
+	for i, m := range []map[int][]*Directive{standalone, inline} {
+		for _, line := range slices.Sorted(maps.Keys(m)) {
+			bind := m[line][0].Bind
+			_ = bind // @inco: bind != "", -continue
+			if !(bind != "") {
+				continue
+			}
+			diag := func(msg string) {
+				col := strings.Index(lines[line-1], "->") + 1
+				diags = append(diags, Diagnostic{Path: path, Line: line, Column: max(col, 1), Code: CodeBinding, Message: msg})
+			}
+			var matches int
+			if _, body := enclosingFunc(f, fset, line); body != nil {
+				ast.Inspect(body, func(n ast.Node) bool {
+					if s, ok := n.(ast.Stmt); ok && bindingMatches(fset, s, bind) {
+						if _, block := s.(*ast.BlockStmt); !block {
+							matches++
+						}
+					}
+					return true
+				})
+			}
+			switch {
+			case matches == 0:
+				diag(fmt.Sprintf("-> %s matches no statement of the enclosing function", bind))
+			case matches > 1:
+				diag(fmt.Sprintf("-> %s matches %d statements of the enclosing function; quote more of the one the directive applies to", bind, matches))
+			default:
+				if err := bindingError(f, fset, line, i == 1, bind); err != nil {
+					diag(err.Error())
+				}
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,97 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Explicit bindings
+// ---------------------------------------------------------------------------
+
+func TestParseDirectives_Binding(t *testing.T) {
+	tests := []struct {
+		comment string
+		expr    string
+		bind    string
+	}{
+		{"// @inco: err == nil -> rows, err := db.Query(...)", "err == nil", "rows, err := db.Query(...)"},
+		{`// @inco: s != "->", -panic("a -> b") -> use(s)`, `s != "->"`, "use(s)"},
+		{"// @must(1s) -> ch <- v", "1s", "ch <- v"},
+		{"// @inco: x > 0", "x > 0", ""},
+	}
+	for _, tt := range tests {
+		ds := ParseDirectives(tt.comment)
+		if len(ds) != 1 || ds[0].Expr != tt.expr || ds[0].Bind != tt.bind {
+			t.Errorf("ParseDirectives(%q) = %+v, want Expr %q, Bind %q", tt.comment, ds, tt.expr, tt.bind)
+		}
+	}
+	ds := ParseDirectives("// @inco: a != nil; b > 0 -> f(a, b)")
+	if len(ds) != 2 || ds[0].Bind != "f(a, b)" || ds[1].Bind != "f(a, b)" {
+		t.Errorf("several directives: %+v", ds)
+	}
+	if ds := ParseDirectives("// @inco: x > 0 ->"); ds != nil {
+		t.Errorf("empty binding: %+v, want nil", ds)
+	}
+}
+
+const bindingSrc = `package main
+
+func load(id string) (int, error) {
+	// @inco: id != "" -> n, err := fetch(id)
+
+	n, err := fetch(id)
+	_ = err // @inco: err == nil, -return(0, err) -> _ = err
+	return n, nil
+}
+
+func fetch(string) (int, error) { return 0, nil }
+`
+
+func TestEngine_Binding(t *testing.T) {
+	e := NewEngine(setupDir(t, map[string]string{"main.go": bindingSrc}))
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{`if !(id != "") {`, "if !(err == nil) {"} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %q:\n%s", want, shadow)
+		}
+	}
+
+	// Moving the statement away leaves the directive bound to another.
+	moved := strings.Replace(bindingSrc, "\n\tn, err := fetch(id)\n", "\n\tvar x int\n\tn, err := fetch(id)\n\t_ = x\n", 1)
+	e = NewEngine(setupDir(t, map[string]string{"main.go": moved}))
+	want := "main.go:4: -> n, err := fetch(id): the directive applies to `var x int` on line 6 instead"
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, want) {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+}
+
+func TestVet_Binding(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func f(a, b int) {
+	// @inco: a > 0 -> g(...)
+	g(a)
+	g(b)
+	// @inco: b > 0 -> h(b)
+	g(b)
+	// @inco: a > b -> g(a)
+	g(b)
+}
+
+func g(int) {}
+`})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, strings.TrimPrefix(d.String(), dir+"/"))
+	}
+	want := []string{
+		"main.go:4:18: -> g(...) matches 4 statements of the enclosing function; quote more of the one the directive applies to",
+		"main.go:7:18: -> h(b) matches no statement of the enclosing function",
+		"main.go:9:18: -> g(a): the directive applies to `g(b)` on line 10 instead",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("vet:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
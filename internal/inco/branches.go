@@ -0,0 +1,113 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// -continue and -break: the enclosing loop, switch or select
+// ---------------------------------------------------------------------------
+
+// branchTarget is a statement a continue or break may leave: a loop, a
+// switch or a select, with its label if any.
+type branchTarget struct {
+	loop  bool   // for or range, which continue may resume
+	label string // "" when unlabeled
+}
+
+// enclosingBranchTargets returns the loops, switches and selects whose
+// body contains line, innermost last, within the innermost function
+// containing it.
+func enclosingBranchTargets(f *ast.File, fset *token.FileSet, line int) []branchTarget {
+	var targets []branchTarget
+	labels := make(map[ast.Stmt]string)
+	contains := func(b *ast.BlockStmt) bool {
+		return b != nil && srcLine(fset, b.Lbrace) <= line && line <= srcLine(fset, b.Rbrace)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		loop := false
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if contains(n.Body) {
+				targets = nil
+			}
+		case *ast.FuncLit:
+			if contains(n.Body) {
+				targets = nil // branches do not leave a function literal
+			}
+		case *ast.LabeledStmt:
+			labels[n.Stmt] = n.Label.Name
+		case *ast.ForStmt:
+			body, loop = n.Body, true
+		case *ast.RangeStmt:
+			body, loop = n.Body, true
+		case *ast.SwitchStmt:
+			body = n.Body
+		case *ast.TypeSwitchStmt:
+			body = n.Body
+		case *ast.SelectStmt:
+			body = n.Body
+		}
+		if contains(body) {
+			targets = append(targets, branchTarget{loop: loop, label: labels[n.(ast.Stmt)]})
+		}
+		return true
+	})
+	return targets
+}
+
+// branchError returns why the -continue or -break action of d, a directive
+// on line, has no statement to leave, or nil. Either takes a label as its
+// only argument: -break(outer).
+func branchError(f *ast.File, fset *token.FileSet, line int, d *Directive) error {
+	_ = d // @inco: d.Action == ActionContinue || d.Action == ActionBreak, -return(nil)
+	if !(d.Action == ActionContinue || d.Action == ActionBreak) {
+		return nil
+	}
+	action := "-" + d.Action.String()
+	_ = d // @inco: len(d.ActionArgs) <= 1, -return(fmt.Errorf("%s(%s): takes at most a label", action, strings.Join(d.ActionArgs, ", ")))
+	if !(len(d.ActionArgs) <= 1) {
+		return fmt.Errorf("%s(%s): takes at most a label", action, strings.Join(d.ActionArgs, ", "))
+	}
+	targets := enclosingBranchTargets(f, fset, line)
+	if len(d.ActionArgs) == 1 {
+		label := d.ActionArgs[0]
+		for _, t := range targets {
+			if t.label != label {
+				continue
+			}
+			_ = t // @inco: t.loop || d.Action == ActionBreak, -return(fmt.Errorf("-continue(%s): %s labels a switch or select, not a loop", label, label))
+			if !(t.loop || d.Action == ActionBreak) {
+				return fmt.Errorf("-continue(%s): %s labels a switch or select, not a loop", label, label)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s(%s): no enclosing loop, switch or select is labeled %s", action, label, label)
+	}
+	for _, t := range targets {
+		if t.loop || d.Action == ActionBreak {
+			return nil
+		}
+	}
+	if d.Action == ActionContinue {
+		return fmt.Errorf("-continue: the directive is not inside a for loop")
+	}
+	return fmt.Errorf("-break: the directive is not inside a for loop, switch or select")
+}
+
+// branchLabels returns the labels a -continue or -break (action) on line
+// may name: those of the enclosing loops, and for -break of the enclosing
+// switches and selects too.
+func branchLabels(f *ast.File, fset *token.FileSet, line int, action ActionKind) map[string]bool {
+	labels := make(map[string]bool)
+	for _, t := range enclosingBranchTargets(f, fset, line) {
+		if t.label != "" && (t.loop || action == ActionBreak) {
+			labels[t.label] = true
+		}
+	}
+	return labels
+}
@@ -0,0 +1,132 @@
+package inco
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// -continue and -break
+// ---------------------------------------------------------------------------
+
+const branchSrc = `package main
+
+func f(items []int, ch chan int) {
+	// line 4: no loop
+outer:
+	for _, x := range items {
+		// line 7: in a loop
+		switch x {
+		case 0:
+			// line 10: in a switch in a loop
+		}
+		go func() {
+			// line 13: in a function literal
+		}()
+	}
+	_ = outer
+sel:
+	select {
+	case <-ch:
+		// line 20: in a select
+	}
+}
+`
+
+func TestBranchError(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", branchSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line    int
+		comment string
+		want    string // "" = valid
+	}{
+		{4, "// @inco: ok, -continue", "-continue: the directive is not inside a for loop"},
+		{4, "// @inco: ok, -break", "-break: the directive is not inside a for loop, switch or select"},
+		{7, "// @inco: ok, -continue", ""},
+		{7, "// @inco: ok, -break(outer)", ""},
+		{10, "// @inco: ok, -continue", ""},
+		{10, "// @inco: ok, -continue(outer)", ""},
+		{10, "// @inco: ok, -break(inner)", "-break(inner): no enclosing loop, switch or select is labeled inner"},
+		{13, "// @inco: ok, -continue", "-continue: the directive is not inside a for loop"},
+		{20, "// @inco: ok, -break(sel)", ""},
+		{20, "// @inco: ok, -continue(sel)", "-continue(sel): sel labels a switch or select, not a loop"},
+		{7, "// @inco: ok, -break(a, b)", "-break(a, b): takes at most a label"},
+	}
+	for _, tt := range tests {
+		err := branchError(f, fset, tt.line, ParseDirective(tt.comment))
+		if got := errString(err); got != tt.want {
+			t.Errorf("line %d %s: err = %q, want %q", tt.line, tt.comment, got, tt.want)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestEngine_LabeledBreak(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func f(rows [][]int) {
+outer:
+	for _, row := range rows {
+		for _, v := range row {
+			// @inco: v >= 0, -break(outer)
+			_ = v
+		}
+	}
+}
+`})
+	e := NewEngine(dir)
+	e.Run()
+	if shadow := readShadow(t, e); !strings.Contains(shadow, "break outer") {
+		t.Errorf("shadow missing the labeled break:\n%s", shadow)
+	}
+
+	src := "package main\n\nfunc f(x int) {\n\t// @inco: x > 0, -continue\n}\n"
+	e = NewEngine(setupDir(t, map[string]string{"main.go": src}))
+	want := "main.go:4: -continue: the directive is not inside a for loop"
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, want) {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+	diags := Vet(setupDir(t, map[string]string{"main.go": src}))
+	if len(diags) != 1 || diags[0].Column != 19 || !strings.HasSuffix(diags[0].Message, "not inside a for loop") {
+		t.Errorf("vet = %v", diags)
+	}
+}
+
+func TestVet_LabelFix(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func f(rows [][]int) {
+outer:
+	for _, row := range rows {
+		for _, v := range row {
+			_ = v // @inco: v >= 0, -continue(outr)
+		}
+	}
+}
+`})
+	diags := Vet(dir)
+	if len(diags) != 1 || len(diags[0].Fixes) != 1 || diags[0].Fixes[0].Message != "replace outr with outer" {
+		t.Fatalf("vet = %+v", diags)
+	}
+	if remaining := ApplyFixes(diags); len(remaining) != 0 {
+		t.Errorf("remaining = %v", remaining)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	if !strings.Contains(string(data), "-continue(outer)") {
+		t.Errorf("fix not applied:\n%s", data)
+	}
+}
@@ -0,0 +1,59 @@
+package inco
+
+import (
+	"cmp"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Build environment: what an overlay was generated for
+// ---------------------------------------------------------------------------
+
+// BuildEnv is the go build environment a generation ran under. It decides
+// which files build and what their imports resolve to (see
+// collectPackages), so shadows generated under one may not fit another.
+type BuildEnv struct {
+	GoVersion string `json:"go_version"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	Tags      string `json:"tags,omitempty"` // -tags given to the go command
+	GOFLAGS   string `json:"goflags,omitempty"`
+}
+
+// buildEnv returns the environment of the go command in e.Root, with
+// e.BuildTags, or nil when the go command cannot be run.
+func (e *Engine) buildEnv() *BuildEnv {
+	cmd := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH", "GOFLAGS")
+	cmd.Dir = e.Root
+	out, err := cmd.Output()
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	v := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	_ = v // @inco: len(v) == 4, -return(nil)
+	if !(len(v) == 4) {
+		return nil
+	}
+	return &BuildEnv{GoVersion: v[0], GOOS: v[1], GOARCH: v[2], Tags: e.BuildTags, GOFLAGS: v[3]}
+}
+
+// changes describes how b differs from old: "GOOS linux → windows, tags
+// none → integration", or "" when they are the same.
+func (b BuildEnv) changes(old BuildEnv) string {
+	var out []string
+	for _, f := range []struct{ name, old, new string }{
+		{"go", old.GoVersion, b.GoVersion},
+		{"GOOS", old.GOOS, b.GOOS},
+		{"GOARCH", old.GOARCH, b.GOARCH},
+		{"tags", old.Tags, b.Tags},
+		{"GOFLAGS", old.GOFLAGS, b.GOFLAGS},
+	} {
+		if f.old != f.new {
+			out = append(out, fmt.Sprintf("%s %s → %s", f.name, cmp.Or(f.old, "none"), cmp.Or(f.new, "none")))
+		}
+	}
+	return strings.Join(out, ", ")
+}
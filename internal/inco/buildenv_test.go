@@ -0,0 +1,64 @@
+package inco
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Build environment
+// ---------------------------------------------------------------------------
+
+func TestBuildEnvChanges(t *testing.T) {
+	old := BuildEnv{GoVersion: "go1.22.1", GOOS: "linux", GOARCH: "amd64"}
+	if got := old.changes(old); got != "" {
+		t.Errorf("changes of the same environment = %q, want none", got)
+	}
+	b := old
+	b.GOOS, b.Tags = "windows", "integration"
+	if got, want := b.changes(old), "GOOS linux → windows, tags none → integration"; got != want {
+		t.Errorf("changes = %q, want %q", got, want)
+	}
+}
+
+func TestEngine_BuildEnvRegenerates(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+func Do(x int) {
+	_ = x // @inco: x > 0
+}
+`,
+	})
+	run := func(tags string) (Meta, *Engine) {
+		e := NewEngine(dir)
+		e.Quiet = true
+		e.BuildTags = tags
+		e.Run()
+		return readMeta(t, e), e
+	}
+	m, e := run("")
+	if m.Env == nil || m.Env.GOOS == "" || m.Env.GoVersion == "" || m.Env.Tags != "" {
+		t.Fatalf("build_env = %+v, want the go environment without tags", m.Env)
+	}
+
+	// A shadow reused from the cache keeps its content; one regenerated
+	// for another environment does not.
+	const reused = "package main // reused\n"
+	os.WriteFile(filepath.Join(e.CacheDir, m.Files["main.go"].Shadow), []byte(reused), 0o644)
+	if _, e := run(""); readShadow(t, e) != reused {
+		t.Fatal("the same environment regenerated the shadow")
+	}
+	m, e = run("integration")
+	if m.Env == nil || m.Env.Tags != "integration" {
+		t.Errorf("build_env = %+v, want tags integration", m.Env)
+	}
+	if readShadow(t, e) == reused {
+		t.Error("other build tags reused the shadow")
+	}
+}
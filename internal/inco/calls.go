@@ -0,0 +1,460 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Call results: one call for the check and its action
+// ---------------------------------------------------------------------------
+
+// hoistedName is the name of the i-th call result a check hoists.
+func hoistedName(i int) string {
+	return fmt.Sprintf("_inco_call%d", i)
+}
+
+// hoistCalls rewrites a check whose action repeats a call of its
+// expression, as in
+//
+//	// @inco: s.Len() > 0, -panic(fmt.Sprintf("length %d", s.Len()))
+//
+// so that the call runs once: it returns the if-statement init binding
+// the call results to temporaries, and expr and args using them:
+//
+//	if _inco_call0 := s.Len(); !(_inco_call0 > 0) {
+//	    panic(fmt.Sprintf("length %d", _inco_call0))
+//	}
+//
+// Only calls expr always evaluates are hoisted: moving one out of the
+// right operand of && or || would run it when the left one already
+// decides, as in s != nil && s.Len() > 0. init is "" when nothing is
+// hoisted.
+func hoistCalls(expr string, args []string) (init, newExpr string, newArgs []string) {
+	x, err := parser.ParseExpr(expr)
+	_ = err // @inco: err == nil, -return("", expr, args)
+	if !(err == nil) {
+		return "", expr, args
+	}
+	var calls []string // hoistable calls of expr, outermost first
+	var walk func(n ast.Expr)
+	walk = func(n ast.Expr) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BinaryExpr:
+				if n.Op == token.LAND || n.Op == token.LOR {
+					walk(n.X)
+					return false
+				}
+			case *ast.FuncLit:
+				return false
+			case *ast.CallExpr:
+				if !pureBuiltin(n) {
+					calls = append(calls, types.ExprString(n))
+				}
+			}
+			return true
+		})
+	}
+	walk(x)
+
+	var hoisted []string
+	newArgs = slices.Clone(args)
+	for i, arg := range args {
+		var used []string
+		newArgs[i], used = replaceCalls(arg, calls, hoisted)
+		for _, c := range used {
+			if !slices.Contains(hoisted, c) {
+				hoisted = append(hoisted, c)
+			}
+		}
+		if len(used) > 0 {
+			newArgs[i], _ = replaceCalls(arg, hoisted, hoisted)
+		}
+	}
+	_ = hoisted // @inco: len(hoisted) > 0, -return("", expr, args)
+	if !(len(hoisted) > 0) {
+		return "", expr, args
+	}
+	newExpr, _ = replaceCalls(expr, hoisted, hoisted)
+	names := make([]string, len(hoisted))
+	for i := range hoisted {
+		names[i] = hoistedName(i)
+	}
+	return strings.Join(names, ", ") + " := " + strings.Join(hoisted, ", "), newExpr, newArgs
+}
+
+// replaceCalls replaces the outermost calls of the expression s that
+// print as one of calls with their temporary, the hoistedName of their
+// index in hoisted, and returns the calls it found. Calls not in hoisted
+// yet are reported but left in place.
+func replaceCalls(s string, calls, hoisted []string) (string, []string) {
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return(s, nil)
+	if !(err == nil) {
+		return s, nil
+	}
+	type span struct {
+		from, to int
+		name     string
+	}
+	var spans []span
+	var found []string
+	ast.Inspect(x, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		_ = ok // @inco: ok, -return(true)
+		if !(ok) {
+			return true
+		}
+		text := types.ExprString(call)
+		_ = text // @inco: slices.Contains(calls, text), -return(true)
+		if !(slices.Contains(calls, text)) {
+			return true
+		}
+		found = append(found, text)
+		if i := slices.Index(hoisted, text); i >= 0 {
+			// Positions of an expression parsed alone are offsets + 1.
+			spans = append(spans, span{int(call.Pos()) - 1, int(call.End()) - 1, hoistedName(i)})
+		}
+		return false
+	})
+	for i := len(spans) - 1; i >= 0; i-- {
+		s = s[:spans[i].from] + spans[i].name + s[spans[i].to:]
+	}
+	return s, found
+}
+
+// pureBuiltin reports whether call is a builtin without side effects, or
+// a conversion to a predeclared type: there is nothing to gain from
+// hoisting it, and vet need not report it.
+func pureBuiltin(call *ast.CallExpr) bool {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	_ = ok // @inco: ok, -return(false)
+	if !(ok) {
+		return false
+	}
+	switch id.Name {
+	case "len", "cap", "min", "max", "real", "imag", "complex":
+		return true
+	}
+	_, conversion := types.Universe.Lookup(id.Name).(*types.TypeName)
+	return conversion
+}
+
+// ---------------------------------------------------------------------------
+// Side effects: calls vet cannot tell are pure
+// ---------------------------------------------------------------------------
+
+// pureFuncs are the standard library functions contracts commonly call
+// that have no side effects, by import path; "*" allows every function of
+// the package.
+var pureFuncs = map[string][]string{
+	"strings":       {"*"},
+	"bytes":         {"Compare", "Contains", "ContainsAny", "ContainsRune", "Count", "Equal", "EqualFold", "HasPrefix", "HasSuffix", "Index", "IndexAny", "IndexByte", "IndexRune", "LastIndex", "TrimSpace"},
+	"unicode":       {"*"},
+	"unicode/utf8":  {"*"},
+	"math":          {"*"},
+	"strconv":       {"*"},
+	"path":          {"*"},
+	"path/filepath": {"Base", "Clean", "Dir", "Ext", "IsAbs", "IsLocal", "Join", "Match", "Rel", "Split", "ToSlash", "FromSlash", "VolumeName"},
+	"slices":        {"BinarySearch", "Compare", "Contains", "Equal", "Index", "IsSorted", "Max", "Min"},
+	"maps":          {"Equal"},
+	"errors":        {"Is"},
+	"regexp":        {"MatchString"},
+	"net/netip":     {"ParseAddr", "ParsePrefix", "ParseAddrPort"},
+	"time":          {"Duration", "ParseDuration"},
+}
+
+// impureCalls returns the calls of expr, a contract expression of f, that
+// are not known to be free of side effects: calls of functions outside
+// pureFuncs, methods and function values. A check runs on every call of
+// the function it guards, and once more under -log-violations, so such a
+// call may run more often than the code reads.
+func impureCalls(f *ast.File, expr string) []string {
+	x, err := parser.ParseExpr(expr)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	imports := fileImportNames(f)
+	local := fileScopeNames(f)
+	var out []string
+	ast.Inspect(x, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		_ = ok // @inco: ok && !pureBuiltin(call), -return(true)
+		if !(ok && !pureBuiltin(call)) {
+			return true
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && !local[pkg.Name] {
+				names := pureFuncs[imports[pkg.Name]]
+				if slices.Contains(names, "*") || slices.Contains(names, sel.Sel.Name) {
+					return true
+				}
+			}
+		}
+		if text := types.ExprString(call.Fun); !slices.Contains(out, text) {
+			out = append(out, text)
+		}
+		return true
+	})
+	return out
+}
+
+// vetPurity reports the contracts of f calling functions not known to be
+// free of side effects (see impureCalls).
+func vetPurity(f *ast.File, fset *token.FileSet, path string, lines []string) []Diagnostic {
+	standalone, inline := collectDirectives(f, fset, lines)
+	maps.Copy(standalone, inline)
+	var diags []Diagnostic
+	for _, line := range slices.Sorted(maps.Keys(standalone)) {
+		for _, d := range standalone[line] {
+			_ = d // @inco: d.Kind.checksExpr(), -continue
+			if !(d.Kind.checksExpr()) {
+				continue
+			}
+			for _, s := range []string{d.Cond, d.Expr} {
+				for _, fn := range impureCalls(f, s) {
+					diags = append(diags, Diagnostic{Path: path, Line: line, Column: strings.Index(lines[line-1], fn+"(") + 1, Code: CodeImpure,
+						Message: fmt.Sprintf("contract %s calls %s, which is not known to be free of side effects; it runs on every check", d.Expr, fn)})
+				}
+			}
+		}
+	}
+	return diags
+}
+
+// ---------------------------------------------------------------------------
+// Side effects: contracts that change program state
+// ---------------------------------------------------------------------------
+
+// vetSideEffects reports the contracts of f that change program state when
+// checked: assignments and channel sends in function literals, channel
+// receives, and calls of methods that modify their receiver. A contract
+// must only observe: once checks are removed for a release, or a message
+// is reworded, the program would behave differently.
+func vetSideEffects(f *ast.File, fset *token.FileSet, path string, lines []string) []Diagnostic {
+	standalone, inline := collectDirectives(f, fset, lines)
+	maps.Copy(standalone, inline)
+	var mutating map[string][]string // loaded on the first method call
+	imports := fileImportNames(f)
+	var diags []Diagnostic
+	for _, line := range slices.Sorted(maps.Keys(standalone)) {
+		for _, d := range standalone[line] {
+			_ = d // @inco: d.Kind.checksExpr(), -continue
+			if !(d.Kind.checksExpr()) {
+				continue
+			}
+			for _, s := range []string{d.Cond, d.Expr} {
+				x, err := parser.ParseExpr(s)
+				_ = err // @inco: s != "" && err == nil, -continue
+				if !(s != "" && err == nil) {
+					continue
+				}
+				report := func(what string) {
+					diags = append(diags, Diagnostic{Path: path, Line: line, Column: strings.Index(lines[line-1], "@") + 1, Code: CodeSideEffect,
+						Message: fmt.Sprintf("contract %s %s; a contract must not change program state", d.Expr, what)})
+				}
+				for _, what := range stateChanges(x) {
+					report(what)
+				}
+				ast.Inspect(x, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					_ = ok // @inco: ok, -return(true)
+					if !(ok) {
+						return true
+					}
+					sel, ok := call.Fun.(*ast.SelectorExpr)
+					_ = ok // @inco: ok, -return(true)
+					if !(ok) {
+						return true
+					}
+					if pkg, ok := sel.X.(*ast.Ident); ok && imports[pkg.Name] != "" {
+						return true // a function of another package
+					}
+					if mutating == nil {
+						mutating = mutatingMethods(filepath.Dir(path), f.Name.Name)
+					}
+					if methods := mutating[sel.Sel.Name]; len(methods) > 0 {
+						report(fmt.Sprintf("calls %s, and %s modifies its receiver", types.ExprString(sel), strings.Join(methods, " and ")))
+					}
+					return true
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// stateChanges describes what the expression x changes by itself: a
+// channel receive, or an assignment, increment or channel send in the body
+// of a function literal to a variable the literal does not declare.
+func stateChanges(x ast.Expr) []string {
+	var out []string
+	var locals map[string]bool // declared in the function literals of x
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			if locals == nil {
+				locals = make(map[string]bool)
+			}
+			ast.Inspect(n, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.Field:
+					for _, id := range n.Names {
+						locals[id.Name] = true
+					}
+				case *ast.AssignStmt:
+					if n.Tok == token.DEFINE {
+						for _, lhs := range n.Lhs {
+							if id, ok := lhs.(*ast.Ident); ok {
+								locals[id.Name] = true
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					for _, id := range n.Names {
+						locals[id.Name] = true
+					}
+				}
+				return true
+			})
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				out = append(out, "receives from "+types.ExprString(n.X))
+			}
+		case *ast.SendStmt:
+			out = append(out, "sends on "+types.ExprString(n.Chan))
+		case *ast.IncDecStmt:
+			if root := rootIdent(n.X); root != nil && !locals[root.Name] {
+				out = append(out, "modifies "+types.ExprString(n.X))
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				return true
+			}
+			for _, lhs := range n.Lhs {
+				if root := rootIdent(lhs); root != nil && root.Name != "_" && !locals[root.Name] {
+					out = append(out, "assigns "+types.ExprString(lhs))
+				}
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// rootIdent returns the variable an assignable expression belongs to: x
+// for x, x.f, x[i], *x and (x), or nil when it is not rooted in one.
+func rootIdent(x ast.Expr) *ast.Ident {
+	for {
+		switch e := x.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.SelectorExpr:
+			x = e.X
+		case *ast.IndexExpr:
+			x = e.X
+		case *ast.StarExpr:
+			x = e.X
+		case *ast.ParenExpr:
+			x = e.X
+		default:
+			return nil
+		}
+	}
+}
+
+// mutatingMethods returns, by method name, the methods ("Queue.Push") of
+// package pkg in dir that modify their receiver: methods with
+// a pointer receiver assigning to it or through it, sending on or closing
+// a channel of it, or calling such a method of it. Types are not
+// resolved, so a contract calling a method of that name on another type
+// is reported too.
+func mutatingMethods(dir, pkg string) map[string][]string {
+	type method struct {
+		recv, name string
+		decl       *ast.FuncDecl
+	}
+	var methods []method
+	entries, _ := os.ReadDir(dir)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		_ = name // @inco: strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go"), -continue
+		if !(strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		_ = err // @inco: err == nil && f.Name.Name == pkg, -continue
+		if !(err == nil && f.Name.Name == pkg) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if ok && fn.Body != nil && fn.Recv != nil && len(fn.Recv.List) == 1 && len(fn.Recv.List[0].Names) == 1 {
+				if _, ptr := fn.Recv.List[0].Type.(*ast.StarExpr); ptr {
+					methods = append(methods, method{recvTypeName(fn.Recv.List[0].Type), fn.Name.Name, fn})
+				}
+			}
+		}
+	}
+
+	mutates := make(map[string]bool) // "Type.Method"
+	for changed := true; changed; {
+		changed = false
+		for _, m := range methods {
+			key := m.recv + "." + m.name
+			_ = key // @inco: !mutates[key], -continue
+			if !(!mutates[key]) {
+				continue
+			}
+			recv := m.decl.Recv.List[0].Names[0].Name
+			onRecv := func(x ast.Expr) bool {
+				root := rootIdent(x)
+				return root != nil && root.Name == recv
+			}
+			ast.Inspect(m.decl.Body, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.AssignStmt:
+					if n.Tok != token.DEFINE && slices.ContainsFunc(n.Lhs, onRecv) {
+						mutates[key] = true
+					}
+				case *ast.IncDecStmt:
+					mutates[key] = mutates[key] || onRecv(n.X)
+				case *ast.SendStmt:
+					mutates[key] = mutates[key] || onRecv(n.Chan)
+				case *ast.CallExpr:
+					switch fun := n.Fun.(type) {
+					case *ast.Ident:
+						if (fun.Name == "close" || fun.Name == "delete" || fun.Name == "clear") && len(n.Args) > 0 {
+							mutates[key] = mutates[key] || onRecv(n.Args[0])
+						}
+					case *ast.SelectorExpr:
+						if id, ok := fun.X.(*ast.Ident); ok && id.Name == recv {
+							mutates[key] = mutates[key] || mutates[m.recv+"."+fun.Sel.Name]
+						}
+					}
+				}
+				return !mutates[key]
+			})
+			changed = changed || mutates[key]
+		}
+	}
+
+	out := make(map[string][]string)
+	for _, m := range methods {
+		if key := m.recv + "." + m.name; mutates[key] && !slices.Contains(out[m.name], key) {
+			out[m.name] = append(out[m.name], key)
+		}
+	}
+	return out
+}
@@ -0,0 +1,163 @@
+package inco
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Calls in contracts
+// ---------------------------------------------------------------------------
+
+func TestHoistCalls(t *testing.T) {
+	tests := []struct {
+		expr, init, newExpr string
+		args, newArgs       []string
+	}{
+		{
+			expr: "s.Len() > 0", args: []string{`fmt.Sprintf("len %d", s.Len())`},
+			init: "_inco_call0 := s.Len()", newExpr: "_inco_call0 > 0", newArgs: []string{`fmt.Sprintf("len %d", _inco_call0)`},
+		},
+		{
+			// Two calls, the second one listed first in the message.
+			expr: "a.N() < b.N()", args: []string{`fmt.Sprint(b.N(), a.N())`},
+			init: "_inco_call0, _inco_call1 := b.N(), a.N()", newExpr: "_inco_call1 < _inco_call0", newArgs: []string{`fmt.Sprint(_inco_call0, _inco_call1)`},
+		},
+		// Not repeated by the action.
+		{expr: "s.Len() > 0", args: []string{`"empty"`}, newExpr: "s.Len() > 0", newArgs: []string{`"empty"`}},
+		// Only evaluated when s is not nil.
+		{expr: "s != nil && s.Len() > 0", args: []string{`fmt.Sprint(s.Len())`}, newExpr: "s != nil && s.Len() > 0", newArgs: []string{`fmt.Sprint(s.Len())`}},
+		// Builtins are not worth a temporary.
+		{expr: "len(xs) > 0", args: []string{`fmt.Sprint(len(xs))`}, newExpr: "len(xs) > 0", newArgs: []string{`fmt.Sprint(len(xs))`}},
+	}
+	for _, tt := range tests {
+		init, expr, args := hoistCalls(tt.expr, tt.args)
+		if init != tt.init || expr != tt.newExpr || !slices.Equal(args, tt.newArgs) {
+			t.Errorf("hoistCalls(%q, %q) = %q, %q, %q; want %q, %q, %q", tt.expr, tt.args, init, expr, args, tt.init, tt.newExpr, tt.newArgs)
+		}
+	}
+}
+
+func TestEngine_HoistsRepeatedCalls(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "fmt"
+
+type Queue struct{ items []int }
+
+func (q *Queue) Len() int { return len(q.items) }
+
+func Pop(q *Queue) {
+	// @inco: q.Len() > 0, -panic(fmt.Sprintf("queue has %d items", q.Len()))
+	q.items = q.items[1:]
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	shadow := readShadow(t, e)
+	if !strings.Contains(shadow, `if _inco_call0 := q.Len(); !(_inco_call0 > 0) {`) ||
+		!strings.Contains(shadow, `panic(fmt.Sprintf("queue has %d items", _inco_call0))`) {
+		t.Errorf("shadow does not call q.Len() once:\n%s", shadow)
+	}
+}
+
+func TestImpureCalls(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "x.go", `package x
+
+import (
+	"errors"
+	str "strings"
+)
+
+func valid(string) bool { return true }
+`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := impureCalls(f, `str.HasPrefix(s, "a") && len(s) < 10 && int64(n) > 0 && errors.Is(err, io.EOF) && errors.As(err, &pe) && valid(s) && r.Next() && valid(s)`)
+	if want := []string{"errors.As", "valid", "r.Next"}; !slices.Equal(got, want) {
+		t.Errorf("impureCalls = %q, want %q", got, want)
+	}
+}
+
+func TestVet_RequirePure(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "strings"
+
+func Do(s string, r interface{ Ready() bool }) {
+	// @inco: strings.HasPrefix(s, "a")
+	// @inco: r.Ready()
+}
+`,
+	})
+	if diags := Vet(dir); len(diags) != 0 {
+		t.Fatalf("Vet without RequirePure = %v, want none", diags)
+	}
+	diags := VetWith(dir, VetOptions{RequirePure: true})
+	if len(diags) != 1 || diags[0].Line != 7 || diags[0].Column != 12 ||
+		diags[0].Message != "contract r.Ready() calls r.Ready, which is not known to be free of side effects; it runs on every check" {
+		t.Errorf("diagnostics = %v, want r.Ready on line 7", diags)
+	}
+}
+
+func TestVet_SideEffects(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"queue.go": `package main
+
+type Queue struct {
+	items []int
+	done  chan struct{}
+}
+
+func (q *Queue) Len() int { return len(q.items) }
+
+func (q *Queue) Push(x int) bool {
+	q.items = append(q.items, x)
+	return true
+}
+
+func (q *Queue) Close() bool {
+	close(q.done)
+	return true
+}
+
+func (q *Queue) Reset() bool { return q.Push(0) && q.Close() }
+`,
+		"main.go": `package main
+
+import "strings"
+
+var calls int
+
+func Do(q *Queue, ch chan int, s string) {
+	// @inco: q.Len() < 10 && strings.Contains(s, "a")
+	// @inco: q.Push(1)
+	// @inco: <-ch > 0
+	// @inco: func() bool { n := 1; calls += n; return true }()
+	// @inco: q.Reset()
+}
+`,
+	})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, fmt.Sprintf("%d: %s", d.Line, d.Message))
+	}
+	want := []string{
+		"9: contract q.Push(1) calls q.Push, and Queue.Push modifies its receiver; a contract must not change program state",
+		"10: contract <-ch > 0 receives from ch; a contract must not change program state",
+		"11: contract func() bool { n := 1; calls += n; return true }() assigns calls; a contract must not change program state",
+		"12: contract q.Reset() calls q.Reset, and Queue.Reset modifies its receiver; a contract must not change program state",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,99 @@
+package inco
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// ---------------------------------------------------------------------------
+// Message catalog
+// ---------------------------------------------------------------------------
+
+// msgCallRe matches a catalog reference in action arguments.
+// Group 1: the quoted key, double-quoted or raw
+var msgCallRe = regexp.MustCompile(`\bmsg\(\s*(` + stringLit + `)\s*\)`)
+
+// messageCatalog maps message keys to the text generated in their place.
+type messageCatalog struct {
+	messages map[string]string
+	hash     string // of the catalog file, recorded in the manifest
+}
+
+// loadCatalog reads e.Messages. The file is a JSON object whose string
+// values are messages; nested objects are flattened into dotted keys, so
+// {"errors": {"negative_x": "..."}} defines "errors.negative_x". Returns
+// nil when no catalog is configured.
+func (e *Engine) loadCatalog() *messageCatalog {
+	_ = e.Messages // @inco: e.Messages != "", -return(nil)
+	if !(e.Messages != "") {
+		return nil
+	}
+	data, err := os.ReadFile(e.Messages)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("message catalog: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("message catalog: %w", err))
+	}
+	var tree map[string]any
+	err = json.Unmarshal(data, &tree)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("message catalog %s: %w", e.Messages, err))
+	if !(err == nil) {
+		panic(fmt.Errorf("message catalog %s: %w", e.Messages, err))
+	}
+	c := &messageCatalog{messages: make(map[string]string), hash: fmt.Sprintf("%x", sha256.Sum256(data))}
+	c.flatten("", tree)
+	return c
+}
+
+func (c *messageCatalog) flatten(prefix string, tree map[string]any) {
+	for k, v := range tree {
+		key := prefix + k
+		switch v := v.(type) {
+		case string:
+			c.messages[key] = v
+		case map[string]any:
+			c.flatten(key+".", v)
+		default:
+			panic(fmt.Errorf("message catalog: %s must be a string or an object, not %T", key, v))
+		}
+	}
+}
+
+// resolveMessages returns d with every msg("key") in its action
+// arguments replaced by the catalog's message as a string literal, or d
+// itself when it has none. Unknown keys, and keys without a catalog,
+// panic at the directive.
+func (e *Engine) resolveMessages(d *Directive, path string, line int) *Directive {
+	var resolved *Directive
+	for i, arg := range d.ActionArgs {
+		_ = arg // @inco: msgCallRe.MatchString(arg), -continue
+		if !(msgCallRe.MatchString(arg)) {
+			continue
+		}
+		if resolved == nil {
+			clone := *d
+			clone.ActionArgs = append([]string(nil), d.ActionArgs...)
+			resolved = &clone
+		}
+		resolved.ActionArgs[i] = msgCallRe.ReplaceAllStringFunc(arg, func(call string) string {
+			key, _ := strconv.Unquote(msgCallRe.FindStringSubmatch(call)[1])
+			_ = e.catalog // @inco: e.catalog != nil, -panic(fmt.Errorf("%s:%d: msg(%q) needs a message catalog (-messages)", e.relPath(path), line, key))
+			if !(e.catalog != nil) {
+				panic(fmt.Errorf("%s:%d: msg(%q) needs a message catalog (-messages)", e.relPath(path), line, key))
+			}
+			text, ok := e.catalog.messages[key]
+			_ = ok // @inco: ok, -panic(fmt.Errorf("%s:%d: message key %q is not in %s", e.relPath(path), line, key, e.relPath(e.Messages)))
+			if !(ok) {
+				panic(fmt.Errorf("%s:%d: message key %q is not in %s", e.relPath(path), line, key, e.relPath(e.Messages)))
+			}
+			return strconv.Quote(text)
+		})
+	}
+	if resolved == nil {
+		return d
+	}
+	return resolved
+}
@@ -0,0 +1,93 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Message catalog
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_MsgShorthand(t *testing.T) {
+	d := ParseDirective(`// @inco: #api x > 0, msg("errors.negative_x")`)
+	if d == nil {
+		t.Fatal("expected directive")
+	}
+	if d.Expr != "x > 0" || d.Action != ActionPanic || !slices.Equal(d.ActionArgs, []string{`msg("errors.negative_x")`}) {
+		t.Errorf("got %+v", d)
+	}
+}
+
+func TestParseDirective_MsgShorthandRawKey(t *testing.T) {
+	d := ParseDirective("// @inco: x > 0, msg(`errors.negative_x`)")
+	if d == nil || d.Expr != "x > 0" || !slices.Equal(d.ActionArgs, []string{"msg(`errors.negative_x`)"}) {
+		t.Errorf("got %+v", d)
+	}
+}
+
+const catalogSrc = `package main
+
+import "errors"
+
+func Do(x int) error {
+	// @inco: x > 0, msg("errors.negative_x")
+	// @inco: x < 100, -return(errors.New(msg("errors.too_big")))
+	// @inco: x != 7, -panic("seven")
+	// @inco: x != 8, -panic(msg(` + "`errors.negative_x`" + `))
+	return nil
+}
+`
+
+func TestEngine_MessageCatalog(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go":       catalogSrc,
+		"messages.json": `{"errors": {"negative_x": "le montant doit être positif", "too_big": "montant \"trop\" élevé"}}`,
+	})
+	e := NewEngine(dir)
+	e.Messages = filepath.Join(dir, "messages.json")
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{
+		`panic("le montant doit être positif")`,
+		`return errors.New("montant \"trop\" élevé")`,
+		`panic("seven")`,
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %s:\n%s", want, shadow)
+		}
+	}
+	if strings.Contains(shadow, "errors.negative_x") {
+		t.Errorf("message key leaked into the shadow:\n%s", shadow)
+	}
+
+	// Changing the catalog regenerates the shadow.
+	if err := os.WriteFile(e.Messages, []byte(`{"errors.negative_x": "amount must be positive", "errors.too_big": "too big"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.Run()
+	if shadow := readShadow(t, e); !strings.Contains(shadow, `panic("amount must be positive")`) {
+		t.Errorf("shadow not regenerated after a catalog change:\n%s", shadow)
+	}
+}
+
+func TestEngine_MessageCatalogErrors(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go":       catalogSrc,
+		"messages.json": `{"errors": {"negative_x": "negative"}}`,
+	})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, `main.go:6: msg("errors.negative_x") needs a message catalog`) {
+		t.Errorf("unexpected error without catalog: %s", msg)
+	}
+
+	e := NewEngine(dir)
+	e.Messages = filepath.Join(dir, "messages.json")
+	msg = runExpectPanic(t, e)
+	if !strings.Contains(msg, `main.go:7: message key "errors.too_big" is not in messages.json`) {
+		t.Errorf("unexpected error for a missing key: %s", msg)
+	}
+}
@@ -0,0 +1,75 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// ---------------------------------------------------------------------------
+// cgo files
+// ---------------------------------------------------------------------------
+
+// cgoPreamble returns the comment group cgo compiles as C for f — the
+// comment immediately preceding import "C" — and whether f imports "C".
+// The group is nil when the import has no preamble.
+func cgoPreamble(f *ast.File) (*ast.CommentGroup, bool) {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		_ = ok // @inco: ok && gd.Tok == token.IMPORT, -continue
+		if !(ok && gd.Tok == token.IMPORT) {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			is := spec.(*ast.ImportSpec)
+			if path, _ := strconv.Unquote(is.Path.Value); path != "C" {
+				continue
+			}
+			// As cgo reads it: the spec's own comment, or the
+			// declaration's for an ungrouped import.
+			if is.Doc != nil {
+				return is.Doc, true
+			}
+			if len(gd.Specs) == 1 {
+				return gd.Doc, true
+			}
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// preambleText returns the source text of the preamble of the file src,
+// parsed into f, or nil when it has none.
+func preambleText(fset *token.FileSet, f *ast.File, src []byte) []byte {
+	cg, _ := cgoPreamble(f)
+	_ = cg // @inco: cg != nil, -return(nil)
+	if !(cg != nil) {
+		return nil
+	}
+	return src[fset.Position(cg.Pos()).Offset:fset.Position(cg.End()).Offset]
+}
+
+// checkCgoShadow returns an error unless the shadow of the cgo file f,
+// parsed from src, still imports "C" with a byte-identical preamble. The
+// go command hands the shadow to cgo, so a preamble the injection moved,
+// split or altered would compile different C, or none.
+func checkCgoShadow(relPath string, fset *token.FileSet, f *ast.File, src, shadow []byte) error {
+	want := preambleText(fset, f, src)
+	sfset := token.NewFileSet()
+	sf, err := parser.ParseFile(sfset, relPath, shadow, parser.ImportsOnly|parser.ParseComments)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil // reported by verifyShadows
+	}
+	_, cgo := cgoPreamble(sf)
+	got := preambleText(sfset, sf, shadow)
+	_ = got // @inco: cgo && bytes.Equal(got, want), -return(fmt.Errorf("%s: cannot inject contracts into this cgo file: the shadow would not keep the C preamble before import \"C\" intact", relPath))
+	if !(cgo && bytes.Equal(got, want)) {
+		return fmt.Errorf("%s: cannot inject contracts into this cgo file: the shadow would not keep the C preamble before import \"C\" intact", relPath)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package inco
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const cgoSrc = `package main
+
+/*
+#include <stdlib.h>
+
+// @inco: x > 0
+static int twice(int x) { return 2 * x; }
+*/
+import "C"
+
+func Twice(x int) int {
+	// @inco: x >= 0, -panic(errors.New("negative"))
+	return int(C.twice(C.int(x)))
+}
+`
+
+func TestEngine_CgoPreamble(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": cgoSrc})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+
+	preamble := cgoSrc[strings.Index(cgoSrc, "/*"):strings.Index(cgoSrc, "import \"C\"")]
+	if !strings.Contains(shadow, preamble+"import \"C\"\n") {
+		t.Errorf("preamble not kept before import \"C\":\n%s", shadow)
+	}
+	if strings.Count(shadow, "if !(") != 1 || !strings.Contains(shadow, "if !(x >= 0)") {
+		t.Errorf("want only the function's contract injected:\n%s", shadow)
+	}
+	if !strings.Contains(shadow, "import \"errors\"") {
+		t.Errorf("errors import not added:\n%s", shadow)
+	}
+}
+
+func TestCheckCgoShadow(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", cgoSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		shadow string
+		ok     bool
+	}{
+		{"unchanged", cgoSrc, true},
+		{"import added after", strings.Replace(cgoSrc, "import \"C\"\n", "import \"C\"\nimport \"errors\"\n", 1), true},
+		{"preamble altered", strings.Replace(cgoSrc, "2 * x", "3 * x", 1), false},
+		{"preamble detached", strings.Replace(cgoSrc, "*/\nimport", "*/\n\nimport", 1), false},
+		{"import removed", strings.Replace(cgoSrc, "import \"C\"\n", "", 1), false},
+	}
+	for _, tt := range tests {
+		err := checkCgoShadow("main.go", fset, f, []byte(cgoSrc), []byte(tt.shadow))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok = %t", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestCgoPreamble_Grouped(t *testing.T) {
+	src := "package p\n\nimport (\n\t\"fmt\"\n\n\t// #include <stdio.h>\n\t\"C\"\n)\n"
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, cgo := cgoPreamble(f)
+	if !cgo || cg == nil || cg.Text() != "#include <stdio.h>\n" {
+		t.Errorf("cgoPreamble = %v, %t", cg, cgo)
+	}
+}
@@ -0,0 +1,196 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// ---------------------------------------------------------------------------
+// Channel contracts
+// ---------------------------------------------------------------------------
+
+// chanDecl is what the source says about a variable at a directive: its
+// declared type, if written, and the make call that created it, if any.
+type chanDecl struct {
+	typ  ast.Expr      // declared or made type; nil when unknown
+	made *ast.CallExpr // make(chan T[, n]) the variable was defined with
+}
+
+// chanType returns the declared channel type, or nil.
+func (c chanDecl) chanType() *ast.ChanType {
+	ct, _ := c.typ.(*ast.ChanType)
+	return ct
+}
+
+// declaredType returns what the enclosing functions of line declare about
+// name before line: a parameter, result, var declaration or := / =
+// definition from make. Later declarations win, so inner scopes shadow
+// outer ones. ok is false when name is not declared there.
+func declaredType(f *ast.File, fset *token.FileSet, line int, name string) (decl chanDecl, ok bool) {
+	fromFields := func(fl *ast.FieldList) {
+		_ = fl // @inco: fl != nil, -return
+		if !(fl != nil) {
+			return
+		}
+		for _, field := range fl.List {
+			for _, n := range field.Names {
+				if n.Name == name {
+					decl, ok = chanDecl{typ: field.Type}, true
+				}
+			}
+		}
+	}
+	fromValue := func(id *ast.Ident, typ, value ast.Expr) {
+		_ = id // @inco: id.Name == name, -return
+		if !(id.Name == name) {
+			return
+		}
+		decl, ok = chanDecl{typ: typ}, true
+		if call, isCall := value.(*ast.CallExpr); isCall && isIdentNamed(call.Fun, "make") && len(call.Args) > 0 {
+			decl.made = call
+			if decl.typ == nil {
+				decl.typ = call.Args[0]
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		var recv *ast.FieldList
+		var ft *ast.FuncType
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			recv, ft, body = fn.Recv, fn.Type, fn.Body
+		case *ast.FuncLit:
+			ft, body = fn.Type, fn.Body
+		default:
+			return true
+		}
+		_ = body // @inco: body != nil && srcLine(fset, body.Lbrace) <= line && line <= srcLine(fset, body.Rbrace), -return(false)
+		if !(body != nil && srcLine(fset, body.Lbrace) <= line && line <= srcLine(fset, body.Rbrace)) {
+			return false
+		}
+		fromFields(recv)
+		fromFields(ft.Params)
+		fromFields(ft.Results)
+		ast.Inspect(body, func(n ast.Node) bool {
+			_ = n // @inco: n != nil && srcLine(fset, n.Pos()) < line, -return(false)
+			if !(n != nil && srcLine(fset, n.Pos()) < line) {
+				return false
+			}
+			switch s := n.(type) {
+			case *ast.FuncLit:
+				return false // visited by the outer Inspect if it encloses line
+			case *ast.AssignStmt:
+				if s.Tok == token.DEFINE && len(s.Lhs) == len(s.Rhs) {
+					for i, lhs := range s.Lhs {
+						if id, isIdent := lhs.(*ast.Ident); isIdent {
+							fromValue(id, nil, s.Rhs[i])
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				for i, id := range s.Names {
+					var value ast.Expr
+					if i < len(s.Values) {
+						value = s.Values[i]
+					}
+					fromValue(id, s.Type, value)
+				}
+			}
+			return true
+		})
+		return true
+	})
+	return decl, ok
+}
+
+func isIdentNamed(x ast.Expr, name string) bool {
+	id, ok := x.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+// vetChannels returns problems with the channel contract d at line: a
+// direction assertion the declared type contradicts or does not enforce,
+// a receive from a send-only channel, and cap of an unbuffered channel.
+func vetChannels(f *ast.File, fset *token.FileSet, line int, d *Directive) []string {
+	switch d.Kind {
+	case KindRecvOnly, KindSendOnly:
+		decl, ok := declaredType(f, fset, line, d.Expr)
+		_ = ok // @inco: ok && decl.typ != nil, -return(nil)
+		if !(ok && decl.typ != nil) {
+			return nil
+		}
+		ct := decl.chanType()
+		if ct == nil {
+			return []string{fmt.Sprintf("%s %s: %s is %s, not a channel", directiveName(d), d.Expr, d.Expr, types.ExprString(decl.typ))}
+		}
+		want, forbidden, verb := ast.RECV, ast.SEND, "received from"
+		if d.Kind == KindSendOnly {
+			want, forbidden, verb = ast.SEND, ast.RECV, "sent to"
+		}
+		switch ct.Dir {
+		case forbidden:
+			return []string{fmt.Sprintf("%s %s: %s is %s and can never be %s (does not compile)",
+				directiveName(d), d.Expr, d.Expr, types.ExprString(ct), verb)}
+		case ast.SEND | ast.RECV:
+			restricted := &ast.ChanType{Dir: want, Value: ct.Value}
+			return []string{fmt.Sprintf("%s %s: %s is %s, which allows both directions; declare it %s so the compiler enforces this",
+				directiveName(d), d.Expr, d.Expr, types.ExprString(ct), types.ExprString(restricted))}
+		}
+		return nil
+	case KindRequire:
+	default:
+		return nil
+	}
+
+	x, err := parser.ParseExpr(d.Expr)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	var problems []string
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.UnaryExpr:
+			id, ok := n.X.(*ast.Ident)
+			if n.Op != token.ARROW || !ok {
+				return true
+			}
+			if decl, _ := declaredType(f, fset, line, id.Name); decl.chanType() != nil && decl.chanType().Dir == ast.SEND {
+				problems = append(problems, fmt.Sprintf("receives from %s, which is %s (does not compile)", id.Name, types.ExprString(decl.typ)))
+			}
+		case *ast.CallExpr:
+			if !isIdentNamed(n.Fun, "cap") || len(n.Args) != 1 {
+				return true
+			}
+			id, ok := n.Args[0].(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if decl, _ := declaredType(f, fset, line, id.Name); decl.made != nil && decl.chanType() != nil && len(decl.made.Args) == 1 {
+				problems = append(problems, fmt.Sprintf("cap(%s) is always 0: %s is made unbuffered", id.Name, id.Name))
+			}
+		}
+		return true
+	})
+	return problems
+}
+
+// chanDirection describes the direction a channel assertion requires.
+func chanDirection(d *Directive) string {
+	if d.Kind == KindSendOnly {
+		return "send-only"
+	}
+	return "receive-only"
+}
+
+// directiveName renders the channel assertion kinds as written.
+func directiveName(d *Directive) string {
+	if d.Kind == KindSendOnly {
+		return "-sendonly"
+	}
+	return "-recvonly"
+}
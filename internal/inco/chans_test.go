@@ -0,0 +1,111 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Channel contracts
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_ChanShorthands(t *testing.T) {
+	tests := []struct {
+		comment string
+		kind    DirectiveKind
+		expr    string
+		action  ActionKind
+	}{
+		{"// @inco: -buffered jobs", KindRequire, "cap(jobs) > 0", ActionPanic},
+		{"// @inco: -buffered jobs, -return(errUnbuffered)", KindRequire, "cap(jobs) > 0", ActionReturn},
+		{"// @inco: #chan -recvonly in", KindRecvOnly, "in", ActionPanic},
+		{"// @inco: -sendonly out", KindSendOnly, "out", ActionPanic},
+	}
+	for _, tt := range tests {
+		d := ParseDirective(tt.comment)
+		if d == nil {
+			t.Errorf("%s: expected directive", tt.comment)
+			continue
+		}
+		if d.Kind != tt.kind || d.Expr != tt.expr || d.Action != tt.action {
+			t.Errorf("%s: got kind=%v expr=%q action=%v", tt.comment, d.Kind, d.Expr, d.Action)
+		}
+	}
+
+	// Direction assertions are static: no guard, no action.
+	for _, comment := range []string{
+		"// @inco: -recvonly in, -return",
+		"// @inco: if(debug) -sendonly out",
+	} {
+		if d := ParseDirective(comment); d != nil {
+			t.Errorf("%s: expected nil, got %+v", comment, d)
+		}
+	}
+}
+
+const chanSrc = `package main
+
+func Pipe(in <-chan int, out chan<- int, both chan int) {
+	// @inco: -recvonly in
+	// @inco: -sendonly out
+	// @inco: -recvonly out
+	// @inco: -sendonly both
+	jobs := make(chan int)
+	// @inco: -buffered jobs
+	// @inco: <-out > 0
+	// @inco: -sendonly n
+	n := 1
+	_ = n
+}
+`
+
+func TestVet_Channels(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": chanSrc})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, strings.TrimPrefix(d.String(), dir+"/"))
+	}
+	want := []string{
+		"main.go:6:5: -recvonly out: out is chan<- int and can never be received from (does not compile)",
+		"main.go:7:5: -sendonly both: both is chan int, which allows both directions; declare it chan<- int so the compiler enforces this",
+		"main.go:9:5: cap(jobs) is always 0: jobs is made unbuffered",
+		"main.go:10:5: receives from out, which is chan<- int (does not compile)",
+		"main.go:10:5: contract <-out > 0 receives from out; a contract must not change program state",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEngine_ChanAssertions(t *testing.T) {
+	dir := setupDir(t, map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n", "main.go": `package main
+
+func Pipe(in <-chan int, out chan<- int) {
+	// @inco: -recvonly in
+	// @inco: -sendonly out
+	// @inco: -buffered out
+}
+`})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{"\tif false { <-in }\n", "\tif false { close(out) }\n", "if !(cap(out) > 0) {"} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %q:\n%s", want, shadow)
+		}
+	}
+
+	// A contradicted assertion is a compile error at the directive.
+	dir = setupDir(t, map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n", "main.go": `package main
+
+func Pipe(out chan<- int) {
+	// @inco: -recvonly out
+}
+`})
+	e = NewEngine(dir)
+	e.Typecheck = true
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, "main.go:4") || !strings.Contains(msg, "send-only") {
+		t.Errorf("expected a compile error at the directive, got: %s", msg)
+	}
+}
@@ -0,0 +1,71 @@
+package inco
+
+import (
+	"fmt"
+	"os"
+)
+
+// ---------------------------------------------------------------------------
+// Terminal colors
+// ---------------------------------------------------------------------------
+
+// Colorizer wraps text in ANSI color escapes when true, and returns it
+// unchanged when false.
+type Colorizer bool
+
+// ColorFor reports whether output to f should be colored: f is a
+// terminal, NO_COLOR is unset or empty, TERM is not "dumb" and disabled
+// (the -no-color flag) is false.
+func ColorFor(f *os.File, disabled bool) Colorizer {
+	_ = disabled // @inco: !disabled && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb", -return(false)
+	if !(!disabled && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb") {
+		return false
+	}
+	return Colorizer(IsTerminal(f))
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	_ = err // @inco: err == nil, -return(false)
+	if !(err == nil) {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (c Colorizer) paint(code, s string) string {
+	_ = c // @inco: c && s != "", -return(s)
+	if !(c && s != "") {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// Bold is for headings and locations.
+func (c Colorizer) Bold(s string) string { return c.paint("1", s) }
+
+// Dim is for secondary detail.
+func (c Colorizer) Dim(s string) string { return c.paint("2", s) }
+
+// Error is for failures and violations.
+func (c Colorizer) Error(s string) string { return c.paint("31", s) }
+
+// Warning is for problems that do not fail the command.
+func (c Colorizer) Warning(s string) string { return c.paint("33", s) }
+
+// OK is for results that need no action.
+func (c Colorizer) OK(s string) string { return c.paint("32", s) }
+
+// Percent formats pct as "57.7%", colored OK from good up, Warning from
+// fair up and Error below.
+func (c Colorizer) Percent(pct, good, fair float64) string {
+	s := fmt.Sprintf("%.1f%%", pct)
+	switch {
+	case pct >= good:
+		return c.OK(s)
+	case pct >= fair:
+		return c.Warning(s)
+	}
+	return c.Error(s)
+}
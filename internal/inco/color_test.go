@@ -0,0 +1,86 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Terminal colors
+// ---------------------------------------------------------------------------
+
+func TestColorizer(t *testing.T) {
+	if got := Colorizer(false).Error("failed"); got != "failed" {
+		t.Errorf("disabled Error = %q, want the text unchanged", got)
+	}
+	if got := Colorizer(true).Error("failed"); got != "\x1b[31mfailed\x1b[0m" {
+		t.Errorf("Error = %q", got)
+	}
+	if got := Colorizer(true).Bold(""); got != "" {
+		t.Errorf("Bold(\"\") = %q, want no escapes", got)
+	}
+	tests := []struct {
+		pct  float64
+		want string
+	}{
+		{80, "\x1b[32m80.0%\x1b[0m"},
+		{50, "\x1b[33m50.0%\x1b[0m"},
+		{12.34, "\x1b[31m12.3%\x1b[0m"},
+	}
+	for _, tt := range tests {
+		if got := Colorizer(true).Percent(tt.pct, 75, 50); got != tt.want {
+			t.Errorf("Percent(%v) = %q, want %q", tt.pct, got, tt.want)
+		}
+	}
+}
+
+func TestColorFor(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if ColorFor(f, false) {
+		t.Error("ColorFor(regular file) = true, want false")
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip("no terminal:", err)
+	}
+	defer tty.Close()
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	if !ColorFor(tty, false) {
+		t.Error("ColorFor(terminal) = false, want true")
+	}
+	if ColorFor(tty, true) {
+		t.Error("ColorFor(terminal, -no-color) = true, want false")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if ColorFor(tty, false) {
+		t.Error("ColorFor(terminal) with NO_COLOR = true, want false")
+	}
+}
+
+func TestTextRenderer_Color(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": renderSrc})
+	r := Audit(dir, Limits{})
+	var plain, colored strings.Builder
+	if err := (TextRenderer{}).Render(&plain, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := (TextRenderer{Color: true}).Render(&colored, r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("uncolored report has escapes:\n%s", plain.String())
+	}
+	for _, want := range []string{"\x1b[1m@inco: coverage:\x1b[0m", "(\x1b[33m66.7%\x1b[0m)", "\x1b[33mmain.go:10: constant message"} {
+		if !strings.Contains(colored.String(), want) {
+			t.Errorf("colored report lacks %q:\n%s", want, colored.String())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package inco
+
+import (
+	"fmt"
+	"go/token"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Release compatibility: inco release-check
+// ---------------------------------------------------------------------------
+
+// BreakingChanges keeps the changes of DiffContracts that break callers of
+// a released API: preconditions added, which callers may now violate, and
+// postconditions removed, which callers may rely on. A contract whose
+// expression changed counts as removed and added, so a changed
+// precondition is reported too. Only exported functions of importable
+// packages are held to their contracts — main and internal packages are
+// not — and with patterns ("./api/...", "./db") only the packages they
+// match.
+func BreakingChanges(changes []FuncChange, patterns []string) []FuncChange {
+	var out []FuncChange
+	for _, fc := range changes {
+		_ = fc // @inco: isAPI(fc), -continue
+		if !(isAPI(fc)) {
+			continue
+		}
+		held := len(patterns) == 0 || slices.ContainsFunc(patterns, func(pattern string) bool {
+			return matchPackage(pattern, fc.Dir)
+		})
+		_ = held // @inco: held, -continue
+		if !(held) {
+			continue
+		}
+		var breaking []ContractChange
+		for _, c := range fc.Changes {
+			if c.Op == "added" && c.Section == "precondition" || c.Op == "removed" && c.Section == "postcondition" {
+				breaking = append(breaking, c)
+			}
+		}
+		if len(breaking) > 0 {
+			fc.Changes = breaking
+			out = append(out, fc)
+		}
+	}
+	return out
+}
+
+// isAPI reports whether the function of fc is part of its package's API:
+// an exported function, or an exported method of an exported type, in a
+// package other importable code may use.
+func isAPI(fc FuncChange) bool {
+	internal := slices.Contains(strings.Split(path.Clean(fc.Dir), "/"), "internal")
+	_ = fc // @inco: fc.Pkg != "main" && !internal, -return(false)
+	if !(fc.Pkg != "main" && !internal) {
+		return false
+	}
+	for _, name := range strings.Split(fc.Func, ".") {
+		if !token.IsExported(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// LastTag returns the most recent git tag reachable from HEAD in dir.
+func LastTag(dir string) (string, error) {
+	out, err := runGit(dir, "describe", "--tags", "--abbrev=0")
+	_ = err // @inco: err == nil, -return("", fmt.Errorf("no tag to compare with: %w", err))
+	if !(err == nil) {
+		return "", fmt.Errorf("no tag to compare with: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
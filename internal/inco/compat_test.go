@@ -0,0 +1,73 @@
+package inco
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Release compatibility
+// ---------------------------------------------------------------------------
+
+func TestBreakingChanges(t *testing.T) {
+	changes := []FuncChange{
+		{Pkg: "api", Dir: "api", Func: "Create", Changes: []ContractChange{
+			{Op: "added", Section: "precondition", Contract: "n > 0"},        // breaks
+			{Op: "removed", Section: "precondition", Contract: "id != \"\""}, // weaker: fine
+			{Op: "changed", Section: "precondition", Contract: "ok"},         // behavior only
+			{Op: "added", Section: "postcondition", Contract: "err == nil"},  // stronger: fine
+			{Op: "removed", Section: "postcondition", Contract: "f != nil"},  // breaks
+		}},
+		{Pkg: "api", Dir: "api", Func: "Server.Handle", Changes: []ContractChange{{Op: "added", Section: "precondition", Contract: "r != nil"}}},
+		{Pkg: "api", Dir: "api", Func: "server.Handle", Changes: []ContractChange{{Op: "added", Section: "precondition", Contract: "r != nil"}}},
+		{Pkg: "api", Dir: "api", Func: "create", Changes: []ContractChange{{Op: "added", Section: "precondition", Contract: "x"}}},
+		{Pkg: "api", Dir: "api", Func: "Loop", Changes: []ContractChange{{Op: "removed", Section: "loop invariant", Contract: "i < n"}}},
+		{Pkg: "db", Dir: "internal/db", Func: "Open", Changes: []ContractChange{{Op: "added", Section: "precondition", Contract: "x"}}},
+		{Pkg: "main", Dir: "cmd/app", Func: "Run", Changes: []ContractChange{{Op: "added", Section: "precondition", Contract: "x"}}},
+		{Pkg: "beta", Dir: "exp/beta", Func: "Try", Changes: []ContractChange{{Op: "added", Section: "precondition", Contract: "x"}}},
+	}
+
+	got := BreakingChanges(changes, nil)
+	if len(got) != 3 || got[0].Func != "Create" || got[1].Func != "Server.Handle" || got[2].Func != "Try" {
+		t.Fatalf("BreakingChanges = %+v, want Create, Server.Handle and Try", got)
+	}
+	if c := got[0].Changes; len(c) != 2 || c[0].Contract != "n > 0" || c[1].Contract != "f != nil" {
+		t.Errorf("Create breaking changes = %+v, want n > 0 added and f != nil removed", c)
+	}
+	if len(changes[0].Changes) != 5 {
+		t.Error("BreakingChanges modified its argument")
+	}
+
+	got = BreakingChanges(changes, []string{"./api/..."})
+	if len(got) != 2 || got[1].Func != "Server.Handle" {
+		t.Errorf("BreakingChanges(./api/...) = %+v, want Create and Server.Handle", got)
+	}
+}
+
+func TestLastTag(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := setupDir(t, map[string]string{"a.go": "package a\n"})
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t", "-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "initial")
+	if _, err := LastTag(dir); err == nil {
+		t.Error("LastTag without tags succeeded")
+	}
+	git("tag", "v1.0.0")
+	git("commit", "-q", "--allow-empty", "-m", "next")
+	git("tag", "v1.1.0")
+	git("commit", "-q", "--allow-empty", "-m", "unreleased")
+	if tag, err := LastTag(dir); err != nil || tag != "v1.1.0" {
+		t.Errorf("LastTag = %q, %v; want v1.1.0", tag, err)
+	}
+}
@@ -0,0 +1,115 @@
+package inco
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/imnive-design/inco-go/overlay"
+)
+
+// ---------------------------------------------------------------------------
+// Composed overlays: the overlays of other code generators
+// ---------------------------------------------------------------------------
+
+// loadGenerated reads and merges the overlay files of e.Overlays, written
+// by code generators whose output exists only in them (protoc plugins
+// writing outside the tree, for instance). Relative paths in an overlay
+// file are relative to its directory. Two overlays replacing one file
+// differently is an error.
+func (e *Engine) loadGenerated() map[string]string {
+	var overlays []*overlay.Overlay
+	for _, path := range e.Overlays {
+		ov, err := overlay.Load(path)
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("composing overlays: %w", err))
+		if !(err == nil) {
+			panic(fmt.Errorf("composing overlays: %w", err))
+		}
+		abs := &overlay.Overlay{Replace: make(map[string]string, len(ov.Replace))}
+		for src, repl := range ov.Replace {
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(filepath.Dir(path), src)
+			}
+			if repl != "" && !filepath.IsAbs(repl) { // "" deletes src
+				repl = filepath.Join(filepath.Dir(path), repl)
+			}
+			abs.Replace[src] = repl
+		}
+		overlays = append(overlays, abs)
+	}
+	merged, err := overlay.Merge(overlays...)
+	_ = err // @inco: err == nil, -panic(fmt.Errorf("composing overlays: %w", err))
+	if !(err == nil) {
+		panic(fmt.Errorf("composing overlays: %w", err))
+	}
+	return merged.Replace
+}
+
+// composedOverlay returns e.Overlay with the generated files of e.Overlays
+// added. A file inco instruments must not be replaced by another
+// generator as well: one of them would be ignored.
+func (e *Engine) composedOverlay() Overlay {
+	composed := Overlay{Replace: make(map[string]string, len(e.Overlay.Replace)+len(e.generated))}
+	for src, shadow := range e.Overlay.Replace {
+		composed.Replace[src] = shadow
+	}
+	for src, repl := range e.generated {
+		_, instrumented := composed.Replace[src]
+		_ = instrumented // @inco: !instrumented, -panic(fmt.Errorf("composing overlays: inco instruments %s, which another overlay replaces with %q", e.relPath(src), repl))
+		if !(!instrumented) {
+			panic(fmt.Errorf("composing overlays: inco instruments %s, which another overlay replaces with %q", e.relPath(src), repl))
+		}
+		composed.Replace[src] = repl
+	}
+	return composed
+}
+
+// generatedOverlayFile writes the generated files of e.Overlays to a
+// temporary overlay file for the go commands inco runs itself, and
+// returns its path and the function removing it. The path is "" without
+// generated files.
+func (e *Engine) generatedOverlayFile() (string, func()) {
+	_ = e.generated // @inco: len(e.generated) > 0, -return("", func() {})
+	if !(len(e.generated) > 0) {
+		return "", func() {}
+	}
+	data, err := json.Marshal(Overlay{Replace: e.generated})
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	f, err := os.CreateTemp("", "inco-generated-*.json")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	return f.Name(), func() { os.Remove(f.Name()) }
+}
+
+// generatedContents returns the content of each generated file of
+// e.Overlays, for typechecking with them. Deleted files are left out.
+func (e *Engine) generatedContents() map[string][]byte {
+	contents := make(map[string][]byte, len(e.generated))
+	for src, repl := range e.generated {
+		_ = repl // @inco: repl != "", -continue
+		if !(repl != "") {
+			continue
+		}
+		data, err := os.ReadFile(repl)
+		_ = err // @inco: err == nil, -panic(fmt.Errorf("composing overlays: %s: %w", src, err))
+		if !(err == nil) {
+			panic(fmt.Errorf("composing overlays: %s: %w", src, err))
+		}
+		contents[src] = data
+	}
+	return contents
+}
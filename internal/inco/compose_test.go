@@ -0,0 +1,81 @@
+package inco
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Composed overlays
+// ---------------------------------------------------------------------------
+
+// setupGenerated sets up a module whose main package imports the package
+// gen/pb, which exists only in the overlay file gen.json.
+func setupGenerated(t *testing.T) string {
+	t.Helper()
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module testmod\n\ngo 1.21\n",
+		"main.go": `package main
+
+import "testmod/gen/pb"
+
+func Do(m *pb.Msg) {
+	_ = m // @inco: m != nil && m.ID > 0
+}
+
+func main() { Do(&pb.Msg{ID: 1}) }
+`,
+	})
+	// Not a .go file, so that only the overlay makes it part of a package.
+	writeFile(t, filepath.Join(dir, "out", "msg.pb.go.gen"), "package pb\n\ntype Msg struct{ ID int }\n")
+	writeFile(t, filepath.Join(dir, "gen.json"), `{"Replace": {"gen/pb/msg.pb.go": "out/msg.pb.go.gen"}}`)
+	return dir
+}
+
+func TestEngine_ComposesOverlays(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := setupGenerated(t)
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Typecheck = true
+	e.Overlays = []string{filepath.Join(dir, "gen.json")}
+	e.Run()
+
+	data, err := os.ReadFile(filepath.Join(e.CacheDir, "overlay.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ov Overlay
+	if err := json.Unmarshal(data, &ov); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ov.Replace[filepath.Join(dir, "gen", "pb", "msg.pb.go")], filepath.Join(dir, "out", "msg.pb.go.gen"); got != want {
+		t.Errorf("generated file maps to %q, want %q", got, want)
+	}
+	if _, ok := ov.Replace[filepath.Join(dir, "main.go")]; !ok {
+		t.Error("overlay.json lacks the shadow of main.go")
+	}
+
+	cmd := exec.Command("go", "build", "-overlay="+filepath.Join(e.CacheDir, "overlay.json"), "-o", os.DevNull, ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build with the composed overlay: %v\n%s", err, out)
+	}
+}
+
+func TestEngine_ComposedOverlayConflicts(t *testing.T) {
+	dir := setupGenerated(t)
+	writeFile(t, filepath.Join(dir, "other.json"), `{"Replace": {"main.go": "out/msg.pb.go.gen"}}`)
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Overlays = []string{filepath.Join(dir, "gen.json"), filepath.Join(dir, "other.json")}
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, "inco instruments main.go") {
+		t.Errorf("panic = %q, want the instrumented file named", msg)
+	}
+}
@@ -0,0 +1,111 @@
+package inco
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Directive continuation lines
+// ---------------------------------------------------------------------------
+
+// joinContinuations merges each directive in f that is continued over the
+// following line comments of its group into a single comment, so that
+// long contracts can be wrapped:
+//
+//	// @inco: len(items) > 0 && \
+//	//     items[0].ID != "", \
+//	//     -panic("first item incomplete")
+//	// @inco: a != nil &&
+//	// ... a.Ready()
+//
+// A line comment continues the directive above it when that one ends with
+// a backslash, which is dropped, or when its own text starts with "... ",
+// which is dropped too. The parts are joined with single spaces. The
+// merged comment keeps the position of the directive's first line and
+// the continuation lines are removed from the group, so every consumer of
+// f sees one directive per first line. It returns the merged comments.
+func joinContinuations(f *ast.File, fset *token.FileSet) map[*ast.Comment]bool {
+	// A trailing comment is a group of its own, so an inline directive
+	// continues into the next group: walk all comments in order.
+	var all []*ast.Comment
+	for _, cg := range f.Comments {
+		all = append(all, cg.List...)
+	}
+	joined := make(map[*ast.Comment]bool)
+	removed := make(map[*ast.Comment]bool)
+	var code map[int]bool // lines holding code, computed on first use
+	for i := 0; i < len(all); i++ {
+		c := all[i]
+		_ = c // @inco: strings.HasPrefix(c.Text, "//") && hasDirectivePrefix(stripComment(c.Text)), -continue
+		if !(strings.HasPrefix(c.Text, "//") && hasDirectivePrefix(stripComment(c.Text))) {
+			continue
+		}
+		text, line := c.Text, srcLine(fset, c.Pos())
+		for i+1 < len(all) {
+			next := all[i+1]
+			rest, ok := continuationOf(text, next.Text)
+			if !ok || srcLine(fset, next.Pos()) != line+1 {
+				break
+			}
+			if code == nil {
+				code = codeLines(f, fset)
+			}
+			if code[line+1] {
+				break // a trailing comment of the next line's code
+			}
+			text = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(text, " \t"), `\`), " \t") + " " + rest
+			removed[next] = true
+			line++
+			i++
+		}
+		if text != c.Text {
+			c.Text = text
+			joined[c] = true
+		}
+	}
+	_ = removed // @inco: len(removed) > 0, -return(joined)
+	if !(len(removed) > 0) {
+		return joined
+	}
+	groups := f.Comments[:0]
+	for _, cg := range f.Comments {
+		cg.List = slices.DeleteFunc(cg.List, func(c *ast.Comment) bool { return removed[c] })
+		if len(cg.List) > 0 {
+			groups = append(groups, cg)
+		}
+	}
+	f.Comments = groups
+	return joined
+}
+
+// codeLines returns the lines of f on which a syntax node starts or ends.
+func codeLines(f *ast.File, fset *token.FileSet) map[int]bool {
+	lines := make(map[int]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.CommentGroup, *ast.Comment:
+			return false
+		}
+		lines[srcLine(fset, n.Pos())] = true
+		lines[srcLine(fset, n.End())] = true
+		return true
+	})
+	return lines
+}
+
+// continuationOf returns the text next contributes when it continues the
+// directive comment text, and whether it does.
+func continuationOf(text, next string) (string, bool) {
+	_ = next // @inco: strings.HasPrefix(next, "//"), -return("", false)
+	if !(strings.HasPrefix(next, "//")) {
+		return "", false
+	}
+	body := strings.TrimSpace(strings.TrimPrefix(next, "//"))
+	if rest, ok := strings.CutPrefix(body, "... "); ok {
+		return strings.TrimSpace(rest), true
+	}
+	return body, strings.HasSuffix(strings.TrimRight(text, " \t"), `\`)
+}
@@ -0,0 +1,86 @@
+package inco
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const continuationSrc = `package main
+
+import "fmt"
+
+func Order(items []string, n int) {
+	// @inco: len(items) > 0 && \
+	//     items[0] != "", \
+	//     -panic("first item missing")
+	// @inco: n > 0 &&
+	// ... n <= len(items),
+	// ... -panic(fmt.Sprintf("bad count %d", n))
+	x := n // @inco: x != 3 && \
+	// x != 4
+	_ = x
+	// A comment ending in a backslash is not a directive: \
+	// so this line stays.
+}
+`
+
+func TestJoinContinuations(t *testing.T) {
+	fset := token.NewFileSet()
+	src := continuationSrc + `
+func Print(n int) {
+	// @inco: n != 9, \
+	fmt.Println(n) // not a continuation
+}
+`
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := joinContinuations(f, fset)
+	if len(joined) != 3 {
+		t.Errorf("joined %d comments, want 3", len(joined))
+	}
+	var got []string
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			got = append(got, c.Text)
+		}
+	}
+	want := []string{
+		`// @inco: len(items) > 0 && items[0] != "", -panic("first item missing")`,
+		`// @inco: n > 0 && n <= len(items), -panic(fmt.Sprintf("bad count %d", n))`,
+		`// @inco: x != 3 && x != 4`,
+		`// A comment ending in a backslash is not a directive: \`,
+		`// so this line stays.`,
+		`// @inco: n != 9, \`,
+		`// not a continuation`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("comments =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEngine_ContinuedDirectives(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": continuationSrc})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{
+		`if !(len(items) > 0 && items[0] != "") {`,
+		`panic("first item missing")`,
+		`if !(n > 0 && n <= len(items)) {`,
+		`if !(x != 3 && x != 4) {`,
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow lacks %q:\n%s", want, shadow)
+		}
+	}
+	if diags := Vet(dir); len(diags) != 0 {
+		t.Errorf("vet: %v", diags)
+	}
+	if r := Audit(dir, Limits{}); r.TotalRequires != 3 {
+		t.Errorf("TotalRequires = %d, want 3", r.TotalRequires)
+	}
+}
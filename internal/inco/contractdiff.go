@@ -0,0 +1,230 @@
+package inco
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Contract diff: inco contracts diff <old> [<new>]
+// ---------------------------------------------------------------------------
+
+// FuncChange lists how the contracts of one function differ between two
+// exports.
+type FuncChange struct {
+	Pkg     string           `json:"package"` // package name
+	Dir     string           `json:"dir"`     // relative to root, "." for the root package
+	Func    string           `json:"func"`    // Recv.Method for methods
+	Path    string           `json:"path"`    // file in the newer export, else in the older
+	Line    int              `json:"line"`
+	Changes []ContractChange `json:"changes"`
+}
+
+// ContractChange is one contract added, removed or changed. A contract is
+// identified by its section, guard and expression; a change keeps those
+// and alters what a violation does or the tags.
+type ContractChange struct {
+	Op       string `json:"op"`      // "added", "removed" or "changed"
+	Section  string `json:"section"` // "precondition", "postcondition" or "loop invariant"
+	Contract string `json:"contract"`
+	Old      string `json:"old,omitempty"` // removed, changed: the violation behavior and tags before
+	New      string `json:"new,omitempty"` // added, changed: after
+}
+
+// DiffContracts compares the contracts of two exports function by
+// function and returns the functions whose contracts differ, sorted by
+// directory and name. Moving a contract or its function does not count.
+func DiffContracts(old, new []PackageDoc) []FuncChange {
+	type entry struct {
+		pkg           string
+		before, after FuncDoc // zero when the function has no contracts there
+		doc           FuncDoc // after, or before when the function has none after
+	}
+	funcs := make(map[string]*entry) // dir + "\x00" + func
+	for i, docs := range [][]PackageDoc{old, new} {
+		for _, p := range docs {
+			for _, fd := range p.Funcs {
+				key := p.Dir + "\x00" + fd.Name
+				e := funcs[key]
+				if e == nil {
+					e = &entry{}
+					funcs[key] = e
+				}
+				e.pkg, e.doc = p.Name, fd
+				if i == 0 {
+					e.before = fd
+				} else {
+					e.after = fd
+				}
+			}
+		}
+	}
+
+	var out []FuncChange
+	for key, e := range funcs {
+		before, after := e.before, e.after
+		var changes []ContractChange
+		for _, s := range []struct {
+			name          string
+			before, after []ContractDoc
+		}{
+			{"precondition", before.Pre, after.Pre},
+			{"postcondition", before.Post, after.Post},
+			{"loop invariant", before.Inv, after.Inv},
+		} {
+			changes = append(changes, diffSection(s.name, s.before, s.after)...)
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		dir, _, _ := strings.Cut(key, "\x00")
+		out = append(out, FuncChange{Pkg: e.pkg, Dir: dir, Func: e.doc.Name, Path: e.doc.Path, Line: e.doc.Line, Changes: changes})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Dir != out[j].Dir {
+			return out[i].Dir < out[j].Dir
+		}
+		return out[i].Func < out[j].Func
+	})
+	return out
+}
+
+// diffSection compares one section of a function's contracts. Identical
+// contracts are paired in order, so a duplicate added or removed counts
+// once.
+func diffSection(section string, before, after []ContractDoc) []ContractChange {
+	id := func(c ContractDoc) string {
+		if c.Cond != "" {
+			return "if(" + c.Cond + ") " + c.Expr
+		}
+		return c.Expr
+	}
+	behavior := func(c ContractDoc) string {
+		if len(c.Tags) == 0 {
+			return c.OnViolation
+		}
+		return c.OnViolation + "  #" + strings.Join(c.Tags, " #")
+	}
+	unmatched := make(map[string][]ContractDoc)
+	for _, c := range before {
+		unmatched[id(c)] = append(unmatched[id(c)], c)
+	}
+	var changes []ContractChange
+	for _, c := range after {
+		olds := unmatched[id(c)]
+		if len(olds) == 0 {
+			changes = append(changes, ContractChange{Op: "added", Section: section, Contract: id(c), New: behavior(c)})
+			continue
+		}
+		unmatched[id(c)] = olds[1:]
+		if behavior(olds[0]) != behavior(c) {
+			changes = append(changes, ContractChange{Op: "changed", Section: section, Contract: id(c), Old: behavior(olds[0]), New: behavior(c)})
+		}
+	}
+	for _, c := range before {
+		if olds := unmatched[id(c)]; len(olds) > 0 {
+			unmatched[id(c)] = olds[1:]
+			changes = append(changes, ContractChange{Op: "removed", Section: section, Contract: id(c), Old: behavior(c)})
+		}
+	}
+	return changes
+}
+
+// WriteContractDiff writes changes as a changelog, one block per
+// function:
+//
+//	api.Server.Create  (api/server.go:12)
+//	  + precondition  len(req.Name) > 0  panics
+//	  - precondition  req.ID != ""  panics
+//	  ~ precondition  n > 0  panics → returns `0, ErrN`
+func WriteContractDiff(w io.Writer, changes []FuncChange) {
+	signs := map[string]string{"added": "+", "removed": "-", "changed": "~"}
+	for i, fc := range changes {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s.%s  (%s:%d)\n", fc.Pkg, fc.Func, fc.Path, fc.Line)
+		for _, c := range fc.Changes {
+			behavior := c.New
+			switch c.Op {
+			case "removed":
+				behavior = c.Old
+			case "changed":
+				behavior = c.Old + " → " + c.New
+			}
+			fmt.Fprintf(w, "  %s %s  %s  %s\n", signs[c.Op], c.Section, c.Contract, behavior)
+		}
+	}
+}
+
+// ExportRef is Export for dir as of the git revision ref: the Go sources
+// and .incoignore files under dir at ref are extracted to a temporary
+// directory, which is removed afterwards. dir must be inside a git work
+// tree.
+func ExportRef(dir, ref string) ([]PackageDoc, error) {
+	// Run in dir, git archive takes its subtree only, relative to dir.
+	archive, err := runGit(dir, "archive", "--format=tar", ref)
+	_ = err // @inco: err == nil, -return(nil, fmt.Errorf("%s: %w", ref, err))
+	if !(err == nil) {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	tmp, err := os.MkdirTemp("", "inco-contracts-")
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		_ = err // @inco: err == nil, -return(nil, fmt.Errorf("%s: %w", ref, err))
+		if !(err == nil) {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		name := filepath.FromSlash(hdr.Name)
+		keep := hdr.Typeflag == tar.TypeReg && filepath.IsLocal(name) && (strings.HasSuffix(name, ".go") || filepath.Base(name) == ".incoignore")
+		_ = keep // @inco: keep, -continue
+		if !(keep) {
+			continue
+		}
+		path := filepath.Join(tmp, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err == nil {
+			err = os.WriteFile(path, data, 0o644)
+		}
+		_ = err // @inco: err == nil, -return(nil, err)
+		if !(err == nil) {
+			return nil, err
+		}
+	}
+	return Export(tmp), nil
+}
+
+// runGit runs git with args in dir and returns its output. The error
+// carries what git printed to stderr.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && stderr.Len() > 0 {
+		err = errors.New(strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
@@ -0,0 +1,115 @@
+package inco
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Contract diff
+// ---------------------------------------------------------------------------
+
+func TestDiffContracts(t *testing.T) {
+	pre := func(expr, onViolation string, tags ...string) ContractDoc {
+		return ContractDoc{Expr: expr, OnViolation: onViolation, Tags: tags}
+	}
+	old := []PackageDoc{{Name: "api", Dir: "api", Funcs: []FuncDoc{
+		{Name: "Create", Path: "api/api.go", Line: 3, Pre: []ContractDoc{pre("id != \"\"", "panics"), pre("n > 0", "panics"), pre("ok", "panics")}},
+		{Name: "Gone", Path: "api/api.go", Line: 9, Pre: []ContractDoc{pre("x", "panics")}},
+	}}}
+	new := []PackageDoc{{Name: "api", Dir: "api", Funcs: []FuncDoc{
+		{Name: "Create", Path: "api/create.go", Line: 5, Pre: []ContractDoc{pre("ok", "panics"), pre("n > 0", "returns `0, ErrN`", "slow"), pre("len(name) > 0", "panics")}},
+		{Name: "New", Path: "api/api.go", Line: 20, Post: []ContractDoc{pre("err == nil", "panics")}},
+	}}}
+
+	got := DiffContracts(old, new)
+	if len(got) != 3 || got[0].Func != "Create" || got[1].Func != "Gone" || got[2].Func != "New" {
+		t.Fatalf("DiffContracts = %+v, want Create, Gone and New", got)
+	}
+	if got[0].Path != "api/create.go" || got[1].Path != "api/api.go" {
+		t.Errorf("paths = %s, %s; want the newer file, else the older", got[0].Path, got[1].Path)
+	}
+	want := []ContractChange{
+		{Op: "changed", Section: "precondition", Contract: "n > 0", Old: "panics", New: "returns `0, ErrN`  #slow"},
+		{Op: "added", Section: "precondition", Contract: "len(name) > 0", New: "panics"},
+		{Op: "removed", Section: "precondition", Contract: `id != ""`, Old: "panics"},
+	}
+	if len(got[0].Changes) != len(want) {
+		t.Fatalf("Create changes = %+v, want %+v", got[0].Changes, want)
+	}
+	for i, c := range want {
+		if got[0].Changes[i] != c {
+			t.Errorf("Create change %d = %+v, want %+v", i, got[0].Changes[i], c)
+		}
+	}
+	if c := got[2].Changes; len(c) != 1 || c[0].Op != "added" || c[0].Section != "postcondition" {
+		t.Errorf("New changes = %+v, want one added postcondition", c)
+	}
+	if len(DiffContracts(old, old)) != 0 {
+		t.Error("DiffContracts(old, old) is not empty")
+	}
+
+	var b strings.Builder
+	WriteContractDiff(&b, got[:1])
+	for _, line := range []string{
+		"api.Create  (api/create.go:5)",
+		"  ~ precondition  n > 0  panics → returns `0, ErrN`  #slow",
+		"  + precondition  len(name) > 0  panics",
+		`  - precondition  id != ""  panics`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("WriteContractDiff output lacks %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestExportRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"sub/sub.go": `package sub
+
+func Div(a, b int) int {
+	// @inco: b != 0
+	return a / b
+}
+`,
+	})
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "initial")
+
+	path := filepath.Join(dir, "sub", "sub.go")
+	src, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(src), "b != 0", "b > 0", 1)), 0o644)
+
+	sub := filepath.Join(dir, "sub")
+	old, err := ExportRef(sub, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(old) != 1 || old[0].Dir != "." || len(old[0].Funcs) != 1 || old[0].Funcs[0].Pre[0].Expr != "b != 0" {
+		t.Fatalf("ExportRef(sub, HEAD) = %+v, want Div with b != 0", old)
+	}
+	got := DiffContracts(old, Export(sub))
+	if len(got) != 1 || len(got[0].Changes) != 2 || got[0].Changes[0].Op != "added" || got[0].Changes[1].Op != "removed" {
+		t.Errorf("diff against the working tree = %+v, want b > 0 added and b != 0 removed", got)
+	}
+
+	if _, err := ExportRef(sub, "no-such-ref"); err == nil {
+		t.Error("ExportRef with an unknown revision succeeded")
+	}
+}
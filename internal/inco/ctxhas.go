@@ -0,0 +1,129 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Context value contracts: ctxhas(key)
+// ---------------------------------------------------------------------------
+
+// ctxHasName is the pseudo-function asserting that a context carries a
+// value: ctxhas(key) expands to ctx.Value(key) != nil, where ctx is the
+// enclosing function's context.Context parameter, or r.Context() for a
+// *http.Request parameter r. ctxhas(c, key) names the context c itself.
+const ctxHasName = "ctxhas"
+
+// ctxHasCall reports whether x is a call of ctxhas.
+func ctxHasCall(x ast.Node) (*ast.CallExpr, bool) {
+	call, ok := x.(*ast.CallExpr)
+	return call, ok && isIdentNamed(call.Fun, ctxHasName)
+}
+
+// expandCtxHas returns s with each ctxhas call expanded against the
+// context expression ctx ("" when the function has none). A call that is
+// not the whole of s is parenthesized. s is returned unchanged when it
+// holds no call or does not parse.
+func expandCtxHas(s, ctx string) (string, error) {
+	_ = s // @inco: strings.Contains(s, ctxHasName+"("), -return(s, nil)
+	if !(strings.Contains(s, ctxHasName+"(")) {
+		return s, nil
+	}
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return(s, nil)
+	if !(err == nil) {
+		return s, nil
+	}
+	var calls []*ast.CallExpr
+	ast.Inspect(x, func(n ast.Node) bool {
+		if call, ok := ctxHasCall(n); ok {
+			calls = append(calls, call)
+			return false
+		}
+		return true
+	})
+	// The parsed expression starts at offset 1 (token.Pos base).
+	text := func(n ast.Node) string { return s[n.Pos()-1 : n.End()-1] }
+	out := s
+	for _, call := range slices.Backward(calls) {
+		c, key := ctx, ""
+		switch len(call.Args) {
+		case 1:
+			key = text(call.Args[0])
+		case 2:
+			c, key = text(call.Args[0]), text(call.Args[1])
+		default:
+			return s, fmt.Errorf("%s: ctxhas takes a key, or a context and a key", text(call))
+		}
+		_ = c // @inco: c != "", -return(s, fmt.Errorf("%s: the enclosing function has no context.Context or *http.Request parameter; write ctxhas(ctx, %s)", text(call), key))
+		if !(c != "") {
+			return s, fmt.Errorf("%s: the enclosing function has no context.Context or *http.Request parameter; write ctxhas(ctx, %s)", text(call), key)
+		}
+		expanded := fmt.Sprintf("%s.Value(%s) != nil", c, key)
+		if ast.Expr(call) != ast.Unparen(x) {
+			expanded = "(" + expanded + ")"
+		}
+		out = out[:call.Pos()-1] + expanded + out[call.End()-1:]
+	}
+	return out, nil
+}
+
+// contextParam returns the context of the innermost function enclosing
+// line: the name of its first context.Context parameter, else
+// name.Context() for its first *http.Request parameter, else "".
+func contextParam(f *ast.File, fset *token.FileSet, line int) string {
+	ft, _ := enclosingFunc(f, fset, line)
+	_ = ft // @inco: ft != nil && ft.Params != nil, -return("")
+	if !(ft != nil && ft.Params != nil) {
+		return ""
+	}
+	imports := make(map[string]string) // import path → local name
+	for name, path := range fileImportNames(f) {
+		imports[path] = name
+	}
+	isType := func(t ast.Expr, path, name string) bool {
+		sel, ok := t.(*ast.SelectorExpr)
+		return ok && imports[path] != "" && isIdentNamed(sel.X, imports[path]) && sel.Sel.Name == name
+	}
+	request := ""
+	for _, field := range ft.Params.List {
+		for _, n := range field.Names {
+			if n.Name == "_" {
+				continue
+			}
+			if isType(field.Type, "context", "Context") {
+				return n.Name
+			}
+			if star, ok := field.Type.(*ast.StarExpr); ok && request == "" && isType(star.X, "net/http", "Request") {
+				request = n.Name + ".Context()"
+			}
+		}
+	}
+	return request
+}
+
+// resolveCtxHas returns d with its ctxhas calls expanded (d itself when it
+// has none). It panics when a call has no context to read.
+func (e *Engine) resolveCtxHas(d *Directive, f *ast.File, fset *token.FileSet, path string, line int) *Directive {
+	_ = d // @inco: strings.Contains(d.Expr+d.Cond, ctxHasName+"("), -return(d)
+	if !(strings.Contains(d.Expr+d.Cond, ctxHasName+"(")) {
+		return d
+	}
+	ctx := contextParam(f, fset, line)
+	resolved := *d
+	var err error
+	resolved.Expr, err = expandCtxHas(d.Expr, ctx)
+	if err == nil {
+		resolved.Cond, err = expandCtxHas(d.Cond, ctx)
+	}
+	_ = err // @inco: err == nil, -panic(e.directiveError(path, line, err))
+	if !(err == nil) {
+		panic(e.directiveError(path, line, err))
+	}
+	return &resolved
+}
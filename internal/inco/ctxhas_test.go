@@ -0,0 +1,96 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Context value contracts
+// ---------------------------------------------------------------------------
+
+func TestExpandCtxHas(t *testing.T) {
+	tests := []struct {
+		expr, ctx string
+		want      string
+	}{
+		{"ctxhas(auth.UserKey)", "ctx", "ctx.Value(auth.UserKey) != nil"},
+		{"ctxhas(userKey{})", "r.Context()", "r.Context().Value(userKey{}) != nil"},
+		{"ctxhas(a) && ctxhas(b)", "ctx", "(ctx.Value(a) != nil) && (ctx.Value(b) != nil)"},
+		{"ctxhas(parent, key)", "", "parent.Value(key) != nil"},
+		{"n > 0", "", "n > 0"},
+	}
+	for _, tt := range tests {
+		got, err := expandCtxHas(tt.expr, tt.ctx)
+		if err != nil || got != tt.want {
+			t.Errorf("expandCtxHas(%q, %q) = %q, %v; want %q", tt.expr, tt.ctx, got, err, tt.want)
+		}
+	}
+	if _, err := expandCtxHas("ctxhas(k)", ""); err == nil || !strings.Contains(err.Error(), "no context.Context or *http.Request parameter") {
+		t.Errorf("without context: err = %v", err)
+	}
+	if _, err := expandCtxHas("ctxhas()", "ctx"); err == nil {
+		t.Error("ctxhas(): expected an error")
+	}
+}
+
+const ctxHasSrc = `package api
+
+import (
+	"context"
+	"net/http"
+)
+
+func Load(ctx context.Context, id string) {
+	// @inco: ctxhas(auth.UserKey), -panic("no user")
+	_ = id
+}
+
+func Serve(w http.ResponseWriter, r *http.Request) {
+	// @inco: if(r.Method == "POST") ctxhas(auth.UserKey)
+	go func(c context.Context) {
+		// @inco: ctxhas(auth.TraceKey)
+	}(r.Context())
+}
+`
+
+func TestEngine_CtxHas(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod":       "module example.com/m\n\ngo 1.21\n",
+		"auth/auth.go": "package auth\n\ntype key int\n\nconst (\n\tUserKey key = iota\n\tTraceKey\n)\n",
+		"api/api.go":   ctxHasSrc,
+	})
+	e := NewEngine(dir)
+	e.Run()
+	data, err := os.ReadFile(e.Overlay.Replace[filepath.Join(dir, "api", "api.go")])
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow := string(data)
+	for _, want := range []string{
+		`import "example.com/m/auth"`,
+		"if !(ctx.Value(auth.UserKey) != nil) {",
+		"if !(r.Context().Value(auth.UserKey) != nil) {",
+		"if !(c.Value(auth.TraceKey) != nil) {",
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %q:\n%s", want, shadow)
+		}
+	}
+}
+
+func TestEngine_CtxHasWithoutContext(t *testing.T) {
+	e := NewEngine(setupDir(t, map[string]string{"main.go": "package main\n\nfunc f(key any) {\n\t// @inco: ctxhas(key)\n}\n"}))
+	want := "main.go:4: ctxhas(key): the enclosing function has no context.Context or *http.Request parameter; write ctxhas(ctx, key)"
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, want) {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+
+	dir := setupDir(t, map[string]string{"main.go": "package main\n\nfunc f(key any) {\n\t// @inco: ctxhas(key)\n}\n"})
+	diags := Vet(dir)
+	if len(diags) != 1 || diags[0].Column != 12 || !strings.HasSuffix(diags[0].Message, "write ctxhas(ctx, key)") {
+		t.Errorf("vet = %v", diags)
+	}
+}
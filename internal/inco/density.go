@@ -0,0 +1,155 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------------------------
+// Contract density
+// ---------------------------------------------------------------------------
+
+// DensityBucket counts the functions with between Min and Max
+// directives.
+type DensityBucket struct {
+	Min   int
+	Max   int // -1 for the last bucket, which has no upper bound
+	Funcs int
+}
+
+// Label returns the range of the bucket: "0", "3-5" or "11+".
+func (b DensityBucket) Label() string {
+	switch b.Max {
+	case -1:
+		return fmt.Sprintf("%d+", b.Min)
+	case b.Min:
+		return fmt.Sprint(b.Min)
+	}
+	return fmt.Sprintf("%d-%d", b.Min, b.Max)
+}
+
+// densityBounds are the ranges of the density histogram. Past a few
+// directives the exact number matters less than which functions carry
+// many; the last bucket starts above the default -max-func-contracts.
+var densityBounds = [][2]int{{0, 0}, {1, 1}, {2, 2}, {3, 5}, {6, 10}, {11, -1}}
+
+// densityHistogram counts the functions of files by directive count.
+func densityHistogram(files []FileAudit) []DensityBucket {
+	buckets := make([]DensityBucket, len(densityBounds))
+	for i, b := range densityBounds {
+		buckets[i] = DensityBucket{Min: b[0], Max: b[1]}
+	}
+	for _, f := range files {
+		for _, fn := range f.Funcs {
+			for i := len(buckets) - 1; i >= 0; i-- {
+				if fn.RequireCount >= buckets[i].Min {
+					buckets[i].Funcs++
+					break
+				}
+			}
+		}
+	}
+	return buckets
+}
+
+// PackageDensity is the contract density of one package.
+type PackageDensity struct {
+	Dir        string // package directory relative to root, "." for the root package
+	Lines      int    // source lines
+	Funcs      int    // functions counted toward coverage
+	Directives int    // @inco: and @invariant directives
+}
+
+// Per100Lines returns the directives per 100 source lines.
+func (p PackageDensity) Per100Lines() float64 {
+	return per(p.Directives*100, p.Lines)
+}
+
+// PerFunc returns the directives per function.
+func (p PackageDensity) PerFunc() float64 {
+	return per(p.Directives, p.Funcs)
+}
+
+// per returns n / total, or 0 when total is 0.
+func per(n, total int) float64 {
+	_ = total // @inco: total > 0, -return(0)
+	if !(total > 0) {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// packageDensities sums up files by package directory, sorted by
+// directory.
+func packageDensities(files []FileAudit) []PackageDensity {
+	byDir := make(map[string]*PackageDensity)
+	var out []*PackageDensity
+	for _, f := range files {
+		dir := filepath.ToSlash(filepath.Dir(f.RelPath))
+		p := byDir[dir]
+		if p == nil {
+			p = &PackageDensity{Dir: dir}
+			byDir[dir] = p
+			out = append(out, p)
+		}
+		p.Lines += f.Lines
+		p.Funcs += len(f.Funcs)
+		p.Directives += f.RequireCount + f.InvariantCount
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Dir < out[j].Dir })
+	densities := make([]PackageDensity, len(out))
+	for i, p := range out {
+		densities[i] = *p
+	}
+	return densities
+}
+
+// countLines returns the number of lines of src.
+func countLines(src []byte) int {
+	n := bytes.Count(src, []byte("\n"))
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// printDensity writes the density figures, the histogram of functions by
+// directive count and the density of each package.
+func printDensity(w io.Writer, r *AuditResult, c Colorizer) {
+	fmt.Fprintf(w, "%s\n", c.Bold("Contract density:"))
+	fmt.Fprintf(w, "  Per 100 lines:  %.1f  (%d lines)\n", r.DirectivesPer100Lines(), r.TotalLines)
+	fmt.Fprintf(w, "  Per function:   %.1f\n\n", r.DirectivesPerFunc())
+
+	most := 0
+	for _, b := range r.Density {
+		most = max(most, b.Funcs)
+	}
+	fmt.Fprintf(w, "  Directives  Functions\n")
+	for _, b := range r.Density {
+		bar := 0
+		if most > 0 {
+			bar = (b.Funcs*30 + most - 1) / most
+		}
+		fmt.Fprintf(w, "  %-10s  %9d  %s\n", b.Label(), b.Funcs, strings.Repeat("█", bar))
+	}
+
+	if len(r.Packages) < 2 {
+		fmt.Fprintln(w)
+		return
+	}
+	dirW := len("Package")
+	for _, p := range r.Packages {
+		dirW = max(dirW, utf8.RuneCountInString(p.Dir))
+	}
+	fmt.Fprintf(w, "\n  %-*s  lines  funcs  per 100 lines  per func\n", dirW, "Package")
+	fmt.Fprintf(w, "  %s  ─────  ─────  ─────────────  ────────\n", strings.Repeat("─", dirW))
+	for _, p := range r.Packages {
+		fmt.Fprintf(w, "  %-*s  %5d  %5d  %13.1f  %8.1f\n", dirW, p.Dir, p.Lines, p.Funcs, p.Per100Lines(), p.PerFunc())
+	}
+	fmt.Fprintln(w)
+}
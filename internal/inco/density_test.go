@@ -0,0 +1,86 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit_Density(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.go"), `package main
+
+func None() {}
+
+func One(x int) {
+	// @inco: x > 0
+}
+
+func Many(a, b, c, d int) {
+	// @inco: a > 0
+	// @inco: b > 0
+	// @inco: c > 0
+	// @inco: d > 0
+}
+`)
+	writeFile(t, filepath.Join(dir, "util", "util.go"), `package util
+
+func Helper() {}
+`)
+
+	r := Audit(dir, Limits{})
+
+	if r.TotalLines != 17 {
+		t.Errorf("TotalLines = %d, want 17", r.TotalLines)
+	}
+	if got := r.DirectivesPerFunc(); got != 1.25 {
+		t.Errorf("DirectivesPerFunc() = %v, want 1.25", got)
+	}
+	if got := r.DirectivesPer100Lines(); got < 29.4 || got > 29.5 {
+		t.Errorf("DirectivesPer100Lines() = %v, want 29.4", got)
+	}
+
+	var labels []string
+	for _, b := range r.Density {
+		labels = append(labels, fmt.Sprintf("%s=%d", b.Label(), b.Funcs))
+	}
+	if got, want := strings.Join(labels, " "), "0=2 1=1 2=0 3-5=1 6-10=0 11+=0"; got != want {
+		t.Errorf("Density = %s, want %s", got, want)
+	}
+
+	if len(r.Packages) != 2 || r.Packages[0].Dir != "." || r.Packages[1].Dir != "util" {
+		t.Fatalf("Packages = %+v, want . and util", r.Packages)
+	}
+	if p := r.Packages[0]; p.Lines != 14 || p.Funcs != 3 || p.Directives != 5 || p.Per100Lines() < 35.7 || p.Per100Lines() > 35.8 {
+		t.Errorf("Packages[0] = %+v (%v per 100 lines)", p, p.Per100Lines())
+	}
+	if p := r.Packages[1]; p.Per100Lines() != 0 || p.PerFunc() != 0 {
+		t.Errorf("Packages[1] = %+v, want no directives", p)
+	}
+
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	for _, want := range []string{
+		"  Per 100 lines:  29.4  (17 lines)\n",
+		"  Per function:   1.2\n",
+		"  0                   2  ██████████████████████████████\n",
+		"  3-5                 1  ███████████████\n",
+		"  11+                 0  \n",
+		"  util         3      1            0.0       0.0\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	for src, want := range map[string]int{"": 0, "a": 1, "a\n": 1, "a\nb": 2, "a\n\nb\n": 3} {
+		if got := countLines([]byte(src)); got != want {
+			t.Errorf("countLines(%q) = %d, want %d", src, got, want)
+		}
+	}
+}
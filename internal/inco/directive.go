@@ -3,28 +3,79 @@
 package inco
 
 import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"regexp"
 	"strings"
 )
 
+// ident matches a Go identifier: a letter or underscore followed by
+// letters, decimal digits and underscores, letters and digits in the
+// Unicode sense.
+const ident = `[\p{L}_][\p{L}\p{Nd}_]*`
+
+// stringLit matches a Go string literal, double-quoted or raw.
+const stringLit = `(?:"(?:[^"\\]|\\.)*"|` + "`[^`]*`)"
+
 var (
 	// directiveRe matches the body after stripping comment delimiters.
 	// Group 1: everything after "@inco: "
 	directiveRe = regexp.MustCompile(`^@inco:\s+(.+)$`)
 
-	// actionRe splits "expr, -action(args)" into components.
-	// Greedy (.+) backtracks to find the last top-level ", -action..." —
-	// this naturally handles commas inside parenthesized sub-expressions.
-	//
-	// Group 1: expression
-	// Group 2: action name (panic|return|continue|break)
-	// Group 3: action arguments (optional)
-	actionRe = regexp.MustCompile(`^(.+),\s*-(panic|return|continue|break)(?:\((.+)\))?\s*$`)
+	// invariantRe matches the body of a loop invariant.
+	// Group 1: everything after "@invariant " (parsed as an @inco: body)
+	invariantRe = regexp.MustCompile(`^@invariant\s+(.+)$`)
+
+	// ensureRe matches the body of a postcondition directive.
+	// Group 1: postcondition name (closed)
+	// Group 2: the identifier it applies to
+	ensureRe = regexp.MustCompile(`^@ensure\s+-(closed)\s+(` + ident + `)\s*$`)
+
+	// ensureExprRe matches the body of an expression postcondition.
+	// Group 1: everything after "@ensure " (parsed as an @inco: body)
+	ensureExprRe = regexp.MustCompile(`^@ensure\s+([^-\s].*)$`)
+
+	// mustRe matches the body of a channel deadline directive.
+	// Group 1: the timeout, e.g. 1s or 250ms
+	mustRe = regexp.MustCompile(`^@must\(\s*(.*?)\s*\)\s*$`)
+
+	// actionRe matches the action of "expr, -action(args)", the text
+	// after the last top-level comma (see splitAction).
+	// Group 1: action name (panic|return|continue|break|default)
+	// Group 2: action arguments (optional)
+	actionRe = regexp.MustCompile(`^-(panic|return|continue|break|default)(?:\((.+)\))?$`)
+
+	// msgActionRe matches the shorthand msg("key") of "expr, msg(\"key\")"
+	// for "expr, -panic(msg(\"key\"))", the text after the last top-level
+	// comma.
+	msgActionRe = regexp.MustCompile(`^msg\(\s*` + stringLit + `\s*\)$`)
+
+	// entryRe matches @entry(name) in a contract (see expandEntry).
+	// Group 1: the name
+	entryRe = regexp.MustCompile(`@entry\(\s*(` + ident + `)\s*\)`)
+
+	// chanRe matches the channel shorthands.
+	// Group 1: buffered, recvonly or sendonly
+	// Group 2: the channel identifier
+	chanRe = regexp.MustCompile(`^-(buffered|recvonly|sendonly)\s+(` + ident + `)$`)
+
+	// idxRe matches the index bounds shorthand "-idx i s".
+	// Group 1: the index; group 2: the indexed value
+	idxRe = regexp.MustCompile(`^-idx\s+(` + ident + `)\s+(` + ident + `(?:\.` + ident + `)*)$`)
 
 	// commentRe strips Go comment delimiters.
 	// Group 1: content of // comment
 	// Group 2: content of /* */ comment
 	commentRe = regexp.MustCompile(`^//\s*(.*?)\s*$|^/\*\s*(.*?)\s*\*/$`)
+
+	// tagRe matches a single directive tag such as #io or #input-validation.
+	// Group 1: the tag name
+	tagRe = regexp.MustCompile(`^#([\p{L}_][\p{L}\p{Nd}_-]*)$`)
+
+	// condRe matches the start of a conditional contract: "if(" or "if (".
+	condRe = regexp.MustCompile(`^if\s*\(`)
 )
 
 // actionFromName maps action name strings to ActionKind.
@@ -33,44 +84,180 @@ var actionFromName = map[string]ActionKind{
 	"return":   ActionReturn,
 	"continue": ActionContinue,
 	"break":    ActionBreak,
+	"default":  ActionDefault,
+}
+
+// ensureFromName maps postcondition names to DirectiveKind.
+var ensureFromName = map[string]DirectiveKind{
+	"closed": KindEnsureClosed,
 }
 
 // ParseDirective extracts a Directive from a comment string.
-// Returns nil when the comment is not a valid @inco: directive.
+// Returns nil when the comment is not a valid @inco: or @ensure directive,
+// or when it holds several directives (see ParseDirectives).
+//
+// Syntax:
 //
-// Syntax: @inco: <expr>[, -action[(args...)]]
+//	@inco: [#tag...] [if(<cond>)] <expr>[, -action[(args...)]]
+//	@inco: [#tag...] [if(<cond>)] <expr>, msg("<key>")
+//	@inco: [#tag...] -buffered|-recvonly|-sendonly <chan>
+//	@inco: [#tag...] [if(<cond>)] -idx <index> <slice>[, -action[(args...)]]
+//	@invariant [#tag...] [if(<cond>)] <expr>[, -action[(args...)]]
+//	@ensure -closed <ident>
+//	@must(<timeout>)
+//
+// Any of them may end in "-> <statement>", an explicit binding.
 func ParseDirective(comment string) *Directive {
+	ds := ParseDirectives(comment)
+	_ = ds // @inco: len(ds) == 1, -return(nil)
+	if !(len(ds) == 1) {
+		return nil
+	}
+	return ds[0]
+}
+
+// ParseDirectives extracts the directives of a comment string, in source
+// order. Several directives are separated by top-level semicolons; after
+// the first, the "@inco:" prefix is optional:
+//
+//	@inco: a != nil; @inco: b > 0, -return(err)
+//	@inco: a != nil; b > 0, -return(err)
+//	@inco: f != nil; @ensure -closed f
+//
+// Tags, guards and actions apply to their own segment only. A trailing
+// "-> stmt" binds every directive of the comment to the statement it
+// applies to (see bindingError). Returns nil
+// when the comment is not a directive or any segment is malformed, so a
+// typo never silently drops part of a contract.
+func ParseDirectives(comment string) []*Directive {
 	body := stripComment(comment)
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/directive.inco.go:42
+	_ = body // @inco: hasDirectivePrefix(body), -return(nil)
+	if !(hasDirectivePrefix(body)) {
+		return nil
+	}
+	body, bind, bound := splitBinding(body)
+	_ = bind // @inco: !bound || bind != "", -return(nil)
+	if !(!bound || bind != "") {
+		return nil
+	}
+	segments := splitTopLevelBy(body, ';')
+	ds := make([]*Directive, 0, len(segments))
+	for i, seg := range segments {
+		if i > 0 && !hasDirectivePrefix(seg) {
+			seg = "@inco: " + seg
+		}
+		d := parseDirectiveBody(seg)
+		_ = d // @inco: d != nil, -return(nil)
+		if !(d != nil) {
+			return nil
+		}
+		d.Bind = bind
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// parseDirectiveBody parses a single directive with its comment
+// delimiters already stripped.
+func parseDirectiveBody(body string) *Directive {
+	_ = body // @inco: body != "", -return(nil)
 	if !(body != "") {
 		return nil
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/directive.inco.go:43
+
+	if em := ensureRe.FindStringSubmatch(body); em != nil {
+		return &Directive{Kind: ensureFromName[em[1]], Expr: em[2]}
+	}
+	if em := ensureExprRe.FindStringSubmatch(body); em != nil {
+		d := parseDirectiveBody("@inco: " + em[1])
+		_ = d // @inco: d != nil && d.Kind == KindRequire && d.Action == ActionPanic, -return(nil)
+		if !(d != nil && d.Kind == KindRequire && d.Action == ActionPanic) {
+			return nil
+		}
+		d.Kind = KindEnsure
+		return d
+	}
+	if mm := mustRe.FindStringSubmatch(body); mm != nil {
+		_ = mm // @inco: mm[1] != "", -return(nil)
+		if !(mm[1] != "") {
+			return nil
+		}
+		return &Directive{Kind: KindMust, Expr: mm[1]}
+	}
+	if im := invariantRe.FindStringSubmatch(body); im != nil {
+		d := parseDirectiveBody("@inco: " + im[1])
+		_ = d // @inco: d != nil && d.Kind == KindRequire, -return(nil)
+		if !(d != nil && d.Kind == KindRequire) {
+			return nil
+		}
+		d.Kind = KindInvariant
+		return d
+	}
 
 	m := directiveRe.FindStringSubmatch(body)
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/directive.inco.go:45
+	_ = m // @inco: m != nil, -return(nil)
 	if !(m != nil) {
 		return nil
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/directive.inco.go:46
-	rest := m[1]
+	tags, rest := splitTags(m[1])
 
-	d := &Directive{Action: ActionPanic}
-	if am := actionRe.FindStringSubmatch(rest); am != nil {
-		d.Expr = strings.TrimSpace(am[1])
-		d.Action = actionFromName[am[2]]
-		if am[3] != "" {
-			d.ActionArgs = splitTopLevel(am[3])
+	d := &Directive{Action: ActionPanic, Tags: tags, Expr: rest}
+	expr, action := splitAction(rest)
+	if am := actionRe.FindStringSubmatch(action); am != nil {
+		d.Expr = expr
+		d.Action = actionFromName[am[1]]
+		if am[2] != "" {
+			d.ActionArgs = splitTopLevel(am[2])
 		}
-	} else {
-		d.Expr = rest
+	} else if msgActionRe.MatchString(action) {
+		d.Expr, d.ActionArgs = expr, []string{action}
 	}
+	d.Cond, d.Expr = splitCond(d.Expr)
+	d.Expr, d.Entry = expandEntry(d.Expr, nil)
+	d.Cond, d.Entry = expandEntry(d.Cond, d.Entry)
 
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/directive.inco.go:59
+	// -default takes exactly one value: the fallback.
+	_ = d // @inco: d.Action != ActionDefault || len(d.ActionArgs) == 1, -return(nil)
+	if !(d.Action != ActionDefault || len(d.ActionArgs) == 1) {
+		return nil
+	}
+	if im := idxRe.FindStringSubmatch(d.Expr); im != nil {
+		d.Expr = idxExpr(im[1], im[2])
+	}
+	if d.Action == ActionDefault {
+		d.Target, _ = defaultTarget(d.Expr)
+	}
+	_ = d.Expr // @inco: d.Expr != "", -return(nil)
 	if !(d.Expr != "") {
 		return nil
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/directive.inco.go:60
+	return expandChanShorthand(d)
+}
+
+// expandChanShorthand rewrites the channel shorthands of d.Expr:
+// "-buffered ch" becomes the contract cap(ch) > 0; "-recvonly ch" and
+// "-sendonly ch" become direction assertions, which take no guard or
+// action. Returns nil for a misused assertion.
+func expandChanShorthand(d *Directive) *Directive {
+	cm := chanRe.FindStringSubmatch(d.Expr)
+	_ = cm // @inco: cm != nil, -return(d)
+	if !(cm != nil) {
+		return d
+	}
+	switch cm[1] {
+	case "buffered":
+		d.Expr = "cap(" + cm[2] + ") > 0"
+		return d
+	case "recvonly":
+		d.Kind = KindRecvOnly
+	default:
+		d.Kind = KindSendOnly
+	}
+	_ = d // @inco: d.Cond == "" && d.Action == ActionPanic && len(d.ActionArgs) == 0, -return(nil)
+	if !(d.Cond == "" && d.Action == ActionPanic && len(d.ActionArgs) == 0) {
+		return nil
+	}
+	d.Expr = cm[2]
 	return d
 }
 
@@ -78,6 +265,71 @@ func ParseDirective(comment string) *Directive {
 // Helpers
 // ---------------------------------------------------------------------------
 
+// defaultTarget returns the variable a -default action assigns: the left
+// operand of the contract's comparison, such as timeout in timeout > 0, or
+// the right one when the left is a literal or call, as in 0 < cfg.Port.
+// Comparisons joined
+// with && or || must all check the same variable, as in n >= 1 && n <= 100.
+// ok is false when expr names no such variable.
+func defaultTarget(expr string) (target string, ok bool) {
+	x, err := parser.ParseExpr(expr)
+	_ = err // @inco: err == nil, -return("", false)
+	if !(err == nil) {
+		return "", false
+	}
+	var find func(x ast.Expr) string
+	find = func(x ast.Expr) string {
+		b, isBinary := ast.Unparen(x).(*ast.BinaryExpr)
+		_ = b // @inco: isBinary, -return("")
+		if !(isBinary) {
+			return ""
+		}
+		switch b.Op {
+		case token.LAND, token.LOR:
+			l, r := find(b.X), find(b.Y)
+			_ = l // @inco: l == r, -return("")
+			if !(l == r) {
+				return ""
+			}
+			return l
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			for _, operand := range []ast.Expr{b.X, b.Y} {
+				if assignable(operand) {
+					return expr[operand.Pos()-1 : operand.End()-1]
+				}
+			}
+		}
+		return ""
+	}
+	target = find(x)
+	return target, target != ""
+}
+
+// assignable reports whether x is a variable, field, element or pointer
+// indirection a statement may assign to.
+func assignable(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x.Name != "_" && x.Name != "nil" && x.Name != "true" && x.Name != "false" && x.Name != "iota"
+	case *ast.SelectorExpr:
+		return assignable(x.X)
+	case *ast.IndexExpr, *ast.StarExpr:
+		return true
+	}
+	return false
+}
+
+// noDefaultTarget explains why the -default action of d has nothing to
+// assign.
+func noDefaultTarget(d *Directive) string {
+	return fmt.Sprintf("-default(%s): %s compares no variable to assign; write the contract as <variable> <op> <value>, e.g. timeout > 0", d.ActionArgs[0], d.Expr)
+}
+
+// hasDirectivePrefix reports whether body starts with a directive keyword.
+func hasDirectivePrefix(body string) bool {
+	return strings.HasPrefix(body, "@inco:") || strings.HasPrefix(body, "@ensure") || strings.HasPrefix(body, "@invariant") || strings.HasPrefix(body, "@must(")
+}
+
 // stripComment removes Go comment delimiters and returns trimmed content.
 func stripComment(s string) string {
 	s = strings.TrimSpace(s)
@@ -94,43 +346,118 @@ func stripComment(s string) string {
 	return m[2]
 }
 
+// splitTags removes the leading #tags from a directive body and returns
+// them with the remainder: "#io #net x > 0" → (["io", "net"], "x > 0").
+func splitTags(s string) ([]string, string) {
+	var tags []string
+	for {
+		field, rest, _ := strings.Cut(s, " ")
+		tm := tagRe.FindStringSubmatch(field)
+		_ = tm // @inco: tm != nil, -return(tags, s)
+		if !(tm != nil) {
+			return tags, s
+		}
+		tags = append(tags, tm[1])
+		s = strings.TrimSpace(rest)
+	}
+}
+
+// splitCond separates the guard of a conditional contract from its
+// expression: "if(debug) x > 0" → ("debug", "x > 0"). cond is empty when s
+// has no guard clause or its parentheses are unbalanced.
+func splitCond(s string) (cond, expr string) {
+	loc := condRe.FindStringIndex(s)
+	_ = loc // @inco: loc != nil, -return("", s)
+	if !(loc != nil) {
+		return "", s
+	}
+	depth := 1
+	for i := loc[1]; i < len(s); i++ {
+		switch s[i] {
+		case '"', '`', '\'':
+			i = skipQuoted(s, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(s[loc[1]:i]), strings.TrimSpace(s[i+1:])
+			}
+		}
+	}
+	return "", s
+}
+
+// splitAction splits a directive body at its last top-level comma into
+// the expression and what may be its action: "ok, -panic(`a, b`)" →
+// ("ok", "-panic(`a, b`)"). Commas and action-like text inside calls,
+// strings and rune literals never split it, whichever quotes a message
+// uses. action is empty when s has no top-level comma.
+func splitAction(s string) (expr, action string) {
+	commas := topLevelIndexes(s, ',')
+	_ = commas // @inco: len(commas) > 0, -return(s, "")
+	if !(len(commas) > 0) {
+		return s, ""
+	}
+	i := commas[len(commas)-1]
+	return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+}
+
 // splitTopLevel splits s by top-level commas, respecting nested parens,
-// brackets, braces, double-quoted strings, and raw strings (backtick).
+// brackets, braces, double-quoted strings, raw strings (backtick) and rune
+// literals.
 func splitTopLevel(s string) []string {
+	return splitTopLevelBy(s, ',')
+}
+
+// splitTopLevelBy splits s by top-level occurrences of sep, with the same
+// nesting and quoting rules as splitTopLevel. Empty segments other than a
+// trailing one are kept.
+func splitTopLevelBy(s string, sep byte) []string {
 	var result []string
-	depth := 0
 	start := 0
+	for _, i := range topLevelIndexes(s, sep) {
+		result = append(result, strings.TrimSpace(s[start:i]))
+		start = i + 1
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		result = append(result, last)
+	}
+	return result
+}
+
+// topLevelIndexes returns the indexes of the occurrences of sep in s
+// outside parens, brackets, braces, strings and rune literals.
+func topLevelIndexes(s string, sep byte) []int {
+	var indexes []int
+	depth := 0
 	for i := 0; i < len(s); i++ {
 		ch := s[i]
 		switch {
-		case ch == '"':
-			// Double-quoted string: skip until unescaped closing quote.
-			i++
-			for i < len(s) {
-				if s[i] == '\\' {
-					i++ // skip escaped char
-				} else if s[i] == '"' {
-					break
-				}
-				i++
-			}
-		case ch == '`':
-			// Raw string: no escapes, skip until closing backtick.
-			i++
-			for i < len(s) && s[i] != '`' {
-				i++
-			}
+		case ch == '"' || ch == '`' || ch == '\'':
+			i = skipQuoted(s, i)
 		case ch == '(' || ch == '[' || ch == '{':
 			depth++
 		case ch == ')' || ch == ']' || ch == '}':
 			depth--
-		case ch == ',' && depth == 0:
-			result = append(result, strings.TrimSpace(s[start:i]))
-			start = i + 1
+		case ch == sep && depth == 0:
+			indexes = append(indexes, i)
 		}
 	}
-	if last := strings.TrimSpace(s[start:]); last != "" {
-		result = append(result, last)
+	return indexes
+}
+
+// skipQuoted returns the index of the quote closing the literal s[i]
+// opens: a double-quoted string, a raw string (no escapes) or a rune
+// literal such as ',' or '"'. It returns len(s) for an unclosed literal.
+// Quotes and backslashes are ASCII, and the bytes of a multi-byte UTF-8
+// character never are, so the literal may hold any text.
+func skipQuoted(s string, i int) int {
+	quote := s[i]
+	for i++; i < len(s) && s[i] != quote; i++ {
+		if s[i] == '\\' && quote != '`' {
+			i++ // skip the escaped character
+		}
 	}
-	return result
+	return i
 }
@@ -2,6 +2,7 @@ package inco
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -170,6 +171,32 @@ func TestParseDirective_Break(t *testing.T) {
 // Edge cases — comma inside expression
 // ---------------------------------------------------------------------------
 
+func TestParseDirective_Default(t *testing.T) {
+	for _, tt := range []struct{ comment, target string }{
+		{"// @inco: timeout > 0, -default(30*time.Second)", "timeout"},
+		{"// @inco: 0 < cfg.Port, -default(8080)", "cfg.Port"},
+		{"// @inco: n >= 1 && n <= 100, -default(10)", "n"},
+		{`// @inco: opts["mode"] != "", -default("fast")`, `opts["mode"]`},
+		{"// @inco: len(name) > 0, -default(\"anon\")", ""},
+		{"// @inco: lo < hi, -default(0)", "lo"},
+		{"// @inco: a > 0 && b > 0, -default(1)", ""},
+	} {
+		d := ParseDirective(tt.comment)
+		if d == nil || d.Action != ActionDefault || len(d.ActionArgs) != 1 {
+			t.Errorf("ParseDirective(%q) = %+v", tt.comment, d)
+			continue
+		}
+		if d.Target != tt.target {
+			t.Errorf("%q: Target = %q, want %q", tt.comment, d.Target, tt.target)
+		}
+	}
+	for _, comment := range []string{"// @inco: x > 0, -default", "// @inco: x > 0, -default(1, 2)"} {
+		if d := ParseDirective(comment); d != nil {
+			t.Errorf("ParseDirective(%q) = %+v, want nil", comment, d)
+		}
+	}
+}
+
 func TestParseDirective_CommaInFuncCallIsNotAction(t *testing.T) {
 	// The comma inside foo(a, b) should NOT be treated as an action separator.
 	d := ParseDirective("// @inco: foo(a, b) > 0")
@@ -239,6 +266,215 @@ func TestParseDirective_BlockComment(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Conditional contracts: if(cond)
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Cond(t *testing.T) {
+	d := ParseDirective(`// @inco: if(cfg.Debug()) len(buf) > 0, -return(nil)`)
+	if d == nil {
+		t.Fatal("got nil")
+	}
+	if d.Cond != "cfg.Debug()" {
+		t.Errorf("Cond = %q, want %q", d.Cond, "cfg.Debug()")
+	}
+	if d.Expr != "len(buf) > 0" {
+		t.Errorf("Expr = %q, want %q", d.Expr, "len(buf) > 0")
+	}
+	if d.Action != ActionReturn {
+		t.Errorf("Action = %v, want ActionReturn", d.Action)
+	}
+}
+
+func TestParseDirective_CondVariants(t *testing.T) {
+	tests := []struct {
+		input, cond, expr string
+	}{
+		{"// @inco: if (debugBuild) x > 0", "debugBuild", "x > 0"},
+		{"// @inco: ifx > 0", "", "ifx > 0"},
+		{"// @inco: if(debugBuild x > 0", "", "if(debugBuild x > 0"},
+	}
+	for _, tt := range tests {
+		d := ParseDirective(tt.input)
+		if d == nil {
+			t.Fatalf("ParseDirective(%q) = nil", tt.input)
+		}
+		if d.Cond != tt.cond || d.Expr != tt.expr {
+			t.Errorf("ParseDirective(%q) = (%q, %q), want (%q, %q)", tt.input, d.Cond, d.Expr, tt.cond, tt.expr)
+		}
+	}
+	if d := ParseDirective("// @inco: if(debugBuild)"); d != nil {
+		t.Errorf("guard without expression should be rejected, got %+v", d)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tags: #tag
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Tags(t *testing.T) {
+	d := ParseDirective(`// @inco: #io #input-validation if(debug) n > 0, -return(0, err)`)
+	if d == nil {
+		t.Fatal("got nil")
+	}
+	if want := []string{"io", "input-validation"}; !reflect.DeepEqual(d.Tags, want) {
+		t.Errorf("Tags = %q, want %q", d.Tags, want)
+	}
+	if d.Cond != "debug" || d.Expr != "n > 0" || d.Action != ActionReturn {
+		t.Errorf("got Cond=%q Expr=%q Action=%v", d.Cond, d.Expr, d.Action)
+	}
+	if d := ParseDirective("// @inco: #io"); d != nil {
+		t.Errorf("tag without expression should be rejected, got %+v", d)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// @ensure -closed
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_EnsureClosed(t *testing.T) {
+	d := ParseDirective("// @ensure -closed f")
+	if d == nil {
+		t.Fatal("got nil")
+	}
+	if d.Kind != KindEnsureClosed {
+		t.Errorf("Kind = %v, want KindEnsureClosed", d.Kind)
+	}
+	if d.Expr != "f" {
+		t.Errorf("Expr = %q, want %q", d.Expr, "f")
+	}
+}
+
+func TestParseDirective_EnsureInvalid(t *testing.T) {
+	for _, input := range []string{
+		"// @ensure",
+		"// @ensure -closed",
+		"// @ensure -closed f.g",
+		"// @ensure -opened f",
+	} {
+		if d := ParseDirective(input); d != nil {
+			t.Errorf("ParseDirective(%q) = %+v, want nil", input, d)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Quoted messages
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_QuotedMessages(t *testing.T) {
+	tests := []struct {
+		input  string
+		expr   string
+		action ActionKind
+		args   []string
+	}{
+		{"// @inco: ok, -panic(`user said \"no\"`)", "ok", ActionPanic, []string{"`user said \"no\"`"}},
+		{`// @inco: ok, -panic("user said \"no\"")`, "ok", ActionPanic, []string{`"user said \"no\""`}},
+		{"// @inco: ok, -panic(`C:\\dir\\`)", "ok", ActionPanic, []string{"`C:\\dir\\`"}},
+		// Action-like text in a message or the expression stays there.
+		{"// @inco: ok, -panic(`a, -return(1)`)", "ok", ActionPanic, []string{"`a, -return(1)`"}},
+		{`// @inco: ok, -panic("a, -return(1)")`, "ok", ActionPanic, []string{`"a, -return(1)"`}},
+		{"// @inco: s != `x, -continue`, -return(0, errors.New(`bad \"s\", -break`))", "s != `x, -continue`", ActionReturn, []string{"0", "errors.New(`bad \"s\", -break`)"}},
+		{"// @inco: ok, -panic(`say \\\"hi\\\" ` + name)", "ok", ActionPanic, []string{"`say \\\"hi\\\" ` + name"}},
+		{`// @inco: ok, -panic("unclosed`, `ok, -panic("unclosed`, ActionPanic, nil},
+	}
+	for _, tt := range tests {
+		d := ParseDirective(tt.input)
+		if d == nil {
+			t.Errorf("ParseDirective(%q) = nil", tt.input)
+			continue
+		}
+		if d.Expr != tt.expr || d.Action != tt.action || !reflect.DeepEqual(d.ActionArgs, tt.args) {
+			t.Errorf("ParseDirective(%q) = expr %q, action %v, args %q\nwant expr %q, action %v, args %q",
+				tt.input, d.Expr, d.Action, d.ActionArgs, tt.expr, tt.action, tt.args)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Unicode text and identifiers
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Unicode(t *testing.T) {
+	tests := []struct {
+		input      string
+		cond, expr string
+		args       []string
+		tags       []string
+	}{
+		{input: `// @inco: 名前 != "", -panic("名前が空です 🙅")`, expr: `名前 != ""`, args: []string{`"名前が空です 🙅"`}},
+		{input: `// @inco: len(ユーザー.メール) > 0, -return(0, fmt.Errorf("メール「%s」は無効 ✉️, 再入力", ユーザー.名前))`,
+			expr: `len(ユーザー.メール) > 0`, args: []string{"0", `fmt.Errorf("メール「%s」は無効 ✉️, 再入力", ユーザー.名前)`}},
+		{input: `// @inco: 区切り != ',', -return(',', nil)`, expr: `区切り != ','`, args: []string{`','`, "nil"}},
+		{input: `// @inco: if(s != ")") len(s) < 10, -panic("長すぎる 📏")`, cond: `s != ")"`, expr: "len(s) < 10", args: []string{`"長すぎる 📏"`}},
+		{input: `// @inco: if(r == '(') 深さ > 0`, cond: `r == '('`, expr: "深さ > 0"},
+		{input: `// @inco: #入力 #検証-済み x > 0`, expr: "x > 0", tags: []string{"入力", "検証-済み"}},
+		{input: `// @inco: -idx 番号 注文.品目`, expr: "番号 >= 0 && 番号 < len(注文.品目)"},
+		{input: `// @inco: -buffered 通知`, expr: "cap(通知) > 0"},
+	}
+	for _, tt := range tests {
+		d := ParseDirective(tt.input)
+		if d == nil {
+			t.Errorf("ParseDirective(%q) = nil", tt.input)
+			continue
+		}
+		if d.Cond != tt.cond || d.Expr != tt.expr || !reflect.DeepEqual(d.ActionArgs, tt.args) || !reflect.DeepEqual(d.Tags, tt.tags) {
+			t.Errorf("ParseDirective(%q) = cond %q, expr %q, args %q, tags %q\nwant cond %q, expr %q, args %q, tags %q",
+				tt.input, d.Cond, d.Expr, d.ActionArgs, d.Tags, tt.cond, tt.expr, tt.args, tt.tags)
+		}
+	}
+
+	if d := ParseDirective("// @ensure -closed 接続"); d == nil || d.Kind != KindEnsureClosed || d.Expr != "接続" {
+		t.Errorf("@ensure -closed with a Unicode identifier = %+v", d)
+	}
+	if d := ParseDirective("// @ensure -closed 🔌"); d != nil {
+		t.Errorf("@ensure -closed with an emoji = %+v, want nil", d)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Several directives in one comment
+// ---------------------------------------------------------------------------
+
+func TestParseDirectives(t *testing.T) {
+	ds := ParseDirectives(`// @inco: a != nil; #slow b > 0, -return(0, fmt.Errorf("b; %d", b)); @inco: if(c) d, -panic("d"); @ensure -closed f`)
+	want := []Directive{
+		{Kind: KindRequire, Action: ActionPanic, Expr: "a != nil"},
+		{Kind: KindRequire, Action: ActionReturn, Expr: "b > 0", Tags: []string{"slow"}, ActionArgs: []string{"0", `fmt.Errorf("b; %d", b)`}},
+		{Kind: KindRequire, Action: ActionPanic, Expr: "d", Cond: "c", ActionArgs: []string{`"d"`}},
+		{Kind: KindEnsureClosed, Expr: "f"},
+	}
+	if len(ds) != len(want) {
+		t.Fatalf("got %d directives, want %d: %+v", len(ds), len(want), ds)
+	}
+	for i, w := range want {
+		if !reflect.DeepEqual(*ds[i], w) {
+			t.Errorf("directive %d = %+v, want %+v", i, *ds[i], w)
+		}
+	}
+	if d := ParseDirective("// @inco: a; b"); d != nil {
+		t.Errorf("ParseDirective of two directives = %+v, want nil", d)
+	}
+}
+
+func TestParseDirectives_Malformed(t *testing.T) {
+	for _, input := range []string{
+		"// @inco: a;; b",
+		"// @inco: a; @ensure -opened f",
+		"// @inco: ; a",
+		"// not a directive; @inco: a",
+	} {
+		if ds := ParseDirectives(input); ds != nil {
+			t.Errorf("ParseDirectives(%q) = %+v, want nil", input, ds)
+		}
+	}
+	// A trailing separator is allowed.
+	if ds := ParseDirectives("// @inco: a;"); len(ds) != 1 {
+		t.Errorf("ParseDirectives with trailing ';' = %+v, want one directive", ds)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // stripComment helper
 // ---------------------------------------------------------------------------
@@ -283,6 +519,13 @@ func TestSplitTopLevel(t *testing.T) {
 		{`"a\"b", c`, []string{`"a\"b"`, "c"}},
 		// Double-quoted string with escaped backslash before closing quote.
 		{`"a\\", c`, []string{`"a\\"`, "c"}},
+		// Rune literals holding a separator or a quote.
+		{`',', nil`, []string{`','`, "nil"}},
+		{`'"', "a,b"`, []string{`'"'`, `"a,b"`}},
+		{`'\'', x`, []string{`'\''`, "x"}},
+		// Multi-byte text in strings, rune literals and identifiers.
+		{`"名前、住所, 電話 📞", '、'`, []string{`"名前、住所, 電話 📞"`, `'、'`}},
+		{`fmt.Errorf("%s が不正です 🚫", 値), 数`, []string{`fmt.Errorf("%s が不正です 🚫", 値)`, "数"}},
 	}
 	for _, c := range cases {
 		got := splitTopLevel(c.input)
@@ -291,3 +534,53 @@ func TestSplitTopLevel(t *testing.T) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Fuzzing
+// ---------------------------------------------------------------------------
+
+// FuzzParseDirectives feeds arbitrary comment text to the parser. Besides
+// not panicking, it must ignore the line ending and the whitespace kind
+// around the comment text, and never return a directive without an
+// expression.
+func FuzzParseDirectives(f *testing.F) {
+	for _, seed := range []string{
+		"// @inco: x > 0",
+		"// @inco: from != nil; to != nil, -panic(\"no account\")",
+		"// @inco: s != \"a;b\", -panic(\"say \\\"hi\\\", -return(1)\")",
+		"// @inco: #io #security n <= len(buf), -return(0, io.ErrShortBuffer)",
+		"// @inco: if(n > 0) len(buf) > 0, -continue(outer)",
+		"// @inco: x > 0, msg(\"errors.negative_x\")",
+		"// @inco: timeout > 0, -default(time.Second)",
+		"// @inco: -idx i xs, -break",
+		"// @inco: -recvonly ch",
+		"// @invariant sum >= 0",
+		"// @ensure -closed f",
+		"// @must(1s); @inco: ok, -panic(\"pool closed\")",
+		"// @inco: id != \"\" -> rows, err := db.Query(q)",
+		"/* @inco: len(xs) > 0 */",
+		"//\t@inco:\tx > 0\r",
+		"// @inco: x > 0,\t-return(`raw, string`)\r",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, comment string) {
+		ds := ParseDirectives(comment)
+		for _, d := range ds {
+			if d.Expr == "" {
+				t.Errorf("ParseDirectives(%q) returned a directive without expression: %+v", comment, d)
+			}
+		}
+		if got := ParseDirectives(comment + "\r"); !reflect.DeepEqual(got, ds) {
+			t.Errorf("ParseDirectives(%q + \"\\r\") = %+v, want %+v", comment, got, ds)
+		}
+		if body, ok := strings.CutPrefix(comment, "// "); ok {
+			if got := ParseDirectives("//\t" + body); !reflect.DeepEqual(got, ds) {
+				t.Errorf("ParseDirectives with a tab after // = %+v, want %+v", got, ds)
+			}
+		}
+		if d := ParseDirective(comment); d != nil && (len(ds) != 1 || !reflect.DeepEqual(d, ds[0])) {
+			t.Errorf("ParseDirective(%q) = %+v, but ParseDirectives = %+v", comment, d, ds)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------------------------
+// Endpoints: contracts by service handler
+// ---------------------------------------------------------------------------
+
+// handlerRe matches a handler directive in a function's doc comment.
+// Group 1: the optional method (GET, POST, …); group 2: the route.
+//
+//	// @handler GET /users/{id}
+//	// @handler /billing.Invoices/Create
+var handlerRe = regexp.MustCompile(`^//\s*@handler(?:\s+([A-Z]+))?\s+(\S+)\s*$`)
+
+// Endpoint is a function serving a request: one declared with a
+// @handler directive, or matched by AuditOptions.Handlers.
+type Endpoint struct {
+	Method    string // e.g. "GET"; "" when the directive names none
+	Route     string // e.g. "/users/{id}"; "" for handlers matched by pattern
+	Func      string // "(*Server).GetUser" for methods
+	Path      string // file relative to root
+	Line      int    // line of the function declaration
+	Contracts []EndpointContract
+}
+
+// EndpointContract is an @inco: contract in a handler. Contracts in
+// function literals count toward the enclosing handler; loop invariants
+// do not count.
+type EndpointContract struct {
+	Line        int
+	Expr        string
+	Cond        string // if(cond) guard
+	OnViolation string
+}
+
+// Name returns how reports show e: "GET /users/{id}", the route alone, or
+// for handlers without a route the function name.
+func (e Endpoint) Name() string {
+	switch {
+	case e.Route == "":
+		return e.Func
+	case e.Method == "":
+		return e.Route
+	}
+	return e.Method + " " + e.Route
+}
+
+// Validated reports whether e checks its input with at least one contract.
+func (e Endpoint) Validated() bool {
+	return len(e.Contracts) > 0
+}
+
+// endpoints returns the handlers declared in f, in file order: functions
+// whose doc comment holds a @handler directive, and functions whose name
+// matches one of patterns (path.Match globs such as "*Handler" or
+// "Server.Handle*", matched against the name with the receiver type
+// bare, whether or not it is a pointer).
+func endpoints(f *ast.File, fset *token.FileSet, relPath string, directives map[int][]*Directive, patterns []string) []Endpoint {
+	var out []Endpoint
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Body != nil, -continue
+		if !(ok && fn.Body != nil) {
+			continue
+		}
+		name := fn.Name.Name
+		if fn.Recv != nil && len(fn.Recv.List) > 0 {
+			name = recvTypeName(fn.Recv.List[0].Type) + "." + name
+		}
+		ep := Endpoint{Func: auditName(fn), Path: relPath, Line: srcLine(fset, fn.Pos())}
+		declared := false
+		if fn.Doc != nil {
+			for _, c := range fn.Doc.List {
+				if m := handlerRe.FindStringSubmatch(c.Text); m != nil {
+					ep.Method, ep.Route, declared = m[1], m[2], true
+				}
+			}
+		}
+		if !declared && !matchesAny(patterns, name) {
+			continue
+		}
+		start, end := srcLine(fset, fn.Body.Lbrace), srcLine(fset, fn.Body.Rbrace)
+		for line, ds := range directives {
+			_ = line // @inco: start <= line && line <= end, -continue
+			if !(start <= line && line <= end) {
+				continue
+			}
+			for _, d := range ds {
+				if d.Kind != KindRequire {
+					continue
+				}
+				ep.Contracts = append(ep.Contracts, EndpointContract{Line: line, Expr: d.Expr, Cond: d.Cond, OnViolation: describeAction(d)})
+			}
+		}
+		sort.SliceStable(ep.Contracts, func(i, j int) bool { return ep.Contracts[i].Line < ep.Contracts[j].Line })
+		out = append(out, ep)
+	}
+	return out
+}
+
+// matchesAny reports whether name matches one of the glob patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// collectEndpoints gathers the endpoints of files, sorted by route, then
+// method, then function; handlers without a route come last.
+func collectEndpoints(files []FileAudit) []Endpoint {
+	var out []Endpoint
+	for _, f := range files {
+		out = append(out, f.Endpoints...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if (a.Route == "") != (b.Route == "") {
+			return a.Route != ""
+		}
+		if a.Route != b.Route {
+			return a.Route < b.Route
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Func < b.Func
+	})
+	return out
+}
+
+// printEndpoints writes the endpoint table: each endpoint with its handler
+// and how many input contracts it has, then the contracts of each
+// validated endpoint and the endpoints without any.
+func printEndpoints(w io.Writer, endpoints []Endpoint, validated int, c Colorizer) {
+	fmt.Fprintf(w, "\n%s\n", c.Bold("Contracts by endpoint:"))
+	pct := float64(validated) / float64(len(endpoints)) * 100
+	fmt.Fprintf(w, "  Validated:  %d / %d  (%s)\n\n", validated, len(endpoints), c.Percent(pct, 90, 60))
+	nameW, funcW := len("Endpoint"), len("Handler")
+	for _, ep := range endpoints {
+		nameW = max(nameW, utf8.RuneCountInString(ep.Name()))
+		funcW = max(funcW, utf8.RuneCountInString(ep.Func))
+	}
+	fmt.Fprintf(w, "  %-*s  %-*s  %s\n", nameW, "Endpoint", funcW, "Handler", "Contracts")
+	fmt.Fprintf(w, "  %s  %s  %s\n", strings.Repeat("─", nameW), strings.Repeat("─", funcW), strings.Repeat("─", len("Contracts")))
+	for _, ep := range endpoints {
+		count := fmt.Sprint(len(ep.Contracts))
+		if !ep.Validated() {
+			count = c.Warning("none")
+		}
+		fmt.Fprintf(w, "  %-*s  %-*s  %s\n", nameW, ep.Name(), funcW, ep.Func, count)
+	}
+	for _, ep := range endpoints {
+		if !ep.Validated() {
+			continue
+		}
+		fmt.Fprintf(w, "\n  %s  (%s:%d)\n", c.Bold(ep.Name()), ep.Path, ep.Line)
+		for _, ec := range ep.Contracts {
+			expr := ec.Expr
+			if ec.Cond != "" {
+				expr = "if(" + ec.Cond + ") " + expr
+			}
+			fmt.Fprintf(w, "    %s  %s\n", expr, ec.OnViolation)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package inco
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Endpoints
+// ---------------------------------------------------------------------------
+
+const handlersSrc = `package api
+
+import "net/http"
+
+type Server struct{}
+
+// GetUser returns one user.
+//
+// @handler GET /users/{id}
+func (s *Server) GetUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	_ = id // @inco: id != "", -panic("id required")
+	for i := range 3 {
+		// @invariant i < 3
+	}
+}
+
+// @handler DELETE /users/{id}
+func (s *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
+}
+
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	_ = r // @inco: if(r.Method != "HEAD") r.Method == "GET", -return
+}
+
+func helper(n int) {
+	// @inco: n > 0
+}
+`
+
+func TestAudit_Endpoints(t *testing.T) {
+	dir := setupDir(t, map[string]string{"api/users.go": handlersSrc})
+	r := AuditWith(dir, AuditOptions{Handlers: []string{"*Handler"}})
+	if len(r.Endpoints) != 3 || r.ValidatedEndpoints != 2 {
+		t.Fatalf("Endpoints = %+v, validated %d", r.Endpoints, r.ValidatedEndpoints)
+	}
+	names := []string{r.Endpoints[0].Name(), r.Endpoints[1].Name(), r.Endpoints[2].Name()}
+	if names[0] != "DELETE /users/{id}" || names[1] != "GET /users/{id}" || names[2] != "HealthHandler" {
+		t.Errorf("endpoints in order %q", names)
+	}
+	get := r.Endpoints[1]
+	if get.Func != "(*Server).GetUser" || get.Line != 10 || len(get.Contracts) != 1 || get.Contracts[0].Expr != `id != ""` {
+		t.Errorf("GET endpoint = %+v", get)
+	}
+	if health := r.Endpoints[2]; len(health.Contracts) != 1 || health.Contracts[0].Cond != `r.Method != "HEAD"` {
+		t.Errorf("pattern-matched endpoint = %+v", health)
+	}
+
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	want := "  Endpoint            Handler               Contracts\n" +
+		"  ──────────────────  ────────────────────  ─────────\n" +
+		"  DELETE /users/{id}  (*Server).DeleteUser  none\n" +
+		"  GET /users/{id}     (*Server).GetUser     1\n" +
+		"  HealthHandler       HealthHandler         1\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("report missing endpoint table:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "  Validated:  2 / 3") {
+		t.Errorf("report missing validated count:\n%s", buf.String())
+	}
+
+	if r := Audit(dir, Limits{}); len(r.Endpoints) != 2 {
+		t.Errorf("without patterns: %d endpoints, want the 2 declared", len(r.Endpoints))
+	}
+}
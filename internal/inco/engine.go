@@ -3,23 +3,28 @@
 package inco
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/ast"
-	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
+	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
-
-	"golang.org/x/tools/go/ast/astutil"
 )
 
 // ---------------------------------------------------------------------------
@@ -29,10 +34,47 @@ import (
 // Engine scans Go source files for @inco: directives and produces an
 // overlay that injects the corresponding if-statements at compile time.
 type Engine struct {
-	Root       string
-	Overlay    Overlay
-	importMap  map[string]string // lazily built: package name → import path
-	importOnce sync.Once
+	Root          string
+	Overlay       Overlay
+	CacheDir      string       // shadow, overlay and manifest directory (default Root/.inco_cache)
+	TrimPath      bool         // emit //line paths relative to the shadow directory (for -trimpath builds)
+	Typecheck     bool         // typecheck packages with the overlay applied before writing it
+	Quiet         bool         // suppress the summary, warnings and progress Run prints to stderr
+	Color         Colorizer    // color the warnings Run prints to stderr
+	Progress      io.Writer    // terminal Run draws a progress line on while processing files; nil = none
+	Mutation      *Mutation    // replaces one directive's expression (inco mutate); nil = none
+	EnableTags    []string     // when non-empty, tagged directives are kept only if they carry one of these tags
+	DisableTags   []string     // tagged directives carrying any of these tags are dropped
+	Sensitive     []string     // glob patterns of names whose values are redacted in violation output ("password", "*token*")
+	Messages      string       // message catalog for msg("key") in actions (JSON); "" = none
+	Packages      []string     // when non-empty, only packages matching these patterns ("./api/...", "./db") are instrumented
+	IncludeVendor bool         // instrument vendored packages too; vendor directories are skipped by default
+	Limits        Limits       // contract size warnings for regenerated files
+	Warnings      []Warning    // set by Run: the warnings it found
+	KeepGoing     bool         // record per-file failures and still write the overlay for the other files
+	LogViolations bool         // log violated panic contracts with incolog instead of panicking
+	Must          bool         // generate the @must channel deadlines (test builds); otherwise they are dropped
+	Tests         bool         // instrument TestMain in _test.go files too (test builds)
+	CountHits     bool         // record each check's evaluation with incolog.Hit, for audit -runtime
+	BuildTags     string       // -tags the go command builds with, recorded in meta.json (see BuildEnv)
+	Overlays      []string     // overlay files of other code generators, composed with inco's (see loadGenerated)
+	Failures      []Diagnostic // set by Run with KeepGoing: the files it could not process
+	graph         *pkgGraph    // lazily built: packages directives may import
+	graphOnce     sync.Once
+
+	// TypecheckCache bounds how many packages' typecheck outcomes are kept
+	// across Runs (0 = DefaultTypecheckCache, negative = unbounded).
+	TypecheckCache int
+	// MaxMemory makes typechecking fail, naming the heaviest packages, when
+	// the heap exceeds this many bytes (0 = no limit).
+	MaxMemory uint64
+
+	catalog       *messageCatalog            // loaded by Run from Messages
+	typechecked   map[string]*typecheckEntry // package dir → last typecheck; kept across Runs
+	typecheckRuns uint64                     // Runs that typechecked, for eviction
+	goEnv         string                     // go settings the typecheck outcomes hold for (see goEnvKey)
+	generated     map[string]string          // loaded by Run: the merged Replace of Overlays
+	mustZero      string                     // set by Instrument: the name a shadow declares mustZeroName as itself
 }
 
 // NewEngine creates an engine rooted at the given directory.
@@ -43,8 +85,31 @@ func NewEngine(root string) *Engine {
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:39
 	return &Engine{
-		Root:    root,
-		Overlay: Overlay{Replace: make(map[string]string)},
+		Root:     root,
+		CacheDir: DefaultCacheDir(root),
+		Overlay:  Overlay{Replace: make(map[string]string)},
+	}
+}
+
+// DefaultCacheDir returns the cache directory used when none is configured.
+func DefaultCacheDir(root string) string {
+	return filepath.Join(root, ".inco_cache")
+}
+
+// ModuleRoot returns the directory of the go.mod that governs the absolute
+// directory dir: dir itself or its nearest ancestor holding one. It is dir
+// when there is none.
+func ModuleRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		_ = parent // @inco: parent != d, -return(dir)
+		if !(parent != d) {
+			return dir
+		}
+		d = parent
 	}
 }
 
@@ -59,10 +124,21 @@ type fileResult struct {
 	ShadowPath string
 	ShadowData []byte // nil when reused from cache
 	Cached     bool
+	Helper     bool      // a file Run adds to a package (see mustHelpers), not a source
+	Warnings   []Warning // contract size warnings; regenerated files only
+	Err        error     // with KeepGoing: why the file could not be processed
+
+	// Annotations describes the file's contracts for annotations.json,
+	// keyed by "relpath:line"; for cached files it is carried over from
+	// the previous run.
+	Annotations map[string][]Annotation
+	// Meta describes the shadow for meta.json; for cached files it is
+	// carried over from the previous run.
+	Meta ShadowMeta
 }
 
 // Run scans all Go source files under Root, processes @inco: directives,
-// and writes the overlay + shadow files into .inco_cache/.
+// and writes the overlay + shadow files into CacheDir.
 //
 // Incremental: if a source file's content hash matches the manifest and
 // the shadow file still exists, the file is skipped.
@@ -79,9 +155,46 @@ func (e *Engine) Run() {
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:68
 
+	// Other processes generating into CacheDir wait until this Run ends.
+	unlock := lockCache(e.CacheDir)
+	defer unlock()
+
+	// An engine may Run repeatedly (inco serve): start from a clean slate.
+	e.Overlay.Replace = make(map[string]string)
+	e.Warnings = nil
+	e.Failures = nil
+	e.catalog = e.loadCatalog()
+	e.generated = e.loadGenerated()
+
 	oldManifest := e.loadManifest()
-	oldOverlay := e.loadOverlayIfExists()
-	paths := collectGoFiles(e.Root)
+	oldAnnotations := e.loadAnnotations()
+	oldMeta, oldEnv := e.loadMeta()
+	env := e.buildEnv()
+	changed := ""
+	if oldEnv != nil && env != nil {
+		changed = env.changes(*oldEnv)
+	}
+	if changed != "" && !e.Quiet {
+		fmt.Fprintf(os.Stderr, "inco: %s the build environment changed since the last generation (%s); regenerating every file\n",
+			e.Color.Warning("warning:"), changed)
+	}
+	if oldAnnotations == nil || oldMeta == nil || changed != "" {
+		// Cached files would lose their annotations or metadata, or were
+		// generated for another build environment: regenerate everything.
+		oldManifest = &Manifest{Files: make(map[string]ManifestEntry)}
+	}
+	paths := collectGoFiles(e.Root, e.IncludeVendor)
+	if e.Tests {
+		paths = append(paths, testMainFiles(e.Root, e.IncludeVendor)...)
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		return e.inCacheDir(path) || !e.selected(path)
+	})
+	unmatched := e.unmatchedPattern(paths)
+	_ = unmatched // @inco: unmatched == "", -panic(categorize(ErrNoPackage, fmt.Errorf("package pattern %q (-pkgs) matches no package", unmatched)))
+	if !(unmatched == "") {
+		panic(categorize(ErrNoPackage, fmt.Errorf("package pattern %q (-pkgs) matches no package", unmatched)))
+	}
 
 	// Process files concurrently.
 	results := make([]fileResult, len(paths))
@@ -90,6 +203,10 @@ func (e *Engine) Run() {
 		workers = len(paths)
 	}
 
+	var progress *Progress
+	if !e.Quiet {
+		progress = StartProgress(e.Progress, "gen", e.Root, len(paths))
+	}
 	var wg sync.WaitGroup
 	var workerErr atomic.Value // stores first panic as error
 	ch := make(chan int, len(paths))
@@ -104,96 +221,205 @@ func (e *Engine) Run() {
 			defer wg.Done()
 			defer func() {
 				if r := recover(); r != nil {
-					workerErr.CompareAndSwap(nil, fmt.Errorf("%v", r))
+					workerErr.CompareAndSwap(nil, panicError(r))
 				}
 			}()
 			// Each goroutine gets its own fset to avoid contention.
 			fset := token.NewFileSet()
 			for idx := range ch {
-				path := paths[idx]
-				srcHash := hashFile(path)
-
-				// Check cache: source unchanged & shadow file exists → reuse.
-				if prev, ok := oldManifest.Files[path]; ok && prev.SrcHash == srcHash {
-					if _, err := os.Stat(prev.ShadowPath); err == nil {
-						results[idx] = fileResult{
-							Path: path, SrcHash: srcHash,
-							ShadowPath: prev.ShadowPath, Cached: true,
-						}
-						continue
-					}
-				}
-
-				// Cache miss — remove stale shadow before generating new one.
-				if old, ok := oldOverlay[path]; ok {
-					os.Remove(old)
-				}
-
-				// Parse and process.
-				f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
-				_ = err // @inco: err == nil, -panic(err)
-				if !(err == nil) {
-					panic(err)
-				}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:122
-				shadowData := e.generateShadow(path, f, fset)
-				results[idx] = fileResult{
-					Path: path, SrcHash: srcHash,
-					ShadowData: shadowData,
-				}
+				results[idx] = e.processFile(paths[idx], fset, oldManifest, oldAnnotations, oldMeta)
+				progress.Step(paths[idx])
 			}
 		}()
 	}
 	wg.Wait()
+	progress.Stop()
 
 	// Re-panic on main goroutine so guardPanic() in main() can catch it.
 	if v := workerErr.Load(); v != nil {
+		e.discardOverlay()
 		panic(v)
 	}
 
+	// Refuse to write anything the compiler would reject.
+	results = append(results, e.mustHelpers(results)...)
+	e.verifyShadows(results)
+
 	// Collect results sequentially — write shadows, build overlay & manifest.
 	newManifest := &Manifest{Files: make(map[string]ManifestEntry)}
+	annotations := make(map[string][]Annotation)
+	meta := make(map[string]ShadowMeta)
 	var skipped int
+	var failed []error // why the files of e.Failures failed
 	for _, r := range results {
+		if r.Err != nil {
+			e.Failures = append(e.Failures, failureDiagnostic(r.Path, r.Err))
+			failed = append(failed, r.Err)
+			continue
+		}
+		if r.Helper {
+			e.writeShadow(r.Path, r.ShadowData)
+			continue
+		}
+		maps.Copy(annotations, r.Annotations)
+		e.Warnings = append(e.Warnings, r.Warnings...)
+		if !e.Quiet {
+			for _, w := range r.Warnings {
+				fmt.Fprintf(os.Stderr, "inco: %s %s\n", e.Color.Warning("warning:"), w)
+			}
+		}
 		if r.Cached {
 			e.Overlay.Replace[r.Path] = r.ShadowPath
-			newManifest.Files[r.Path] = ManifestEntry{SrcHash: r.SrcHash, ShadowPath: r.ShadowPath}
+			newManifest.Files[r.Path] = e.manifestEntry(r.Path, r.SrcHash, r.ShadowPath)
 			skipped++
 		} else {
 			e.writeShadow(r.Path, r.ShadowData)
 			if sp, ok := e.Overlay.Replace[r.Path]; ok {
-				newManifest.Files[r.Path] = ManifestEntry{SrcHash: r.SrcHash, ShadowPath: sp}
+				newManifest.Files[r.Path] = e.manifestEntry(r.Path, r.SrcHash, sp)
 			}
 		}
-	}
-
-	// Clean up shadows for source files that no longer exist.
-	for srcPath, shadowPath := range oldOverlay {
-		if _, ok := newManifest.Files[srcPath]; !ok {
-			os.Remove(shadowPath)
+		if sp, ok := e.Overlay.Replace[r.Path]; ok {
+			r.Meta.Shadow = filepath.Base(sp)
+			meta[filepath.ToSlash(e.relPath(r.Path))] = r.Meta
 		}
 	}
 
-	if len(e.Overlay.Replace) > 0 {
+	e.writeAnnotations(annotations)
+	e.writeMeta(meta, env)
+	written := len(e.Overlay.Replace) > 0 || len(e.generated) > 0
+	if written {
 		e.writeOverlay()
 		e.writeManifest(newManifest)
 		processed := len(e.Overlay.Replace) - skipped
-		fmt.Fprintf(os.Stderr, "inco: overlay written to %s (%d file(s) mapped, %d processed, %d cached)\n",
-			filepath.Join(e.Root, ".inco_cache", "overlay.json"),
-			len(e.Overlay.Replace), processed, skipped)
+		if !e.Quiet {
+			fmt.Fprintf(os.Stderr, "inco: overlay written to %s (%d file(s) mapped, %d processed, %d cached)\n",
+				filepath.Join(e.CacheDir, "overlay.json"),
+				len(e.Overlay.Replace), processed, skipped)
+		}
 	} else {
+		// A previous overlay would map files to shadows just removed.
+		os.Remove(filepath.Join(e.CacheDir, "overlay.json"))
 		e.writeManifest(newManifest)
 	}
+
+	// Remove the shadows of changed and deleted source files.
+	e.sweepShadows()
+
+	_ = e.Failures // @inco: len(e.Failures) == 0, -panic(e.failuresError(failed, written))
+	if !(len(e.Failures) == 0) {
+		panic(e.failuresError(failed, written))
+	}
+}
+
+// failureDiagnostic describes a file Run could not process. Syntax and
+// directive errors carry their position; other failures point at the file.
+func failureDiagnostic(path string, err error) Diagnostic {
+	d := Diagnostic{Path: path, Line: 1, Column: 1, Code: CodeGenerate, Severity: SeverityError, Message: err.Error()}
+	var list scanner.ErrorList
+	var le lineError
+	switch {
+	case errors.As(err, &list) && len(list) > 0:
+		d.Line, d.Column, d.Code, d.Message = list[0].Pos.Line, list[0].Pos.Column, CodeFile, list[0].Msg
+	case errors.As(err, &le):
+		d.Line, d.Column, d.Message = le.line, 0, le.err.Error()
+	}
+	// A shadow's //line directives map lines but not columns: point at
+	// the directive on the line.
+	if d.Column == 0 {
+		d.Column = directiveColumn(path, d.Line)
+	}
+	return d
+}
+
+// directiveColumn returns the column of the directive on line of the file
+// at path, or 1 when the line holds none.
+func directiveColumn(path string, line int) int {
+	data, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -return(1)
+	if !(err == nil) {
+		return 1
+	}
+	lines := strings.Split(string(data), "\n")
+	_ = line // @inco: line >= 1 && line <= len(lines), -return(1)
+	if !(line >= 1 && line <= len(lines)) {
+		return 1
+	}
+	return max(strings.Index(lines[line-1], "@"), 0) + 1
+}
+
+// failuresError summarizes e.Failures, which errs caused: errors.Is
+// matches the category of any of them. written reports whether Run wrote
+// an overlay for the files that succeeded.
+func (e *Engine) failuresError(errs []error, written bool) error {
+	var lines []string
+	for _, d := range e.Failures {
+		lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", e.relPath(d.Path), d.Line, d.Column, d.Message))
+	}
+	head := fmt.Sprintf("%d file(s) failed", len(e.Failures))
+	if written {
+		head += "; the overlay covers the others"
+	}
+	return summarized{msg: head + ":\n\t" + strings.Join(lines, "\n\t"), errs: errs}
 }
 
 // ---------------------------------------------------------------------------
 // File processing
 // ---------------------------------------------------------------------------
 
-// generateShadow produces the shadow file content for a source file.
-// It is safe to call from multiple goroutines — it only reads e.Root
-// and uses the provided fset.
-func (e *Engine) generateShadow(path string, f *ast.File, fset *token.FileSet) []byte {
+// processFile returns the shadow of the source file at path, or its cached
+// counterpart when the manifest shows it unchanged. With e.KeepGoing, a
+// failure is returned in the result's Err instead of panicking.
+func (e *Engine) processFile(path string, fset *token.FileSet, oldManifest *Manifest, oldAnnotations map[string][]Annotation, oldMeta map[string]ShadowMeta) (result fileResult) {
+	if e.KeepGoing {
+		defer func() {
+			if r := recover(); r != nil {
+				result = fileResult{Path: path, Err: panicError(r)}
+			}
+		}()
+	}
+	srcHash := hashFile(path)
+
+	// Check cache: source unchanged & shadow file exists → reuse.
+	if prev, ok := oldManifest.Files[path]; ok && prev == e.manifestEntry(path, srcHash, prev.ShadowPath) {
+		if _, err := os.Stat(prev.ShadowPath); err == nil {
+			return fileResult{
+				Path: path, SrcHash: srcHash,
+				ShadowPath: prev.ShadowPath, Cached: true,
+				Annotations: fileAnnotations(oldAnnotations, e.relPath(path)),
+				Meta:        oldMeta[filepath.ToSlash(e.relPath(path))],
+				Warnings:    oldMeta[filepath.ToSlash(e.relPath(path))].Warnings,
+			}
+		}
+	}
+
+	// Cache miss — parse and process; sweepShadows removes the old shadow.
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	joinContinuations(f, fset)
+	r := e.generateShadow(path, f, fset)
+	r.Path, r.SrcHash = path, srcHash
+	return r
+}
+
+// generateShadow produces the shadow file content for a source file, with
+// its contract size warnings and annotations; the caller sets Path and
+// SrcHash. It is safe to call from multiple goroutines — it only reads
+// e.Root and uses the provided fset.
+func (e *Engine) generateShadow(path string, f *ast.File, fset *token.FileSet) fileResult {
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	return e.shadowOf(path, src, f, fset)
+}
+
+// shadowOf is generateShadow for the file at path holding src, f being
+// its syntax tree.
+func (e *Engine) shadowOf(path string, src []byte, f *ast.File, fset *token.FileSet) fileResult {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:180
 	if !(path != "") {
 		panic("generateShadow: empty path")
@@ -203,104 +429,488 @@ func (e *Engine) generateShadow(path string, f *ast.File, fset *token.FileSet) [
 		panic("generateShadow: nil AST")
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:182
-	// 1. Collect directive lines from AST comments.
-	directives := make(map[int]*Directive) // 1-based line → Directive
+	linePath := e.linePath(path)
+
+	// 1. Split the source into lines.
+	lines := splitLines(src)
+
+	// 2. Collect injectable directives, then apply tag filters and any
+	// mutation under test.
+	standalone, inline := collectDirectives(f, fset, lines)
+	if testFileRe.MatchString(path) {
+		keepTestMain(f, fset, standalone, inline)
+	}
+	annotations := e.annotate(e.relPath(path), standalone, inline)
+	directives := make(map[int][]*Directive) // 1-based line → directives in source order
+	for _, m := range []map[int][]*Directive{standalone, inline} {
+		// In line order, so that a file with several directives failing
+		// to resolve always reports the same one.
+		for _, lineNum := range slices.Sorted(maps.Keys(m)) {
+			ds := m[lineNum]
+			ds = slices.DeleteFunc(ds, func(d *Directive) bool { return !e.tagEnabled(d) })
+			for i, d := range ds {
+				ds[i] = e.resolveInit(e.redact(e.resolveReturn(e.resolveEnsure(e.resolveEntry(e.resolveCtxHas(e.resolveMessages(d, path, lineNum), f, fset, path, lineNum), f, fset, path, lineNum), path, lineNum), f, fset, path, lineNum)), f, fset, path, lineNum)
+			}
+			if mu := e.Mutation; mu != nil && mu.Path == path && mu.Line == lineNum && mu.Index < len(ds) {
+				mutated := *ds[mu.Index]
+				mutated.Expr = mu.Expr
+				ds[mu.Index] = &mutated
+			}
+			if len(ds) == 0 {
+				delete(m, lineNum)
+				continue
+			}
+			m[lineNum] = ds
+			directives[lineNum] = ds
+		}
+	}
+
+	// 3. Refuse contracts where injected code would be illegal or unsafe.
+	for _, lineNum := range slices.Sorted(maps.Keys(directives)) {
+		why := restrictedContext(f, fset, path, lineNum)
+		_ = why // @inco: why == "", -panic(e.directiveError(path, lineNum, fmt.Errorf("cannot inject contract: %s", why)))
+		if !(why == "") {
+			panic(e.directiveError(path, lineNum, fmt.Errorf("cannot inject contract: %s", why)))
+		}
+	}
+
+	// A -default fallback needs a variable to assign.
+	for _, lineNum := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[lineNum] {
+			_ = d // @inco: d.Action != ActionDefault || d.Target != "", -panic(e.directiveError(path, lineNum, errors.New(noDefaultTarget(d))))
+			if !(d.Action != ActionDefault || d.Target != "") {
+				panic(e.directiveError(path, lineNum, errors.New(noDefaultTarget(d))))
+			}
+		}
+	}
+
+	// A directive bound with -> must sit next to its statement.
+	for i, m := range []map[int][]*Directive{standalone, inline} {
+		for _, lineNum := range slices.Sorted(maps.Keys(m)) {
+			bind := m[lineNum][0].Bind
+			_ = bind // @inco: bind != "", -continue
+			if !(bind != "") {
+				continue
+			}
+			err := bindingError(f, fset, lineNum, i == 1, bind)
+			_ = err // @inco: err == nil, -panic(e.directiveError(path, lineNum, err))
+			if !(err == nil) {
+				panic(e.directiveError(path, lineNum, err))
+			}
+		}
+	}
+
+	// -continue and -break need a statement to leave.
+	for _, lineNum := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[lineNum] {
+			err := branchError(f, fset, lineNum, d)
+			_ = err // @inco: err == nil, -panic(e.directiveError(path, lineNum, err))
+			if !(err == nil) {
+				panic(e.directiveError(path, lineNum, err))
+			}
+		}
+	}
+
+	// Directives on a function without a body have nowhere to go;
+	// refuse them rather than drop them.
 	for _, cg := range f.Comments {
 		for _, c := range cg.List {
-			d := ParseDirective(c.Text)
-			if d != nil {
-				line := fset.Position(c.Pos()).Line
-				directives[line] = d
+			fn := bodilessFunc(f, c)
+			_ = fn // @inco: fn != nil, -continue
+			if !(fn != nil) {
+				continue
+			}
+			ds := slices.DeleteFunc(ParseDirectives(c.Text), func(d *Directive) bool { return !e.tagEnabled(d) })
+			_ = ds // @inco: len(ds) == 0, -panic(e.directiveError(path, srcLine(fset, c.Pos()), fmt.Errorf("cannot inject contract: %s", bodilessWhy(fn))))
+			if !(len(ds) == 0) {
+				panic(e.directiveError(path, srcLine(fset, c.Pos()), fmt.Errorf("cannot inject contract: %s", bodilessWhy(fn))))
 			}
 		}
 	}
 
-	// 2. Read source as lines.
-	src, err := os.ReadFile(path)
-	_ = err // @inco: err == nil, -panic(err)
-	if !(err == nil) {
-		panic(err)
+	// 4. Move loop invariants to the top of their loop body.
+	invariants := make(map[int][]invariantSite) // line of the loop body's { → invariants
+	for _, m := range []map[int][]*Directive{standalone, inline} {
+		for lineNum, ds := range m {
+			var rest []*Directive
+			for _, d := range ds {
+				if d.Kind != KindInvariant {
+					rest = append(rest, d)
+					continue
+				}
+				loop := enclosingLoopBody(f, fset, lineNum)
+				_ = loop // @inco: loop != nil, -panic(e.directiveError(path, lineNum, fmt.Errorf("@invariant %s is not inside a for loop body", d.Expr)))
+				if !(loop != nil) {
+					panic(e.directiveError(path, lineNum, fmt.Errorf("@invariant %s is not inside a for loop body", d.Expr)))
+				}
+				top := srcLine(fset, loop.Lbrace)
+				invariants[top] = append(invariants[top], invariantSite{d, lineNum})
+			}
+			if len(rest) == 0 {
+				delete(m, lineNum)
+			} else {
+				m[lineNum] = rest
+			}
+		}
+	}
+	for _, sites := range invariants {
+		sort.SliceStable(sites, func(i, j int) bool { return sites[i].line < sites[j].line })
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:197
-	lines := strings.Split(string(src), "\n")
 
-	// 3. Classify directives as standalone or inline using AST.
-	standalone := make(map[int]*Directive)
-	inline := make(map[int]*Directive)
+	// 5. Track Close calls for @ensure -closed until the enclosing body ends.
+	// A function tracks each name once: a second directive for it would
+	// declare its flag again and wrap the same calls twice.
+	closeCalls := make(map[int][]int) // 1-based line → byte offsets of tracked ident.Close() calls
+	sites := closeCallSites(f, fset, lines)
+	tracked := make(map[string]bool) // name and the line its function ends on
+	var repeated []*Directive
+	for _, lineNum := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[lineNum] {
+			_ = d // @inco: d.Kind == KindEnsureClosed, -continue
+			if !(d.Kind == KindEnsureClosed) {
+				continue
+			}
+			// An inline directive may track a name its own statement declares.
+			declared := declaredBefore(f, fset, lineNum+1)[d.Expr]
+			_ = declared // @inco: declared, -panic(e.directiveError(path, lineNum, fmt.Errorf("@ensure -closed %s: %s is not declared in the enclosing function", d.Expr, d.Expr)))
+			if !(declared) {
+				panic(e.directiveError(path, lineNum, fmt.Errorf("@ensure -closed %s: %s is not declared in the enclosing function", d.Expr, d.Expr)))
+			}
+			end, _ := enclosingBodyEnd(f, fset, lineNum)
+			key := fmt.Sprintf("%s:%d", d.Expr, end)
+			if tracked[key] {
+				repeated = append(repeated, d)
+				continue
+			}
+			tracked[key] = true
+			for _, c := range sites[d.Expr] {
+				if c.line > lineNum && c.line <= end && !slices.Contains(closeCalls[c.line], c.offset) {
+					closeCalls[c.line] = append(closeCalls[c.line], c.offset)
+				}
+			}
+		}
+	}
+	isRepeated := func(d *Directive) bool { return slices.Contains(repeated, d) }
+	for _, m := range []map[int][]*Directive{standalone, inline} {
+		for lineNum, ds := range m {
+			if ds = slices.DeleteFunc(slices.Clone(ds), isRepeated); len(ds) > 0 {
+				m[lineNum] = ds
+			} else {
+				delete(m, lineNum)
+			}
+		}
+	}
 
-	stmtLines := collectStmtLines(f, fset)
-	for lineNum, d := range directives {
-		idx := lineNum - 1
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:206
-		if !(idx >= 0 && idx < len(lines)) {
+	// Channel operations carrying @must become a select that times out.
+	// They are checked in every build but only generated in test builds.
+	musts := make(map[int]string) // 1-based line → select replacing it
+	mustZero := false
+	isMust := func(d *Directive) bool { return d.Kind == KindMust }
+	for _, lineNum := range slices.Sorted(maps.Keys(directives)) {
+		i := slices.IndexFunc(directives[lineNum], isMust)
+		_ = i // @inco: i >= 0, -continue
+		if !(i >= 0) {
 			continue
 		}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:207
-		trimmed := strings.TrimSpace(lines[idx])
-		isCommentLine := strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*")
-		if isCommentLine {
-			standalone[lineNum] = d
-		} else if stmtLines[lineNum] {
-			inline[lineNum] = d
+		d := directives[lineNum][i]
+		_, err := mustTimeout(d)
+		if err == nil && len(standalone[lineNum]) > 0 {
+			err = fmt.Errorf("@must(%s) must end a line holding a channel send or receive", d.Expr)
+		}
+		var stmt ast.Stmt
+		if err == nil {
+			stmt, err = mustStmt(f, fset, lines, lineNum, d)
+		}
+		_ = err // @inco: err == nil, -panic(e.directiveError(path, lineNum, err))
+		if !(err == nil) {
+			panic(e.directiveError(path, lineNum, err))
+		}
+		if e.Must {
+			code, zero := e.generateMust(d, stmt, src, fset, extractIndent(lines[lineNum-1]), path, lineNum)
+			musts[lineNum], mustZero = code, mustZero || zero
+		}
+
+		// The select replaces the line itself; other directives on it are
+		// generated after it as usual.
+		if ds := slices.DeleteFunc(slices.Clone(inline[lineNum]), isMust); len(ds) > 0 {
+			inline[lineNum] = ds
+		} else {
+			delete(inline, lineNum)
 		}
+		if ds := slices.DeleteFunc(slices.Clone(directives[lineNum]), isMust); !e.Must && len(ds) > 0 {
+			directives[lineNum] = ds
+		} else if !e.Must {
+			delete(directives, lineNum)
+		}
+	}
+
+	// 6. Resolve imports needed by directive expressions and actions.
+	imports := e.missingImports(path, f, fset, directives)
+	if e.LogViolations && logsViolations(directives) || e.CountHits && countsHits(directives) {
+		imports = append(imports, importSpec{Name: incologName, Path: incologPath})
 	}
+	if len(musts) > 0 {
+		imports = append(imports, importSpec{Name: mustTimeName, Path: "time"})
+	}
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Path < imports[j].Path })
+	importLine := importInsertLine(f, fset)
 
-	// 4. Build output.
+	// 7. Build output.
 	var output []string
 	prevWasDirective := false
 
 	for idx, line := range lines {
 		lineNum := idx + 1
+		if offsets, ok := closeCalls[lineNum]; ok {
+			line = rewriteCloseCalls(line, offsets)
+		}
+		code, must := musts[lineNum]
+		if must {
+			line = code
+		}
 
-		if d, ok := standalone[lineNum]; ok {
+		if ds, ok := standalone[lineNum]; ok {
 			indent := extractIndent(line)
-			output = append(output, fmt.Sprintf("//line %s:%d", path, lineNum))
-			output = append(output, e.generateIfBlock(d, indent, path, lineNum))
+			for _, d := range ds {
+				output = append(output, e.generateBlock(d, indent, path, lineNum))
+			}
 			prevWasDirective = true
-		} else if d, ok := inline[lineNum]; ok {
+		} else if ds, ok := inline[lineNum]; ok {
+			if prevWasDirective {
+				output = append(output, fmt.Sprintf("//line %s:%d", linePath, lineNum))
+			}
 			output = append(output, line)
 			indent := extractIndent(line)
-			output = append(output, e.generateIfBlock(d, indent, path, lineNum))
+			for _, d := range ds {
+				output = append(output, e.generateBlock(d, indent, path, lineNum))
+			}
 			prevWasDirective = true
 		} else {
 			if prevWasDirective {
-				output = append(output, fmt.Sprintf("//line %s:%d", path, lineNum))
+				output = append(output, fmt.Sprintf("//line %s:%d", linePath, lineNum))
 				prevWasDirective = false
 			}
 			output = append(output, line)
+			prevWasDirective = must
+		}
+
+		if sites, ok := invariants[lineNum]; ok {
+			indent := extractIndent(line) + "\t"
+			for _, s := range sites {
+				output = append(output, e.generateIfBlock(s.d, indent, path, s.line))
+			}
+			prevWasDirective = true
+		}
+
+		if lineNum == importLine && len(imports) > 0 {
+			output = append(output, generateImports(imports, linePath, lineNum+1)...)
 		}
 	}
 
-	// 5. Add missing imports.
-	content := strings.Join(output, "\n")
-	content = e.addMissingImports(content, f, directives)
+	if mustZero && e.mustZero != "" {
+		output = append(output, "", mustZeroDecl(e.mustZero))
+	}
 
-	return []byte(content)
+	shadow := joinLines(output, src)
+
+	// 8. cgo compiles the preamble of a file importing "C" as C code: it
+	// must reach the shadow unchanged.
+	if _, cgo := cgoPreamble(f); cgo {
+		err := checkCgoShadow(e.relPath(path), fset, f, src, shadow)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+	}
+	warnings := e.Limits.check(f, fset, e.relPath(path), directives)
+	warnings = append(warnings, shadowWarnings(f, fset, e.relPath(path), directives)...)
+	warnings = append(warnings, entryWarnings(f, fset, e.relPath(path), directives)...)
+	if len(directives) > 0 {
+		warnings = append(warnings, formatDrift(e.relPath(path), src, f, fset)...)
+	}
+	meta := shadowMeta(directives, imports, src, shadow)
+	meta.Warnings = warnings
+	return fileResult{
+		ShadowData:  shadow,
+		Warnings:    warnings,
+		Annotations: annotations,
+		Meta:        meta,
+	}
 }
 
 // ---------------------------------------------------------------------------
 // Code generation
 // ---------------------------------------------------------------------------
 
+// generateBlock returns the injected code for a directive of any kind. It
+// starts with a //line directive mapping it to the directive's line.
+func (e *Engine) generateBlock(d *Directive, indent, path string, line int) string {
+	switch d.Kind {
+	case KindEnsureClosed:
+		return e.lineDirective(path, line) + e.generateEnsureClosed(d, indent, path, line)
+	case KindEnsure:
+		return e.generateEnsure(d, indent, path, line)
+	case KindRecvOnly, KindSendOnly:
+		return e.lineDirective(path, line) + generateChanAssertion(d, indent)
+	default: // KindRequire
+		return e.generateIfBlock(d, indent, path, line)
+	}
+}
+
+// generateEnsureClosed returns the tracking shim for @ensure -closed.
+// A flag is declared at the directive and set by every rewritten Close
+// call; a deferred check panics if the function returns without it. A
+// function unwinding from a panic has not returned: the check lets that
+// panic through instead of hiding it behind its own. A logged violation
+// hides nothing and needs no such care. Every line is mapped to the
+// directive.
+//
+//	_inco_closed_f := false
+//	defer func() {
+//	    if !_inco_closed_f {
+//	        if _inco_panic := recover(); _inco_panic != nil {
+//	            panic(_inco_panic)
+//	        }
+//	        panic(...)
+//	    }
+//	}()
+func (e *Engine) generateEnsureClosed(d *Directive, indent, path string, line int) string {
+	flag := closedFlagName(d.Expr)
+	msg := fmt.Sprintf("inco violation: %s not closed before return (at %s:%d)", d.Expr, e.relPath(path), line)
+	at := e.lineDirective(path, line)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s := false\n", indent, flag)
+	fmt.Fprintf(&b, "%s%sdefer func() {\n", at, indent)
+	fmt.Fprintf(&b, "%s%s\tif !%s {\n", at, indent, flag)
+	if e.LogViolations {
+		fmt.Fprintf(&b, "%s%s\t\t%s\n", at, indent, e.logViolation(d, path, line, "nil"))
+	} else {
+		fmt.Fprintf(&b, "%s%s\t\tif _inco_panic := recover(); _inco_panic != nil {\n", at, indent)
+		fmt.Fprintf(&b, "%s%s\t\t\tpanic(_inco_panic)\n", at, indent)
+		fmt.Fprintf(&b, "%s%s\t\t}\n", at, indent)
+		fmt.Fprintf(&b, "%s%s\t\tpanic(%q)\n", at, indent, msg)
+	}
+	fmt.Fprintf(&b, "%s%s\t}\n", at, indent)
+	fmt.Fprintf(&b, "%s%s}()", at, indent)
+	return b.String()
+}
+
+// generateEnsure returns the check of an @ensure postcondition, deferred
+// to when the function returns. The values its old calls read are copied
+// at the directive first, in a block of their own so that the snapshots
+// of several postconditions do not collide:
+//
+//	{
+//	    _inco_old0 := s.count
+//	    defer func() {
+//	        if !(s.count == _inco_old0+1) {
+//	            panic(...)
+//	        }
+//	    }()
+//	}
+func (e *Engine) generateEnsure(d *Directive, indent, path string, line int) string {
+	check, olds, _ := ensureSnapshots(d) // resolveEnsure rejected the errors
+	at := e.lineDirective(path, line)
+	inner := indent
+	var b strings.Builder
+	if len(olds) > 0 {
+		inner += "\t"
+		b.WriteString(at + indent + "{\n")
+		for i, x := range olds {
+			fmt.Fprintf(&b, "%s%s%s := %s\n", at, inner, snapshotName(i), x)
+		}
+	}
+	fmt.Fprintf(&b, "%s%sdefer func() {\n%s\n%s}()", at, inner, e.generateCheck(d, check, inner+"\t", path, line), inner)
+	if len(olds) > 0 {
+		b.WriteString("\n" + indent + "}")
+	}
+	return b.String()
+}
+
+// generateChanAssertion returns dead code that compiles only when the
+// channel can be used in the asserted direction: a receive for -recvonly,
+// a close (illegal on receive-only channels) for -sendonly. The compiler
+// removes it. It is a single line, so a violation is a compile error at
+// the directive's line.
+//
+//	if false { <-ch }
+func generateChanAssertion(d *Directive, indent string) string {
+	stmt := "<-" + d.Expr
+	if d.Kind == KindSendOnly {
+		stmt = "close(" + d.Expr + ")"
+	}
+	return fmt.Sprintf("%sif false { %s }", indent, stmt)
+}
+
 // generateIfBlock returns the text of the injected if-statement.
 //
 //	if !(expr) {
 //	    panic(...)
 //	}
+//
+// A conditional contract (Cond set) is wrapped in its guard:
+//
+//	if cond {
+//	    if !(expr) {
+//	        panic(...)
+//	    }
+//	}
+//
+// Calls the action repeats are made once, in the if-statement's init (see
+// hoistCalls).
 func (e *Engine) generateIfBlock(d *Directive, indent, path string, line int) string {
-	cond := fmt.Sprintf("!(%s)", d.Expr)
-	body := e.buildPanicBody(d, path, line)
-	return fmt.Sprintf("%sif %s {\n%s\t%s\n%s}", indent, cond, indent, body, indent)
+	return e.generateCheck(d, d, indent, path, line)
+}
+
+// generateCheck returns the if-statement of generateIfBlock checking the
+// guard, expression and action of check, a rewrite of d such as the
+// snapshots of a postcondition, while messages and logs show d's.
+func (e *Engine) generateCheck(d, check *Directive, indent, path string, line int) string {
+	inner := indent
+	if check.Cond != "" {
+		inner += "\t"
+	}
+	init, expr, args := hoistCalls(check.Expr, check.ActionArgs)
+	cond := fmt.Sprintf("!(%s)", expr)
+	if init != "" {
+		cond = init + "; " + cond
+	}
+	hoisted := *d
+	hoisted.ActionArgs = args
+	body := e.buildPanicBody(&hoisted, path, line)
+	// Each line holding the directive's text is mapped to the directive,
+	// so that compile errors in it, and the panic's trace (see
+	// ViolationScanner), point there.
+	at := e.lineDirective(path, line)
+	block := fmt.Sprintf("%s%sif %s {\n%s%s\t%s\n%s}", at, inner, cond, at, inner, body, inner)
+	if e.CountHits {
+		block = fmt.Sprintf("%s%s%s.Hit(%q, %d)\n%s", at, inner, incologName, filepath.ToSlash(e.relPath(path)), line, block)
+	}
+	_ = check.Cond // @inco: check.Cond != "", -return(block)
+	if !(check.Cond != "") {
+		return block
+	}
+	return fmt.Sprintf("%s%sif %s {\n%s\n%s}", at, indent, check.Cond, block, indent)
+}
+
+// lineDirective returns the //line directive, with its newline, mapping
+// the generated line that follows it to line of the file at path.
+func (e *Engine) lineDirective(path string, line int) string {
+	return fmt.Sprintf("//line %s:%d\n", e.linePath(path), line)
 }
 
 // buildPanicBody generates the action statement for @inco:.
 //
-//   - ActionReturn + args → return arg0, arg1, ...
+//   - ActionReturn + args → return arg0, arg1, ... (zero-filled, see returnValues)
 //   - ActionReturn bare   → return
-//   - ActionContinue      → continue
-//   - ActionBreak         → break
+//   - ActionContinue      → continue [label]
+//   - ActionBreak         → break [label]
+//   - ActionDefault       → target = arg
 //   - ActionPanic + args  → panic(arg)
 //   - ActionPanic default → panic("inco violation: <expr> (at file:line)")
+//
+// With LogViolations, a panic becomes a call logging the violation (see
+// logViolation).
 func (e *Engine) buildPanicBody(d *Directive, path string, line int) string {
 	switch d.Action {
 	case ActionReturn:
@@ -308,54 +918,290 @@ func (e *Engine) buildPanicBody(d *Directive, path string, line int) string {
 			return "return " + strings.Join(d.ActionArgs, ", ")
 		}
 		return "return"
-	case ActionContinue:
-		return "continue"
-	case ActionBreak:
-		return "break"
+	case ActionContinue, ActionBreak:
+		if len(d.ActionArgs) > 0 {
+			return d.Action.String() + " " + d.ActionArgs[0]
+		}
+		return d.Action.String()
+	case ActionDefault:
+		return d.Target + " = " + d.ActionArgs[0]
 	default: // ActionPanic
+		if e.LogViolations {
+			value := "nil"
+			if len(d.ActionArgs) > 0 {
+				value = d.ActionArgs[0]
+			}
+			return e.logViolation(d, path, line, value)
+		}
 		if len(d.ActionArgs) > 0 {
 			return "panic(" + d.ActionArgs[0] + ")"
 		}
-		relPath := path
-		if rel, err := filepath.Rel(e.Root, path); err == nil {
-			relPath = rel
-		}
-		msg := fmt.Sprintf("inco violation: %s (at %s:%d)", d.Expr, relPath, line)
+		msg := fmt.Sprintf("inco violation: %s (at %s:%d)", d.Expr, e.relPath(path), line)
 		return fmt.Sprintf("panic(%q)", msg)
 	}
 }
 
+// incologPath is the import path of the package logging violations under
+// LogViolations and evaluations under CountHits, and incologName the name
+// shadows import it as.
+const (
+	incologPath = "github.com/imnive-design/inco-go/incolog"
+	incologName = "inco_log"
+)
+
+// logViolation returns the call that logs a violation of d, directive of
+// path at line, with the panic value expression value:
+//
+//	inco_log.Violation("require", "api/user.go", 18, "len(name) > 0", nil)
+func (e *Engine) logViolation(d *Directive, path string, line int, value string) string {
+	return fmt.Sprintf("%s.Violation(%q, %q, %d, %q, %s)",
+		incologName, d.Kind.String(), filepath.ToSlash(e.relPath(path)), line, d.Expr, value)
+}
+
+// countsHits reports whether any of directives is a check, which records
+// its evaluation under CountHits.
+func countsHits(directives map[int][]*Directive) bool {
+	for _, ds := range directives {
+		for _, d := range ds {
+			if d.Kind.checksExpr() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// logsViolations reports whether any of directives would panic, and so
+// logs under LogViolations.
+func logsViolations(directives map[int][]*Directive) bool {
+	for _, ds := range directives {
+		for _, d := range ds {
+			if d.Kind == KindEnsureClosed || d.Kind == KindMust || d.Kind.checksExpr() && d.Action == ActionPanic {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relPath returns path relative to e.Root, or path itself if that fails.
+func (e *Engine) relPath(path string) string {
+	if rel, err := filepath.Rel(e.Root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// tagEnabled reports whether d survives the EnableTags/DisableTags
+// filters. Untagged directives are always enabled.
+func (e *Engine) tagEnabled(d *Directive) bool {
+	for _, tag := range d.Tags {
+		_ = tag // @inco: !slices.Contains(e.DisableTags, tag), -return(false)
+		if !(!slices.Contains(e.DisableTags, tag)) {
+			return false
+		}
+	}
+	if len(e.EnableTags) == 0 || len(d.Tags) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(d.Tags, func(tag string) bool {
+		return slices.Contains(e.EnableTags, tag)
+	})
+}
+
+// tagFilter returns a canonical description of the tag filters, recorded
+// in the manifest so that changing them invalidates cached shadows.
+//
+//	EnableTags [net io], DisableTags [legacy] → "+io,net -legacy"
+func (e *Engine) tagFilter() string {
+	var parts []string
+	if len(e.EnableTags) > 0 {
+		parts = append(parts, "+"+strings.Join(slices.Sorted(slices.Values(e.EnableTags)), ","))
+	}
+	if len(e.DisableTags) > 0 {
+		parts = append(parts, "-"+strings.Join(slices.Sorted(slices.Values(e.DisableTags)), ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// inCacheDir reports whether path lies inside CacheDir, so that shadows in
+// a cache directory configured under Root are never scanned as sources.
+func (e *Engine) inCacheDir(path string) bool {
+	rel, err := filepath.Rel(e.CacheDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// selected reports whether the file at path belongs to a package matched
+// by e.Packages. Without patterns every package is selected.
+func (e *Engine) selected(path string) bool {
+	_ = e.Packages // @inco: len(e.Packages) > 0, -return(true)
+	if !(len(e.Packages) > 0) {
+		return true
+	}
+	dir := filepath.ToSlash(e.relPath(filepath.Dir(path)))
+	return slices.ContainsFunc(e.Packages, func(pattern string) bool {
+		return matchPackage(pattern, dir)
+	})
+}
+
+// unmatchedPattern returns the first pattern of e.Packages that selects
+// none of the files at paths, or "" when each selects one.
+func (e *Engine) unmatchedPattern(paths []string) string {
+	for _, pattern := range e.Packages {
+		matched := slices.ContainsFunc(paths, func(path string) bool {
+			return matchPackage(pattern, filepath.ToSlash(e.relPath(filepath.Dir(path))))
+		})
+		_ = matched // @inco: matched, -return(pattern)
+		if !(matched) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// matchPackage reports whether the package in dir, slash-separated and
+// relative to the root, matches pattern: "./dir" selects one package,
+// "./dir/..." the tree rooted at dir. Patterns reaching outside the root
+// panic.
+func matchPackage(pattern, dir string) bool {
+	clean := path.Clean(filepath.ToSlash(pattern))
+	_ = clean // @inco: !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../"), -panic(fmt.Errorf("package pattern %q must be relative to the project root", pattern))
+	if !(!path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")) {
+		panic(fmt.Errorf("package pattern %q must be relative to the project root", pattern))
+	}
+	if clean == "..." {
+		return true
+	}
+	if tree, ok := strings.CutSuffix(clean, "/..."); ok {
+		return tree == dir || strings.HasPrefix(dir, tree+"/")
+	}
+	return clean == dir
+}
+
+// linePath returns the file name to embed in //line directives for path.
+//
+// By default this is the absolute source path. With TrimPath it is the
+// path relative to CacheDir: the compiler resolves relative //line
+// names against the directory of the file it compiles (the shadow), so
+// the result is the same absolute source path, which -trimpath then
+// rewrites to the module-relative form recorded for ordinary files —
+// without embedding the host directory layout in the shadow itself.
+func (e *Engine) linePath(path string) string {
+	_ = e.TrimPath // @inco: e.TrimPath, -return(path)
+	if !(e.TrimPath) {
+		return path
+	}
+	rel, err := filepath.Rel(e.CacheDir, path)
+	_ = err // @inco: err == nil, -return(path)
+	if !(err == nil) {
+		return path
+	}
+	return rel
+}
+
+// closedFlagName returns the tracking flag for an @ensure -closed target.
+func closedFlagName(ident string) string {
+	return "_inco_closed_" + ident
+}
+
+// closeSite is an ident.Close() call: its line and the byte offset of
+// ident within the line.
+type closeSite struct {
+	line, offset int
+}
+
+// closeCallSites returns the ident.Close() calls of f by ident. Only calls
+// written on one line are listed; strings and comments hold none.
+func closeCallSites(f *ast.File, fset *token.FileSet, lines []string) map[string][]closeSite {
+	sites := make(map[string][]closeSite)
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		_ = call // @inco: ok && len(call.Args) == 0, -return(true)
+		if !(ok && len(call.Args) == 0) {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		_ = sel // @inco: ok && sel.Sel.Name == "Close", -return(true)
+		if !(ok && sel.Sel.Name == "Close") {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		_ = id // @inco: ok, -return(true)
+		if !(ok) {
+			return true
+		}
+		pos := fset.PositionFor(call.Pos(), false)
+		if pos.Line <= len(lines) && strings.HasPrefix(lines[pos.Line-1][pos.Column-1:], id.Name+".Close()") {
+			sites[id.Name] = append(sites[id.Name], closeSite{pos.Line, pos.Column - 1})
+		}
+		return true
+	})
+	return sites
+}
+
+// rewriteCloseCalls wraps the ident.Close() calls starting at offsets in
+// line so that each sets the tracking flag before closing.
+//
+//	f.Close() → func() error { _inco_closed_f = true; return f.Close() }()
+func rewriteCloseCalls(line string, offsets []int) string {
+	for _, off := range slices.Backward(slices.Sorted(slices.Values(offsets))) {
+		ident, _, _ := strings.Cut(line[off:], ".Close()")
+		call := ident + ".Close()"
+		line = line[:off] + fmt.Sprintf("func() error { %s = true; return %s }()", closedFlagName(ident), call) + line[off+len(call):]
+	}
+	return line
+}
+
 // ---------------------------------------------------------------------------
 // Import management
 // ---------------------------------------------------------------------------
 
-// buildImportMap dynamically resolves package names to import paths by
-// querying the Go toolchain. The result is cached for the engine's lifetime
-// so that "go list" runs at most once per invocation.
-func (e *Engine) buildImportMap() map[string]string {
-	e.importOnce.Do(func() {
-		e.importMap = make(map[string]string)
-		ambiguous := make(map[string]bool)
-
-		// 1. All standard library packages.
-		e.collectPackages(ambiguous, "std")
+// listedPackage is a package reported by "go list".
+type listedPackage struct {
+	Name       string
+	ImportPath string
+	Dir        string
+	Imports    []string
+}
 
-		// 2. Packages already used in the module (covers third-party deps).
-		e.collectPackages(ambiguous, "-deps", "-e", "./...")
+// pkgGraph indexes the packages visible to the module: the standard
+// library plus the module's packages and their dependencies.
+type pkgGraph struct {
+	byName map[string][]string       // package name → import paths
+	byDir  map[string]*listedPackage // package directory → package
+}
 
-		// Remove ambiguous names (multiple import paths share a short name,
-		// e.g. "template" → text/template vs html/template).
-		for name := range ambiguous {
-			delete(e.importMap, name)
+// buildPkgGraph queries the Go toolchain for the packages directives may
+// reference. The result is cached for the engine's lifetime so that
+// "go list" runs at most twice per invocation.
+func (e *Engine) buildPkgGraph() *pkgGraph {
+	e.graphOnce.Do(func() {
+		e.graph = &pkgGraph{
+			byName: make(map[string][]string),
+			byDir:  make(map[string]*listedPackage),
 		}
+		// 1. All standard library packages.
+		e.collectPackages("std")
+		// 2. The module's packages and everything they import (covers
+		// third-party dependencies).
+		e.collectPackages("-deps", "./...")
 	})
-	return e.importMap
+	return e.graph
 }
 
-// collectPackages runs "go list" with the given patterns and records
-// each name → importPath pair in e.importMap.
-func (e *Engine) collectPackages(ambiguous map[string]bool, patterns ...string) {
-	args := append([]string{"list", "-e", "-f", "{{.Name}} {{.ImportPath}}"}, patterns...)
+// collectPackages runs "go list" with the given patterns, under
+// e.BuildTags, and records the packages in e.graph.
+func (e *Engine) collectPackages(patterns ...string) {
+	args := []string{"list", "-e", "-f", "{{.Name}}\t{{.ImportPath}}\t{{.Dir}}\t{{join .Imports \" \"}}"}
+	if e.BuildTags != "" {
+		args = append(args, "-tags="+e.BuildTags)
+	}
+	generated, remove := e.generatedOverlayFile()
+	defer remove()
+	if generated != "" {
+		args = append(args, "-overlay="+generated)
+	}
+	args = append(args, patterns...)
 	cmd := exec.Command("go", args...)
 	cmd.Dir = e.Root
 	out, err := cmd.Output()
@@ -363,67 +1209,221 @@ func (e *Engine) collectPackages(ambiguous map[string]bool, patterns ...string)
 	if !(err == nil) {
 		return
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:331
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:332
-		if !(line != "") {
-			continue
-		}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:333
-		parts := strings.SplitN(line, " ", 2)
-		valid := len(parts) == 2 && parts[0] != "" && parts[0] != "main"
+	// Lines are not trimmed: a package without imports ends in a tab.
+	for _, line := range strings.Split(string(out), "\n") {
+		parts := strings.Split(line, "\t")
+		valid := len(parts) == 4 && parts[0] != "" && parts[0] != "main"
 		_ = valid // @inco: valid, -continue
 		if !(valid) {
 			continue
 		}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:336
-		name, impPath := parts[0], parts[1]
-		// Skip internal and vendored packages — they are not freely importable.
-		internal := internalPkgRe.MatchString(impPath)
-		_ = internal // @inco: !internal, -continue
-		if !(!internal) {
+		p := &listedPackage{Name: parts[0], ImportPath: parts[1], Dir: parts[2], Imports: strings.Fields(parts[3])}
+		_, seen := e.graph.byDir[p.Dir]
+		_ = seen // @inco: !seen, -continue
+		if !(!seen) {
 			continue
 		}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:340
-		if existing, ok := e.importMap[name]; ok && existing != impPath {
-			ambiguous[name] = true
-		} else if !ambiguous[name] {
-			e.importMap[name] = impPath
+		e.graph.byDir[p.Dir] = p
+		e.graph.byName[p.Name] = append(e.graph.byName[p.Name], p.ImportPath)
+	}
+}
+
+// resolve returns the import path that package name refers to in code
+// in dir, or "" when there is none or the choice is ambiguous.
+//
+// Only packages dir may import are considered: vendored paths never, and
+// internal packages only from within the tree rooted at their parent.
+// When several candidates remain (e.g. text/template and html/template),
+// the one already imported by dir's package wins.
+func (g *pkgGraph) resolve(name, dir string) string {
+	p := g.lookupDir(dir)
+	from := ""
+	if p != nil {
+		from = p.ImportPath
+	}
+	var candidates []string
+	for _, impPath := range g.byName[name] {
+		if importable(from, impPath) {
+			candidates = append(candidates, impPath)
 		}
 	}
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	_ = p // @inco: p != nil, -return("")
+	if !(p != nil) {
+		return ""
+	}
+	candidates = slices.DeleteFunc(candidates, func(impPath string) bool {
+		return !slices.Contains(p.Imports, impPath)
+	})
+	_ = candidates // @inco: len(candidates) == 1, -return("")
+	if !(len(candidates) == 1) {
+		return ""
+	}
+	return candidates[0]
 }
 
-// pkgRefRe matches package-qualified identifiers like fmt.Errorf, errors.New.
-var pkgRefRe = regexp.MustCompile(`\b([a-zA-Z_]\w*)\.\w+`)
+// lookupDir returns the package in dir, resolving symlinks when the go
+// command reported the directory under its real path.
+func (g *pkgGraph) lookupDir(dir string) *listedPackage {
+	if p, ok := g.byDir[dir]; ok {
+		return p
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		return g.byDir[real]
+	}
+	return nil
+}
 
-// internalPkgRe matches import paths that are internal or vendored.
-var internalPkgRe = regexp.MustCompile(`(^|/)internal/|(^|/)vendor/`)
+// importable reports whether the package at import path from may import
+// impPath. from is "" when the importing package is unknown; the standard
+// library's own internal packages are never importable.
+func importable(from, impPath string) bool {
+	if vendorPkgRe.MatchString(impPath) {
+		return false
+	}
+	// i indexes the slash before the last "internal" element, counting
+	// the leading slash added to impPath.
+	i := strings.LastIndex("/"+impPath+"/", "/internal/")
+	_ = i // @inco: i >= 0, -return(true)
+	if !(i >= 0) {
+		return true
+	}
+	parent := impPath[:max(i-1, 0)]
+	return parent != "" && (from == parent || strings.HasPrefix(from, parent+"/"))
+}
 
-// addMissingImports re-parses the shadow content, detects package references
-// in directive action args, and adds missing imports via astutil.AddImport.
-func (e *Engine) addMissingImports(content string, origFile *ast.File, directives map[int]*Directive) string {
+// incoexprPath is the import path of the predicates contracts may call
+// as incoexpr.Between(...) and so on.
+const incoexprPath = "github.com/imnive-design/inco-go/incoexpr"
+
+// vendorPkgRe matches vendored import paths.
+var vendorPkgRe = regexp.MustCompile(`(^|/)vendor/`)
+
+// missingImports detects package references in directives that the
+// original file does not import and returns the imports to add, sorted
+// by path.
+//
+// A qualifier only counts as a package reference when no declaration at
+// the directive site shadows it: in "user.Name != \"\"" with a parameter
+// named user, user is the parameter, not os/user. Method expressions such
+// as (*T).Valid and instantiations such as Max[int] need no import.
+// Package names are resolved against the module's package graph, so
+// project, internal and third-party packages are found as well as the
+// standard library (see pkgGraph.resolve). incoexpr resolves to inco's
+// helper package even before the module imports it anywhere.
+func (e *Engine) missingImports(path string, origFile *ast.File, fset *token.FileSet, directives map[int][]*Directive) []importSpec {
 	// 1. Collect all package-qualified identifiers from directives.
 	needed := make(map[string]bool)
-	for _, d := range directives {
-		sources := d.ActionArgs
-		if d.Expr != "" {
-			sources = append(sources, d.Expr)
-		}
-		for _, s := range sources {
-			for _, match := range pkgRefRe.FindAllStringSubmatch(s, -1) {
-				needed[match[1]] = true
+	fileDecls := fileScopeNames(origFile)
+	for line, ds := range directives {
+		// The guard of an inline directive follows its statement, so
+		// names declared on the directive's own line are in scope.
+		local := declaredBefore(origFile, fset, line+1)
+		for _, d := range ds {
+			sources := slices.Clone(d.ActionArgs)
+			for _, s := range []string{d.Expr, d.Cond} {
+				if s != "" {
+					sources = append(sources, s)
+				}
+			}
+			for _, s := range sources {
+				for _, name := range selectorQualifiers(s) {
+					if !local[name] && !fileDecls[name] {
+						needed[name] = true
+					}
+				}
 			}
 		}
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:370
 	if !(len(needed) > 0) {
-		return content
+		return nil
+	}
+
+	// 2. Resolve each needed package not already bound in the file.
+	graph := e.buildPkgGraph()
+	bound := fileImportNames(origFile)
+	var toAdd []importSpec
+	for pkg := range needed {
+		_, isBound := bound[pkg]
+		_ = isBound // @inco: !isBound, -continue
+		if !(!isBound) {
+			continue
+		}
+		impPath := graph.resolve(pkg, filepath.Dir(path))
+		if impPath == "" && pkg == "incoexpr" {
+			impPath = incoexprPath
+		}
+		if impPath != "" {
+			if _, spec := resolveImport(bound, pkg, impPath); spec != nil {
+				toAdd = append(toAdd, *spec)
+			}
+		}
+	}
+	sort.Slice(toAdd, func(i, j int) bool { return toAdd[i].Path < toAdd[j].Path })
+	return toAdd
+}
+
+// selectorQualifiers returns the identifiers that qualify a selector in the
+// Go expression s: "fmt.Sprint(x.y)" → [fmt x]. Selectors inside string
+// literals are not references. s that does not parse yields nothing;
+// shadow verification reports it.
+func selectorQualifiers(s string) []string {
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:371
+	var names []string
+	ast.Inspect(x, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				names = append(names, id.Name)
+			}
+		}
+		return true
+	})
+	return names
+}
 
-	// 2. Determine which packages are already imported.
-	imported := make(map[string]bool)
-	for _, imp := range origFile.Imports {
+// fileScopeNames returns the names of f's top-level declarations, which
+// shadow any package of the same name.
+func fileScopeNames(f *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				names[decl.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, id := range spec.Names {
+						names[id.Name] = true
+					}
+				case *ast.TypeSpec:
+					names[spec.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// importSpec is an import declaration to be added to a shadow file.
+type importSpec struct {
+	Name string // local alias; empty to use the package's own name
+	Path string // import path
+}
+
+// fileImportNames returns the local name → import path bindings of f.
+// Unaliased imports are keyed by the last path segment.
+func fileImportNames(f *ast.File) map[string]string {
+	bound := make(map[string]string)
+	for _, imp := range f.Imports {
 		path := strings.Trim(imp.Path.Value, `"`)
 		// Use local name if aliased, otherwise last segment.
 		var name string
@@ -433,49 +1433,61 @@ func (e *Engine) addMissingImports(content string, origFile *ast.File, directive
 			parts := strings.Split(path, "/")
 			name = parts[len(parts)-1]
 		}
-		imported[name] = true
+		bound[name] = path
 	}
+	return bound
+}
 
-	// 3. Find which needed packages are missing.
-	importMap := e.buildImportMap()
-	var toAdd []string
-	for pkg := range needed {
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:391
-		if !(!imported[pkg]) {
-			continue
-		}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:392
-		if _, ok := importMap[pkg]; ok {
-			toAdd = append(toAdd, pkg)
+// resolveImport returns the qualifier generated code must use to refer to
+// package name at impPath, and the import to add (nil when the file already
+// imports impPath). When name is already bound to a different package, the
+// new import is aliased as inco_<name> so the generated selector cannot
+// resolve to the wrong package.
+func resolveImport(bound map[string]string, name, impPath string) (string, *importSpec) {
+	for local, p := range bound {
+		if p == impPath && local != "_" && local != "." {
+			return local, nil
 		}
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:396
-	if !(len(toAdd) > 0) {
-		return content
+	if _, taken := bound[name]; !taken {
+		return name, &importSpec{Path: impPath}
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:397
+	alias := "inco_" + name
+	return alias, &importSpec{Name: alias, Path: impPath}
+}
 
-	// 4. Re-parse the shadow content and add imports via astutil.
-	fset := token.NewFileSet()
-	shadowAST, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	_ = err // @inco: err == nil, -return(content)
-	if !(err == nil) {
-		return content
-	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:402
-	for _, pkg := range toAdd {
-		astutil.AddImport(fset, shadowAST, importMap[pkg])
+// importInsertLine returns the 1-based line after which new import
+// declarations are inserted: the end of the last existing import
+// declaration, or the package clause when the file has no imports.
+// Positions come from the original file's FileSet, so the result indexes
+// directly into the unmodified source lines.
+func importInsertLine(f *ast.File, fset *token.FileSet) int {
+	line := srcLine(fset, f.Name.End())
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if ok && gd.Tok == token.IMPORT {
+			line = srcLine(fset, gd.End())
+		}
 	}
+	return line
+}
 
-	// 5. Re-render.
-	var buf strings.Builder
-	err = format.Node(&buf, fset, shadowAST)
-	_ = err // @inco: err == nil, -return(content)
-	if !(err == nil) {
-		return content
+// generateImports returns one import declaration per spec followed by a
+// //line directive that restores the position of the next source line.
+//
+//	import "fmt"
+//	import inco_errors "errors"
+//	//line /abs/path/main.go:4
+func generateImports(specs []importSpec, path string, nextLine int) []string {
+	var out []string
+	for _, spec := range specs {
+		if spec.Name != "" {
+			out = append(out, fmt.Sprintf("import %s %q", spec.Name, spec.Path))
+		} else {
+			out = append(out, fmt.Sprintf("import %q", spec.Path))
+		}
 	}
-//line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:410
-	return buf.String()
+	return append(out, fmt.Sprintf("//line %s:%d", path, nextLine))
 }
 
 // ---------------------------------------------------------------------------
@@ -483,8 +1495,7 @@ func (e *Engine) addMissingImports(content string, origFile *ast.File, directive
 // ---------------------------------------------------------------------------
 
 func (e *Engine) writeShadow(origPath string, content []byte) {
-	cacheDir := filepath.Join(e.Root, ".inco_cache")
-	err := os.MkdirAll(cacheDir, 0o755)
+	err := os.MkdirAll(e.CacheDir, 0o755)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
@@ -492,12 +1503,9 @@ func (e *Engine) writeShadow(origPath string, content []byte) {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:421
 
 	hash := sha256.Sum256(content)
-	shadowName := fmt.Sprintf("%s_%x.go",
-		strings.TrimSuffix(filepath.Base(origPath), ".go"),
-		hash[:8])
-	shadowPath := filepath.Join(cacheDir, shadowName)
+	shadowPath := filepath.Join(e.CacheDir, e.shadowName(origPath, hash[:8]))
 
-	err = os.WriteFile(shadowPath, content, 0o644)
+	err = writeFileAtomic(shadowPath, content, 0o644)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
@@ -506,21 +1514,37 @@ func (e *Engine) writeShadow(origPath string, content []byte) {
 	e.Overlay.Replace[origPath] = shadowPath
 }
 
+// shadowName returns the cache file name of the shadow of origPath whose
+// content hash starts with hash: the package directory relative to the
+// root with its separators as "_" (the root's own name for the root
+// package), the file name and the hash, joined by "__", as in
+// internal_store__db__3f2a9c1e0b7d4a56.go. The names keep shadows of the
+// same package together and tell a reviewer of overlay.json which file
+// each one stands for.
+func (e *Engine) shadowName(origPath string, hash []byte) string {
+	pkg := filepath.Base(e.Root)
+	if rel, err := filepath.Rel(e.Root, filepath.Dir(origPath)); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		pkg = strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+	}
+	return fmt.Sprintf("%s__%s__%x.go", pkg, strings.TrimSuffix(filepath.Base(origPath), ".go"), hash)
+}
+
+// writeOverlay writes overlay.json. encoding/json sorts the map keys, so
+// the file only changes where a shadow does.
 func (e *Engine) writeOverlay() {
-	cacheDir := filepath.Join(e.Root, ".inco_cache")
-	err := os.MkdirAll(cacheDir, 0o755)
+	err := os.MkdirAll(e.CacheDir, 0o755)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:437
-	data, err := json.MarshalIndent(e.Overlay, "", "  ")
+	data, err := json.MarshalIndent(e.composedOverlay(), "", "  ")
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:439
-	err = os.WriteFile(filepath.Join(cacheDir, "overlay.json"), data, 0o644)
+	err = writeFileAtomic(filepath.Join(e.CacheDir, "overlay.json"), data, 0o644)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
@@ -528,27 +1552,79 @@ func (e *Engine) writeOverlay() {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:441
 }
 
-// loadOverlayIfExists reads the previous overlay.json and returns the
-// shadow path map. Returns nil if the file does not exist.
-func (e *Engine) loadOverlayIfExists() map[string]string {
-	overlayPath := filepath.Join(e.Root, ".inco_cache", "overlay.json")
-	data, err := os.ReadFile(overlayPath)
-	if err != nil {
+// CheckOverlay returns an error unless the overlay.json in cacheDir, if
+// any, parses and every shadow it maps a file to exists, so that a cache
+// damaged by an interrupted run or by hand is reported as such instead of
+// as a confusing go build failure.
+func CheckOverlay(cacheDir string) error {
+	path := filepath.Join(cacheDir, "overlay.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
 		return nil
 	}
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
 	var ov Overlay
-	if json.Unmarshal(data, &ov) != nil {
-		return nil
+	err = json.Unmarshal(data, &ov)
+	_ = err // @inco: err == nil, -return(fmt.Errorf("%s is corrupt: %w", path, err))
+	if !(err == nil) {
+		return fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	for _, src := range slices.Sorted(maps.Keys(ov.Replace)) {
+		if ov.Replace[src] == "" {
+			continue // src is deleted
+		}
+		_, err := os.Stat(ov.Replace[src])
+		_ = err // @inco: err == nil, -return(fmt.Errorf("%s maps %s to a missing shadow %s", path, src, ov.Replace[src]))
+		if !(err == nil) {
+			return fmt.Errorf("%s maps %s to a missing shadow %s", path, src, ov.Replace[src])
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory renamed over it, so that an interrupted write leaves either
+// the old content or the new, never a truncated file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), perm)
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
 	}
-	return ov.Replace
+	return os.Rename(tmp.Name(), path)
 }
 
 // ---------------------------------------------------------------------------
 // Manifest I/O (incremental gen)
 // ---------------------------------------------------------------------------
 
+// discardOverlay removes overlay.json and the manifest when Run fails: a
+// build must not go on with the shadows of an earlier Run, and the next
+// Run generates every file again.
+func (e *Engine) discardOverlay() {
+	os.Remove(filepath.Join(e.CacheDir, "overlay.json"))
+	os.Remove(e.manifestPath())
+}
+
 func (e *Engine) manifestPath() string {
-	return filepath.Join(e.Root, ".inco_cache", "manifest.json")
+	return filepath.Join(e.CacheDir, "manifest.json")
 }
 
 func (e *Engine) loadManifest() *Manifest {
@@ -566,8 +1642,7 @@ func (e *Engine) loadManifest() *Manifest {
 }
 
 func (e *Engine) writeManifest(m *Manifest) {
-	cacheDir := filepath.Join(e.Root, ".inco_cache")
-	err := os.MkdirAll(cacheDir, 0o755)
+	err := os.MkdirAll(e.CacheDir, 0o755)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
@@ -579,7 +1654,7 @@ func (e *Engine) writeManifest(m *Manifest) {
 		panic(err)
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:482
-	err = os.WriteFile(e.manifestPath(), data, 0o644)
+	err = writeFileAtomic(e.manifestPath(), data, 0o644)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
 		panic(err)
@@ -587,6 +1662,28 @@ func (e *Engine) writeManifest(m *Manifest) {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:484
 }
 
+// manifestEntry returns the manifest record for a shadow generated from
+// path, whose content hash is srcHash, under the engine's current settings.
+func (e *Engine) manifestEntry(path, srcHash, shadowPath string) ManifestEntry {
+	entry := ManifestEntry{
+		SrcHash:       srcHash,
+		ShadowPath:    shadowPath,
+		TrimPath:      e.TrimPath,
+		TagFilter:     e.tagFilter(),
+		LogViolations: e.LogViolations,
+		Must:          e.Must,
+		CountHits:     e.CountHits,
+	}
+	if e.catalog != nil {
+		entry.Messages = e.catalog.hash
+	}
+	entry.Sensitive = strings.Join(e.Sensitive, ",")
+	if m := e.Mutation; m != nil && m.Path == path {
+		entry.Mutation = fmt.Sprintf("%d.%d:%s", m.Line, m.Index, m.Expr)
+	}
+	return entry
+}
+
 // hashFile returns the hex-encoded SHA-256 of a file's contents.
 func hashFile(path string) string {
 	data, err := os.ReadFile(path)
@@ -603,11 +1700,248 @@ func hashFile(path string) string {
 // Utilities
 // ---------------------------------------------------------------------------
 
+// utf8BOM is the byte order mark some editors put at the start of UTF-8
+// files.
+const utf8BOM = "\uFEFF"
+
+// splitLines splits src into lines, without their line endings, "\n" or
+// "\r\n", and without a leading BOM. Code looking at the text of a line (a
+// continuation marker, a trailing comment, the package clause) then sees
+// the same text whatever editor saved the file; joinLines puts both back.
+func splitLines(src []byte) []string {
+	lines := strings.Split(strings.TrimPrefix(string(src), utf8BOM), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// joinLines joins lines, as splitLines returned them or generated ones
+// holding several lines, with the line endings of src, "\r\n" when its
+// first line ends with one, and behind the BOM src starts with, if any.
+func joinLines(lines []string, src []byte) []byte {
+	text := strings.Join(lines, "\n")
+	if i := bytes.IndexByte(src, '\n'); i > 0 && src[i-1] == '\r' {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	if bytes.HasPrefix(src, []byte(utf8BOM)) {
+		text = utf8BOM + text
+	}
+	return []byte(text)
+}
+
 // extractIndent returns the leading whitespace of a line.
 func extractIndent(line string) string {
 	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
 }
 
+// collectDirectives returns the directives of f that can be injected,
+// keyed by 1-based line: standalone directives on comment-only lines
+// inside function bodies, and inline directives trailing a statement.
+// lines are the file's source lines. A line's directives are listed in
+// source order, whether they share a comment or not.
+func collectDirectives(f *ast.File, fset *token.FileSet, lines []string) (standalone, inline map[int][]*Directive) {
+	standalone = make(map[int][]*Directive)
+	inline = make(map[int][]*Directive)
+	stmtLines := collectStmtLines(f, fset)
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			ds := ParseDirectives(c.Text)
+			_ = ds // @inco: len(ds) > 0, -continue
+			if !(len(ds) > 0) {
+				continue
+			}
+			lineNum := srcLine(fset, c.Pos())
+			idx := lineNum - 1
+			_ = idx // @inco: idx >= 0 && idx < len(lines), -continue
+			if !(idx >= 0 && idx < len(lines)) {
+				continue
+			}
+			trimmed := strings.TrimSpace(lines[idx])
+			isCommentLine := strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*")
+			_, inBody := enclosingBodyEnd(f, fset, lineNum)
+			if isCommentLine && inBody {
+				// Outside function bodies (e.g. syntax examples in doc
+				// comments) there is nowhere to inject a statement.
+				standalone[lineNum] = append(standalone[lineNum], ds...)
+			} else if stmtLines[lineNum] {
+				inline[lineNum] = append(inline[lineNum], ds...)
+			}
+		}
+	}
+	return standalone, inline
+}
+
+// srcLine returns the 1-based line of pos in the file as stored on disk.
+// //line directives are ignored: released files already carry them, and
+// the result must index the file's own lines.
+func srcLine(fset *token.FileSet, pos token.Pos) int {
+	return fset.PositionFor(pos, false).Line
+}
+
+// invariantSite is a loop invariant and the line it is written on.
+type invariantSite struct {
+	d    *Directive
+	line int
+}
+
+// enclosingLoopBody returns the body of the innermost for or range loop
+// containing line within the innermost function containing it, or nil.
+func enclosingLoopBody(f *ast.File, fset *token.FileSet, line int) *ast.BlockStmt {
+	var loop *ast.BlockStmt
+	contains := func(b *ast.BlockStmt) bool {
+		return b != nil && srcLine(fset, b.Lbrace) <= line && line <= srcLine(fset, b.Rbrace)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if contains(n.Body) {
+				loop = nil
+			}
+		case *ast.FuncLit:
+			if contains(n.Body) {
+				loop = nil // loops outside a function literal do not enclose its body
+			}
+		case *ast.ForStmt:
+			if contains(n.Body) {
+				loop = n.Body
+			}
+		case *ast.RangeStmt:
+			if contains(n.Body) {
+				loop = n.Body
+			}
+		}
+		return true
+	})
+	return loop
+}
+
+// enclosingBodyEnd returns the last line of the innermost function body
+// containing line and true, or line itself and false when it is not inside
+// a function.
+func enclosingBodyEnd(f *ast.File, fset *token.FileSet, line int) (int, bool) {
+	end := line
+	bestStart := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			body = fn.Body
+		case *ast.FuncLit:
+			body = fn.Body
+		}
+		if body == nil {
+			return true
+		}
+		start := srcLine(fset, body.Lbrace)
+		stop := srcLine(fset, body.Rbrace)
+		if start <= line && line <= stop && start >= bestStart {
+			bestStart, end = start, stop
+		}
+		return true
+	})
+	return end, bestStart > 0
+}
+
+// declaredBefore returns the names visible at line from the functions
+// enclosing it: receivers, type parameters, parameters, named results, and variables
+// declared in their bodies on earlier lines. Scoping inside a body is
+// approximated by position, which is enough to catch names that were
+// renamed or never declared.
+func declaredBefore(f *ast.File, fset *token.FileSet, line int) map[string]bool {
+	names := make(map[string]bool)
+	addFields := func(fl *ast.FieldList) {
+		_ = fl // @inco: fl != nil, -return
+		if !(fl != nil) {
+			return
+		}
+		for _, field := range fl.List {
+			for _, n := range field.Names {
+				names[n.Name] = true
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		var recv *ast.FieldList
+		var ft *ast.FuncType
+		var body *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			recv, ft, body = fn.Recv, fn.Type, fn.Body
+		case *ast.FuncLit:
+			ft, body = fn.Type, fn.Body
+		default:
+			return true
+		}
+		_ = body // @inco: body != nil && srcLine(fset, body.Lbrace) <= line && line <= srcLine(fset, body.Rbrace), -return(false)
+		if !(body != nil && srcLine(fset, body.Lbrace) <= line && line <= srcLine(fset, body.Rbrace)) {
+			return false
+		}
+		addFields(recv)
+		addFields(ft.TypeParams)
+		if recv != nil && len(recv.List) > 0 {
+			for _, id := range recvTypeParams(recv.List[0].Type) {
+				names[id.Name] = true
+			}
+		}
+		addFields(ft.Params)
+		addFields(ft.Results)
+		ast.Inspect(body, func(n ast.Node) bool {
+			_ = n // @inco: n != nil && srcLine(fset, n.Pos()) < line, -return(false)
+			if !(n != nil && srcLine(fset, n.Pos()) < line) {
+				return false
+			}
+			switch s := n.(type) {
+			case *ast.AssignStmt:
+				if s.Tok == token.DEFINE {
+					for _, lhs := range s.Lhs {
+						if id, ok := lhs.(*ast.Ident); ok {
+							names[id.Name] = true
+						}
+					}
+				}
+			case *ast.RangeStmt:
+				if s.Tok == token.DEFINE {
+					for _, x := range []ast.Expr{s.Key, s.Value} {
+						if id, ok := x.(*ast.Ident); ok {
+							names[id.Name] = true
+						}
+					}
+				}
+			case *ast.ValueSpec:
+				for _, id := range s.Names {
+					names[id.Name] = true
+				}
+			}
+			return true
+		})
+		return true
+	})
+	return names
+}
+
+// recvTypeParams returns the type parameters of a generic receiver type:
+// *Stack[T] → [T].
+func recvTypeParams(expr ast.Expr) []*ast.Ident {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	var indices []ast.Expr
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		indices = []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		indices = t.Indices
+	}
+	var ids []*ast.Ident
+	for _, x := range indices {
+		if id, ok := x.(*ast.Ident); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
 // collectStmtLines walks the AST and returns a set of line numbers that
 // contain statements inside function bodies. A directive comment whose
 // line appears in this set is classified as "inline" rather than "standalone".
@@ -619,11 +1953,8 @@ func collectStmtLines(f *ast.File, fset *token.FileSet) map[int]bool {
 			return false
 		}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/engine.inco.go:510
-		switch n.(type) {
-		case *ast.AssignStmt, *ast.ExprStmt, *ast.ReturnStmt,
-			*ast.IncDecStmt, *ast.SendStmt, *ast.GoStmt, *ast.DeferStmt,
-			*ast.BranchStmt:
-			lines[fset.Position(n.Pos()).Line] = true
+		if s, ok := n.(ast.Stmt); ok && isSimpleStmt(s) {
+			lines[srcLine(fset, n.Pos())] = true
 		}
 		return true
 	})
@@ -2,10 +2,21 @@ package inco
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/imnive-design/inco-go/incolog"
 )
 
 // setupDir creates a temp directory with Go source files and returns its path.
@@ -161,6 +172,34 @@ func Process(name string, age int) {
 	}
 }
 
+func TestEngine_MultipleDirectivesPerLine(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"main.go": `package main
+
+import "os"
+
+func Process(name string, age int) error {
+	// @inco: len(name) > 0; age > 0, -return(os.ErrInvalid)
+	f, err := os.Open(name) /* @inco: err == nil, -return(err) */ // @inco: f != nil; @ensure -closed f
+	return f.Close()
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Run()
+	shadow := readShadow(t, e)
+	var last int
+	for _, want := range []string{"!(len(name) > 0)", "!(age > 0)", "!(err == nil)", "!(f != nil)", "_inco_closed_f := false", "_inco_closed_f = true"} {
+		i := strings.Index(shadow, want)
+		if i < last {
+			t.Fatalf("%q missing or out of source order in:\n%s", want, shadow)
+		}
+		last = i
+	}
+}
+
 // ---------------------------------------------------------------------------
 // //line directives
 // ---------------------------------------------------------------------------
@@ -222,6 +261,36 @@ func Do(x int) {
 	}
 }
 
+func TestEngine_ShadowNames(t *testing.T) {
+	src := "package %s\n\nfunc Do(x int) {\n\t// @inco: x > 0\n\t_ = x\n}\n"
+	dir := setupDir(t, map[string]string{
+		"main.go":              fmt.Sprintf(src, "main"),
+		"internal/store/db.go": fmt.Sprintf(src, "store"),
+		"api/db.go":            fmt.Sprintf(src, "api"),
+	})
+	e := NewEngine(dir)
+	e.Run()
+	for path, prefix := range map[string]string{
+		"main.go":              filepath.Base(dir) + "__main__",
+		"internal/store/db.go": "internal_store__db__",
+		"api/db.go":            "api__db__",
+	} {
+		name := filepath.Base(e.Overlay.Replace[filepath.Join(dir, filepath.FromSlash(path))])
+		if !strings.HasPrefix(name, prefix) || !shadowNameRe.MatchString(name) {
+			t.Errorf("%s: shadow %q, want %s<hash>.go", path, name, prefix)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".inco_cache", "overlay.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, main, store := strings.Index(string(data), "api/db.go"), strings.Index(string(data), "/main.go"), strings.Index(string(data), "internal/store/db.go")
+	if !(api < store && store < main) {
+		t.Errorf("overlay.json keys not sorted:\n%s", data)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Skips hidden directories
 // ---------------------------------------------------------------------------
@@ -522,6 +591,77 @@ func Do(s string) (int, error) {
 	}
 }
 
+// importShadowPos parses shadow and returns the //line-adjusted line of
+// the function named fn, failing if the shadow does not parse.
+func importShadowPos(t *testing.T, shadow, fn string) int {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "shadow.go", shadow, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("shadow does not parse: %v\n%s", err, shadow)
+	}
+	for _, decl := range f.Decls {
+		if fd, ok := decl.(*ast.FuncDecl); ok && fd.Name.Name == fn {
+			return fset.Position(fd.Pos()).Line
+		}
+	}
+	t.Fatalf("func %s not found in shadow:\n%s", fn, shadow)
+	return 0
+}
+
+func TestEngine_ImportInjectionPositions(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		doLine int
+	}{
+		{"no imports", `package main
+
+func Do(s string) (int, error) {
+	// @inco: len(s) > 0, -return(0, errors.New("empty"))
+	return len(s), nil
+}
+`, 3},
+		{"single import", `package main
+
+import "fmt"
+
+func Do(s string) (int, error) {
+	// @inco: len(s) > 0, -return(0, errors.New("empty"))
+	fmt.Println(s)
+	return len(s), nil
+}
+`, 5},
+		{"grouped imports", `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func Do(s string) (int, error) {
+	// @inco: len(s) > 0, -return(0, errors.New("empty"))
+	fmt.Fprintln(os.Stderr, s)
+	return len(s), nil
+}
+`, 8},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := setupDir(t, map[string]string{"main.go": c.src})
+			e := NewEngine(dir)
+			e.Run()
+			shadow := readShadow(t, e)
+			if !strings.Contains(shadow, `import "errors"`) {
+				t.Errorf("should inject errors import, got:\n%s", shadow)
+			}
+			if got := importShadowPos(t, shadow, "Do"); got != c.doLine {
+				t.Errorf("Do reported at line %d, want %d; shadow:\n%s", got, c.doLine, shadow)
+			}
+		})
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Deeply nested closure
 // ---------------------------------------------------------------------------
@@ -569,6 +709,32 @@ func TestEngine_SkipsVendor(t *testing.T) {
 	}
 }
 
+func TestEngine_KeepsLeadingComments(t *testing.T) {
+	header := "// Copyright 2024 The Authors.\n// SPDX-License-Identifier: MIT\n\n//go:build linux\n\n"
+	dir := setupDir(t, map[string]string{
+		"main.go": header + "package main\n\nfunc F(x int) {\n\t// @inco: x > 0, -panic(errors.New(\"x\"))\n}\n",
+	})
+	e := NewEngine(dir)
+	e.Run()
+	if shadow := readShadow(t, e); !strings.HasPrefix(shadow, header+"package main\n") {
+		t.Errorf("shadow does not start with the source's header:\n%s", shadow)
+	}
+}
+
+func TestEngine_IncludeVendor(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"vendor/v/v.go":  "package v\n\nfunc V(x int) {\n\t// @inco: x > 0\n}\n",
+		"testdata/td.go": "package td\n\nfunc TD(x int) {\n\t// @inco: x > 0\n}\n",
+	})
+	e := NewEngine(dir)
+	e.IncludeVendor = true
+	e.Run()
+	if _, ok := e.Overlay.Replace[filepath.Join(dir, "vendor", "v", "v.go")]; !ok || len(e.Overlay.Replace) != 2 {
+		t.Errorf("want main.go and vendor/v/v.go in the overlay, got %v", e.Overlay.Replace)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Inline directive
 // ---------------------------------------------------------------------------
@@ -778,15 +944,62 @@ func Do(x int) {
 }
 
 // ---------------------------------------------------------------------------
-// loadOverlayIfExists — no overlay.json
+// Crash-safe cache writes
 // ---------------------------------------------------------------------------
 
-func TestEngine_LoadOverlayIfExists_NoFile(t *testing.T) {
+func TestWriteFileAtomic(t *testing.T) {
 	dir := t.TempDir()
+	path := filepath.Join(dir, "overlay.json")
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("content = %q, want %q", data, content)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+		t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
+func TestCheckOverlay(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": "package main\n\nfunc F(x int) {\n\t// @inco: x > 0\n}\n"})
 	e := NewEngine(dir)
-	ov := e.loadOverlayIfExists()
-	if ov != nil {
-		t.Errorf("should return nil when no overlay.json, got %v", ov)
+	e.Run()
+	if err := CheckOverlay(e.CacheDir); err != nil {
+		t.Errorf("fresh overlay: %v", err)
+	}
+	if err := CheckOverlay(t.TempDir()); err != nil {
+		t.Errorf("no overlay: %v", err)
+	}
+
+	for _, sp := range e.Overlay.Replace {
+		os.Remove(sp)
+	}
+	if err := CheckOverlay(e.CacheDir); err == nil || !strings.Contains(err.Error(), "missing shadow") {
+		t.Errorf("missing shadow: err = %v", err)
+	}
+	e.Run() // regenerates the missing shadow
+	if err := CheckOverlay(e.CacheDir); err != nil {
+		t.Errorf("after regeneration: %v", err)
+	}
+
+	overlay := filepath.Join(e.CacheDir, "overlay.json")
+	os.WriteFile(overlay, []byte(`{"Replace": {"a.go": "/tm`), 0o644)
+	if err := CheckOverlay(e.CacheDir); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("truncated overlay: err = %v", err)
+	}
+
+	// An overlay left over when no source file remains is removed.
+	os.Remove(filepath.Join(dir, "main.go"))
+	e.Run()
+	if _, err := os.Stat(overlay); !os.IsNotExist(err) {
+		t.Errorf("stale overlay.json kept: %v", err)
 	}
 }
 
@@ -828,3 +1041,856 @@ func Do(x int) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// @ensure -closed
+// ---------------------------------------------------------------------------
+
+func TestEngine_EnsureClosed(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "os"
+
+func Read(path string) {
+	f, _ := os.Open(path)
+	// @ensure -closed f
+	defer f.Close()
+	_ = f.Name()
+}
+
+func after(f *os.File) {
+	f.Close()
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	if !strings.Contains(shadow, "_inco_closed_f := false") {
+		t.Errorf("shadow should declare tracking flag, got:\n%s", shadow)
+	}
+	if !strings.Contains(shadow, "f not closed before return") {
+		t.Error("shadow should contain deferred close check")
+	}
+	if !strings.Contains(shadow, "defer func() error { _inco_closed_f = true; return f.Close() }()") {
+		t.Errorf("Close call should be rewritten, got:\n%s", shadow)
+	}
+	// Close calls outside the enclosing function must not be rewritten.
+	if !strings.Contains(shadow, "\tf.Close()\n") {
+		t.Errorf("Close call outside the function should be untouched, got:\n%s", shadow)
+	}
+}
+
+func TestEngine_EnsureClosedPanicking(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module closed\n\ngo 1.21\n",
+		"main.go": `package main
+
+import "os"
+
+func read(path string) {
+	f, _ := os.Open(path)
+	// @ensure -closed f
+	// @ensure -closed f
+	panic("read failed")
+	f.Close()
+}
+
+func main() { read("go.mod") }
+`,
+	})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	if shadow := readShadow(t, e); strings.Count(shadow, "_inco_closed_f := false") != 1 {
+		t.Errorf("a name tracked twice in a function should get one flag:\n%s", shadow)
+	}
+
+	// The file is left open by a panic: that panic is the one reported.
+	cmd := exec.Command("go", "run", "-overlay="+filepath.Join(e.CacheDir, "overlay.json"), ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "panic: read failed") || strings.Contains(string(out), "not closed before return") {
+		t.Errorf("go run = %v, want the panic of read only:\n%s", err, out)
+	}
+}
+
+func TestEngine_EnsureClosedUndeclared(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "os"
+
+func Read(path string) {
+	file, _ := os.Open(path)
+	// @ensure -closed f
+	defer file.Close()
+}
+`,
+	})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, "main.go:7: @ensure -closed f: f is not declared") {
+		t.Errorf("message should point at the directive, got: %s", msg)
+	}
+}
+
+func TestDeclaredBefore(t *testing.T) {
+	src := `package p
+
+func (s *S) M(a int, b string) (n int, err error) {
+	c := 1
+	var d int
+	for i, v := range []int{} {
+		_ = func(x int) {
+			e := 2
+			// line 10
+			_ = e
+		}
+	}
+	late := 3
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := declaredBefore(f, fset, 10)
+	for _, name := range []string{"s", "a", "b", "n", "err", "c", "d", "i", "v", "x", "e"} {
+		if !got[name] {
+			t.Errorf("%s should be declared at line 10", name)
+		}
+	}
+	if got["late"] {
+		t.Error("late is declared after line 10")
+	}
+}
+
+func TestRewriteCloseCalls(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"\tf.Close()", "\tfunc() error { _inco_closed_f = true; return f.Close() }()"},
+		{"\terr = f.Close()", "\terr = func() error { _inco_closed_f = true; return f.Close() }()"},
+		{"\t_, _ = f.Close(), f.Close()", "\t_, _ = func() error { _inco_closed_f = true; return f.Close() }(), func() error { _inco_closed_f = true; return f.Close() }()"},
+		{"\tx.f.Close()", "\tx.f.Close()"},
+		{"\tff.Close()", "\tff.Close()"},
+		{"\ts := \"f.Close()\"", "\ts := \"f.Close()\""},
+		{"\ts := `f.Close()` // f.Close()", "\ts := `f.Close()` // f.Close()"},
+	}
+	for _, c := range cases {
+		src := "package p\n\nfunc _() {\n" + c.input + "\n}\n"
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var offsets []int
+		for _, site := range closeCallSites(f, fset, splitLines([]byte(src)))["f"] {
+			offsets = append(offsets, site.offset)
+		}
+		if got := rewriteCloseCalls(c.input, offsets); got != c.want {
+			t.Errorf("rewriteCloseCalls(%q) = %q, want %q", c.input, got, c.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Import injection — qualifiers shadowed at the directive site
+// ---------------------------------------------------------------------------
+
+func TestEngine_ImportShadowedQualifiers(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"main.go": `package main
+
+type User struct{ Name string }
+
+func (u *User) Valid() bool { return u.Name != "" }
+
+type Stack[T any] struct{ items []T }
+
+func (s *Stack[T]) Push(list T) {
+	// @inco: any(list) != nil, -panic("see strings.Cut")
+	s.items = append(s.items, list)
+}
+
+func Greet[T comparable](user *User, xs []int, zero T) {
+	// @inco: user.Name != ""
+	// @inco: (*User).Valid(user)
+	// @inco: slices.Max[[]int](xs) > 0
+	sort := &Stack[int]{}
+	_ = sort // @inco: sort.items == nil
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Run()
+	shadow := readShadow(t, e)
+	if !strings.Contains(shadow, `import "slices"`) {
+		t.Errorf("should inject slices import, got:\n%s", shadow)
+	}
+	for _, unwanted := range []string{`"os/user"`, `"strings"`, `"sort"`, `"container/list"`} {
+		if strings.Contains(shadow, unwanted) {
+			t.Errorf("should not import %s, got:\n%s", unwanted, shadow)
+		}
+	}
+}
+
+func TestSelectorQualifiers(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`fmt.Sprint(x.y) != ""`, []string{"fmt", "x"}},
+		{`slices.Max[[]int](xs) > 0`, []string{"slices"}},
+		{`(*T).Valid(t)`, nil},
+		{`s == "strings.Cut"`, nil},
+		{`x >`, nil},
+	}
+	for _, tt := range tests {
+		if got := selectorQualifiers(tt.expr); !slices.Equal(got, tt.want) {
+			t.Errorf("selectorQualifiers(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Import injection — resolution against the module's package graph
+// ---------------------------------------------------------------------------
+
+func TestEngine_ImportModulePackages(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"internal/check/check.go": `package check
+
+func Positive(n int) bool { return n > 0 }
+`,
+		"app/page.go": `package app
+
+import "html/template"
+
+var page = template.HTML("<p>")
+`,
+		"app/app.go": `package app
+
+func Render(n int, s string) {
+	// @inco: check.Positive(n)
+	// @inco: template.HTMLEscapeString(s) == s
+	_ = s
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Run()
+	data, err := os.ReadFile(e.Overlay.Replace[filepath.Join(dir, "app", "app.go")])
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow := string(data)
+	for _, want := range []string{`import "example.com/m/internal/check"`, `import "html/template"`} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow should contain %s, got:\n%s", want, shadow)
+		}
+	}
+}
+
+func TestEngine_ImportIncoexpr(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Admit(age int, mode string) {
+	// @inco: incoexpr.Between(age, 0, 150)
+	_ = mode // @inco: incoexpr.OneOf(mode, "r", "w")
+}
+`})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	if strings.Count(shadow, `"github.com/imnive-design/inco-go/incoexpr"`) != 1 {
+		t.Errorf("incoexpr import not added once:\n%s", shadow)
+	}
+}
+
+func TestImportable(t *testing.T) {
+	tests := []struct {
+		from, path string
+		want       bool
+	}{
+		{"example.com/m/app", "fmt", true},
+		{"example.com/m/app", "example.com/m/internal/check", true},
+		{"example.com/m", "example.com/m/internal/check", true},
+		{"example.com/m/app", "example.com/m/app/internal/x", true},
+		{"example.com/m/cmd", "example.com/m/app/internal/x", false},
+		{"example.com/other", "example.com/m/internal/check", false},
+		{"", "example.com/m/internal/check", false},
+		{"example.com/m/app", "internal/poll", false},
+		{"example.com/m/app", "example.com/m/vendor/x", false},
+	}
+	for _, tt := range tests {
+		if got := importable(tt.from, tt.path); got != tt.want {
+			t.Errorf("importable(%q, %q) = %v, want %v", tt.from, tt.path, got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Import name collisions
+// ---------------------------------------------------------------------------
+
+func TestResolveImport(t *testing.T) {
+	bound := map[string]string{
+		"other": "example.com/a/other",
+		"cfg":   "example.com/b/other",
+		"_":     "example.com/c/side",
+	}
+	cases := []struct {
+		name, path string
+		wantQual   string
+		wantSpec   *importSpec
+	}{
+		// Already imported under an alias — reuse it.
+		{"other", "example.com/b/other", "cfg", nil},
+		// Free name — plain import.
+		{"fmt", "fmt", "fmt", &importSpec{Path: "fmt"}},
+		// Name bound to a different package — alias the new import.
+		{"other", "example.com/d/other", "inco_other", &importSpec{Name: "inco_other", Path: "example.com/d/other"}},
+		// Blank imports do not make a package referable.
+		{"side", "example.com/c/side", "side", &importSpec{Path: "example.com/c/side"}},
+	}
+	for _, c := range cases {
+		qual, spec := resolveImport(bound, c.name, c.path)
+		if qual != c.wantQual || !reflect.DeepEqual(spec, c.wantSpec) {
+			t.Errorf("resolveImport(%q, %q) = %q, %+v; want %q, %+v",
+				c.name, c.path, qual, spec, c.wantQual, c.wantSpec)
+		}
+	}
+}
+
+func TestGenerateImports_Aliased(t *testing.T) {
+	got := generateImports([]importSpec{
+		{Path: "fmt"},
+		{Name: "inco_other", Path: "example.com/d/other"},
+	}, "/src/main.go", 4)
+	want := []string{
+		`import "fmt"`,
+		`import inco_other "example.com/d/other"`,
+		"//line /src/main.go:4",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generateImports = %q, want %q", got, want)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TrimPath — relative //line paths
+// ---------------------------------------------------------------------------
+
+func TestEngine_TrimPathLineDirectives(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"pkg/main.go": `package pkg
+
+func Do(x int) {
+	// @inco: x > 0
+	_ = x
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.TrimPath = true
+	e.Run()
+	shadow := readShadow(t, e)
+	want := "//line " + filepath.Join("..", "pkg", "main.go") + ":4"
+	if !strings.Contains(shadow, want) {
+		t.Errorf("shadow should contain %q, got:\n%s", want, shadow)
+	}
+	if strings.Contains(shadow, dir) {
+		t.Errorf("shadow should not embed the host path %s, got:\n%s", dir, shadow)
+	}
+}
+
+func TestEngine_TrimPathInvalidatesCache(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+func Do(x int) {
+	// @inco: x > 0
+	_ = x
+}
+`,
+	})
+	NewEngine(dir).Run()
+
+	e := NewEngine(dir)
+	e.TrimPath = true
+	e.Run()
+	if shadow := readShadow(t, e); strings.Contains(shadow, dir) {
+		t.Errorf("cached absolute shadow reused in TrimPath mode:\n%s", shadow)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// CacheDir — configurable cache location
+// ---------------------------------------------------------------------------
+
+func TestModuleRoot(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod":             "module example.com/m\n",
+		"cmd/app/main.go":    "package main\n",
+		"tools/go.mod":       "module example.com/m/tools\n",
+		"tools/lint/lint.go": "package lint\n",
+	})
+	for sub, want := range map[string]string{
+		"":           dir,
+		"cmd/app":    dir,
+		"tools":      filepath.Join(dir, "tools"),
+		"tools/lint": filepath.Join(dir, "tools"),
+	} {
+		if got := ModuleRoot(filepath.Join(dir, sub)); got != want {
+			t.Errorf("ModuleRoot(%s) = %s, want %s", sub, got, want)
+		}
+	}
+	// Outside any module: the directory itself.
+	if outside := t.TempDir(); ModuleRoot(outside) != outside {
+		t.Errorf("ModuleRoot outside a module = %s, want %s", ModuleRoot(outside), outside)
+	}
+}
+
+func TestEngine_CustomCacheDir(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+func Do(x int) {
+	// @inco: x > 0
+	_ = x
+}
+`,
+	})
+	cache := filepath.Join(dir, "build", "inco")
+	for i := 0; i < 2; i++ { // second run must not scan the shadows as sources
+		e := NewEngine(dir)
+		e.CacheDir = cache
+		e.Run()
+		if len(e.Overlay.Replace) != 1 {
+			t.Fatalf("run %d: overlay has %d entries, want 1", i+1, len(e.Overlay.Replace))
+		}
+		for _, sp := range e.Overlay.Replace {
+			if filepath.Dir(sp) != cache {
+				t.Errorf("shadow written to %s, want under %s", sp, cache)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cache, "overlay.json")); err != nil {
+		t.Errorf("overlay.json not in custom cache dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".inco_cache")); !os.IsNotExist(err) {
+		t.Errorf("default cache dir should not be created, stat err = %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Self-hosting — released files and doc-comment examples
+// ---------------------------------------------------------------------------
+
+func TestEngine_DocCommentDirectiveIgnored(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+// Check documents the syntax:
+//
+//	@inco: <expr>
+func Check(x int) {
+	// @inco: x > 0
+	_ = x
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	if strings.Contains(shadow, "!(<expr>)") {
+		t.Errorf("doc comment example should not be injected, got:\n%s", shadow)
+	}
+	if !strings.Contains(shadow, "!(x > 0)") {
+		t.Errorf("shadow should contain the function's guard, got:\n%s", shadow)
+	}
+}
+
+func TestEngine_ExistingLineDirectivesIgnored(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+func Do(x int) {
+//line /elsewhere/main.inco.go:40
+	_ = x // @inco: x > 0
+	_ = x
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Run()
+	lines := strings.Split(readShadow(t, e), "\n")
+	if len(lines) < 7 || lines[5] != "//line "+filepath.Join(dir, "main.go")+":5" || strings.TrimSpace(lines[6]) != "if !(x > 0) {" {
+		t.Errorf("guard should follow its own source line, got:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Conditional contracts
+// ---------------------------------------------------------------------------
+
+func TestEngine_ConditionalContract(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+var debugBuild = false
+
+func Do(x int) {
+	// @inco: if(debugBuild) x > 0
+	_ = x
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	at := "//line " + filepath.Join(dir, "main.go") + ":6\n"
+	want := at + "\tif debugBuild {\n" + at + "\t\tif !(x > 0) {\n" + at + "\t\t\tpanic("
+	if !strings.Contains(shadow, want) {
+		t.Errorf("shadow should guard the check with debugBuild, got:\n%s", shadow)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Tag filters
+// ---------------------------------------------------------------------------
+
+func TestEngine_TagFilters(t *testing.T) {
+	src := `package main
+
+func Do(x, y, z int) {
+	// @inco: #io x > 0
+	// @inco: #security #io y > 0
+	// @inco: z > 0
+	_ = x + y + z
+}
+`
+	tests := []struct {
+		name            string
+		enable, disable []string
+		want            []string
+	}{
+		{"all", nil, nil, []string{"x > 0", "y > 0", "z > 0"}},
+		{"enable", []string{"security"}, nil, []string{"y > 0", "z > 0"}},
+		{"disable", nil, []string{"security"}, []string{"x > 0", "z > 0"}},
+		{"both", []string{"io"}, []string{"security"}, []string{"x > 0", "z > 0"}},
+	}
+	dir := setupDir(t, map[string]string{"main.go": src})
+	for _, tt := range tests { // same dir: changing filters must invalidate the cache
+		e := NewEngine(dir)
+		e.EnableTags, e.DisableTags = tt.enable, tt.disable
+		e.Run()
+		shadow := readShadow(t, e)
+		var got []string
+		for _, expr := range []string{"x > 0", "y > 0", "z > 0"} {
+			if strings.Contains(shadow, "!("+expr+")") {
+				got = append(got, expr)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: guarded %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_PackagePatterns(t *testing.T) {
+	src := func(pkg string) string {
+		return "package " + pkg + "\n\nfunc F(x int) {\n\t// @inco: x > 0\n\t_ = x\n}\n"
+	}
+	dir := setupDir(t, map[string]string{
+		"main.go":               src("main"),
+		"internal/api/api.go":   src("api"),
+		"internal/api/v2/v2.go": src("v2"),
+		"internal/apix/x.go":    src("apix"),
+		"pkg/db/db.go":          src("db"),
+		"pkg/db/sub/sub.go":     src("sub"),
+	})
+	e := NewEngine(dir)
+	e.Packages = []string{"./internal/api/...", "./pkg/db"}
+	e.Run()
+
+	var got []string
+	for p := range e.Overlay.Replace {
+		rel, _ := filepath.Rel(dir, p)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	slices.Sort(got)
+	want := []string{"internal/api/api.go", "internal/api/v2/v2.go", "pkg/db/db.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("instrumented %v, want %v", got, want)
+	}
+
+	// Narrowing the selection drops the shadows of deselected files.
+	e.Packages = []string{"./pkg/db"}
+	e.Run()
+	if len(e.Overlay.Replace) != 1 {
+		t.Errorf("instrumented %d files, want 1", len(e.Overlay.Replace))
+	}
+}
+
+func TestMatchPackage(t *testing.T) {
+	tests := []struct {
+		pattern, dir string
+		want         bool
+	}{
+		{"./...", ".", true},
+		{"./...", "a/b", true},
+		{".", ".", true},
+		{".", "a", false},
+		{"./a", "a", true},
+		{"./a", "a/b", false},
+		{"a/...", "a", true},
+		{"./a/...", "a/b", true},
+		{"./a/...", "ab", false},
+	}
+	for _, tt := range tests {
+		if got := matchPackage(tt.pattern, tt.dir); got != tt.want {
+			t.Errorf("matchPackage(%q, %q) = %v, want %v", tt.pattern, tt.dir, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"../other/...", "/abs/..."} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("matchPackage(%q) should panic", pattern)
+				}
+			}()
+			matchPackage(pattern, ".")
+		}()
+	}
+}
+
+func TestEngine_LogViolations(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+import "os"
+
+func Open(name string, n int) error {
+	// @inco: len(name) > 0, -panic("name required")
+	// @inco: n >= 0, -return(nil)
+	f, _ := os.Open(name)
+	// @ensure -closed f
+	defer f.Close()
+	return nil
+}
+`})
+	e := NewEngine(dir)
+	e.LogViolations = true
+	e.Quiet = true
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{
+		`inco_log "github.com/imnive-design/inco-go/incolog"`,
+		`inco_log.Violation("require", "main.go", 6, "len(name) > 0", "name required")`,
+		`inco_log.Violation("ensure-closed", "main.go", 9, "f", nil)`,
+		"return nil",
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow lacks %s:\n%s", want, shadow)
+		}
+	}
+	if strings.Contains(shadow, "panic(") {
+		t.Errorf("shadow still panics:\n%s", shadow)
+	}
+
+	e.LogViolations = false
+	e.Run()
+	if shadow := readShadow(t, e); strings.Contains(shadow, "inco_log") {
+		t.Errorf("log mode kept after it was turned off:\n%s", shadow)
+	}
+}
+
+func TestTallyViolations(t *testing.T) {
+	records := []incolog.Record{
+		{Kind: "require", File: "b.go", Line: 3, Expr: "x > 0"},
+		{Kind: "require", File: "a.go", Line: 9, Expr: "y != nil", Message: "y required"},
+		{Kind: "require", File: "b.go", Line: 3, Expr: "x > 0", Message: "x = -1"},
+		{Kind: "require", File: "b.go", Line: 3, Expr: "x > 0", Message: "x = -1"},
+	}
+	got := TallyViolations(records)
+	if len(got) != 2 {
+		t.Fatalf("got %d tallies, want 2: %+v", len(got), got)
+	}
+	if got[0].Path != "b.go" || got[0].Count != 3 || len(got[0].Messages) != 1 || got[0].Messages[0] != "x = -1" {
+		t.Errorf("first tally = %+v", got[0])
+	}
+	if got[1].Path != "a.go" || got[1].Count != 1 {
+		t.Errorf("second tally = %+v", got[1])
+	}
+}
+
+func TestEngine_DefaultAction(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+import "time"
+
+type Config struct{ Port int }
+
+func Load(timeout time.Duration, cfg *Config) {
+	// @inco: timeout > 0, -default(30*time.Second)
+	_ = cfg // @inco: if(cfg != nil) cfg.Port > 0, -default(8080)
+}
+`})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	at := "//line " + filepath.Join(dir, "main.go")
+	for _, want := range []string{
+		"if !(timeout > 0) {\n" + at + ":8\n\t\ttimeout = 30*time.Second\n\t}",
+		at + ":9\n\tif cfg != nil {\n" + at + ":9\n\t\tif !(cfg.Port > 0) {\n" + at + ":9\n\t\t\tcfg.Port = 8080\n\t\t}\n\t}",
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow lacks %q:\n%s", want, shadow)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc F(s string) {\n\t// @inco: len(s) > 0, -default(\"x\")\n}\n"), 0o644)
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, `main.go:4: -default("x"): len(s) > 0 compares no variable to assign`) {
+		t.Errorf("contract without a variable: %s", msg)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Line endings and BOM
+// ---------------------------------------------------------------------------
+
+func TestEngine_CRLFAndBOM(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	src := utf8BOM + strings.ReplaceAll(`// Package main checks its argument.
+package main
+
+func check(n int) {
+	// @inco: n > 0 && \
+	// ... n < 10
+	_ = n
+}
+
+func main() { check(42) }
+`, "\n", "\r\n")
+	dir := setupDir(t, map[string]string{"go.mod": "module bom\n\ngo 1.21\n", "main.go": src})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+
+	shadow := readShadow(t, e)
+	if !strings.HasPrefix(shadow, utf8BOM+"// Package main") || strings.Contains(strings.ReplaceAll(shadow, "\r\n", ""), "\n") {
+		t.Errorf("shadow lost the BOM or \\r\\n line endings:\n%q", shadow)
+	}
+
+	cmd := exec.Command("go", "run", "-overlay="+filepath.Join(e.CacheDir, "overlay.json"), ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), filepath.Join(dir, "main.go")+":5") {
+		t.Errorf("go run = %v, want a panic at main.go:5:\n%s", err, out)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Fuzzing
+// ---------------------------------------------------------------------------
+
+// FuzzGenerateShadow injects the directives of an arbitrary function body
+// and checks the //line bookkeeping: the code lines survive in order,
+// every //line directive names a line of the source, and a file with
+// "\r\n" line endings or a BOM gets the same shadow as without, with its
+// line endings and BOM.
+func FuzzGenerateShadow(f *testing.F) {
+	for _, seed := range []string{
+		"\t// @inco: x > 0",
+		"\t// @inco: s != \"\", -return(0, errors.New(\"empty\"))",
+		"\ty := x * 2 // @inco: y > x, -panic(\"overflow\")\n\t_ = y",
+		"\tfor _, v := range xs {\n\t\t// @inco: v >= 0, -continue\n\t\t// @invariant x >= 0\n\t\tx += v\n\t}",
+		"\t// @inco: len(xs) > 0 && \\\n\t// ... xs[0] > 0, -return(0, nil)",
+		"\t// @inco: s != \"a // b\"; x != 1\n\t/* @inco: x != 2 */",
+		"\tif x > 0 { // @inco: s != \"\"\n\t}",
+		"\t// @inco: #io if(x > 0) len(s) > 0, -panic(\"say \\\"hi\\\"\")",
+		"    // @inco: x > 0\n    x++",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body string) {
+		src := "package p\n\nimport \"errors\"\n\nvar _ = errors.New\n\nfunc F(x int, s string, xs []int) (int, error) {\n" + body + "\n\treturn x, nil\n}\n"
+		if _, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments); err != nil || strings.Contains(src, "\r") {
+			t.Skip()
+		}
+		shadow, err := fuzzShadow(t, src)
+		crlfShadow, crlfErr := fuzzShadow(t, strings.ReplaceAll(src, "\n", "\r\n"))
+		if fmt.Sprint(err) != fmt.Sprint(crlfErr) {
+			t.Fatalf("with \\r\\n line endings: error %v, want %v", crlfErr, err)
+		}
+		if err != nil {
+			return
+		}
+		if want := strings.ReplaceAll(shadow, "\n", "\r\n"); crlfShadow != want {
+			t.Errorf("with \\r\\n line endings the shadow differs:\n%s", lineDiff(want, crlfShadow))
+		}
+		if bomShadow, _ := fuzzShadow(t, utf8BOM+src); bomShadow != utf8BOM+shadow {
+			t.Errorf("with a BOM the shadow differs:\n%s", lineDiff(utf8BOM+shadow, bomShadow))
+		}
+
+		// Standalone directives replace their comment line: check the
+		// other lines.
+		lines := strings.Split(src, "\n")
+		var rest []string
+		for _, line := range lines {
+			if trimmed := strings.TrimSpace(line); !strings.HasPrefix(trimmed, "//") && !strings.HasPrefix(trimmed, "/*") {
+				rest = append(rest, line)
+			}
+		}
+		for _, line := range strings.Split(shadow, "\n") {
+			if n, ok := strings.CutPrefix(line, "//line $ROOT/p.go:"); ok {
+				if l, err := strconv.Atoi(n); err != nil || l < 1 || l > len(lines) {
+					t.Errorf("//line directive %q outside the %d source lines", line, len(lines))
+				}
+			}
+			if len(rest) > 0 && line == rest[0] {
+				rest = rest[1:]
+			}
+		}
+		if len(rest) > 0 && !strings.Contains(body, "@ensure") && !strings.Contains(body, "@must") {
+			t.Errorf("source line %q missing from the shadow:\n%s", rest[0], shadow)
+		}
+	})
+}
+
+// fuzzShadow returns the shadow of src, with its directory as $ROOT, or
+// the error generating it panics with.
+func fuzzShadow(t *testing.T, src string) (shadow string, err error) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "p.go")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, perr := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(strings.ReplaceAll(fmt.Sprint(r), dir, "$ROOT"))
+		}
+	}()
+	e := NewEngine(dir)
+	e.Quiet = true
+	r := e.generateShadow(path, f, fset)
+	return strings.ReplaceAll(string(r.ShadowData), dir, "$ROOT"), nil
+}
@@ -0,0 +1,415 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Postconditions: @ensure expr and old(x)
+// ---------------------------------------------------------------------------
+
+// oldName is the pseudo-function of postconditions reading a value as it
+// was at the directive: in "@ensure s.count == old(s.count) + 1", old
+// copies s.count where the directive is written, and the check compares
+// it with s.count when the function returns. It is reserved in @ensure
+// only; in @inco: contracts old is an ordinary call.
+const oldName = "old"
+
+// oldCall reports whether x is a call of old.
+func oldCall(x ast.Node) (*ast.CallExpr, bool) {
+	call, ok := x.(*ast.CallExpr)
+	return call, ok && isIdentNamed(call.Fun, oldName)
+}
+
+// snapshotName is the name of the i-th value the old calls of a
+// postcondition copy.
+func snapshotName(i int) string {
+	return fmt.Sprintf("_inco_old%d", i)
+}
+
+// snapshotOld returns s with each old(x) replaced by the snapshotName of
+// x's index in olds, and olds with the operands not in it yet appended.
+// Calls with the same operand share a snapshot. s is returned unchanged
+// when it does not parse.
+func snapshotOld(s string, olds []string) (string, []string, error) {
+	_ = s // @inco: strings.Contains(s, oldName+"("), -return(s, olds, nil)
+	if !(strings.Contains(s, oldName+"(")) {
+		return s, olds, nil
+	}
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return(s, olds, nil)
+	if !(err == nil) {
+		return s, olds, nil
+	}
+	// The parsed expression starts at offset 1 (token.Pos base).
+	text := func(n ast.Node) string { return s[n.Pos()-1 : n.End()-1] }
+	var calls []*ast.CallExpr
+	var problem error
+	ast.Inspect(x, func(n ast.Node) bool {
+		call, ok := oldCall(n)
+		_ = ok // @inco: ok, -return(problem == nil)
+		if !(ok) {
+			return problem == nil
+		}
+		switch {
+		case len(call.Args) != 1 || call.Ellipsis.IsValid():
+			problem = fmt.Errorf("%s: old takes one value", text(call))
+		case strings.Contains(text(call.Args[0]), oldName+"("):
+			if _, nested := oldCalls(call.Args[0]); nested {
+				problem = fmt.Errorf("%s: old inside old; the outer call already copies the value at the directive", text(call))
+			}
+		}
+		calls = append(calls, call)
+		return false
+	})
+	_ = problem // @inco: problem == nil, -return(s, olds, problem)
+	if !(problem == nil) {
+		return s, olds, problem
+	}
+	names := make([]string, len(calls))
+	for i, call := range calls {
+		operand := text(call.Args[0])
+		j := slices.Index(olds, operand)
+		if j < 0 {
+			j = len(olds)
+			olds = append(olds, operand)
+		}
+		names[i] = snapshotName(j)
+	}
+	out := s
+	for i, call := range slices.Backward(calls) {
+		out = out[:call.Pos()-1] + names[i] + out[call.End()-1:]
+	}
+	return out, olds, nil
+}
+
+// oldCalls returns the old calls of x, and whether there is any.
+func oldCalls(x ast.Node) ([]*ast.CallExpr, bool) {
+	var calls []*ast.CallExpr
+	ast.Inspect(x, func(n ast.Node) bool {
+		if call, ok := oldCall(n); ok {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	return calls, len(calls) > 0
+}
+
+// ensureSnapshots returns the check of the postcondition d, with its old
+// calls replaced by snapshots (see snapshotOld), and the values to copy at
+// the directive, in snapshot order.
+func ensureSnapshots(d *Directive) (check *Directive, olds []string, err error) {
+	resolved := *d
+	resolved.ActionArgs = slices.Clone(d.ActionArgs)
+	for _, s := range []*string{&resolved.Cond, &resolved.Expr} {
+		*s, olds, err = snapshotOld(*s, olds)
+		_ = err // @inco: err == nil, -return(nil, nil, err)
+		if !(err == nil) {
+			return nil, nil, err
+		}
+	}
+	for i := range resolved.ActionArgs {
+		resolved.ActionArgs[i], olds, err = snapshotOld(resolved.ActionArgs[i], olds)
+		_ = err // @inco: err == nil, -return(nil, nil, err)
+		if !(err == nil) {
+			return nil, nil, err
+		}
+	}
+	return &resolved, olds, nil
+}
+
+// resolveEnsure returns d, and panics when d is a postcondition with an
+// old call that cannot be copied (see snapshotOld).
+func (e *Engine) resolveEnsure(d *Directive, path string, line int) *Directive {
+	_ = d // @inco: d.Kind == KindEnsure, -return(d)
+	if !(d.Kind == KindEnsure) {
+		return d
+	}
+	_, _, err := ensureSnapshots(d)
+	_ = err // @inco: err == nil, -panic(e.directiveError(path, line, err))
+	if !(err == nil) {
+		panic(e.directiveError(path, line, err))
+	}
+	return d
+}
+
+// oldOperands returns the operands of the old calls of d's check.
+func oldOperands(d *Directive) []string {
+	var out []string
+	for _, s := range append([]string{d.Cond, d.Expr}, d.ActionArgs...) {
+		x, err := parser.ParseExpr(s)
+		if err != nil {
+			continue
+		}
+		calls, _ := oldCalls(x)
+		for _, call := range calls {
+			if len(call.Args) == 1 {
+				out = append(out, s[call.Args[0].Pos()-1:call.Args[0].End()-1])
+			}
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Vet: what old copies
+// ---------------------------------------------------------------------------
+
+// ensureProblem is a problem vetEnsure finds, with the code it reports.
+type ensureProblem struct {
+	code Code
+	msg  string
+}
+
+// syncNoCopy holds the types of package sync that must not be copied
+// after first use.
+var syncNoCopy = map[string]bool{
+	"Mutex": true, "RWMutex": true, "WaitGroup": true, "Once": true, "Cond": true, "Map": true, "Pool": true,
+}
+
+// vetEnsure returns the problems of the postcondition d at line of the
+// file at path:
+//
+//   - an old call that cannot be copied (see snapshotOld)
+//   - old(p) of a pointer p, read through as in old(p).n or *old(p): the
+//     snapshot copies the pointer, so it reads the value at return
+//   - old of a value receiver's state: the method changes its own copy of
+//     the receiver, so the postcondition says nothing about the caller's
+//   - old of a value holding a sync lock or a sync/atomic value, which
+//     must not be copied
+//
+// Types are those the source declares, as for -idx: variables of the
+// enclosing functions and fields of the package's struct types. Package
+// types are matched by the names sync and atomic.
+func vetEnsure(f *ast.File, fset *token.FileSet, path string, line int, d *Directive) []ensureProblem {
+	_ = d // @inco: d.Kind == KindEnsure, -return(nil)
+	if !(d.Kind == KindEnsure) {
+		return nil
+	}
+	if _, _, err := ensureSnapshots(d); err != nil {
+		return []ensureProblem{{CodeOld, err.Error()}}
+	}
+	var problems []ensureProblem
+	var structs map[string]*ast.StructType // read on first use
+	typeOf := func(x ast.Expr) ast.Expr {
+		if structs == nil {
+			structs = packageStructs(f, filepath.Dir(path))
+		}
+		return snapshotType(f, fset, line, x, structs)
+	}
+	recv, recvType, method := methodReceiver(f, fset, line)
+	for _, s := range append([]string{d.Cond, d.Expr}, d.ActionArgs...) {
+		x, err := parser.ParseExpr(s)
+		if err != nil {
+			continue
+		}
+		// Read-through uses of old(p).
+		ast.Inspect(x, func(n ast.Node) bool {
+			var call *ast.CallExpr
+			via := ""
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				if c, ok := oldCall(ast.Unparen(n.X)); ok {
+					call, via = c, "."+n.Sel.Name
+				}
+			case *ast.StarExpr:
+				if c, ok := oldCall(ast.Unparen(n.X)); ok {
+					call, via = c, "*"
+				}
+			}
+			_ = call // @inco: call != nil && len(call.Args) == 1, -return(true)
+			if !(call != nil && len(call.Args) == 1) {
+				return true
+			}
+			p := types.ExprString(call.Args[0])
+			_, ptr := typeOf(call.Args[0]).(*ast.StarExpr)
+			_ = ptr // @inco: ptr, -return(true)
+			if !(ptr) {
+				return true
+			}
+			read, fix := "old("+p+")"+via, "old("+p+via+")"
+			if via == "*" {
+				read, fix = "*old("+p+")", "old(*"+p+")"
+			}
+			problems = append(problems, ensureProblem{CodeOldPointer, fmt.Sprintf("old(%s) copies the pointer %s, not what it points to, so %s reads the value at return; write %s", p, p, read, fix)})
+			return true
+		})
+		calls, _ := oldCalls(x)
+		for _, call := range calls {
+			_ = call // @inco: len(call.Args) == 1, -continue
+			if !(len(call.Args) == 1) {
+				continue
+			}
+			operand := call.Args[0]
+			text := types.ExprString(operand)
+			if root := rootIdent(operand); root != nil && recv != "" && root.Name == recv {
+				if _, ptr := recvType.(*ast.StarExpr); !ptr {
+					problems = append(problems, ensureProblem{CodeValueReceiver, fmt.Sprintf("old(%s): %s is a value receiver, so %s changes its own copy of %s, which the caller never sees; the postcondition checks only that copy; use a pointer receiver", text, recv, method, recv)})
+				}
+			}
+			t := typeOf(operand)
+			if lock := lockIn(t, structs, nil); lock != "" {
+				problems = append(problems, ensureProblem{CodeCopyLock, fmt.Sprintf("old(%s) copies a %s, which must not be copied; snapshot the fields the postcondition compares instead", text, lock)})
+			}
+		}
+	}
+	return slices.CompactFunc(problems, func(a, b ensureProblem) bool { return a == b })
+}
+
+// methodReceiver returns the receiver name and type, and the name, of the
+// method enclosing line; "" and nil outside methods.
+func methodReceiver(f *ast.File, fset *token.FileSet, line int) (name string, typ ast.Expr, method string) {
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Body != nil && srcLine(fset, fn.Body.Lbrace) <= line && line <= srcLine(fset, fn.Body.Rbrace), -continue
+		if !(ok && fn.Body != nil && srcLine(fset, fn.Body.Lbrace) <= line && line <= srcLine(fset, fn.Body.Rbrace)) {
+			continue
+		}
+		if fn.Recv != nil && len(fn.Recv.List) == 1 && len(fn.Recv.List[0].Names) == 1 {
+			return fn.Recv.List[0].Names[0].Name, fn.Recv.List[0].Type, fn.Name.Name
+		}
+	}
+	return "", nil, ""
+}
+
+// snapshotType returns the declared type of x, an operand of old at line:
+// a variable the enclosing functions declare (see declaredType), a field
+// of one of structs, or the target of a pointer. nil when the source does
+// not spell it out.
+func snapshotType(f *ast.File, fset *token.FileSet, line int, x ast.Expr, structs map[string]*ast.StructType) ast.Expr {
+	switch x := ast.Unparen(x).(type) {
+	case *ast.Ident:
+		decl, _ := declaredType(f, fset, line, x.Name)
+		return decl.typ
+	case *ast.StarExpr:
+		if p, ok := snapshotType(f, fset, line, x.X, structs).(*ast.StarExpr); ok {
+			return p.X
+		}
+	case *ast.SelectorExpr:
+		t := snapshotType(f, fset, line, x.X, structs)
+		if p, ok := t.(*ast.StarExpr); ok {
+			t = p.X // selectors read through pointers
+		}
+		if st := structs[recvTypeName(t)]; t != nil && st != nil {
+			return fieldType(st, x.Sel.Name)
+		}
+	}
+	return nil
+}
+
+// fieldType returns the type of the field name of st, embedded fields
+// included; nil when there is none.
+func fieldType(st *ast.StructType, name string) ast.Expr {
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 && embeddedName(field.Type) == name {
+			return field.Type
+		}
+		for _, n := range field.Names {
+			if n.Name == name {
+				return field.Type
+			}
+		}
+	}
+	return nil
+}
+
+// embeddedName returns the field name of the embedded type t.
+func embeddedName(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// lockIn returns the sync or sync/atomic type a value of type typ holds, as
+// itself, a field, an embedded field or an array element, such as
+// "sync.Mutex"; "" when there is none. Pointers, slices and maps share
+// what they refer to, so copying them copies no lock. seen guards
+// against recursive struct types.
+func lockIn(typ ast.Expr, structs map[string]*ast.StructType, seen map[string]bool) string {
+	switch t := typ.(type) {
+	case *ast.IndexExpr: // atomic.Pointer[T], generic struct types
+		return lockIn(t.X, structs, seen)
+	case *ast.SelectorExpr:
+		pkg, _ := t.X.(*ast.Ident)
+		if pkg != nil && (pkg.Name == "sync" && syncNoCopy[t.Sel.Name] || pkg.Name == "atomic") {
+			return pkg.Name + "." + t.Sel.Name
+		}
+	case *ast.Ident:
+		st := structs[t.Name]
+		_ = st // @inco: st != nil && !seen[t.Name], -return("")
+		if !(st != nil && !seen[t.Name]) {
+			return ""
+		}
+		if seen == nil {
+			seen = make(map[string]bool)
+		}
+		seen[t.Name] = true
+		return lockIn(st, structs, seen)
+	case *ast.StructType:
+		for _, field := range t.Fields.List {
+			if lock := lockIn(field.Type, structs, seen); lock != "" {
+				return lock
+			}
+		}
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return lockIn(t.Elt, structs, seen)
+		}
+	}
+	return ""
+}
+
+// packageStructs returns the struct types declared at the top level of
+// f and of the other non-test files of its package in dir, by name.
+func packageStructs(f *ast.File, dir string) map[string]*ast.StructType {
+	structs := make(map[string]*ast.StructType)
+	add := func(file *ast.File) {
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && structs[ts.Name.Name] == nil {
+					structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	add(f)
+	entries, _ := os.ReadDir(dir)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		_ = name // @inco: strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go"), -continue
+		if !(strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		_ = err // @inco: err == nil && file.Name.Name == f.Name.Name, -continue
+		if !(err == nil && file.Name.Name == f.Name.Name) {
+			continue
+		}
+		add(file)
+	}
+	return structs
+}
@@ -0,0 +1,175 @@
+package inco
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Postconditions
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Ensure(t *testing.T) {
+	d := ParseDirective("// @ensure #state if(ok) s.count == old(s.count)+1, -panic(\"lost a count\")")
+	if d == nil || d.Kind != KindEnsure || d.Cond != "ok" || d.Expr != "s.count == old(s.count)+1" ||
+		!reflect.DeepEqual(d.Tags, []string{"state"}) || !reflect.DeepEqual(d.ActionArgs, []string{`"lost a count"`}) {
+		t.Errorf("ParseDirective = %+v", d)
+	}
+	if d := ParseDirective("// @ensure -closed f"); d == nil || d.Kind != KindEnsureClosed {
+		t.Errorf("@ensure -closed = %+v", d)
+	}
+	for _, bad := range []string{
+		"// @ensure n > 0, -return(err)", // a postcondition can only panic
+		"// @ensure -open f",
+		"// @ensure",
+	} {
+		if d := ParseDirective(bad); d != nil {
+			t.Errorf("ParseDirective(%q) = %+v, want nil", bad, d)
+		}
+	}
+}
+
+func TestSnapshotOld(t *testing.T) {
+	d := ParseDirective(`// @ensure if(old(n) > 0) s.n == old(s.n) + old(n) && old(s.n) >= 0, -panic(fmt.Sprint(old(s.n)))`)
+	check, olds, err := ensureSnapshots(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Cond != "_inco_old0 > 0" || check.Expr != "s.n == _inco_old1 + _inco_old0 && _inco_old1 >= 0" ||
+		check.ActionArgs[0] != "fmt.Sprint(_inco_old1)" || !reflect.DeepEqual(olds, []string{"n", "s.n"}) {
+		t.Errorf("ensureSnapshots = %+v, %q", check, olds)
+	}
+	if d.Expr != "s.n == old(s.n) + old(n) && old(s.n) >= 0" {
+		t.Errorf("ensureSnapshots changed the directive: %q", d.Expr)
+	}
+
+	for expr, want := range map[string]string{
+		"old(a, b) > 0":        "old(a, b): old takes one value",
+		"old(old(n)) == n":     "old(old(n)): old inside old",
+		"n == old(xs...)[0]":   "old(xs...): old takes one value",
+		"old() == nil":         "old(): old takes one value",
+		"n > 0 && len(old(s))": "",
+	} {
+		_, _, err := snapshotOld(expr, nil)
+		if got := fmt.Sprint(err); want == "" && err != nil || want != "" && !strings.HasPrefix(got, want) {
+			t.Errorf("snapshotOld(%q) error = %v, want %q", expr, err, want)
+		}
+	}
+}
+
+const ensureVetSrc = `package main
+
+import "sync"
+
+type Account struct {
+	mu      sync.Mutex
+	balance int
+	log     []string
+}
+
+type Point struct{ X, Y int }
+
+func (a *Account) Deposit(n int) (err error) {
+	// @ensure a.balance == old(a.balance) + n
+	// @ensure old(a).balance < a.balance && *old(a) != *a
+	// @ensure len(old(*a).log) <= len(a.log)
+	// @ensure old(a.mu) == a.mu
+	// @ensure old(err) == nil || err != nil
+	// @ensure old(n, a) > 0
+	// @ensure @entry(n) > 0
+	a.balance += n
+	return nil
+}
+
+func (p Point) Move(dx int) {
+	// @ensure p.X == old(p.X) + dx
+	p.X += dx
+}
+
+func (p *Point) Shift(q Point) {
+	// @ensure p.X == old(q.X)
+	p.X = q.X
+}
+`
+
+func TestVet_Ensure(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": ensureVetSrc})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, fmt.Sprintf("%d: %s %s: %s", d.Line, d.Code, d.Severity, d.Message))
+	}
+	want := []string{
+		"15: old-pointer warning: old(a) copies the pointer a, not what it points to, so old(a).balance reads the value at return; write old(a.balance)",
+		"15: old-pointer warning: old(a) copies the pointer a, not what it points to, so *old(a) reads the value at return; write old(*a)",
+		"16: copy-lock error: old(*a) copies a sync.Mutex, which must not be copied; snapshot the fields the postcondition compares instead",
+		"17: copy-lock error: old(a.mu) copies a sync.Mutex, which must not be copied; snapshot the fields the postcondition compares instead",
+		"18: entry-result warning: err is the named result on line 13, which nothing assigns before this contract: the check reads its zero value, not anything the function computed; move the contract after err is set, or write @entry(err) if the zero value is what it checks",
+		"19: old error: old(n, a): old takes one value",
+		"20: entry error: @entry(n) in @ensure: the postcondition is checked at return; write old(n) for the value at the directive",
+		"26: value-receiver warning: old(p.X): p is a value receiver, so Move changes its own copy of p, which the caller never sees; the postcondition checks only that copy; use a pointer receiver",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEngine_Ensure(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type Counter struct{ n int }
+
+func (c *Counter) Add(k int) {
+	// @ensure c.n == old(c.n) + 1, -panic(fmt.Sprintf("n went from %d to %d", old(c.n), c.n))
+	c.n += k
+}
+
+func main() {
+	c := &Counter{}
+	c.Add(1)
+	fmt.Println("added", c.n)
+	c.Add(2)
+}
+`
+	dir := setupDir(t, map[string]string{"go.mod": "module ensure\n\ngo 1.21\n", "main.go": src})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+
+	cmd := exec.Command("go", "run", "-overlay="+filepath.Join(e.CacheDir, "overlay.json"), ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "added 1") || !strings.Contains(string(out), "n went from 1 to 3") ||
+		!strings.Contains(string(out), filepath.Join(dir, "main.go")+":8") {
+		t.Errorf("go run = %v, want the second Add to fail its postcondition at main.go:8:\n%s", err, out)
+	}
+
+	dir = setupDir(t, map[string]string{"main.go": "package main\n\nfunc F(n int) {\n\t// @ensure n == old(old(n))\n\tn++\n}\n"})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, "main.go:4") || !strings.Contains(msg, "old inside old") {
+		t.Errorf("panic = %q, want the nested old at main.go:4", msg)
+	}
+}
+
+func TestVerify_EnsurePositions(t *testing.T) {
+	msg := typecheckFailure(t, `package main
+
+type counter struct{ n int }
+
+func (c *counter) Inc() {
+	// @ensure c.n == old(c.n+delta)
+	// @ensure c.n > old(c.n) && c.n <= limit
+	c.n++
+}
+`)
+	for _, want := range []string{"main.go:6: undefined: delta", "main.go:7: undefined: limit"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("typecheck failure lacks %q: the old() snapshot and the deferred check should point at their directive, got: %s", want, msg)
+		}
+	}
+}
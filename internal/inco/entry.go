@@ -0,0 +1,260 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// Named results read at entry: @entry(name)
+// ---------------------------------------------------------------------------
+
+// expandEntry returns s with each @entry(name) replaced by name, and names
+// with those names appended. @entry marks a name the contract reads at its
+// value on entry to the function on purpose: a named result still holding
+// its zero value, or a parameter before the body assigns it.
+func expandEntry(s string, names []string) (string, []string) {
+	for _, m := range entryRe.FindAllStringSubmatch(s, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return entryRe.ReplaceAllString(s, "$1"), names
+}
+
+// entryRead is a named result a contract reads before the function
+// assigns it, so the check only ever sees the zero value.
+type entryRead struct {
+	name   string
+	result int // line of the named result
+}
+
+func (r entryRead) String() string {
+	return fmt.Sprintf("%s is the named result on line %d, which nothing assigns before this contract: the check reads its zero value, not anything the function computed; move the contract after %s is set, or write @entry(%s) if the zero value is what it checks",
+		r.name, r.result, r.name, r.name)
+}
+
+// entryReads returns the names of d's check, other than those it marks
+// with @entry, that are named results of the function enclosing line and
+// that no statement before the end of line assigns. On entry a named
+// result is the zero value of its type, so a contract such as err == nil
+// written above the code setting err checks nothing. Statements are taken
+// in source order, but inside a loop its statements precede one another;
+// a result whose address is taken, or that a function literal assigns,
+// counts as assigned. A postcondition reads its names at return, but the
+// operands of its old calls where it is written.
+func entryReads(f *ast.File, fset *token.FileSet, line int, d *Directive) []entryRead {
+	pos := lineEnd(f, fset, line)
+	names := append(varIdents(d.Cond), varIdents(d.Expr)...)
+	if d.Kind == KindEnsure {
+		names = nil
+		for _, s := range oldOperands(d) {
+			names = append(names, varIdents(s)...)
+		}
+	}
+	slices.Sort(names)
+	var out []entryRead
+	for _, name := range slices.Compact(names) {
+		_ = name // @inco: !slices.Contains(d.Entry, name), -continue
+		if !(!slices.Contains(d.Entry, name)) {
+			continue
+		}
+		body, decl, result := entryScope(f, pos, name)
+		_ = result // @inco: result && localDecl(body, pos, name) == nil, -continue
+		if !(result && localDecl(body, pos, name) == nil) {
+			continue
+		}
+		if assignedBefore(body, pos, name) == 0 {
+			out = append(out, entryRead{name: name, result: srcLine(fset, decl.Pos())})
+		}
+	}
+	return out
+}
+
+// entryError returns the error of a misplaced @entry(name) of d: one that
+// names no parameter or named result of the function enclosing line, or
+// one written after the body assigns the name, where it no longer holds
+// its entry value. A postcondition reads the values of its old calls at
+// the directive instead. nil when every @entry is where it belongs.
+func entryError(f *ast.File, fset *token.FileSet, line int, d *Directive) error {
+	pos := lineEnd(f, fset, line)
+	for _, name := range d.Entry {
+		_ = d // @inco: d.Kind != KindEnsure, -return(fmt.Errorf("@entry(%s) in @ensure: the postcondition is checked at return; write old(%s) for the value at the directive", name, name))
+		if !(d.Kind != KindEnsure) {
+			return fmt.Errorf("@entry(%s) in @ensure: the postcondition is checked at return; write old(%s) for the value at the directive", name, name)
+		}
+		body, _, _ := entryScope(f, pos, name)
+		_ = body // @inco: body != nil && localDecl(body, pos, name) == nil, -return(fmt.Errorf("@entry(%s): %s is not a parameter or named result of the enclosing function", name, name))
+		if !(body != nil && localDecl(body, pos, name) == nil) {
+			return fmt.Errorf("@entry(%s): %s is not a parameter or named result of the enclosing function", name, name)
+		}
+		assigned := assignedBefore(body, pos, name)
+		_ = assigned // @inco: assigned == 0, -return(fmt.Errorf("@entry(%s): %s is assigned on line %d, so the check would not read its entry value; move the contract above that line", name, name, srcLine(fset, assigned)))
+		if !(assigned == 0) {
+			return fmt.Errorf("@entry(%s): %s is assigned on line %d, so the check would not read its entry value; move the contract above that line", name, name, srcLine(fset, assigned))
+		}
+	}
+	return nil
+}
+
+// resolveEntry returns d, and panics when one of its @entry names is
+// misplaced (see entryError).
+func (e *Engine) resolveEntry(d *Directive, f *ast.File, fset *token.FileSet, path string, line int) *Directive {
+	_ = d // @inco: len(d.Entry) > 0, -return(d)
+	if !(len(d.Entry) > 0) {
+		return d
+	}
+	err := entryError(f, fset, line, d)
+	_ = err // @inco: err == nil, -panic(e.directiveError(path, line, err))
+	if !(err == nil) {
+		panic(e.directiveError(path, line, err))
+	}
+	return d
+}
+
+// varIdents returns the identifiers of the expression s, but not the
+// field and method names of its selectors, which name no variable.
+func varIdents(s string) []string {
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	var names []string
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.Ident:
+			names = append(names, n.Name)
+		}
+		return true
+	}
+	ast.Inspect(x, visit)
+	return names
+}
+
+// entryScope returns the body of the innermost function enclosing pos with
+// a parameter, receiver or named result called name, that identifier, and
+// whether it is a result.
+func entryScope(f *ast.File, pos token.Pos, name string) (body *ast.BlockStmt, decl *ast.Ident, result bool) {
+	find := func(lists ...*ast.FieldList) *ast.Ident {
+		for _, fl := range lists {
+			if fl == nil {
+				continue
+			}
+			for _, field := range fl.List {
+				for _, n := range field.Names {
+					if n.Name == name {
+						return n
+					}
+				}
+			}
+		}
+		return nil
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		var b *ast.BlockStmt
+		var ft *ast.FuncType
+		var recv *ast.FieldList
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			b, ft, recv = fn.Body, fn.Type, fn.Recv
+		case *ast.FuncLit:
+			b, ft = fn.Body, fn.Type
+		default:
+			return true
+		}
+		_ = b // @inco: b != nil && b.Pos() <= pos && pos < b.End(), -return(false)
+		if !(b != nil && b.Pos() <= pos && pos < b.End()) {
+			return false
+		}
+		if id := find(recv, ft.Params); id != nil {
+			body, decl, result = b, id, false // later matches are nested deeper
+		} else if id := find(ft.Results); id != nil {
+			body, decl, result = b, id, true
+		}
+		return true
+	})
+	return body, decl, result
+}
+
+// assignedBefore returns the position of the first statement of body that
+// may change name before pos: an assignment, an increment or decrement, a
+// range clause assigning it, or taking its address, written above pos or
+// later in a loop enclosing pos, whose next iteration it precedes. NoPos
+// when there is none.
+func assignedBefore(body *ast.BlockStmt, pos token.Pos, name string) token.Pos {
+	var loops []ast.Node // loops enclosing pos
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			if n.Pos() <= pos && pos < n.End() {
+				loops = append(loops, n)
+			}
+		}
+		return true
+	})
+	first := token.NoPos
+	assigns := func(at token.Pos, xs ...ast.Expr) {
+		before := at < pos || slices.ContainsFunc(loops, func(l ast.Node) bool { return l.Pos() <= at && at < l.End() })
+		for _, x := range xs {
+			if isIdentNamed(x, name) && before && (first == token.NoPos || at < first) {
+				first = at
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			assigns(n.Pos(), n.Lhs...)
+		case *ast.IncDecStmt:
+			assigns(n.Pos(), n.X)
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				assigns(n.Pos(), n.Key, n.Value)
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				assigns(n.Pos(), n.X)
+			}
+		}
+		return true
+	})
+	return first
+}
+
+// lineEnd returns the position at the end of line, after any statement an
+// inline directive on it follows.
+func lineEnd(f *ast.File, fset *token.FileSet, line int) token.Pos {
+	tf := fset.File(f.Pos())
+	_ = line // @inco: line < tf.LineCount(), -return(token.Pos(tf.Base() + tf.Size()))
+	if !(line < tf.LineCount()) {
+		return token.Pos(tf.Base() + tf.Size())
+	}
+	return tf.LineStart(line+1) - 1
+}
+
+// entryWarnings warns about the checks of directives, keyed by line, that
+// read a named result at its zero value (see entryReads).
+func entryWarnings(f *ast.File, fset *token.FileSet, relPath string, directives map[int][]*Directive) []Warning {
+	var out []Warning
+	for _, line := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[line] {
+			_ = d // @inco: d.Kind.checksExpr(), -continue
+			if !(d.Kind.checksExpr()) {
+				continue
+			}
+			for _, r := range entryReads(f, fset, line, d) {
+				out = append(out, Warning{Path: relPath, Line: line, Message: r.String()})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package inco
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Named results read at entry
+// ---------------------------------------------------------------------------
+
+const entrySrc = `package main
+
+type store struct{ err error }
+
+func (s *store) Load(path string) (data []byte, err error) {
+	// @inco: err == nil
+	// @inco: @entry(err) == nil && s.err == nil
+	// @inco: if(@entry(data) == nil) path != ""
+	data, err = read(path)
+	_ = err // @inco: err == nil, -return(nil, err)
+	return data, nil
+}
+
+func Count(xs []int) (n int) {
+	for range xs {
+		// @inco: n >= 0
+		n++
+	}
+	func() (n int) {
+		// @inco: n == 0
+		return 1
+	}()
+	return n
+}
+
+func read(path string) ([]byte, error) { return nil, nil }
+`
+
+func TestParseDirective_Entry(t *testing.T) {
+	d := ParseDirective("// @inco: if(@entry( n ) > 0) @entry(err) == nil && n > 0, -return(err)")
+	if d == nil {
+		t.Fatal("directive with @entry did not parse")
+	}
+	if d.Cond != "n > 0" || d.Expr != "err == nil && n > 0" || !reflect.DeepEqual(d.Entry, []string{"err", "n"}) {
+		t.Errorf("ParseDirective = cond %q, expr %q, entry %q", d.Cond, d.Expr, d.Entry)
+	}
+}
+
+func TestVet_EntryResults(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": entrySrc})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, fmt.Sprintf("%d:%d: %s %s", d.Line, d.Column, d.Code, d.Severity))
+	}
+	want := []string{
+		"6:5: entry-result warning",
+		"20:6: entry-result warning",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestVet_EntryMisplaced(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Parse(s string) (n int, err error) {
+	n = len(s)
+	// @inco: @entry(n) == 0
+	// @inco: @entry(s) != "" && @entry(m) > 0
+	m := n
+	return m, nil
+}
+`})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, fmt.Sprintf("%d: %s: %s", d.Line, d.Code, d.Message))
+	}
+	want := []string{
+		"5: entry: @entry(n): n is assigned on line 4, so the check would not read its entry value; move the contract above that line",
+		"6: entry: @entry(m): m is not a parameter or named result of the enclosing function",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEngine_EntryResults(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": entrySrc})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	if len(e.Warnings) != 2 || e.Warnings[0].Line != 6 || e.Warnings[1].Line != 20 ||
+		!strings.Contains(e.Warnings[0].Message, "err is the named result on line 5") {
+		t.Errorf("Warnings = %q, want the contracts on lines 6 and 20", e.Warnings)
+	}
+	shadow := readShadow(t, e)
+	for _, want := range []string{"if !(err == nil && s.err == nil) {", "if data == nil {"} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow lacks %q:\n%s", want, shadow)
+		}
+	}
+
+	dir = setupDir(t, map[string]string{"main.go": "package main\n\nfunc F() (n int) {\n\tn = 1\n\t// @inco: @entry(n) == 0\n\treturn n\n}\n"})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, "main.go:5") || !strings.Contains(msg, "@entry(n): n is assigned on line 4") {
+		t.Errorf("panic = %q, want the misplaced @entry at main.go:5", msg)
+	}
+}
@@ -0,0 +1,130 @@
+package inco
+
+import (
+	"go/ast"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Unguarded errors: error assignments nothing checks
+// ---------------------------------------------------------------------------
+
+// isErrorName reports whether a variable called name holds an error by
+// convention: err, or a name ending in Err such as readErr. The audit
+// does not typecheck, so the name is all there is to go by.
+func isErrorName(name string) bool {
+	return name == "err" || strings.HasSuffix(name, "Err")
+}
+
+// unguardedErrors returns the assignments to an error variable in the
+// functions of f that nothing checks: the next statement of the block to
+// mention the variable assigns it again instead of reading it (if err !=
+// nil, return err, _ = err // @inco: ...), and no contract up to there
+// mentions it. When the rest of the block does not mention it, checking
+// it is left to the code around the block. Func names the enclosing
+// declared function; function literals count as part of it.
+func unguardedErrors(f *ast.File, fset *token.FileSet, relPath string, directives map[int][]*Directive) []Mark {
+	var out []Mark
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Body != nil, -continue
+		if !(ok && fn.Body != nil) {
+			continue
+		}
+		name := auditName(fn)
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			var list []ast.Stmt
+			switch n := n.(type) {
+			case *ast.BlockStmt:
+				list = n.List
+			case *ast.CaseClause:
+				list = n.Body
+			case *ast.CommClause:
+				list = n.Body
+			}
+			for i, stmt := range list {
+				assign, ok := stmt.(*ast.AssignStmt)
+				_ = ok // @inco: ok, -continue
+				if !(ok) {
+					continue
+				}
+				for _, lhs := range assign.Lhs {
+					id, ok := lhs.(*ast.Ident)
+					_ = ok // @inco: ok && isErrorName(id.Name), -continue
+					if !(ok && isErrorName(id.Name)) {
+						continue
+					}
+					next := overwrite(list[i+1:], id.Name)
+					_ = next // @inco: next != nil, -continue
+					if !(next != nil) {
+						continue
+					}
+					from := srcLine(fset, assign.Pos())
+					if !guardedBetween(directives, id.Name, from, srcLine(fset, next.Pos())) {
+						out = append(out, Mark{Path: relPath, Line: from, Func: name})
+					}
+				}
+			}
+			return true
+		})
+	}
+	return out
+}
+
+// overwrite returns the first of stmts to mention the variable name if
+// it assigns the variable without reading it, and nil otherwise.
+// Assignments nested in other statements (a loop body, a branch) do not
+// count: they may not run.
+func overwrite(stmts []ast.Stmt, name string) ast.Stmt {
+	for _, s := range stmts {
+		if usesIdent(s, name) {
+			return nil
+		}
+		if assign, ok := s.(*ast.AssignStmt); ok && slices.ContainsFunc(assign.Lhs, func(lhs ast.Expr) bool {
+			id, ok := lhs.(*ast.Ident)
+			return ok && id.Name == name
+		}) {
+			return s
+		}
+	}
+	return nil
+}
+
+// usesIdent reports whether n reads the variable name. Assigning to it
+// does not count.
+func usesIdent(n ast.Node, name string) bool {
+	used := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if _, ok := lhs.(*ast.Ident); !ok && usesIdent(lhs, name) {
+					used = true
+				}
+			}
+			for _, rhs := range n.Rhs {
+				used = used || usesIdent(rhs, name)
+			}
+			return false
+		case *ast.Ident:
+			used = used || n.Name == name
+		}
+		return !used
+	})
+	return used
+}
+
+// guardedBetween reports whether a check among directives, on lines from
+// to to, mentions name.
+func guardedBetween(directives map[int][]*Directive, name string, from, to int) bool {
+	for line := from; line <= to; line++ {
+		for _, d := range directives[line] {
+			if d.Kind.checksExpr() && d.Kind != KindEnsure && slices.Contains(exprIdents(d.Expr), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
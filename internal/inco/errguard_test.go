@@ -0,0 +1,58 @@
+package inco
+
+import (
+	"slices"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Unguarded errors
+// ---------------------------------------------------------------------------
+
+func TestAudit_UnguardedErrors(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"store/db.go": `package store
+
+import "os"
+
+type DB struct{ path string }
+
+func (db *DB) Load() ([]byte, error) {
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(db.path)
+	_ = err // @inco: err == nil
+	defer f.Close()
+	info, statErr := f.Stat()
+	// @inco: statErr == nil, -panic("stat")
+	_ = info
+	_, err = f.Seek(0, 0)
+	data = append(data, 0)
+	_, err = f.Read(nil)
+	for range data {
+		_, err = f.Read(nil)
+	}
+	return data, err
+}
+
+func Save(path string) {
+	err := os.WriteFile(path, nil, 0o644)
+	err = os.Remove(path)
+	if err != nil {
+		panic(err)
+	}
+}
+`,
+	})
+	r := Audit(dir, Limits{})
+	got := r.UnguardedErrors()
+	want := []Mark{
+		{Path: "store/db.go", Line: 18, Func: "(*DB).Load"},
+		{Path: "store/db.go", Line: 28, Func: "Save"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("UnguardedErrors = %v, want %v", got, want)
+	}
+}
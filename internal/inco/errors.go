@@ -0,0 +1,108 @@
+package inco
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ---------------------------------------------------------------------------
+// Error categories
+// ---------------------------------------------------------------------------
+
+// The categories of the errors Engine.Run panics with and Engine.Try
+// returns. The errors read as before; errors.Is tells which category one
+// belongs to:
+//
+//	defer func() {
+//		if err, ok := recover().(error); ok && errors.Is(err, inco.ErrTypecheck) { ... }
+//	}()
+var (
+	// ErrNoPackage is a package selection (Engine.Packages) that matches
+	// no package.
+	ErrNoPackage = errors.New("no package")
+
+	// ErrTypecheck is generated code the compiler would reject: a shadow
+	// failing -typecheck, or the code of a directive inco try renders.
+	ErrTypecheck = errors.New("typecheck failed")
+
+	// ErrParseDirective is a directive that cannot be parsed or injected
+	// where it stands: an invalid expression, a -return not matching the
+	// results, a @must not on a channel operation, and so on.
+	ErrParseDirective = errors.New("invalid directive")
+)
+
+// categories names the categories for those who cannot import them, such
+// as the clients of inco serve.
+var categories = []struct {
+	err  error
+	name string
+}{
+	{ErrNoPackage, "no-package"},
+	{ErrTypecheck, "typecheck"},
+	{ErrParseDirective, "invalid-directive"},
+}
+
+// ErrorCategory returns the name of err's category: "no-package",
+// "typecheck" or "invalid-directive", or "" when it has none.
+func ErrorCategory(err error) string {
+	for _, c := range categories {
+		if errors.Is(err, c.err) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// categorized is an error filed under one of the categories above. It
+// reads as the error itself.
+type categorized struct {
+	category error
+	err      error
+}
+
+func (c categorized) Error() string   { return c.err.Error() }
+func (c categorized) Unwrap() []error { return []error{c.category, c.err} }
+
+// categorize files err under category; nil stays nil.
+func categorize(category, err error) error {
+	_ = err // @inco: err != nil, -return(nil)
+	if !(err != nil) {
+		return nil
+	}
+	return categorized{category: category, err: err}
+}
+
+// directiveError reports err about the directive on line of the file at
+// path, under ErrParseDirective.
+func (e *Engine) directiveError(path string, line int, err error) error {
+	return categorize(ErrParseDirective, lineError{path: e.relPath(path), line: line, err: err})
+}
+
+// lineError is err at a line of a file; Run's failure diagnostics take the
+// line from it.
+type lineError struct {
+	path string
+	line int
+	err  error
+}
+
+func (l lineError) Error() string { return fmt.Sprintf("%s:%d: %v", l.path, l.line, l.err) }
+func (l lineError) Unwrap() error { return l.err }
+
+// summarized sums up several errors, which it wraps.
+type summarized struct {
+	msg  string
+	errs []error
+}
+
+func (s summarized) Error() string   { return s.msg }
+func (s summarized) Unwrap() []error { return s.errs }
+
+// panicError returns the value of a recovered panic as an error, keeping
+// its category when it is one.
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
@@ -0,0 +1,122 @@
+package inco
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// runError returns the error Run panics with.
+func runError(t *testing.T, e *Engine) error {
+	t.Helper()
+	var err error
+	func() {
+		defer func() {
+			err, _ = recover().(error)
+		}()
+		e.Run()
+	}()
+	if err == nil {
+		t.Fatal("Run should panic with an error")
+	}
+	return err
+}
+
+func TestErrorCategories_Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		setup    func(e *Engine)
+		category error
+		msg      string
+	}{
+		{"directive", "package main\n\nfunc Do() {\n\t// @invariant ok\n}\n", nil,
+			ErrParseDirective, "main.go:4:5: @invariant ok is not inside a for loop body"},
+		{"shadow", "package main\n\nfunc Do(x int) {\n\t// @inco: x >\n}\n", nil,
+			ErrParseDirective, "invalid shadow for main.go"},
+		{"typecheck", "package main\n\nfunc Do(x int) {\n\t// @inco: y > 0\n\t_ = x\n}\n\nfunc main() {}\n",
+			func(e *Engine) { e.Typecheck = true }, ErrTypecheck, "undefined: y"},
+		{"pkgs", "package main\n\nfunc main() {}\n",
+			func(e *Engine) { e.Packages = []string{".", "./api/..."} }, ErrNoPackage, `package pattern "./api/..." (-pkgs) matches no package`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := setupDir(t, map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n", "main.go": tt.src})
+			e := NewEngine(dir)
+			e.Quiet = true
+			e.KeepGoing = tt.name == "directive" // failures are collected, then reported together
+			if tt.setup != nil {
+				tt.setup(e)
+			}
+			err := runError(t, e)
+			if !errors.Is(err, tt.category) {
+				t.Errorf("Run error %q is not %v", err, tt.category)
+			}
+			if !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("Run error = %q, want it to contain %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestErrorCategories_Try(t *testing.T) {
+	e := NewEngine(t.TempDir())
+	tests := []struct {
+		directive, params string
+		category          error
+	}{
+		{"x >", "x int", ErrParseDirective},
+		{"x > 0, -panic(x, y)", "x int", nil},
+		{`x > ""`, "x int", ErrTypecheck},
+		{"x > 0, -return(1)", "x int", ErrParseDirective},
+	}
+	for _, tt := range tests {
+		_, err := e.Try(tt.directive, tt.params, "")
+		if tt.category == nil {
+			continue
+		}
+		if !errors.Is(err, tt.category) {
+			t.Errorf("Try(%q) = %v, want a %v error", tt.directive, err, tt.category)
+		}
+		if got, want := ErrorCategory(err), ErrorCategory(tt.category); got != want || got == "" {
+			t.Errorf("ErrorCategory(Try(%q)) = %q, want %q", tt.directive, got, want)
+		}
+	}
+}
+
+func TestDiagnosticCodes(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Do(n int, items []int) {
+	// @inco:n > 0
+	for _, n := range items {
+		// @inco: n > 0
+		_ = n
+	}
+	// @invariant n > 0
+}
+
+// Export panics on a bad n.
+func Export(n int) {
+	// @inco: n > 0
+}
+`})
+	want := map[Code]Severity{
+		CodeMissingSpace:   SeverityError,
+		CodeShadowedParam:  SeverityWarning,
+		CodeInvariant:      SeverityError,
+		CodeMissingMessage: SeverityWarning,
+	}
+	got := make(map[Code]Severity)
+	for _, d := range VetWith(dir, VetOptions{RequireMessages: true}) {
+		got[d.Code] = d.Severity
+	}
+	for code, severity := range want {
+		if got[code] != severity {
+			t.Errorf("diagnostic %s has severity %q, want %q (all: %v)", code, got[code], severity, got)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("diagnostic codes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,248 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Export types
+// ---------------------------------------------------------------------------
+
+// PackageDoc lists the contracts of the functions in one package.
+type PackageDoc struct {
+	Name  string    `json:"name"`
+	Dir   string    `json:"dir"` // relative to root, "." for the root package
+	Funcs []FuncDoc `json:"funcs"`
+}
+
+// FuncDoc lists the contracts of one function.
+type FuncDoc struct {
+	Name      string        `json:"name"` // Recv.Method for methods
+	Signature string        `json:"signature"`
+	Path      string        `json:"path"` // relative to root
+	Line      int           `json:"line"`
+	Pre       []ContractDoc `json:"preconditions,omitempty"`
+	Post      []ContractDoc `json:"postconditions,omitempty"`
+	Inv       []ContractDoc `json:"loop_invariants,omitempty"`
+}
+
+// ContractDoc describes one directive for documentation.
+type ContractDoc struct {
+	Expr        string   `json:"expr"`
+	Cond        string   `json:"cond,omitempty"` // if(cond) guard
+	Tags        []string `json:"tags,omitempty"`
+	OnViolation string   `json:"on_violation"` // e.g. "panics", "returns `nil, err`"
+	Line        int      `json:"line"`
+}
+
+// ---------------------------------------------------------------------------
+// Export entry point
+// ---------------------------------------------------------------------------
+
+// Export extracts the contracts of every function under root, grouped by
+// package and sorted by directory, file and line. Functions and packages
+// without contracts are omitted. Directives in function literals count
+// toward the enclosing function declaration.
+func Export(root string) []PackageDoc {
+	_ = root // @inco: root != "", -panic("Export: root must not be empty")
+	if !(root != "") {
+		panic("Export: root must not be empty")
+	}
+	e := &Engine{Root: root}
+	byDir := make(map[string]*PackageDoc)
+	fset := token.NewFileSet()
+	walkGoFiles(root, false, func(path string) error {
+		funcs, name := exportFile(fset, e.relPath(path), path)
+		_ = funcs // @inco: len(funcs) > 0, -return(nil)
+		if !(len(funcs) > 0) {
+			return nil
+		}
+		dir := filepath.Dir(e.relPath(path))
+		if byDir[dir] == nil {
+			byDir[dir] = &PackageDoc{Name: name, Dir: dir}
+		}
+		byDir[dir].Funcs = append(byDir[dir].Funcs, funcs...)
+		return nil
+	})
+
+	var pkgs []PackageDoc
+	for _, dir := range slices.Sorted(maps.Keys(byDir)) {
+		p := byDir[dir]
+		sort.SliceStable(p.Funcs, func(i, j int) bool {
+			if p.Funcs[i].Path != p.Funcs[j].Path {
+				return p.Funcs[i].Path < p.Funcs[j].Path
+			}
+			return p.Funcs[i].Line < p.Funcs[j].Line
+		})
+		pkgs = append(pkgs, *p)
+	}
+	return pkgs
+}
+
+// exportFile returns the functions with contracts in path and the name of
+// its package.
+func exportFile(fset *token.FileSet, relPath, path string) ([]FuncDoc, string) {
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	joinContinuations(f, fset)
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	standalone, inline := collectDirectives(f, fset, splitLines(src))
+
+	var funcs []FuncDoc
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Body != nil, -continue
+		if !(ok && fn.Body != nil) {
+			continue
+		}
+		fd := FuncDoc{
+			Name:      funcDeclName(fn),
+			Signature: funcSignature(fset, fn),
+			Path:      relPath,
+			Line:      srcLine(fset, fn.Pos()),
+		}
+		for line := srcLine(fset, fn.Body.Lbrace); line <= srcLine(fset, fn.Body.Rbrace); line++ {
+			for _, d := range append(standalone[line], inline[line]...) {
+				switch d.Kind {
+				case KindEnsureClosed:
+					fd.Post = append(fd.Post, ContractDoc{Expr: d.Expr, Tags: d.Tags, OnViolation: "panics", Line: line})
+				case KindEnsure:
+					fd.Post = append(fd.Post, ContractDoc{Expr: d.Expr, Cond: d.Cond, Tags: d.Tags, OnViolation: describeAction(d), Line: line})
+				case KindInvariant:
+					fd.Inv = append(fd.Inv, ContractDoc{Expr: d.Expr, Cond: d.Cond, Tags: d.Tags, OnViolation: describeAction(d), Line: line})
+				case KindMust:
+					fd.Post = append(fd.Post, ContractDoc{Expr: "the channel operation completes within " + d.Expr, Tags: d.Tags, OnViolation: "panics (test builds)", Line: line})
+				case KindRecvOnly, KindSendOnly:
+					fd.Pre = append(fd.Pre, ContractDoc{Expr: d.Expr + " is a " + chanDirection(d) + " channel", Tags: d.Tags, OnViolation: "does not compile", Line: line})
+				default:
+					fd.Pre = append(fd.Pre, ContractDoc{Expr: d.Expr, Cond: d.Cond, Tags: d.Tags, OnViolation: describeAction(d), Line: line})
+				}
+			}
+		}
+		if len(fd.Pre)+len(fd.Post)+len(fd.Inv) > 0 {
+			funcs = append(funcs, fd)
+		}
+	}
+	return funcs, f.Name.Name
+}
+
+// funcDeclName returns fn's name, qualified by its receiver type for
+// methods (as in the audit report).
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		return recvTypeName(fn.Recv.List[0].Type) + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// funcSignature renders fn's declaration without its body or doc comment.
+func funcSignature(fset *token.FileSet, fn *ast.FuncDecl) string {
+	decl := *fn
+	decl.Body, decl.Doc = nil, nil
+	var buf bytes.Buffer
+	err := format.Node(&buf, fset, &decl)
+	_ = err // @inco: err == nil, -return(fn.Name.Name)
+	if !(err == nil) {
+		return fn.Name.Name
+	}
+	return buf.String()
+}
+
+// describeAction returns what happens when d is violated.
+func describeAction(d *Directive) string {
+	args := strings.Join(d.ActionArgs, ", ")
+	switch d.Action {
+	case ActionReturn:
+		if args == "" {
+			return "returns"
+		}
+		return "returns `" + args + "`"
+	case ActionContinue:
+		if args != "" {
+			return "skips to the next iteration of loop `" + args + "`"
+		}
+		return "skips to the next loop iteration"
+	case ActionBreak:
+		if args != "" {
+			return "breaks out of `" + args + "`"
+		}
+		return "stops the loop"
+	case ActionDefault:
+		return "sets `" + d.Target + " = " + args + "`"
+	default: // ActionPanic
+		if args == "" {
+			return "panics"
+		}
+		return "panics with `" + args + "`"
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Markdown rendering
+// ---------------------------------------------------------------------------
+
+// WriteMarkdown writes p as a markdown document: one section per function
+// with its signature, preconditions, postconditions and loop invariants.
+func (p PackageDoc) WriteMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "# Package %s\n\n", p.Name)
+	fmt.Fprintf(w, "Contracts of the functions in `%s`.\n", p.Dir)
+	for _, fn := range p.Funcs {
+		fmt.Fprintf(w, "\n## %s\n\n", fn.Name)
+		fmt.Fprintf(w, "```go\n%s\n```\n\n", fn.Signature)
+		fmt.Fprintf(w, "Defined at `%s:%d`.\n", filepath.ToSlash(fn.Path), fn.Line)
+		if len(fn.Pre) > 0 {
+			fmt.Fprintf(w, "\n**Preconditions**\n\n")
+			for _, c := range fn.Pre {
+				fmt.Fprintf(w, "- %s`%s` — otherwise %s%s\n", condPrefix(c), c.Expr, c.OnViolation, tagSuffix(c))
+			}
+		}
+		if len(fn.Post) > 0 {
+			fmt.Fprintf(w, "\n**Postconditions**\n\n")
+			for _, c := range fn.Post {
+				fmt.Fprintf(w, "- `%s` is closed before the function returns — otherwise %s%s\n", c.Expr, c.OnViolation, tagSuffix(c))
+			}
+		}
+		if len(fn.Inv) > 0 {
+			fmt.Fprintf(w, "\n**Loop invariants**\n\n")
+			for _, c := range fn.Inv {
+				fmt.Fprintf(w, "- %s`%s` at the start of every iteration (line %d) — otherwise %s%s\n", condPrefix(c), c.Expr, c.Line, c.OnViolation, tagSuffix(c))
+			}
+		}
+	}
+}
+
+// condPrefix returns the markdown describing a conditional contract's
+// guard, or "" for unconditional contracts.
+func condPrefix(c ContractDoc) string {
+	if c.Cond == "" {
+		return ""
+	}
+	return "when `" + c.Cond + "`: "
+}
+
+// tagSuffix returns the markdown listing a contract's tags, or "".
+func tagSuffix(c ContractDoc) string {
+	if len(c.Tags) == 0 {
+		return ""
+	}
+	return " (#" + strings.Join(c.Tags, ", #") + ")"
+}
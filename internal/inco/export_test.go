@@ -0,0 +1,92 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Contract export
+// ---------------------------------------------------------------------------
+
+func TestExport(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "os"
+
+// Open opens path.
+func Open(path string, n int) (*os.File, error) {
+	// @inco: path != "", -return(nil, os.ErrInvalid)
+	// @inco: #slow if(n > 0) n < 100
+	f, err := os.Open(path)
+	// @ensure -closed f
+	defer f.Close()
+	return f, err
+}
+
+func noContracts() {}
+`,
+		"sub/sub.go": `package sub
+
+type T struct{}
+
+func (t *T) Do(x int) {
+	_ = x // @inco: x >= 0, -panic("negative")
+}
+`,
+	})
+	pkgs := Export(dir)
+	if len(pkgs) != 2 || pkgs[0].Dir != "." || pkgs[1].Dir != "sub" {
+		t.Fatalf("packages = %+v, want . and sub", pkgs)
+	}
+	if len(pkgs[0].Funcs) != 1 || pkgs[0].Funcs[0].Name != "Open" {
+		t.Fatalf("main funcs = %+v, want only Open", pkgs[0].Funcs)
+	}
+	fn := pkgs[0].Funcs[0]
+	if fn.Signature != "func Open(path string, n int) (*os.File, error)" {
+		t.Errorf("signature = %q", fn.Signature)
+	}
+	if len(fn.Pre) != 2 || len(fn.Post) != 1 {
+		t.Fatalf("pre = %+v, post = %+v", fn.Pre, fn.Post)
+	}
+	if got := pkgs[1].Funcs[0].Name; got != "T.Do" {
+		t.Errorf("method name = %q, want T.Do", got)
+	}
+
+	var b strings.Builder
+	pkgs[0].WriteMarkdown(&b)
+	md := b.String()
+	for _, want := range []string{
+		"# Package main",
+		"## Open",
+		"`path != \"\"` — otherwise returns `nil, os.ErrInvalid`",
+		"when `n > 0`: `n < 100` — otherwise panics (#slow)",
+		"`f` is closed before the function returns",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestDescribeAction(t *testing.T) {
+	tests := []struct {
+		directive string
+		want      string
+	}{
+		{"// @inco: x", "panics"},
+		{`// @inco: x, -panic("bad")`, "panics with `\"bad\"`"},
+		{"// @inco: x, -return", "returns"},
+		{"// @inco: x, -return(0, err)", "returns `0, err`"},
+		{"// @inco: x, -continue", "skips to the next loop iteration"},
+		{"// @inco: x, -break", "stops the loop"},
+		{"// @inco: x, -continue(rows)", "skips to the next iteration of loop `rows`"},
+		{"// @inco: x, -break(rows)", "breaks out of `rows`"},
+	}
+	for _, tt := range tests {
+		if got := describeAction(ParseDirective(tt.directive)); got != tt.want {
+			t.Errorf("describeAction(%q) = %q, want %q", tt.directive, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// gofmt pre-check: directives that gofmt would rebind
+// ---------------------------------------------------------------------------
+
+// binding is where a directive comment attaches: inline after the
+// statement on its line, standalone before the statement that follows it,
+// or nowhere.
+type binding struct {
+	line    int    // 1-based line of the comment
+	comment string // the comment, e.g. "// @inco: y > 0"
+	target  string // e.g. "after `y := 1`", or "" when the comment binds to nothing
+}
+
+// formatDrift reports the directives of a file that is not gofmt-clean and
+// would bind to a different statement once formatted. Directives bind by
+// line, so code sharing a line with a directive (a one-line block, a
+// statement after a closing brace) changes what it guards when gofmt
+// splits it. Files gofmt cannot format are left to the type checker.
+func formatDrift(relPath string, src []byte, f *ast.File, fset *token.FileSet) []Warning {
+	formatted, err := format.Source(src)
+	_ = err // @inco: err == nil && !bytes.Equal(formatted, src), -return(nil)
+	if !(err == nil && !bytes.Equal(formatted, src)) {
+		return nil
+	}
+	ffset := token.NewFileSet()
+	ff, err := parser.ParseFile(ffset, relPath, formatted, parser.ParseComments)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	got, want := directiveBindings(f, fset, src), directiveBindings(ff, ffset, formatted)
+	_ = got // @inco: len(got) == len(want), -return(nil)
+	if !(len(got) == len(want)) {
+		return nil
+	}
+	var out []Warning
+	for i, b := range got {
+		if b.target == want[i].target {
+			continue
+		}
+		out = append(out, Warning{
+			Path:    relPath,
+			Line:    b.line,
+			Message: fmt.Sprintf("%s binds %s, but %s once gofmt'd; run gofmt", b.comment, describeTarget(b.target), describeTarget(want[i].target)),
+		})
+	}
+	return out
+}
+
+// describeTarget phrases a binding target for a warning.
+func describeTarget(target string) string {
+	if target == "" {
+		return "to no statement"
+	}
+	return target
+}
+
+// directiveBindings resolves the directive comments of f in source order,
+// the way collectDirectives attaches them.
+func directiveBindings(f *ast.File, fset *token.FileSet, src []byte) []binding {
+	lines := splitLines(src)
+	stmtLines := collectStmtLines(f, fset)
+	var out []binding
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			_ = c // @inco: len(ParseDirectives(c.Text)) > 0, -continue
+			if !(len(ParseDirectives(c.Text)) > 0) {
+				continue
+			}
+			line := srcLine(fset, c.Pos())
+			b := binding{line: line, comment: strings.TrimSpace(c.Text)}
+			trimmed := strings.TrimSpace(lines[line-1])
+			_, inBody := enclosingBodyEnd(f, fset, line)
+			switch {
+			case (strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/*")) && inBody:
+				b.target = "at the end of its function"
+				if s := attachedStmt(f, fset, line, false); s != nil {
+					b.target = "before `" + stmtHead(fset, src, s) + "`"
+				}
+			case stmtLines[line]:
+				b.target = "after `" + stmtHead(fset, src, attachedStmt(f, fset, line, true)) + "`"
+			}
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// isSimpleStmt reports whether s is a statement an inline directive can
+// follow; collectStmtLines records the lines they start on.
+func isSimpleStmt(s ast.Stmt) bool {
+	switch s.(type) {
+	case *ast.AssignStmt, *ast.ExprStmt, *ast.ReturnStmt,
+		*ast.IncDecStmt, *ast.SendStmt, *ast.GoStmt, *ast.DeferStmt,
+		*ast.BranchStmt:
+		return true
+	}
+	return false
+}
+
+// stmtHead returns the first line of the source of s, shortened to 40
+// characters.
+func stmtHead(fset *token.FileSet, src []byte, s ast.Stmt) string {
+	start, end := fset.PositionFor(s.Pos(), false).Offset, fset.PositionFor(s.End(), false).Offset
+	text, _, _ := strings.Cut(string(src[start:end]), "\n")
+	text = strings.TrimSpace(text)
+	if r := []rune(text); len(r) > 40 {
+		text = string(r[:37]) + "..."
+	}
+	return text
+}
@@ -0,0 +1,62 @@
+package inco
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------------------------
+// gofmt pre-check
+// ---------------------------------------------------------------------------
+
+func TestEngine_FormatDrift(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": "package main\n\nfunc f(ok bool) int {\n\ty := 0\n\tif ok { y = 1 } // @inco: y > 0\n\treturn y\n}\n",
+		// Not gofmt-clean, but formatting keeps every directive where it is.
+		"tidy.go": "package main\n\nfunc g(x int)  {\n  // @inco: x > 0\n  x++ // @inco: x > 1\n}\n",
+	})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	want := "main.go:5: // @inco: y > 0 binds after `y = 1`, but to no statement once gofmt'd; run gofmt"
+	if len(e.Warnings) != 1 || e.Warnings[0].String() != want {
+		t.Errorf("Warnings = %q, want %q", e.Warnings, want)
+	}
+}
+
+func TestDirectiveBindings(t *testing.T) {
+	src := "package main\n\nfunc f(x int) {\n\t// @inco: x > 0\n\tx++ // @inco: x > 1\n\t// @inco: x > 2\n}\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range directiveBindings(f, fset, []byte(src)) {
+		got = append(got, b.target)
+	}
+	want := "before `x++`|after `x++`|at the end of its function"
+	if strings.Join(got, "|") != want {
+		t.Errorf("targets = %q, want %q", got, want)
+	}
+}
+
+func TestDirectiveBindings_LongUnicodeStatement(t *testing.T) {
+	src := "package main\n\nfunc f(名前 string) {\n\t// @inco: 名前 != \"\"\n\tprintln(\"こんにちは、\" + 名前 + \" さん 👋 ようこそ、またお会いしましたね\")\n}\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := directiveBindings(f, fset, []byte(src))
+	want := "before `println(\"こんにちは、\" + 名前 + \" さん 👋 ようこそ、ま...`"
+	if len(b) != 1 || b[0].target != want {
+		t.Fatalf("bindings = %+v, want %q", b, want)
+	}
+	if !utf8.ValidString(b[0].target) {
+		t.Errorf("target %q is not valid UTF-8", b[0].target)
+	}
+}
@@ -0,0 +1,103 @@
+package inco
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of TestGolden_Shadows")
+
+// ---------------------------------------------------------------------------
+// Golden shadows
+// ---------------------------------------------------------------------------
+
+// TestGolden_Shadows generates the shadows of each case in
+// testdata/shadows, a package of Go files, and compares the shadow of each
+// file.go with file.go.golden. The project root reads as $ROOT in the
+// golden files. A file without a golden file must not get a shadow
+// differing from its source.
+//
+// After a deliberate change to the generated code, rewrite the golden
+// files and review their diff:
+//
+//	go test ./internal/inco -run TestGolden -update
+func TestGolden_Shadows(t *testing.T) {
+	cases, err := filepath.Glob(filepath.Join("testdata", "shadows", "*"))
+	if err != nil || len(cases) == 0 {
+		t.Fatalf("no cases in testdata/shadows (%v)", err)
+	}
+	for _, c := range cases {
+		t.Run(filepath.Base(c), func(t *testing.T) {
+			files := map[string]string{"go.mod": "module golden\n\ngo 1.21\n"}
+			inputs, _ := filepath.Glob(filepath.Join(c, "*.go"))
+			for _, in := range inputs {
+				src, err := os.ReadFile(in)
+				if err != nil {
+					t.Fatal(err)
+				}
+				files[filepath.Base(in)] = string(src)
+			}
+			dir := setupDir(t, files)
+			e := NewEngine(dir)
+			e.Quiet = true
+			e.Run()
+
+			for _, in := range inputs {
+				name := filepath.Base(in)
+				got := files[name]
+				if shadow, ok := e.Overlay.Replace[filepath.Join(dir, name)]; ok {
+					data, err := os.ReadFile(shadow)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got = strings.ReplaceAll(string(data), dir, "$ROOT")
+				}
+				golden := in + ".golden"
+				if *update {
+					if got == files[name] {
+						os.Remove(golden)
+						continue
+					}
+					if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(golden)
+				if os.IsNotExist(err) {
+					want, err = []byte(files[name]), nil
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != string(want) {
+					t.Errorf("shadow of %s differs from %s:\n%s", name, golden, lineDiff(string(want), got))
+				}
+			}
+		})
+	}
+}
+
+// lineDiff returns the lines of want and got from the first that differs,
+// a few of each, for a failure message that points at the change.
+func lineDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	i := 0
+	for i < len(w) && i < len(g) && w[i] == g[i] {
+		i++
+	}
+	var b strings.Builder
+	for _, side := range []struct {
+		sign  string
+		lines []string
+	}{{"-", w}, {"+", g}} {
+		for j := i; j < len(side.lines) && j < i+5; j++ {
+			b.WriteString(side.sign + " " + side.lines[j] + "\n")
+		}
+	}
+	return fmt.Sprintf("first difference at line %d:\n%s", i+1, b.String())
+}
@@ -190,13 +190,15 @@ func TestEngine_NestedIncoignore(t *testing.T) {
 		"main.go": "package main\n\nfunc main() {}\n",
 		"sub/ok.go": `package sub
 
-// @inco: true
-func OK() {}
+func OK() {
+	// @inco: true
+}
 `,
 		"sub/gen.pb.go": `package sub
 
-// @inco: true
-func Gen() {}
+func Gen() {
+	// @inco: true
+}
 `,
 		"sub/.incoignore": "*.pb.go\n",
 	})
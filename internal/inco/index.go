@@ -0,0 +1,97 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+)
+
+// ---------------------------------------------------------------------------
+// Index bounds contracts
+// ---------------------------------------------------------------------------
+
+// idxExpr returns the contract "-idx i s" expands to.
+func idxExpr(i, s string) string {
+	return fmt.Sprintf("%s >= 0 && %s < len(%s)", i, i, s)
+}
+
+// idxOperands recognizes the expansion of "-idx i s" and returns i and s.
+func idxOperands(expr string) (i, s string, ok bool) {
+	x, err := parser.ParseExpr(expr)
+	_ = err // @inco: err == nil, -return("", "", false)
+	if !(err == nil) {
+		return "", "", false
+	}
+	and, ok := x.(*ast.BinaryExpr)
+	_ = and // @inco: ok && and.Op == token.LAND, -return("", "", false)
+	if !(ok && and.Op == token.LAND) {
+		return "", "", false
+	}
+	lo, okLo := and.X.(*ast.BinaryExpr)
+	hi, okHi := and.Y.(*ast.BinaryExpr)
+	_ = lo // @inco: okLo && okHi && lo.Op == token.GEQ && hi.Op == token.LSS, -return("", "", false)
+	if !(okLo && okHi && lo.Op == token.GEQ && hi.Op == token.LSS) {
+		return "", "", false
+	}
+	call, okCall := hi.Y.(*ast.CallExpr)
+	_ = call // @inco: okCall && isIdentNamed(call.Fun, "len") && len(call.Args) == 1, -return("", "", false)
+	if !(okCall && isIdentNamed(call.Fun, "len") && len(call.Args) == 1) {
+		return "", "", false
+	}
+	i, s = types.ExprString(lo.X), types.ExprString(call.Args[0])
+	if idxExpr(i, s) != expr {
+		return "", "", false
+	}
+	return i, s, true
+}
+
+// integerTypes are the predeclared types an index may have.
+var integerTypes = map[string]bool{
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true, "rune": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true, "byte": true,
+}
+
+// vetIndex returns problems with the index bounds contract d at line: an
+// index declared with a type that is not an integer, and an indexed value
+// declared with a type that has no index bounds. Variables whose type the
+// source does not spell out, or spells as a named type, are not checked.
+func vetIndex(f *ast.File, fset *token.FileSet, line int, d *Directive) []string {
+	i, s, ok := idxOperands(d.Expr)
+	_ = ok // @inco: ok, -return(nil)
+	if !(ok) {
+		return nil
+	}
+	var problems []string
+	if decl, _ := declaredType(f, fset, line, i); decl.typ != nil {
+		if id, isIdent := decl.typ.(*ast.Ident); isIdent && types.Universe.Lookup(id.Name) != nil && !integerTypes[id.Name] {
+			problems = append(problems, fmt.Sprintf("-idx %s %s: %s is %s, not an integer", i, s, i, id.Name))
+		}
+	}
+	decl, _ := declaredType(f, fset, line, s)
+	if why := notIndexable(decl.typ); why != "" {
+		problems = append(problems, fmt.Sprintf("-idx %s %s: %s is %s, %s", i, s, s, types.ExprString(decl.typ), why))
+	}
+	return problems
+}
+
+// notIndexable returns why values of the declared type typ have no index
+// bounds, or "" when they do or typ is unknown.
+func notIndexable(typ ast.Expr) string {
+	switch t := typ.(type) {
+	case *ast.MapType:
+		return "a map; its keys have no bounds"
+	case *ast.ChanType, *ast.FuncType, *ast.StructType, *ast.InterfaceType:
+		return "which cannot be indexed"
+	case *ast.StarExpr:
+		if _, isArray := t.X.(*ast.ArrayType); !isArray {
+			return "which cannot be indexed"
+		}
+	case *ast.Ident:
+		if types.Universe.Lookup(t.Name) != nil && t.Name != "string" {
+			return "which cannot be indexed"
+		}
+	}
+	return ""
+}
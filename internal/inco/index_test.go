@@ -0,0 +1,65 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Index bounds contracts
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Idx(t *testing.T) {
+	d := ParseDirective(`// @inco: if(n > 0) -idx i buf, -return(0, errRange)`)
+	if d == nil {
+		t.Fatal("got nil")
+	}
+	if d.Expr != "i >= 0 && i < len(buf)" || d.Cond != "n > 0" || d.Action != ActionReturn {
+		t.Errorf("got %+v", d)
+	}
+	if d := ParseDirective("// @inco: -idx pos r.data"); d == nil || d.Expr != "pos >= 0 && pos < len(r.data)" {
+		t.Errorf("selector: got %+v", d)
+	}
+	if d := ParseDirective("// @invariant -idx i s"); d == nil || d.Kind != KindInvariant || d.Expr != "i >= 0 && i < len(s)" {
+		t.Errorf("invariant: got %+v", d)
+	}
+	if i, s, ok := idxOperands("pos >= 0 && pos < len(r.data)"); !ok || i != "pos" || s != "r.data" {
+		t.Errorf("idxOperands = %q, %q, %t", i, s, ok)
+	}
+	if _, _, ok := idxOperands("i >= 0 && j < len(s)"); ok {
+		t.Error("idxOperands accepted two different indexes")
+	}
+}
+
+func TestVet_Idx(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Get(i int, f float64, s []byte, m map[int]string, a *[4]int, name string, c chan int) {
+	// @inco: -idx i s
+	// @inco: -idx i a
+	// @inco: -idx i name
+	// @inco: -idx f s
+	// @inco: -idx i m
+	// @inco: -idx i c
+	k := 0
+	// @inco: -idx k s
+}
+`})
+	diags := Vet(dir)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{7, "-idx f s: f is float64, not an integer"},
+		{8, "-idx i m: m is map[int]string, a map; its keys have no bounds"},
+		{9, "-idx i c: c is chan int, which cannot be indexed"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		if d := diags[i]; d.Line != w.line || !strings.Contains(d.Message, w.message) {
+			t.Errorf("diag %d = %v, want line %d containing %q", i, d, w.line, w.message)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package inco
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Instrumenting one file
+// ---------------------------------------------------------------------------
+
+// InstrumentResult is the instrumented form of one source file.
+type InstrumentResult struct {
+	Content []byte // the shadow; the source itself when Changed is false
+	Changed bool   // the file has directives to inject
+	// SourceLines holds, for each line of Content, the source line it
+	// stands for: the source map of the //line directives, 0 for the
+	// directives themselves.
+	SourceLines []int
+	Warnings    []Warning
+}
+
+// ErrInvalidFile is a file Instrument refuses before parsing it: one
+// without content, or a path that leaves the root.
+var ErrInvalidFile = errors.New("invalid file")
+
+// Instrument returns the shadow inco gen would write for a file at path
+// holding src, without reading the file or writing the cache: build
+// systems that run inco as a remote action send the file and get it back
+// instrumented. path may be relative to the root; it names the file in
+// //line directives and messages, and its directory is the package
+// imports are resolved for, and it must stay under the root. src must
+// not be empty: Instrument never reads the file. Settings, the message catalog and the package
+// graph are the engine's.
+//
+// The shadow is parsed, as inco gen verifies it, but not typechecked,
+// which takes its package. Instrument loads the message catalog like Run
+// and must not run concurrently with Run or another Instrument. Errors
+// are those inco gen reports for the file, categorized the same way.
+func (e *Engine) Instrument(path string, src []byte) (result InstrumentResult, err error) {
+	_ = src // @inco: len(src) > 0, -return(InstrumentResult{}, fmt.Errorf("%w %s: no content", ErrInvalidFile, path))
+	if !(len(src) > 0) {
+		return InstrumentResult{}, fmt.Errorf("%w %s: no content", ErrInvalidFile, path)
+	}
+	path, err = e.rootedPath(path)
+	_ = err // @inco: err == nil, -return(InstrumentResult{}, err)
+	if !(err == nil) {
+		return InstrumentResult{}, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = InstrumentResult{}, panicError(r)
+		}
+	}()
+	e.catalog = e.loadCatalog()
+	// The file comes back alone, without the file Run adds to declare
+	// the @must helper: its shadow declares one under a name of its own.
+	sum := sha256.Sum256([]byte(filepath.ToSlash(e.relPath(path))))
+	e.mustZero = fmt.Sprintf("%s_%x", mustZeroName, sum[:4])
+	defer func() { e.mustZero = "" }()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	_ = err // @inco: err == nil, -return(InstrumentResult{}, err)
+	if !(err == nil) {
+		return InstrumentResult{}, err
+	}
+	joinContinuations(f, fset)
+	r := e.shadowOf(path, src, f, fset)
+	_, err = parser.ParseFile(token.NewFileSet(), path, r.ShadowData, parser.AllErrors)
+	_ = err // @inco: err == nil, -return(InstrumentResult{}, categorize(ErrParseDirective, fmt.Errorf("invalid shadow for %s: %w", e.relPath(path), err)))
+	if !(err == nil) {
+		return InstrumentResult{}, categorize(ErrParseDirective, fmt.Errorf("invalid shadow for %s: %w", e.relPath(path), err))
+	}
+	result = InstrumentResult{Content: r.ShadowData, Changed: string(r.ShadowData) != string(src), Warnings: r.Warnings}
+	if !result.Changed {
+		result.Content = src
+	}
+	result.SourceLines = sourceLines(result.Content)
+	return result, nil
+}
+
+// rootedPath resolves path, which may be relative to the root, and
+// reports an error when it leaves the root.
+func (e *Engine) rootedPath(path string) (string, error) {
+	joined := filepath.Clean(path)
+	if !filepath.IsAbs(joined) {
+		joined = filepath.Join(e.Root, joined)
+	}
+	root, err := filepath.Abs(e.Root)
+	_ = err // @inco: err == nil, -return("", err)
+	if !(err == nil) {
+		return "", err
+	}
+	abs, err := filepath.Abs(joined)
+	_ = err // @inco: err == nil, -return("", err)
+	if !(err == nil) {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	_ = err // @inco: err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), -return("", fmt.Errorf("%w %s: not under the root %s", ErrInvalidFile, path, e.Root))
+	if !(err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		return "", fmt.Errorf("%w %s: not under the root %s", ErrInvalidFile, path, e.Root)
+	}
+	return joined, nil
+}
+
+// sourceLines maps each line of the Go file src to the line the compiler
+// reports for it, following its //line directives. A directive names the
+// line that follows it; lines before the first count from 1.
+func sourceLines(src []byte) []int {
+	lines := splitLines(src)
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	out := make([]int, len(lines))
+	next := 1
+	for i, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "//line "); ok {
+			if colon := strings.LastIndexByte(rest, ':'); colon >= 0 {
+				if n, err := strconv.Atoi(rest[colon+1:]); err == nil && n > 0 {
+					next = n
+					continue
+				}
+			}
+		}
+		out[i] = next
+		next++
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package inco
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Instrument
+// ---------------------------------------------------------------------------
+
+const instrumentSrc = `package api
+
+func Get(id string) (string, error) {
+	// @inco: id != "", -return("", errors.New("empty id"))
+	return id, nil
+}
+`
+
+func TestEngine_Instrument(t *testing.T) {
+	dir := setupDir(t, map[string]string{"go.mod": "module example.com/api\n\ngo 1.21\n"})
+	e := NewEngine(dir)
+	e.Quiet = true
+
+	// The file need not exist: its content comes with the call.
+	r, err := e.Instrument("api/get.go", []byte(instrumentSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(r.Content)
+	if !r.Changed || !strings.Contains(content, "\"errors\"") || !strings.Contains(content, `return "", errors.New("empty id")`) {
+		t.Fatalf("Instrument = %+v:\n%s", r, content)
+	}
+	if !strings.Contains(content, "//line "+filepath.Join(dir, "api", "get.go")+":4") {
+		t.Errorf("//line directives do not name the file:\n%s", content)
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if len(r.SourceLines) != len(lines) {
+		t.Fatalf("%d source lines for %d lines", len(r.SourceLines), len(lines))
+	}
+	for i, line := range lines {
+		want := map[string]int{"package api": 1, "\treturn id, nil": 5, "}": 6}[line]
+		if strings.HasPrefix(line, "//line ") {
+			want = 0
+		}
+		if want != 0 || strings.HasPrefix(line, "//line ") {
+			if r.SourceLines[i] != want {
+				t.Errorf("line %d %q maps to source line %d, want %d", i+1, line, r.SourceLines[i], want)
+			}
+		}
+	}
+	if _, err := os.Stat(e.CacheDir); !os.IsNotExist(err) {
+		t.Errorf("Instrument wrote the cache directory (%v)", err)
+	}
+
+	// Without directives the source comes back as it is.
+	src := "package api\n\nfunc Ping() {}\n"
+	r, err = e.Instrument("api/ping.go", []byte(src))
+	if err != nil || r.Changed || string(r.Content) != src || !reflect.DeepEqual(r.SourceLines, []int{1, 2, 3}) {
+		t.Errorf("Instrument without directives = %+v, %v", r, err)
+	}
+
+	_, err = e.Instrument("api/bad.go", []byte("package api\n\nfunc F(n int) {\n\t// @inco: n >\n}\n"))
+	if !errors.Is(err, ErrParseDirective) || !strings.Contains(err.Error(), "bad.go:4") {
+		t.Errorf("invalid directive: %v", err)
+	}
+	if _, err = e.Instrument("api/bad.go", []byte("package api\n\nfunc F(")); err == nil {
+		t.Error("a file that does not parse was instrumented")
+	}
+
+	// Instrument never reads the file: empty content is refused, not
+	// read from disk, and so are paths that leave the root.
+	os.WriteFile(filepath.Join(dir, "disk.go"), []byte(instrumentSrc), 0o644)
+	for _, src := range [][]byte{nil, {}} {
+		if r, err := e.Instrument("disk.go", src); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("Instrument with content %q = %+v, %v; want it refused", src, r, err)
+		}
+	}
+	outside := filepath.Join(t.TempDir(), "get.go")
+	os.WriteFile(outside, []byte(instrumentSrc), 0o644)
+	for _, path := range []string{"../get.go", "api/../../get.go", outside, ".", dir} {
+		if _, err := e.Instrument(path, []byte(instrumentSrc)); !errors.Is(err, ErrInvalidFile) || !strings.Contains(err.Error(), "not under the root") {
+			t.Errorf("Instrument(%q) = %v; want it refused", path, err)
+		}
+	}
+	if _, err := e.Instrument("api/../get.go", []byte(instrumentSrc)); err != nil {
+		t.Errorf("a path that stays under the root was refused: %v", err)
+	}
+}
+
+func TestSourceLines(t *testing.T) {
+	src := "package p\n//line a.go:10\nx\ny\n//line a.go:3\nz\n//line not a directive\n"
+	if got, want := sourceLines([]byte(src)), []int{1, 0, 10, 11, 0, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sourceLines = %v, want %v", got, want)
+	}
+}
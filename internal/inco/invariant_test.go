@@ -0,0 +1,96 @@
+package inco
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Loop invariants
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Invariant(t *testing.T) {
+	d := ParseDirective("// @invariant i <= len(buf), -return(errOverflow)")
+	if d == nil {
+		t.Fatal("expected directive")
+	}
+	if d.Kind != KindInvariant || d.Expr != "i <= len(buf)" || d.Action != ActionReturn {
+		t.Errorf("got kind=%v expr=%q action=%v", d.Kind, d.Expr, d.Action)
+	}
+	if d := ParseDirective("// @invariant"); d != nil {
+		t.Errorf("expected nil for an empty invariant, got %+v", d)
+	}
+}
+
+func TestEngine_InvariantAtLoopTop(t *testing.T) {
+	dir := setupDir(t, map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n", "main.go": `package main
+
+func Fill(buf []byte) int {
+	i := 0
+	for i < 10 {
+		buf[i] = 1
+		// @invariant i <= len(buf)
+		i++
+	}
+	return i
+}
+`})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Run()
+	shadow := readShadow(t, e)
+	path := filepath.Join(dir, "main.go")
+	want := "\tfor i < 10 {\n//line " + path + ":7\n\t\tif !(i <= len(buf)) {"
+	if !strings.Contains(shadow, want) {
+		t.Errorf("invariant not checked at the top of the loop body:\n%s", shadow)
+	}
+	if strings.Count(shadow, "i <= len(buf)) {") != 1 {
+		t.Errorf("invariant should be injected once:\n%s", shadow)
+	}
+	if !strings.Contains(shadow, "//line "+path+":6\n\t\tbuf[i] = 1") {
+		t.Errorf("line mapping not restored after the invariant:\n%s", shadow)
+	}
+}
+
+func TestEngine_InvariantOutsideLoop(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Do(xs []int) {
+	for range xs {
+		func() {
+			// @invariant len(xs) > 0
+		}()
+	}
+}
+`})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, "main.go:6: @invariant len(xs) > 0 is not inside a for loop body") {
+		t.Errorf("unexpected error: %s", msg)
+	}
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, d.Message)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "not inside a for loop body") {
+		t.Errorf("vet diagnostics = %q", got)
+	}
+}
+
+func TestAudit_Invariants(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Sum(xs []int) (n int) {
+	_ = xs // @inco: xs != nil
+	for i := range xs {
+		// @invariant i < len(xs)
+		n += xs[i]
+	}
+	return n
+}
+`})
+	r := Audit(dir, Limits{})
+	if r.TotalRequires != 1 || r.TotalInvariants != 1 || r.TotalDirectives != 2 {
+		t.Errorf("requires=%d invariants=%d directives=%d", r.TotalRequires, r.TotalInvariants, r.TotalDirectives)
+	}
+}
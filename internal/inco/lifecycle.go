@@ -0,0 +1,137 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------------------------
+// State rules: method contracts on the receiver
+// ---------------------------------------------------------------------------
+
+// StateRule is a contract in a method that reads the method's receiver,
+// such as c.state == StateOpen on Conn.Close. Together the rules of a type
+// describe its lifecycle: which methods may be called in which state.
+type StateRule struct {
+	Type        string // receiver type name
+	Method      string
+	Line        int // line of the directive
+	Expr        string
+	Cond        string // if(cond) guard
+	OnViolation string // e.g. "panics with `\"Close before Open\"`"
+}
+
+// ReceiverAudit lists the state rules of one receiver type.
+type ReceiverAudit struct {
+	Type  string
+	Dir   string // package directory relative to root, "." for the root package
+	Rules []StateRule
+}
+
+// stateRules returns the contracts in the methods of f that mention the
+// method's receiver, sorted by line. Contracts in function literals count
+// toward the enclosing method.
+func stateRules(f *ast.File, fset *token.FileSet, directives map[int][]*Directive) []StateRule {
+	var rules []StateRule
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Recv != nil && fn.Body != nil && len(fn.Recv.List) == 1, -continue
+		if !(ok && fn.Recv != nil && fn.Body != nil && len(fn.Recv.List) == 1) {
+			continue
+		}
+		field := fn.Recv.List[0]
+		_ = field // @inco: len(field.Names) == 1 && field.Names[0].Name != "_", -continue
+		if !(len(field.Names) == 1 && field.Names[0].Name != "_") {
+			continue
+		}
+		recv := field.Names[0].Name
+		start, end := srcLine(fset, fn.Body.Lbrace), srcLine(fset, fn.Body.Rbrace)
+		for line, ds := range directives {
+			_ = line // @inco: start <= line && line <= end, -continue
+			if !(start <= line && line <= end) {
+				continue
+			}
+			for _, d := range ds {
+				if !d.Kind.checksExpr() || d.Kind == KindEnsure {
+					continue
+				}
+				if !slices.Contains(exprIdents(d.Expr), recv) && !slices.Contains(exprIdents(d.Cond), recv) {
+					continue
+				}
+				rules = append(rules, StateRule{
+					Type:        recvTypeName(field.Type),
+					Method:      fn.Name.Name,
+					Line:        line,
+					Expr:        d.Expr,
+					Cond:        d.Cond,
+					OnViolation: describeAction(d),
+				})
+			}
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Line < rules[j].Line })
+	return rules
+}
+
+// receiverAudits groups the state rules of files by package directory and
+// receiver type, sorted by directory then type.
+func receiverAudits(files []FileAudit) []ReceiverAudit {
+	byType := make(map[[2]string]*ReceiverAudit)
+	var out []*ReceiverAudit
+	for _, f := range files {
+		dir := filepath.ToSlash(filepath.Dir(f.RelPath))
+		for _, rule := range f.StateRules {
+			key := [2]string{dir, rule.Type}
+			if byType[key] == nil {
+				byType[key] = &ReceiverAudit{Type: rule.Type, Dir: dir}
+				out = append(out, byType[key])
+			}
+			byType[key].Rules = append(byType[key].Rules, rule)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Dir != out[j].Dir {
+			return out[i].Dir < out[j].Dir
+		}
+		return out[i].Type < out[j].Type
+	})
+	audits := make([]ReceiverAudit, len(out))
+	for i, ra := range out {
+		audits[i] = *ra
+	}
+	return audits
+}
+
+// printReceivers writes one table of state rules per receiver type.
+func printReceivers(w io.Writer, receivers []ReceiverAudit, c Colorizer) {
+	fmt.Fprintf(w, "\n%s\n", c.Bold("Contracts by receiver type:"))
+	for _, ra := range receivers {
+		name := ra.Type
+		if ra.Dir != "." {
+			name = ra.Dir + "." + ra.Type
+		}
+		fmt.Fprintf(w, "\n  %s\n", c.Bold(name))
+		methodW, exprW := len("Method"), len("Contract")
+		contracts := make([]string, len(ra.Rules))
+		for i, rule := range ra.Rules {
+			contracts[i] = rule.Expr
+			if rule.Cond != "" {
+				contracts[i] = "if(" + rule.Cond + ") " + rule.Expr
+			}
+			methodW = max(methodW, utf8.RuneCountInString(rule.Method))
+			exprW = max(exprW, utf8.RuneCountInString(contracts[i]))
+		}
+		fmt.Fprintf(w, "    %-*s  %-*s  %s\n", methodW, "Method", exprW, "Contract", "On violation")
+		fmt.Fprintf(w, "    %s  %s  %s\n", strings.Repeat("─", methodW), strings.Repeat("─", exprW), strings.Repeat("─", len("On violation")))
+		for i, rule := range ra.Rules {
+			fmt.Fprintf(w, "    %-*s  %-*s  %s\n", methodW, rule.Method, exprW, contracts[i], rule.OnViolation)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package inco
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// State rules
+// ---------------------------------------------------------------------------
+
+const sessionSrc = `package net
+
+type state int
+
+const (
+	StateNew state = iota
+	StateOpen
+)
+
+type Conn struct{ state state }
+
+func (c *Conn) Open() {
+	_ = c // @inco: c.state == StateNew, -panic("Open twice")
+	c.state = StateOpen
+}
+
+func (c *Conn) Close(force bool) error {
+	_ = force // @inco: if(!force) c.state == StateOpen, -return(errClosed)
+	_ = force // @inco: force || true
+	return nil
+}
+
+func (Conn) Name(s string) string {
+	_ = s // @inco: s != ""
+	return s
+}
+`
+
+func TestAudit_StateRules(t *testing.T) {
+	dir := setupDir(t, map[string]string{"net/conn.go": sessionSrc})
+	r := Audit(dir, Limits{})
+	if len(r.Receivers) != 1 {
+		t.Fatalf("Receivers = %+v, want one type", r.Receivers)
+	}
+	ra := r.Receivers[0]
+	if ra.Type != "Conn" || ra.Dir != "net" || len(ra.Rules) != 2 {
+		t.Fatalf("got %+v", ra)
+	}
+	if rule := ra.Rules[1]; rule.Method != "Close" || rule.Cond != "!force" || rule.OnViolation != "returns `errClosed`" {
+		t.Errorf("Close rule = %+v", rule)
+	}
+
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	want := "  net.Conn\n" +
+		"    Method  Contract                         On violation\n" +
+		"    ──────  ───────────────────────────────  ────────────\n" +
+		"    Open    c.state == StateNew              panics with `\"Open twice\"`\n" +
+		"    Close   if(!force) c.state == StateOpen  returns `errClosed`\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("report missing receiver table:\n%s", buf.String())
+	}
+}
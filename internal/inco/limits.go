@@ -0,0 +1,119 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// ---------------------------------------------------------------------------
+// Contract size limits
+// ---------------------------------------------------------------------------
+
+// Default contract size limits. Exceeding a limit produces a warning, never
+// an error: a long list of contracts or a sprawling expression makes
+// violations hard to read and is better factored into a validation helper.
+const (
+	DefaultMaxFuncContracts = 10 // contracts injected into one function
+	DefaultMaxExprTerms     = 4  // operands joined by && or || in one expression
+)
+
+// Limits configures the contract size warnings. A zero field selects the
+// default; a negative field disables the check.
+type Limits struct {
+	MaxFuncContracts int
+	MaxExprTerms     int
+}
+
+// Warning is a contract that exceeds one of the Limits.
+type Warning struct {
+	Path    string `json:"path"` // relative to root
+	Line    int    `json:"line"` // 1-based
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s:%d: %s", w.Path, w.Line, w.Message)
+}
+
+// maxFuncContracts returns the effective per-function limit; <= 0 disables.
+func (l Limits) maxFuncContracts() int {
+	if l.MaxFuncContracts == 0 {
+		return DefaultMaxFuncContracts
+	}
+	return l.MaxFuncContracts
+}
+
+// maxExprTerms returns the effective expression limit; <= 0 disables.
+func (l Limits) maxExprTerms() int {
+	if l.MaxExprTerms == 0 {
+		return DefaultMaxExprTerms
+	}
+	return l.MaxExprTerms
+}
+
+// check returns the warnings for the directives of f, keyed by line as
+// returned by collectDirectives, sorted by line. Contracts count toward
+// the function declaration enclosing them, including those in function
+// literals.
+func (l Limits) check(f *ast.File, fset *token.FileSet, relPath string, directives map[int][]*Directive) []Warning {
+	var warnings []Warning
+	lines := slices.Sorted(maps.Keys(directives))
+
+	if limit := l.maxExprTerms(); limit > 0 {
+		for _, line := range lines {
+			for _, d := range directives[line] {
+				if n := exprTerms(d.Expr); d.Kind.checksExpr() && n > limit {
+					warnings = append(warnings, Warning{relPath, line,
+						fmt.Sprintf("contract joins %d conditions (limit %d); consider a named validation helper", n, limit)})
+				}
+			}
+		}
+	}
+
+	if limit := l.maxFuncContracts(); limit > 0 {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			_ = ok // @inco: ok && fn.Body != nil, -continue
+			if !(ok && fn.Body != nil) {
+				continue
+			}
+			start, end := srcLine(fset, fn.Body.Lbrace), srcLine(fset, fn.Body.Rbrace)
+			n := 0
+			for _, line := range lines {
+				if start <= line && line <= end {
+					n += len(directives[line])
+				}
+			}
+			if n > limit {
+				warnings = append(warnings, Warning{relPath, srcLine(fset, fn.Pos()),
+					fmt.Sprintf("%s has %d contracts (limit %d); consider factoring validation into a helper", funcDeclName(fn), n, limit)})
+			}
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Line < warnings[j].Line })
+	return warnings
+}
+
+// exprTerms returns the number of operands joined by && or || at any depth
+// in expr: "a && (b || c)" has 3. Expressions that do not parse count as 1.
+func exprTerms(expr string) int {
+	x, err := parser.ParseExpr(expr)
+	_ = err // @inco: err == nil, -return(1)
+	if !(err == nil) {
+		return 1
+	}
+	n := 1
+	ast.Inspect(x, func(node ast.Node) bool {
+		if be, ok := node.(*ast.BinaryExpr); ok && (be.Op == token.LAND || be.Op == token.LOR) {
+			n++
+		}
+		return true
+	})
+	return n
+}
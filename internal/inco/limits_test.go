@@ -0,0 +1,111 @@
+package inco
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Contract size limits
+// ---------------------------------------------------------------------------
+
+const limitsSrc = `package main
+
+func Big(a, b, c int) {
+	// @inco: a > 0 && b > 0 && c > 0
+	// @inco: a < b; b < c
+	f := func() {
+		_ = c // @inco: c != 42
+	}
+	f()
+}
+
+func Small(a int) {
+	// @inco: a > 0 || a < -10
+}
+`
+
+func TestLimits_Check(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", limitsSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standalone, inline := collectDirectives(f, fset, strings.Split(limitsSrc, "\n"))
+	for line, ds := range inline {
+		standalone[line] = append(standalone[line], ds...)
+	}
+
+	cases := []struct {
+		name   string
+		limits Limits
+		want   []string
+	}{
+		{"defaults", Limits{}, nil},
+		{"tight", Limits{MaxFuncContracts: 3, MaxExprTerms: 2}, []string{
+			"main.go:3: Big has 4 contracts (limit 3)",
+			"main.go:4: contract joins 3 conditions (limit 2)",
+		}},
+		{"disabled", Limits{MaxFuncContracts: -1, MaxExprTerms: -1}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			warnings := c.limits.check(f, fset, "main.go", standalone)
+			if len(warnings) != len(c.want) {
+				t.Fatalf("got %d warnings, want %d: %v", len(warnings), len(c.want), warnings)
+			}
+			for i, w := range c.want {
+				if !strings.HasPrefix(warnings[i].String(), w) {
+					t.Errorf("warning %d = %q, want prefix %q", i, warnings[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestExprTerms(t *testing.T) {
+	cases := []struct {
+		expr string
+		want int
+	}{
+		{"x > 0", 1},
+		{"a && b", 2},
+		{"a && (b || c)", 3},
+		{"f(a && b) || c", 3},
+		{"x >", 1},
+	}
+	for _, c := range cases {
+		if got := exprTerms(c.expr); got != c.want {
+			t.Errorf("exprTerms(%q) = %d, want %d", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestAudit_Warnings(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": limitsSrc})
+	result := Audit(dir, Limits{MaxFuncContracts: 3})
+	if result.TotalWarnings != 1 || len(result.Files[0].Warnings) != 1 {
+		t.Fatalf("TotalWarnings = %d, file warnings = %v; want 1", result.TotalWarnings, result.Files[0].Warnings)
+	}
+	var b strings.Builder
+	result.PrintReport(&b)
+	if !strings.Contains(b.String(), "Contract size warnings (1):") {
+		t.Errorf("report missing warnings section:\n%s", b.String())
+	}
+}
+
+func TestLimits_WarningsOfCachedShadows(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": limitsSrc})
+	for run := 1; run <= 2; run++ {
+		e := NewEngine(dir)
+		e.Quiet = true
+		e.Limits = Limits{MaxFuncContracts: 3, MaxExprTerms: 2}
+		e.Run()
+		// The second run reuses the shadow and reports its warnings again.
+		if len(e.Warnings) != 2 || e.Warnings[0].Line != 3 || e.Warnings[1].Line != 4 {
+			t.Errorf("run %d: Warnings = %q, want lines 3 and 4", run, e.Warnings)
+		}
+	}
+}
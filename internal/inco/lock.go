@@ -0,0 +1,215 @@
+package inco
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Cache directory locking
+// ---------------------------------------------------------------------------
+
+// lockTimeout is how long a process waits for another one's lock on the
+// cache directory before giving up.
+const lockTimeout = 10 * time.Minute
+
+// lockPoll is the interval at which a waiting process retries the lock.
+const lockPoll = 50 * time.Millisecond
+
+// staleAge is the age beyond which a lock or reader registration written
+// on another host is considered abandoned; on this host, a dead process
+// is detected directly.
+const staleAge = time.Hour
+
+// shadowNameRe matches the names writeShadow gives shadows, and the
+// file_<hash>.go names of earlier versions.
+var shadowNameRe = regexp.MustCompile(`_[0-9a-f]{16}\.go$`)
+
+// lockCache takes the lock on cacheDir that serialises the processes
+// writing to it, waiting while another live process holds it. A lock left
+// by a process that died is taken over. It panics after lockTimeout and
+// returns the function releasing the lock.
+func lockCache(cacheDir string) func() {
+	err := os.MkdirAll(cacheDir, 0o755)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	path := filepath.Join(cacheDir, "lock")
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			fmt.Fprintln(f, holderID())
+			f.Close()
+			return func() { os.Remove(path) }
+		}
+		_ = err // @inco: os.IsExist(err), -panic(err)
+		if !(os.IsExist(err)) {
+			panic(err)
+		}
+		holder, stale := staleHolder(path)
+		if stale {
+			removeStale(path)
+			continue
+		}
+		_ = holder // @inco: time.Now().Before(deadline), -panic(fmt.Errorf("%s is locked by %s; remove the lock if that process is gone", cacheDir, holder))
+		if !(time.Now().Before(deadline)) {
+			panic(fmt.Errorf("%s is locked by %s; remove the lock if that process is gone", cacheDir, holder))
+		}
+		time.Sleep(lockPoll)
+	}
+}
+
+// holderID identifies the calling process in lock and reader files:
+// "host pid".
+func holderID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s %d", host, os.Getpid())
+}
+
+// staleHolder reads the holder of the lock or reader file at path and
+// reports whether it is abandoned: its process on this host has exited,
+// or, for another host, the file is older than staleAge. A file without
+// a holder yet is only stale once it is that old.
+func staleHolder(path string) (holder string, stale bool) {
+	info, err := os.Stat(path)
+	_ = err // @inco: err == nil, -return("", os.IsNotExist(err))
+	if !(err == nil) {
+		return "", os.IsNotExist(err)
+	}
+	data, _ := os.ReadFile(path)
+	holder = strings.TrimSpace(string(data))
+	host, pidText, _ := strings.Cut(holder, " ")
+	pid, err := strconv.Atoi(pidText)
+	myHost, _ := os.Hostname()
+	if err == nil && host == myHost {
+		return holder, !processAlive(pid)
+	}
+	return holder, time.Since(info.ModTime()) > staleAge
+}
+
+// staleSeq numbers the names removeStale moves files to.
+var staleSeq atomic.Uint64
+
+// removeStale removes the lock or reader file at path that staleHolder
+// found abandoned. Another process may have replaced it since, after
+// removing it first: the file is therefore renamed to a name of its own,
+// which takes it from path atomically, and judged again there. A file
+// that is not abandoned, one a live process created, is put back, unless
+// yet another process has created path in the meantime.
+func removeStale(path string) {
+	tmp := fmt.Sprintf("%s.stale-%d-%d", path, os.Getpid(), staleSeq.Add(1))
+	err := os.Rename(path, tmp)
+	_ = err // @inco: err == nil, -return
+	if !(err == nil) {
+		return
+	}
+	if _, stale := staleHolder(tmp); !stale {
+		os.Link(tmp, path)
+	}
+	os.Remove(tmp)
+}
+
+// processAlive reports whether the process with id pid is running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	_ = err // @inco: err == nil, -return(false)
+	if !(err == nil) {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
+
+// RemoveCache removes cacheDir, waiting for a generation running in
+// another process to finish first.
+func RemoveCache(cacheDir string) {
+	_, err := os.Stat(cacheDir)
+	_ = err // @inco: err == nil, -return
+	if !(err == nil) {
+		return
+	}
+	unlock := lockCache(cacheDir)
+	defer unlock()
+	err = os.RemoveAll(cacheDir)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Overlay readers
+// ---------------------------------------------------------------------------
+
+// UseOverlay registers the calling process as a user of the overlay in
+// cacheDir, e.g. for the duration of a go build -overlay, and checks it
+// (see CheckOverlay). A generation running in another process is waited
+// for first; later ones leave the shadows the overlay names in place
+// until the returned release function is called or the process exits.
+func UseOverlay(cacheDir string) (release func(), err error) {
+	unlock := lockCache(cacheDir)
+	defer unlock()
+	dir := filepath.Join(cacheDir, "readers")
+	err = os.MkdirAll(dir, 0o755)
+	_ = err // @inco: err == nil, -return(func() {}, err)
+	if !(err == nil) {
+		return func() {}, err
+	}
+	host, _ := os.Hostname()
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d", host, os.Getpid()))
+	err = os.WriteFile(path, []byte(holderID()+"\n"), 0o644)
+	_ = err // @inco: err == nil, -return(func() {}, err)
+	if !(err == nil) {
+		return func() {}, err
+	}
+	return func() { os.Remove(path) }, CheckOverlay(cacheDir)
+}
+
+// activeReaders reports whether a live process uses the overlay in
+// cacheDir. Registrations of processes that are gone are removed.
+func activeReaders(cacheDir string) bool {
+	dir := filepath.Join(cacheDir, "readers")
+	entries, _ := os.ReadDir(dir)
+	active := false
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if _, stale := staleHolder(path); stale {
+			removeStale(path)
+			continue
+		}
+		active = true
+	}
+	return active
+}
+
+// sweepShadows removes the shadows in e.CacheDir that the overlay no
+// longer maps a file to, unless another process is using the overlay:
+// its go command may still read them. They are then swept by a later
+// Run.
+func (e *Engine) sweepShadows() {
+	_ = e // @inco: !activeReaders(e.CacheDir), -return
+	if !(!activeReaders(e.CacheDir)) {
+		return
+	}
+	inUse := make(map[string]bool, len(e.Overlay.Replace))
+	for _, sp := range e.Overlay.Replace {
+		inUse[filepath.Base(sp)] = true
+	}
+	entries, _ := os.ReadDir(e.CacheDir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && shadowNameRe.MatchString(name) && !inUse[name] {
+			os.Remove(filepath.Join(e.CacheDir, name))
+		}
+	}
+}
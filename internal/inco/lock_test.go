@@ -0,0 +1,154 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockCache_Waits(t *testing.T) {
+	dir := t.TempDir()
+	unlock := lockCache(dir)
+	acquired := make(chan time.Time)
+	go func() {
+		unlock2 := lockCache(dir)
+		acquired <- time.Now()
+		unlock2()
+	}()
+	time.Sleep(4 * lockPoll)
+	released := time.Now()
+	unlock()
+	if at := <-acquired; at.Before(released) {
+		t.Error("second lock taken while the first was held")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "lock")); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestLockCache_Stale(t *testing.T) {
+	dir := t.TempDir()
+	host, _ := os.Hostname()
+	lock := filepath.Join(dir, "lock")
+
+	// A process on this host that no longer exists.
+	os.WriteFile(lock, []byte(host+" 2147483646\n"), 0o644)
+	done := make(chan bool)
+	go func() {
+		lockCache(dir)()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale lock of a dead process not taken over")
+	}
+
+	// Another host: stale only once old.
+	os.WriteFile(lock, []byte("elsewhere 1\n"), 0o644)
+	if holder, stale := staleHolder(lock); stale || holder != "elsewhere 1" {
+		t.Errorf("fresh foreign lock: holder %q, stale %t", holder, stale)
+	}
+	old := time.Now().Add(-2 * staleAge)
+	os.Chtimes(lock, old, old)
+	if _, stale := staleHolder(lock); !stale {
+		t.Error("old foreign lock not stale")
+	}
+
+	// This process is alive.
+	os.WriteFile(lock, []byte(holderID()+"\n"), 0o644)
+	if _, stale := staleHolder(lock); stale {
+		t.Error("lock of a live process considered stale")
+	}
+}
+
+func TestEngine_KeepsShadowsForReaders(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": "package main\n\nfunc F(x int) {\n\t// @inco: x > 0\n}\n"})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	old := e.Overlay.Replace[filepath.Join(dir, "main.go")]
+
+	release, err := UseOverlay(e.CacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc F(x int) {\n\t// @inco: x > 1\n}\n"), 0o644)
+	e.Run()
+	if _, err := os.Stat(old); err != nil {
+		t.Errorf("shadow removed while the overlay is in use: %v", err)
+	}
+
+	release()
+	e.Run()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("unused shadow not swept once released: %v", err)
+	}
+	if shadow := readShadow(t, e); !strings.Contains(shadow, "x > 1") {
+		t.Errorf("current shadow swept:\n%s", shadow)
+	}
+}
+
+func TestLockCache_ConcurrentTakeover(t *testing.T) {
+	dir := t.TempDir()
+	host, _ := os.Hostname()
+	lock := filepath.Join(dir, "lock")
+	dead := []byte(host + " 2147483646\n") // a process on this host that no longer exists
+
+	// Two waiters find the lock abandoned; the first takes it over before
+	// the second removes it. The second must not remove the new lock.
+	os.WriteFile(lock, dead, 0o644)
+	if _, stale := staleHolder(lock); !stale {
+		t.Fatal("lock of a dead process not stale")
+	}
+	unlock := lockCache(dir)
+	removeStale(lock)
+	if data, err := os.ReadFile(lock); err != nil || strings.TrimSpace(string(data)) != holderID() {
+		t.Errorf("lock taken over by a live process removed: %q, %v", data, err)
+	}
+	unlock()
+
+	// The same for a reader registration, renewed by a process with the
+	// same name since it was found abandoned.
+	reader := filepath.Join(dir, "readers", host+"-1")
+	os.MkdirAll(filepath.Dir(reader), 0o755)
+	os.WriteFile(reader, dead, 0o644)
+	if _, stale := staleHolder(reader); !stale {
+		t.Fatal("registration of a dead process not stale")
+	}
+	os.WriteFile(reader, []byte(holderID()+"\n"), 0o644)
+	removeStale(reader)
+	if !activeReaders(dir) {
+		t.Error("registration of a live process removed")
+	}
+	os.RemoveAll(filepath.Dir(reader))
+
+	// Waiters racing for an abandoned lock hold it one at a time.
+	os.WriteFile(lock, dead, 0o644)
+	var holding, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := lockCache(dir)
+			if holding.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			time.Sleep(time.Millisecond)
+			holding.Add(-1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("the lock was held by two waiters at once %d time(s)", n)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
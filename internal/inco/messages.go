@@ -0,0 +1,192 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Panic message quality
+// ---------------------------------------------------------------------------
+
+// minDuplicateMessages is how many panic contracts must share a message
+// before the audit flags it: one message reused across many contracts
+// cannot tell their failures apart.
+const minDuplicateMessages = 3
+
+// Message quality rules.
+const (
+	RuleDefaultMessage = "default-message" // exported function relies on the generated message
+	RuleEmptyMessage   = "empty-message"   // -panic("")
+	RuleDuplicate      = "duplicate"       // message shared by minDuplicateMessages or more contracts
+	RuleNoContext      = "no-context"      // constant message for an expression over variables
+)
+
+// MessageIssue is a panic contract whose message makes its failures hard
+// to diagnose.
+type MessageIssue struct {
+	Path    string `json:"path"` // relative to root
+	Line    int    `json:"line"` // 1-based
+	Expr    string `json:"expr"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (m MessageIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s [%s]", m.Path, m.Line, m.Message, m.Rule)
+}
+
+// panicMessage is a panic contract as seen by the message audit.
+type panicMessage struct {
+	line     int
+	expr     string
+	literal  string // the message when it is a string literal
+	isLit    bool
+	exported bool // enclosed by an exported function or method
+	hasArg   bool // -panic(msg) rather than the generated message
+}
+
+// panicMessages returns the panic contracts of f, keyed by line as
+// returned by collectDirectives, in line order.
+func panicMessages(f *ast.File, fset *token.FileSet, directives map[int][]*Directive) []panicMessage {
+	var out []panicMessage
+	for _, line := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[line] {
+			_ = d // @inco: d.Kind.checksExpr() && d.Action == ActionPanic, -continue
+			if !(d.Kind.checksExpr() && d.Action == ActionPanic) {
+				continue
+			}
+			pm := panicMessage{line: line, expr: d.Expr, exported: exportedAt(f, fset, line), hasArg: len(d.ActionArgs) > 0}
+			if pm.hasArg {
+				if lit, ok := parseStringLit(d.ActionArgs[0]); ok {
+					pm.literal, pm.isLit = lit, true
+				}
+			}
+			out = append(out, pm)
+		}
+	}
+	return out
+}
+
+// messageIssues applies the per-contract rules to msgs, the panic
+// contracts of the file at relPath. Duplicates need every file and are
+// found by duplicateMessageIssues.
+func messageIssues(relPath string, msgs []panicMessage) []MessageIssue {
+	var issues []MessageIssue
+	for _, m := range msgs {
+		issue := func(rule, format string, args ...any) {
+			issues = append(issues, MessageIssue{relPath, m.line, m.expr, rule, fmt.Sprintf(format, args...)})
+		}
+		switch {
+		case !m.hasArg && m.exported:
+			issue(RuleDefaultMessage, "exported function relies on the generated message for %s; pass -panic(...) explaining the violation", m.expr)
+		case m.isLit && m.literal == "":
+			issue(RuleEmptyMessage, "empty panic message for %s", m.expr)
+		case m.isLit && mentionsVariables(m.expr):
+			issue(RuleNoContext, "constant message %q for %s omits the values involved; use fmt.Sprintf", m.literal, m.expr)
+		}
+	}
+	return issues
+}
+
+// duplicateMessageIssues flags literal messages shared by at least
+// minDuplicateMessages contracts across files (relative path → contracts).
+func duplicateMessageIssues(files map[string][]panicMessage) []MessageIssue {
+	type site struct {
+		path string
+		m    panicMessage
+	}
+	byText := make(map[string][]site)
+	for path, msgs := range files {
+		for _, m := range msgs {
+			if m.isLit && m.literal != "" {
+				byText[m.literal] = append(byText[m.literal], site{path, m})
+			}
+		}
+	}
+	var issues []MessageIssue
+	for text, sites := range byText {
+		_ = sites // @inco: len(sites) >= minDuplicateMessages, -continue
+		if !(len(sites) >= minDuplicateMessages) {
+			continue
+		}
+		for _, s := range sites {
+			issues = append(issues, MessageIssue{s.path, s.m.line, s.m.expr, RuleDuplicate,
+				fmt.Sprintf("message %q is shared by %d contracts", text, len(sites))})
+		}
+	}
+	sortMessageIssues(issues)
+	return issues
+}
+
+func sortMessageIssues(issues []MessageIssue) {
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Path != issues[j].Path {
+			return issues[i].Path < issues[j].Path
+		}
+		return issues[i].Line < issues[j].Line
+	})
+}
+
+// vetMessages reports the panic contracts in exported functions of f, the
+// file at path, that lack a message of their own: no -panic argument, so
+// the violation only echoes the expression, or an empty one. Callers of
+// an exported function see that message first.
+func vetMessages(f *ast.File, fset *token.FileSet, path string, lines []string) []Diagnostic {
+	standalone, inline := collectDirectives(f, fset, lines)
+	maps.Copy(standalone, inline)
+	var diags []Diagnostic
+	for _, m := range panicMessages(f, fset, standalone) {
+		_ = m // @inco: m.exported && (!m.hasArg || m.isLit && m.literal == ""), -continue
+		if !(m.exported && (!m.hasArg || m.isLit && m.literal == "")) {
+			continue
+		}
+		diags = append(diags, Diagnostic{Path: path, Line: m.line, Column: strings.Index(lines[m.line-1], "@") + 1, Code: CodeMissingMessage,
+			Message: fmt.Sprintf("contract %s in an exported function has no message; add -panic(\"...\") saying what the caller did wrong", m.expr)})
+	}
+	return diags
+}
+
+// exportedAt reports whether line lies in the body of an exported function
+// or method declaration.
+func exportedAt(f *ast.File, fset *token.FileSet, line int) bool {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Body != nil &&
+			srcLine(fset, fn.Body.Lbrace) <= line && line <= srcLine(fset, fn.Body.Rbrace) {
+			return fn.Name.IsExported()
+		}
+	}
+	return false
+}
+
+// parseStringLit returns the value of s if it is a Go string literal.
+func parseStringLit(s string) (string, bool) {
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return("", false)
+	if !(err == nil) {
+		return "", false
+	}
+	lit, ok := x.(*ast.BasicLit)
+	_ = ok // @inco: ok && lit.Kind == token.STRING, -return("", false)
+	if !(ok && lit.Kind == token.STRING) {
+		return "", false
+	}
+	v, err := strconv.Unquote(lit.Value)
+	return v, err == nil
+}
+
+// mentionsVariables reports whether expr refers to anything but
+// predeclared identifiers, whose values a message could report.
+func mentionsVariables(expr string) bool {
+	return slices.ContainsFunc(exprIdents(expr), func(name string) bool {
+		return types.Universe.Lookup(name) == nil
+	})
+}
@@ -0,0 +1,87 @@
+package inco
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Panic message quality
+// ---------------------------------------------------------------------------
+
+func TestAudit_MessageIssues(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"a.go": `package main
+
+import "fmt"
+
+func Exported(x int) {
+	// @inco: x > 0
+	// @inco: x < 100, -panic("")
+	// @inco: x != 7, -panic(fmt.Sprintf("x = %d is unlucky", x))
+	// @inco: x != 13, -return
+}
+
+func unexported(x int) {
+	// @inco: x > 0
+	// @inco: x != 1, -panic("bad input")
+	// @inco: true, -panic("bad input")
+}
+`,
+		"b.go": `package main
+
+func Other(y int) {
+	// @inco: y > 0, -panic("bad input")
+}
+`,
+	})
+	result := Audit(dir, Limits{})
+	if result.TotalPanicContracts != 7 {
+		t.Errorf("TotalPanicContracts = %d, want 7", result.TotalPanicContracts)
+	}
+
+	var got []string
+	for _, f := range result.Files {
+		for _, issue := range f.MessageIssues {
+			got = append(got, strings.Join([]string{f.RelPath, issue.Expr, issue.Rule}, " "))
+		}
+	}
+	want := []string{
+		"a.go x > 0 default-message",
+		"a.go x < 100 empty-message",
+		"a.go x != 1 no-context",
+		"a.go x != 1 duplicate",
+		"a.go true duplicate",
+		"b.go y > 0 no-context",
+		"b.go y > 0 duplicate",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Clear: x != 7 (formatted) and the unexported default message.
+	if result.ClearMessages != 2 || result.TotalMessageIssues != 7 {
+		t.Errorf("ClearMessages = %d, TotalMessageIssues = %d; want 2, 7", result.ClearMessages, result.TotalMessageIssues)
+	}
+	var b strings.Builder
+	result.PrintReport(&b)
+	if !strings.Contains(b.String(), "Diagnosability:   28.6%") {
+		t.Errorf("report missing diagnosability score:\n%s", b.String())
+	}
+}
+
+func TestMentionsVariables(t *testing.T) {
+	tests := map[string]bool{
+		"x > 0":          true,
+		"len(xs) > 0":    true,
+		"true":           false,
+		"len(nil) == 0":  false,
+		"p.Name != \"\"": true,
+	}
+	for expr, want := range tests {
+		if got := mentionsVariables(expr); got != want {
+			t.Errorf("mentionsVariables(%q) = %v, want %v", expr, got, want)
+		}
+	}
+}
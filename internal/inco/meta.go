@@ -0,0 +1,112 @@
+package inco
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// ---------------------------------------------------------------------------
+// Shadow metadata
+// ---------------------------------------------------------------------------
+
+// ShadowMeta describes what generation injected into one shadow file.
+type ShadowMeta struct {
+	Shadow     string             `json:"shadow"`             // shadow file name in the cache directory
+	Directives []InjectedContract `json:"directives"`         // in source order
+	Imports    []string           `json:"imports,omitempty"`  // import paths added for the contracts
+	BytesDelta int                `json:"bytes_delta"`        // shadow size minus source size
+	Warnings   []Warning          `json:"warnings,omitempty"` // reported again when the shadow is reused
+}
+
+// InjectedContract is a directive whose check a shadow contains.
+type InjectedContract struct {
+	Kind string `json:"kind"` // as in annotations.json
+	Line int    `json:"line"` // 1-based line of the directive
+}
+
+// MetaTotals sums the ShadowMeta of every shadow.
+type MetaTotals struct {
+	Files      int `json:"files"`
+	Directives int `json:"directives"`
+	Imports    int `json:"imports"`
+	BytesDelta int `json:"bytes_delta"`
+}
+
+// Meta is the content of meta.json.
+type Meta struct {
+	Root   string                `json:"root"`                // absolute project root
+	Env    *BuildEnv             `json:"build_env,omitempty"` // what the shadows were generated for; nil when go could not tell
+	Totals MetaTotals            `json:"totals"`              // over Files
+	Files  map[string]ShadowMeta `json:"files"`               // slash-separated path relative to Root → metadata
+}
+
+// shadowMeta describes the directives injected into a shadow and the
+// imports added for them; the caller sets Shadow.
+func shadowMeta(directives map[int][]*Directive, imports []importSpec, src, shadow []byte) ShadowMeta {
+	m := ShadowMeta{Directives: []InjectedContract{}, BytesDelta: len(shadow) - len(src)}
+	for _, line := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[line] {
+			m.Directives = append(m.Directives, InjectedContract{Kind: d.Kind.String(), Line: line})
+		}
+	}
+	for _, imp := range imports {
+		m.Imports = append(m.Imports, imp.Path)
+	}
+	sort.Strings(m.Imports)
+	return m
+}
+
+// ---------------------------------------------------------------------------
+// meta.json I/O
+// ---------------------------------------------------------------------------
+
+func (e *Engine) metaPath() string {
+	return filepath.Join(e.CacheDir, "meta.json")
+}
+
+// loadMeta returns the shadow metadata of the previous run, keyed by
+// relative path, and the build environment it ran under, or nil when
+// there is none.
+func (e *Engine) loadMeta() (map[string]ShadowMeta, *BuildEnv) {
+	data, err := os.ReadFile(e.metaPath())
+	_ = err // @inco: err == nil, -return(nil, nil)
+	if !(err == nil) {
+		return nil, nil
+	}
+	var m Meta
+	if json.Unmarshal(data, &m) != nil || m.Files == nil {
+		return nil, nil
+	}
+	return m.Files, m.Env
+}
+
+// writeMeta writes meta.json for files, keyed by relative path, generated
+// under env.
+func (e *Engine) writeMeta(files map[string]ShadowMeta, env *BuildEnv) {
+	m := Meta{Root: e.Root, Env: env, Files: files}
+	for _, f := range files {
+		m.Totals.Files++
+		m.Totals.Directives += len(f.Directives)
+		m.Totals.Imports += len(f.Imports)
+		m.Totals.BytesDelta += f.BytesDelta
+	}
+	err := os.MkdirAll(e.CacheDir, 0o755)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	err = writeFileAtomic(e.metaPath(), data, 0o644)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
@@ -0,0 +1,84 @@
+package inco
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// meta.json
+// ---------------------------------------------------------------------------
+
+// readMeta returns the meta.json written by e.
+func readMeta(t *testing.T, e *Engine) Meta {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(e.CacheDir, "meta.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestEngine_Meta(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+import "os"
+
+func Open(path string) (*os.File, error) {
+	// @inco: path != "", -panic(fmt.Sprintf("empty path %q", path))
+	f, err := os.Open(path) // @inco: err == nil, -return(nil, err); @ensure -closed f
+	return f, f.Close()
+}
+`,
+		"sub/sub.go": `package sub
+
+func Do(xs []int) {
+	for _, x := range xs {
+		// @invariant x >= 0
+	}
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	m := readMeta(t, e)
+
+	main := m.Files["main.go"]
+	wantDirectives := []InjectedContract{{"require", 6}, {"require", 7}, {"ensure-closed", 7}}
+	if !reflect.DeepEqual(main.Directives, wantDirectives) {
+		t.Errorf("main.go directives = %+v, want %+v", main.Directives, wantDirectives)
+	}
+	if !reflect.DeepEqual(main.Imports, []string{"fmt"}) {
+		t.Errorf("main.go imports = %v, want [fmt]", main.Imports)
+	}
+	src, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	shadow, err := os.ReadFile(filepath.Join(e.CacheDir, main.Shadow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.BytesDelta != len(shadow)-len(src) || main.BytesDelta <= 0 {
+		t.Errorf("main.go bytes_delta = %d, want %d", main.BytesDelta, len(shadow)-len(src))
+	}
+	if got := m.Files["sub/sub.go"].Directives; !reflect.DeepEqual(got, []InjectedContract{{"invariant", 5}}) {
+		t.Errorf("sub/sub.go directives = %+v", got)
+	}
+	wantTotals := MetaTotals{Files: 2, Directives: 4, Imports: 1, BytesDelta: main.BytesDelta + m.Files["sub/sub.go"].BytesDelta}
+	if m.Root != dir || m.Totals != wantTotals {
+		t.Errorf("root, totals = %q, %+v; want %q, %+v", m.Root, m.Totals, dir, wantTotals)
+	}
+
+	// A second run reuses every shadow and keeps their metadata.
+	e.Run()
+	if again := readMeta(t, e); !reflect.DeepEqual(again, m) {
+		t.Errorf("after a cached run:\n got %+v\nwant %+v", again, m)
+	}
+}
@@ -0,0 +1,96 @@
+package inco
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/mod/modfile"
+)
+
+// ---------------------------------------------------------------------------
+// Modules of an audit
+// ---------------------------------------------------------------------------
+
+// ModuleAudit holds the coverage figures of one module of a workspace.
+type ModuleAudit struct {
+	Path         string // module path, from its go.mod
+	Dir          string // module directory relative to root, "." for the root
+	Files        int
+	Funcs        int
+	GuardedFuncs int
+}
+
+// modulePath returns the module path declared by the go.mod governing
+// the absolute directory dir (see ModuleRoot), or "" when there is none.
+func modulePath(dir string) string {
+	data, err := os.ReadFile(filepath.Join(ModuleRoot(dir), "go.mod"))
+	_ = err // @inco: err == nil, -return("")
+	if !(err == nil) {
+		return ""
+	}
+	return modfile.ModulePath(data)
+}
+
+// workspaceModules returns the modules the go.work file of root uses, in
+// its order, with the figures of files: none when root has no go.work.
+// A file counts for the innermost module holding it.
+func workspaceModules(root string, files []FileAudit) []ModuleAudit {
+	data, err := os.ReadFile(filepath.Join(root, "go.work"))
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	work, err := modfile.ParseWork("go.work", data, nil)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	var mods []ModuleAudit
+	for _, use := range work.Use {
+		dir := filepath.ToSlash(filepath.Clean(use.Path))
+		mods = append(mods, ModuleAudit{Path: modulePath(filepath.Join(root, dir)), Dir: dir})
+	}
+	for _, f := range files {
+		best, bestLen := -1, -1
+		for i, m := range mods {
+			if m.Dir == "." {
+				if bestLen < 0 {
+					best, bestLen = i, 0
+				}
+			} else if strings.HasPrefix(filepath.ToSlash(f.RelPath), m.Dir+"/") && len(m.Dir) > bestLen {
+				best, bestLen = i, len(m.Dir)
+			}
+		}
+		_ = best // @inco: best >= 0, -continue
+		if !(best >= 0) {
+			continue
+		}
+		mods[best].Files++
+		for _, fn := range f.Funcs {
+			mods[best].Funcs++
+			if fn.RequireCount > 0 {
+				mods[best].GuardedFuncs++
+			}
+		}
+	}
+	return mods
+}
+
+// printModules writes one row of coverage figures per workspace module.
+func printModules(w io.Writer, mods []ModuleAudit, c Colorizer) {
+	fmt.Fprintf(w, "%s\n", c.Bold("Workspace modules:"))
+	pathW, dirW := len("Module"), len("Dir")
+	for _, m := range mods {
+		pathW, dirW = max(pathW, utf8.RuneCountInString(m.Path)), max(dirW, utf8.RuneCountInString(m.Dir))
+	}
+	fmt.Fprintf(w, "  %-*s  %-*s  files  funcs  guarded\n", pathW, "Module", dirW, "Dir")
+	fmt.Fprintf(w, "  %s  %s  ─────  ─────  ───────\n", strings.Repeat("─", pathW), strings.Repeat("─", dirW))
+	for _, m := range mods {
+		fmt.Fprintf(w, "  %-*s  %-*s  %5d  %5d  %7d\n", pathW, m.Path, dirW, m.Dir, m.Files, m.Funcs, m.GuardedFuncs)
+	}
+	fmt.Fprintln(w)
+}
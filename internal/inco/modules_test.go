@@ -0,0 +1,79 @@
+package inco
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit_Module(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/shop\n\ngo 1.21\n")
+	writeFile(t, filepath.Join(dir, "cart", "cart.go"), "package cart\n\nfunc Add() {}\n")
+
+	if r := Audit(dir, Limits{}); r.Module != "example.com/shop" || r.Modules != nil {
+		t.Errorf("Module, Modules = %q, %v, want example.com/shop and no workspace", r.Module, r.Modules)
+	}
+	// A subdirectory belongs to the module above it.
+	r := Audit(filepath.Join(dir, "cart"), Limits{})
+	if r.Module != "example.com/shop" {
+		t.Errorf("Module of a subdirectory = %q, want example.com/shop", r.Module)
+	}
+
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	if !strings.Contains(buf.String(), "  Module:         example.com/shop\n") {
+		t.Errorf("report missing module:\n%s", buf.String())
+	}
+	buf.Reset()
+	if err := (SARIFRenderer{}).Render(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Runs []struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil || len(log.Runs) != 1 || log.Runs[0].Properties["module"] != "example.com/shop" {
+		t.Errorf("SARIF run properties = %+v (%v), want the module", log.Runs, err)
+	}
+
+	if r := Audit(t.TempDir(), Limits{}); r.Module != "" {
+		t.Errorf("Module outside a module = %q", r.Module)
+	}
+}
+
+func TestAudit_WorkspaceModules(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.work"), "go 1.21\n\nuse (\n\t./api\n\t./api/client\n\t./worker\n)\n")
+	writeFile(t, filepath.Join(dir, "api", "go.mod"), "module example.com/api\n\ngo 1.21\n")
+	writeFile(t, filepath.Join(dir, "api", "api.go"), "package api\n\nfunc Serve(n int) {\n\t// @inco: n > 0\n}\n\nfunc Stop() {}\n")
+	writeFile(t, filepath.Join(dir, "api", "client", "go.mod"), "module example.com/api/client\n\ngo 1.21\n")
+	writeFile(t, filepath.Join(dir, "api", "client", "client.go"), "package client\n\nfunc Get() {}\n")
+	writeFile(t, filepath.Join(dir, "worker", "go.mod"), "module example.com/worker\n\ngo 1.21\n")
+	writeFile(t, filepath.Join(dir, "worker", "worker.go"), "package worker\n\nfunc Run(n int) {\n\t// @inco: n > 0\n}\n")
+
+	r := Audit(dir, Limits{})
+
+	want := []ModuleAudit{
+		{Path: "example.com/api", Dir: "api", Files: 1, Funcs: 2, GuardedFuncs: 1},
+		{Path: "example.com/api/client", Dir: "api/client", Files: 1, Funcs: 1},
+		{Path: "example.com/worker", Dir: "worker", Files: 1, Funcs: 1, GuardedFuncs: 1},
+	}
+	if len(r.Modules) != len(want) {
+		t.Fatalf("Modules = %+v, want %+v", r.Modules, want)
+	}
+	for i := range want {
+		if r.Modules[i] != want[i] {
+			t.Errorf("Modules[%d] = %+v, want %+v", i, r.Modules[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	if !strings.Contains(buf.String(), "  example.com/api/client  api/client      1      1        0\n") {
+		t.Errorf("report missing module rows:\n%s", buf.String())
+	}
+}
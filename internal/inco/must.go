@@ -0,0 +1,235 @@
+package inco
+
+import (
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Channel deadlines: @must(timeout)
+// ---------------------------------------------------------------------------
+
+// mustTimeName is the name shadows import package time as for @must, so
+// that it cannot clash with the file's own imports.
+const mustTimeName = "inco_time"
+
+// mustZeroName names the function declaring the variables of a receive
+// that @must moves into a select: _inco_must_zero(ch) returns the zero
+// values of ch's element type and of ok. Run declares it once per package,
+// in a file it adds to the overlay (see mustHelpers).
+const mustZeroName = "_inco_must_zero"
+
+// mustZeroFile is the name of the file declaring mustZeroName in a
+// package, and mustZeroTestFile its name in an external test package.
+const (
+	mustZeroFile     = "inco_must_zero.go"
+	mustZeroTestFile = "inco_must_zero_test.go"
+)
+
+// mustZeroDecl returns the declaration of the function name, called as
+// mustZeroName is.
+func mustZeroDecl(name string) string {
+	return "func " + name + "[T any](<-chan T) (v T, ok bool) { return }"
+}
+
+// mustTimeout returns the timeout of the @must directive d.
+func mustTimeout(d *Directive) (time.Duration, error) {
+	timeout, err := time.ParseDuration(d.Expr)
+	_ = err // @inco: err == nil, -return(0, fmt.Errorf("@must(%s): timeout is not a duration such as 1s or 250ms", d.Expr))
+	if !(err == nil) {
+		return 0, fmt.Errorf("@must(%s): timeout is not a duration such as 1s or 250ms", d.Expr)
+	}
+	_ = timeout // @inco: timeout > 0, -return(0, fmt.Errorf("@must(%s): timeout must be positive", d.Expr))
+	if !(timeout > 0) {
+		return 0, fmt.Errorf("@must(%s): timeout must be positive", d.Expr)
+	}
+	return timeout, nil
+}
+
+// mustStmt returns the channel operation a @must directive ending line
+// applies to: a send, a receive, or an assignment or definition from a
+// receive, alone on the line. Otherwise it returns why there is none.
+func mustStmt(f *ast.File, fset *token.FileSet, lines []string, line int, d *Directive) (ast.Stmt, error) {
+	comms := make(map[ast.Stmt]bool) // select cases, which are no statements of their own
+	var stmt ast.Stmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		if cc, ok := n.(*ast.CommClause); ok && cc.Comm != nil {
+			comms[cc.Comm] = true
+		}
+		s, ok := n.(ast.Stmt)
+		if !ok || comms[s] || srcLine(fset, s.Pos()) != line || !isChanOp(s) {
+			return true
+		}
+		stmt = s
+		return false
+	})
+	_ = stmt // @inco: stmt != nil, -return(nil, fmt.Errorf("@must(%s) must end a line holding a channel send or receive", d.Expr))
+	if !(stmt != nil) {
+		return nil, fmt.Errorf("@must(%s) must end a line holding a channel send or receive", d.Expr)
+	}
+	start, end := fset.Position(stmt.Pos()), fset.Position(stmt.End())
+	text := lines[line-1]
+	rest := strings.TrimSpace(text[min(end.Column-1, len(text)):])
+	alone := end.Line == line && strings.TrimSpace(text[:start.Column-1]) == "" && (strings.HasPrefix(rest, "//") || strings.HasPrefix(rest, "/*"))
+	_ = alone // @inco: alone, -return(nil, fmt.Errorf("@must(%s): the channel operation must be alone on its line", d.Expr))
+	if !(alone) {
+		return nil, fmt.Errorf("@must(%s): the channel operation must be alone on its line", d.Expr)
+	}
+	// The definition is split in two (see generateMust), which evaluates
+	// the channel twice.
+	if s, ok := stmt.(*ast.AssignStmt); ok && s.Tok == token.DEFINE {
+		ch := ast.Unparen(s.Rhs[0]).(*ast.UnaryExpr).X
+		_ = ch // @inco: isIdent(ch) || isSelector(ch), -return(nil, fmt.Errorf("@must(%s): receive from a variable or field, not %s, when defining variables", d.Expr, types.ExprString(ch)))
+		if !(isIdent(ch) || isSelector(ch)) {
+			return nil, fmt.Errorf("@must(%s): receive from a variable or field, not %s, when defining variables", d.Expr, types.ExprString(ch))
+		}
+	}
+	return stmt, nil
+}
+
+// isSelector reports whether x is a selector expression such as s.ch.
+func isSelector(x ast.Expr) bool {
+	_, ok := x.(*ast.SelectorExpr)
+	return ok
+}
+
+// isChanOp reports whether s is a channel send, a receive, or an
+// assignment or definition from a single receive.
+func isChanOp(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.SendStmt:
+		return true
+	case *ast.ExprStmt:
+		return isRecv(s.X)
+	case *ast.AssignStmt:
+		return (s.Tok == token.ASSIGN || s.Tok == token.DEFINE) && len(s.Rhs) == 1 && isRecv(s.Rhs[0])
+	}
+	return false
+}
+
+// isRecv reports whether x is a receive <-ch.
+func isRecv(x ast.Expr) bool {
+	u, ok := ast.Unparen(x).(*ast.UnaryExpr)
+	return ok && u.Op == token.ARROW
+}
+
+// generateMust returns the select replacing the channel operation stmt of
+// src, the line a @must directive d ends, and whether it calls
+// mustZeroName. The select fails the operation once the timeout passes:
+//
+//	select {
+//	case v, ok = <-ch:
+//	case <-inco_time.After(1000000000 /* 1s */):
+//	    panic("inco violation: v, ok := <-ch did not complete within 1s (at main.go:12)")
+//	}
+//
+// A definition v, ok := <-ch first declares v and ok with
+// _inco_must_zero(ch), since variables defined in a select case are local
+// to it. Under LogViolations the violation is logged and the operation
+// then waits on as it would without the directive.
+func (e *Engine) generateMust(d *Directive, stmt ast.Stmt, src []byte, fset *token.FileSet, indent, path string, line int) (code string, zero bool) {
+	text := func(n ast.Node) string {
+		return string(src[fset.Position(n.Pos()).Offset:fset.Position(n.End()).Offset])
+	}
+	timeout, _ := mustTimeout(d)
+	comm, pre := text(stmt), ""
+	if s, ok := stmt.(*ast.AssignStmt); ok {
+		lhs := string(src[fset.Position(s.Lhs[0].Pos()).Offset:fset.Position(s.Lhs[len(s.Lhs)-1].End()).Offset])
+		comm = lhs + " = " + text(s.Rhs[0])
+		if s.Tok == token.DEFINE {
+			vars := lhs
+			if len(s.Lhs) == 1 {
+				vars += ", _"
+			}
+			pre = fmt.Sprintf("%s%s := %s(%s)\n", indent, vars, cmp.Or(e.mustZero, mustZeroName), text(ast.Unparen(s.Rhs[0]).(*ast.UnaryExpr).X))
+			zero = true
+		}
+	}
+	msg := fmt.Sprintf("inco violation: %s did not complete within %s (at %s:%d)", text(stmt), d.Expr, e.relPath(path), line)
+	report := fmt.Sprintf("panic(%q)", msg)
+	if e.LogViolations {
+		report = fmt.Sprintf("%s\n%s\t%s", e.logViolation(d, path, line, "nil"), indent, comm)
+	}
+	at := e.lineDirective(path, line)
+	code = fmt.Sprintf("%s%sselect {\n%s%scase %s:\n%scase <-%s.After(%d /* %s */):\n%s%s\t%s\n%s}",
+		pre, indent, at, indent, comm, indent, mustTimeName, timeout.Nanoseconds(), d.Expr, at, indent, report, indent)
+	return code, zero
+}
+
+// mustHelpers returns the files declaring mustZeroName for the packages
+// of results whose shadows generate a @must directive: one per package, in
+// its directory, named mustZeroFile or, for an external test package,
+// mustZeroTestFile. A shadow cannot declare the function itself, as two
+// shadows of a package would then declare it twice.
+func (e *Engine) mustHelpers(results []fileResult) []fileResult {
+	pkgs := make(map[string]string) // helper path → package name
+	for _, r := range results {
+		must := r.Err == nil && slices.ContainsFunc(r.Meta.Directives, func(c InjectedContract) bool { return c.Kind == KindMust.String() })
+		_ = must // @inco: must, -continue
+		if !(must) {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), r.Path, nil, parser.PackageClauseOnly)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		name := mustZeroFile
+		if strings.HasSuffix(f.Name.Name, "_test") {
+			name = mustZeroTestFile
+		}
+		pkgs[filepath.Join(filepath.Dir(r.Path), name)] = f.Name.Name
+	}
+	var helpers []fileResult
+	for _, path := range slices.Sorted(maps.Keys(pkgs)) {
+		_, err := os.Stat(path)
+		_ = err // @inco: os.IsNotExist(err), -panic(fmt.Errorf("%s: the overlay adds a file of this name to declare the @must helper; rename the file", e.relPath(path)))
+		if !(os.IsNotExist(err)) {
+			panic(fmt.Errorf("%s: the overlay adds a file of this name to declare the @must helper; rename the file", e.relPath(path)))
+		}
+		src := fmt.Sprintf("// Code generated by inco. DO NOT EDIT.\n\npackage %s\n\n%s\n", pkgs[path], mustZeroDecl(mustZeroName))
+		helpers = append(helpers, fileResult{Path: path, ShadowData: []byte(src), Helper: true})
+	}
+	return helpers
+}
+
+// vetMusts returns a diagnostic for each @must directive in a function body
+// that generateShadow would reject: a timeout that is not a positive
+// duration, or a line without a channel operation alone on it.
+func vetMusts(f *ast.File, fset *token.FileSet, path string, lines []string) []Diagnostic {
+	standalone, inline := collectDirectives(f, fset, lines)
+	var diags []Diagnostic
+	for _, ds := range []map[int][]*Directive{standalone, inline} {
+		for _, line := range slices.Sorted(maps.Keys(ds)) {
+			_, inBody := enclosingBodyEnd(f, fset, line)
+			_ = inBody // @inco: inBody, -continue
+			if !(inBody) {
+				continue
+			}
+			for _, d := range ds[line] {
+				if d.Kind != KindMust {
+					continue
+				}
+				_, err := mustTimeout(d)
+				if err == nil {
+					_, err = mustStmt(f, fset, lines, line, d)
+				}
+				if err != nil {
+					col := strings.Index(lines[line-1], "@must(") + 1
+					diags = append(diags, Diagnostic{Path: path, Line: line, Column: max(col, 1), Code: CodeMust, Message: err.Error()})
+				}
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,183 @@
+package inco
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Channel deadlines
+// ---------------------------------------------------------------------------
+
+func TestParseDirective_Must(t *testing.T) {
+	d := ParseDirective("// @must(250ms)")
+	if d == nil || d.Kind != KindMust || d.Expr != "250ms" {
+		t.Fatalf("got %+v", d)
+	}
+	ds := ParseDirectives("// @must(1s); @inco: ok")
+	if len(ds) != 2 || ds[0].Kind != KindMust || ds[1].Kind != KindRequire || ds[1].Expr != "ok" {
+		t.Errorf("combined: got %+v", ds)
+	}
+	for _, comment := range []string{"// @must()", "// @must 1s"} {
+		if d := ParseDirective(comment); d != nil {
+			t.Errorf("%s: expected nil, got %+v", comment, d)
+		}
+	}
+}
+
+const mustSrc = `package main
+
+type server struct{ done chan struct{} }
+
+func Run(jobs chan int, s server) int {
+	jobs <- 1 // @must(1s)
+	v := <-jobs // @must(250ms)
+	n, ok := <-jobs // @must(1s)
+	v, ok = <-jobs // @must(1s); @inco: ok
+	<-s.done // @must(2s)
+	return v + n
+}
+`
+
+func TestEngine_Must(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": mustSrc})
+	e := NewEngine(dir)
+	e.Must = true
+	e.Run()
+	data, err := os.ReadFile(e.Overlay.Replace[filepath.Join(dir, "main.go")])
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow := string(data)
+
+	for _, want := range []string{
+		`import inco_time "time"`,
+		"\tselect {\n//line " + dir + "/main.go:6\n\tcase jobs <- 1:\n\tcase <-inco_time.After(1000000000 /* 1s */):\n",
+		`panic("inco violation: jobs <- 1 did not complete within 1s (at main.go:6)")`,
+		"\tv, _ := _inco_must_zero(jobs)\n\tselect {\n",
+		"\tcase v = <-jobs:\n\tcase <-inco_time.After(250000000 /* 250ms */):\n",
+		"\tn, ok := _inco_must_zero(jobs)\n",
+		"\tcase n, ok = <-jobs:\n",
+		"\tcase v, ok = <-jobs:\n",
+		`panic("inco violation: ok (at main.go:9)")`,
+		"\tcase <-s.done:\n",
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %q:\n%s", want, shadow)
+		}
+	}
+}
+
+func TestEngine_MustPackage(t *testing.T) {
+	recv := "package main\n\nfunc %s(ch chan int) int {\n\tv, ok := <-ch // @must(1s)\n\t_ = ok\n\treturn v\n}\n"
+	dir := setupDir(t, map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.21\n",
+		"a.go":           fmt.Sprintf(recv, "A"),
+		"b.go":           fmt.Sprintf(recv, "B"),
+		"main_test.go":   "package main_test\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) {\n\tch := make(chan int, 1)\n\tch <- m.Run()\n\tcode := <-ch // @must(1s)\n\t_ = code\n}\n",
+		"plain/plain.go": "package plain\n\nfunc F(x int) {\n\t// @inco: x > 0\n}\n",
+	})
+	e := NewEngine(dir)
+	e.Must = true
+	e.Tests = true
+	e.Typecheck = true
+	e.Quiet = true
+	e.Run() // panics if the shadows of a.go and b.go both declared the helper
+
+	var helpers []string
+	for src, sp := range e.Overlay.Replace {
+		data, err := os.ReadFile(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(data), "func "+mustZeroName); strings.HasPrefix(filepath.Base(src), "inco_must_zero") {
+			helpers = append(helpers, fmt.Sprintf("%s: %d", filepath.Base(src), n))
+		} else if n > 0 {
+			t.Errorf("shadow of %s declares %s itself", src, mustZeroName)
+		}
+	}
+	slices.Sort(helpers)
+	if want := []string{"inco_must_zero.go: 1", "inco_must_zero_test.go: 1"}; !slices.Equal(helpers, want) {
+		t.Errorf("helpers = %q, want %q", helpers, want)
+	}
+	if data, _ := os.ReadFile(e.Overlay.Replace[filepath.Join(dir, mustZeroTestFile)]); !strings.Contains(string(data), "package main_test\n") {
+		t.Errorf("external test package helper:\n%s", data)
+	}
+	if _, ok := readMeta(t, e).Files[mustZeroFile]; ok {
+		t.Errorf("meta.json describes the helper as a source")
+	}
+
+	// A file instrumented on its own carries a helper of its own.
+	for _, name := range []string{"a.go", "b.go"} {
+		r, err := e.Instrument(name, []byte(fmt.Sprintf(recv, "A")))
+		if err != nil || strings.Count(string(r.Content), "func "+mustZeroName+"_") != 1 {
+			t.Errorf("Instrument(%s) = %v:\n%s", name, err, r.Content)
+		}
+	}
+}
+
+func TestEngine_MustOutsideTests(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": mustSrc})
+	e := NewEngine(dir)
+	e.Run()
+	shadow := readShadow(t, e)
+	if strings.Contains(shadow, "select") || strings.Contains(shadow, "inco_time") || strings.Contains(shadow, "_inco_must_zero") {
+		t.Errorf("deadlines generated outside test builds:\n%s", shadow)
+	}
+	if !strings.Contains(shadow, "\tjobs <- 1 // @must(1s)\n") {
+		t.Errorf("channel operation not kept as written:\n%s", shadow)
+	}
+	if !strings.Contains(shadow, `panic("inco violation: ok (at main.go:9)")`) {
+		t.Errorf("other directives on the line dropped:\n%s", shadow)
+	}
+}
+
+func TestEngine_MustErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"n := 1 // @must(1s)", "main.go:4: @must(1s) must end a line holding a channel send or receive"},
+		{"ch <- 1 // @must(soon)", "main.go:4: @must(soon): timeout is not a duration such as 1s or 250ms"},
+		{"ch <- 1 // @must(0s)", "main.go:4: @must(0s): timeout must be positive"},
+		{"ch <- 1; ch <- 2 // @must(1s)", "main.go:4: @must(1s): the channel operation must be alone on its line"},
+		{"v := <-chans[0] // @must(1s)", "main.go:4: @must(1s): receive from a variable or field, not chans[0], when defining variables"},
+	}
+	for _, tt := range tests {
+		src := "package main\n\nfunc f(ch chan int, chans []chan int) {\n\t" + tt.line + "\n}\n"
+		for _, must := range []bool{true, false} {
+			e := NewEngine(setupDir(t, map[string]string{"main.go": src}))
+			e.Must = must
+			if msg := runExpectPanic(t, e); !strings.Contains(msg, tt.want) {
+				t.Errorf("%s (Must=%t): got %q, want %q", tt.line, must, msg, tt.want)
+			}
+		}
+	}
+}
+
+func TestVet_Must(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func f(ch chan int) {
+	ch <- 1 // @must(1s)
+	ch <- 2 // @must(forever)
+	n := 1 // @must(1s)
+	_ = n
+}
+`})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, strings.TrimPrefix(d.String(), dir+"/"))
+	}
+	want := []string{
+		"main.go:5:13: @must(forever): timeout is not a duration such as 1s or 250ms",
+		"main.go:6:12: @must(1s) must end a line holding a channel send or receive",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,218 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// Mutation types
+// ---------------------------------------------------------------------------
+
+// Mutation replaces the expression of the directive at Path:Line when an
+// Engine generates shadows.
+type Mutation struct {
+	Path  string // absolute source path
+	Line  int    // 1-based line of the directive
+	Index int    // position among the directives on Line, in source order
+	Expr  string // replacement expression
+}
+
+// Mutant is one mutation of a contract and its outcome.
+type Mutant struct {
+	Mutation
+	RelPath  string // Path relative to root
+	Operator string // negate, drop or boundary
+	Orig     string // original expression
+	Killed   bool   // the test suite failed with the mutation applied
+}
+
+// MutationResult is the aggregate report of a mutation run.
+type MutationResult struct {
+	Mutants []Mutant
+	Killed  int
+}
+
+// ---------------------------------------------------------------------------
+// Mutate entry point
+// ---------------------------------------------------------------------------
+
+// Mutate measures how well the tests exercise the contracts under root.
+//
+// Every @inco: expression is mutated in turn — negated, dropped (replaced
+// by true) and, for comparisons, shifted by one at the boundary — and
+// "go test" is run with the mutant's overlay. A mutant is killed when the
+// tests fail; surviving mutants are contracts no test depends on.
+//
+// testArgs are passed to go test (default ./...). Shadows are generated in
+// a temporary directory, so Root's cache is left untouched. Progress is
+// written to log.
+func Mutate(root string, testArgs []string, log io.Writer) *MutationResult {
+	_ = root // @inco: root != "", -panic("Mutate: root must not be empty")
+	if !(root != "") {
+		panic("Mutate: root must not be empty")
+	}
+	if len(testArgs) == 0 {
+		testArgs = []string{"./..."}
+	}
+	cacheDir, err := os.MkdirTemp("", "inco-mutate-")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	// run generates the overlay with m applied and reports whether the
+	// test suite passes.
+	run := func(m *Mutation) bool {
+		e := NewEngine(root)
+		e.CacheDir = cacheDir
+		e.Mutation = m
+		e.Must = true
+		e.Quiet = true
+		e.Run()
+		args := append([]string{"test", "-count=1", "-overlay=" + filepath.Join(cacheDir, "overlay.json")}, testArgs...)
+		cmd := exec.Command("go", args...)
+		cmd.Dir = root
+		return cmd.Run() == nil
+	}
+
+	passed := run(nil)
+	_ = passed // @inco: passed, -panic("mutate: the test suite fails without mutations")
+	if !(passed) {
+		panic("mutate: the test suite fails without mutations")
+	}
+
+	r := &MutationResult{Mutants: collectMutants(root, cacheDir)}
+	for i := range r.Mutants {
+		m := &r.Mutants[i]
+		m.Killed = !run(&m.Mutation)
+		status := "survived"
+		if m.Killed {
+			r.Killed++
+			status = "killed"
+		}
+		fmt.Fprintf(log, "inco: mutant %d/%d %s:%d %s: %s\n", i+1, len(r.Mutants), m.RelPath, m.Line, m.Operator, status)
+	}
+	return r
+}
+
+// collectMutants returns the mutants of every injectable @inco: directive
+// under root, ordered by file, line and operator.
+func collectMutants(root, cacheDir string) []Mutant {
+	e := &Engine{Root: root, CacheDir: cacheDir}
+	var mutants []Mutant
+	fset := token.NewFileSet()
+	for _, path := range slices.DeleteFunc(collectGoFiles(root, false), e.inCacheDir) {
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		joinContinuations(f, fset)
+		src, err := os.ReadFile(path)
+		_ = err // @inco: err == nil, -panic(err)
+		if !(err == nil) {
+			panic(err)
+		}
+		standalone, inline := collectDirectives(f, fset, splitLines(src))
+		directives := maps.Clone(standalone)
+		maps.Copy(directives, inline)
+		for _, line := range slices.Sorted(maps.Keys(directives)) {
+			for i, d := range directives[line] {
+				_ = d // @inco: d.Kind.checksExpr(), -continue
+				if !(d.Kind.checksExpr()) {
+					continue
+				}
+				for _, em := range mutateExpr(d.Expr) {
+					mutants = append(mutants, Mutant{
+						Mutation: Mutation{Path: path, Line: line, Index: i, Expr: em.Expr},
+						RelPath:  e.relPath(path),
+						Operator: em.Operator,
+						Orig:     d.Expr,
+					})
+				}
+			}
+		}
+	}
+	return mutants
+}
+
+// exprMutation is a mutated expression and the operator that produced it.
+type exprMutation struct {
+	Operator string
+	Expr     string
+}
+
+// boundaryOps maps each relational operator to its off-by-one neighbour.
+var boundaryOps = map[token.Token]token.Token{
+	token.LSS: token.LEQ,
+	token.LEQ: token.LSS,
+	token.GTR: token.GEQ,
+	token.GEQ: token.GTR,
+}
+
+// mutateExpr returns the mutations of a contract expression:
+//
+//	negate:   x > 0 → !(x > 0)
+//	drop:     x > 0 → true
+//	boundary: x > 0 → x >= 0 (top-level <, <=, >, >= only)
+func mutateExpr(expr string) []exprMutation {
+	muts := []exprMutation{
+		{"negate", "!(" + expr + ")"},
+		{"drop", "true"},
+	}
+	fset := token.NewFileSet()
+	x, err := parser.ParseExprFrom(fset, "", expr, 0)
+	_ = err // @inco: err == nil, -return(muts)
+	if !(err == nil) {
+		return muts
+	}
+	be, ok := x.(*ast.BinaryExpr)
+	_ = ok // @inco: ok, -return(muts)
+	if !(ok) {
+		return muts
+	}
+	if op, ok := boundaryOps[be.Op]; ok {
+		i := fset.Position(be.OpPos).Offset
+		muts = append(muts, exprMutation{"boundary", expr[:i] + op.String() + expr[i+len(be.Op.String()):]})
+	}
+	return muts
+}
+
+// ---------------------------------------------------------------------------
+// Report rendering
+// ---------------------------------------------------------------------------
+
+// PrintReport writes a human-readable mutation report to w.
+func (r *MutationResult) PrintReport(w io.Writer) {
+	fmt.Fprintf(w, "inco mutate — contract mutation report\n")
+	fmt.Fprintf(w, "======================================\n\n")
+
+	total := len(r.Mutants)
+	fmt.Fprintf(w, "  Mutants:   %d\n", total)
+	if total == 0 {
+		fmt.Fprintf(w, "  (no contracts found)\n")
+		return
+	}
+	fmt.Fprintf(w, "  Killed:    %d  (%.1f%%)\n", r.Killed, float64(r.Killed)/float64(total)*100)
+	fmt.Fprintf(w, "  Survived:  %d\n", total-r.Killed)
+
+	if r.Killed == total {
+		return
+	}
+	fmt.Fprintf(w, "\nSurviving mutants (no test fails when the contract changes):\n")
+	for _, m := range r.Mutants {
+		if !m.Killed {
+			fmt.Fprintf(w, "  %s:%d  %-8s  %s  →  %s\n", m.RelPath, m.Line, m.Operator, m.Orig, m.Expr)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package inco
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Expression mutations
+// ---------------------------------------------------------------------------
+
+func TestMutateExpr(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []exprMutation
+	}{
+		{"x > 0", []exprMutation{{"negate", "!(x > 0)"}, {"drop", "true"}, {"boundary", "x >= 0"}}},
+		{"len(s)<=n", []exprMutation{{"negate", "!(len(s)<=n)"}, {"drop", "true"}, {"boundary", "len(s)<n"}}},
+		{"p != nil", []exprMutation{{"negate", "!(p != nil)"}, {"drop", "true"}}},
+		{"ok", []exprMutation{{"negate", "!(ok)"}, {"drop", "true"}}},
+	}
+	for _, tt := range tests {
+		if got := mutateExpr(tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mutateExpr(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Mutate — end to end
+// ---------------------------------------------------------------------------
+
+func TestMutate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test once per mutant")
+	}
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"m.go": `package m
+
+func Half(x int) int {
+	// @inco: x >= 0
+	return x / 2
+}
+`,
+		"m_test.go": `package m
+
+import "testing"
+
+func TestHalf(t *testing.T) {
+	if Half(4) != 2 {
+		t.Fatal("Half(4)")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Half(-1) should panic")
+		}
+	}()
+	Half(-1)
+}
+`,
+	})
+	r := Mutate(dir, nil, io.Discard)
+	if len(r.Mutants) != 3 {
+		t.Fatalf("got %d mutants, want 3: %+v", len(r.Mutants), r.Mutants)
+	}
+	// Half(0) is never tested, so the boundary mutant x > 0 survives.
+	for _, m := range r.Mutants {
+		if want := m.Operator != "boundary"; m.Killed != want {
+			t.Errorf("%s mutant %q: killed = %v, want %v", m.Operator, m.Expr, m.Killed, want)
+		}
+	}
+	if r.Killed != 2 {
+		t.Errorf("Killed = %d, want 2", r.Killed)
+	}
+}
@@ -0,0 +1,111 @@
+package inco
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Progress line
+// ---------------------------------------------------------------------------
+
+// Progress redraws a status line on a terminal while a long run goes on:
+// the files done, the package being processed and the time elapsed,
+// e.g. "inco: gen 1200/5000 files  internal/api  12s". The line appears
+// only once the run has taken progressDelay, so short runs print nothing,
+// and is cleared by Stop. A nil *Progress does nothing.
+type Progress struct {
+	w       io.Writer
+	label   string
+	root    string
+	total   int // 0 when unknown
+	start   time.Time
+	done    atomic.Int64
+	current atomic.Value // string: directory of the last file, relative to root
+	drawn   bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Timing of the progress line.
+var (
+	progressDelay    = 500 * time.Millisecond
+	progressInterval = 100 * time.Millisecond
+)
+
+// StartProgress starts the status line of label ("gen", "audit") on w for
+// total files under root, or returns nil when w is nil.
+func StartProgress(w io.Writer, label, root string, total int) *Progress {
+	_ = w // @inco: w != nil, -return(nil)
+	if !(w != nil) {
+		return nil
+	}
+	p := &Progress{w: w, label: label, root: root, total: total, start: time.Now(), stop: make(chan struct{})}
+	p.current.Store("")
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+func (p *Progress) run() {
+	defer p.wg.Done()
+	delay := time.NewTimer(progressDelay)
+	defer delay.Stop()
+	select {
+	case <-delay.C:
+	case <-p.stop:
+		return
+	}
+	tick := time.NewTicker(progressInterval)
+	defer tick.Stop()
+	for {
+		p.draw()
+		select {
+		case <-tick.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// draw rewrites the status line in place.
+func (p *Progress) draw() {
+	files := fmt.Sprintf("%d files", p.done.Load())
+	if p.total > 0 {
+		files = fmt.Sprintf("%d/%d files", p.done.Load(), p.total)
+	}
+	fmt.Fprintf(p.w, "\r\x1b[Kinco: %s %s  %s  %s", p.label, files, p.current.Load(), time.Since(p.start).Round(time.Second))
+	p.drawn = true
+}
+
+// Step records that the file at path is done. It is safe for concurrent
+// use.
+func (p *Progress) Step(path string) {
+	_ = p // @inco: p != nil, -return
+	if !(p != nil) {
+		return
+	}
+	dir, err := filepath.Rel(p.root, filepath.Dir(path))
+	if err != nil {
+		dir = filepath.Dir(path)
+	}
+	p.current.Store(filepath.ToSlash(dir))
+	p.done.Add(1)
+}
+
+// Stop stops redrawing and clears the status line.
+func (p *Progress) Stop() {
+	_ = p // @inco: p != nil, -return
+	if !(p != nil) {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\x1b[K")
+	}
+}
@@ -0,0 +1,49 @@
+package inco
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Progress line
+// ---------------------------------------------------------------------------
+
+func TestProgress(t *testing.T) {
+	defer func(delay, interval time.Duration) {
+		progressDelay, progressInterval = delay, interval
+	}(progressDelay, progressInterval)
+	progressDelay, progressInterval = 0, time.Millisecond
+
+	var out strings.Builder
+	root := t.TempDir()
+	p := StartProgress(&out, "gen", root, 3)
+	p.Step(filepath.Join(root, "api", "user.go"))
+	p.Step(filepath.Join(root, "api", "order.go"))
+	time.Sleep(20 * time.Millisecond)
+	p.Stop()
+
+	got := out.String()
+	if !strings.Contains(got, "\r\x1b[Kinco: gen 2/3 files  api  0s") {
+		t.Errorf("progress output %q lacks the status line", got)
+	}
+	if !strings.HasSuffix(got, "\r\x1b[K") {
+		t.Errorf("progress output %q does not end by clearing the line", got)
+	}
+}
+
+func TestProgress_ShortRun(t *testing.T) {
+	var out strings.Builder
+	p := StartProgress(&out, "audit", t.TempDir(), 0)
+	p.Step("main.go")
+	p.Stop()
+	if out.Len() != 0 {
+		t.Errorf("a run shorter than the delay printed %q", out.String())
+	}
+
+	none := StartProgress(nil, "gen", "", 0)
+	none.Step("main.go")
+	none.Stop()
+}
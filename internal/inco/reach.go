@@ -0,0 +1,67 @@
+package inco
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/imnive-design/inco-go/incolog"
+)
+
+// ---------------------------------------------------------------------------
+// Runtime reachability: contracts no run evaluated
+// ---------------------------------------------------------------------------
+
+// ContractSite is a check (@inco: or @invariant) and where it is written.
+type ContractSite struct {
+	Path string // relative to root
+	Line int    // line of the directive
+	Expr string
+}
+
+func (s ContractSite) String() string {
+	return fmt.Sprintf("%s:%d  %s", s.Path, s.Line, s.Expr)
+}
+
+// contractSites returns the checks of a file in line order.
+func contractSites(relPath string, directives map[int][]*Directive) []ContractSite {
+	var out []ContractSite
+	for _, line := range slices.Sorted(maps.Keys(directives)) {
+		for _, d := range directives[line] {
+			if d.Kind.checksExpr() {
+				out = append(out, ContractSite{Path: relPath, Line: line, Expr: d.Expr})
+			}
+		}
+	}
+	return out
+}
+
+// reachability splits the checks of files by whether hits, the sites a
+// -count-hits run recorded (see incolog.Hit), include them, and returns
+// how many were evaluated and those that were not.
+func reachability(files []FileAudit, hits map[incolog.Site]bool) (evaluated int, unevaluated []ContractSite) {
+	for _, f := range files {
+		for _, c := range f.contracts {
+			if hits[incolog.Site{File: filepath.ToSlash(c.Path), Line: c.Line}] {
+				evaluated++
+			} else {
+				unevaluated = append(unevaluated, c)
+			}
+		}
+	}
+	return evaluated, unevaluated
+}
+
+// printReachability writes the runtime reachability section: how many
+// checks the recorded runs evaluated, then each one they never did.
+func printReachability(w io.Writer, evaluated int, unevaluated []ContractSite, c Colorizer) {
+	total := evaluated + len(unevaluated)
+	fmt.Fprintf(w, "\n%s\n", c.Bold("Runtime reachability:"))
+	pct := float64(evaluated) / float64(total) * 100
+	fmt.Fprintf(w, "  Evaluated:  %d / %d contracts  (%s)\n", evaluated, total, c.Percent(pct, 90, 60))
+	for _, s := range unevaluated {
+		fmt.Fprintf(w, "  %s  never evaluated\n", c.Warning(s.String()))
+	}
+}
@@ -0,0 +1,67 @@
+package inco
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/imnive-design/inco-go/incolog"
+)
+
+// ---------------------------------------------------------------------------
+// Runtime reachability
+// ---------------------------------------------------------------------------
+
+const reachSrc = `package main
+
+func Do(x int) {
+	// @inco: x > 0
+	if x > 100 {
+		// @inco: x < 1000, -return
+	}
+}
+`
+
+func TestEngine_CountHits(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": reachSrc})
+	e := NewEngine(dir)
+	e.CountHits = true
+	e.Run()
+	shadow := readShadow(t, e)
+	at := "//line " + filepath.Join(dir, "main.go")
+	for _, want := range []string{
+		`import inco_log "github.com/imnive-design/inco-go/incolog"`,
+		"\tinco_log.Hit(\"main.go\", 4)\n" + at + ":4\n\tif !(x > 0) {",
+		"\t\tinco_log.Hit(\"main.go\", 6)\n" + at + ":6\n\t\tif !(x < 1000) {",
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %q:\n%s", want, shadow)
+		}
+	}
+}
+
+func TestAudit_Runtime(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": reachSrc})
+	r := AuditWith(dir, AuditOptions{Runtime: map[incolog.Site]bool{{File: "main.go", Line: 4}: true}})
+	want := []ContractSite{{Path: "main.go", Line: 6, Expr: "x < 1000"}}
+	if !r.Runtime || r.EvaluatedContracts != 1 || len(r.Unevaluated) != 1 || r.Unevaluated[0] != want[0] {
+		t.Fatalf("Runtime = %t, evaluated %d, unevaluated %+v", r.Runtime, r.EvaluatedContracts, r.Unevaluated)
+	}
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	for _, want := range []string{
+		"Runtime reachability:\n  Evaluated:  1 / 2 contracts",
+		"  main.go:6  x < 1000  never evaluated\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	Audit(dir, Limits{}).PrintReport(&buf)
+	if strings.Contains(buf.String(), "Runtime reachability") {
+		t.Error("reachability reported without runtime data")
+	}
+}
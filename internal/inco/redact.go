@@ -0,0 +1,128 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Redaction of sensitive values
+// ---------------------------------------------------------------------------
+
+// redacted replaces sensitive values in generated violation output.
+const redacted = `"[redacted]"`
+
+// sensitive reports whether name matches one of e.Sensitive, glob
+// patterns compared case-insensitively: "password", "*token*".
+func (e *Engine) sensitive(name string) bool {
+	return slices.ContainsFunc(e.Sensitive, func(pattern string) bool {
+		ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+		return ok
+	})
+}
+
+// redact returns d with sensitive values in its panic argument replaced
+// by "[redacted]", or d itself when nothing is sensitive. Only the panic
+// argument is printed, or logged under LogViolations: the arguments of
+// other actions are values the function goes on to use, and are never
+// rewritten.
+func (e *Engine) redact(d *Directive) *Directive {
+	_ = e.Sensitive // @inco: len(e.Sensitive) > 0 && d.Action == ActionPanic && len(d.ActionArgs) > 0, -return(d)
+	if !(len(e.Sensitive) > 0 && d.Action == ActionPanic && len(d.ActionArgs) > 0) {
+		return d
+	}
+	arg := e.redactExpr(d.ActionArgs[0])
+	_ = arg // @inco: arg != d.ActionArgs[0], -return(d)
+	if !(arg != d.ActionArgs[0]) {
+		return d
+	}
+	clone := *d
+	clone.ActionArgs = []string{arg}
+	return &clone
+}
+
+// redactExpr replaces the sensitive identifiers and field selections of
+// the Go expression src. Expressions that do not parse are returned
+// unchanged.
+func (e *Engine) redactExpr(src string) string {
+	x, err := parser.ParseExpr(src)
+	_ = err // @inco: err == nil, -return(src)
+	if !(err == nil) {
+		return src
+	}
+	type span struct{ start, end int }
+	var spans []span
+	offset := func(p token.Pos) int { return int(p) - 1 } // ParseExpr positions start at 1
+
+	var visit func(n ast.Node)
+	visit = func(n ast.Node) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				// A method called on a sensitive value is redacted as a
+				// whole (token.String()); the function itself never is.
+				sel, isSel := n.Fun.(*ast.SelectorExpr)
+				switch {
+				case isSel && e.sensitiveOperand(sel.X):
+					spans = append(spans, span{offset(n.Pos()), offset(n.End())})
+					return false
+				case isSel:
+					visit(sel.X)
+				case !isIdent(n.Fun):
+					visit(n.Fun)
+				}
+				for _, a := range n.Args {
+					visit(a)
+				}
+				return false
+			case *ast.SelectorExpr:
+				if e.sensitive(n.Sel.Name) {
+					spans = append(spans, span{offset(n.Pos()), offset(n.End())})
+					return false
+				}
+				visit(n.X)
+				return false
+			case *ast.KeyValueExpr:
+				visit(n.Value)
+				return false
+			case *ast.Ident:
+				if e.sensitive(n.Name) {
+					spans = append(spans, span{offset(n.Pos()), offset(n.End())})
+				}
+			}
+			return true
+		})
+	}
+	visit(x)
+	slices.SortFunc(spans, func(a, b span) int { return a.start - b.start })
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		fmt.Fprintf(&b, "%s%s", src[last:s.start], redacted)
+		last = s.end
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
+
+// sensitiveOperand reports whether x is a sensitive variable or field.
+func (e *Engine) sensitiveOperand(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return e.sensitive(x.Name)
+	case *ast.SelectorExpr:
+		return e.sensitive(x.Sel.Name)
+	}
+	return false
+}
+
+func isIdent(x ast.Expr) bool {
+	_, ok := x.(*ast.Ident)
+	return ok
+}
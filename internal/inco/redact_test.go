@@ -0,0 +1,73 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Redaction of sensitive values
+// ---------------------------------------------------------------------------
+
+func TestRedactExpr(t *testing.T) {
+	e := &Engine{Sensitive: []string{"password", "*Token*"}}
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`password`, `"[redacted]"`},
+		{`fmt.Sprintf("login %s/%s", user, password)`, `fmt.Sprintf("login %s/%s", user, "[redacted]")`},
+		{`fmt.Errorf("bad token %q", req.AuthToken)`, `fmt.Errorf("bad token %q", "[redacted]")`},
+		{`fmt.Sprintf("%v", creds.Password)`, `fmt.Sprintf("%v", "[redacted]")`},
+		{`getPassword()`, `getPassword()`},
+		{`tokenStore.Describe(id)`, `"[redacted]"`},
+		{`fmt.Sprint(req.Token.String(), n)`, `fmt.Sprint("[redacted]", n)`},
+		{`strings.ToUpper(password)`, `strings.ToUpper("[redacted]")`},
+		{`Creds{Password: pw}`, `Creds{Password: pw}`},
+		{`fmt.Sprint(string(password))`, `fmt.Sprint(string("[redacted]"))`},
+		{`not valid (`, `not valid (`},
+	}
+	for _, tt := range tests {
+		if got := e.redactExpr(tt.src); got != tt.want {
+			t.Errorf("redactExpr(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_Redaction(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"main.go": `package main
+
+import "fmt"
+
+func Login(user, password string) error {
+	// @inco: len(password) >= 8, -panic(fmt.Sprintf("weak password %q for %s", password, user))
+	// @inco: user != "", -return(fmt.Errorf("no user for password %q", password))
+	return nil
+}
+
+func Verify(token []byte) ([]byte, error) {
+	// @inco: len(token) > 0, -return(wrap(token), fmt.Errorf("empty token %q", token))
+	return token, nil
+}
+
+func wrap(b []byte) []byte { return b }
+`,
+	})
+	e := NewEngine(dir)
+	e.Sensitive = []string{"password", "token"}
+	e.Typecheck = true
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{
+		`panic(fmt.Sprintf("weak password %q for %s", "[redacted]", user))`,
+		`return fmt.Errorf("no user for password %q", password)`, // returned, not printed
+		`return wrap(token), fmt.Errorf("empty token %q", token)`,
+		`if !(len(password) >= 8)`, // the check itself still sees the value
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow missing %s:\n%s", want, shadow)
+		}
+	}
+}
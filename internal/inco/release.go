@@ -5,33 +5,38 @@ package inco
 import (
 	"encoding/json"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
-// releaseHeader is prepended to released files following Go's generated-code
-// convention so that tools (and inco itself) know to skip them.
+// releaseHeader marks released files following Go's generated-code
+// convention so that tools (and inco itself) know to skip them. It goes
+// after the file's leading comment block (see withReleaseHeader).
 const releaseHeader = "// Code generated by inco. DO NOT EDIT.\n\n"
 
-// Release reads the overlay from .inco_cache and produces release files.
+// Release reads the overlay from cacheDir and produces release files.
 //
 // For each overlay entry whose original is a .inco.go file:
-//   - The shadow content (with guards) is written as <base>.go (prepending
-//     the generated-code header; //line directives are preserved for traces).
+//   - The shadow content (with guards) is written as <base>.go (adding the
+//     generated-code header below the license and build constraints;
+//     //line directives are preserved for traces).
 //   - The original .inco.go is renamed to .inco (backup — invisible to the
 //     Go compiler).
 //
 // After release, plain "go build" compiles the guarded .go files.
 // "inco release clean" restores the originals.
-func Release(root string) {
+func Release(root, cacheDir string) {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:26
 	if !(root != "") {
 		panic("Release: root must not be empty")
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:27
 
-	ov := loadOverlay(root)
+	ov := loadOverlay(cacheDir)
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:29
 	if !(len(ov.Replace) > 0) {
 		panic("Release: no overlay entries — run gen first")
@@ -54,9 +59,11 @@ func Release(root string) {
 		}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:38
 
-		// 2. Write <base>.go alongside the original.
+		// 2. Write <base>.go alongside the original, re-anchoring relative
+		// //line paths (TrimPath shadows) to the release file's directory.
 		releasePath := releasePathFor(origPath)
-		err = os.WriteFile(releasePath, []byte(releaseHeader+string(shadowContent)), 0o644)
+		shadowContent = relocateLineDirectives(shadowContent, filepath.Dir(shadowPath), filepath.Dir(releasePath))
+		err = os.WriteFile(releasePath, withReleaseHeader(shadowContent), 0o644)
 		_ = err // @inco: err == nil, -panic(err)
 		if !(err == nil) {
 			panic(err)
@@ -84,14 +91,14 @@ func Release(root string) {
 // For each overlay entry whose original is a .inco.go file:
 //   - The generated .go file is removed.
 //   - The .inco backup is renamed back to .inco.go.
-func ReleaseClean(root string) {
+func ReleaseClean(root, cacheDir string) {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:62
 	if !(root != "") {
 		panic("ReleaseClean: root must not be empty")
 	}
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:63
 
-	ov := loadOverlay(root)
+	ov := loadOverlay(cacheDir)
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/release.inco.go:65
 	if !(len(ov.Replace) > 0) {
 		panic("ReleaseClean: no overlay entries")
@@ -129,9 +136,9 @@ func ReleaseClean(root string) {
 // Helpers
 // ---------------------------------------------------------------------------
 
-// loadOverlay reads and parses .inco_cache/overlay.json.
-func loadOverlay(root string) Overlay {
-	overlayPath := filepath.Join(root, ".inco_cache", "overlay.json")
+// loadOverlay reads and parses cacheDir/overlay.json.
+func loadOverlay(cacheDir string) Overlay {
+	overlayPath := filepath.Join(cacheDir, "overlay.json")
 	data, err := os.ReadFile(overlayPath)
 	_ = err // @inco: err == nil, -panic(err)
 	if !(err == nil) {
@@ -149,6 +156,78 @@ func loadOverlay(root string) Overlay {
 	return ov
 }
 
+// lineDirectiveRe matches a //line directive with a relative file name.
+// Group 1: file name
+// Group 2: line number
+var lineDirectiveRe = regexp.MustCompile(`(?m)^//line ([^/\n][^\n]*):(\d+)$`)
+
+// relocateLineDirectives rewrites relative //line file names, which the
+// compiler resolves against the directory of the compiled file, so that
+// content moved from fromDir to toDir still points at the same sources.
+// Absolute file names are left unchanged.
+func relocateLineDirectives(content []byte, fromDir, toDir string) []byte {
+	return lineDirectiveRe.ReplaceAllFunc(content, func(m []byte) []byte {
+		sm := lineDirectiveRe.FindSubmatch(m)
+		name := string(sm[1])
+		_ = name // @inco: !filepath.IsAbs(name), -return(m)
+		if !(!filepath.IsAbs(name)) {
+			return m
+		}
+		rel, err := filepath.Rel(toDir, filepath.Join(fromDir, name))
+		_ = err // @inco: err == nil, -return(m)
+		if !(err == nil) {
+			return m
+		}
+		return []byte(fmt.Sprintf("//line %s:%s", rel, sm[2]))
+	})
+}
+
+// withReleaseHeader returns content with releaseHeader inserted after its
+// leading comment block — license text and build constraints, up to the
+// package doc comment or clause — which so stays verbatim at the top of
+// the file:
+//
+//	// Copyright 2024 The Authors.
+//
+//	//go:build linux
+//
+//	// Code generated by inco. DO NOT EDIT.
+//
+//	package p
+//
+// Content without such a block, or that does not parse, gets the header
+// first. The header follows the line endings of content and stays behind
+// its BOM, which must remain the first bytes of the file.
+func withReleaseHeader(content []byte) []byte {
+	text := []byte(strings.Join(splitLines(content), "\n"))
+	return joinLines(splitLines(insertReleaseHeader(text)), content)
+}
+
+// insertReleaseHeader inserts the header into content, with "\n" line
+// endings and no BOM, as withReleaseHeader describes.
+func insertReleaseHeader(content []byte) []byte {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.PackageClauseOnly|parser.ParseComments)
+	_ = err // @inco: err == nil, -return(append([]byte(releaseHeader), content...))
+	if !(err == nil) {
+		return append([]byte(releaseHeader), content...)
+	}
+	end := -1
+	for _, cg := range f.Comments {
+		if cg == f.Doc || cg.Pos() > f.Package {
+			break
+		}
+		end = fset.Position(cg.End()).Offset
+	}
+	_ = end // @inco: end >= 0, -return(append([]byte(releaseHeader), content...))
+	if !(end >= 0) {
+		return append([]byte(releaseHeader), content...)
+	}
+	out := append([]byte{}, content[:end]...)
+	out = append(out, "\n\n"+strings.TrimSpace(releaseHeader)...)
+	return append(out, content[end:]...)
+}
+
 // releasePathFor returns the .go path for a .inco.go source file.
 //
 //	/a/b/foo.inco.go → /a/b/foo.go
@@ -0,0 +1,60 @@
+package inco
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// relocateLineDirectives
+// ---------------------------------------------------------------------------
+
+func TestRelocateLineDirectives(t *testing.T) {
+	root := filepath.FromSlash("/mod")
+	cache := filepath.Join(root, ".inco_cache")
+	pkg := filepath.Join(root, "pkg")
+
+	rel := filepath.Join("..", "pkg", "foo.inco.go")
+	abs := filepath.Join(pkg, "foo.inco.go")
+	input := "package pkg\n//line " + rel + ":4\nfunc F() {}\n//line " + abs + ":9\n"
+	want := "package pkg\n//line foo.inco.go:4\nfunc F() {}\n//line " + abs + ":9\n"
+
+	if got := string(relocateLineDirectives([]byte(input), cache, pkg)); got != want {
+		t.Errorf("relocateLineDirectives =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// withReleaseHeader
+// ---------------------------------------------------------------------------
+
+func TestWithReleaseHeader(t *testing.T) {
+	const gen = "// Code generated by inco. DO NOT EDIT."
+	tests := []struct {
+		name, in, want string
+	}{
+		{"no header", "package p\n", gen + "\n\npackage p\n"},
+		{"package doc only", "// Package p does things.\npackage p\n", gen + "\n\n// Package p does things.\npackage p\n"},
+		{
+			"license and build tags",
+			"// Copyright 2024 The Authors.\n// SPDX-License-Identifier: MIT\n\n//go:build linux\n\n// Package p does things.\npackage p\n",
+			"// Copyright 2024 The Authors.\n// SPDX-License-Identifier: MIT\n\n//go:build linux\n\n" + gen + "\n\n// Package p does things.\npackage p\n",
+		},
+		{
+			"block comment license",
+			"/*\n * Licensed under MIT.\n */\n\npackage p\n",
+			"/*\n * Licensed under MIT.\n */\n\n" + gen + "\n\npackage p\n",
+		},
+		{"bom", "\uFEFFpackage p\n", "\uFEFF" + gen + "\n\npackage p\n"},
+		{
+			"crlf",
+			"// Copyright 2024 The Authors.\r\n\r\npackage p\r\n",
+			"// Copyright 2024 The Authors.\r\n\r\n" + gen + "\r\n\r\npackage p\r\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := string(withReleaseHeader([]byte(tt.in))); got != tt.want {
+			t.Errorf("%s:\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
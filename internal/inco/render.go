@@ -0,0 +1,351 @@
+package inco
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// Audit report formats
+// ---------------------------------------------------------------------------
+
+// Renderer writes an audit report in one format.
+type Renderer interface {
+	Render(w io.Writer, r *AuditResult) error
+}
+
+// Renderers maps the names accepted by inco audit -format to renderers.
+var Renderers = map[string]Renderer{
+	"text":  TextRenderer{},
+	"json":  JSONRenderer{},
+	"html":  HTMLRenderer{},
+	"sarif": SARIFRenderer{},
+}
+
+// RendererNames returns the keys of Renderers, sorted.
+func RendererNames() []string {
+	names := make([]string, 0, len(Renderers))
+	for name := range Renderers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// errWriter remembers the first write error so that reports written with
+// a series of fmt.Fprintf calls can return it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	_ = e // @inco: e.err == nil, -return(0, e.err)
+	if !(e.err == nil) {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// TextRenderer writes the human-readable report of PrintReport.
+type TextRenderer struct {
+	Color Colorizer
+}
+
+func (t TextRenderer) Render(w io.Writer, r *AuditResult) error {
+	ew := &errWriter{w: w}
+	r.printReport(ew, t.Color)
+	return ew.err
+}
+
+// JSONRenderer writes the AuditResult as indented JSON, as inco serve
+// returns it.
+type JSONRenderer struct{}
+
+func (JSONRenderer) Render(w io.Writer, r *AuditResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// HTMLRenderer writes a standalone HTML page with the report's tables.
+type HTMLRenderer struct{}
+
+func (HTMLRenderer) Render(w io.Writer, r *AuditResult) error {
+	return auditHTML.Execute(w, r)
+}
+
+var auditHTML = template.Must(template.New("audit").Funcs(template.FuncMap{
+	"pct": func(n, total int) string {
+		_ = total // @inco: total > 0, -return("—")
+		if !(total > 0) {
+			return "—"
+		}
+		return fmt.Sprintf("%.1f%%", float64(n)/float64(total)*100)
+	},
+	"guarded": func(f FileAudit) int {
+		n := 0
+		for _, fn := range f.Funcs {
+			if fn.RequireCount > 0 {
+				n++
+			}
+		}
+		return n
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>inco audit</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+td.n { text-align: right; }
+code { font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>inco audit — contract coverage report</h1>
+<table>
+{{- with .Module}}
+<tr><th>Module</th><td><code>{{.}}</code></td></tr>
+{{- end}}
+<tr><th>Files scanned</th><td class="n">{{.TotalFiles}}</td></tr>
+<tr><th>Functions</th><td class="n">{{.TotalFuncs}}</td></tr>
+<tr><th>With @inco:</th><td class="n">{{.GuardedFuncs}} ({{pct .GuardedFuncs .TotalFuncs}})</td></tr>
+<tr><th>@inco: directives</th><td class="n">{{.TotalRequires}}</td></tr>
+<tr><th>@invariant directives</th><td class="n">{{.TotalInvariants}}</td></tr>
+<tr><th>Native if statements</th><td class="n">{{.TotalIfs}}</td></tr>
+<tr><th>Directives per 100 lines</th><td class="n">{{printf "%.1f" .DirectivesPer100Lines}}</td></tr>
+<tr><th>Directives per function</th><td class="n">{{printf "%.1f" .DirectivesPerFunc}}</td></tr>
+<tr><th>Panic contracts</th><td class="n">{{.TotalPanicContracts}}</td></tr>
+<tr><th>Diagnosability</th><td class="n">{{printf "%.1f%%" .Diagnosability}}</td></tr>
+</table>
+{{with .Modules}}
+<h2>Workspace modules</h2>
+<table>
+<tr><th>Module</th><th>Dir</th><th>files</th><th>funcs</th><th>guarded</th></tr>
+{{- range .}}
+<tr><td><code>{{.Path}}</code></td><td><code>{{.Dir}}</code></td><td class="n">{{.Files}}</td><td class="n">{{.Funcs}}</td><td class="n">{{.GuardedFuncs}}</td></tr>
+{{- end}}
+</table>
+{{end}}
+<h2>Contract density</h2>
+<table>
+<tr><th>Directives</th><th>Functions</th></tr>
+{{- range .Density}}
+<tr><td>{{.Label}}</td><td class="n">{{.Funcs}}</td></tr>
+{{- end}}
+</table>
+<table>
+<tr><th>Package</th><th>lines</th><th>funcs</th><th>per 100 lines</th><th>per func</th></tr>
+{{- range .Packages}}
+<tr><td><code>{{.Dir}}</code></td><td class="n">{{.Lines}}</td><td class="n">{{.Funcs}}</td><td class="n">{{printf "%.1f" .Per100Lines}}</td><td class="n">{{printf "%.1f" .PerFunc}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Per-file breakdown</h2>
+<table>
+<tr><th>File</th><th>@inco:</th><th>if</th><th>funcs</th><th>guarded</th></tr>
+{{- range .Files}}
+<tr><td><code>{{.RelPath}}</code></td><td class="n">{{.RequireCount}}</td><td class="n">{{.IfCount}}</td><td class="n">{{len .Funcs}}</td><td class="n">{{guarded .}}</td></tr>
+{{- end}}
+</table>
+{{with .Unguarded}}
+<h2>Functions without @inco: ({{len .}})</h2>
+<ul>
+{{- range .}}
+<li><code>{{.Path}}:{{.Line}}</code> {{.Func}}</li>
+{{- end}}
+</ul>
+{{end}}
+{{- if .TotalWarnings}}
+<h2>Contract size warnings ({{.TotalWarnings}})</h2>
+<ul>
+{{- range .Files}}{{range .Warnings}}
+<li><code>{{.Path}}:{{.Line}}</code> {{.Message}}</li>
+{{- end}}{{end}}
+</ul>
+{{end}}
+{{- if .TotalMessageIssues}}
+<h2>Panic message quality</h2>
+<ul>
+{{- range .Files}}{{range .MessageIssues}}
+<li><code>{{.Path}}:{{.Line}}</code> {{.Message}} [{{.Rule}}]</li>
+{{- end}}{{end}}
+</ul>
+{{end}}
+{{- with .Receivers}}
+<h2>Contracts by receiver type</h2>
+{{- range .}}
+<h3><code>{{if ne .Dir "."}}{{.Dir}}.{{end}}{{.Type}}</code></h3>
+<table>
+<tr><th>Method</th><th>Contract</th><th>On violation</th></tr>
+{{- range .Rules}}
+<tr><td>{{.Method}}</td><td><code>{{if .Cond}}if({{.Cond}}) {{end}}{{.Expr}}</code></td><td>{{.OnViolation}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{end}}
+{{- with .Endpoints}}
+<h2>Contracts by endpoint ({{$.ValidatedEndpoints}} of {{len .}} validated)</h2>
+<table>
+<tr><th>Endpoint</th><th>Handler</th><th>Contracts</th></tr>
+{{- range .}}
+<tr><td><code>{{.Name}}</code></td><td><code>{{.Path}}:{{.Line}}</code> {{.Func}}</td><td>{{range .Contracts}}<code>{{if .Cond}}if({{.Cond}}) {{end}}{{.Expr}}</code> {{.OnViolation}}<br>{{else}}none{{end}}</td></tr>
+{{- end}}
+</table>
+{{end}}
+{{- with .Wireups}}
+<h2>Wire-up safety ({{$.CheckedDependencies}} of {{$.TotalDependencies}} dependencies checked)</h2>
+<table>
+<tr><th>Constructor</th><th>Field</th><th>From</th><th>Checked</th></tr>
+{{- range .}}{{$w := .}}{{range .Fields}}
+<tr><td><code>{{$w.Path}}:{{$w.Line}}</code> {{$w.Func}}</td><td><code>{{$w.Type}}.{{.Name}}</code> ({{.Kind}})</td><td><code>{{.Param}}</code></td><td>{{if .Checked}}yes{{else}}no{{end}}</td></tr>
+{{- end}}{{end}}
+</table>
+{{end}}
+{{- if .Runtime}}
+<h2>Runtime reachability ({{.EvaluatedContracts}} contracts evaluated)</h2>
+<ul>
+{{- range .Unevaluated}}
+<li><code>{{.Path}}:{{.Line}}</code> <code>{{.Expr}}</code> never evaluated</li>
+{{- end}}
+</ul>
+{{end}}
+{{- with .IgnoredPaths}}
+<h2>Ignored by .incoignore ({{len .}})</h2>
+<ul>
+{{- range .}}
+<li><code>{{.}}</code></li>
+{{- end}}
+</ul>
+{{end -}}
+</body>
+</html>
+`))
+
+// SARIFRenderer writes the report's findings as a SARIF 2.1.0 log, for
+// code scanning tools: unguarded functions, endpoints without contracts,
+// unchecked constructor dependencies, contracts no recorded run evaluated,
+// contract size warnings and panic message issues. Locations are relative to %SRCROOT%, the audited root.
+type SARIFRenderer struct{}
+
+// sarifRules describes the rule IDs the SARIF log uses.
+var sarifRules = []struct {
+	ID, Description, Level string
+}{
+	{"unguarded-function", "Function declares no @inco: contract", "note"},
+	{"unvalidated-endpoint", "Endpoint handler declares no @inco: contract", "warning"},
+	{"unchecked-dependency", "Constructor stores a pointer or interface parameter no contract checks", "warning"},
+	{"unevaluated-contract", "Contract no recorded run evaluated (audit -runtime)", "note"},
+	{"contract-size", "Contract or function exceeds the size limits", "warning"},
+	{"default-message", "Exported function relies on the generated violation message", "note"},
+	{"empty-message", "Contract panics with an empty message", "warning"},
+	{"no-context", "Constant panic message omits the values involved", "note"},
+	{"duplicate", "Panic message shared by several contracts", "note"},
+}
+
+func (SARIFRenderer) Render(w io.Writer, r *AuditResult) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI       string `json:"uri"`
+				URIBaseID string `json:"uriBaseId"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+
+	levels := make(map[string]string, len(sarifRules))
+	var rules []rule
+	for _, sr := range sarifRules {
+		levels[sr.ID] = sr.Level
+		rules = append(rules, rule{ID: sr.ID, ShortDescription: message{sr.Description}})
+	}
+	results := []result{}
+	add := func(ruleID, path string, line int, text string) {
+		var loc location
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(path)
+		loc.PhysicalLocation.ArtifactLocation.URIBaseID = "%SRCROOT%"
+		loc.PhysicalLocation.Region.StartLine = line
+		results = append(results, result{RuleID: ruleID, Level: levels[ruleID], Message: message{text}, Locations: []location{loc}})
+	}
+	for _, m := range r.Unguarded() {
+		add("unguarded-function", m.Path, m.Line, m.Func+" has no @inco: contract")
+	}
+	for _, ep := range r.Endpoints {
+		if !ep.Validated() {
+			add("unvalidated-endpoint", ep.Path, ep.Line, ep.Name()+" ("+ep.Func+") validates no input with @inco:")
+		}
+	}
+	for _, wu := range r.Wireups {
+		for _, wf := range wu.Unchecked() {
+			add("unchecked-dependency", wu.Path, wu.Line, fmt.Sprintf("%s stores %s in %s.%s without checking it is not nil", wu.Func, wf.Param, wu.Type, wf.Name))
+		}
+	}
+	for _, s := range r.Unevaluated {
+		add("unevaluated-contract", s.Path, s.Line, s.Expr+" was never evaluated: the code it guards may be dead")
+	}
+	for _, f := range r.Files {
+		for _, warn := range f.Warnings {
+			add("contract-size", warn.Path, warn.Line, warn.Message)
+		}
+		for _, issue := range f.MessageIssues {
+			add(issue.Rule, issue.Path, issue.Line, issue.Message)
+		}
+	}
+
+	run := map[string]any{
+		"tool": map[string]any{"driver": map[string]any{
+			"name":           "inco audit",
+			"informationUri": "https://github.com/imnive-design/inco-go",
+			"rules":          rules,
+		}},
+		"results": results,
+	}
+	if r.Module != "" || len(r.Modules) > 0 {
+		props := map[string]any{}
+		if r.Module != "" {
+			props["module"] = r.Module
+		}
+		if len(r.Modules) > 0 {
+			var paths []string
+			for _, m := range r.Modules {
+				paths = append(paths, m.Path)
+			}
+			props["modules"] = paths
+		}
+		run["properties"] = props
+	}
+	log := map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs":    []any{run},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
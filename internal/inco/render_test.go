@@ -0,0 +1,95 @@
+package inco
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Audit report formats
+// ---------------------------------------------------------------------------
+
+const renderSrc = `package main
+
+type Conn struct{ open bool }
+
+func Open(name string) {
+	// @inco: name != ""
+}
+
+func (c *Conn) Close() {
+	_ = c // @inco: c.open, -panic("bad input")
+}
+
+func Unguarded(y int) {}
+`
+
+func TestRenderers(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": renderSrc})
+	r := Audit(dir, Limits{})
+
+	render := func(name string) string {
+		t.Helper()
+		var b strings.Builder
+		if err := Renderers[name].Render(&b, r); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return b.String()
+	}
+
+	if text := render("text"); !strings.Contains(text, "Functions without @inco: (1):\n  main.go:13  Unguarded") {
+		t.Errorf("text report:\n%s", text)
+	}
+
+	var decoded AuditResult
+	if err := json.Unmarshal([]byte(render("json")), &decoded); err != nil || decoded.TotalFuncs != 3 {
+		t.Errorf("json report: TotalFuncs = %d, err = %v", decoded.TotalFuncs, err)
+	}
+
+	html := render("html")
+	for _, want := range []string{"<code>main.go:13</code> Unguarded", "<code>Conn</code>", "<code>c.open</code>", "&#34;bad input&#34;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html report lacks %q:\n%s", want, html)
+		}
+	}
+
+	var sarif struct {
+		Version string
+		Runs    []struct {
+			Results []struct {
+				RuleID    string
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(render("sarif")), &sarif); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, res := range sarif.Runs[0].Results {
+		loc := res.Locations[0].PhysicalLocation
+		got = append(got, fmt.Sprintf("%s@%s:%d", res.RuleID, loc.ArtifactLocation.URI, loc.Region.StartLine))
+	}
+	if want := "unguarded-function@main.go:13|default-message@main.go:6|no-context@main.go:10"; sarif.Version != "2.1.0" || strings.Join(got, "|") != want {
+		t.Errorf("sarif results = %q (version %q), want %q", got, sarif.Version, want)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestTextRenderer_WriteError(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": renderSrc})
+	if err := (TextRenderer{}).Render(failingWriter{}, Audit(dir, Limits{})); err == nil || err.Error() != "disk full" {
+		t.Errorf("Render = %v, want the write error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// -return arity: zero-filling the values a -return action leaves out
+// ---------------------------------------------------------------------------
+
+// returnValues returns the values of the -return action of d fitted to
+// the results of ft, the function enclosing it: values left out are
+// zero-filled in front, since the values given are usually the last ones
+// (an error): -return(err) in a func() (*User, error) returns nil, err.
+// A bare -return in a function with named results stays bare. It fails
+// when d gives more values than ft returns.
+func returnValues(d *Directive, ft *ast.FuncType) ([]string, error) {
+	var results []ast.Expr // one type per result
+	named := false
+	if ft != nil && ft.Results != nil {
+		for _, field := range ft.Results.List {
+			named = named || len(field.Names) > 0
+			for range max(len(field.Names), 1) {
+				results = append(results, field.Type)
+			}
+		}
+	}
+	args := d.ActionArgs
+	_ = args // @inco: len(args) <= len(results), -return(nil, fmt.Errorf("-return(%s): the enclosing function returns %s", strings.Join(args, ", "), countValues(len(results))))
+	if !(len(args) <= len(results)) {
+		return nil, fmt.Errorf("-return(%s): the enclosing function returns %s", strings.Join(args, ", "), countValues(len(results)))
+	}
+	if len(args) == 0 && named {
+		return nil, nil
+	}
+	values := make([]string, 0, len(results))
+	for _, t := range results[:len(results)-len(args)] {
+		values = append(values, zeroValue(t))
+	}
+	return append(values, args...), nil
+}
+
+// countValues phrases a number of results: "no values", "1 value", ...
+func countValues(n int) string {
+	switch n {
+	case 0:
+		return "no values"
+	case 1:
+		return "1 value"
+	}
+	return fmt.Sprintf("%d values", n)
+}
+
+// zeroValue returns an expression for the zero value of the type t:
+// a literal for predeclared types, nil for the types that have it, and
+// *new(T) for any other.
+func zeroValue(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "bool":
+			return "false"
+		case "string":
+			return `""`
+		case "error", "any":
+			return "nil"
+		case "int", "int8", "int16", "int32", "int64",
+			"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+			"byte", "rune", "float32", "float64", "complex64", "complex128":
+			return "0"
+		}
+	case *ast.StarExpr, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		return "nil"
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "nil"
+		}
+	}
+	return "*new(" + types.ExprString(t) + ")"
+}
+
+// resolveReturn returns d with the values of its -return action fitted to
+// the enclosing function's results (see returnValues), or d itself for
+// other actions. A -return giving too many values is a generation error.
+func (e *Engine) resolveReturn(d *Directive, f *ast.File, fset *token.FileSet, path string, line int) *Directive {
+	_ = d // @inco: d.Action == ActionReturn, -return(d)
+	if !(d.Action == ActionReturn) {
+		return d
+	}
+	ft, _ := enclosingFunc(f, fset, line)
+	values, err := returnValues(d, ft)
+	_ = err // @inco: err == nil, -panic(e.directiveError(path, line, err))
+	if !(err == nil) {
+		panic(e.directiveError(path, line, err))
+	}
+	resolved := *d
+	resolved.ActionArgs = values
+	return &resolved
+}
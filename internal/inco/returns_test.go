@@ -0,0 +1,69 @@
+package inco
+
+import (
+	"go/ast"
+	"go/parser"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// -return arity
+// ---------------------------------------------------------------------------
+
+func TestReturnValues(t *testing.T) {
+	tests := []struct {
+		sig, args string
+		want      string
+	}{
+		{"func() (*User, error)", "err", "nil, err"},
+		{"func() (int, string, bool, error)", "err", `0, "", false, err`},
+		{"func() (User, []byte, [2]int, error)", "err", "*new(User), nil, *new([2]int), err"},
+		{"func() (a, b int)", "", ""},
+		{"func() (int, error)", "", "0, nil"},
+		{"func() (int, error)", "n, nil", "n, nil"},
+		{"func()", "", ""},
+	}
+	for _, tt := range tests {
+		x, err := parser.ParseExpr(tt.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &Directive{Action: ActionReturn}
+		if tt.args != "" {
+			d.ActionArgs = splitTopLevel(tt.args)
+		}
+		values, err := returnValues(d, x.(*ast.FuncType))
+		if got := strings.Join(values, ", "); err != nil || got != tt.want {
+			t.Errorf("%s, -return(%s) = %q, %v; want %q", tt.sig, tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestEngine_ReturnArity(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+import "errors"
+
+func Load(id string) ([]byte, int, error) {
+	// @inco: id != "", -return(errors.New("no id"))
+	return nil, 0, nil
+}
+`})
+	e := NewEngine(dir)
+	e.Run()
+	if shadow := readShadow(t, e); !strings.Contains(shadow, `return nil, 0, errors.New("no id")`) {
+		t.Errorf("shadow missing the zero-filled return:\n%s", shadow)
+	}
+
+	src := "package main\n\nfunc f(x int) error {\n\t// @inco: x > 0, -return(0, nil)\n\treturn nil\n}\n"
+	e = NewEngine(setupDir(t, map[string]string{"main.go": src}))
+	want := "main.go:4: -return(0, nil): the enclosing function returns 1 value"
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, want) {
+		t.Errorf("got %q, want %q", msg, want)
+	}
+	diags := Vet(setupDir(t, map[string]string{"main.go": src}))
+	if len(diags) != 1 || diags[0].Column != 19 || !strings.HasSuffix(diags[0].Message, "returns 1 value") {
+		t.Errorf("vet = %v", diags)
+	}
+}
@@ -0,0 +1,82 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Restricted contexts
+// ---------------------------------------------------------------------------
+
+// restrictedPragmas are the compiler directives that make a function unfit
+// for injected checks, with the restriction each imposes. A guard calls
+// panic, may format its message and allocate: none of that is legal, or
+// safe, where these apply.
+var restrictedPragmas = map[string]string{
+	"go:nosplit":           "must not grow the stack",
+	"go:norace":            "is excluded from race instrumentation",
+	"go:nowritebarrier":    "must not contain write barriers",
+	"go:nowritebarrierrec": "must not contain write barriers, even in callees",
+	"go:systemstack":       "must run on the system stack",
+}
+
+// restrictedContext returns why no directive at line of f, the file at
+// path, may be injected, or "" when injection is safe. Directives are
+// refused in runtime packages and in functions carrying one of the
+// restrictedPragmas; the latter includes function literals inside such
+// functions.
+func restrictedContext(f *ast.File, fset *token.FileSet, path string, line int) string {
+	dir := filepath.ToSlash(filepath.Dir(path)) + "/"
+	if f.Name.Name == "runtime" || strings.Contains(dir, "/runtime/internal/") || strings.Contains(dir, "/internal/runtime/") {
+		return fmt.Sprintf("package %s is part of the Go runtime, which is compiled under restrictions injected checks may violate", f.Name.Name)
+	}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Body != nil && fn.Doc != nil, -continue
+		if !(ok && fn.Body != nil && fn.Doc != nil) {
+			continue
+		}
+		_ = fn // @inco: srcLine(fset, fn.Body.Lbrace) <= line && line <= srcLine(fset, fn.Body.Rbrace), -continue
+		if !(srcLine(fset, fn.Body.Lbrace) <= line && line <= srcLine(fset, fn.Body.Rbrace)) {
+			continue
+		}
+		for _, c := range fn.Doc.List {
+			pragma, _, _ := strings.Cut(strings.TrimPrefix(c.Text, "//"), " ")
+			if why, ok := restrictedPragmas[pragma]; ok {
+				return fmt.Sprintf("%s is //%s and %s", funcDeclName(fn), pragma, why)
+			}
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// Body-less declarations
+// ---------------------------------------------------------------------------
+
+// bodilessFunc returns the function declared without a body — implemented
+// in assembly or pulled in with //go:linkname — whose doc comment holds c,
+// or nil.
+func bodilessFunc(f *ast.File, c *ast.Comment) *ast.FuncDecl {
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Body == nil && fn.Doc != nil, -continue
+		if !(ok && fn.Body == nil && fn.Doc != nil) {
+			continue
+		}
+		if fn.Doc.Pos() <= c.Pos() && c.End() <= fn.Doc.End() {
+			return fn
+		}
+	}
+	return nil
+}
+
+// bodilessWhy explains why no contract can be injected for fn, which has
+// no body.
+func bodilessWhy(fn *ast.FuncDecl) string {
+	return fmt.Sprintf("%s has no body (it is implemented in assembly or linked with //go:linkname); put the contract on a Go wrapper", funcDeclName(fn))
+}
@@ -0,0 +1,114 @@
+package inco
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestrictedContext(t *testing.T) {
+	src := `package p
+
+//go:nosplit
+func spin(n int) {
+	_ = n // line 5
+}
+
+//go:norace
+//go:noinline
+func racy(n int) {
+	f := func() {
+		_ = n // line 12
+	}
+	f()
+}
+
+//go:noinline
+func plain(n int) {
+	_ = n // line 18
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line int
+		want string // substring; "" = unrestricted
+	}{
+		{5, "spin is //go:nosplit and must not grow the stack"},
+		{12, "racy is //go:norace"},
+		{18, ""},
+	}
+	for _, tt := range tests {
+		got := restrictedContext(f, fset, "/mod/p/p.go", tt.line)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("line %d: got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"/mod/runtime/internal/sys/p.go", "/mod/internal/runtime/atomic/p.go"} {
+		if got := restrictedContext(f, fset, path, 18); !strings.Contains(got, "Go runtime") {
+			t.Errorf("%s: got %q, want a runtime package restriction", path, got)
+		}
+	}
+}
+
+func TestEngine_RefusesRestrictedContexts(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+//go:nosplit
+func spin(n int) {
+	// @inco: n > 0
+	_ = n
+}
+`,
+	})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, "main.go:5: cannot inject contract: spin is //go:nosplit") {
+		t.Errorf("unexpected error: %s", msg)
+	}
+
+	diags := Vet(dir)
+	if len(diags) != 1 || diags[0].Path != filepath.Join(dir, "main.go") || diags[0].Line != 5 ||
+		!strings.Contains(diags[0].Message, "contract cannot be injected") {
+		t.Errorf("vet should flag the directive, got %v", diags)
+	}
+}
+
+func TestEngine_RefusesBodilessContracts(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"xor.go": `package p
+
+// xorBytes is implemented in xor_amd64.s.
+//
+// @inco: len(dst) >= len(src)
+func xorBytes(dst, src []byte)
+
+// Xor documents its contract, e.g.:
+//
+//	// @inco: len(dst) >= len(src)
+func Xor(dst, src []byte) {
+	xorBytes(dst, src)
+}
+`,
+	})
+	msg := runExpectPanic(t, NewEngine(dir))
+	if !strings.Contains(msg, "xor.go:5: cannot inject contract: xorBytes has no body") {
+		t.Errorf("unexpected error: %s", msg)
+	}
+
+	diags := Vet(dir)
+	if len(diags) != 1 || diags[0].Line != 5 || !strings.Contains(diags[0].Message, "xorBytes has no body") {
+		t.Errorf("vet should flag the directive, got %v", diags)
+	}
+
+	r := Audit(dir, Limits{})
+	if r.TotalFuncs != 1 || r.BodilessFuncs != 1 {
+		t.Errorf("TotalFuncs = %d, BodilessFuncs = %d, want 1, 1", r.TotalFuncs, r.BodilessFuncs)
+	}
+}
@@ -0,0 +1,309 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Satisfiability of consecutive contracts
+// ---------------------------------------------------------------------------
+
+// bound is one comparison of an operand with a constant, as written in a
+// contract, e.g. "x > 10" on line 4.
+type bound struct {
+	value float64
+	incl  bool   // <= or >= rather than < or >
+	text  string // the comparison as written
+	line  int
+}
+
+// interval holds what the contracts so far require of one operand.
+type interval struct {
+	lo, hi *bound   // nil when unbounded
+	ne     []*bound // != comparisons
+	since  int      // line of the first contract; assignments after it reset the interval
+}
+
+// vetSatisfiable reports contracts that cannot hold together with earlier
+// contracts in the same block: x < 5 after x > 10, x == 0 after x != 0.
+// Only unconditional @inco: contracts are considered, and only their
+// top-level && terms that compare an operand with a numeric constant. An
+// assignment to any identifier of an operand between two contracts
+// forgets what was known about it.
+func vetSatisfiable(f *ast.File, fset *token.FileSet, path string, lines []string) []Diagnostic {
+	standalone, inline := collectDirectives(f, fset, lines)
+	directives := maps.Clone(standalone)
+	maps.Copy(directives, inline)
+
+	// Group the directive lines by their innermost block.
+	groups := make(map[ast.Node][]int)
+	for _, line := range slices.Sorted(maps.Keys(directives)) {
+		if block := innermostBlock(f, fset, line); block != nil {
+			groups[block] = append(groups[block], line)
+		}
+	}
+
+	var diags []Diagnostic
+	for block, dlines := range groups {
+		assigned := assignmentLines(block, fset)
+		known := make(map[string]*interval)
+		for _, line := range dlines {
+			for _, d := range directives[line] {
+				if d.Kind != KindRequire || d.Cond != "" {
+					continue
+				}
+				for _, term := range comparisonTerms(d.Expr) {
+					iv := known[term.operand]
+					if iv == nil || assignedBetween(assigned, term.idents, iv.since, line) {
+						iv = &interval{since: line}
+						known[term.operand] = iv
+					}
+					term.bound.line = line
+					if prev := iv.add(term.op, term.bound); prev != nil {
+						delete(known, term.operand) // report each contradiction once
+						col := strings.Index(lines[line-1], term.bound.text) + 1
+						diags = append(diags, Diagnostic{Path: path, Line: line, Column: max(col, 1), Code: CodeUnsatisfiable,
+							Message: fmt.Sprintf("contract %s contradicts %s (line %d): no value satisfies both, so the function cannot be called correctly",
+								term.bound.text, prev.text, prev.line)})
+					}
+				}
+			}
+		}
+	}
+	return diags
+}
+
+// add narrows iv by "operand op b" and returns the earlier comparison it
+// contradicts, or nil.
+func (iv *interval) add(op token.Token, b *bound) *bound {
+	switch op {
+	case token.GTR, token.GEQ:
+		b.incl = op == token.GEQ
+		if iv.lo == nil || b.value > iv.lo.value || (b.value == iv.lo.value && !b.incl) {
+			iv.lo = b
+		}
+	case token.LSS, token.LEQ:
+		b.incl = op == token.LEQ
+		if iv.hi == nil || b.value < iv.hi.value || (b.value == iv.hi.value && !b.incl) {
+			iv.hi = b
+		}
+	case token.EQL:
+		b.incl = true
+		for _, ne := range iv.ne {
+			if ne.value == b.value {
+				return ne
+			}
+		}
+		if iv.lo != nil && !iv.lo.admits(b.value, 1) {
+			return iv.lo
+		}
+		if iv.hi != nil && !iv.hi.admits(b.value, -1) {
+			return iv.hi
+		}
+		iv.lo, iv.hi = b, b
+		return nil
+	case token.NEQ:
+		iv.ne = append(iv.ne, b)
+		if iv.lo != nil && iv.hi != nil && iv.lo.value == b.value && iv.hi.value == b.value {
+			return iv.lo
+		}
+		return nil
+	}
+
+	// The new bound b is iv.lo or iv.hi; check it against the other side
+	// and against the != comparisons.
+	if iv.lo != nil && iv.hi != nil {
+		if iv.lo.value > iv.hi.value || (iv.lo.value == iv.hi.value && !(iv.lo.incl && iv.hi.incl)) {
+			if b == iv.lo {
+				return iv.hi
+			}
+			return iv.lo
+		}
+		if iv.lo.value == iv.hi.value {
+			for _, ne := range iv.ne {
+				if ne.value == iv.lo.value {
+					return ne
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// admits reports whether v satisfies b as a lower (dir 1) or upper
+// (dir -1) bound.
+func (b *bound) admits(v float64, dir int) bool {
+	d := (v - b.value) * float64(dir)
+	return d > 0 || (d == 0 && b.incl)
+}
+
+// comparisonTerm is a top-level && term "operand op constant".
+type comparisonTerm struct {
+	operand string
+	idents  []string // identifiers of the operand
+	op      token.Token
+	bound   *bound
+}
+
+// comparisonTerms returns the terms of expr that compare an operand with
+// a numeric constant, normalised so the operand is on the left.
+func comparisonTerms(expr string) []comparisonTerm {
+	x, err := parser.ParseExpr(expr)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	var terms []comparisonTerm
+	var walk func(ast.Expr)
+	walk = func(x ast.Expr) {
+		x = ast.Unparen(x)
+		be, ok := x.(*ast.BinaryExpr)
+		_ = ok // @inco: ok, -return
+		if !(ok) {
+			return
+		}
+		if be.Op == token.LAND {
+			walk(be.X)
+			walk(be.Y)
+			return
+		}
+		op, operand, lit := be.Op, be.X, be.Y
+		if _, isConst := numericConstant(operand); isConst {
+			op, operand, lit = mirror(op), be.Y, be.X
+		}
+		v, isConst := numericConstant(lit)
+		_ = isConst // @inco: isConst && op != token.ILLEGAL, -return
+		if !(isConst && op != token.ILLEGAL) {
+			return
+		}
+		if _, bothConst := numericConstant(operand); bothConst {
+			return
+		}
+		switch op {
+		case token.GTR, token.GEQ, token.LSS, token.LEQ, token.EQL, token.NEQ:
+			s := types.ExprString(operand)
+			terms = append(terms, comparisonTerm{operand: s, idents: exprIdents(s), op: op,
+				bound: &bound{value: v, text: types.ExprString(be)}})
+		}
+	}
+	walk(x)
+	return terms
+}
+
+// numericConstant returns the value of an integer or floating-point
+// literal, optionally signed and parenthesized.
+func numericConstant(x ast.Expr) (float64, bool) {
+	x = ast.Unparen(x)
+	sign := 1.0
+	if u, ok := x.(*ast.UnaryExpr); ok && (u.Op == token.SUB || u.Op == token.ADD) {
+		if u.Op == token.SUB {
+			sign = -1
+		}
+		x = ast.Unparen(u.X)
+	}
+	lit, ok := x.(*ast.BasicLit)
+	_ = ok // @inco: ok && (lit.Kind == token.INT || lit.Kind == token.FLOAT), -return(0, false)
+	if !(ok && (lit.Kind == token.INT || lit.Kind == token.FLOAT)) {
+		return 0, false
+	}
+	v, _ := constant.Float64Val(constant.ToFloat(constant.MakeFromLiteral(lit.Value, lit.Kind, 0)))
+	return sign * v, true
+}
+
+// mirror returns the comparison with its operands swapped: < for >.
+func mirror(op token.Token) token.Token {
+	switch op {
+	case token.GTR:
+		return token.LSS
+	case token.GEQ:
+		return token.LEQ
+	case token.LSS:
+		return token.GTR
+	case token.LEQ:
+		return token.GEQ
+	case token.EQL, token.NEQ:
+		return op
+	}
+	return token.ILLEGAL
+}
+
+// innermostBlock returns the innermost block, case clause or select
+// clause inside a function body containing line, or nil outside function
+// bodies.
+func innermostBlock(f *ast.File, fset *token.FileSet, line int) ast.Node {
+	var block ast.Node
+	within := func(n ast.Node) bool {
+		return srcLine(fset, n.Pos()) <= line && line <= srcLine(fset, n.End())
+	}
+	inBody := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			inBody = inBody || (n.Body != nil && within(n.Body))
+		case *ast.FuncLit:
+			inBody = inBody || within(n.Body)
+		case *ast.BlockStmt, *ast.CaseClause, *ast.CommClause:
+			if inBody && within(n) {
+				block = n
+			}
+		}
+		return true
+	})
+	return block
+}
+
+// assignmentLines returns, for each identifier assigned in block, the
+// lines it is assigned on: assignments, ++/--, range variables and
+// address-taking, which may hide an assignment.
+func assignmentLines(block ast.Node, fset *token.FileSet) map[string][]int {
+	assigned := make(map[string][]int)
+	mark := func(x ast.Expr, pos token.Pos) {
+		for _, name := range exprIdents(types.ExprString(x)) {
+			assigned[name] = append(assigned[name], srcLine(fset, pos))
+		}
+	}
+	ast.Inspect(block, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, x := range n.Lhs {
+				mark(x, n.Pos())
+			}
+		case *ast.IncDecStmt:
+			mark(n.X, n.Pos())
+		case *ast.RangeStmt:
+			if n.Key != nil {
+				mark(n.Key, n.Pos())
+			}
+			if n.Value != nil {
+				mark(n.Value, n.Pos())
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				mark(n.X, n.Pos())
+			}
+		}
+		return true
+	})
+	return assigned
+}
+
+// assignedBetween reports whether any of idents is assigned on a line in
+// (from, to].
+func assignedBetween(assigned map[string][]int, idents []string, from, to int) bool {
+	for _, name := range idents {
+		for _, line := range assigned[name] {
+			if from < line && line <= to {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Satisfiability of consecutive contracts
+// ---------------------------------------------------------------------------
+
+const satisfySrc = `package main
+
+func Range(x, y, n int, f float64) {
+	// @inco: x > 10
+	// @inco: x < 5
+	// @inco: y != 0 && n >= 0
+	// @inco: y == 0
+	// @inco: 0.5 < f
+	// @inco: f <= 0.5
+}
+
+func Reassigned(x int) {
+	// @inco: x > 10
+	x = 0
+	// @inco: x < 5
+}
+
+func Blocks(x int) {
+	// @inco: x > 10
+	if x > 0 {
+		// @inco: x < 5
+	}
+}
+
+func Conditional(x int, debug bool) {
+	// @inco: x > 10
+	// @inco: if(debug) x < 5
+	// @inco: x >= 11 && x <= 20
+}
+`
+
+func TestVet_Unsatisfiable(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": satisfySrc})
+	diags := Vet(dir)
+	want := []struct {
+		line    int
+		message string
+	}{
+		{5, "contract x < 5 contradicts x > 10 (line 4)"},
+		{7, "contract y == 0 contradicts y != 0 (line 6)"},
+		{9, "contract f <= 0.5 contradicts 0.5 < f (line 8)"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		if d := diags[i]; d.Line != w.line || !strings.Contains(d.Message, w.message) {
+			t.Errorf("diag %d = %v, want line %d containing %q", i, d, w.line, w.message)
+		}
+	}
+}
+
+func TestInterval_Add(t *testing.T) {
+	tests := []struct {
+		exprs []string
+		want  bool // contradiction on the last expression
+	}{
+		{[]string{"x > 1", "x < 3"}, false},
+		{[]string{"x >= 2", "x <= 2"}, false},
+		{[]string{"x > 2", "x <= 2"}, true},
+		{[]string{"x >= 2", "x <= 2", "x != 2"}, true},
+		{[]string{"x == 3", "x > 3"}, true},
+		{[]string{"-1 > x", "x == -1"}, true},
+		{[]string{"len(s) > 0", "len(s) == 0"}, true},
+		{[]string{"x > 1", "y < 0"}, false},
+	}
+	for _, tt := range tests {
+		known := make(map[string]*interval)
+		var got bool
+		for _, e := range tt.exprs {
+			for _, term := range comparisonTerms(e) {
+				if known[term.operand] == nil {
+					known[term.operand] = &interval{}
+				}
+				got = known[term.operand].add(term.op, term.bound) != nil
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%q: contradiction = %v, want %v", tt.exprs, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package inco
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Project scaffolding (inco init)
+// ---------------------------------------------------------------------------
+
+// InitOptions selects the optional parts of Init.
+type InitOptions struct {
+	CacheDir string // cache directory to keep out of git (default Root/.inco_cache)
+	Hook     bool   // install a git pre-commit hook running inco vet
+	CI       bool   // write a GitHub Actions workflow
+}
+
+// incoignoreTemplate is the .incoignore Init writes: inco reads no other
+// project file, its settings come from flags and the environment.
+const incoignoreTemplate = `# .incoignore — files and directories inco gen and inco audit skip.
+# Patterns follow a simplified .gitignore syntax; a .incoignore in a
+# subdirectory applies to that subtree only.
+#
+#   generated/          a directory
+#   *_mock.go           files by name, anywhere
+#   example/*.inco.go   files under a path
+#
+# vendor/, testdata/ and hidden directories are always skipped.
+#
+# inco has no other configuration file: settings are flags or INCO_*
+# environment variables (INCO_TYPECHECK=1, INCO_PKGS=./api/..., ...).
+# 'inco doctor' lists every setting with its default.
+`
+
+// preCommitHook is the git pre-commit hook Init installs.
+const preCommitHook = `#!/bin/sh
+# Installed by inco init: refuse commits with malformed or unsatisfiable
+# directives. Bypass once with git commit --no-verify.
+exec inco vet
+`
+
+// ciWorkflow is the GitHub Actions workflow Init writes.
+const ciWorkflow = `name: inco
+
+on:
+  push:
+  pull_request:
+
+permissions:
+  contents: read
+
+jobs:
+  contracts:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+
+      - uses: actions/setup-go@v5
+        with:
+          go-version-file: go.mod
+
+      - name: Install inco
+        run: go install github.com/imnive-design/inco-go/cmd/inco@latest
+
+      - name: Check directives
+        run: inco vet .
+
+      - name: Test with contracts enforced
+        run: inco test ./...
+
+      - name: Contract coverage
+        run: inco audit .
+`
+
+// Init sets up inco in the project at root: it writes a commented
+// .incoignore, keeps the cache directory out of git through .gitignore
+// and, as opts asks, installs a pre-commit hook and a GitHub Actions
+// workflow. Existing files are kept; Init only appends to .gitignore. It
+// returns one line per step, e.g. "created .incoignore".
+func Init(root string, opts InitOptions) []string {
+	var steps []string
+	steps = append(steps, createFile(root, ".incoignore", incoignoreTemplate, 0o644))
+
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(root, ".inco_cache")
+	}
+	if rel, err := filepath.Rel(root, cacheDir); err == nil && filepath.IsLocal(rel) {
+		steps = append(steps, ignoreInGit(root, "/"+filepath.ToSlash(rel)+"/"))
+	}
+
+	if opts.Hook {
+		info, err := os.Stat(filepath.Join(root, ".git"))
+		_ = err // @inco: err == nil && info.IsDir(), -panic(fmt.Errorf("%s is not the top of a git repository, so there is nowhere to install the hook", root))
+		if !(err == nil && info.IsDir()) {
+			panic(fmt.Errorf("%s is not the top of a git repository, so there is nowhere to install the hook", root))
+		}
+		steps = append(steps, createFile(root, filepath.Join(".git", "hooks", "pre-commit"), preCommitHook, 0o755))
+	}
+	if opts.CI {
+		steps = append(steps, createFile(root, filepath.Join(".github", "workflows", "inco.yml"), ciWorkflow, 0o644))
+	}
+	return steps
+}
+
+// createFile writes content to root/rel unless the file exists, and
+// describes what it did.
+func createFile(root, rel, content string, perm os.FileMode) string {
+	path := filepath.Join(root, rel)
+	_, err := os.Stat(path)
+	_ = err // @inco: err != nil, -return("kept existing " + filepath.ToSlash(rel))
+	if !(err != nil) {
+		return "kept existing " + filepath.ToSlash(rel)
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	err = os.WriteFile(path, []byte(content), perm)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	return "created " + filepath.ToSlash(rel)
+}
+
+// ignoreInGit appends pattern to root/.gitignore unless a line already
+// names it (with or without its slashes), and describes what it did.
+func ignoreInGit(root, pattern string) string {
+	path := filepath.Join(root, ".gitignore")
+	data, err := os.ReadFile(path)
+	_ = err // @inco: err == nil || os.IsNotExist(err), -panic(err)
+	if !(err == nil || os.IsNotExist(err)) {
+		panic(err)
+	}
+	name := strings.Trim(pattern, "/")
+	ignored := slices.ContainsFunc(strings.Split(string(data), "\n"), func(l string) bool {
+		return strings.Trim(strings.TrimSpace(l), "/") == name
+	})
+	_ = ignored // @inco: !ignored, -return(".gitignore already ignores " + pattern)
+	if !(!ignored) {
+		return ".gitignore already ignores " + pattern
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	err = os.WriteFile(path, append(data, pattern+"\n"...), 0o644)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	return "added " + pattern + " to .gitignore"
+}
@@ -0,0 +1,62 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInit(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		".gitignore": "bin/",
+		"main.go":    "package main\n\nfunc main() {}\n",
+	})
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := Init(dir, InitOptions{Hook: true, CI: true})
+	want := []string{
+		"created .incoignore",
+		"added /.inco_cache/ to .gitignore",
+		"created .git/hooks/pre-commit",
+		"created .github/workflows/inco.yml",
+	}
+	if !slices.Equal(steps, want) {
+		t.Errorf("steps = %q, want %q", steps, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".gitignore")); string(data) != "bin/\n/.inco_cache/\n" {
+		t.Errorf(".gitignore = %q", data)
+	}
+	if info, err := os.Stat(filepath.Join(dir, ".git", "hooks", "pre-commit")); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("pre-commit hook missing or not executable: %v", err)
+	}
+	// The template parses as an ignore file that ignores nothing.
+	if ig := NewIgnoreTree(dir); ig.Match(filepath.Join(dir, "main.go"), false) {
+		t.Error("the .incoignore template ignores main.go")
+	}
+
+	// A second run keeps everything.
+	os.WriteFile(filepath.Join(dir, ".incoignore"), []byte("gen/\n"), 0o644)
+	steps = Init(dir, InitOptions{Hook: true, CI: true})
+	for _, step := range steps {
+		if !strings.HasPrefix(step, "kept existing") && !strings.Contains(step, "already ignores") {
+			t.Errorf("second run: %s", step)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".incoignore")); string(data) != "gen/\n" {
+		t.Errorf(".incoignore overwritten: %q", data)
+	}
+}
+
+func TestInit_HookNeedsGit(t *testing.T) {
+	dir := t.TempDir()
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(error).Error(), "not the top of a git repository") {
+			t.Errorf("recover() = %v", r)
+		}
+	}()
+	Init(dir, InitOptions{Hook: true})
+}
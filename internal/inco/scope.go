@@ -0,0 +1,199 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// Shadowed parameters: contracts naming a parameter a local redeclares
+// ---------------------------------------------------------------------------
+
+// shadowing is a parameter that a local declaration hides at a directive.
+type shadowing struct {
+	name  string
+	param int // line of the parameter or receiver
+	local int // line of the local declaration in scope at the directive
+}
+
+func (s shadowing) String() string {
+	return fmt.Sprintf("%s refers to the local %s declared on line %d, not the parameter on line %d; rename the local or move the contract above it",
+		s.name, s.name, s.local, s.param)
+}
+
+// shadowedParams returns the identifiers of exprs that name a parameter
+// of a function enclosing pos, but that a declaration in a block nested
+// in the function's body, and in scope at pos, redeclares. The check
+// injected at pos would then read the local, which is rarely what a
+// contract written against the signature means. A := at the top of the
+// body assigns the parameter rather than declaring a new variable, so it
+// does not count. Named results are left out: err := inside a block is
+// the usual way to handle an error there.
+func shadowedParams(f *ast.File, fset *token.FileSet, pos token.Pos, exprs ...string) []shadowing {
+	var names []string
+	for _, s := range exprs {
+		names = append(names, exprIdents(s)...)
+	}
+	var out []shadowing
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		fn, param := paramScope(f, pos, name)
+		_ = fn // @inco: fn != nil, -continue
+		if !(fn != nil) {
+			continue
+		}
+		if local := localDecl(fn, pos, name); local != nil {
+			out = append(out, shadowing{name: name, param: srcLine(fset, param.Pos()), local: srcLine(fset, local.Pos())})
+		}
+	}
+	return out
+}
+
+// paramScope returns the body of the innermost function enclosing pos with
+// a parameter or receiver called name, and that identifier.
+func paramScope(f *ast.File, pos token.Pos, name string) (body *ast.BlockStmt, param *ast.Ident) {
+	find := func(lists ...*ast.FieldList) *ast.Ident {
+		for _, fl := range lists {
+			if fl == nil {
+				continue
+			}
+			for _, field := range fl.List {
+				for _, n := range field.Names {
+					if n.Name == name {
+						return n
+					}
+				}
+			}
+		}
+		return nil
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		var b *ast.BlockStmt
+		var id *ast.Ident
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			b, id = fn.Body, find(fn.Recv, fn.Type.Params)
+		case *ast.FuncLit:
+			b, id = fn.Body, find(fn.Type.Params)
+		default:
+			return true
+		}
+		_ = b // @inco: b != nil && b.Pos() <= pos && pos < b.End(), -return(false)
+		if !(b != nil && b.Pos() <= pos && pos < b.End()) {
+			return false
+		}
+		if id != nil {
+			body, param = b, id // later matches are nested deeper
+		}
+		return true
+	})
+	return body, param
+}
+
+// localDecl returns the innermost declaration of name in a scope nested in
+// body that is in scope at pos, or nil when there is none.
+func localDecl(body *ast.BlockStmt, pos token.Pos, name string) *ast.Ident {
+	var found *ast.Ident
+	// declares records the identifiers of idents called name declared
+	// before pos.
+	declares := func(idents ...*ast.Ident) {
+		for _, id := range idents {
+			if id != nil && id.Name == name && id.Pos() < pos {
+				found = id
+			}
+		}
+	}
+	defined := func(s ast.Stmt) {
+		if a, ok := s.(*ast.AssignStmt); ok && a.Tok == token.DEFINE {
+			for _, lhs := range a.Lhs {
+				id, _ := lhs.(*ast.Ident)
+				declares(id)
+			}
+		}
+	}
+	stmts := func(list []ast.Stmt) {
+		for _, s := range list {
+			defined(s)
+			if d, ok := s.(*ast.DeclStmt); ok {
+				for _, spec := range d.Decl.(*ast.GenDecl).Specs {
+					if vs, ok := spec.(*ast.ValueSpec); ok {
+						declares(vs.Names...)
+					}
+				}
+			}
+		}
+	}
+	fields := func(fl *ast.FieldList) {
+		if fl != nil {
+			for _, field := range fl.List {
+				declares(field.Names...)
+			}
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		_ = n // @inco: n != nil && n.Pos() <= pos && pos < n.End(), -return(false)
+		if !(n != nil && n.Pos() <= pos && pos < n.End()) {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			if n != body {
+				stmts(n.List)
+			}
+		case *ast.CaseClause:
+			stmts(n.Body)
+		case *ast.CommClause:
+			defined(n.Comm)
+			stmts(n.Body)
+		case *ast.IfStmt:
+			defined(n.Init)
+		case *ast.SwitchStmt:
+			defined(n.Init)
+		case *ast.TypeSwitchStmt:
+			defined(n.Init)
+			defined(n.Assign)
+		case *ast.ForStmt:
+			defined(n.Init)
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE && n.Body.Pos() <= pos {
+				k, _ := n.Key.(*ast.Ident)
+				v, _ := n.Value.(*ast.Ident)
+				declares(k, v)
+			}
+		case *ast.FuncLit:
+			fields(n.Type.Params)
+			fields(n.Type.Results)
+		}
+		return true
+	})
+	return found
+}
+
+// shadowWarnings warns about the checks of directives, keyed by line, that
+// name a parameter a local declaration hides (see shadowedParams).
+func shadowWarnings(f *ast.File, fset *token.FileSet, relPath string, directives map[int][]*Directive) []Warning {
+	var out []Warning
+	seen := make(map[int]bool) // lines whose directives are checked
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			line := srcLine(fset, c.Pos())
+			_ = line // @inco: !seen[line] && len(directives[line]) > 0 && len(ParseDirectives(c.Text)) > 0, -continue
+			if !(!seen[line] && len(directives[line]) > 0 && len(ParseDirectives(c.Text)) > 0) {
+				continue
+			}
+			seen[line] = true
+			for _, d := range directives[line] {
+				_ = d // @inco: d.Kind.checksExpr(), -continue
+				if !(d.Kind.checksExpr()) {
+					continue
+				}
+				for _, s := range shadowedParams(f, fset, c.Pos(), d.Cond, d.Expr) {
+					out = append(out, Warning{Path: relPath, Line: line, Message: s.String()})
+				}
+			}
+		}
+	}
+	return out
+}
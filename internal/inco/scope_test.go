@@ -0,0 +1,60 @@
+package inco
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Shadowed parameters
+// ---------------------------------------------------------------------------
+
+const shadowSrc = `package main
+
+func Scale(n int, items []int) (out []int) {
+	// @inco: n > 0
+	for _, n := range items {
+		// @inco: n >= 0
+		out = append(out, n)
+	}
+	if len(items) > 0 {
+		n := len(items)
+		_ = n // @inco: n < 100
+	}
+	items, err := split(items)
+	_ = err // @inco: err == nil && len(items) > 0
+	func(items []int) {
+		// @inco: items != nil
+	}(nil)
+	return out
+}
+
+func split(xs []int) ([]int, error) { return xs, nil }
+`
+
+func TestVet_ShadowedParams(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": shadowSrc})
+	var got []string
+	for _, d := range Vet(dir) {
+		got = append(got, fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message))
+	}
+	want := []string{
+		"6:6: n refers to the local n declared on line 5, not the parameter on line 3; rename the local or move the contract above it",
+		"11:12: n refers to the local n declared on line 10, not the parameter on line 3; rename the local or move the contract above it",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEngine_WarnsShadowedParams(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": shadowSrc})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	if len(e.Warnings) != 2 || e.Warnings[0].Line != 6 || e.Warnings[1].Line != 11 ||
+		!strings.Contains(e.Warnings[0].Message, "refers to the local n declared on line 5") {
+		t.Errorf("Warnings = %q, want the contracts on lines 6 and 11", e.Warnings)
+	}
+}
@@ -0,0 +1,276 @@
+package inco
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ---------------------------------------------------------------------------
+// JSON-RPC types
+// ---------------------------------------------------------------------------
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    *rpcErrorData `json:"data,omitempty"`
+}
+
+// rpcErrorData tells which category of engine failure an internal error
+// is (see ErrorCategory).
+type rpcErrorData struct {
+	Category string `json:"category"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// positionParams are the parameters of explain and suggest.
+type positionParams struct {
+	Path string `json:"path"` // absolute or relative to the root
+	Line int    `json:"line"` // 1-based
+}
+
+// GenerateResult is the result of the generate method.
+type GenerateResult struct {
+	Overlay  string    `json:"overlay"` // path of overlay.json; "" when no file has directives
+	Files    int       `json:"files"`   // source files mapped in the overlay
+	Warnings []Warning `json:"warnings"`
+}
+
+// ---------------------------------------------------------------------------
+// Server
+// ---------------------------------------------------------------------------
+
+// Server answers JSON-RPC 2.0 requests for one project, so editors and
+// daemons can drive inco without starting a process per request. The
+// engine, and with it the package graph used to resolve imports and the
+// cache of generated shadows, lives as long as the server.
+//
+// Requests and responses are single-line JSON objects separated by
+// newlines. Methods:
+//
+//	generate {}                  → GenerateResult
+//	vet      {"fix": bool}       → []Diagnostic (those remaining after -fix)
+//	audit    {}                  → AuditResult
+//	explain  {"path", "line"}    → []Annotation for the directives on line
+//	suggest  {"path", "line"}    → []Suggestion for the function enclosing line
+//	shutdown                     → null; the server stops reading
+type Server struct {
+	Engine *Engine // configured engine; generate calls its Run
+}
+
+// NewServer returns a server for e. e should be Quiet: the server's
+// output stream carries responses only.
+func NewServer(e *Engine) *Server {
+	_ = e // @inco: e != nil, -panic("NewServer: nil engine")
+	if !(e != nil) {
+		panic("NewServer: nil engine")
+	}
+	return &Server{Engine: e}
+}
+
+// Serve reads requests from r and writes responses to w until r is
+// exhausted or a shutdown request arrives. Notifications (requests without
+// an id) get no response.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			resp, stop := s.handle(line)
+			if resp != nil {
+				data, merr := marshalRPC(resp)
+				_ = merr // @inco: merr == nil, -return(merr)
+				if !(merr == nil) {
+					return merr
+				}
+				_, werr := w.Write(data)
+				_ = werr // @inco: werr == nil, -return(werr)
+				if !(werr == nil) {
+					return werr
+				}
+			}
+			if stop {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		_ = err // @inco: err == nil, -return(err)
+		if !(err == nil) {
+			return err
+		}
+	}
+}
+
+// handle answers one request line. It returns a nil response for
+// notifications and stop for shutdown.
+func (s *Server) handle(line []byte) (resp *rpcResponse, stop bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+			Error: &rpcError{Code: rpcParseError, Message: err.Error()}}, false
+	}
+	resp = &rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if len(req.ID) == 0 {
+		resp = nil
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if resp != nil {
+			resp.Error = &rpcError{Code: rpcInvalidRequest, Message: `request must have "jsonrpc": "2.0" and a method`}
+		}
+		return resp, false
+	}
+
+	result, err := s.call(req.Method, req.Params)
+	if resp == nil {
+		return nil, req.Method == "shutdown"
+	}
+	var rerr *rpcError
+	switch {
+	case errors.As(err, &rerr):
+		resp.Error = rerr
+	case err != nil:
+		resp.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		if category := ErrorCategory(err); category != "" {
+			resp.Error.Data = &rpcErrorData{Category: category}
+		}
+	default:
+		data, merr := marshalRPC(result)
+		if merr != nil {
+			resp.Error = &rpcError{Code: rpcInternalError, Message: merr.Error()}
+		} else {
+			resp.Result = bytes.TrimSuffix(data, []byte("\n"))
+		}
+	}
+	return resp, req.Method == "shutdown"
+}
+
+// marshalRPC encodes v as one line of JSON, ending in a newline. Contract
+// expressions are full of < > &, so HTML escaping is off.
+func marshalRPC(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	return buf.Bytes(), err
+}
+
+// call dispatches method. Panics from the engine, which reports errors by
+// panicking, become internal errors.
+func (s *Server) call(method string, params json.RawMessage) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
+		}
+	}()
+
+	// decode unmarshals params into v; absent params leave v unchanged.
+	decode := func(v any) error {
+		if len(params) == 0 || string(params) == "null" {
+			return nil
+		}
+		if err := json.Unmarshal(params, v); err != nil {
+			return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	e := s.Engine
+	switch method {
+	case "generate":
+		e.Run()
+		r := GenerateResult{Files: len(e.Overlay.Replace), Warnings: e.Warnings}
+		if r.Files > 0 {
+			r.Overlay = filepath.Join(e.CacheDir, "overlay.json")
+		}
+		if r.Warnings == nil {
+			r.Warnings = []Warning{}
+		}
+		return r, nil
+	case "vet":
+		var p struct {
+			Fix bool `json:"fix"`
+		}
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		diags := Vet(e.Root)
+		if p.Fix {
+			diags = ApplyFixes(diags)
+		}
+		return append([]Diagnostic{}, diags...), nil
+	case "audit":
+		return Audit(e.Root, e.Limits), nil
+	case "explain", "suggest":
+		var p positionParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		if p.Path == "" || p.Line < 1 {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: `"path" and a positive "line" are required`}
+		}
+		path := p.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(e.Root, path)
+		}
+		if method == "explain" {
+			return append([]Annotation{}, e.Explain(path, p.Line)...), nil
+		}
+		return append([]Suggestion{}, Suggest(path, p.Line)...), nil
+	case "shutdown":
+		return nil, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", method)}
+}
+
+// Explain returns the annotations of the directives on line of the file
+// at path, as written to annotations.json.
+func (e *Engine) Explain(path string, line int) []Annotation {
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	joinContinuations(f, fset)
+	standalone, inline := collectDirectives(f, fset, splitLines(src))
+	rel := e.relPath(path)
+	return e.annotate(rel, standalone, inline)[fmt.Sprintf("%s:%d", filepath.ToSlash(rel), line)]
+}
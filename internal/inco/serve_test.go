@@ -0,0 +1,159 @@
+package inco
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// JSON-RPC server
+// ---------------------------------------------------------------------------
+
+const serveSrc = `package main
+
+type Config struct{ Name string }
+
+func Load(cfg *Config, opts map[string]string, n int) {
+	// @inco: opts != nil
+	_ = n // @inco: n > 0, -return
+}
+`
+
+func TestServer_Serve(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": serveSrc})
+	e := NewEngine(dir)
+	e.Quiet = true
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"generate"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"explain","params":{"path":"main.go","line":7}}`,
+		`{"jsonrpc":"2.0","id":"s","method":"suggest","params":{"path":"main.go","line":6}}`,
+		`{"jsonrpc":"2.0","method":"vet"}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":3,"method":"explain","params":{"line":0}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"frobnicate"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":6,"method":"audit"}`,
+	}, "\n")
+	var out strings.Builder
+	if err := NewServer(e).Serve(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"overlay":"` + filepath.Join(dir, ".inco_cache", "overlay.json") + `","files":1,"warnings":[]}}`,
+		`{"jsonrpc":"2.0","id":2,"result":[{"kind":"require","expr":"n > 0","on_violation":"returns","enabled":true,"text":"Precondition: requires n > 0; otherwise returns."}]}`,
+		`{"jsonrpc":"2.0","id":"s","result":[{"directive":"// @inco: cfg != nil","reason":"cfg is a pointer and may be nil","edit":{"offset":104,"end":104,"new_text":"\n\t// @inco: cfg != nil"}}]}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid character 'o' in literal null (expecting 'u')"}}`,
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"\"path\" and a positive \"line\" are required"}}`,
+		`{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"method \"frobnicate\" not found"}}`,
+		`{"jsonrpc":"2.0","id":5,"result":null}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d responses, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("response %d:\n got %s\nwant %s", i, lines[i], want[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".inco_cache", "overlay.json")); err != nil {
+		t.Errorf("generate did not write the overlay: %v", err)
+	}
+}
+
+func TestServer_EngineErrors(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Do(x int) {
+	// @inco: x >
+}
+`})
+	e := NewEngine(dir)
+	e.Quiet = true
+	var out strings.Builder
+	err := NewServer(e).Serve(strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"generate"}`), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp struct {
+		Error *rpcError `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != rpcInternalError || !strings.Contains(resp.Error.Message, "invalid shadow") {
+		t.Errorf("response = %s, want an internal error for the invalid shadow", out.String())
+	}
+	if resp.Error != nil && (resp.Error.Data == nil || resp.Error.Data.Category != "invalid-directive") {
+		t.Errorf("response = %s, want the invalid-directive category", out.String())
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Do(p *int, m map[string]int, f func(), s []int, err error, _ *int) {
+	// @inco: m != nil
+	go func(ch chan int) {
+		_ = ch
+	}(nil)
+}
+`})
+	path := filepath.Join(dir, "main.go")
+	var got []string
+	for _, s := range Suggest(path, 4) {
+		got = append(got, s.Directive)
+	}
+	if want := "// @inco: p != nil|// @inco: f != nil"; strings.Join(got, "|") != want {
+		t.Errorf("Suggest(Do) = %q, want %q", got, want)
+	}
+	if got := Suggest(path, 6); len(got) != 1 || got[0].Directive != "// @inco: ch != nil" {
+		t.Errorf("Suggest(func literal) = %+v, want ch != nil", got)
+	}
+	if got := Suggest(path, 1); got != nil {
+		t.Errorf("Suggest outside functions = %+v, want nil", got)
+	}
+}
+
+func TestSuggest_LiftChecks(t *testing.T) {
+	src := `package main
+
+func Open(cfg *Config, name string, n int) (*File, error) {
+	if cfg == nil || name == "" {
+		return nil, errInvalid
+	}
+	if !(n > 0) {
+		panic("n must be positive")
+	}
+	if n > 10 {
+		n = 10
+	}
+	return open(cfg, name, n)
+}
+`
+	dir := setupDir(t, map[string]string{"main.go": src})
+	got := Suggest(filepath.Join(dir, "main.go"), 4)
+	want := []string{
+		"// @inco: cfg != nil, -return(nil, errInvalid)\n// @inco: name != \"\", -return(nil, errInvalid)",
+		"// @inco: n > 0, -panic(\"n must be positive\")",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d suggestions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Directive != w {
+			t.Errorf("suggestion %d = %q, want %q", i, got[i].Directive, w)
+		}
+	}
+
+	// Applying the first edit replaces the if statement, keeping the indentation.
+	e := got[0].Edit
+	lifted := src[:e.Offset] + e.NewText + src[e.End:]
+	if !strings.Contains(lifted, "{\n\t// @inco: cfg != nil, -return(nil, errInvalid)\n\t// @inco: name != \"\", -return(nil, errInvalid)\n\tif !(n > 0)") {
+		t.Errorf("after the edit:\n%s", lifted)
+	}
+}
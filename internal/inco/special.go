@@ -0,0 +1,104 @@
+package inco
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"strconv"
+)
+
+// ---------------------------------------------------------------------------
+// Special functions: init and TestMain
+// ---------------------------------------------------------------------------
+
+// inInit reports whether the innermost function enclosing line is a
+// package init function. Function literals declared in init do not count:
+// they may run long after initialization.
+func inInit(f *ast.File, fset *token.FileSet, line int) bool {
+	_, body := enclosingFunc(f, fset, line)
+	_ = body // @inco: body != nil, -return(false)
+	if !(body != nil) {
+		return false
+	}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "init" && fn.Body == body {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveInit labels the panic of d, a check on line of an init function,
+// as a violation during package initialization. The program stops before
+// main, often before it logs anything, so the message has to say where
+// it stopped:
+//
+//	inco violation in init: len(routes) > 0 (at api/routes.go:12)
+//	inco violation in init (at api/routes.go:12): no routes registered
+//
+// A message that is not a string literal is formatted with %v after the
+// label.
+func (e *Engine) resolveInit(d *Directive, f *ast.File, fset *token.FileSet, path string, line int) *Directive {
+	_ = d // @inco: d.Kind.checksExpr() && d.Action == ActionPanic && inInit(f, fset, line), -return(d)
+	if !(d.Kind.checksExpr() && d.Action == ActionPanic && inInit(f, fset, line)) {
+		return d
+	}
+	resolved := *d
+	label := fmt.Sprintf("inco violation in init (at %s:%d)", e.relPath(path), line)
+	if len(d.ActionArgs) == 0 {
+		resolved.ActionArgs = []string{strconv.Quote(fmt.Sprintf("inco violation in init: %s (at %s:%d)", d.Expr, e.relPath(path), line))}
+		return &resolved
+	}
+	if msg, err := strconv.Unquote(d.ActionArgs[0]); err == nil {
+		resolved.ActionArgs = []string{strconv.Quote(label + ": " + msg)}
+		return &resolved
+	}
+	resolved.ActionArgs = []string{fmt.Sprintf("fmt.Sprintf(\"%%s: %%v\", %q, %s)", label, d.ActionArgs[0])}
+	return &resolved
+}
+
+// testMainFiles returns the test files under root, with vendor
+// directories when vendor is set, that declare TestMain: the files
+// Engine.Tests adds to those it instruments.
+func testMainFiles(root string, vendor bool) []string {
+	var paths []string
+	walkTestFiles(root, vendor, func(path string) error {
+		src, err := os.ReadFile(path)
+		if err == nil && bytes.Contains(src, []byte("func TestMain(")) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths
+}
+
+// testMainBody returns the body of the TestMain function f declares, or
+// nil when there is none.
+func testMainBody(f *ast.File) *ast.BlockStmt {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "TestMain" {
+			return fn.Body
+		}
+	}
+	return nil
+}
+
+// keepTestMain deletes from the directive maps of a test file, keyed by
+// line, the directives outside its TestMain function: in a test file
+// only TestMain is instrumented, as the setup every test of the package
+// depends on.
+func keepTestMain(f *ast.File, fset *token.FileSet, maps ...map[int][]*Directive) {
+	from, to := 0, -1
+	if body := testMainBody(f); body != nil {
+		from, to = srcLine(fset, body.Pos()), srcLine(fset, body.End())
+	}
+	for _, m := range maps {
+		for line := range m {
+			if line < from || line > to {
+				delete(m, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEngine_InitViolations(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"main.go": `package main
+
+var routes []string
+
+type limitError struct{ n int }
+
+func init() {
+	// @inco: len(routes) > 0
+	// @inco: len(routes) < 10, -panic("too many routes")
+	// @inco: len(routes) != 3, -panic(limitError{len(routes)})
+	// @inco: len(routes) != 4, -return
+	go func() {
+		// @inco: len(routes) > 1
+	}()
+}
+
+func main() {
+	// @inco: len(routes) > 2
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Typecheck = true // the labeled message needs fmt
+	e.Run()
+	shadow := readShadow(t, e)
+	for _, want := range []string{
+		`panic("inco violation in init: len(routes) > 0 (at main.go:8)")`,
+		`panic("inco violation in init (at main.go:9): too many routes")`,
+		`panic(fmt.Sprintf("%s: %v", "inco violation in init (at main.go:10)", limitError{len(routes)}))`,
+		`panic("inco violation: len(routes) > 1 (at main.go:13)")`, // runs after init
+		`panic("inco violation: len(routes) > 2 (at main.go:18)")`,
+	} {
+		if !strings.Contains(shadow, want) {
+			t.Errorf("shadow lacks %s:\n%s", want, shadow)
+		}
+	}
+	if !strings.Contains(shadow, `"fmt"`) {
+		t.Errorf("shadow does not import fmt:\n%s", shadow)
+	}
+
+	// The scanner recognises the default message without a trace.
+	if m := defaultMsgRe.FindStringSubmatch("inco violation in init: len(routes) > 0 (at main.go:8)"); m == nil || m[1] != "main.go" {
+		t.Errorf("defaultMsgRe does not match an init violation: %v", m)
+	}
+}
+
+func TestEngine_TestMain(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"lib.go": "package lib\n\nfunc F(n int) {\n\t// @inco: n > 0\n}\n",
+		"lib_test.go": `package lib
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	dsn := os.Getenv("DSN")
+	// @inco: dsn != "", -panic("DSN must be set")
+	os.Exit(m.Run())
+}
+
+func TestF(t *testing.T) {
+	// @inco: t == nil
+}
+`,
+		"other_test.go": "package lib\n\nimport \"testing\"\n\nfunc TestG(t *testing.T) {\n\t// @inco: t == nil\n}\n",
+	})
+	instrumented := func(e *Engine) []string {
+		var got []string
+		for p := range e.Overlay.Replace {
+			got = append(got, filepath.Base(p))
+		}
+		slices.Sort(got)
+		return got
+	}
+
+	e := NewEngine(dir)
+	e.Quiet = true
+	e.Run()
+	if got := instrumented(e); !slices.Equal(got, []string{"lib.go"}) {
+		t.Errorf("without Tests, instrumented %v, want [lib.go]", got)
+	}
+
+	e.Tests = true
+	e.Run()
+	if got := instrumented(e); !slices.Equal(got, []string{"lib.go", "lib_test.go"}) {
+		t.Fatalf("with Tests, instrumented %v, want [lib.go lib_test.go]", got)
+	}
+	data, err := os.ReadFile(e.Overlay.Replace[filepath.Join(dir, "lib_test.go")])
+	if err != nil {
+		t.Fatal(err)
+	}
+	shadow := string(data)
+	if !strings.Contains(shadow, `panic("DSN must be set")`) {
+		t.Errorf("TestMain's contract is not injected:\n%s", shadow)
+	}
+	if strings.Count(shadow, "if !(") != 1 {
+		t.Errorf("only TestMain should be instrumented:\n%s", shadow)
+	}
+}
@@ -0,0 +1,103 @@
+package inco
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ---------------------------------------------------------------------------
+// Committed overlays: inco gen -check
+// ---------------------------------------------------------------------------
+
+// StaleShadows compares the overlay committed in the cache directory
+// committed with one freshly generated into fresh, both for the sources
+// under root, and describes each difference, sorted by source path:
+//
+//	main.go: shadow out of date
+//	api/new.go: not in the committed overlay
+//	old.go: in the committed overlay but no longer generated
+//
+// Sources are compared by their path relative to root and shadows by
+// name and content. A cache committed from another checkout compares
+// equal when its overlay was rebased to root (see overlay.RebasePaths)
+// and its shadows were generated with -trimpath. A missing committed
+// overlay.json is a difference too.
+func StaleShadows(root, committed, fresh string) ([]string, error) {
+	want, err := readOverlay(root, fresh)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(committed, "overlay.json")); os.IsNotExist(err) {
+		return []string{filepath.Join(committed, "overlay.json") + ": missing"}, nil
+	}
+	got, err := readOverlay(root, committed)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+
+	rels := slices.Collect(maps.Keys(want))
+	for rel := range got {
+		if _, ok := want[rel]; !ok {
+			rels = append(rels, rel)
+		}
+	}
+	slices.Sort(rels)
+	var diffs []string
+	for _, rel := range rels {
+		w, inWant := want[rel]
+		g, inGot := got[rel]
+		switch {
+		case !inGot:
+			diffs = append(diffs, rel+": not in the committed overlay")
+		case !inWant:
+			diffs = append(diffs, rel+": in the committed overlay but no longer generated")
+		case filepath.Base(w) != filepath.Base(g):
+			diffs = append(diffs, rel+": shadow out of date")
+		default:
+			wantData, err := os.ReadFile(w)
+			_ = err // @inco: err == nil, -return(nil, err)
+			if !(err == nil) {
+				return nil, err
+			}
+			gotData, err := os.ReadFile(g)
+			if err != nil || !bytes.Equal(wantData, gotData) {
+				diffs = append(diffs, rel+": shadow out of date")
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// readOverlay reads the overlay.json in cacheDir and returns its shadows
+// keyed by source path relative to root. Shadows are looked up in
+// cacheDir itself, where a relocated or copied cache keeps them.
+func readOverlay(root, cacheDir string) (map[string]string, error) {
+	path := filepath.Join(cacheDir, "overlay.json")
+	data, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	var ov Overlay
+	err = json.Unmarshal(data, &ov)
+	_ = err // @inco: err == nil, -return(nil, fmt.Errorf("%s is corrupt: %w", path, err))
+	if !(err == nil) {
+		return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	shadows := make(map[string]string, len(ov.Replace))
+	for src, shadow := range ov.Replace {
+		rel, err := filepath.Rel(root, src)
+		if err != nil {
+			rel = src
+		}
+		shadows[filepath.ToSlash(rel)] = filepath.Join(cacheDir, filepath.Base(shadow))
+	}
+	return shadows, nil
+}
@@ -0,0 +1,47 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Committed overlays
+// ---------------------------------------------------------------------------
+
+func TestStaleShadows(t *testing.T) {
+	guarded := "package main\n\nfunc Do(x int) {\n\t// @inco: x > 0\n\t_ = x\n}\n"
+	dir := setupDir(t, map[string]string{"main.go": guarded, "old.go": guarded})
+	committed := filepath.Join(dir, ".inco_cache")
+	NewEngine(dir).Run()
+
+	// fresh regenerates into a new directory, as inco gen -check does.
+	fresh := func() string {
+		e := NewEngine(dir)
+		e.CacheDir = t.TempDir()
+		e.Run()
+		return e.CacheDir
+	}
+	if diffs, err := StaleShadows(dir, committed, fresh()); err != nil || len(diffs) != 0 {
+		t.Fatalf("up to date: diffs = %q, err = %v", diffs, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte(strings.Replace(guarded, "x > 0", "x > 1", 1)), 0o644)
+	os.Remove(filepath.Join(dir, "old.go"))
+	os.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0o644)
+	diffs, err := StaleShadows(dir, committed, fresh())
+	want := []string{
+		"main.go: shadow out of date",
+		"new.go: not in the committed overlay",
+		"old.go: in the committed overlay but no longer generated",
+	}
+	if err != nil || strings.Join(diffs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("diffs = %q, err = %v\nwant %q", diffs, err, want)
+	}
+
+	if diffs, _ := StaleShadows(dir, t.TempDir(), fresh()); len(diffs) != 1 || !strings.HasSuffix(diffs[0], "overlay.json: missing") {
+		t.Errorf("without a committed overlay: %q", diffs)
+	}
+}
@@ -0,0 +1,248 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Contract suggestions
+// ---------------------------------------------------------------------------
+
+// Suggestion is a contract the function at a position could declare.
+type Suggestion struct {
+	Directive string   `json:"directive"` // e.g. "// @inco: cfg != nil"
+	Reason    string   `json:"reason"`
+	Edit      TextEdit `json:"edit"` // inserts Directive at the top of the body
+}
+
+// Suggest proposes preconditions for the innermost function enclosing
+// line of the file at path:
+//
+//   - the validation checks the body starts with, "if cond { return ... }"
+//     or "if cond { panic(...) }", lifted into directives that replace them
+//   - a nil check for every parameter of pointer, map, function, channel or
+//     interface type that no directive or lifted check mentions yet
+func Suggest(path string, line int) []Suggestion {
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	joinContinuations(f, fset)
+
+	ft, body := enclosingFunc(f, fset, line)
+	_ = body // @inco: body != nil, -return(nil)
+	if !(body != nil) {
+		return nil
+	}
+
+	// Identifiers already constrained by a directive in the function.
+	mentioned := make(map[string]bool)
+	standalone, inline := collectDirectives(f, fset, splitLines(src))
+	for _, m := range []map[int][]*Directive{standalone, inline} {
+		for l, ds := range m {
+			if l < srcLine(fset, body.Lbrace) || l > srcLine(fset, body.Rbrace) {
+				continue
+			}
+			for _, d := range ds {
+				for _, name := range exprIdents(d.Expr) {
+					mentioned[name] = true
+				}
+			}
+		}
+	}
+
+	lbrace := fset.PositionFor(body.Lbrace, false)
+	indent := extractIndent(splitLines(src)[lbrace.Line-1]) + "\t"
+	var out []Suggestion
+	for _, stmt := range body.List {
+		directives := liftCheck(stmt)
+		_ = directives // @inco: len(directives) > 0, -break
+		if !(len(directives) > 0) {
+			break
+		}
+		for _, text := range directives {
+			for _, name := range exprIdents(ParseDirective(text).Expr) {
+				mentioned[name] = true
+			}
+		}
+		out = append(out, Suggestion{
+			Directive: strings.Join(directives, "\n"),
+			Reason:    fmt.Sprintf("the check on line %d validates the inputs; as a directive it is documented and audited", srcLine(fset, stmt.Pos())),
+			Edit: TextEdit{
+				Offset:  fset.PositionFor(stmt.Pos(), false).Offset,
+				End:     fset.PositionFor(stmt.End(), false).Offset,
+				NewText: strings.Join(directives, "\n"+indent),
+			},
+		})
+	}
+	for _, field := range ft.Params.List {
+		kind := nillableKind(field.Type)
+		_ = kind // @inco: kind != "", -continue
+		if !(kind != "") {
+			continue
+		}
+		for _, name := range field.Names {
+			if name.Name == "_" || mentioned[name.Name] {
+				continue
+			}
+			directive := fmt.Sprintf("// @inco: %s != nil", name.Name)
+			out = append(out, Suggestion{
+				Directive: directive,
+				Reason:    fmt.Sprintf("%s is a %s and may be nil", name.Name, kind),
+				Edit:      TextEdit{Offset: lbrace.Offset + 1, End: lbrace.Offset + 1, NewText: "\n" + indent + directive},
+			})
+		}
+	}
+	return out
+}
+
+// liftCheck returns the directive comments equivalent to stmt when it is a
+// validation check, "if cond { return ... }" or "if cond { panic(x) }"
+// without init statement or else branch, or nil. A cond of the form
+// "a || b" yields a directive per term.
+func liftCheck(stmt ast.Stmt) []string {
+	ifs, ok := stmt.(*ast.IfStmt)
+	_ = ok // @inco: ok && ifs.Init == nil && ifs.Else == nil && len(ifs.Body.List) == 1, -return(nil)
+	if !(ok && ifs.Init == nil && ifs.Else == nil && len(ifs.Body.List) == 1) {
+		return nil
+	}
+	var action string
+	switch s := ifs.Body.List[0].(type) {
+	case *ast.ReturnStmt:
+		action = "-return"
+		if len(s.Results) > 0 {
+			args := make([]string, len(s.Results))
+			for i, r := range s.Results {
+				args[i] = types.ExprString(r)
+			}
+			action += "(" + strings.Join(args, ", ") + ")"
+		}
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 || types.ExprString(call.Fun) != "panic" {
+			return nil
+		}
+		action = "-panic(" + types.ExprString(call.Args[0]) + ")"
+	default:
+		return nil
+	}
+
+	var out []string
+	for _, term := range orTerms(ifs.Cond) {
+		text := "// @inco: " + negate(term) + ", " + action
+		_ = text // @inco: ParseDirective(text) != nil, -return(nil)
+		if !(ParseDirective(text) != nil) {
+			return nil
+		}
+		out = append(out, text)
+	}
+	return out
+}
+
+// orTerms flattens the top-level || of x.
+func orTerms(x ast.Expr) []ast.Expr {
+	x = ast.Unparen(x)
+	if be, ok := x.(*ast.BinaryExpr); ok && be.Op == token.LOR {
+		return append(orTerms(be.X), orTerms(be.Y)...)
+	}
+	return []ast.Expr{x}
+}
+
+// negate returns the Go expression for !x, without double negation or
+// parentheses around a comparison: "x == nil" → "x != nil".
+func negate(x ast.Expr) string {
+	x = ast.Unparen(x)
+	switch x := x.(type) {
+	case *ast.UnaryExpr:
+		if x.Op == token.NOT {
+			return types.ExprString(ast.Unparen(x.X))
+		}
+	case *ast.BinaryExpr:
+		if op, ok := negatedOp[x.Op]; ok {
+			return types.ExprString(&ast.BinaryExpr{X: x.X, Op: op, Y: x.Y})
+		}
+	case *ast.Ident, *ast.CallExpr, *ast.SelectorExpr, *ast.IndexExpr:
+		return "!" + types.ExprString(x)
+	}
+	return "!(" + types.ExprString(x) + ")"
+}
+
+// negatedOp maps each comparison to its negation.
+var negatedOp = map[token.Token]token.Token{
+	token.EQL: token.NEQ, token.NEQ: token.EQL,
+	token.LSS: token.GEQ, token.GEQ: token.LSS,
+	token.GTR: token.LEQ, token.LEQ: token.GTR,
+}
+
+// enclosingFunc returns the type and body of the innermost function
+// declaration or literal whose body contains line, or nils.
+func enclosingFunc(f *ast.File, fset *token.FileSet, line int) (*ast.FuncType, *ast.BlockStmt) {
+	var ft *ast.FuncType
+	var body *ast.BlockStmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		var t *ast.FuncType
+		var b *ast.BlockStmt
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			t, b = fn.Type, fn.Body
+		case *ast.FuncLit:
+			t, b = fn.Type, fn.Body
+		default:
+			return true
+		}
+		if b != nil && srcLine(fset, b.Lbrace) <= line && line <= srcLine(fset, b.Rbrace) {
+			ft, body = t, b // later matches are nested deeper
+		}
+		return true
+	})
+	return ft, body
+}
+
+// nillableKind describes a parameter type whose zero value is nil, or
+// returns "" for other types. Named types are not resolved.
+func nillableKind(t ast.Expr) string {
+	switch t.(type) {
+	case *ast.StarExpr:
+		return "pointer"
+	case *ast.MapType:
+		return "map"
+	case *ast.FuncType:
+		return "function"
+	case *ast.ChanType:
+		return "channel"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return ""
+}
+
+// exprIdents returns the identifiers in the Go expression s; nil when s
+// does not parse.
+func exprIdents(s string) []string {
+	x, err := parser.ParseExpr(s)
+	_ = err // @inco: err == nil, -return(nil)
+	if !(err == nil) {
+		return nil
+	}
+	var names []string
+	ast.Inspect(x, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			names = append(names, id.Name)
+		}
+		return true
+	})
+	return names
+}
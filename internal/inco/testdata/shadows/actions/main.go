@@ -0,0 +1,35 @@
+package main
+
+import "errors"
+
+var errEmpty = errors.New("empty")
+
+func First(xs []int) (int, error) {
+	// @inco: len(xs) > 0, -return(0, errEmpty)
+	return xs[0], nil
+}
+
+func Sum(rows [][]int) int {
+	total := 0
+outer:
+	for _, row := range rows {
+		// @inco: row != nil, -continue
+		for _, v := range row {
+			// @inco: v >= 0, -continue(outer)
+			// @inco: v < 100, -break
+			total += v
+		}
+	}
+	return total
+}
+
+func Pick(n int) string {
+	switch {
+	case n > 0:
+		// @inco: n < 10, -break
+		return "small"
+	}
+	return "other"
+}
+
+func main() {}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type conn struct {
+	f *os.File
+}
+
+// Copy closes both files; only the calls themselves count.
+func Copy(c conn, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	// @ensure -closed f
+	// @ensure -closed f
+	fmt.Println("f.Close()", 'f') // f.Close() is called below
+	c.f.Close()
+	defer func() {
+		f, _ := os.Open(path)
+		// @ensure -closed f
+		f.Close()
+	}()
+	return f.Close()
+}
+
+func main() {}
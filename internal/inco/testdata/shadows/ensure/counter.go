@@ -0,0 +1,25 @@
+package main
+
+import "fmt"
+
+type Counter struct {
+	count int
+	items []string
+}
+
+// Add appends item and counts it.
+func (c *Counter) Add(item string) (n int) {
+	// @ensure c.count == old(c.count)+1, -panic(fmt.Sprintf("count %d, was %d", c.count, old(c.count)))
+	// @ensure if(item != "") len(c.items) > old(len(c.items)) && n == c.count
+	c.count++
+	if item != "" {
+		c.items = append(c.items, item)
+	}
+	return c.count
+}
+
+// Reset empties c.
+func (c *Counter) Reset() {
+	// @ensure #state c.count == 0
+	c.count, c.items = 0, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import "os"
+
+func Size(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	// @ensure -closed f
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	f.Close()
+	return info.Size(), nil
+}
+
+func main() {}
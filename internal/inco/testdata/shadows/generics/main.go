@@ -0,0 +1,25 @@
+package main
+
+type Stack[T any] struct {
+	items []T
+}
+
+func (s *Stack[T]) Pop() (T, bool) {
+	var zero T
+	// @inco: s != nil, -panic("nil stack")
+	// @inco: len(s.items) > 0, -return(zero, false)
+	top := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return top, true
+}
+
+func Map[T, U any](xs []T, f func(T) U) []U {
+	// @inco: f != nil
+	out := make([]U, 0, len(xs))
+	for _, x := range xs {
+		out = append(out, f(x))
+	}
+	return out
+}
+
+func main() {}
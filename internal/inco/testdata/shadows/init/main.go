@@ -0,0 +1,16 @@
+package main
+
+var routes = map[string]string{}
+
+func init() {
+	routes["/"] = "index"
+	// @inco: len(routes) > 0
+	// @inco: routes["/"] != "", -panic("no index route")
+	register := func(path string) {
+		// @inco: path != ""
+		routes[path] = path
+	}
+	register("/health")
+}
+
+func main() {}
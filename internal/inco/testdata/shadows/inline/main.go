@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+func Port() (int, error) {
+	v, err := strconv.Atoi(os.Getenv("PORT")) // @inco: err == nil, -return(0, err)
+	_ = v                                     // @inco: v > 0 && v < 65536, -panic("port out of range")
+	return v, nil
+}
+
+func main() {}
@@ -0,0 +1,14 @@
+package main
+
+func Drain(queue []int) int {
+	sum := 0
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		// @invariant sum >= 0
+		sum += next
+	}
+	return sum
+}
+
+func main() {}
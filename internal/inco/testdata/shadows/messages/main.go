@@ -0,0 +1,18 @@
+package main
+
+import "errors"
+
+var routes []string
+
+func init() {
+	// @inco: len(routes) == 0, -panic(`routes "preloaded"`)
+}
+
+func Reply(answer string) (int, error) {
+	// @inco: answer != "", -panic(`user said "no", -return(0, nil)`)
+	// @inco: answer != `"no"`, -return(0, errors.New(`refused: "no"`))
+	// @inco: len(answer) < 80, -panic("answer \"too long\", -return(1)")
+	return len(answer), nil
+}
+
+func main() {}
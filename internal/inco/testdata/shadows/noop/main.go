@@ -0,0 +1,11 @@
+package main
+
+// Plain has no directives: its shadow is the source itself.
+func Plain(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func main() {}
@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+type Account struct {
+	Balance int
+}
+
+// Transfer moves amount from one account to another.
+func Transfer(from, to *Account, amount int) {
+	// @inco: from != nil; to != nil, -panic("no account")
+	// @inco: amount > 0
+	// @inco: from.Balance >= amount && \
+	// ... amount < 1000, -panic(fmt.Sprintf("cannot move %d", amount))
+	from.Balance -= amount
+	to.Balance += amount
+}
+
+func main() {}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+func Read(path string, buf []byte, n int) (int, error) {
+	// @inco: #io n <= len(buf), -return(0, io.ErrShortBuffer)
+	// @inco: #security #io !strings.Contains(path, ".."), -panic("path traversal")
+	// @inco: if(n > 0) len(buf) > 0
+	return n, nil
+}
+
+func main() {}
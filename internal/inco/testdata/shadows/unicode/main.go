@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type 注文 struct {
+	品目  []string
+	区切り rune
+}
+
+// 品目名 returns the name of item 番号 of 注文.
+func 品目名(o 注文, 番号 int) (string, error) {
+	// @inco: -idx 番号 o.品目
+	// @inco: o.区切り != ',', -return("", fmt.Errorf("区切り「%c」は使えません 🚫", o.区切り))
+	名前 := o.品目[番号] // @inco: 名前 != "", -panic("名前が空です 🙅")
+	return 名前, nil
+}
+
+func 読む(path string, 旧接続 *os.File) error {
+	接続, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	// @ensure -closed 接続
+	旧接続.Close()
+	return 接続.Close()
+}
+
+func main() {}
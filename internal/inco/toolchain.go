@@ -0,0 +1,78 @@
+package inco
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/version"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Go toolchain: what inco needs of the go command
+// ---------------------------------------------------------------------------
+
+// MinGoVersion is the first go release with the -overlay flag.
+const MinGoVersion = "go1.16"
+
+// ErrNoGo is the error for a PATH without a go command.
+var ErrNoGo = errors.New("no go command in PATH: install " + MinGoVersion + " or later from https://go.dev/dl")
+
+// Toolchain is what inco needs to know of the go command in a directory.
+type Toolchain struct {
+	Version string // e.g. "go1.22.1"
+	GOMOD   string // the main module's go.mod; "" in GOPATH mode, os.DevNull outside a module
+}
+
+// DetectToolchain asks the go command in dir for its version and module
+// mode. It fails when there is no go command to ask.
+func DetectToolchain(dir string) (Toolchain, error) {
+	cmd := exec.Command("go", "env", "GOVERSION", "GOMOD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	var notFound *exec.Error
+	_ = err // @inco: !errors.As(err, &notFound), -return(Toolchain{}, ErrNoGo)
+	if !(!errors.As(err, &notFound)) {
+		return Toolchain{}, ErrNoGo
+	}
+	_ = err // @inco: err == nil, -return(Toolchain{}, fmt.Errorf("go env: %w", err))
+	if !(err == nil) {
+		return Toolchain{}, fmt.Errorf("go env: %w", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	t := Toolchain{Version: lines[0]}
+	if len(lines) > 1 {
+		t.GOMOD = lines[1]
+	}
+	if t.Version == "" {
+		// GOVERSION is new in go1.16; go version names older releases.
+		cmd := exec.Command("go", "version")
+		cmd.Dir = dir
+		out, _ := cmd.Output()
+		if fields := strings.Fields(string(out)); len(fields) >= 3 {
+			t.Version = fields[2] // go version go1.15.2 linux/amd64
+		}
+	}
+	return t, nil
+}
+
+// Problems returns what keeps inco from running the go command of t, each
+// with what to do about it: a release without -overlay, GOPATH mode, no
+// main module.
+func (t Toolchain) Problems() []string {
+	var problems []string
+	// Development builds ("devel go1.x-...") are taken to be recent.
+	if t.Version == "" || version.IsValid(t.Version) && version.Compare(t.Version, MinGoVersion) < 0 {
+		problems = append(problems, fmt.Sprintf("%s lacks -overlay, which inco needs: install %s or later from https://go.dev/dl",
+			cmp.Or(t.Version, "this go release"), MinGoVersion))
+	}
+	switch t.GOMOD {
+	case "":
+		problems = append(problems, "go runs in GOPATH mode (GO111MODULE=off): inco needs module mode; unset GO111MODULE")
+	case os.DevNull:
+		problems = append(problems, "no go.mod in this directory or any parent: run 'go mod init <module path>' first")
+	}
+	return problems
+}
@@ -0,0 +1,66 @@
+package inco
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Go toolchain
+// ---------------------------------------------------------------------------
+
+func TestToolchainProblems(t *testing.T) {
+	for _, tt := range []struct {
+		t    Toolchain
+		want string // problems joined by "|", matched by prefix
+	}{
+		{Toolchain{Version: "go1.22.1", GOMOD: "/m/go.mod"}, ""},
+		{Toolchain{Version: "go1.16", GOMOD: "/m/go.mod"}, ""},
+		{Toolchain{Version: "devel go1.24-abcdef", GOMOD: "/m/go.mod"}, ""},
+		{Toolchain{Version: "go1.15.2", GOMOD: "/m/go.mod"}, "go1.15.2 lacks -overlay"},
+		{Toolchain{GOMOD: "/m/go.mod"}, "this go release lacks -overlay"},
+		{Toolchain{Version: "go1.22.1"}, "go runs in GOPATH mode"},
+		{Toolchain{Version: "go1.22.1", GOMOD: os.DevNull}, "no go.mod"},
+		{Toolchain{Version: "go1.14"}, "go1.14 lacks -overlay|go runs in GOPATH mode"},
+	} {
+		got := tt.t.Problems()
+		want := strings.Split(tt.want, "|")
+		if tt.want == "" {
+			want = nil
+		}
+		ok := len(got) == len(want)
+		for i := 0; ok && i < len(want); i++ {
+			ok = strings.HasPrefix(got[i], want[i])
+		}
+		if !ok {
+			t.Errorf("%+v.Problems() = %q, want %q", tt.t, got, want)
+		}
+	}
+}
+
+func TestDetectToolchain(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	t.Setenv("GO111MODULE", "")
+	t.Setenv("GOFLAGS", "")
+	dir := setupDir(t, map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n"})
+	tc, err := DetectToolchain(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(tc.Version, "go1.") && !strings.HasPrefix(tc.Version, "devel") {
+		t.Errorf("Version = %q", tc.Version)
+	}
+	if tc.GOMOD != filepath.Join(dir, "go.mod") || len(tc.Problems()) != 0 {
+		t.Errorf("GOMOD = %q, problems %q; want %s/go.mod and none", tc.GOMOD, tc.Problems(), dir)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := DetectToolchain(dir); err != ErrNoGo {
+		t.Errorf("without go in PATH, err = %v, want ErrNoGo", err)
+	}
+}
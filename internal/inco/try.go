@@ -0,0 +1,144 @@
+package inco
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Contract simulation (inco try)
+// ---------------------------------------------------------------------------
+
+// tryFuncLine is the line of the synthetic function's signature in the
+// source Try generates.
+const tryFuncLine = 3
+
+// Try renders the code inco gen would inject for one directive, placed at
+// the top of a synthetic function with the given parameter and result
+// lists (e.g. "name string", "error"). The directive may omit the
+// "@inco:" prefix. The engine's message catalog and sensitive-name
+// patterns apply.
+//
+// The rendered function is typechecked; the returned error reports a
+// directive that does not parse or whose code does not compile. Errors in
+// the signature itself (such as a type from another package) are not
+// reported, so parameters may use any type name.
+func (e *Engine) Try(directive, params, results string) (code string, err error) {
+	body := strings.TrimSpace(directive)
+	if !hasDirectivePrefix(body) {
+		body = "@inco: " + body
+	}
+	ds := ParseDirectives("// " + body)
+	_ = ds // @inco: len(ds) > 0, -return("", categorize(ErrParseDirective, fmt.Errorf("cannot parse %q; the syntax is [if(<cond>)] <expr>[, -action(args)]", directive)))
+	if !(len(ds) > 0) {
+		return "", categorize(ErrParseDirective, fmt.Errorf("cannot parse %q; the syntax is [if(<cond>)] <expr>[, -action(args)]", directive))
+	}
+
+	for _, d := range ds {
+		if !d.Kind.checksExpr() {
+			continue
+		}
+		_, err := parser.ParseExpr(d.Expr)
+		if err != nil && len(splitTopLevel(d.Expr)) > 1 {
+			return "", categorize(ErrParseDirective, fmt.Errorf("%q is not a Go expression; write a message as an action: <expr>, -panic(\"message\")", d.Expr))
+		}
+		_ = err // @inco: err == nil, -return("", categorize(ErrParseDirective, fmt.Errorf("%q is not a Go expression: %w", d.Expr, err)))
+		if !(err == nil) {
+			return "", categorize(ErrParseDirective, fmt.Errorf("%q is not a Go expression: %w", d.Expr, err))
+		}
+	}
+
+	results = strings.TrimSpace(results)
+	if results != "" && !strings.HasPrefix(results, "(") {
+		results = "(" + results + ")"
+	}
+	comment := "\t// " + body + "\n"
+	for _, d := range ds {
+		if d.Kind == KindInvariant || d.Action == ActionContinue || d.Action == ActionBreak {
+			comment = "\tfor {\n\t\t// " + body + "\n\t}\n" // loop contracts need a loop
+			break
+		}
+	}
+	src := fmt.Sprintf("package try\n\nfunc f(%s) %s {\n%s}\n", params, results, comment)
+
+	dir, err := os.MkdirTemp("", "inco-try-")
+	_ = err // @inco: err == nil, -return("", err)
+	if !(err == nil) {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "try.go")
+	err = os.WriteFile(path, []byte(src), 0o644)
+	_ = err // @inco: err == nil, -return("", err)
+	if !(err == nil) {
+		return "", err
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	_ = err // @inco: err == nil, -return("", fmt.Errorf("parameters %q or results %q: %w", params, results, err))
+	if !(err == nil) {
+		return "", fmt.Errorf("parameters %q or results %q: %w", params, results, err)
+	}
+
+	// Generation reports errors by panicking, as in inco gen.
+	t := &Engine{Root: dir, Messages: e.Messages, Sensitive: e.Sensitive}
+	defer func() {
+		if r := recover(); r != nil {
+			code, err = "", panicError(r)
+		}
+	}()
+	t.catalog = t.loadCatalog()
+	shadow := string(t.generateShadow(path, f, fset).ShadowData)
+
+	var kept []string
+	for _, line := range strings.Split(shadow, "\n") {
+		if !strings.HasPrefix(line, "//line ") {
+			kept = append(kept, line)
+		}
+	}
+	formatted, err := format.Source([]byte(strings.Join(kept, "\n")))
+	_ = err // @inco: err == nil, -return("", err)
+	if !(err == nil) {
+		return "", err
+	}
+	code = strings.TrimPrefix(string(formatted), "package try\n\n")
+	return code, typecheckTry(shadow)
+}
+
+// typecheckTry typechecks the shadow of Try's synthetic file, ignoring
+// errors in the signature and the missing return of a function with
+// results.
+func typecheckTry(shadow string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "try.go", shadow, 0)
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	var errs []error
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			te := err.(types.Error)
+			if te.Msg == "missing return" || te.Fset.Position(te.Pos).Line == tryFuncLine {
+				return
+			}
+			errs = append(errs, errors.New(te.Msg))
+		},
+	}
+	conf.Check("try", fset, []*ast.File{f}, nil)
+	_ = errs // @inco: len(errs) > 0, -return(nil)
+	if !(len(errs) > 0) {
+		return nil
+	}
+	return categorize(ErrTypecheck, fmt.Errorf("does not compile: %w", errors.Join(errs...)))
+}
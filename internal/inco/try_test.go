@@ -0,0 +1,57 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Contract simulation
+// ---------------------------------------------------------------------------
+
+func TestTry(t *testing.T) {
+	e := &Engine{}
+	code, err := e.Try(`len(name) > 0, -panic("name required")`, "name string", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "func f(name string) {\n\tif !(len(name) > 0) {\n\t\tpanic(\"name required\")\n\t}\n}\n"
+	if code != want {
+		t.Errorf("code:\n%s\nwant:\n%s", code, want)
+	}
+
+	// Imports the action needs are added; types from other packages in
+	// the signature are not errors.
+	code, err = e.Try(`@inco: u != nil, -return(fmt.Errorf("nil user"))`, "u *User", "error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(code, "import \"fmt\"\n") || !strings.Contains(code, "func f(u *User) error {") {
+		t.Errorf("code:\n%s", code)
+	}
+
+	// Loop contracts are rendered inside a loop.
+	code, err = e.Try(`@invariant i < n, -break`, "i, n int", "")
+	if err != nil || !strings.Contains(code, "\tfor {\n\t\tif !(i < n) {\n\t\t\tbreak\n") {
+		t.Errorf("code:\n%s\nerr: %v", code, err)
+	}
+}
+
+func TestTry_Errors(t *testing.T) {
+	e := &Engine{}
+	tests := []struct {
+		directive, params, want string
+	}{
+		{`len(name) > 0, "name required"`, "name string", `write a message as an action: <expr>, -panic("message")`},
+		{`len(nme) > 0`, "name string", "does not compile: undefined: nme"},
+		{`x > 0`, "x string", "mismatched types string and untyped int"},
+		{`x >`, "x int", `"x >" is not a Go expression`},
+		{`@ensure -open f`, "", "cannot parse"},
+	}
+	for _, tt := range tests {
+		_, err := e.Try(tt.directive, tt.params, "")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Try(%q) error = %v, want %q", tt.directive, err, tt.want)
+		}
+	}
+}
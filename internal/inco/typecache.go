@@ -0,0 +1,101 @@
+package inco
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// typecheck.json I/O (typecheck outcomes across processes)
+// ---------------------------------------------------------------------------
+
+// typecheckFile is the JSON structure of .inco_cache/typecheck.json: the
+// typecheck outcome of every package, so that a new process skips the
+// packages whose shadows are unchanged since the last inco gen.
+type typecheckFile struct {
+	Env      string                     `json:"env"`      // go command settings the outcomes hold for
+	Packages map[string]typecheckRecord `json:"packages"` // package dir → outcome
+}
+
+// typecheckRecord is the persisted outcome of typechecking one package.
+type typecheckRecord struct {
+	Key        string   `json:"key"`               // hash of the package's shadows, go.mod and go.sum
+	ImportPath string   `json:"import_path"`       // the package's import path
+	Imports    []string `json:"imports,omitempty"` // import paths of its direct dependencies
+	Errors     []string `json:"errors,omitempty"`  // its typecheck errors
+}
+
+func (e *Engine) typecheckCachePath() string {
+	return filepath.Join(e.CacheDir, "typecheck.json")
+}
+
+// goEnvKey describes the go command settings a typecheck depends on
+// beyond the sources: toolchain version, target platform, cgo and
+// GOFLAGS (build tags). It is "" when the go command cannot be run.
+func (e *Engine) goEnvKey() string {
+	cmd := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS")
+	cmd.Dir = e.Root
+	out, err := cmd.Output()
+	_ = err // @inco: err == nil, -return("")
+	if !(err == nil) {
+		return ""
+	}
+	return strings.Join(strings.Fields(string(out)), " ")
+}
+
+// loadTypechecked returns the outcomes typecheck.json holds for the
+// current go settings env, keyed by package dir, or an empty map when
+// there are none. Loaded outcomes count as typechecked before any Run of
+// this engine, so they are evicted first.
+func (e *Engine) loadTypechecked(env string) map[string]*typecheckEntry {
+	entries := make(map[string]*typecheckEntry)
+	_ = env // @inco: env != "", -return(entries)
+	if !(env != "") {
+		return entries
+	}
+	data, err := os.ReadFile(e.typecheckCachePath())
+	_ = err // @inco: err == nil, -return(entries)
+	if !(err == nil) {
+		return entries
+	}
+	var c typecheckFile
+	if json.Unmarshal(data, &c) != nil || c.Env != env {
+		return entries
+	}
+	for dir, p := range c.Packages {
+		entries[dir] = &typecheckEntry{key: p.Key, importPath: p.ImportPath, imports: p.Imports, msgs: p.Errors}
+	}
+	return entries
+}
+
+// writeTypechecked writes the engine's typecheck outcomes to
+// typecheck.json for the go settings env. Nothing is written when env is
+// unknown, since the outcomes could not be matched on load.
+func (e *Engine) writeTypechecked(env string) {
+	_ = env // @inco: env != "", -return
+	if !(env != "") {
+		return
+	}
+	c := typecheckFile{Env: env, Packages: make(map[string]typecheckRecord, len(e.typechecked))}
+	for dir, t := range e.typechecked {
+		c.Packages[dir] = typecheckRecord{Key: t.key, ImportPath: t.importPath, Imports: t.imports, Errors: t.msgs}
+	}
+	err := os.MkdirAll(e.CacheDir, 0o755)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	err = writeFileAtomic(e.typecheckCachePath(), data, 0o644)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+}
@@ -9,10 +9,53 @@
 //	// @inco: <expr>, -return(x, y)
 //	// @inco: <expr>, -continue
 //	// @inco: <expr>, -break
+//	// @inco: if(<cond>) <expr>
+//	// @inco: #tag <expr>
+//	// @ensure -closed <ident>
 //
 // The default action is -panic with an auto-generated message.
 package inco
 
+// ---------------------------------------------------------------------------
+// Kind
+// ---------------------------------------------------------------------------
+
+// DirectiveKind identifies the family a directive belongs to.
+type DirectiveKind int
+
+const (
+	KindRequire      DirectiveKind = iota // default — @inco: precondition
+	KindEnsureClosed                      // @ensure -closed: Close must be called before return
+	KindRecvOnly                          // @inco: -recvonly ch: ch must be receivable (checked at compile time)
+	KindSendOnly                          // @inco: -sendonly ch: ch must be sendable (checked at compile time)
+	KindInvariant                         // @invariant: loop invariant, checked at the top of every iteration
+	KindMust                              // @must(d): a channel send or receive completes within d (test builds)
+	KindEnsure                            // @ensure expr: postcondition, checked when the function returns
+)
+
+var kindNames = map[DirectiveKind]string{
+	KindRequire:      "require",
+	KindEnsureClosed: "ensure-closed",
+	KindRecvOnly:     "recv-only",
+	KindSendOnly:     "send-only",
+	KindInvariant:    "invariant",
+	KindMust:         "must",
+	KindEnsure:       "ensure",
+}
+
+// checksExpr reports whether directives of kind k check Expr at run time,
+// so mutation, size limits and message audits apply to them.
+func (k DirectiveKind) checksExpr() bool {
+	return k == KindRequire || k == KindInvariant || k == KindEnsure
+}
+
+func (k DirectiveKind) String() string {
+	if s, ok := kindNames[k]; ok {
+		return s
+	}
+	return "unknown"
+}
+
 // ---------------------------------------------------------------------------
 // Action
 // ---------------------------------------------------------------------------
@@ -25,6 +68,7 @@ const (
 	ActionReturn                     // return (with optional values)
 	ActionContinue                   // continue enclosing loop
 	ActionBreak                      // break enclosing loop
+	ActionDefault                    // assign a fallback to the checked variable
 )
 
 var actionNames = map[ActionKind]string{
@@ -32,6 +76,7 @@ var actionNames = map[ActionKind]string{
 	ActionReturn:   "return",
 	ActionContinue: "continue",
 	ActionBreak:    "break",
+	ActionDefault:  "default",
 }
 
 func (k ActionKind) String() string {
@@ -45,11 +90,17 @@ func (k ActionKind) String() string {
 // Directive
 // ---------------------------------------------------------------------------
 
-// Directive is the parsed form of a single @inco: comment.
+// Directive is the parsed form of a single @inco: or @ensure comment.
 type Directive struct {
-	Action     ActionKind // panic (default), return, continue, break
-	ActionArgs []string   // e.g. -panic("msg") → ['"msg"'], -return(0, err) → ["0", "err"]
-	Expr       string     // the Go boolean expression
+	Kind       DirectiveKind // require (default), ensure, ensure-closed, ...
+	Action     ActionKind    // panic (default), return, continue, break, default
+	ActionArgs []string      // e.g. -panic("msg") → ['"msg"'], -return(0, err) → ["0", "err"], -default(30) → ["30"]
+	Expr       string        // the Go boolean expression (@ensure -closed: the tracked identifier)
+	Cond       string        // if(cond): the contract is only checked while cond is true; empty = always
+	Target     string        // -default: the variable assigned the fallback; empty when Expr names none
+	Tags       []string      // #tag groups, e.g. #io #security → ["io", "security"]
+	Bind       string        // "-> stmt": the statement the directive applies to; empty = unbound
+	Entry      []string      // @entry(name): names the check reads at their entry value on purpose
 }
 
 // ---------------------------------------------------------------------------
@@ -69,6 +120,14 @@ type Manifest struct {
 
 // ManifestEntry records the state of a single source file at last gen.
 type ManifestEntry struct {
-	SrcHash    string `json:"src_hash"`    // SHA-256 hex of source content
-	ShadowPath string `json:"shadow_path"` // absolute path to shadow file
+	SrcHash       string `json:"src_hash"`                 // SHA-256 hex of source content
+	ShadowPath    string `json:"shadow_path"`              // absolute path to shadow file
+	TrimPath      bool   `json:"trimpath,omitempty"`       // shadow uses relative //line paths
+	TagFilter     string `json:"tag_filter,omitempty"`     // enabled/disabled tags the shadow was generated with
+	Mutation      string `json:"mutation,omitempty"`       // line:expr of the mutated directive (inco mutate)
+	Messages      string `json:"messages,omitempty"`       // hash of the message catalog the shadow was generated with
+	Sensitive     string `json:"sensitive,omitempty"`      // sensitive name patterns the shadow was redacted with
+	LogViolations bool   `json:"log_violations,omitempty"` // violations are logged instead of panicking
+	Must          bool   `json:"must,omitempty"`           // @must channel deadlines are generated
+	CountHits     bool   `json:"count_hits,omitempty"`     // checks record their evaluation
 }
@@ -0,0 +1,381 @@
+package inco
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// maxVerifyErrors caps how many errors a failed verification reports.
+const maxVerifyErrors = 10
+
+// DefaultTypecheckCache is the number of packages whose typecheck outcome
+// an engine keeps across Runs when Engine.TypecheckCache is zero.
+const DefaultTypecheckCache = 512
+
+// typecheckBatch is the number of packages loaded together. Syntax trees
+// and type information are dropped after each batch, so memory tracks the
+// batch rather than the whole repository.
+const typecheckBatch = 8
+
+// maxHeavyPackages caps how many packages a -max-memory failure names.
+const maxHeavyPackages = 5
+
+// verifyShadows checks generated shadows before the overlay is written.
+//
+// Every freshly generated shadow is parsed; with e.Typecheck, the packages
+// under Root are also typechecked with all shadows applied. Any error
+// panics, so overlay.json is never written for shadows the compiler would
+// reject, and the overlay of an earlier Run is discarded. Error positions honour the injected //line directives and thus
+// point at the originating directive in the source file. With
+// e.KeepGoing, an invalid shadow fails only its own file.
+func (e *Engine) verifyShadows(results []fileResult) {
+	fset := token.NewFileSet()
+	for i, r := range results {
+		_ = r // @inco: !r.Cached && r.Err == nil, -continue
+		if !(!r.Cached && r.Err == nil) {
+			continue
+		}
+		_, err := parser.ParseFile(fset, r.Path, r.ShadowData, parser.AllErrors)
+		if err != nil && e.KeepGoing {
+			results[i] = fileResult{Path: r.Path, Err: categorize(ErrParseDirective, fmt.Errorf("invalid shadow: %w", err))}
+			continue
+		}
+		if err != nil {
+			e.discardOverlay()
+			panic(categorize(ErrParseDirective, fmt.Errorf("invalid shadow for %s: %w", e.relPath(r.Path), err)))
+		}
+	}
+
+	_ = e.Typecheck // @inco: e.Typecheck, -return
+	if !(e.Typecheck) {
+		return
+	}
+	msgs := e.typecheckOverlay(results)
+	if len(msgs) > 0 {
+		e.discardOverlay()
+		panic(categorize(ErrTypecheck, fmt.Errorf("shadow typecheck failed:\n\t%s", strings.Join(msgs, "\n\t"))))
+	}
+}
+
+// typecheckEntry is the cached typecheck outcome of one package.
+type typecheckEntry struct {
+	key        string   // hash of the package's shadows as typechecked
+	importPath string   // the package's import path
+	imports    []string // import paths of its direct dependencies
+	msgs       []string // its errors
+	heap       uint64   // estimated heap growth while typechecking it (with MaxMemory only)
+	run        uint64   // the Run that last typechecked it, for eviction
+}
+
+// typecheckOverlay typechecks the packages under Root with each source
+// file replaced by its shadow and returns their errors, at most
+// maxVerifyErrors.
+//
+// Outcomes are cached per package, keyed by the hash of its shadows, for
+// as long as the engine lives and in typecheck.json for later processes:
+// when the engine runs repeatedly (inco serve), or inco gen runs again on
+// unchanged code, only packages whose shadows changed, and the packages
+// importing them, are typechecked again. Stale packages are loaded in batches of
+// typecheckBatch; with e.MaxMemory, the heap is checked after each batch.
+// Beyond e.TypecheckCache packages, the least recently typechecked are
+// evicted and checked again on the next Run.
+func (e *Engine) typecheckOverlay(results []fileResult) []string {
+	overlay := make(map[string][]byte, len(results))
+	byDir := make(map[string][]string) // package dir → its files
+	for _, r := range results {
+		_ = r.Err // @inco: r.Err == nil, -continue
+		if !(r.Err == nil) {
+			continue
+		}
+		data := r.ShadowData
+		if r.Cached {
+			var err error
+			data, err = os.ReadFile(r.ShadowPath)
+			_ = err // @inco: err == nil, -panic(err)
+			if !(err == nil) {
+				panic(err)
+			}
+		}
+		overlay[r.Path] = data
+		// A file excluded by //go:build ignore is in no package: it is a
+		// program run on its own (go run gen.go), so it is checked as one.
+		if buildIgnored(data) {
+			byDir[r.Path] = []string{r.Path}
+			continue
+		}
+		byDir[filepath.Dir(r.Path)] = append(byDir[filepath.Dir(r.Path)], r.Path)
+	}
+
+	// Generated files of other overlays are only there to be imported.
+	generated := e.generatedContents()
+	maps.Copy(overlay, generated)
+
+	// 1. Hash each package's shadows, together with go.mod, go.sum and
+	// the generated files.
+	modHash := hashFiles(append([]string{filepath.Join(e.Root, "go.mod"), filepath.Join(e.Root, "go.sum")}, slices.Sorted(maps.Values(e.generated))...)...)
+	keys := make(map[string]string, len(byDir))
+	for dir, paths := range byDir {
+		slices.Sort(paths)
+		h := sha256.New()
+		h.Write([]byte(modHash))
+		for _, p := range paths {
+			sum := sha256.Sum256(overlay[p])
+			fmt.Fprintf(h, "%s %x\n", p, sum)
+		}
+		keys[dir] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	// 2. A package is stale when its key changed, or when it imports a
+	// stale package.
+	if e.typechecked == nil {
+		e.goEnv = e.goEnvKey()
+		e.typechecked = e.loadTypechecked(e.goEnv)
+	}
+	maps.DeleteFunc(e.typechecked, func(dir string, _ *typecheckEntry) bool {
+		_, ok := keys[dir]
+		return !ok
+	})
+	stale := make(map[string]bool)
+	for dir, key := range keys {
+		if c, ok := e.typechecked[dir]; !ok || c.key != key {
+			stale[dir] = true
+		}
+	}
+	for changed := len(stale) > 0; changed; {
+		changed = false
+		stalePaths := make(map[string]bool)
+		for dir := range stale {
+			if c, ok := e.typechecked[dir]; ok {
+				stalePaths[c.importPath] = true
+			}
+		}
+		for dir, c := range e.typechecked {
+			if !stale[dir] && slices.ContainsFunc(c.imports, func(p string) bool { return stalePaths[p] }) {
+				stale[dir], changed = true, true
+			}
+		}
+	}
+
+	// 3. Typecheck the stale packages, a batch at a time.
+	e.typecheckRuns++
+	patterns := slices.Sorted(maps.Keys(stale))
+	programs := slices.DeleteFunc(slices.Clone(patterns), func(p string) bool { return !strings.HasSuffix(p, ".go") })
+	patterns = slices.DeleteFunc(patterns, func(p string) bool { return strings.HasSuffix(p, ".go") })
+	for batch := range slices.Chunk(patterns, typecheckBatch) {
+		if msgs := e.typecheckBatch(batch, overlay, byDir, keys); msgs != nil {
+			return msgs
+		}
+	}
+	for _, program := range programs {
+		if msgs := e.typecheckBatch([]string{program}, overlay, byDir, keys); msgs != nil {
+			return msgs
+		}
+	}
+
+	// 4. Report the errors of all packages, cached or not.
+	var msgs []string
+	for _, dir := range slices.Sorted(maps.Keys(e.typechecked)) {
+		for _, m := range e.typechecked[dir].msgs {
+			if len(msgs) < maxVerifyErrors {
+				msgs = append(msgs, m)
+			}
+		}
+	}
+
+	// 5. Keep the cache within bounds, and for the next process.
+	e.evictTypechecked()
+	e.writeTypechecked(e.goEnv)
+	return msgs
+}
+
+// buildIgnored reports whether the //go:build line of the Go source src
+// requires the ignore tag, as //go:build ignore does for programs meant
+// for go run. Such a file is only ever built when named on the
+// command line.
+func buildIgnored(src []byte) bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly|parser.ParseComments)
+	_ = err // @inco: err == nil, -return(false)
+	if !(err == nil) {
+		return false
+	}
+	for _, cg := range f.Comments {
+		_ = cg // @inco: cg.Pos() < f.Package, -break
+		if !(cg.Pos() < f.Package) {
+			break
+		}
+		for _, c := range cg.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			x, err := constraint.Parse(c.Text)
+			return err == nil && requiresTag(x, "ignore")
+		}
+	}
+	return false
+}
+
+// requiresTag reports whether the build constraint x can only be
+// satisfied with tag set.
+func requiresTag(x constraint.Expr, tag string) bool {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		return x.Tag == tag
+	case *constraint.AndExpr:
+		return requiresTag(x.X, tag) || requiresTag(x.Y, tag)
+	case *constraint.OrExpr:
+		return requiresTag(x.X, tag) && requiresTag(x.Y, tag)
+	}
+	return false
+}
+
+// typecheckBatch typechecks the packages in dirs and caches their
+// outcome. It returns non-nil messages when loading fails, and panics when
+// the heap exceeds e.MaxMemory.
+func (e *Engine) typecheckBatch(dirs []string, overlay map[string][]byte, byDir map[string][]string, keys map[string]string) []string {
+	var before runtime.MemStats
+	if e.MaxMemory > 0 {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+	}
+	cfg := &packages.Config{
+		Mode:    packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedSyntax | packages.NeedTypes,
+		Dir:     e.Root,
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, dirs...)
+	_ = err // @inco: err == nil, -return([]string{err.Error()})
+	if !(err == nil) {
+		return []string{err.Error()}
+	}
+	for _, dir := range dirs {
+		delete(e.typechecked, dir)
+	}
+	for _, pkg := range pkgs {
+		_ = pkg // @inco: len(pkg.GoFiles) > 0, -continue
+		if !(len(pkg.GoFiles) > 0) {
+			continue
+		}
+		dir := filepath.Dir(pkg.GoFiles[0])
+		if pkg.PkgPath == "command-line-arguments" {
+			dir = pkg.GoFiles[0] // a //go:build ignore program, loaded by file
+		}
+		c := &typecheckEntry{key: keys[dir], importPath: pkg.PkgPath,
+			imports: slices.Sorted(maps.Keys(pkg.Imports)), run: e.typecheckRuns}
+		// go list compiles the package, repeating its type errors as a
+		// list error: "# example.com/m" followed by the compiler's output.
+		typeErrors := slices.ContainsFunc(pkg.Errors, func(pe packages.Error) bool { return pe.Kind == packages.TypeError })
+		for _, pe := range pkg.Errors {
+			if pe.Kind == packages.ListError && typeErrors && (pkg.PkgPath == "command-line-arguments" || strings.HasPrefix(pe.Msg, "# ")) {
+				continue
+			}
+			c.msgs = append(c.msgs, pe.Error())
+		}
+		e.typechecked[dir] = c
+	}
+
+	_ = e.MaxMemory // @inco: e.MaxMemory > 0, -return(nil)
+	if !(e.MaxMemory > 0) {
+		return nil
+	}
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	e.attributeHeap(dirs, overlay, byDir, after.HeapAlloc-min(before.HeapAlloc, after.HeapAlloc))
+	runtime.KeepAlive(pkgs)
+	_ = after // @inco: after.HeapAlloc <= e.MaxMemory, -panic(e.memoryError(after.HeapAlloc))
+	if !(after.HeapAlloc <= e.MaxMemory) {
+		panic(e.memoryError(after.HeapAlloc))
+	}
+	return nil
+}
+
+// attributeHeap splits the heap growth of a batch among its packages in
+// proportion to the size of their shadows.
+func (e *Engine) attributeHeap(dirs []string, overlay map[string][]byte, byDir map[string][]string, growth uint64) {
+	sizes := make(map[string]uint64, len(dirs))
+	var total uint64
+	for _, dir := range dirs {
+		for _, p := range byDir[dir] {
+			sizes[dir] += uint64(len(overlay[p]))
+		}
+		total += sizes[dir]
+	}
+	_ = total // @inco: total > 0, -return
+	if !(total > 0) {
+		return
+	}
+	for _, dir := range dirs {
+		if c, ok := e.typechecked[dir]; ok {
+			c.heap = uint64(float64(growth) * float64(sizes[dir]) / float64(total))
+		}
+	}
+}
+
+// memoryError reports a heap above e.MaxMemory with the heaviest packages
+// typechecked so far.
+func (e *Engine) memoryError(heap uint64) error {
+	dirs := slices.Collect(maps.Keys(e.typechecked))
+	slices.SortFunc(dirs, func(a, b string) int {
+		if c := cmp.Compare(e.typechecked[b].heap, e.typechecked[a].heap); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	var heavy []string
+	for _, dir := range dirs[:min(len(dirs), maxHeavyPackages)] {
+		heavy = append(heavy, fmt.Sprintf("%s (~%s)", e.relPath(dir), formatBytes(e.typechecked[dir].heap)))
+	}
+	return fmt.Errorf("typecheck heap %s exceeds max memory %s; heaviest packages:\n\t%s",
+		formatBytes(heap), formatBytes(e.MaxMemory), strings.Join(heavy, "\n\t"))
+}
+
+// evictTypechecked drops the least recently typechecked packages beyond
+// e.TypecheckCache.
+func (e *Engine) evictTypechecked() {
+	limit := e.TypecheckCache
+	if limit == 0 {
+		limit = DefaultTypecheckCache
+	}
+	_ = limit // @inco: limit > 0 && len(e.typechecked) > limit, -return
+	if !(limit > 0 && len(e.typechecked) > limit) {
+		return
+	}
+	dirs := slices.Collect(maps.Keys(e.typechecked))
+	slices.SortFunc(dirs, func(a, b string) int {
+		if c := cmp.Compare(e.typechecked[a].run, e.typechecked[b].run); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	for _, dir := range dirs[:len(dirs)-limit] {
+		delete(e.typechecked, dir)
+	}
+}
+
+// formatBytes renders n in MiB.
+func formatBytes(n uint64) string {
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+}
+
+// hashFiles returns a hash of the contents of paths; missing files hash
+// as empty.
+func hashFiles(paths ...string) string {
+	h := sha256.New()
+	for _, p := range paths {
+		data, _ := os.ReadFile(p)
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s %x\n", p, sum)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,445 @@
+package inco
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runExpectPanic runs e.Run and returns the recovered panic message.
+func runExpectPanic(t *testing.T, e *Engine) string {
+	t.Helper()
+	var msg string
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = fmt.Sprint(r)
+			}
+		}()
+		e.Run()
+	}()
+	if msg == "" {
+		t.Fatal("Run should panic")
+	}
+	return msg
+}
+
+// ---------------------------------------------------------------------------
+// Parse verification
+// ---------------------------------------------------------------------------
+
+func TestVerify_InvalidShadowRefusesOverlay(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"main.go": `package main
+
+func Do(x int) {
+	// @inco: x >
+	_ = x
+}
+`,
+	})
+	e := NewEngine(dir)
+	msg := runExpectPanic(t, e)
+	if !strings.Contains(msg, "invalid shadow for main.go") {
+		t.Errorf("message should name the file, got: %s", msg)
+	}
+	// The error must point at the directive line, not the shadow line.
+	if !strings.Contains(msg, filepath.Join(dir, "main.go")+":4") {
+		t.Errorf("message should point at the directive (line 4), got: %s", msg)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".inco_cache", "overlay.json")); err == nil {
+		t.Error("overlay.json must not be written for an invalid shadow")
+	}
+}
+
+func TestVerify_KeepGoing(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"good.go": `package main
+
+func Good(x int) {
+	// @inco: x > 0
+	_ = x
+}
+`,
+		"badshadow.go": `package main
+
+func Do(x int) {
+	// @inco: x >
+	_ = x
+}
+`,
+		"unparsable.go": `package main
+
+func Broken( {
+`,
+	})
+	e := NewEngine(dir)
+	e.KeepGoing = true
+	msg := runExpectPanic(t, e)
+	if !strings.Contains(msg, "2 file(s) failed; the overlay covers the others") {
+		t.Errorf("summary should count both failures, got: %s", msg)
+	}
+	if !strings.Contains(msg, "badshadow.go:4:5: ") {
+		t.Errorf("invalid shadow should point at the directive's column, got: %s", msg)
+	}
+	if len(e.Failures) != 2 {
+		t.Fatalf("got %d failures, want 2: %v", len(e.Failures), e.Failures)
+	}
+	for _, d := range e.Failures {
+		switch filepath.Base(d.Path) {
+		case "badshadow.go":
+			if d.Line != 4 || d.Column != 5 {
+				t.Errorf("invalid shadow reported at %d:%d, want the directive (4:5)", d.Line, d.Column)
+			}
+		case "unparsable.go":
+			if d.Line != 3 {
+				t.Errorf("syntax error reported at line %d, want 3", d.Line)
+			}
+		default:
+			t.Errorf("unexpected failure: %+v", d)
+		}
+	}
+
+	// The overlay still covers the file that succeeded, and only it.
+	data, err := os.ReadFile(filepath.Join(e.CacheDir, "overlay.json"))
+	if err != nil {
+		t.Fatalf("overlay.json not written: %v", err)
+	}
+	var ov Overlay
+	if err := json.Unmarshal(data, &ov); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ov.Replace[filepath.Join(dir, "good.go")]; !ok || len(ov.Replace) != 1 {
+		t.Errorf("overlay should map good.go only, got %v", ov.Replace)
+	}
+}
+
+func TestVerify_KeepGoingNoOverlay(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"badshadow.go": `package main
+
+func Do(x int) {
+	// @inco: x >
+	_ = x
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.KeepGoing = true
+	msg := runExpectPanic(t, e)
+	if !strings.Contains(msg, "1 file(s) failed:") {
+		t.Errorf("summary should count the failure, got: %s", msg)
+	}
+	if strings.Contains(msg, "overlay") {
+		t.Errorf("no overlay was written, yet the summary mentions one: %s", msg)
+	}
+	if _, err := os.Stat(filepath.Join(e.CacheDir, "overlay.json")); !os.IsNotExist(err) {
+		t.Errorf("overlay.json should not exist, stat err = %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Typecheck verification
+// ---------------------------------------------------------------------------
+
+func TestVerify_Typecheck(t *testing.T) {
+	src := `package main
+
+func Do(x int) {
+	// @inco: y > 0
+	_ = x
+}
+
+func main() {}
+`
+	dir := setupDir(t, map[string]string{
+		"go.mod":  "module example.com/m\n\ngo 1.21\n",
+		"main.go": src,
+	})
+
+	// Without -typecheck the shadow parses and the overlay is written.
+	NewEngine(dir).Run()
+
+	e := NewEngine(dir)
+	e.Typecheck = true
+	msg := runExpectPanic(t, e)
+	if !strings.Contains(msg, "undefined: y") {
+		t.Errorf("typecheck should report the undefined identifier, got: %s", msg)
+	}
+	if !strings.Contains(msg, "main.go:4") {
+		t.Errorf("typecheck error should point at the directive (line 4), got: %s", msg)
+	}
+	if n := strings.Count(msg, "undefined: y"); n != 1 {
+		t.Errorf("typecheck error reported %d times, want once: %s", n, msg)
+	}
+	// The overlay of the first Run must not outlive the failure.
+	for _, name := range []string{"overlay.json", "manifest.json"} {
+		if _, err := os.Stat(filepath.Join(e.CacheDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s of the earlier run left in place after the typecheck failed", name)
+		}
+	}
+}
+
+// typecheckFailure returns the message of the typecheck failure of the
+// shadow of src, a file main.go.
+func typecheckFailure(t *testing.T, src string) string {
+	t.Helper()
+	e := NewEngine(setupDir(t, map[string]string{
+		"go.mod":  "module example.com/m\n\ngo 1.21\n",
+		"main.go": src,
+	}))
+	e.Typecheck = true
+	e.Quiet = true
+	return runExpectPanic(t, e)
+}
+
+func TestVerify_TypecheckActionPosition(t *testing.T) {
+	msg := typecheckFailure(t, `package main
+
+func wrap(b []byte) []byte { return b }
+
+func Encode(s string) ([]byte, error) {
+	_ = s // @inco: s != "", -return(wrap(s), nil)
+	return []byte(s), nil
+}
+`)
+	if !strings.Contains(msg, "main.go:6: cannot use s") {
+		t.Errorf("the error in the -return argument should point at the directive (line 6), got: %s", msg)
+	}
+}
+
+func TestVerify_TypecheckGuardPosition(t *testing.T) {
+	msg := typecheckFailure(t, `package main
+
+func Load(path string) {
+	// @inco: path != ""
+	_ = path // @inco: if(verbose) len(path) < 256
+}
+`)
+	if !strings.Contains(msg, "main.go:5: undefined: verbose") {
+		t.Errorf("the error in the if(cond) guard should point at the directive (line 5), got: %s", msg)
+	}
+}
+
+func TestVerify_TypecheckComposedPosition(t *testing.T) {
+	msg := typecheckFailure(t, `package main
+
+func Read(buf []byte) int {
+	n := len(buf) // @inco: n > 0; @inco: n <= limit, -return(0)
+	return n
+}
+`)
+	if !strings.Contains(msg, "main.go:4: undefined: limit") {
+		t.Errorf("the error in the second directive of the line should point at it (line 4), got: %s", msg)
+	}
+}
+
+func TestVerify_TypecheckDefaultPosition(t *testing.T) {
+	msg := typecheckFailure(t, `package main
+
+func Retries(n int) int {
+	_ = n // @inco: n > 0, -default(fallback)
+	return n
+}
+`)
+	if !strings.Contains(msg, "main.go:4: undefined: fallback") {
+		t.Errorf("the error in the -default value should point at the directive (line 4), got: %s", msg)
+	}
+}
+
+func TestVerify_TypecheckIgnoredProgram(t *testing.T) {
+	program := "//go:build ignore\n\npackage main\n\nimport \"example.com/m/lib\"\n\nfunc main() {\n\tn := lib.N()\n\t// @inco: n > LIMIT\n}\n"
+	dir := setupDir(t, map[string]string{
+		"go.mod":        "module example.com/m\n\ngo 1.21\n",
+		"lib/lib.go":    "package lib\n\nfunc N() int { return 1 }\n",
+		"lib/gen.go":    strings.Replace(program, "LIMIT", "0", 1),
+		"tools/bad.go":  program,
+		"tools/tool.go": "//go:build ignore && linux\n\npackage main\n\nfunc main() {}\n",
+	})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Quiet = true
+	msg := runExpectPanic(t, e)
+	if !strings.Contains(msg, "tools/bad.go:9: undefined: LIMIT") || strings.Count(msg, "undefined: LIMIT") != 1 {
+		t.Errorf("the program should be typechecked on its own, once, got: %s", msg)
+	}
+	for _, key := range []string{filepath.Join(dir, "lib"), filepath.Join(dir, "lib", "gen.go"), filepath.Join(dir, "tools", "tool.go")} {
+		if c := e.typechecked[key]; c == nil || len(c.msgs) != 0 {
+			t.Errorf("%s: typecheck entry %+v", key, c)
+		}
+	}
+}
+
+func TestBuildIgnored(t *testing.T) {
+	for src, want := range map[string]bool{
+		"//go:build ignore\n\npackage main\n":                             true,
+		"// Command gen.\n\n//go:build ignore && linux\n\npackage main\n": true,
+		"//go:build ignore || windows\n\npackage main\n":                  false,
+		"//go:build !ignore\n\npackage main\n":                            false,
+		"//go:build linux\n\npackage main\n":                              false,
+		"package main\n\n//go:build ignore\n":                             false,
+	} {
+		if got := buildIgnored([]byte(src)); got != want {
+			t.Errorf("buildIgnored(%q) = %t, want %t", src, got, want)
+		}
+	}
+}
+
+func TestVerify_TypecheckCache(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": `package a
+
+func A(x int) int {
+	// @inco: x > 0
+	return x
+}
+`,
+		"b/b.go": `package b
+
+import "example.com/m/a"
+
+func B() int { return a.A(1) }
+`,
+		"c/c.go": `package c
+
+func C(x int) {
+	// @inco: x != 0
+}
+`,
+	})
+	e := NewEngine(dir)
+	e.Typecheck = true
+	e.Quiet = true
+	e.Run()
+	if len(e.typechecked) != 3 {
+		t.Fatalf("typechecked %d packages, want 3", len(e.typechecked))
+	}
+	before := maps.Clone(e.typechecked)
+
+	// Unchanged sources: nothing is typechecked again.
+	e.Run()
+	for dir, c := range e.typechecked {
+		if before[dir] != c {
+			t.Errorf("%s was typechecked again without changes", dir)
+		}
+	}
+
+	// Changing a invalidates a and its importer b, but not c.
+	if err := os.WriteFile(filepath.Join(dir, "a", "a.go"), []byte(`package a
+
+func A(x int) int {
+	// @inco: x > 1
+	return x
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e.Run()
+	for _, pkg := range []string{"a", "b", "c"} {
+		p := filepath.Join(dir, pkg)
+		if rechecked := before[p] != e.typechecked[p]; rechecked != (pkg != "c") {
+			t.Errorf("package %s typechecked again = %v, want %v", pkg, rechecked, pkg != "c")
+		}
+	}
+
+	// Errors in cached packages are still reported.
+	if err := os.WriteFile(filepath.Join(dir, "c", "c.go"), []byte(`package c
+
+func C(x int) {
+	// @inco: y != 0
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runExpectPanic(t, e)
+	if err := os.WriteFile(filepath.Join(dir, "a", "a.go"), []byte(`package a
+
+func A(x int) int {
+	// @inco: x > 2
+	return x
+}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, "undefined: y") {
+		t.Errorf("cached error of c should be reported, got: %s", msg)
+	}
+}
+
+func TestVerify_TypecheckCacheAcrossProcesses(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n",
+		"a/a.go": "package a\n\nfunc A(x int) {\n\t// @inco: x > 0\n}\n",
+		"b/b.go": "package b\n\nfunc B(x int) {\n\t// @inco: y > 0\n}\n",
+	})
+	newEngine := func() *Engine {
+		e := NewEngine(dir)
+		e.Typecheck, e.Quiet = true, true
+		return e
+	}
+	runExpectPanic(t, newEngine())
+
+	// A new engine reuses typecheck.json: nothing is typechecked again,
+	// and the cached error is still reported.
+	e := newEngine()
+	if msg := runExpectPanic(t, e); !strings.Contains(msg, "undefined: y") {
+		t.Errorf("cached error should be reported, got: %s", msg)
+	}
+	if len(e.typechecked) != 2 {
+		t.Fatalf("loaded %d packages, want 2", len(e.typechecked))
+	}
+	for dir, c := range e.typechecked {
+		if c.run != 0 {
+			t.Errorf("%s was typechecked again without changes", dir)
+		}
+	}
+
+	// Other build settings invalidate the whole file.
+	t.Setenv("GOFLAGS", "-tags=other")
+	e = newEngine()
+	runExpectPanic(t, e)
+	for dir, c := range e.typechecked {
+		if c.run == 0 {
+			t.Errorf("%s reused under different GOFLAGS", dir)
+		}
+	}
+}
+
+func TestVerify_TypecheckBounds(t *testing.T) {
+	files := map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n"}
+	for _, pkg := range []string{"a", "b", "c"} {
+		files[pkg+"/"+pkg+".go"] = fmt.Sprintf("package %s\n\nfunc F(x int) {\n\t// @inco: x != 0\n}\n", pkg)
+	}
+
+	t.Run("cache size", func(t *testing.T) {
+		dir := setupDir(t, files)
+		e := NewEngine(dir)
+		e.Typecheck, e.Quiet, e.TypecheckCache = true, true, 2
+		e.Run()
+		if len(e.typechecked) != 2 {
+			t.Errorf("kept %d packages, want 2", len(e.typechecked))
+		}
+		e.Run() // the evicted package is typechecked again
+		if len(e.typechecked) != 2 {
+			t.Errorf("kept %d packages after second run, want 2", len(e.typechecked))
+		}
+	})
+
+	t.Run("max memory", func(t *testing.T) {
+		dir := setupDir(t, files)
+		e := NewEngine(dir)
+		e.Typecheck, e.Quiet, e.MaxMemory = true, true, 1
+		msg := runExpectPanic(t, e)
+		if !strings.Contains(msg, "exceeds max memory") || !strings.Contains(msg, "heaviest packages") {
+			t.Errorf("unexpected error: %s", msg)
+		}
+		if _, err := os.Stat(filepath.Join(e.CacheDir, "overlay.json")); err == nil {
+			t.Error("overlay.json written despite exceeding max memory")
+		}
+	})
+}
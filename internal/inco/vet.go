@@ -0,0 +1,446 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Diagnostic types
+// ---------------------------------------------------------------------------
+
+// Diagnostic is a problem found in a directive, optionally with fixes.
+type Diagnostic struct {
+	Path     string         `json:"path"`   // absolute path
+	Line     int            `json:"line"`   // 1-based
+	Column   int            `json:"column"` // 1-based, in bytes
+	Code     Code           `json:"code"`
+	Severity Severity       `json:"severity"`
+	Message  string         `json:"message"`
+	Fixes    []SuggestedFix `json:"suggested_fixes,omitempty"`
+}
+
+// Code identifies the check behind a Diagnostic. Codes are stable; the
+// messages are not.
+type Code string
+
+const (
+	CodeFile           Code = "file"            // the file cannot be read or parsed
+	CodeGenerate       Code = "generate"        // inco gen failed on the file (Engine.Failures)
+	CodeMissingSpace   Code = "missing-space"   // "@inco:expr"
+	CodeMalformed      Code = "malformed"       // a directive that does not parse
+	CodeInvalidExpr    Code = "invalid-expr"    // a condition that is not a Go expression
+	CodeNotInjectable  Code = "not-injectable"  // no body, or a restricted context
+	CodeUndeclared     Code = "undeclared"      // @ensure -closed of an undeclared name
+	CodeChannel        Code = "channel"         // -buffered, -recvonly, -sendonly
+	CodeIndex          Code = "index"           // -idx
+	CodeInvariant      Code = "invariant"       // @invariant outside a loop
+	CodeCtxHas         Code = "ctxhas"          // ctxhas without a context
+	CodeReturn         Code = "return"          // -return not matching the results
+	CodeBranch         Code = "branch"          // -continue or -break with nothing to leave
+	CodeDefault        Code = "default"         // -default without a target
+	CodePanicMessage   Code = "panic-message"   // a -panic message that is not an expression
+	CodeShadowedParam  Code = "shadowed-param"  // a parameter a local declaration hides
+	CodeEntryResult    Code = "entry-result"    // a named result read before it is assigned
+	CodeEntry          Code = "entry"           // @entry of a name not at its entry value
+	CodeOld            Code = "old"             // an old() of @ensure that cannot be copied
+	CodeOldPointer     Code = "old-pointer"     // old(p) of a pointer, read through
+	CodeValueReceiver  Code = "value-receiver"  // old() of a value receiver's copy
+	CodeCopyLock       Code = "copy-lock"       // old() copying a lock
+	CodeUnsatisfiable  Code = "unsatisfiable"   // contradicting contracts
+	CodeMust           Code = "must"            // @must not on a channel operation
+	CodeBinding        Code = "binding"         // -> not next to its statement
+	CodeSideEffect     Code = "side-effect"     // a contract changing program state
+	CodeMissingMessage Code = "missing-message" // -require-messages
+	CodeUncovered      Code = "uncovered"       // -require-contracts
+	CodeImpure         Code = "impure"          // -require-pure
+)
+
+// Severity is how much a Diagnostic matters. inco vet fails on both; an
+// editor may show them apart.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // the contract is ignored, rejected or wrong
+	SeverityWarning Severity = "warning" // the contract works, but likely not as meant
+)
+
+// severity returns the severity of the diagnostics with code c: warnings
+// for the opt-in rules, shadowed parameters, named results read at entry
+// and snapshots that likely miss what they mean to copy, errors otherwise.
+func (c Code) severity() Severity {
+	switch c {
+	case CodeShadowedParam, CodeEntryResult, CodeOldPointer, CodeValueReceiver, CodeMissingMessage, CodeUncovered, CodeImpure:
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// SuggestedFix is a set of edits that resolves a Diagnostic.
+type SuggestedFix struct {
+	Message string     `json:"message"`
+	Edits   []TextEdit `json:"edits"`
+}
+
+// TextEdit replaces the bytes [Offset, End) of the diagnostic's file with
+// NewText. Offsets are relative to the file as stored on disk.
+type TextEdit struct {
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	NewText string `json:"new_text"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.Path, d.Line, d.Column, d.Message)
+}
+
+// ---------------------------------------------------------------------------
+// Vet entry point
+// ---------------------------------------------------------------------------
+
+// missingSpaceRe matches a directive body that lacks the space after
+// "@inco:", which ParseDirective silently ignores.
+var missingSpaceRe = regexp.MustCompile(`^@inco:\S`)
+
+// Vet checks every directive under root and returns the problems found,
+// sorted by position. Problems with a mechanical solution carry a
+// SuggestedFix that ApplyFixes can apply:
+//
+//   - "@inco:expr" missing the space after the colon
+//   - a -panic message that is not a Go expression (missing quotes)
+//   - an @ensure -closed identifier that is not declared, but close to
+//     one that is
+//
+// Contracts that contradict earlier ones in the same block, such as
+// x < 5 after x > 10, are reported too (see vetSatisfiable), and so are
+// contracts that change program state (see vetSideEffects).
+func Vet(root string) []Diagnostic {
+	return VetWith(root, VetOptions{})
+}
+
+// VetOptions enables the optional vet rules.
+type VetOptions struct {
+	// RequireMessages reports contracts in exported functions that panic
+	// with the generated message, or an empty one, instead of a message
+	// written for callers (see vetMessages).
+	RequireMessages bool
+
+	// RequireContracts reports exported functions without a contract, the
+	// functions inco audit -annotate marks (see vetUncovered).
+	RequireContracts bool
+
+	// RequirePure reports contracts calling functions not known to be
+	// free of side effects (see vetPurity).
+	RequirePure bool
+}
+
+// VetWith is Vet with the optional rules opts enables.
+func VetWith(root string, opts VetOptions) []Diagnostic {
+	_ = root // @inco: root != "", -panic("Vet: root must not be empty")
+	if !(root != "") {
+		panic("Vet: root must not be empty")
+	}
+	var diags []Diagnostic
+	fset := token.NewFileSet()
+	walkGoFiles(root, false, func(path string) error {
+		diags = append(diags, vetFile(fset, path, opts)...)
+		return nil
+	})
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Path != diags[j].Path {
+			return diags[i].Path < diags[j].Path
+		}
+		return diags[i].Line < diags[j].Line
+	})
+	return diags
+}
+
+// VetFile is VetWith for the single file at path. Unlike VetWith it does
+// not consult .incoignore; go/analysis drivers use it on the files of the
+// package they load.
+func VetFile(path string, opts VetOptions) []Diagnostic {
+	return vetFile(token.NewFileSet(), path, opts)
+}
+
+// vetFile returns the diagnostics for the directives in a single file.
+func vetFile(fset *token.FileSet, path string, opts VetOptions) []Diagnostic {
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -return([]Diagnostic{{Path: path, Line: 1, Column: 1, Code: CodeFile, Severity: SeverityError, Message: err.Error()}})
+	if !(err == nil) {
+		return []Diagnostic{{Path: path, Line: 1, Column: 1, Code: CodeFile, Severity: SeverityError, Message: err.Error()}}
+	}
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	_ = err // @inco: err == nil, -return([]Diagnostic{{Path: path, Line: 1, Column: 1, Code: CodeFile, Severity: SeverityError, Message: err.Error()}})
+	if !(err == nil) {
+		return []Diagnostic{{Path: path, Line: 1, Column: 1, Code: CodeFile, Severity: SeverityError, Message: err.Error()}}
+	}
+	joined := joinContinuations(f, fset)
+
+	var diags []Diagnostic
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			cd := vetComment(f, fset, path, c)
+			if joined[c] {
+				// Offsets into the merged text do not map back to the
+				// source lines.
+				for i := range cd {
+					cd[i].Fixes = nil
+				}
+			}
+			diags = append(diags, cd...)
+		}
+	}
+	lines := splitLines(src)
+	diags = append(diags, vetSatisfiable(f, fset, path, lines)...)
+	diags = append(diags, vetMusts(f, fset, path, lines)...)
+	diags = append(diags, vetBindings(f, fset, path, lines)...)
+	diags = append(diags, vetSideEffects(f, fset, path, lines)...)
+	if opts.RequireMessages {
+		diags = append(diags, vetMessages(f, fset, path, lines)...)
+	}
+	if opts.RequireContracts {
+		diags = append(diags, vetUncovered(f, fset, path)...)
+	}
+	if opts.RequirePure {
+		diags = append(diags, vetPurity(f, fset, path, lines)...)
+	}
+	for i := range diags {
+		diags[i].Severity = diags[i].Code.severity()
+	}
+	return diags
+}
+
+// vetComment returns the diagnostics for a single comment.
+func vetComment(f *ast.File, fset *token.FileSet, path string, c *ast.Comment) []Diagnostic {
+	pos := fset.PositionFor(c.Pos(), false)
+	// at returns a diagnostic with code positioned at byte i of the comment
+	// text.
+	at := func(code Code, i int, msg string, fixes ...SuggestedFix) Diagnostic {
+		return Diagnostic{Path: path, Line: pos.Line, Column: pos.Column + i, Code: code, Message: msg, Fixes: fixes}
+	}
+	// edit returns a fix replacing text[i:j] of the comment with s.
+	edit := func(msg string, i, j int, s string) SuggestedFix {
+		return SuggestedFix{Message: msg, Edits: []TextEdit{{Offset: pos.Offset + i, End: pos.Offset + j, NewText: s}}}
+	}
+
+	// A directive documenting a function without a body can never be
+	// injected.
+	if fn := bodilessFunc(f, c); fn != nil && len(ParseDirectives(c.Text)) > 0 {
+		return []Diagnostic{at(CodeNotInjectable, strings.Index(c.Text, "@"), "contract cannot be injected: "+bodilessWhy(fn))}
+	}
+
+	// Outside function bodies directives are never injected (see
+	// generateShadow), so syntax examples in doc comments are not checked.
+	_, inBody := enclosingBodyEnd(f, fset, pos.Line)
+	_ = inBody // @inco: inBody, -return(nil)
+	if !(inBody) {
+		return nil
+	}
+
+	if body := stripComment(c.Text); missingSpaceRe.MatchString(body) {
+		i := strings.Index(c.Text, "@inco:") + len("@inco:")
+		return []Diagnostic{at(CodeMissingSpace, i, "missing space after @inco: (directive is ignored)",
+			edit("insert space", i, i, " "))}
+	}
+
+	ds := ParseDirectives(c.Text)
+	if body := stripComment(c.Text); ds == nil && strings.HasPrefix(body, "@inco:") {
+		return []Diagnostic{at(CodeMalformed, strings.Index(c.Text, "@inco:"), "malformed directive (ignored): a ;-separated segment is empty or invalid")}
+	}
+	if why := restrictedContext(f, fset, path, pos.Line); why != "" && len(ds) > 0 {
+		return []Diagnostic{at(CodeNotInjectable, strings.Index(c.Text, "@"), "contract cannot be injected: "+why)}
+	}
+	var diags []Diagnostic
+	for _, d := range ds {
+		if d.Kind == KindEnsureClosed {
+			declared := declaredBefore(f, fset, pos.Line+1)
+			_ = declared // @inco: !declared[d.Expr], -continue
+			if !(!declared[d.Expr]) {
+				continue
+			}
+			i := strings.LastIndex(c.Text, "-closed "+d.Expr) + len("-closed ")
+			diag := at(CodeUndeclared, i, fmt.Sprintf("@ensure -closed %s: %s is not declared in the enclosing function", d.Expr, d.Expr))
+			if name := closestName(d.Expr, declared); name != "" {
+				diag.Fixes = append(diag.Fixes, edit(fmt.Sprintf("replace %s with %s", d.Expr, name), i, i+len(d.Expr), name))
+			}
+			diags = append(diags, diag)
+			continue
+		}
+
+		for _, problem := range vetChannels(f, fset, pos.Line, d) {
+			diags = append(diags, at(CodeChannel, strings.Index(c.Text, "@"), problem))
+		}
+		for _, problem := range vetIndex(f, fset, pos.Line, d) {
+			i := strings.Index(c.Text, "-idx")
+			if i < 0 {
+				i = strings.Index(c.Text, "@")
+			}
+			diags = append(diags, at(CodeIndex, i, problem))
+		}
+		if d.Kind == KindInvariant && enclosingLoopBody(f, fset, pos.Line) == nil {
+			diags = append(diags, at(CodeInvariant, strings.Index(c.Text, "@invariant"), fmt.Sprintf("@invariant %s is not inside a for loop body", d.Expr)))
+		}
+		if !d.Kind.checksExpr() {
+			continue
+		}
+		for _, s := range shadowedParams(f, fset, c.Pos(), d.Cond, d.Expr) {
+			diags = append(diags, at(CodeShadowedParam, strings.Index(c.Text, "@"), s.String()))
+		}
+		for _, r := range entryReads(f, fset, pos.Line, d) {
+			diags = append(diags, at(CodeEntryResult, strings.Index(c.Text, "@"), r.String()))
+		}
+		if err := entryError(f, fset, pos.Line, d); err != nil {
+			diags = append(diags, at(CodeEntry, strings.Index(c.Text, "@entry("), err.Error()))
+		}
+		for _, p := range vetEnsure(f, fset, path, pos.Line, d) {
+			diags = append(diags, at(p.code, strings.Index(c.Text, oldName+"("), p.msg))
+		}
+		for _, s := range []string{d.Cond, d.Expr} {
+			if _, err := parser.ParseExpr(s); s != "" && err != nil {
+				diags = append(diags, at(CodeInvalidExpr, strings.Index(c.Text, s), fmt.Sprintf("invalid expression %q: %v", s, err)))
+			}
+		}
+		for _, s := range []string{d.Cond, d.Expr} {
+			if _, err := expandCtxHas(s, contextParam(f, fset, pos.Line)); err != nil {
+				diags = append(diags, at(CodeCtxHas, strings.Index(c.Text, ctxHasName+"("), err.Error()))
+			}
+		}
+		if d.Action == ActionReturn {
+			ft, _ := enclosingFunc(f, fset, pos.Line)
+			if _, err := returnValues(d, ft); err != nil {
+				diags = append(diags, at(CodeReturn, strings.Index(c.Text, "-return"), err.Error()))
+			}
+		}
+		if err := branchError(f, fset, pos.Line, d); err != nil {
+			diag := at(CodeBranch, strings.Index(c.Text, "-"+d.Action.String()), err.Error())
+			if len(d.ActionArgs) == 1 {
+				label := d.ActionArgs[0]
+				if name := closestName(label, branchLabels(f, fset, pos.Line, d.Action)); name != "" {
+					i := strings.Index(c.Text, "-"+d.Action.String()+"("+label) + len(d.Action.String()) + 2
+					diag.Fixes = append(diag.Fixes, edit(fmt.Sprintf("replace %s with %s", label, name), i, i+len(label), name))
+				}
+			}
+			diags = append(diags, diag)
+		}
+		if d.Action == ActionDefault && d.Target == "" {
+			diags = append(diags, at(CodeDefault, strings.Index(c.Text, "-default("), noDefaultTarget(d)))
+		}
+		if d.Action == ActionPanic && len(d.ActionArgs) == 1 {
+			arg := d.ActionArgs[0]
+			if _, err := parser.ParseExpr(arg); err != nil {
+				i := strings.Index(c.Text, "-panic("+arg) + len("-panic(")
+				diags = append(diags, at(CodePanicMessage, i, fmt.Sprintf("panic message %q is not a Go expression", arg),
+					edit("quote the message", i, i+len(arg), strconv.Quote(arg))))
+			}
+		}
+	}
+	return diags
+}
+
+// closestName returns the name in names within edit distance 2 of target,
+// preferring the closest and then the alphabetically first; "" if none.
+func closestName(target string, names map[string]bool) string {
+	best, bestDist := "", 3
+	for name := range names {
+		dist := editDistance(target, name)
+		if dist < bestDist || (dist == bestDist && name < best) {
+			best, bestDist = name, dist
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// ---------------------------------------------------------------------------
+// Applying fixes
+// ---------------------------------------------------------------------------
+
+// ApplyFixes applies the first suggested fix of every diagnostic that has
+// one and returns the diagnostics that remain unfixed. Edits overlapping an
+// edit already applied to the same file are skipped and their diagnostic
+// is kept.
+func ApplyFixes(diags []Diagnostic) []Diagnostic {
+	var remaining []Diagnostic
+	byFile := make(map[string][]Diagnostic)
+	var order []string
+	for _, d := range diags {
+		if len(d.Fixes) == 0 {
+			remaining = append(remaining, d)
+			continue
+		}
+		if _, ok := byFile[d.Path]; !ok {
+			order = append(order, d.Path)
+		}
+		byFile[d.Path] = append(byFile[d.Path], d)
+	}
+	for _, path := range order {
+		remaining = append(remaining, applyFileFixes(path, byFile[path])...)
+	}
+	return remaining
+}
+
+// applyFileFixes applies the first fix of each diagnostic to path, back to
+// front so earlier offsets stay valid, and returns the diagnostics whose
+// fix could not be applied.
+func applyFileFixes(path string, diags []Diagnostic) []Diagnostic {
+	src, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Fixes[0].Edits[0].Offset > diags[j].Fixes[0].Edits[0].Offset
+	})
+
+	var skipped []Diagnostic
+	limit := len(src) // edits must end at or before the previous edit's start
+	for _, d := range diags {
+		edits := d.Fixes[0].Edits
+		ok := true
+		for _, ed := range edits {
+			ok = ok && ed.Offset <= ed.End && ed.End <= limit
+		}
+		if !ok {
+			skipped = append(skipped, d)
+			continue
+		}
+		for k := len(edits) - 1; k >= 0; k-- {
+			ed := edits[k]
+			src = append(src[:ed.Offset], append([]byte(ed.NewText), src[ed.End:]...)...)
+			limit = min(limit, ed.Offset)
+		}
+	}
+	err = os.WriteFile(path, src, 0o644)
+	_ = err // @inco: err == nil, -panic(err)
+	if !(err == nil) {
+		panic(err)
+	}
+	return skipped
+}
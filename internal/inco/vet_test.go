@@ -0,0 +1,138 @@
+package inco
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Vet diagnostics
+// ---------------------------------------------------------------------------
+
+const vetSrc = `package main
+
+import "os"
+
+// Doc examples are not checked:
+//
+//	@inco: <expr>
+func Do(x int, path string) {
+	// @inco:x > 0
+	// @inco: x >
+	// @inco: x != 0, -panic(x must be set)
+	file, _ := os.Open(path)
+	// @ensure -closed fil
+	defer file.Close()
+}
+`
+
+func TestVet_Diagnostics(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": vetSrc})
+	diags := Vet(dir)
+	want := []struct {
+		line    int
+		message string
+		fix     string
+	}{
+		{9, "missing space after @inco:", "insert space"},
+		{10, `invalid expression "x >"`, ""},
+		{11, `panic message "x must be set" is not a Go expression`, "quote the message"},
+		{13, "@ensure -closed fil: fil is not declared", "replace fil with file"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(diags), len(want), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.Line != w.line || !strings.Contains(d.Message, w.message) {
+			t.Errorf("diag %d = %v, want line %d containing %q", i, d, w.line, w.message)
+		}
+		var fix string
+		if len(d.Fixes) > 0 {
+			fix = d.Fixes[0].Message
+		}
+		if fix != w.fix {
+			t.Errorf("diag %d fix = %q, want %q", i, fix, w.fix)
+		}
+	}
+}
+
+func TestVet_ApplyFixes(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": vetSrc})
+	remaining := ApplyFixes(Vet(dir))
+	if len(remaining) != 1 || remaining[0].Line != 10 {
+		t.Fatalf("only the invalid expression should remain, got %v", remaining)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"\t// @inco: x > 0\n",
+		"\t// @inco: x != 0, -panic(\"x must be set\")\n",
+		"\t// @ensure -closed file\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("fixed source should contain %q, got:\n%s", want, got)
+		}
+	}
+	if again := Vet(dir); len(again) != 1 {
+		t.Errorf("after fixing, Vet should report 1 diagnostic, got %v", again)
+	}
+}
+
+func TestVet_DefaultWithoutVariable(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": "package main\n\nfunc Open(path string, n int) {\n\t// @inco: n > 0, -default(1)\n\t// @inco: len(path) > 0, -default(\"a.txt\")\n}\n"})
+	diags := Vet(dir)
+	if len(diags) != 1 || diags[0].Line != 5 || !strings.Contains(diags[0].Message, `-default("a.txt"): len(path) > 0 compares no variable to assign`) {
+		t.Errorf("got %v", diags)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"f", "", 1},
+		{"fil", "file", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVet_RequireMessages(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func Open(name string, n int) {
+	// @inco: name != ""
+	// @inco: n > 0, -panic("")
+	// @inco: n < 100, -panic("open: too many retries")
+	// @inco: n != 7, -return
+	_ = name
+}
+
+func open(name string) {
+	// @inco: name != ""
+}
+`})
+	if diags := Vet(dir); len(diags) != 0 {
+		t.Fatalf("rule is off by default, got %v", diags)
+	}
+	diags := VetWith(dir, VetOptions{RequireMessages: true})
+	if len(diags) != 2 || diags[0].Line != 4 || diags[1].Line != 5 || diags[0].Column != 5 {
+		t.Fatalf("want lines 4 and 5, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Message, `contract name != "" in an exported function has no message`) {
+		t.Errorf("message = %q", diags[0].Message)
+	}
+}
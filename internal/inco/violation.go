@@ -0,0 +1,232 @@
+package inco
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/imnive-design/inco-go/incolog"
+)
+
+// ---------------------------------------------------------------------------
+// Detecting contract violations in program output
+// ---------------------------------------------------------------------------
+
+var (
+	// frameLocRe matches the location line of a goroutine trace frame.
+	// Group 1: file
+	// Group 2: line
+	frameLocRe = regexp.MustCompile(`^\t(.+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`)
+
+	// defaultMsgRe matches the location in the default violation message.
+	// Group 1: file
+	// Group 2: line
+	defaultMsgRe = regexp.MustCompile(`^inco violation(?: in init)?: .* \(at (.+):(\d+)\)$`)
+
+	// recoveredRe matches the suffix of a panic recovered and raised
+	// again, as by the testing package: " [recovered]" or
+	// " [recovered, repanicked]".
+	recoveredRe = regexp.MustCompile(` \[recovered[^\]]*\]$`)
+)
+
+// Violation is a panic raised by an injected contract, found in the
+// output of go run or go test.
+type Violation struct {
+	Message   string       // the panic value as the runtime printed it
+	Path      string       // file of the directive, relative to the root
+	Line      int          // line of the directive
+	Contracts []Annotation // the contracts on that line; nil when unknown
+}
+
+func (v Violation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%d: %s", v.Path, v.Line, v.Message)
+	for _, a := range v.Contracts {
+		fmt.Fprintf(&b, "\n\t%s", a.Text)
+	}
+	return b.String()
+}
+
+// ViolationScanner is an io.Writer that reads one output stream of go run
+// or go test and records the panics whose goroutine trace starts at a
+// directive. A panic with a custom
+// -panic(...) message is recognised by its location, one with the
+// default message also by its text.
+type ViolationScanner struct {
+	root  string
+	items map[string][]Annotation // "relpath:line" → contracts
+	line  []byte                  // incomplete last line
+
+	// The panic being read.
+	inPanic, inTrace bool
+	message          string
+	pending          int    // location lines to read before the one that raised the panic
+	loc              string // "file:line" of that location
+	Violations       []Violation
+}
+
+// NewViolationScanner returns a scanner for programs built with the
+// overlay of the engine's last Run.
+func (e *Engine) NewViolationScanner() *ViolationScanner {
+	return &ViolationScanner{root: e.Root, items: e.loadAnnotations()}
+}
+
+// Write scans the complete lines in p; it never fails.
+func (s *ViolationScanner) Write(p []byte) (int, error) {
+	s.line = append(s.line, p...)
+	for {
+		i := bytes.IndexByte(s.line, '\n')
+		if i < 0 {
+			break
+		}
+		s.scanLine(strings.TrimSuffix(string(s.line[:i]), "\r"))
+		s.line = s.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close scans a final line without a newline and ends the trace being
+// read.
+func (s *ViolationScanner) Close() error {
+	if len(s.line) > 0 {
+		s.scanLine(string(s.line))
+		s.line = nil
+	}
+	s.endPanic()
+	return nil
+}
+
+// scanLine advances the panic being read by one line of output.
+func (s *ViolationScanner) scanLine(line string) {
+	switch {
+	case !s.inPanic:
+		if msg, ok := strings.CutPrefix(line, "panic: "); ok {
+			s.inPanic, s.message, s.pending, s.loc = true, recoveredRe.ReplaceAllString(msg, ""), 1, ""
+		}
+	case !s.inTrace:
+		// Nested "panic: ..." lines of re-panics precede the trace.
+		if strings.HasPrefix(line, "goroutine ") {
+			s.inTrace = true
+		}
+	case strings.HasPrefix(line, "panic("):
+		s.pending = 2 // a re-panic: the frame after this one raised the panic
+	case strings.HasPrefix(line, "\t"):
+		m := frameLocRe.FindStringSubmatch(line)
+		if m == nil {
+			s.endPanic()
+		} else if s.pending--; s.pending == 0 {
+			s.loc = m[1] + ":" + m[2]
+		}
+	case line == "" || strings.HasPrefix(line, "goroutine "):
+		s.endPanic()
+	}
+}
+
+// endPanic records the panic being read if it was raised by a contract.
+func (s *ViolationScanner) endPanic() {
+	_ = s.inPanic // @inco: s.inPanic, -return
+	if !(s.inPanic) {
+		return
+	}
+	s.inPanic, s.inTrace = false, false
+
+	if file, line, ok := cutLocation(s.loc); ok {
+		if key := s.directiveKey(file, line); key != "" {
+			path, _, _ := cutLocation(key)
+			s.Violations = append(s.Violations, Violation{Message: s.message, Path: path, Line: line, Contracts: s.items[key]})
+			return
+		}
+	}
+	if m := defaultMsgRe.FindStringSubmatch(s.message); m != nil {
+		line, _ := strconv.Atoi(m[2])
+		v := Violation{Message: s.message, Path: m[1], Line: line}
+		if key := s.directiveKey(m[1], line); key != "" {
+			v.Contracts = s.items[key]
+		}
+		s.Violations = append(s.Violations, v)
+	}
+}
+
+// directiveKey returns the annotations key of file:line, or "" when no
+// directive is there. A relative file (from a -trimpath build) matches
+// the key it ends with.
+func (s *ViolationScanner) directiveKey(file string, line int) string {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(s.root, file)
+		_ = err // @inco: err == nil, -return("")
+		if !(err == nil) {
+			return ""
+		}
+		file = rel
+	}
+	file = filepath.ToSlash(file)
+	suffix := fmt.Sprintf(":%d", line)
+	for key := range s.items {
+		rel, ok := strings.CutSuffix(key, suffix)
+		if ok && (rel == file || strings.HasSuffix(file, "/"+rel)) {
+			return key
+		}
+	}
+	return ""
+}
+
+// cutLocation splits "file:line".
+func cutLocation(loc string) (file string, line int, ok bool) {
+	i := strings.LastIndexByte(loc, ':')
+	_ = i // @inco: i > 0, -return("", 0, false)
+	if !(i > 0) {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(loc[i+1:])
+	return loc[:i], n, err == nil
+}
+
+// ---------------------------------------------------------------------------
+// Violation logs (-log-violations)
+// ---------------------------------------------------------------------------
+
+// maxTallyMessages caps the distinct messages a ViolationTally keeps.
+const maxTallyMessages = 3
+
+// ViolationTally counts the logged violations of one contract.
+type ViolationTally struct {
+	Kind     string   // "require", "invariant", "ensure" or "ensure-closed"
+	Path     string   // file of the directive, relative to the root
+	Line     int      // line of the directive
+	Expr     string   // the contract's expression
+	Count    int      // violations logged
+	Messages []string // distinct -panic messages, at most maxTallyMessages
+}
+
+// TallyViolations groups the records of a violation log by contract and
+// returns the tallies, most violated first.
+func TallyViolations(records []incolog.Record) []ViolationTally {
+	index := make(map[string]int) // "kind file:line expr" → tallies index
+	var tallies []ViolationTally
+	for _, r := range records {
+		key := fmt.Sprintf("%s %s:%d %s", r.Kind, r.File, r.Line, r.Expr)
+		i, ok := index[key]
+		if !ok {
+			i = len(tallies)
+			index[key] = i
+			tallies = append(tallies, ViolationTally{Kind: r.Kind, Path: r.File, Line: r.Line, Expr: r.Expr})
+		}
+		t := &tallies[i]
+		t.Count++
+		if r.Message != "" && len(t.Messages) < maxTallyMessages && !slices.Contains(t.Messages, r.Message) {
+			t.Messages = append(t.Messages, r.Message)
+		}
+	}
+	slices.SortStableFunc(tallies, func(a, b ViolationTally) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Or(strings.Compare(a.Path, b.Path), cmp.Compare(a.Line, b.Line))
+	})
+	return tallies
+}
@@ -0,0 +1,72 @@
+package inco
+
+import (
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Violation detection
+// ---------------------------------------------------------------------------
+
+const testPanicOutput = `--- FAIL: TestF (0.00s)
+panic: x must be positive [recovered, repanicked]
+
+goroutine 6 [running]:
+testing.tRunner.func1.2({0x6b3fa8, 0x563570})
+	/usr/local/go/src/testing/testing.go:2123 +0x232
+panic({0x6b3fa8?, 0x563570?})
+	/usr/local/go/src/runtime/panic.go:859 +0x125
+m.F(...)
+	/src/m/main.go:4
+m.TestF(0x2d6a599bc248?)
+	/src/m/main_test.go:6 +0x25
+FAIL	m	0.004s
+`
+
+func TestViolationScanner(t *testing.T) {
+	items := map[string][]Annotation{"main.go:4": {{Kind: "require", Expr: "x > 0", Text: "Precondition: requires x > 0."}}}
+	tests := []struct {
+		name, output string
+		want         []string
+	}{
+		{"test re-panic", testPanicOutput, []string{"main.go:4: x must be positive\n\tPrecondition: requires x > 0."}},
+		{"program", "panic: x must be positive\n\ngoroutine 1 [running]:\nmain.F(...)\n\t/src/m/main.go:4\nmain.main()\n\t/src/m/main.go:8 +0x25\nexit status 2\n",
+			[]string{"main.go:4: x must be positive\n\tPrecondition: requires x > 0."}},
+		{"trimpath", "panic: boom\n\ngoroutine 1 [running]:\nmain.F(...)\n\tm/main.go:4 +0x1\n",
+			[]string{"main.go:4: boom\n\tPrecondition: requires x > 0."}},
+		{"default message", "panic: inco violation: n > 0 (at lib/n.go:9)\n\ngoroutine 1 [running]:\nmain.main()\n\t/elsewhere/n.go:9 +0x1\n",
+			[]string{"lib/n.go:9: inco violation: n > 0 (at lib/n.go:9)"}},
+		{"ordinary panic", "panic: 1\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/m/main.go:2 +0x25\n", nil},
+	}
+	for _, tt := range tests {
+		s := &ViolationScanner{root: "/src/m", items: items}
+		// Split writes mid-line, as a pipe may.
+		for i := 0; i < len(tt.output); i += 7 {
+			s.Write([]byte(tt.output[i:min(i+7, len(tt.output))]))
+		}
+		s.Close()
+		var got []string
+		for _, v := range s.Violations {
+			got = append(got, v.String())
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEngine_PanicMapsToDirective(t *testing.T) {
+	dir := setupDir(t, map[string]string{"main.go": `package main
+
+func F(x int) {
+	_ = x // @inco: x > 0, -panic("x must be positive")
+}
+`})
+	e := NewEngine(dir)
+	e.Run()
+	want := "\tif !(x > 0) {\n//line " + dir + "/main.go:4\n\t\tpanic(\"x must be positive\")\n\t}\n//line " + dir + "/main.go:5\n}"
+	if shadow := readShadow(t, e); !strings.Contains(shadow, want) {
+		t.Errorf("panic should map to the directive line:\n%s", shadow)
+	}
+}
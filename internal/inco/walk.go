@@ -6,16 +6,29 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 )
 
 // walkGoFiles walks root and calls fn for each non-test .go file that is
 // not excluded by skipDirRe or .incoignore. It handles directory skipping,
 // file filtering, and ignore-list matching in a single place so that
-// engine and audit share the same traversal logic.
+// engine and audit share the same traversal logic. With vendor, vendor
+// directories are walked too.
 //
 // Nested .incoignore files in subdirectories are supported: rules in a
 // child directory apply only to that subtree.
-func walkGoFiles(root string, fn func(path string) error) error {
+func walkGoFiles(root string, vendor bool, fn func(path string) error) error {
+	return walkFiles(root, vendor, false, fn)
+}
+
+// walkTestFiles is walkGoFiles for the _test.go files.
+func walkTestFiles(root string, vendor bool, fn func(path string) error) error {
+	return walkFiles(root, vendor, true, fn)
+}
+
+// walkFiles is walkGoFiles, for the test files with tests and for the
+// others without.
+func walkFiles(root string, vendor, tests bool, fn func(path string) error) error {
 	ig := NewIgnoreTree(root)
 
 	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
@@ -26,7 +39,7 @@ func walkGoFiles(root string, fn func(path string) error) error {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/walk.inco.go:21
 		if d.IsDir() {
 			name := d.Name()
-			skip := skipDirRe.MatchString(name)
+			skip := skipDirRe.MatchString(name) && !(vendor && name == "vendor")
 			_ = skip // @inco: !skip, -return(filepath.SkipDir)
 			if !(!skip) {
 				return filepath.SkipDir
@@ -42,7 +55,7 @@ func walkGoFiles(root string, fn func(path string) error) error {
 //line /Users/hitomikirigiri/Desktop/imnive/inco/internal/inco/walk.inco.go:29
 			return nil
 		}
-		isGoSource := goSourceRe.MatchString(d.Name()) && !testFileRe.MatchString(d.Name())
+		isGoSource := goSourceRe.MatchString(d.Name()) && testFileRe.MatchString(d.Name()) == tests
 		_ = isGoSource // @inco: isGoSource, -return(nil)
 		if !(isGoSource) {
 			return nil
@@ -59,11 +72,12 @@ func walkGoFiles(root string, fn func(path string) error) error {
 }
 
 // collectGoFiles returns all non-test .go file paths under root,
-// respecting skipDirRe and .incoignore. This is a convenience wrapper
-// around walkGoFiles for callers that need the full path list up front.
-func collectGoFiles(root string) []string {
+// respecting skipDirRe (vendor aside, with vendor) and .incoignore. This is
+// a convenience wrapper around walkGoFiles for callers that need the full
+// path list up front.
+func collectGoFiles(root string, vendor bool) []string {
 	var paths []string
-	walkGoFiles(root, func(path string) error {
+	walkGoFiles(root, vendor, func(path string) error {
 		paths = append(paths, path)
 		return nil
 	})
@@ -78,6 +92,17 @@ func collectGoFiles(root string) []string {
 // hidden dirs (starting with .), vendor, testdata.
 var skipDirRe = regexp.MustCompile(`^\.|^vendor$|^testdata$`)
 
+// inVendor reports whether the slash- or OS-separated relative path rel
+// lies in a vendor directory.
+func inVendor(rel string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == "vendor" {
+			return true
+		}
+	}
+	return false
+}
+
 // goSourceRe matches .go filenames.
 var goSourceRe = regexp.MustCompile(`^.+\.go$`)
 
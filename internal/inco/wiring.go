@@ -0,0 +1,253 @@
+package inco
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------------------------------------------------------
+// Wire-up safety: constructor contracts on injected dependencies
+// ---------------------------------------------------------------------------
+
+// Wireup is a constructor, a function named New… returning a struct type
+// of its package, and the dependencies it injects: the pointer and
+// interface fields it sets from its parameters.
+type Wireup struct {
+	Type   string // struct type built, e.g. "Server"
+	Func   string // constructor, e.g. "NewServer"
+	Dir    string // package directory relative to root, "." for the root package
+	Path   string // file relative to root
+	Line   int    // line of the constructor declaration
+	Fields []WiredField
+}
+
+// WiredField is a dependency a constructor stores in its struct.
+type WiredField struct {
+	Name    string // struct field
+	Param   string // constructor parameter stored in it
+	Kind    string // "pointer" or "interface"
+	Checked bool   // a contract in the constructor mentions the parameter or the field
+}
+
+// Unchecked returns the fields of w no contract guards against nil.
+func (w Wireup) Unchecked() []WiredField {
+	var out []WiredField
+	for _, f := range w.Fields {
+		if !f.Checked {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// wiringDecls are the declarations of one file that wireups resolves
+// across its package: struct field types and interface type names.
+type wiringDecls struct {
+	structs    map[string]map[string]ast.Expr // struct type → field → type
+	interfaces map[string]bool
+}
+
+// wiringFacts returns the struct and interface declarations of f, and its
+// constructors with every field they set from a parameter; wireups later
+// keeps the fields whose type can be nil.
+func wiringFacts(f *ast.File, fset *token.FileSet, relPath string, directives map[int][]*Directive) (wiringDecls, []Wireup) {
+	decls := wiringDecls{structs: make(map[string]map[string]ast.Expr), interfaces: make(map[string]bool)}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		_ = ok // @inco: ok && gd.Tok == token.TYPE, -continue
+		if !(ok && gd.Tok == token.TYPE) {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			switch t := ts.Type.(type) {
+			case *ast.InterfaceType:
+				decls.interfaces[ts.Name.Name] = true
+			case *ast.StructType:
+				fields := make(map[string]ast.Expr)
+				for _, field := range t.Fields.List {
+					for _, n := range field.Names {
+						fields[n.Name] = field.Type
+					}
+				}
+				decls.structs[ts.Name.Name] = fields
+			}
+		}
+	}
+
+	var out []Wireup
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		_ = ok // @inco: ok && fn.Recv == nil && fn.Body != nil && strings.HasPrefix(fn.Name.Name, "New"), -continue
+		if !(ok && fn.Recv == nil && fn.Body != nil && strings.HasPrefix(fn.Name.Name, "New")) {
+			continue
+		}
+		typ := constructedType(fn.Type)
+		_ = typ // @inco: typ != "", -continue
+		if !(typ != "") {
+			continue
+		}
+		params := make(map[string]bool)
+		for _, field := range fn.Type.Params.List {
+			for _, n := range field.Names {
+				params[n.Name] = n.Name != "_"
+			}
+		}
+		var mentioned []string // names in the constructor's contracts
+		start, end := srcLine(fset, fn.Body.Lbrace), srcLine(fset, fn.Body.Rbrace)
+		for line, ds := range directives {
+			_ = line // @inco: start <= line && line <= end, -continue
+			if !(start <= line && line <= end) {
+				continue
+			}
+			for _, d := range ds {
+				if d.Kind.checksExpr() {
+					mentioned = append(mentioned, exprIdents(d.Expr)...)
+				}
+			}
+		}
+		w := Wireup{Type: typ, Func: fn.Name.Name, Dir: filepath.ToSlash(filepath.Dir(relPath)), Path: relPath, Line: srcLine(fset, fn.Pos())}
+		set := func(field string, value ast.Expr) {
+			p, ok := ast.Unparen(value).(*ast.Ident)
+			if !ok || !params[p.Name] || slices.ContainsFunc(w.Fields, func(f WiredField) bool { return f.Name == field }) {
+				return
+			}
+			checked := slices.Contains(mentioned, p.Name) || slices.Contains(mentioned, field)
+			w.Fields = append(w.Fields, WiredField{Name: field, Param: p.Name, Checked: checked})
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				if !isIdentNamed(n.Type, typ) {
+					return true
+				}
+				for _, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok && isIdent(kv.Key) {
+						set(kv.Key.(*ast.Ident).Name, kv.Value)
+					}
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if sel, ok := lhs.(*ast.SelectorExpr); ok && len(n.Rhs) == len(n.Lhs) {
+						set(sel.Sel.Name, n.Rhs[i])
+					}
+				}
+			}
+			return true
+		})
+		if len(w.Fields) > 0 {
+			out = append(out, w)
+		}
+	}
+	return decls, out
+}
+
+// constructedType returns the struct type name T of a constructor
+// returning T or *T first, or "".
+func constructedType(ft *ast.FuncType) string {
+	_ = ft // @inco: ft.Results != nil && len(ft.Results.List) > 0, -return("")
+	if !(ft.Results != nil && len(ft.Results.List) > 0) {
+		return ""
+	}
+	t := ft.Results.List[0].Type
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// wireups resolves the constructors of files against the declarations of
+// their package: it keeps the fields of pointer or interface type, and
+// the constructors of struct types left with any. Interfaces declared in
+// other packages are not recognized. The result is sorted by directory,
+// type and constructor.
+func wireups(files []FileAudit) []Wireup {
+	structs := make(map[string]map[string]map[string]ast.Expr) // dir → type → field → type
+	interfaces := make(map[string]map[string]bool)             // dir → interface names
+	for _, f := range files {
+		dir := filepath.ToSlash(filepath.Dir(f.RelPath))
+		if structs[dir] == nil {
+			structs[dir], interfaces[dir] = make(map[string]map[string]ast.Expr), make(map[string]bool)
+		}
+		for name, fields := range f.wiringDecls.structs {
+			structs[dir][name] = fields
+		}
+		for name := range f.wiringDecls.interfaces {
+			interfaces[dir][name] = true
+		}
+	}
+	var out []Wireup
+	for _, f := range files {
+		for _, w := range f.wireups {
+			fields := structs[w.Dir][w.Type]
+			var kept []WiredField
+			for _, wf := range w.Fields {
+				switch t := fields[wf.Name].(type) {
+				case *ast.StarExpr:
+					wf.Kind = "pointer"
+				case *ast.InterfaceType:
+					wf.Kind = "interface"
+				case *ast.Ident:
+					if interfaces[w.Dir][t.Name] {
+						wf.Kind = "interface"
+					}
+				}
+				if wf.Kind != "" {
+					kept = append(kept, wf)
+				}
+			}
+			if len(kept) > 0 {
+				w.Fields = kept
+				out = append(out, w)
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Dir != b.Dir {
+			return a.Dir < b.Dir
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Func < b.Func
+	})
+	return out
+}
+
+// printWireups writes the wire-up safety section: how many injected
+// dependencies their constructors check, then each unchecked one.
+func printWireups(w io.Writer, wireups []Wireup, checked, total int, c Colorizer) {
+	fmt.Fprintf(w, "\n%s\n", c.Bold("Wire-up safety:"))
+	pct := float64(checked) / float64(total) * 100
+	fmt.Fprintf(w, "  Checked:  %d / %d dependencies  (%s)\n", checked, total, c.Percent(pct, 90, 60))
+	for _, wu := range wireups {
+		unchecked := wu.Unchecked()
+		if len(unchecked) == 0 {
+			continue
+		}
+		name := wu.Type
+		if wu.Dir != "." {
+			name = wu.Dir + "." + wu.Type
+		}
+		fmt.Fprintf(w, "\n  %s  %s  (%s:%d)\n", c.Bold(wu.Func), name, wu.Path, wu.Line)
+		fieldW, paramW := 0, 0
+		for _, wf := range unchecked {
+			fieldW = max(fieldW, utf8.RuneCountInString(wf.Name))
+			paramW = max(paramW, utf8.RuneCountInString(wf.Param))
+		}
+		for _, wf := range unchecked {
+			fmt.Fprintf(w, "    %-*s  from %-*s  %s may be nil\n", fieldW, wf.Name, paramW, wf.Param, c.Warning(wf.Kind))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package inco
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Wire-up safety
+// ---------------------------------------------------------------------------
+
+func TestAudit_Wireups(t *testing.T) {
+	dir := setupDir(t, map[string]string{
+		"svc/types.go": `package svc
+
+import "database/sql"
+
+type Store interface{ Get(id string) string }
+
+type Service struct {
+	db    *sql.DB
+	store Store
+	log   interface{ Print(...any) }
+	name  string
+}
+`,
+		"svc/service.go": `package svc
+
+import "database/sql"
+
+func NewService(db *sql.DB, store Store, log interface{ Print(...any) }, name string) *Service {
+	// @inco: db != nil, -panic("nil db")
+	s := &Service{db: db, name: name}
+	s.store = store
+	s.log = log
+	// @inco: s.store != nil
+	return s
+}
+
+func NewDefault() Service {
+	return Service{name: "default"}
+}
+`,
+	})
+	r := Audit(dir, Limits{})
+	if len(r.Wireups) != 1 || r.TotalDependencies != 3 || r.CheckedDependencies != 2 {
+		t.Fatalf("Wireups = %+v, %d of %d checked", r.Wireups, r.CheckedDependencies, r.TotalDependencies)
+	}
+	w := r.Wireups[0]
+	want := []WiredField{
+		{Name: "db", Param: "db", Kind: "pointer", Checked: true},
+		{Name: "store", Param: "store", Kind: "interface", Checked: true},
+		{Name: "log", Param: "log", Kind: "interface"},
+	}
+	if w.Func != "NewService" || w.Type != "Service" || w.Dir != "svc" || w.Line != 5 || len(w.Fields) != len(want) {
+		t.Fatalf("wireup = %+v", w)
+	}
+	for i, f := range want {
+		if w.Fields[i] != f {
+			t.Errorf("field %d = %+v, want %+v", i, w.Fields[i], f)
+		}
+	}
+
+	var buf bytes.Buffer
+	r.PrintReport(&buf)
+	for _, want := range []string{
+		"  Checked:  2 / 3 dependencies",
+		"  NewService  svc.Service  (svc/service.go:5)\n    log  from log  interface may be nil\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+}
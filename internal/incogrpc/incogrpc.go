@@ -0,0 +1,317 @@
+// Package incogrpc serves inco's instrumentation as the gRPC service of
+// instrument.proto (inco serve -grpc), so build systems can run inco as a
+// remote toolchain action. It lives apart from package inco so that
+// importing the engine, as incotest and incolint do, does not pull in grpc.
+package incogrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"net"
+	"sync"
+
+	"github.com/imnive-design/inco-go/internal/inco"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ---------------------------------------------------------------------------
+// gRPC server (inco serve -grpc)
+// ---------------------------------------------------------------------------
+
+// Server serves the inco.v1.Instrumenter service of instrument.proto for
+// one project: Engine.Instrument as a remote call, so build systems can
+// run inco as a remote toolchain action instead of a local binary. As
+// with inco.Server, the engine and its package graph live as long as the
+// server.
+type Server struct {
+	Engine *inco.Engine // configured engine; Instrument calls share it
+	mu     sync.Mutex   // serializes Instrument calls, which must not overlap
+}
+
+// NewServer returns a gRPC server for e.
+func NewServer(e *inco.Engine) *Server {
+	_ = e // @inco: e != nil, -panic("NewServer: nil engine")
+	if !(e != nil) {
+		panic("NewServer: nil engine")
+	}
+	return &Server{Engine: e}
+}
+
+// Serve accepts connections on l until it fails or ctx is done; then it
+// stops taking calls, lets those in flight finish and returns nil.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := grpc.NewServer(grpc.ForceServerCodec(wireCodec{}))
+	srv.RegisterService(&instrumenterService, s)
+	stop := context.AfterFunc(ctx, srv.GracefulStop)
+	defer stop()
+	return srv.Serve(l)
+}
+
+// instrumenter is the handler type of instrumenterService.
+type instrumenter interface {
+	instrument(ctx context.Context, req *instrumentRequest) (*instrumentResponse, error)
+}
+
+// instrumenterService describes inco.v1.Instrumenter for grpc, as
+// protoc-gen-go-grpc would generate it from instrument.proto.
+var instrumenterService = grpc.ServiceDesc{
+	ServiceName: "inco.v1.Instrumenter",
+	HandlerType: (*instrumenter)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Instrument",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(instrumentRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handle := func(ctx context.Context, req any) (any, error) {
+				return srv.(instrumenter).instrument(ctx, req.(*instrumentRequest))
+			}
+			if interceptor == nil {
+				return handle(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/inco.v1.Instrumenter/Instrument"}, handle)
+		},
+	}},
+	Metadata: "instrument.proto",
+}
+
+func (s *Server) instrument(ctx context.Context, req *instrumentRequest) (*instrumentResponse, error) {
+	_ = req.Path // @inco: req.Path != "", -return(nil, status.Error(codes.InvalidArgument, "path is required"))
+	if !(req.Path != "") {
+		return nil, status.Error(codes.InvalidArgument, "path is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.Engine.Instrument(req.Path, req.Content)
+	_ = err // @inco: err == nil, -return(nil, grpcError(err))
+	if !(err == nil) {
+		return nil, grpcError(err)
+	}
+	resp := &instrumentResponse{Content: r.Content, Changed: r.Changed, Warnings: r.Warnings}
+	for _, n := range r.SourceLines {
+		resp.SourceLines = append(resp.SourceLines, int32(n))
+	}
+	return resp, nil
+}
+
+// grpcError returns the status of an Instrument error: INVALID_ARGUMENT
+// for an empty file, a path outside the root, a file that does not parse
+// or a directive inco gen rejects, INTERNAL otherwise. A categorized
+// error carries its category (see inco.ErrorCategory) as the reason of a
+// google.rpc.ErrorInfo in domain "inco".
+func grpcError(err error) error {
+	code := codes.Internal
+	var syntax scanner.ErrorList
+	if errors.Is(err, inco.ErrParseDirective) || errors.Is(err, inco.ErrInvalidFile) || errors.As(err, &syntax) {
+		code = codes.InvalidArgument
+	}
+	st := status.New(code, err.Error())
+	category := inco.ErrorCategory(err)
+	_ = category // @inco: category != "", -return(st.Err())
+	if !(category != "") {
+		return st.Err()
+	}
+	if detailed, derr := st.WithDetails(&errdetails.ErrorInfo{Reason: category, Domain: "inco"}); derr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// ---------------------------------------------------------------------------
+// Messages of instrument.proto
+// ---------------------------------------------------------------------------
+
+// The messages of instrument.proto, encoded with protowire by wireCodec:
+// they are few and small, and the protobuf wire format is stable, so
+// inco needs neither protoc nor generated code for them. Encoding either
+// way lets clients in this package, such as the tests, use the codec too.
+
+type instrumentRequest struct {
+	Path    string
+	Content []byte
+}
+
+type instrumentResponse struct {
+	Content     []byte
+	Changed     bool
+	SourceLines []int32
+	Warnings    []inco.Warning
+}
+
+// wireMessage is a message wireCodec can encode.
+type wireMessage interface {
+	marshalWire() []byte
+	unmarshalWire(b []byte) error
+}
+
+// wireCodec is the grpc codec of the wireMessages, under the name of
+// grpc's protobuf codec so that clients may use theirs.
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return "proto" }
+
+func (wireCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(wireMessage)
+	_ = ok // @inco: ok, -return(nil, fmt.Errorf("inco: cannot marshal %T", v))
+	if !(ok) {
+		return nil, fmt.Errorf("inco: cannot marshal %T", v)
+	}
+	return m.marshalWire(), nil
+}
+
+func (wireCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(wireMessage)
+	_ = ok // @inco: ok, -return(fmt.Errorf("inco: cannot unmarshal %T", v))
+	if !(ok) {
+		return fmt.Errorf("inco: cannot unmarshal %T", v)
+	}
+	return m.unmarshalWire(data)
+}
+
+func (m *instrumentRequest) marshalWire() []byte {
+	var b []byte
+	b = appendString(b, 1, m.Path)
+	return appendBytes(b, 2, m.Content)
+}
+
+func (m *instrumentRequest) unmarshalWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			m.Path = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			m.Content = append([]byte(nil), v...)
+		}
+		return nil
+	})
+}
+
+func (m *instrumentResponse) marshalWire() []byte {
+	var b []byte
+	b = appendBytes(b, 1, m.Content)
+	if m.Changed {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if len(m.SourceLines) > 0 {
+		var packed []byte
+		for _, n := range m.SourceLines {
+			packed = protowire.AppendVarint(packed, uint64(n))
+		}
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, packed)
+	}
+	for _, w := range m.Warnings {
+		var wb []byte
+		wb = appendString(wb, 1, w.Path)
+		if w.Line != 0 {
+			wb = protowire.AppendTag(wb, 2, protowire.VarintType)
+			wb = protowire.AppendVarint(wb, uint64(int32(w.Line)))
+		}
+		wb = appendString(wb, 3, w.Message)
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, wb)
+	}
+	return b
+}
+
+func (m *instrumentResponse) unmarshalWire(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			m.Content = append([]byte(nil), v...)
+		case num == 2 && typ == protowire.VarintType:
+			n, _ := protowire.ConsumeVarint(v)
+			m.Changed = n != 0
+		case num == 3 && typ == protowire.VarintType: // unpacked
+			n, _ := protowire.ConsumeVarint(v)
+			m.SourceLines = append(m.SourceLines, int32(n))
+		case num == 3 && typ == protowire.BytesType: // packed
+			for len(v) > 0 {
+				n, l := protowire.ConsumeVarint(v)
+				_ = l // @inco: l > 0, -return(protowire.ParseError(l))
+				if !(l > 0) {
+					return protowire.ParseError(l)
+				}
+				m.SourceLines = append(m.SourceLines, int32(n))
+				v = v[l:]
+			}
+		case num == 4 && typ == protowire.BytesType:
+			var w inco.Warning
+			err := consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					w.Path = string(v)
+				case num == 2 && typ == protowire.VarintType:
+					n, _ := protowire.ConsumeVarint(v)
+					w.Line = int(int32(n))
+				case num == 3 && typ == protowire.BytesType:
+					w.Message = string(v)
+				}
+				return nil
+			})
+			_ = err // @inco: err == nil, -return(err)
+			if !(err == nil) {
+				return err
+			}
+			m.Warnings = append(m.Warnings, w)
+		}
+		return nil
+	})
+}
+
+// appendString appends field num holding s, unless s is empty.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	_ = s // @inco: s != "", -return(b)
+	if !(s != "") {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendBytes appends field num holding v, unless v is empty.
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	_ = v // @inco: len(v) > 0, -return(b)
+	if !(len(v) > 0) {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// consumeFields calls field with the number, wire type and value of each
+// field of the message b, in order. The value of a varint is its
+// encoding; that of a length-delimited field, its content. Fields of
+// other types are passed as encoded, so unknown fields are skipped.
+func consumeFields(b []byte, field func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		_ = n // @inco: n > 0, -return(protowire.ParseError(n))
+		if !(n > 0) {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		size := protowire.ConsumeFieldValue(num, typ, b)
+		_ = size // @inco: size >= 0, -return(protowire.ParseError(size))
+		if !(size >= 0) {
+			return protowire.ParseError(size)
+		}
+		v := b[:size]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		if err := field(num, typ, v); err != nil {
+			return err
+		}
+		b = b[size:]
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package incogrpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/imnive-design/inco-go/internal/inco"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ---------------------------------------------------------------------------
+// gRPC server
+// ---------------------------------------------------------------------------
+
+const instrumentSrc = `package api
+
+func Get(id string) (string, error) {
+	// @inco: id != "", -return("", errors.New("empty id"))
+	return id, nil
+}
+`
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/api\n\ngo 1.21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := inco.NewEngine(dir)
+	e.Quiet = true
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- NewServer(e).Serve(ctx, l) }()
+
+	conn, err := grpc.NewClient(l.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wireCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	instrument := func(path, src string) (*instrumentResponse, error) {
+		resp := new(instrumentResponse)
+		err := conn.Invoke(context.Background(), "/inco.v1.Instrumenter/Instrument", &instrumentRequest{Path: path, Content: []byte(src)}, resp)
+		return resp, err
+	}
+
+	resp, err := instrument("api/get.go", instrumentSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := e.Instrument("api/get.go", []byte(instrumentSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Changed || string(resp.Content) != string(want.Content) || len(resp.SourceLines) != len(want.SourceLines) {
+		t.Fatalf("Instrument over gRPC = %+v, want %+v", resp, want)
+	}
+	for i, n := range want.SourceLines {
+		if int(resp.SourceLines[i]) != n {
+			t.Errorf("SourceLines[%d] = %d, want %d", i, resp.SourceLines[i], n)
+		}
+	}
+
+	_, err = instrument("api/bad.go", "package api\n\nfunc F(n int) {\n\t// @inco: n >\n}\n")
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || !strings.Contains(st.Message(), "bad.go:4") {
+		t.Errorf("invalid directive: %v", err)
+	}
+	if details := st.Details(); len(details) != 1 || details[0].(*errdetails.ErrorInfo).GetReason() != "invalid-directive" {
+		t.Errorf("details = %v, want the invalid-directive category", details)
+	}
+	if _, err = instrument("", "package api\n"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("call without a path: %v", err)
+	}
+	if _, err = instrument("api/get.go", ""); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("call without content: %v", err)
+	}
+	if _, err = instrument("../get.go", instrumentSrc); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("call with a path outside the root: %v", err)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Serve = %v after cancel", err)
+	}
+}
+
+func TestWireCodec_RoundTrip(t *testing.T) {
+	resp := &instrumentResponse{
+		Content:     []byte("package p\n"),
+		Changed:     true,
+		SourceLines: []int32{1, 0, 300, 301},
+		Warnings:    []inco.Warning{{Path: "p.go", Line: 3, Message: "too many contracts"}, {Message: "no line"}},
+	}
+	var got instrumentResponse
+	if err := got.unmarshalWire(resp.marshalWire()); err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Content) != string(resp.Content) || !got.Changed || len(got.SourceLines) != 4 || got.SourceLines[2] != 300 || len(got.Warnings) != 2 || got.Warnings[0] != resp.Warnings[0] {
+		t.Errorf("round trip = %+v, want %+v", got, resp)
+	}
+	var req instrumentRequest
+	if err := req.unmarshalWire([]byte{0x0a, 0x05}); err == nil {
+		t.Error("truncated message decoded without error")
+	}
+}
@@ -0,0 +1,188 @@
+// Package overlay reads, combines and rewrites the overlay files inco gen
+// writes for go build -overlay, for build tooling that post-processes
+// them, e.g. to move the shadows into another build system's output tree:
+//
+//	ov, err := overlay.Load(".inco_cache/overlay.json")
+//	...
+//	ov, err = ov.CopyShadows("bazel-out/inco")
+//	...
+//	err = ov.Write("bazel-out/inco/overlay.json")
+package overlay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Overlay is the JSON document go build -overlay reads: each source file
+// path maps to the file the go command reads in its place.
+type Overlay struct {
+	Replace map[string]string `json:"Replace"`
+}
+
+// Load reads the overlay file at path, e.g. .inco_cache/overlay.json.
+func Load(path string) (*Overlay, error) {
+	data, err := os.ReadFile(path)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	var o Overlay
+	err = json.Unmarshal(data, &o)
+	_ = err // @inco: err == nil, -return(nil, fmt.Errorf("%s: %w", path, err))
+	if !(err == nil) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if o.Replace == nil {
+		o.Replace = make(map[string]string)
+	}
+	return &o, nil
+}
+
+// Write writes o to path through a temporary file renamed into place, so
+// that a go command reading path never sees it half written.
+func (o *Overlay) Write(path string) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0o644)
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Files returns the source files o replaces, sorted.
+func (o *Overlay) Files() []string {
+	return slices.Sorted(maps.Keys(o.Replace))
+}
+
+// Merge combines overlays, e.g. of several modules built together. A file
+// replaced by more than one of them must map to the same path in each;
+// otherwise Merge returns an error naming the file.
+func Merge(overlays ...*Overlay) (*Overlay, error) {
+	merged := &Overlay{Replace: make(map[string]string)}
+	for _, o := range overlays {
+		for _, src := range o.Files() {
+			shadow := o.Replace[src]
+			prev, ok := merged.Replace[src]
+			_ = prev // @inco: !ok || prev == shadow, -return(nil, fmt.Errorf("overlay: %s is replaced by both %s and %s", src, prev, shadow))
+			if !(!ok || prev == shadow) {
+				return nil, fmt.Errorf("overlay: %s is replaced by both %s and %s", src, prev, shadow)
+			}
+			merged.Replace[src] = shadow
+		}
+	}
+	return merged, nil
+}
+
+// Filter returns the overlay holding the entries of o for which keep
+// returns true.
+func (o *Overlay) Filter(keep func(src, shadow string) bool) *Overlay {
+	filtered := &Overlay{Replace: make(map[string]string)}
+	for src, shadow := range o.Replace {
+		if keep(src, shadow) {
+			filtered.Replace[src] = shadow
+		}
+	}
+	return filtered
+}
+
+// RebasePaths returns o with every path, source file or replacement,
+// that lies under the directory oldRoot moved to the same place under
+// newRoot; other paths are kept. It is for trees that moved, such as
+// sources copied into a build sandbox.
+func (o *Overlay) RebasePaths(oldRoot, newRoot string) *Overlay {
+	rebased := &Overlay{Replace: make(map[string]string, len(o.Replace))}
+	for src, shadow := range o.Replace {
+		rebased.Replace[rebase(src, oldRoot, newRoot)] = rebase(shadow, oldRoot, newRoot)
+	}
+	return rebased
+}
+
+// rebase moves path from under oldRoot to under newRoot, if it lies there.
+func rebase(path, oldRoot, newRoot string) string {
+	rel, err := filepath.Rel(filepath.Clean(oldRoot), filepath.Clean(path))
+	_ = err // @inco: err == nil && filepath.IsLocal(rel), -return(path)
+	if !(err == nil && filepath.IsLocal(rel)) {
+		return path
+	}
+	return filepath.Join(newRoot, rel)
+}
+
+// CopyShadows copies every replacement file of o into dir, which it
+// creates, and returns the overlay mapping each source file to its copy.
+// Replacements with the same base name in different directories are an
+// error. Shadows generated with -trimpath hold //line paths relative to
+// their own directory, so positions in their copies only stay right when
+// dir is at the same place relative to the sources.
+func (o *Overlay) CopyShadows(dir string) (*Overlay, error) {
+	err := os.MkdirAll(dir, 0o755)
+	_ = err // @inco: err == nil, -return(nil, err)
+	if !(err == nil) {
+		return nil, err
+	}
+	copied := &Overlay{Replace: make(map[string]string, len(o.Replace))}
+	from := make(map[string]string) // copy → replacement it was copied from
+	for _, src := range o.Files() {
+		shadow := o.Replace[src]
+		dst := filepath.Join(dir, filepath.Base(shadow))
+		prev, ok := from[dst]
+		_ = prev // @inco: !ok || prev == shadow, -return(nil, fmt.Errorf("overlay: %s and %s would both be copied to %s", prev, shadow, dst))
+		if !(!ok || prev == shadow) {
+			return nil, fmt.Errorf("overlay: %s and %s would both be copied to %s", prev, shadow, dst)
+		}
+		if !ok {
+			err := copyFile(shadow, dst)
+			_ = err // @inco: err == nil, -return(nil, err)
+			if !(err == nil) {
+				return nil, err
+			}
+			from[dst] = shadow
+		}
+		copied.Replace[src] = dst
+	}
+	return copied, nil
+}
+
+// copyFile copies the file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	_ = err // @inco: err == nil, -return(err)
+	if !(err == nil) {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overlay.json")
+	o := &Overlay{Replace: map[string]string{"/src/a.go": "/cache/a_0123456789abcdef.go"}}
+	if err := o.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Replace) != 1 || got.Replace["/src/a.go"] != "/cache/a_0123456789abcdef.go" {
+		t.Errorf("Load = %v", got.Replace)
+	}
+
+	os.WriteFile(path, []byte(`{"Replace": {`), 0o644)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("truncated overlay: err = %v", err)
+	}
+	os.WriteFile(path, []byte(`{}`), 0o644)
+	if got, err := Load(path); err != nil || got.Replace == nil {
+		t.Errorf("empty overlay: %v, %v", got, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := &Overlay{Replace: map[string]string{"/m1/a.go": "/c1/a.go"}}
+	b := &Overlay{Replace: map[string]string{"/m2/b.go": "/c2/b.go", "/m1/a.go": "/c1/a.go"}}
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := merged.Files(); len(got) != 2 || got[0] != "/m1/a.go" || got[1] != "/m2/b.go" {
+		t.Errorf("merged files = %v", got)
+	}
+
+	c := &Overlay{Replace: map[string]string{"/m1/a.go": "/c3/a.go"}}
+	if _, err := Merge(a, c); err == nil || !strings.Contains(err.Error(), "/m1/a.go") {
+		t.Errorf("conflict: err = %v", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	o := &Overlay{Replace: map[string]string{"/m/api/a.go": "/c/a.go", "/m/db/b.go": "/c/b.go"}}
+	api := o.Filter(func(src, _ string) bool { return strings.HasPrefix(src, "/m/api/") })
+	if got := api.Files(); len(got) != 1 || got[0] != "/m/api/a.go" {
+		t.Errorf("filtered files = %v", got)
+	}
+	if len(o.Replace) != 2 {
+		t.Error("Filter modified its receiver")
+	}
+}
+
+func TestRebasePaths(t *testing.T) {
+	o := &Overlay{Replace: map[string]string{
+		"/work/m/a.go":         "/work/m/.inco_cache/a_1.go",
+		"/elsewhere/b.go":      "/tmp/b_2.go",
+		"/work/mod/sibling.go": "/work/mod/.inco_cache/s.go",
+	}}
+	got := o.RebasePaths("/work/m", "/sandbox/m").Replace
+	want := map[string]string{
+		"/sandbox/m/a.go":      "/sandbox/m/.inco_cache/a_1.go",
+		"/elsewhere/b.go":      "/tmp/b_2.go",
+		"/work/mod/sibling.go": "/work/mod/.inco_cache/s.go",
+	}
+	for src, shadow := range want {
+		if got[src] != shadow {
+			t.Errorf("%s => %q, want %q", src, got[src], shadow)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d entries, want %d", len(got), len(want))
+	}
+}
+
+func TestCopyShadows(t *testing.T) {
+	cache := t.TempDir()
+	shadow := filepath.Join(cache, "a_0123456789abcdef.go")
+	os.WriteFile(shadow, []byte("package a\n"), 0o644)
+	o := &Overlay{Replace: map[string]string{"/m/a.go": shadow}}
+
+	out := filepath.Join(t.TempDir(), "bazel-out", "inco")
+	copied, err := o.CopyShadows(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := copied.Replace["/m/a.go"]
+	if dst != filepath.Join(out, "a_0123456789abcdef.go") {
+		t.Errorf("copy at %s", dst)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "package a\n" {
+		t.Errorf("copy holds %q", data)
+	}
+
+	other := filepath.Join(t.TempDir(), "a_0123456789abcdef.go")
+	os.WriteFile(other, []byte("package b\n"), 0o644)
+	o.Replace["/m/b.go"] = other
+	if _, err := o.CopyShadows(out); err == nil {
+		t.Error("two shadows copied to the same name")
+	}
+}